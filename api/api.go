@@ -0,0 +1,9 @@
+// Package api embeds the protocol schema so binaries can publish it.
+package api
+
+import "embed"
+
+// Proto holds the .proto files under proto/.
+//
+//go:embed proto/*.proto
+var Proto embed.FS
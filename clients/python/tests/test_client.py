@@ -0,0 +1,209 @@
+"""Client tests against a scripted in-process server, and against a real
+server when TICKSTORM_ADDR is set (see scripts/check-python-client.sh)."""
+
+import asyncio
+import hashlib
+import hmac
+import os
+import unittest
+
+from tickstorm import Client, ServerError, framing, wire
+
+
+class FakeServer:
+    """Accepts one connection and records the frames it receives. Each
+    handler maps a frame type to the frames sent back."""
+
+    def __init__(self, handlers):
+        self.handlers = handlers
+        self.received = []
+        self.writer = None
+
+    async def start(self):
+        self.server = await asyncio.start_server(self._serve, "127.0.0.1", 0)
+        return self.server.sockets[0].getsockname()[1]
+
+    async def stop(self):
+        if self.writer:
+            self.writer.close()
+        self.server.close()
+        await self.server.wait_closed()
+
+    def push(self, frame_type, name, msg):
+        self.writer.write(framing.encode_frame(frame_type, wire.encode(name, msg)))
+
+    async def _serve(self, reader, writer):
+        self.writer = writer
+        try:
+            while True:
+                frame_type, payload = await framing.read_frame(reader)
+                self.received.append((frame_type, payload))
+                for reply in self.handlers.get(frame_type, lambda p: [])(payload):
+                    self.push(*reply)
+                await writer.drain()
+        except asyncio.IncompleteReadError:
+            pass
+
+
+def ack(ack_type, **extra):
+    return framing.ACK, "AckResponse", dict(ack_type=ack_type, success=True, **extra)
+
+
+class ClientTest(unittest.IsolatedAsyncioTestCase):
+    async def connect(self, handlers, **kwargs):
+        self.server = FakeServer(handlers)
+        port = await self.server.start()
+        self.client = Client("127.0.0.1", port, timeout=2, **kwargs)
+        await self.client.connect()
+        self.addAsyncCleanup(self.server.stop)
+        self.addAsyncCleanup(self.client.close)
+
+    async def test_authenticate_with_password(self):
+        await self.connect({framing.AUTH: lambda p: [ack("MESSAGE_TYPE_AUTH", metadata={"affinity_token": "a1"})]})
+        await self.client.authenticate("demo", "secret")
+        req = wire.decode("AuthRequest", self.server.received[0][1])
+        self.assertEqual(req["username"], "demo")
+        self.assertEqual(req["password"], "secret")
+        self.assertEqual(self.client.affinity_token, "a1")
+
+    async def test_authenticate_with_challenge(self):
+        nonce = b"\x01" * 16
+
+        def auth(payload):
+            req = wire.decode("AuthRequest", payload)
+            if "response" not in req:
+                return [(framing.AUTH_CHALLENGE, "AuthChallenge", {"mechanism": "hmac-sha256", "nonce": nonce})]
+            if req["response"] == hmac.new(b"secret", nonce, hashlib.sha256).digest():
+                return [ack("MESSAGE_TYPE_AUTH")]
+            return [(framing.ERROR, "ErrorResponse", {"code": "ERROR_CODE_INVALID_AUTH"})]
+
+        await self.connect({framing.AUTH: auth})
+        await self.client.authenticate("demo", "secret", challenge=True)
+        for _, payload in self.server.received:
+            self.assertNotIn("password", wire.decode("AuthRequest", payload))
+
+    async def test_error_raises(self):
+        await self.connect({framing.AUTH: lambda p: [(framing.ERROR, "ErrorResponse", {
+            "code": "ERROR_CODE_RATE_LIMITED", "message": "slow down", "retryable": True, "retry_after_ms": 500,
+        })]})
+        with self.assertRaises(ServerError) as cm:
+            await self.client.authenticate("demo", "secret")
+        self.assertEqual(cm.exception.code, "ERROR_CODE_RATE_LIMITED")
+        self.assertTrue(cm.exception.retryable)
+        self.assertEqual(cm.exception.retry_after_ms, 500)
+
+    async def test_stream_acknowledges_batches_and_tracks_pongs(self):
+        infos = []
+
+        def heartbeat(payload):
+            hb = wire.decode("HeartbeatRequest", payload)
+            return [(framing.PONG, "HeartbeatResponse", {
+                "client_timestamp_ms": hb["timestamp_ms"], "server_timestamp_ms": 1, "sequence": hb["sequence"],
+            })]
+
+        await self.connect({
+            framing.AUTH: lambda p: [ack("MESSAGE_TYPE_AUTH")],
+            framing.SUBSCRIBE: lambda p: [ack("MESSAGE_TYPE_SUBSCRIBE", metadata={"resume_token": "tok"})],
+            framing.HEARTBEAT: heartbeat,
+        }, heartbeat_interval=0.01, on_info=infos.append)
+        await self.client.authenticate("demo", "secret")
+        await self.client.subscribe(symbols=["AAPL"], delivery_mode="DELIVERY_MODE_AT_LEAST_ONCE")
+        self.assertEqual(self.client.resume_token, "tok")
+
+        self.server.push(framing.INFO, "InfoMessage", {"code": "INFO_CODE_CLOCK_SKEW"})
+        for seq in (1, 2):
+            self.server.push(framing.DATA_BATCH, "DataBatch", {
+                "ticks": [{"symbol": "AAPL", "price": 189.25}], "batch_sequence": seq,
+            })
+        batches = []
+        async for batch in self.client.batches():
+            batches.append(batch)
+            if len(batches) == 2:
+                # Heartbeats go out meanwhile; the PONGs precede batch 3
+                await asyncio.sleep(0.05)
+                self.server.push(framing.DATA_BATCH, "DataBatch", {"batch_sequence": 3})
+            if len(batches) == 3:
+                break
+        self.assertEqual(batches[0]["ticks"][0]["price"], 189.25)
+        self.assertEqual(infos, [{"code": "INFO_CODE_CLOCK_SKEW"}])
+        self.assertIsNotNone(self.client.rtt_ms)
+
+        await asyncio.sleep(0.05)
+        acked = [wire.decode("BatchAck", p)["batch_sequence"]
+                 for t, p in self.server.received if t == framing.BATCH_ACK]
+        self.assertEqual(acked, [1, 2], "a batch is acknowledged once the caller asks for the next")
+
+    async def auths(self, n):
+        while sum(t == framing.AUTH for t, _ in self.server.received) < n:
+            await asyncio.sleep(0.01)
+
+    async def test_reauth_required_is_answered(self):
+        nonce = b"\x02" * 16
+
+        def auth(payload):
+            req = wire.decode("AuthRequest", payload)
+            if "response" not in req:
+                return [(framing.AUTH_CHALLENGE, "AuthChallenge", {"mechanism": "hmac-sha256", "nonce": nonce})]
+            return [ack("MESSAGE_TYPE_AUTH")]
+
+        await self.connect({
+            framing.AUTH: auth,
+            framing.SUBSCRIBE: lambda p: [ack("MESSAGE_TYPE_SUBSCRIBE")],
+        }, heartbeat_interval=60)
+        await self.client.authenticate("demo", "secret", challenge=True)
+        await self.client.subscribe()
+
+        self.server.push(framing.INFO, "InfoMessage", {"code": "INFO_CODE_REAUTH_REQUIRED", "metadata": {"grace_ms": "5000"}})
+        self.server.push(framing.DATA_BATCH, "DataBatch", {"batch_sequence": 1})
+        async for batch in self.client.batches():
+            break
+        # The challenge is answered from within batches()
+        await self.auths(3)
+        self.server.push(framing.DATA_BATCH, "DataBatch", {"batch_sequence": 2})
+        async for batch in self.client.batches():
+            break
+        await self.auths(4)
+
+        auths = [wire.decode("AuthRequest", p) for t, p in self.server.received if t == framing.AUTH]
+        self.assertEqual(len(auths), 4, "initial challenge exchange plus the renewal")
+        self.assertEqual(auths[3]["response"], hmac.new(b"secret", nonce, hashlib.sha256).digest())
+        self.assertNotIn("password", auths[2])
+
+
+
+@unittest.skipUnless(os.environ.get("TICKSTORM_ADDR"), "TICKSTORM_ADDR not set")
+class LiveServerTest(unittest.IsolatedAsyncioTestCase):
+    """Runs against a real server using STREAM_USER and STREAM_PASS."""
+
+    async def client(self):
+        # The server drops connections from one IP that arrive within 100ms
+        await asyncio.sleep(0.2)
+        host, _, port = os.environ["TICKSTORM_ADDR"].rpartition(":")
+        c = Client(host, int(port), timeout=10)
+        await c.connect()
+        self.addAsyncCleanup(c.close)
+        return c
+
+    async def test_password_auth_heartbeat_and_stream(self):
+        c = await self.client()
+        await c.authenticate(os.environ["STREAM_USER"], os.environ["STREAM_PASS"])
+        await c.heartbeat()
+        self.assertIsNotNone(c.rtt_ms)
+        await c.subscribe("SUBSCRIPTION_MODE_SECOND")
+        async for batch in c.batches():
+            self.assertTrue(batch.get("ticks"))
+            break
+
+    async def test_challenge_auth(self):
+        c = await self.client()
+        await c.authenticate(os.environ["STREAM_USER"], os.environ["STREAM_PASS"], challenge=True)
+
+    async def test_wrong_password_rejected(self):
+        c = await self.client()
+        with self.assertRaises(ServerError) as cm:
+            await c.authenticate(os.environ["STREAM_USER"], os.environ["STREAM_PASS"] + "-wrong")
+        self.assertEqual(cm.exception.code, "ERROR_CODE_INVALID_AUTH")
+
+
+if __name__ == "__main__":
+    unittest.main()
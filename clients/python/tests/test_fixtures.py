@@ -0,0 +1,99 @@
+"""Checks the codec against the golden fixtures in api/fixtures."""
+
+import base64
+import json
+import os
+import unittest
+
+from tickstorm import framing, wire
+
+FIXTURES = os.path.join(os.path.dirname(__file__), "..", "..", "..", "api", "fixtures")
+
+
+def to_json(name, msg):
+    """Converts a decoded message to its proto3 JSON form."""
+    out = {}
+    for spec in wire.SCHEMA[name].values():
+        field, kind = spec[0], spec[1]
+        if field not in msg:
+            continue
+        convert = lambda v: _json_value(kind, v)  # noqa: E731
+        out[field] = [convert(v) for v in msg[field]] if len(spec) > 2 else convert(msg[field])
+    return out
+
+
+def from_json(name, obj):
+    """Converts proto3 JSON to the dicts the codec encodes."""
+    msg = {}
+    for spec in wire.SCHEMA[name].values():
+        field, kind = spec[0], spec[1]
+        if field not in obj:
+            continue
+        convert = lambda v: _value(kind, v)  # noqa: E731
+        msg[field] = [convert(v) for v in obj[field]] if len(spec) > 2 else convert(obj[field])
+    return msg
+
+
+def _json_value(kind, v):
+    if kind in ("int64", "uint64"):
+        return str(v)
+    if kind == "bytes":
+        return base64.b64encode(v).decode()
+    if isinstance(kind, tuple) and kind[0] == "message":
+        return to_json(kind[1], v)
+    return v
+
+
+def _value(kind, v):
+    if kind in ("int64", "uint64"):
+        return int(v)
+    if kind == "bytes":
+        return base64.b64decode(v)
+    if isinstance(kind, tuple) and kind[0] == "message":
+        return from_json(kind[1], v)
+    return v
+
+
+class FixtureTest(unittest.TestCase):
+    @classmethod
+    def setUpClass(cls):
+        with open(os.path.join(FIXTURES, "manifest.json")) as f:
+            cls.manifest = json.load(f)
+
+    def frame(self, entry):
+        with open(os.path.join(FIXTURES, entry["file"]), "rb") as f:
+            data = f.read()
+        self.assertEqual(data.hex(), entry["hex"])
+        return data
+
+    def test_decode(self):
+        for entry in self.manifest:
+            if entry.get("error"):
+                continue
+            with self.subTest(entry["name"]):
+                frame_type, payload = framing.decode_frame(self.frame(entry))
+                self.assertEqual(frame_type, entry["type"])
+                name = entry["message"].rsplit(".", 1)[1]
+                self.assertEqual(to_json(name, wire.decode(name, payload)), entry["fields"])
+
+    def test_encode(self):
+        for entry in self.manifest:
+            if entry.get("error"):
+                continue
+            with self.subTest(entry["name"]):
+                name = entry["message"].rsplit(".", 1)[1]
+                payload = wire.encode(name, from_json(name, entry["fields"]))
+                self.assertEqual(framing.encode_frame(entry["type"], payload), self.frame(entry))
+
+    def test_malformed(self):
+        malformed = [e for e in self.manifest if e.get("error")]
+        self.assertTrue(malformed)
+        for entry in malformed:
+            with self.subTest(entry["name"]):
+                with self.assertRaises(framing.FrameError) as cm:
+                    framing.decode_frame(self.frame(entry))
+                self.assertEqual(str(cm.exception), entry["error"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,14 @@
+"""Reference Python client for the Tick-Storm protocol.
+
+Standard library only; see README.md.
+"""
+
+from .client import Client, ProtocolError, ServerError
+from .framing import FrameError, decode_frame, encode_frame, read_frame
+from .wire import decode, encode
+
+__all__ = [
+    "Client", "ProtocolError", "ServerError",
+    "FrameError", "decode_frame", "encode_frame", "read_frame",
+    "decode", "encode",
+]
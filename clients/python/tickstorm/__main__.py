@@ -0,0 +1,67 @@
+"""Streams ticks to stdout: python -m tickstorm --addr localhost:8080 AAPL MSFT
+
+Credentials come from STREAM_USER and STREAM_PASS.
+"""
+
+import argparse
+import asyncio
+import os
+import ssl
+import sys
+
+from .client import Client
+
+
+def parse_args(argv):
+    p = argparse.ArgumentParser(prog="python -m tickstorm")
+    p.add_argument("--addr", default="localhost:8080", help="server host:port")
+    p.add_argument("--tls", action="store_true", help="connect with TLS")
+    p.add_argument("--insecure", action="store_true", help="skip TLS certificate verification")
+    p.add_argument("--challenge", action="store_true", help="authenticate with HMAC challenge-response")
+    p.add_argument("--mode", choices=["second", "minute"], default="second")
+    p.add_argument("--at-least-once", action="store_true", help="request at-least-once delivery")
+    p.add_argument("--batches", type=int, default=0, help="exit after this many batches (0 runs until interrupted)")
+    p.add_argument("symbols", nargs="*", help="symbols to subscribe to (default all)")
+    return p.parse_args(argv)
+
+
+async def run(args):
+    host, _, port = args.addr.rpartition(":")
+    ctx = None
+    if args.tls:
+        ctx = ssl.create_default_context()
+        if args.insecure:
+            ctx.check_hostname = False
+            ctx.verify_mode = ssl.CERT_NONE
+
+    def info(msg):
+        print("INFO %s: %s %s" % (msg.get("code"), msg.get("message", ""), msg.get("metadata", {})),
+              file=sys.stderr)
+
+    async with Client(host, int(port), ssl=ctx, on_info=info) as c:
+        await c.authenticate(os.environ.get("STREAM_USER", ""), os.environ.get("STREAM_PASS", ""),
+                             challenge=args.challenge)
+        delivery = "DELIVERY_MODE_AT_LEAST_ONCE" if args.at_least_once else "DELIVERY_MODE_UNSPECIFIED"
+        await c.subscribe("SUBSCRIPTION_MODE_" + args.mode.upper(), args.symbols, delivery_mode=delivery)
+        print("subscribed to %s" % (", ".join(args.symbols) or "all symbols"), file=sys.stderr)
+
+        received = 0
+        async for batch in c.batches():
+            for tick in batch.get("ticks", []):
+                print("%s %d price=%.4f volume=%.2f" % (
+                    tick.get("symbol", ""), tick.get("timestamp_ms", 0),
+                    tick.get("price", 0.0), tick.get("volume", 0.0)))
+            received += 1
+            if args.batches and received >= args.batches:
+                return
+
+
+def main(argv=None):
+    try:
+        asyncio.run(run(parse_args(argv)))
+    except KeyboardInterrupt:
+        pass
+
+
+if __name__ == "__main__":
+    main()
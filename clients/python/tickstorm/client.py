@@ -0,0 +1,252 @@
+"""asyncio client for the Tick-Storm stream."""
+
+import asyncio
+import hashlib
+import hmac
+import time
+
+from . import framing, wire
+
+HMAC_SHA256 = "hmac-sha256"
+
+
+class ServerError(Exception):
+    """The server answered with an ERROR frame."""
+
+    def __init__(self, response):
+        self.code = response.get("code", "ERROR_CODE_UNSPECIFIED")
+        self.message = response.get("message", "")
+        self.retryable = response.get("retryable", False)
+        self.retry_after_ms = response.get("retry_after_ms", 0)
+        self.correlation_id = response.get("correlation_id", "")
+        super().__init__("%s: %s" % (self.code, self.message))
+
+
+class ProtocolError(Exception):
+    """The server sent a frame the client did not expect."""
+
+
+def _now_ms():
+    return int(time.time() * 1000)
+
+
+class Client:
+    """A connection to a Tick-Storm server.
+
+    Authenticate, subscribe, then iterate over batches():
+
+        async with Client("localhost", 8080) as c:
+            await c.authenticate("user", "pass")
+            await c.subscribe("SUBSCRIPTION_MODE_SECOND", ["AAPL"])
+            async for batch in c.batches():
+                ...
+
+    Heartbeats are sent every heartbeat_interval seconds once subscribed, which
+    must be shorter than the server's HEARTBEAT_TIMEOUT (20s by default).
+    """
+
+    def __init__(self, host, port, *, ssl=None, client_id="tickstorm-python",
+                 heartbeat_interval=10.0, timeout=10.0, on_info=None, on_status=None):
+        self.host = host
+        self.port = port
+        self.ssl = ssl
+        self.client_id = client_id
+        self.heartbeat_interval = heartbeat_interval
+        self.timeout = timeout
+        self.on_info = on_info  # Called with each INFO message dict
+        self.on_status = on_status  # Called with each STREAM_STATUS message dict
+        self.rtt_ms = None  # Round trip of the last heartbeat
+        self.resume_token = ""
+        self.affinity_token = ""  # Names the instance; see authenticate()
+        self._reader = None
+        self._writer = None
+        self._heartbeats = None
+        self._sequence = 0
+        self._last_pong = None  # (server timestamp, local receive time)
+        self._at_least_once = False
+        self._sent = {}  # Heartbeat sequence -> send time
+        self._auth = None  # (request, password, challenge) to answer REAUTH_REQUIRED
+
+    async def __aenter__(self):
+        await self.connect()
+        return self
+
+    async def __aexit__(self, *exc):
+        await self.close()
+
+    async def connect(self):
+        self._reader, self._writer = await asyncio.wait_for(
+            asyncio.open_connection(self.host, self.port, ssl=self.ssl), self.timeout)
+
+    async def close(self):
+        if self._heartbeats:
+            self._heartbeats.cancel()
+            self._heartbeats = None
+        if self._writer:
+            self._writer.close()
+            try:
+                await self._writer.wait_closed()
+            except (ConnectionError, OSError):
+                pass
+            self._writer = None
+
+    def send(self, frame_type, message_name, message):
+        """Writes message as a frame of frame_type."""
+        self._writer.write(framing.encode_frame(frame_type, wire.encode(message_name, message)))
+
+    async def read(self):
+        """Reads the next frame, returning (type, payload)."""
+        return await asyncio.wait_for(framing.read_frame(self._reader), self.timeout)
+
+    async def _expect(self, frame_type, message_name):
+        while True:
+            got, payload = await self.read()
+            if got == frame_type:
+                return wire.decode(message_name, payload)
+            if got == framing.ERROR:
+                raise ServerError(wire.decode("ErrorResponse", payload))
+            if got == framing.INFO:
+                self._info(payload)
+                continue
+            if got == framing.STREAM_STATUS:
+                self._status(payload)
+                continue
+            if got == framing.KEEPALIVE:
+                continue
+            raise ProtocolError("expected frame type 0x%02X, got 0x%02X" % (frame_type, got))
+
+    def _info(self, payload):
+        info = wire.decode("InfoMessage", payload)
+        if info.get("code") == "INFO_CODE_REAUTH_REQUIRED" and self._auth:
+            self._reauth()
+        if self.on_info:
+            self.on_info(info)
+
+    def _reauth(self):
+        """Sends a fresh AUTH with the credentials given to authenticate(),
+        renewing a session that reached the server's MAX_SESSION_AGE. The
+        ACK, or AUTH_CHALLENGE, is handled by batches()."""
+        request, _, _ = self._auth
+        self.send(framing.AUTH, "AuthRequest", request)
+
+    def _answer_challenge(self, payload):
+        if not self._auth:
+            return
+        request, password, _ = self._auth
+        nonce = wire.decode("AuthChallenge", payload).get("nonce", b"")
+        answer = dict(request, response=hmac.new(password.encode(), nonce, hashlib.sha256).digest())
+        self.send(framing.AUTH, "AuthRequest", answer)
+
+    def _status(self, payload):
+        if self.on_status:
+            self.on_status(wire.decode("StreamStatus", payload))
+
+    async def authenticate(self, username, password, *, challenge=False, traceparent=""):
+        """Authenticates and returns the ACK. With challenge, the password is
+        never sent: the client answers an AUTH_CHALLENGE with
+        HMAC-SHA256(password, nonce) instead. A W3C traceparent links the
+        server's logs for this connection to the caller's trace. The ACK's
+        affinity_token is kept, so a reconnect meant to RESUME can ask the
+        load balancer for the same instance."""
+        request = {"username": username, "client_id": self.client_id, "version": "1.0.0"}
+        if traceparent:
+            request["metadata"] = {"traceparent": traceparent}
+        if challenge:
+            request["mechanism"] = HMAC_SHA256
+        else:
+            request["password"] = password
+        await self._drain(framing.AUTH, "AuthRequest", request)
+
+        if challenge:
+            ch = await self._expect(framing.AUTH_CHALLENGE, "AuthChallenge")
+            nonce = ch.get("nonce", b"")
+            await self._drain(framing.AUTH, "AuthRequest", dict(
+                request, response=hmac.new(password.encode(), nonce, hashlib.sha256).digest()))
+        ack = await self._expect_ack()
+        self._auth = (request, password, challenge)
+        self.affinity_token = ack.get("metadata", {}).get("affinity_token", "")
+        return ack
+
+    async def subscribe(self, mode="SUBSCRIPTION_MODE_SECOND", symbols=(), *,
+                        delivery_mode="DELIVERY_MODE_UNSPECIFIED", request_id="", traceparent=""):
+        """Subscribes, starts heartbeats and returns the ACK."""
+        request = {"mode": mode, "symbols": list(symbols), "delivery_mode": delivery_mode,
+                   "request_id": request_id}
+        if traceparent:
+            request["metadata"] = {"traceparent": traceparent}
+        await self._drain(framing.SUBSCRIBE, "SubscribeRequest", request)
+        ack = await self._expect_ack()
+        self._at_least_once = delivery_mode == "DELIVERY_MODE_AT_LEAST_ONCE"
+        self.resume_token = ack.get("metadata", {}).get("resume_token", "")
+        self._heartbeats = asyncio.get_running_loop().create_task(self._heartbeat_loop())
+        return ack
+
+    async def _expect_ack(self):
+        ack = await self._expect(framing.ACK, "AckResponse")
+        if not ack.get("success"):
+            raise ProtocolError("request not acknowledged: %s" % ack.get("message", ""))
+        return ack
+
+    async def _drain(self, frame_type, message_name, message):
+        self.send(frame_type, message_name, message)
+        await self._writer.drain()
+
+    def send_heartbeat(self):
+        """Sends a heartbeat reporting the last PONG, for the server's RTT and
+        clock skew estimates."""
+        self._sequence += 1
+        hb = {"timestamp_ms": _now_ms(), "sequence": self._sequence}
+        if self._last_pong:
+            hb["pong_server_timestamp_ms"], hb["pong_received_ms"] = self._last_pong
+        self._sent[self._sequence] = time.monotonic()
+        self.send(framing.HEARTBEAT, "HeartbeatRequest", hb)
+
+    async def heartbeat(self):
+        """Sends a heartbeat and waits for its PONG. Use before subscribing;
+        afterwards PONGs are read by batches()."""
+        self.send_heartbeat()
+        await self._writer.drain()
+        self._pong(await self._expect(framing.PONG, "HeartbeatResponse"))
+
+    def _pong(self, pong):
+        sent = self._sent.pop(pong.get("sequence", 0), None)
+        if sent is not None:
+            self.rtt_ms = (time.monotonic() - sent) * 1000
+        self._last_pong = (pong.get("server_timestamp_ms", 0), _now_ms())
+
+    async def _heartbeat_loop(self):
+        while True:
+            await asyncio.sleep(self.heartbeat_interval)
+            self.send_heartbeat()
+            await self._writer.drain()
+
+    async def batches(self):
+        """Yields DATA_BATCH messages until the connection closes. PONG, INFO
+        and STREAM_STATUS frames are handled along the way; an ERROR raises ServerError.
+        REAUTH_REQUIRED is answered with the credentials given to authenticate().
+        With at-least-once delivery each batch is acknowledged after it has
+        been yielded, so a batch the caller did not finish is redelivered."""
+        while True:
+            try:
+                frame_type, payload = await framing.read_frame(self._reader)
+            except asyncio.IncompleteReadError:
+                return
+            if frame_type == framing.DATA_BATCH:
+                batch = wire.decode("DataBatch", payload)
+                yield batch
+                if self._at_least_once:
+                    self.send(framing.BATCH_ACK, "BatchAck", {
+                        "batch_sequence": batch.get("batch_sequence", 0),
+                        "timestamp_ms": _now_ms(),
+                    })
+            elif frame_type == framing.PONG:
+                self._pong(wire.decode("HeartbeatResponse", payload))
+            elif frame_type == framing.INFO:
+                self._info(payload)
+            elif frame_type == framing.STREAM_STATUS:
+                self._status(payload)
+            elif frame_type == framing.AUTH_CHALLENGE:
+                self._answer_challenge(payload)
+            elif frame_type == framing.ERROR:
+                raise ServerError(wire.decode("ErrorResponse", payload))
+            # Other frames, such as ACKs of later requests, are not needed here
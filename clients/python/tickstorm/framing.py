@@ -0,0 +1,130 @@
+"""Tick-Storm frame encoding.
+
+A frame is an 8-byte header, the protobuf payload and a CRC32C trailer:
+
+    [Magic 0xF5 0x7D][Version][Type][Length, big-endian uint32][Payload][CRC32C]
+
+The CRC32C (Castagnoli) covers the header and the payload.
+"""
+
+import struct
+
+MAGIC = b"\xf5\x7d"
+PROTOCOL_VERSION = 0x01
+HEADER_SIZE = 8
+CRC_SIZE = 4
+MAX_PAYLOAD_SIZE = 64 * 1024
+
+# Frame types
+AUTH = 0x01
+SUBSCRIBE = 0x02
+HEARTBEAT = 0x03
+DATA_BATCH = 0x04
+ERROR = 0x05
+ACK = 0x06
+PONG = 0x07
+BATCH_ACK = 0x08
+GAP_FILL = 0x09
+AUTH_CHALLENGE = 0x0A
+INFO = 0x0B
+RESUME = 0x0C
+SYMBOL_LIST = 0x0D
+SUBSCRIPTION_UPDATE = 0x0E
+PUBLISH = 0x0F
+STREAM_STATUS = 0x10
+HISTORY = 0x11
+KEEPALIVE = 0x12
+
+
+class FrameError(ValueError):
+    """A frame was rejected. The messages match the Go server's errors."""
+
+
+class InvalidMagic(FrameError):
+    def __init__(self):
+        super().__init__("invalid magic bytes")
+
+
+class UnsupportedVersion(FrameError):
+    def __init__(self):
+        super().__init__("unsupported protocol version")
+
+
+class InvalidChecksum(FrameError):
+    def __init__(self):
+        super().__init__("invalid checksum")
+
+
+class MessageTooLarge(FrameError):
+    def __init__(self):
+        super().__init__("message too large")
+
+
+class IncompleteFrame(FrameError):
+    def __init__(self):
+        super().__init__("incomplete frame")
+
+
+def _crc32c_table():
+    table = []
+    for i in range(256):
+        crc = i
+        for _ in range(8):
+            crc = (crc >> 1) ^ 0x82F63B78 if crc & 1 else crc >> 1
+        table.append(crc)
+    return table
+
+
+_CRC_TABLE = _crc32c_table()
+
+
+def crc32c(data):
+    crc = 0xFFFFFFFF
+    for b in data:
+        crc = _CRC_TABLE[(crc ^ b) & 0xFF] ^ (crc >> 8)
+    return crc ^ 0xFFFFFFFF
+
+
+def encode_frame(frame_type, payload):
+    """Returns the wire bytes of a frame carrying payload."""
+    if len(payload) > MAX_PAYLOAD_SIZE:
+        raise MessageTooLarge()
+    data = MAGIC + struct.pack(">BBI", PROTOCOL_VERSION, frame_type, len(payload)) + payload
+    return data + struct.pack(">I", crc32c(data))
+
+
+def parse_header(header, max_payload_size=MAX_PAYLOAD_SIZE):
+    """Validates a frame header and returns (type, payload length)."""
+    if header[:2] != MAGIC:
+        raise InvalidMagic()
+    version, frame_type, length = struct.unpack(">BBI", header[2:HEADER_SIZE])
+    if version != PROTOCOL_VERSION:
+        raise UnsupportedVersion()
+    if length > max_payload_size:
+        raise MessageTooLarge()
+    return frame_type, length
+
+
+def decode_frame(data):
+    """Decodes one complete frame, returning (type, payload)."""
+    if len(data) < HEADER_SIZE + CRC_SIZE:
+        raise IncompleteFrame()
+    frame_type, length = parse_header(data[:HEADER_SIZE])
+    if len(data) != HEADER_SIZE + length + CRC_SIZE:
+        raise IncompleteFrame()
+    return frame_type, _check(data[:HEADER_SIZE + length], data[HEADER_SIZE + length:])
+
+
+def _check(body, trailer):
+    (want,) = struct.unpack(">I", trailer)
+    if crc32c(body) != want:
+        raise InvalidChecksum()
+    return bytes(body[HEADER_SIZE:])
+
+
+async def read_frame(reader, max_payload_size=MAX_PAYLOAD_SIZE):
+    """Reads the next frame from an asyncio.StreamReader."""
+    header = await reader.readexactly(HEADER_SIZE)
+    frame_type, length = parse_header(header, max_payload_size)
+    rest = await reader.readexactly(length + CRC_SIZE)
+    return frame_type, _check(header + rest[:length], rest[length:])
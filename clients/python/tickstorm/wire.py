@@ -0,0 +1,334 @@
+"""Minimal proto3 codec for the Tick-Storm messages.
+
+The schema below mirrors api/proto/protocol.proto. It exists so the reference
+client runs on the standard library alone; SDKs that can depend on protobuf
+should generate code from the protocol bundle instead.
+
+Messages are plain dicts keyed by proto field name. Fields at their default
+value are omitted when encoding and absent after decoding, as in proto3.
+"""
+
+import math
+import struct
+
+# Wire types
+VARINT, FIXED64, LEN, FIXED32 = 0, 1, 2, 5
+
+MESSAGE_TYPE = {
+    0: "MESSAGE_TYPE_UNSPECIFIED", 1: "MESSAGE_TYPE_AUTH", 2: "MESSAGE_TYPE_SUBSCRIBE",
+    3: "MESSAGE_TYPE_HEARTBEAT", 4: "MESSAGE_TYPE_DATA_BATCH", 5: "MESSAGE_TYPE_ERROR",
+    6: "MESSAGE_TYPE_ACK", 7: "MESSAGE_TYPE_PONG", 8: "MESSAGE_TYPE_BATCH_ACK",
+    9: "MESSAGE_TYPE_GAP_FILL", 10: "MESSAGE_TYPE_AUTH_CHALLENGE", 11: "MESSAGE_TYPE_INFO",
+    12: "MESSAGE_TYPE_RESUME", 13: "MESSAGE_TYPE_SYMBOL_LIST",
+    14: "MESSAGE_TYPE_SUBSCRIPTION_UPDATE", 15: "MESSAGE_TYPE_PUBLISH",
+    16: "MESSAGE_TYPE_STREAM_STATUS", 17: "MESSAGE_TYPE_HISTORY",
+    18: "MESSAGE_TYPE_KEEPALIVE",
+}
+SUBSCRIPTION_MODE = {
+    0: "SUBSCRIPTION_MODE_UNSPECIFIED", 1: "SUBSCRIPTION_MODE_SECOND", 2: "SUBSCRIPTION_MODE_MINUTE",
+}
+DELIVERY_MODE = {
+    0: "DELIVERY_MODE_UNSPECIFIED", 1: "DELIVERY_MODE_AT_MOST_ONCE", 2: "DELIVERY_MODE_AT_LEAST_ONCE",
+}
+ERROR_CODE = {
+    0: "ERROR_CODE_UNSPECIFIED", 1: "ERROR_CODE_INVALID_AUTH", 2: "ERROR_CODE_AUTH_REQUIRED",
+    3: "ERROR_CODE_ALREADY_AUTHENTICATED", 4: "ERROR_CODE_INVALID_SUBSCRIPTION",
+    5: "ERROR_CODE_ALREADY_SUBSCRIBED", 6: "ERROR_CODE_NOT_SUBSCRIBED",
+    7: "ERROR_CODE_HEARTBEAT_TIMEOUT", 8: "ERROR_CODE_INVALID_MESSAGE",
+    9: "ERROR_CODE_CHECKSUM_FAILED", 10: "ERROR_CODE_PROTOCOL_VERSION",
+    11: "ERROR_CODE_MESSAGE_TOO_LARGE", 12: "ERROR_CODE_RATE_LIMITED",
+    13: "ERROR_CODE_INTERNAL_ERROR", 14: "ERROR_CODE_GAP_UNAVAILABLE",
+    15: "ERROR_CODE_RESUME_FAILED", 16: "ERROR_CODE_SERVER_BUSY", 17: "ERROR_CODE_NOT_PRODUCER",
+    18: "ERROR_CODE_DUPLICATE_SESSION", 19: "ERROR_CODE_SLOW_CONSUMER", 20: "ERROR_CODE_QUOTA_EXCEEDED",
+    21: "ERROR_CODE_SERVER_DRAINING", 22: "ERROR_CODE_NOT_ENTITLED",
+}
+INFO_CODE = {
+    0: "INFO_CODE_UNSPECIFIED", 1: "INFO_CODE_CLOCK_SKEW", 2: "INFO_CODE_SERVER_CLOSING",
+    3: "INFO_CODE_SESSION_OPEN", 4: "INFO_CODE_SESSION_CLOSED", 5: "INFO_CODE_NOTICE",
+    6: "INFO_CODE_REAUTH_REQUIRED",
+}
+STREAM_STATE = {
+    0: "STREAM_STATE_UNSPECIFIED", 1: "STREAM_STATE_LIVE", 2: "STREAM_STATE_HALTED",
+    3: "STREAM_STATE_STALE",
+}
+
+
+def _enum(values):
+    return ("enum", values)
+
+
+def _msg(name):
+    return ("message", name)
+
+
+# Field kinds: string, bytes, int64, uint64, uint32, bool, double, map,
+# ("enum", values) and ("message", name). A third element of True marks a
+# repeated field.
+SCHEMA = {
+    "AuthRequest": {
+        1: ("username", "string"), 2: ("password", "string"), 3: ("client_id", "string"),
+        4: ("version", "string"), 5: ("mechanism", "string"), 6: ("response", "bytes"),
+        7: ("request_id", "string"), 8: ("metadata", "map"),
+    },
+    "AuthChallenge": {
+        1: ("mechanism", "string"), 2: ("nonce", "bytes"), 3: ("timestamp_ms", "int64"),
+    },
+    "SubscribeRequest": {
+        1: ("mode", _enum(SUBSCRIPTION_MODE)), 2: ("symbols", "string", True),
+        3: ("start_time_ms", "int64"), 4: ("metadata", "map"),
+        5: ("delivery_mode", _enum(DELIVERY_MODE)), 6: ("request_id", "string"),
+    },
+    "SubscriptionUpdateRequest": {
+        1: ("add_symbols", "string", True), 2: ("remove_symbols", "string", True),
+        3: ("timestamp_ms", "int64"),
+    },
+    "PublishRequest": {
+        1: ("ticks", _msg("Tick"), True), 2: ("timestamp_ms", "int64"),
+    },
+    "ResumeRequest": {
+        1: ("token", "string"), 2: ("last_sequence", "uint32"), 3: ("timestamp_ms", "int64"),
+    },
+    "HeartbeatRequest": {
+        1: ("timestamp_ms", "int64"), 2: ("sequence", "uint64"),
+        3: ("pong_server_timestamp_ms", "int64"), 4: ("pong_received_ms", "int64"),
+    },
+    "HeartbeatResponse": {
+        1: ("client_timestamp_ms", "int64"), 2: ("server_timestamp_ms", "int64"),
+        3: ("sequence", "uint64"),
+    },
+    "Tick": {
+        1: ("symbol", "string"), 2: ("timestamp_ms", "int64"), 3: ("price", "double"),
+        4: ("volume", "double"), 5: ("bid", "double"), 6: ("ask", "double"),
+        7: ("bid_size", "int64"), 8: ("ask_size", "int64"),
+        9: ("mode", _enum(SUBSCRIPTION_MODE)), 10: ("metadata", "map"),
+    },
+    "DataBatch": {
+        1: ("ticks", _msg("Tick"), True), 2: ("batch_timestamp_ms", "int64"),
+        3: ("batch_sequence", "uint32"), 4: ("is_snapshot", "bool"),
+        5: ("publish_sequence", "uint64"),
+    },
+    "BatchAck": {
+        1: ("batch_sequence", "uint32"), 2: ("timestamp_ms", "int64"),
+    },
+    "GapFillRequest": {
+        1: ("last_sequence", "uint32"), 2: ("timestamp_ms", "int64"),
+    },
+    "ErrorResponse": {
+        1: ("code", _enum(ERROR_CODE)), 2: ("message", "string"), 3: ("details", "string"),
+        4: ("timestamp_ms", "int64"), 5: ("retry_after_ms", "int64"), 6: ("retryable", "bool"),
+        7: ("correlation_id", "string"),
+    },
+    "InfoMessage": {
+        1: ("code", _enum(INFO_CODE)), 2: ("message", "string"), 3: ("metadata", "map"),
+        4: ("timestamp_ms", "int64"),
+    },
+    "StreamStatus": {
+        1: ("symbol", "string"), 2: ("state", _enum(STREAM_STATE)), 3: ("reason", "string"),
+        4: ("timestamp_ms", "int64"),
+    },
+    "AckResponse": {
+        1: ("ack_type", _enum(MESSAGE_TYPE)), 2: ("success", "bool"), 3: ("message", "string"),
+        4: ("timestamp_ms", "int64"), 5: ("metadata", "map"), 6: ("correlation_id", "string"),
+    },
+    "HistoryRequest": {
+        1: ("symbols", "string", True), 2: ("minutes", "uint32"),
+        3: ("mode", _enum(SUBSCRIPTION_MODE)), 4: ("request_id", "string"),
+        5: ("timestamp_ms", "int64"),
+    },
+    "Keepalive": {},
+    "SymbolListRequest": {
+        1: ("prefix", "string"), 2: ("modes", _enum(SUBSCRIPTION_MODE), True),
+        3: ("timestamp_ms", "int64"),
+    },
+    "SymbolInfo": {
+        1: ("symbol", "string"), 2: ("description", "string"), 3: ("tick_size", "double"),
+        4: ("modes", _enum(SUBSCRIPTION_MODE), True), 5: ("currency", "string"),
+    },
+    "SymbolListResponse": {
+        1: ("symbols", _msg("SymbolInfo"), True), 2: ("timestamp_ms", "int64"),
+        3: ("truncated", "bool"),
+    },
+}
+
+_SCALAR_VARINTS = ("int64", "uint64", "uint32", "bool")
+
+
+class DecodeError(ValueError):
+    """The payload is not a valid encoding of the message."""
+
+
+def _put_varint(out, n):
+    n &= (1 << 64) - 1  # Negative int64 values take ten bytes
+    while True:
+        b = n & 0x7F
+        n >>= 7
+        if n:
+            out.append(b | 0x80)
+        else:
+            out.append(b)
+            return
+
+
+def _get_varint(data, pos):
+    result = shift = 0
+    while True:
+        if pos >= len(data):
+            raise DecodeError("truncated varint")
+        b = data[pos]
+        pos += 1
+        result |= (b & 0x7F) << shift
+        if not b & 0x80:
+            return result, pos
+        shift += 7
+        if shift >= 64:
+            raise DecodeError("varint too long")
+
+
+def _is_default(kind, value):
+    if isinstance(kind, tuple):
+        return value is None if kind[0] == "message" else _enum_number(kind, value) == 0
+    if kind == "double":
+        return value == 0 and math.copysign(1, value) > 0  # -0.0 is sent
+    return not value
+
+
+def encode(name, msg):
+    """Encodes the dict msg as the message called name."""
+    out = bytearray()
+    for number, spec in sorted(SCHEMA[name].items()):
+        field, kind = spec[0], spec[1]
+        repeated = len(spec) > 2
+        if field not in msg:
+            continue
+        value = msg[field]
+        if repeated:
+            if not value:
+                continue
+            if kind in _SCALAR_VARINTS or isinstance(kind, tuple) and kind[0] == "enum":
+                packed = bytearray()
+                for v in value:
+                    _put_varint(packed, _enum_number(kind, v))
+                _put_len(out, number, packed)
+            else:
+                for v in value:
+                    _encode_field(out, number, kind, v)
+        elif kind == "map":
+            for k, v in value.items():
+                entry = bytearray()  # Go writes key and value even when empty
+                _put_len(entry, 1, k.encode())
+                _put_len(entry, 2, v.encode())
+                _put_len(out, number, entry)
+        elif not _is_default(kind, value):
+            _encode_field(out, number, kind, value)
+    return bytes(out)
+
+
+def _put_len(out, number, data):
+    _put_varint(out, number << 3 | LEN)
+    _put_varint(out, len(data))
+    out.extend(data)
+
+
+def _enum_number(kind, value):
+    if isinstance(kind, tuple) and kind[0] == "enum" and isinstance(value, str):
+        for number, enum_name in kind[1].items():
+            if enum_name == value:
+                return number
+        raise ValueError("unknown enum value %r" % value)
+    return int(value)
+
+
+def _encode_field(out, number, kind, value):
+    if kind == "string":
+        _put_len(out, number, value.encode())
+    elif kind == "bytes":
+        _put_len(out, number, value)
+    elif kind == "double":
+        _put_varint(out, number << 3 | FIXED64)
+        out.extend(struct.pack("<d", value))
+    elif kind in _SCALAR_VARINTS or kind[0] == "enum":
+        _put_varint(out, number << 3 | VARINT)
+        _put_varint(out, _enum_number(kind, value))
+    else:
+        _put_len(out, number, encode(kind[1], value))
+
+
+def decode(name, data):
+    """Decodes data as the message called name. Unknown fields are skipped."""
+    fields = SCHEMA[name]
+    msg = {}
+    pos = 0
+    while pos < len(data):
+        key, pos = _get_varint(data, pos)
+        number, wire = key >> 3, key & 7
+        if wire == VARINT:
+            raw, pos = _get_varint(data, pos)
+        elif wire == FIXED64:
+            raw, pos = data[pos:pos + 8], pos + 8
+        elif wire == FIXED32:
+            raw, pos = data[pos:pos + 4], pos + 4
+        elif wire == LEN:
+            size, pos = _get_varint(data, pos)
+            raw, pos = data[pos:pos + size], pos + size
+        else:
+            raise DecodeError("unsupported wire type %d" % wire)
+        if pos > len(data):
+            raise DecodeError("truncated field %d" % number)
+        if number not in fields:
+            continue
+
+        spec = fields[number]
+        field, kind = spec[0], spec[1]
+        if len(spec) > 2:
+            if wire == LEN and (kind in _SCALAR_VARINTS or kind[0] == "enum"):
+                p = 0
+                while p < len(raw):
+                    v, p = _get_varint(raw, p)
+                    msg.setdefault(field, []).append(_scalar(kind, v))
+            else:
+                msg.setdefault(field, []).append(_decode_value(kind, wire, raw))
+        elif kind == "map":
+            entry = _decode_map_entry(raw)
+            msg.setdefault(field, {}).update([entry])
+        else:
+            msg[field] = _decode_value(kind, wire, raw)
+    return msg
+
+
+def _decode_map_entry(raw):
+    key = value = ""
+    pos = 0
+    while pos < len(raw):
+        tag, pos = _get_varint(raw, pos)
+        size, pos = _get_varint(raw, pos)
+        text = bytes(raw[pos:pos + size]).decode()
+        pos += size
+        if tag >> 3 == 1:
+            key = text
+        elif tag >> 3 == 2:
+            value = text
+    return key, value
+
+
+def _scalar(kind, v):
+    if kind == "int64" and v >= 1 << 63:
+        return v - (1 << 64)
+    if kind == "uint32":
+        return v & 0xFFFFFFFF
+    if kind == "bool":
+        return bool(v)
+    if isinstance(kind, tuple) and kind[0] == "enum":
+        return kind[1].get(v, v)
+    return v
+
+
+def _decode_value(kind, wire, raw):
+    if kind == "string":
+        return bytes(raw).decode()
+    if kind == "bytes":
+        return bytes(raw)
+    if kind == "double":
+        return struct.unpack("<d", raw)[0]
+    if kind in _SCALAR_VARINTS or kind[0] == "enum":
+        return _scalar(kind, raw)
+    return decode(kind[1], raw)
@@ -0,0 +1,67 @@
+// Command conformance connects to a Tick-Storm server and checks that it
+// follows the wire protocol, printing a pass/fail report. It exits non-zero
+// when any check fails.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+
+	"github.com/furkansarikaya/tick-storm/internal/conformance"
+)
+
+func main() {
+	cfg := conformance.DefaultConfig()
+	var useTLS, insecure, list bool
+	var run string
+	maxMessageSize := uint(cfg.MaxMessageSize)
+
+	flag.StringVar(&cfg.Addr, "addr", cfg.Addr, "server address")
+	flag.StringVar(&cfg.Username, "user", cfg.Username, "username (default STREAM_USER)")
+	flag.StringVar(&cfg.Password, "pass", cfg.Password, "password (default STREAM_PASS)")
+	flag.BoolVar(&useTLS, "tls", false, "connect with TLS")
+	flag.BoolVar(&insecure, "insecure", false, "skip TLS certificate verification")
+	flag.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "how long to wait for each response")
+	flag.DurationVar(&cfg.CloseWait, "close-wait", cfg.CloseWait, "how long a rejected connection is watched for further responses")
+	flag.DurationVar(&cfg.ConnectInterval, "connect-interval", cfg.ConnectInterval, "pause between connections, to stay under connection rate limits")
+	flag.UintVar(&maxMessageSize, "max-message-size", maxMessageSize, "the server's frame payload limit in bytes")
+	flag.DurationVar(&cfg.HeartbeatTimeout, "heartbeat-timeout", cfg.HeartbeatTimeout, "the server's heartbeat timeout (0 skips the timeout check)")
+	flag.StringVar(&run, "run", "", "only run checks whose names match this regular expression")
+	flag.BoolVar(&list, "list", false, "list the checks and exit")
+	flag.Parse()
+
+	if list {
+		for _, check := range conformance.Checks {
+			fmt.Printf("%-28s %s\n", check.Name, check.Description)
+		}
+		return
+	}
+
+	cfg.MaxMessageSize = uint32(maxMessageSize)
+	if useTLS {
+		cfg.TLS = &tls.Config{InsecureSkipVerify: insecure}
+	}
+	var filter *regexp.Regexp
+	if run != "" {
+		var err error
+		if filter, err = regexp.Compile(run); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run pattern: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report := conformance.Run(ctx, cfg, filter)
+	report.Print(os.Stdout)
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,205 @@
+// Command fuzz-client sends mutated protocol frames to a Tick-Storm server
+// to shake out panics, unbounded allocations, and checksum bypasses.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// mutation transforms a well-formed frame into a (possibly) malformed one.
+type mutation struct {
+	name  string
+	apply func(rng *rand.Rand, data []byte) []byte
+}
+
+var mutations = []mutation{
+	{"bit_flip", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		i := rng.Intn(len(out))
+		out[i] ^= 1 << uint(rng.Intn(8))
+		return out
+	}},
+	{"truncate", func(rng *rand.Rand, data []byte) []byte {
+		return append([]byte(nil), data[:rng.Intn(len(data))]...)
+	}},
+	{"bad_checksum", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		binary.BigEndian.PutUint32(out[len(out)-protocol.CRCSize:], rng.Uint32())
+		return out
+	}},
+	{"bad_magic", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		out[0], out[1] = byte(rng.Intn(256)), byte(rng.Intn(256))
+		return out
+	}},
+	{"huge_length", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		binary.BigEndian.PutUint32(out[4:8], 0xFFFFFFFF-uint32(rng.Intn(1024)))
+		return resign(out)
+	}},
+	{"random_type", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		out[3] = byte(rng.Intn(256))
+		return resign(out)
+	}},
+	{"payload_garbage", func(rng *rand.Rand, data []byte) []byte {
+		out := append([]byte(nil), data...)
+		for i := protocol.FrameHeaderSize; i < len(out)-protocol.CRCSize; i++ {
+			out[i] = byte(rng.Intn(256))
+		}
+		return resign(out)
+	}},
+}
+
+// resign recomputes the CRC32C trailer so the mutation reaches the payload decoder.
+func resign(data []byte) []byte {
+	if len(data) < protocol.MinFrameSize {
+		return data
+	}
+	checksumStart := len(data) - protocol.CRCSize
+	sum := crc32.Checksum(data[:checksumStart], crc32.MakeTable(crc32.Castagnoli))
+	binary.BigEndian.PutUint32(data[checksumStart:], sum)
+	return data
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "server address")
+	iterations := flag.Int("n", 1000, "number of mutated frames to send")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed for reproducible runs")
+	authFirst := flag.Bool("auth", true, "authenticate with STREAM_USER/STREAM_PASS before sending mutations")
+	timeout := flag.Duration("timeout", 2*time.Second, "per-connection I/O timeout")
+	flag.Parse()
+
+	rng := rand.New(rand.NewSource(*seed))
+	log.Printf("fuzzing %s with seed %d", *addr, *seed)
+
+	templates, err := buildTemplates()
+	if err != nil {
+		log.Fatalf("failed to build frame templates: %v", err)
+	}
+
+	results := make(map[string]int)
+	dialFailures := 0
+	for i := 0; i < *iterations; i++ {
+		m := mutations[rng.Intn(len(mutations))]
+		data := m.apply(rng, templates[rng.Intn(len(templates))])
+
+		outcome, err := sendMutated(*addr, data, *authFirst, *timeout)
+		if err != nil {
+			dialFailures++
+			if dialFailures > 10 {
+				log.Fatalf("server unreachable after %d mutations (last: %s): %v", i, m.name, err)
+			}
+			continue
+		}
+		results[m.name+"/"+outcome]++
+	}
+
+	for key, count := range results {
+		fmt.Printf("%-32s %d\n", key, count)
+	}
+	if dialFailures > 0 {
+		fmt.Fprintf(os.Stderr, "dial failures: %d\n", dialFailures)
+	}
+}
+
+// buildTemplates returns well-formed AUTH, SUBSCRIBE and HEARTBEAT frames to mutate.
+func buildTemplates() ([][]byte, error) {
+	msgs := []struct {
+		msgType protocol.MessageType
+		msg     proto.Message
+	}{
+		{protocol.MessageTypeAuth, &pb.AuthRequest{Username: "fuzz", Password: "fuzz", ClientId: "fuzz-client"}},
+		{protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, Symbols: []string{"AAPL"}}},
+		{protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 1}},
+	}
+
+	templates := make([][]byte, 0, len(msgs))
+	for _, m := range msgs {
+		frame, err := protocol.MarshalMessage(m.msgType, m.msg)
+		if err != nil {
+			return nil, err
+		}
+		data, err := frame.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, data)
+	}
+	return templates, nil
+}
+
+// sendMutated opens a connection, optionally authenticates, writes the mutated
+// frame and classifies how the server reacted.
+func sendMutated(addr string, data []byte, authFirst bool, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	reader := protocol.NewFrameReader(conn, 0)
+	if authFirst {
+		if err := authenticate(conn, reader); err != nil {
+			return "auth_failed", nil
+		}
+	}
+
+	if _, err := conn.Write(data); err != nil {
+		return "write_error", nil
+	}
+
+	frame, err := reader.ReadFrame()
+	switch {
+	case err == nil && frame.Type == protocol.MessageTypeError:
+		return "error_frame", nil
+	case err == nil:
+		return fmt.Sprintf("type_%d", frame.Type), nil
+	case err == io.EOF:
+		return "closed", nil
+	default:
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return "timeout", nil
+		}
+		return "closed", nil
+	}
+}
+
+// authenticate performs the AUTH handshake using credentials from the environment.
+func authenticate(conn net.Conn, reader *protocol.FrameReader) error {
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: os.Getenv("STREAM_USER"),
+		Password: os.Getenv("STREAM_PASS"),
+		ClientId: "fuzz-client",
+		Version:  "1.0.0",
+	})
+	if err != nil {
+		return err
+	}
+	if err := protocol.NewFrameWriter(conn).WriteFrame(frame); err != nil {
+		return err
+	}
+
+	resp, err := reader.ReadFrame()
+	if err != nil {
+		return err
+	}
+	if resp.Type != protocol.MessageTypeACK {
+		return fmt.Errorf("unexpected response type %d", resp.Type)
+	}
+	return nil
+}
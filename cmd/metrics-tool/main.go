@@ -0,0 +1,110 @@
+// Command metrics-tool generates the Grafana dashboard and Prometheus alert
+// rules from the metrics registered by the server, and checks committed
+// artifacts against them.
+//
+// Usage:
+//
+//	metrics-tool dashboard [-o file]
+//	metrics-tool rules [-o file]
+//	metrics-tool check [-dashboard file] [-rules file]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/furkansarikaya/tick-storm/internal/observability"
+)
+
+const (
+	defaultDashboardPath = "monitoring/grafana/tickstorm-overview.json"
+	defaultRulesPath     = "monitoring/prometheus-rules.yml"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "dashboard":
+		err = generate(os.Args[2:], "dashboard", observability.GenerateDashboard)
+	case "rules":
+		err = generate(os.Args[2:], "rules", observability.GenerateAlertRules)
+	case "check":
+		err = check(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "metrics-tool %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: metrics-tool dashboard|rules|check [flags]")
+}
+
+func generate(args []string, name string, gen func(*observability.Catalog) ([]byte, error)) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	out := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	data, err := gen(observability.ServerCatalog())
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0o644)
+}
+
+// check fails if a committed artifact differs from what would be generated
+// now, or references a metric the server no longer registers.
+func check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	dashboardPath := fs.String("dashboard", defaultDashboardPath, "dashboard file to check")
+	rulesPath := fs.String("rules", defaultRulesPath, "alert rules file to check")
+	fs.Parse(args)
+
+	catalog := observability.ServerCatalog()
+	var problems []string
+	for _, a := range []struct {
+		path string
+		gen  func(*observability.Catalog) ([]byte, error)
+		cmd  string
+	}{
+		{*dashboardPath, observability.GenerateDashboard, "dashboard"},
+		{*rulesPath, observability.GenerateAlertRules, "rules"},
+	} {
+		committed, err := os.ReadFile(a.path)
+		if err != nil {
+			return err
+		}
+		if unknown := catalog.Unknown(string(committed)); len(unknown) > 0 {
+			problems = append(problems, fmt.Sprintf("%s references unregistered metrics: %s",
+				a.path, strings.Join(unknown, ", ")))
+		}
+		want, err := a.gen(catalog)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(committed, want) {
+			problems = append(problems, fmt.Sprintf("%s is stale; run: metrics-tool %s -o %s",
+				a.path, a.cmd, a.path))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
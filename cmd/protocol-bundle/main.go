@@ -0,0 +1,61 @@
+// Command protocol-bundle writes the protocol bundle for client SDK
+// generation: the framing descriptor, the .proto schema and the golden
+// fixtures in one versioned archive.
+//
+// Usage:
+//
+//	protocol-bundle [-o dir]
+//	protocol-bundle -descriptor
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/spec"
+)
+
+func main() {
+	dir := flag.String("o", "dist", "output directory")
+	descriptor := flag.Bool("descriptor", false, "print the framing descriptor and exit")
+	flag.Parse()
+
+	if err := run(*dir, *descriptor); err != nil {
+		fmt.Fprintf(os.Stderr, "protocol-bundle: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, descriptor bool) error {
+	if descriptor {
+		d, err := spec.Describe()
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	bundle, err := spec.Bundle()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	out := filepath.Join(dir, spec.BundleName())
+	if err := os.WriteFile(out, bundle, 0o644); err != nil {
+		return err
+	}
+	// sha256sum -c format
+	sum := fmt.Sprintf("%s  %s\n", spec.Digest(bundle), spec.BundleName())
+	if err := os.WriteFile(out+".sha256", []byte(sum), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (%d bytes)\n", out, len(bundle))
+	return nil
+}
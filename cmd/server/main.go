@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
+	"bufio"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/logging"
 	"github.com/furkansarikaya/tick-storm/internal/server"
 )
 
 func main() {
 	// Command line flags
 	healthCheck := flag.Bool("health-check", false, "Perform health check and exit")
+	hashPassword := flag.Bool("hash-password", false, "Read a password from stdin, print its hash for STREAM_PASS_HASH and exit")
+	check := flag.Bool("check", false, "Validate configuration, TLS files, ulimits, ports, data source and credentials, print a report and exit")
 	flag.Parse()
 
 	// Handle health check
@@ -24,21 +31,47 @@ func main() {
 		return
 	}
 
+	if *hashPassword {
+		printPasswordHash()
+		return
+	}
+
+	if *check {
+		runPreflight()
+		return
+	}
+
+	// Set up logging before anything else logs
+	logConfig := logging.DefaultConfig()
+	logEnvErr := logging.LoadConfigFromEnv(logConfig)
+	logger, logCloser, err := logging.New(logConfig)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	defer logCloser.Close()
+	slog.SetDefault(logger)
+	if logEnvErr != nil {
+		logger.Warn("invalid logging configuration", "error", logEnvErr)
+	}
+
 	// Load configuration
 	config := server.DefaultConfig()
 	server.LoadConfigFromEnv(config)
+	config.Logger = logger
 
 	// Create server
 	srv := server.NewServer(config)
 
 	// Start server
-	log.Printf("Starting Tick-Storm TCP server on %s", config.ListenAddr)
+	logger.Info("starting Tick-Storm TCP server", "addr", config.ListenAddr)
 	if err := srv.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		logger.Error("failed to start server", "error", err)
+		logCloser.Close()
+		os.Exit(1)
 	}
 
 	// Get actual listen address (useful when using port 0)
-	log.Printf("Server listening on %s", srv.ListenAddr())
+	logger.Info("server listening", "addr", srv.ListenAddr())
 
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
@@ -46,24 +79,18 @@ func main() {
 
 	// Wait for shutdown signal
 	<-sigChan
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := srv.Stop(ctx); err != nil {
-		log.Printf("Error during shutdown: %v", err)
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("error during shutdown", "error", err)
 	}
 
-	// Print final stats
-	stats := srv.GetStats()
-	fmt.Println("\nFinal server statistics:")
-	for key, value := range stats {
-		fmt.Printf("  %s: %v\n", key, value)
-	}
-
-	log.Println("Server stopped")
+	// Log final stats
+	logger.Info("server stopped", "stats", srv.GetStats())
 }
 
 // performHealthCheck performs a basic health check for container health checks
@@ -76,3 +103,77 @@ func performHealthCheck() {
 	log.Println("Health check: OK")
 	os.Exit(0)
 }
+
+// printPasswordHash hashes the first line of stdin using PASSWORD_HASH_* settings
+func printPasswordHash() {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		log.Fatal("Password must not be empty")
+	}
+
+	hash, err := auth.HashPassword(password, auth.DefaultHashParams())
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+	fmt.Println(hash)
+}
+
+// preflightTimeout bounds the secrets backend and data source probes of --check.
+const preflightTimeout = 30 * time.Second
+
+// runPreflight loads configuration as a normal start would, prints the
+// preflight report and exits non-zero if any check failed. Environment
+// values the loaders ignore as invalid fail the check too.
+func runPreflight() {
+	recorder := &warningRecorder{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(recorder))
+	logErr := logging.LoadConfigFromEnv(logging.DefaultConfig())
+	config := server.DefaultConfig()
+	server.LoadConfigFromEnv(config)
+	slog.SetDefault(previous)
+
+	env := server.PreflightCheck{Name: "environment", Status: server.PreflightOK, Detail: "all settings parsed"}
+	if logErr != nil {
+		recorder.messages = append(recorder.messages, "invalid logging configuration: "+logErr.Error())
+	}
+	if len(recorder.messages) > 0 {
+		env.Status, env.Detail = server.PreflightFail, strings.Join(recorder.messages, "; ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+	report := server.Preflight(ctx, config)
+	report.Checks = append([]server.PreflightCheck{env}, report.Checks...)
+	report.WriteTo(os.Stdout)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+// warningRecorder is a slog handler keeping the warnings logged while the
+// environment is parsed.
+type warningRecorder struct {
+	messages []string
+}
+
+func (h *warningRecorder) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn
+}
+
+func (h *warningRecorder) Handle(_ context.Context, record slog.Record) error {
+	msg := record.Message
+	record.Attrs(func(a slog.Attr) bool {
+		msg += " " + a.String()
+		return true
+	})
+	h.messages = append(h.messages, msg)
+	return nil
+}
+
+func (h *warningRecorder) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *warningRecorder) WithGroup(string) slog.Handler      { return h }
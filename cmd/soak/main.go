@@ -0,0 +1,49 @@
+// Command soak runs the Tick-Storm server with synthetic clients in-process
+// and fails if goroutines, memory, or pool backlogs grow without bound.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/furkansarikaya/tick-storm/internal/soak"
+)
+
+func main() {
+	cfg := soak.DefaultConfig()
+	soak.LoadConfigFromEnv(cfg)
+
+	flag.DurationVar(&cfg.Duration, "duration", cfg.Duration, "total soak duration")
+	flag.DurationVar(&cfg.Warmup, "warmup", cfg.Warmup, "time to wait before capturing the baseline")
+	flag.DurationVar(&cfg.SampleInterval, "sample-interval", cfg.SampleInterval, "interval between resource samples")
+	flag.IntVar(&cfg.Clients, "clients", cfg.Clients, "number of synthetic clients")
+	flag.DurationVar(&cfg.ClientLifetime, "client-lifetime", cfg.ClientLifetime, "average client session length before reconnect (0 = never)")
+	flag.IntVar(&cfg.MaxGoroutineGrowth, "max-goroutine-growth", cfg.MaxGoroutineGrowth, "allowed goroutine growth over baseline")
+	flag.Int64Var(&cfg.MaxRSSGrowthMB, "max-rss-growth-mb", cfg.MaxRSSGrowthMB, "allowed RSS growth over baseline in MB")
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report, err := soak.NewRunner(cfg, logger).Run(ctx)
+	if report != nil {
+		fmt.Printf("samples=%d reconnects=%d batches=%d client_errors=%d\n",
+			len(report.Samples), report.Reconnects, report.Batches, report.ClientErrors)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "soak failed: %v\n", err)
+		if report != nil && report.LeakDiff != "" {
+			fmt.Fprintf(os.Stderr, "\nleaked goroutine stacks:\n%s", report.LeakDiff)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("soak passed")
+}
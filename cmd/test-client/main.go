@@ -8,6 +8,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/furkansarikaya/tick-storm/internal/auth"
 	"github.com/furkansarikaya/tick-storm/internal/protocol"
 	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 	"google.golang.org/protobuf/proto"
@@ -41,6 +42,14 @@ func main() {
 		log.Fatal("STREAM_USER and STREAM_PASS environment variables must be set")
 	}
 
+	// Challenge-response keeps the password off the wire
+	password := authReq.Password
+	useChallenge := os.Getenv("STREAM_AUTH_CHALLENGE") == "true"
+	if useChallenge {
+		authReq.Password = ""
+		authReq.Mechanism = protocol.AuthMechanismHMACSHA256
+	}
+
 	payload, err := proto.Marshal(authReq)
 	if err != nil {
 		log.Fatalf("Failed to marshal auth request: %v", err)
@@ -63,6 +72,26 @@ func main() {
 		log.Fatalf("Failed to read AUTH response: %v", err)
 	}
 
+	if useChallenge && respFrame.Type == protocol.MessageTypeAuthChallenge {
+		var challenge pb.AuthChallenge
+		if err := proto.Unmarshal(respFrame.Payload, &challenge); err != nil {
+			log.Fatalf("Failed to unmarshal AUTH challenge: %v", err)
+		}
+		authReq.Response = auth.ChallengeResponse(password, challenge.Nonce)
+		payload, err := proto.Marshal(authReq)
+		if err != nil {
+			log.Fatalf("Failed to marshal challenge response: %v", err)
+		}
+		if err := sendFrame(conn, &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}); err != nil {
+			log.Fatalf("Failed to send challenge response: %v", err)
+		}
+		log.Println("Answered AUTH challenge")
+
+		if respFrame, err = readFrame(conn); err != nil {
+			log.Fatalf("Failed to read AUTH response: %v", err)
+		}
+	}
+
 	if respFrame.Type == protocol.MessageTypeACK {
 		var ack pb.AckResponse
 		if err := proto.Unmarshal(respFrame.Payload, &ack); err != nil {
@@ -162,6 +191,17 @@ func main() {
 						}
 					}
 
+				case protocol.MessageTypeInfo:
+					var info pb.InfoMessage
+					if err := proto.Unmarshal(frame.Payload, &info); err != nil {
+						log.Printf("Failed to unmarshal info message: %v", err)
+						continue
+					}
+					log.Printf("Server notice %s: %s %v", info.Code, info.Message, info.Metadata)
+
+				case protocol.MessageTypeKeepalive:
+					// Sent on idle connections; nothing to do
+
 				default:
 					log.Printf("Received frame type: %d", frame.Type)
 				}
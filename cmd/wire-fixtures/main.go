@@ -0,0 +1,67 @@
+// Command wire-fixtures writes the golden wire-format fixtures, one frame per
+// file plus a JSON manifest, and checks committed fixtures against them.
+//
+// Usage:
+//
+//	wire-fixtures [-o dir]
+//	wire-fixtures -check [-o dir]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/golden"
+)
+
+const defaultDir = "api/fixtures"
+
+func main() {
+	dir := flag.String("o", defaultDir, "fixture directory")
+	check := flag.Bool("check", false, "check the directory instead of writing it")
+	flag.Parse()
+
+	files, err := golden.Files()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "wire-fixtures: %v\n", err)
+		os.Exit(1)
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if *check {
+		stale := 0
+		for _, name := range names {
+			committed, err := os.ReadFile(filepath.Join(*dir, name))
+			if err != nil || !bytes.Equal(committed, files[name]) {
+				fmt.Fprintf(os.Stderr, "%s is stale or missing\n", filepath.Join(*dir, name))
+				stale++
+			}
+		}
+		if stale > 0 {
+			fmt.Fprintln(os.Stderr, "run make wire-fixtures to regenerate")
+			os.Exit(1)
+		}
+		fmt.Printf("%d fixtures up to date\n", len(names)-1)
+		return
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "wire-fixtures: %v\n", err)
+		os.Exit(1)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(*dir, name), files[name], 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "wire-fixtures: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Wrote %d fixtures to %s\n", len(names)-1, *dir)
+}
@@ -3,22 +3,27 @@ package auth
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"strconv"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/furkansarikaya/tick-storm/internal/protocol"
 	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
 	"google.golang.org/protobuf/proto"
 )
 
 var (
-	// ErrInvalidCredentials indicates authentication failed due to invalid credentials.
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidCredentials indicates authentication failed due to invalid
+	// credentials. It is the same error custom authenticators return.
+	ErrInvalidCredentials = authn.ErrInvalidCredentials
 	
 	// ErrAuthRequired indicates authentication is required but not provided.
 	ErrAuthRequired = errors.New("authentication required")
@@ -30,19 +35,44 @@ var (
 	ErrAuthTimeout = errors.New("authentication timeout")
 	
 	// ErrRateLimited indicates too many authentication attempts.
-	ErrRateLimited = errors.New("rate limited")
+	ErrRateLimited = authn.ErrRateLimited
+	
+	// ErrUnavailable indicates a custom authentication backend could not
+	// decide; such attempts do not count towards the rate limit.
+	ErrUnavailable = authn.ErrUnavailable
 	
 	// ErrFirstFrameMustBeAuth indicates the first frame must be an AUTH frame.
 	ErrFirstFrameMustBeAuth = errors.New("first frame must be AUTH")
+	
+	// ErrChallengeRequired indicates a plaintext password was sent while
+	// challenge-response authentication is mandatory.
+	ErrChallengeRequired = errors.New("challenge-response authentication required")
+	
+	// ErrUnsupportedMechanism indicates an unknown challenge mechanism.
+	ErrUnsupportedMechanism = errors.New("unsupported auth mechanism")
+	
+	// ErrNoChallenge indicates a challenge response without an outstanding challenge.
+	ErrNoChallenge = errors.New("no outstanding auth challenge")
 )
 
 // Config holds authentication configuration.
 type Config struct {
 	Username        string
 	Password        string
+	PasswordHash    string // bcrypt or argon2id hash; checked instead of Password when set
 	Timeout         time.Duration
 	MaxAttempts     int
 	RateLimitWindow time.Duration
+	
+	// RequireChallenge rejects AUTH frames carrying a plaintext password
+	RequireChallenge bool
+	
+	// Per-username lockout, whatever the source IP: LockoutThreshold
+	// consecutive failures lock the username out for LockoutBase, doubling
+	// with each further failure up to LockoutMax (0 threshold disables)
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
 }
 
 // DefaultConfig returns default authentication configuration.
@@ -50,9 +80,12 @@ func DefaultConfig() *Config {
 	cfg := &Config{
 		Username:        os.Getenv("STREAM_USER"),
 		Password:        os.Getenv("STREAM_PASS"),
+		PasswordHash:    os.Getenv("STREAM_PASS_HASH"),
 		Timeout:         30 * time.Second,
 		MaxAttempts:     3,
 		RateLimitWindow: 1 * time.Minute,
+		LockoutBase:     1 * time.Minute,
+		LockoutMax:      1 * time.Hour,
 	}
 
 	// Optional overrides
@@ -66,6 +99,26 @@ func DefaultConfig() *Config {
 			cfg.RateLimitWindow = d
 		}
 	}
+	if v := os.Getenv("AUTH_REQUIRE_CHALLENGE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.RequireChallenge = b
+		}
+	}
+	if v := os.Getenv("AUTH_LOCKOUT_THRESHOLD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.LockoutThreshold = n
+		}
+	}
+	if v := os.Getenv("AUTH_LOCKOUT_BASE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.LockoutBase = d
+		}
+	}
+	if v := os.Getenv("AUTH_LOCKOUT_MAX"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.LockoutMax = d
+		}
+	}
 
 	return cfg
 }
@@ -74,8 +127,32 @@ func DefaultConfig() *Config {
 type Authenticator struct {
 	config      *Config
 	rateLimiter *RateLimiter
-	mu          sync.RWMutex
-	sessions    map[string]*Session
+	lockout     *userLockout // nil when per-username lockout is disabled
+	onLockout   atomic.Pointer[LockoutHandler]
+	sessions    sessionStore // Sessions and outstanding challenges by connection ID
+	creds       atomic.Pointer[Credentials]
+	previous    atomic.Pointer[rotatedCredentials] // Replaced credentials still accepted
+}
+
+// Credentials are the username and secret clients authenticate against.
+type Credentials struct {
+	Username     string
+	Password     string
+	PasswordHash string // Checked instead of Password when set
+}
+
+// rotatedCredentials are replaced credentials accepted until their grace
+// period ends.
+type rotatedCredentials struct {
+	Credentials
+	until time.Time
+}
+
+// Challenge is a nonce issued to a client that requested challenge-response auth.
+type Challenge struct {
+	Username  string
+	Mechanism string
+	Nonce     []byte
 }
 
 // Session represents an authenticated session.
@@ -85,6 +162,11 @@ type Session struct {
 	Authenticated bool
 	AuthTime      time.Time
 	LastActivity  time.Time
+	Attributes    map[string]string // Claims from a custom authenticator
+	
+	// PreviousCredentials is set when the client authenticated with
+	// credentials replaced by a rotation, during their grace period
+	PreviousCredentials bool
 }
 
 // NewAuthenticator creates a new authenticator.
@@ -93,11 +175,188 @@ func NewAuthenticator(config *Config) *Authenticator {
 		config = DefaultConfig()
 	}
 	
-	return &Authenticator{
+	a := &Authenticator{
 		config:      config,
 		rateLimiter: NewRateLimiter(config.MaxAttempts, config.RateLimitWindow),
-		sessions:    make(map[string]*Session),
+		lockout:     newUserLockout(config.LockoutThreshold, config.LockoutBase, config.LockoutMax),
+	}
+	a.creds.Store(&Credentials{
+		Username:     config.Username,
+		Password:     config.Password,
+		PasswordHash: config.PasswordHash,
+	})
+	return a
+}
+
+// SetCredentials atomically replaces the credentials new AUTH attempts are
+// checked against, ending any rotation grace period. Established sessions
+// are unaffected.
+func (a *Authenticator) SetCredentials(creds Credentials) {
+	a.creds.Store(&creds)
+	a.previous.Store(nil)
+}
+
+// RotateCredentials replaces the credentials like SetCredentials but keeps
+// accepting the current ones for grace, so clients can move to the new
+// secret without being refused in between. Rotating again within the grace
+// period drops the oldest set.
+func (a *Authenticator) RotateCredentials(creds Credentials, grace time.Duration) {
+	current := a.creds.Load()
+	if grace <= 0 {
+		a.SetCredentials(creds)
+		return
+	}
+	if *current == creds {
+		return
 	}
+	a.previous.Store(&rotatedCredentials{Credentials: *current, until: time.Now().Add(grace)})
+	a.creds.Store(&creds)
+}
+
+// RotationGraceUntil returns when the previous credentials stop being
+// accepted, or the zero time if there are none.
+func (a *Authenticator) RotationGraceUntil() time.Time {
+	if prev := a.previous.Load(); prev != nil && time.Now().Before(prev.until) {
+		return prev.until
+	}
+	return time.Time{}
+}
+
+// OnLockout sets the handler told when a username is locked out.
+func (a *Authenticator) OnLockout(handler LockoutHandler) {
+	a.onLockout.Store(&handler)
+}
+
+// LockedUsers returns the number of usernames currently locked out.
+func (a *Authenticator) LockedUsers() int {
+	return a.lockout.locked()
+}
+
+// CurrentCredentials returns the credentials currently in effect.
+func (a *Authenticator) CurrentCredentials() Credentials {
+	return *a.creds.Load()
+}
+
+// ChallengeResponse computes the client's answer to an AUTH_CHALLENGE nonce.
+func ChallengeResponse(password string, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+// WantsChallenge reports whether frame is an AUTH request asking for a
+// challenge rather than carrying credentials.
+func (a *Authenticator) WantsChallenge(frame *protocol.Frame) bool {
+	var authReq pb.AuthRequest
+	if err := proto.Unmarshal(frame.Payload, &authReq); err != nil {
+		return false
+	}
+	return authReq.Mechanism != "" && len(authReq.Response) == 0
+}
+
+// IssueChallenge records a fresh nonce for peer in reply to an AUTH request
+// that asked for one. The client answers with another AUTH frame on the same
+// connection carrying ChallengeResponse(password, nonce).
+func (a *Authenticator) IssueChallenge(peer Peer, frame *protocol.Frame) (*Challenge, error) {
+	var authReq pb.AuthRequest
+	if err := proto.Unmarshal(frame.Payload, &authReq); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal auth request: %w", err)
+	}
+	if authReq.Mechanism != protocol.AuthMechanismHMACSHA256 {
+		return nil, ErrUnsupportedMechanism
+	}
+	// The HMAC key is the password itself, which a hash-only setup lacks
+	if a.creds.Load().Password == "" {
+		return nil, fmt.Errorf("%w: no plaintext secret configured", ErrUnsupportedMechanism)
+	}
+	
+	nonce := make([]byte, protocol.AuthNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	challenge := &Challenge{
+		Username:  authReq.Username,
+		Mechanism: authReq.Mechanism,
+		Nonce:     nonce,
+	}
+	
+	a.sessions.setChallenge(peer.ConnID, challenge)
+	return challenge, nil
+}
+
+// verify checks authReq against the accepted credentials, either through
+// the client's outstanding challenge or the legacy plaintext password, and
+// reports whether it matched credentials in their rotation grace period.
+func (a *Authenticator) verify(peer Peer, authReq *pb.AuthRequest) (previous bool, err error) {
+	if authReq.Mechanism == "" {
+		if a.config.RequireChallenge {
+			return false, ErrChallengeRequired
+		}
+		return a.checkPassword(authReq.Username, authReq.Password)
+	}
+	
+	challenge, ok := a.sessions.takeChallenge(peer.ConnID)
+	if !ok {
+		return false, ErrNoChallenge
+	}
+	if authReq.Mechanism != challenge.Mechanism || authReq.Username != challenge.Username {
+		return false, ErrInvalidCredentials
+	}
+	
+	for i, creds := range a.acceptedCredentials() {
+		if creds.Password == "" {
+			continue
+		}
+		expected := ChallengeResponse(creds.Password, challenge.Nonce)
+		userOK := constantTimeEqual(authReq.Username, creds.Username)
+		if hmac.Equal(authReq.Response, expected) && userOK {
+			return i > 0, nil
+		}
+	}
+	return false, ErrInvalidCredentials
+}
+
+// checkPassword verifies plaintext credentials against each accepted set's
+// hash, or password, and reports whether the previous set matched.
+func (a *Authenticator) checkPassword(username, password string) (previous bool, err error) {
+	for i, creds := range a.acceptedCredentials() {
+		ok, err := creds.check(username, password)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return i > 0, nil
+		}
+	}
+	return false, ErrInvalidCredentials
+}
+
+// acceptedCredentials returns the current credentials followed by the
+// previous ones while their rotation grace period lasts.
+func (a *Authenticator) acceptedCredentials() []*Credentials {
+	accepted := []*Credentials{a.creds.Load()}
+	if prev := a.previous.Load(); prev != nil && time.Now().Before(prev.until) {
+		accepted = append(accepted, &prev.Credentials)
+	}
+	return accepted
+}
+
+// check verifies plaintext credentials against c without short-circuiting
+// on the username.
+func (c *Credentials) check(username, password string) (bool, error) {
+	userOK := constantTimeEqual(username, c.Username)
+	
+	var passOK bool
+	if c.PasswordHash != "" {
+		ok, err := VerifyPassword(c.PasswordHash, password)
+		if err != nil {
+			return false, fmt.Errorf("failed to verify password: %w", err)
+		}
+		passOK = ok
+	} else {
+		passOK = constantTimeEqual(password, c.Password)
+	}
+	return userOK && passOK, nil
 }
 
 // ValidateFirstFrame validates that the first frame is an AUTH frame.
@@ -108,13 +367,41 @@ func (a *Authenticator) ValidateFirstFrame(frame *protocol.Frame) error {
 	return nil
 }
 
-// Authenticate processes an authentication request.
-func (a *Authenticator) Authenticate(ctx context.Context, clientAddr string, frame *protocol.Frame) (*Session, error) {
-	// Derive per-IP key for rate limiting (strip port from remote address)
-	ipKey := clientAddr
+// rateLimitKey derives the per-IP rate limiting key by stripping the port
+// from a remote address.
+func rateLimitKey(clientAddr string) string {
 	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
-		ipKey = host
+		return host
 	}
+	return clientAddr
+}
+
+// RetryAfter returns how long clientAddr must wait before its next attempt
+// is allowed after ErrRateLimited, or 0 if it is not blocked.
+func (a *Authenticator) RetryAfter(clientAddr string) time.Duration {
+	return a.rateLimiter.RetryAfter(rateLimitKey(clientAddr))
+}
+
+// Verifier checks a parsed AUTH request and returns the identity it grants.
+type Verifier func(ctx context.Context, req *pb.AuthRequest) (*Session, error)
+
+// Authenticate processes an authentication request against the configured
+// credentials.
+func (a *Authenticator) Authenticate(ctx context.Context, peer Peer, frame *protocol.Frame) (*Session, error) {
+	return a.AuthenticateWith(ctx, peer, frame, func(_ context.Context, req *pb.AuthRequest) (*Session, error) {
+		previous, err := a.verify(peer, req)
+		if err != nil {
+			return nil, err
+		}
+		return &Session{ClientID: req.ClientId, Username: req.Username, PreviousCredentials: previous}, nil
+	})
+}
+
+// AuthenticateWith processes an authentication request checked by verify,
+// applying the same per-IP rate limiting, per-username lockout and session
+// tracking as the built-in credentials.
+func (a *Authenticator) AuthenticateWith(ctx context.Context, peer Peer, frame *protocol.Frame, verify Verifier) (*Session, error) {
+	ipKey := rateLimitKey(peer.Addr)
 
 	// Check rate limiting per IP
 	if !a.rateLimiter.Allow(ipKey) {
@@ -122,12 +409,9 @@ func (a *Authenticator) Authenticate(ctx context.Context, clientAddr string, fra
 	}
 	
 	// Check if already authenticated
-	a.mu.RLock()
-	if session, exists := a.sessions[clientAddr]; exists && session.Authenticated {
-		a.mu.RUnlock()
+	if a.IsAuthenticated(peer.ConnID) {
 		return nil, ErrAlreadyAuthenticated
 	}
-	a.mu.RUnlock()
 	
 	// Parse AUTH request
 	var authReq pb.AuthRequest
@@ -135,68 +419,82 @@ func (a *Authenticator) Authenticate(ctx context.Context, clientAddr string, fra
 		return nil, fmt.Errorf("failed to unmarshal auth request: %w", err)
 	}
 	
-	// Validate credentials
-	if authReq.Username != a.config.Username || authReq.Password != a.config.Password {
-		a.rateLimiter.RecordFailure(ipKey)
-		return nil, ErrInvalidCredentials
+	// Refuse locked out usernames without checking the password
+	if retryAfter := a.lockout.retryAfter(authReq.Username); retryAfter > 0 {
+		return nil, &LockoutError{Username: authReq.Username, RetryAfter: retryAfter}
 	}
 	
-	// Create session
-	session := &Session{
-		ClientID:      authReq.ClientId,
-		Username:      authReq.Username,
-		Authenticated: true,
-		AuthTime:      time.Now(),
-		LastActivity:  time.Now(),
+	// Validate credentials
+	session, err := verify(ctx, &authReq)
+	if err != nil {
+		if !errors.Is(err, ErrUnavailable) {
+			a.rateLimiter.RecordFailure(ipKey)
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			a.recordUserFailure(authReq.Username)
+		}
+		return nil, err
 	}
 	
+	// Complete the session
+	session.Authenticated = true
+	session.AuthTime = time.Now()
+	session.LastActivity = session.AuthTime
+	
 	// Store session
-	a.mu.Lock()
-	a.sessions[clientAddr] = session
-	a.mu.Unlock()
+	a.sessions.setSession(peer.ConnID, session)
 	
 	// Reset rate limiter on successful auth (per IP)
 	a.rateLimiter.Reset(ipKey)
+	a.lockout.reset(authReq.Username)
 	
 	return session, nil
 }
 
-// GetSession retrieves a session for a client.
-func (a *Authenticator) GetSession(clientAddr string) (*Session, bool) {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	
-	session, exists := a.sessions[clientAddr]
-	return session, exists
+// recordUserFailure counts a failed login for username and reports any
+// lockout it starts.
+func (a *Authenticator) recordUserFailure(username string) {
+	failures, lockout := a.lockout.recordFailure(username)
+	if lockout == 0 {
+		return
+	}
+	if handler := a.onLockout.Load(); handler != nil {
+		(*handler)(username, failures, lockout)
+	}
 }
 
-// RemoveSession removes a session.
-func (a *Authenticator) RemoveSession(clientAddr string) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	
-	delete(a.sessions, clientAddr)
+// GetSession retrieves the session of a connection.
+func (a *Authenticator) GetSession(connID string) (*Session, bool) {
+	return a.sessions.session(connID)
 }
 
-// UpdateActivity updates the last activity time for a session.
-func (a *Authenticator) UpdateActivity(clientAddr string) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	
-	if session, exists := a.sessions[clientAddr]; exists {
-		session.LastActivity = time.Now()
-	}
+// RemoveSession removes a connection's session and any outstanding challenge.
+func (a *Authenticator) RemoveSession(connID string) {
+	a.sessions.remove(connID)
 }
 
-// IsAuthenticated checks if a client is authenticated.
-func (a *Authenticator) IsAuthenticated(clientAddr string) bool {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
-	
-	session, exists := a.sessions[clientAddr]
+// ExpireSession forgets a connection's session so that it can authenticate
+// again, keeping any challenge issued for that.
+func (a *Authenticator) ExpireSession(connID string) {
+	a.sessions.removeSession(connID)
+}
+
+// UpdateActivity updates the last activity time of a connection's session.
+func (a *Authenticator) UpdateActivity(connID string) {
+	a.sessions.touch(connID, time.Now())
+}
+
+// IsAuthenticated checks if a connection is authenticated.
+func (a *Authenticator) IsAuthenticated(connID string) bool {
+	session, exists := a.sessions.session(connID)
 	return exists && session.Authenticated
 }
 
+// SessionCount returns the number of authenticated connections tracked.
+func (a *Authenticator) SessionCount() int {
+	return a.sessions.len()
+}
+
 // CreateAckResponse creates an ACK response frame.
 func CreateAckResponse() *protocol.Frame {
 	ack := &pb.AckResponse{
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+func FuzzAuthenticate(f *testing.F) {
+	valid, err := proto.Marshal(&pb.AuthRequest{
+		Username: "fuzzuser",
+		Password: "fuzzpass",
+		ClientId: "fuzz-client",
+		Version:  "1.0.0",
+	})
+	if err != nil {
+		f.Fatalf("failed to marshal seed: %v", err)
+	}
+	f.Add(valid)
+	f.Add([]byte{})
+	f.Add([]byte{0x0a, 0xFF, 0xFF, 0xFF, 0xFF, 0x0F})
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		authenticator := NewAuthenticator(&Config{
+			Username:        "fuzzuser",
+			Password:        "fuzzpass",
+			Timeout:         time.Second,
+			MaxAttempts:     1 << 20,
+			RateLimitWindow: time.Minute,
+		})
+
+		frame := &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}
+		session, err := authenticator.Authenticate(context.Background(), testPeer("127.0.0.1:9999"), frame)
+		if err != nil {
+			if session != nil {
+				t.Fatalf("session returned alongside error: %v", err)
+			}
+			return
+		}
+
+		// Success is only possible when the payload carries the configured credentials
+		var req pb.AuthRequest
+		if uerr := proto.Unmarshal(payload, &req); uerr != nil {
+			t.Fatalf("authenticated with unparseable payload: %v", uerr)
+		}
+		if req.Username != "fuzzuser" || req.Password != "fuzzpass" {
+			t.Fatalf("authenticated with wrong credentials: %q/%q", req.Username, req.Password)
+		}
+		if !session.Authenticated {
+			t.Fatalf("session not marked authenticated")
+		}
+
+		// A second attempt on the same address must be rejected
+		if _, err := authenticator.Authenticate(context.Background(), testPeer("127.0.0.1:9999"), frame); !errors.Is(err, ErrAlreadyAuthenticated) {
+			t.Fatalf("expected ErrAlreadyAuthenticated, got %v", err)
+		}
+	})
+}
@@ -133,7 +133,7 @@ func TestAuthenticatorAuthenticate(t *testing.T) {
 			// Create a fresh authenticator for each test to avoid state pollution
 			testAuth := NewAuthenticator(config)
 			remoteAddr := "127.0.0.1:12345"
-			_, err := testAuth.Authenticate(ctx, remoteAddr, tt.frame)
+			_, err := testAuth.Authenticate(ctx, testPeer(remoteAddr), tt.frame)
 			if tt.wantErr != nil {
 				if err == nil {
 					t.Errorf("Authenticate() expected error %v, got nil", tt.wantErr)
@@ -170,13 +170,13 @@ func TestAuthenticatorAlreadyAuthenticated(t *testing.T) {
 	}
 
 	// First authentication should succeed
-	_, err := authenticator.Authenticate(ctx, clientAddr, frame)
+	_, err := authenticator.Authenticate(ctx, testPeer(clientAddr), frame)
 	if err != nil {
 		t.Fatalf("First authentication failed: %v", err)
 	}
 
 	// Second authentication should fail with already authenticated error
-	_, err = authenticator.Authenticate(ctx, clientAddr, frame)
+	_, err = authenticator.Authenticate(ctx, testPeer(clientAddr), frame)
 	if err != ErrAlreadyAuthenticated {
 		t.Errorf("Expected ErrAlreadyAuthenticated, got %v", err)
 	}
@@ -208,7 +208,7 @@ func TestAuthenticatorSessionManagement(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	session, err := authenticator.Authenticate(ctx, clientAddr, frame)
+	session, err := authenticator.Authenticate(ctx, testPeer(clientAddr), frame)
 	if err != nil {
 		t.Fatalf("Authentication failed: %v", err)
 	}
@@ -249,7 +249,7 @@ func TestAuthenticatorSessionAfterAuth(t *testing.T) {
 			return payload
 		}(),
 	}
-	session, err := authenticator.Authenticate(ctx, clientAddr, frame)
+	session, err := authenticator.Authenticate(ctx, testPeer(clientAddr), frame)
 	if err != nil {
 		t.Fatalf("Authentication failed: %v", err)
 	}
@@ -379,19 +379,27 @@ func TestAuthenticatorRateLimitingPerIPIgnoresPort(t *testing.T) {
 
     // First attempt from IP 10.0.0.1 (port 10000) should be allowed by limiter,
     // but fail with invalid credentials.
-    if _, err := a.Authenticate(ctx, "10.0.0.1:10000", frame); err != ErrInvalidCredentials {
+    if _, err := a.Authenticate(ctx, testPeer("10.0.0.1:10000"), frame); err != ErrInvalidCredentials {
         t.Fatalf("expected ErrInvalidCredentials on first attempt, got %v", err)
     }
 
     // Second attempt from same IP but different port should be rate limited.
-    if _, err := a.Authenticate(ctx, "10.0.0.1:20000", frame); err != ErrRateLimited {
+    if _, err := a.Authenticate(ctx, testPeer("10.0.0.1:20000"), frame); err != ErrRateLimited {
         t.Fatalf("expected ErrRateLimited on second attempt (same IP, different port), got %v", err)
     }
 
     // Attempt from a different IP should not be affected by the limiter state of 10.0.0.1.
-    if _, err := a.Authenticate(ctx, "10.0.0.2:30000", frame); err != ErrInvalidCredentials {
+    if _, err := a.Authenticate(ctx, testPeer("10.0.0.2:30000"), frame); err != ErrInvalidCredentials {
         t.Fatalf("expected ErrInvalidCredentials for different IP, got %v", err)
     }
+
+    // The blocked IP is told how long to wait, whatever its port.
+    if d := a.RetryAfter("10.0.0.1:40000"); d <= 0 || d > 3*cfg.RateLimitWindow {
+        t.Fatalf("expected a retry-after within the block period, got %v", d)
+    }
+    if d := a.RetryAfter("10.0.0.3:40000"); d != 0 {
+        t.Fatalf("expected no retry-after for an unknown IP, got %v", d)
+    }
 }
 
 func TestAuthenticatorEnvOverridesAffectRateLimiter(t *testing.T) {
@@ -421,15 +429,15 @@ func TestAuthenticatorEnvOverridesAffectRateLimiter(t *testing.T) {
     frame := &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: badPayload}
 
     // Two attempts within window should be allowed by limiter (then invalid creds error)
-    if _, err := a.Authenticate(ctx, "192.168.100.1:1111", frame); err != ErrInvalidCredentials {
+    if _, err := a.Authenticate(ctx, testPeer("192.168.100.1:1111"), frame); err != ErrInvalidCredentials {
         t.Fatalf("first attempt expected ErrInvalidCredentials, got %v", err)
     }
-    if _, err := a.Authenticate(ctx, "192.168.100.1:2222", frame); err != ErrInvalidCredentials {
+    if _, err := a.Authenticate(ctx, testPeer("192.168.100.1:2222"), frame); err != ErrInvalidCredentials {
         t.Fatalf("second attempt expected ErrInvalidCredentials, got %v", err)
     }
 
     // Next attempt should be rate limited (blocked)
-    if _, err := a.Authenticate(ctx, "192.168.100.1:3333", frame); err != ErrRateLimited {
+    if _, err := a.Authenticate(ctx, testPeer("192.168.100.1:3333"), frame); err != ErrRateLimited {
         t.Fatalf("third attempt expected ErrRateLimited, got %v", err)
     }
 
@@ -437,7 +445,102 @@ func TestAuthenticatorEnvOverridesAffectRateLimiter(t *testing.T) {
     time.Sleep(350 * time.Millisecond)
 
     // After block period, attempts should be allowed again by limiter
-    if _, err := a.Authenticate(ctx, "192.168.100.1:4444", frame); err != ErrInvalidCredentials {
+    if _, err := a.Authenticate(ctx, testPeer("192.168.100.1:4444"), frame); err != ErrInvalidCredentials {
         t.Fatalf("post-block attempt expected ErrInvalidCredentials, got %v", err)
     }
 }
+
+func TestAuthenticatorChallengeResponse(t *testing.T) {
+	config := &Config{
+		Username:        "testuser",
+		Password:        "testpass",
+		MaxAttempts:     10,
+		RateLimitWindow: time.Minute,
+	}
+	a := NewAuthenticator(config)
+	ctx := context.Background()
+	addr := "127.0.0.1:5000"
+
+	authFrame := func(req *pb.AuthRequest) *protocol.Frame {
+		payload, _ := proto.Marshal(req)
+		return &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}
+	}
+
+	hello := authFrame(&pb.AuthRequest{Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256})
+	if !a.WantsChallenge(hello) {
+		t.Fatal("expected challenge request")
+	}
+	if a.WantsChallenge(authFrame(&pb.AuthRequest{Username: "testuser", Password: "testpass"})) {
+		t.Fatal("plaintext AUTH must not request a challenge")
+	}
+
+	// A response without an outstanding challenge is rejected
+	_, err := a.Authenticate(ctx, testPeer(addr), authFrame(&pb.AuthRequest{
+		Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256, Response: make([]byte, 32),
+	}))
+	if !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("expected ErrNoChallenge, got %v", err)
+	}
+
+	// Wrong password produces a mismatched HMAC
+	challenge, err := a.IssueChallenge(testPeer(addr), hello)
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	if len(challenge.Nonce) != protocol.AuthNonceSize {
+		t.Fatalf("nonce length = %d", len(challenge.Nonce))
+	}
+	_, err = a.Authenticate(ctx, testPeer(addr), authFrame(&pb.AuthRequest{
+		Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: ChallengeResponse("wrongpass", challenge.Nonce),
+	}))
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	// The challenge was consumed; replaying a correct answer to it fails
+	replay := authFrame(&pb.AuthRequest{
+		Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: ChallengeResponse("testpass", challenge.Nonce),
+	})
+	if _, err := a.Authenticate(ctx, testPeer(addr), replay); !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("expected ErrNoChallenge on replay, got %v", err)
+	}
+
+	challenge, err = a.IssueChallenge(testPeer(addr), hello)
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	session, err := a.Authenticate(ctx, testPeer(addr), authFrame(&pb.AuthRequest{
+		Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: ChallengeResponse("testpass", challenge.Nonce),
+	}))
+	if err != nil {
+		t.Fatalf("challenge auth failed: %v", err)
+	}
+	if session.Username != "testuser" {
+		t.Errorf("session username = %q", session.Username)
+	}
+
+	if _, err := a.IssueChallenge(testPeer("127.0.0.1:5001"), authFrame(&pb.AuthRequest{Username: "testuser", Mechanism: "md5"})); !errors.Is(err, ErrUnsupportedMechanism) {
+		t.Errorf("expected ErrUnsupportedMechanism, got %v", err)
+	}
+}
+
+func TestAuthenticatorRequireChallenge(t *testing.T) {
+	os.Setenv("AUTH_REQUIRE_CHALLENGE", "true")
+	defer os.Unsetenv("AUTH_REQUIRE_CHALLENGE")
+
+	config := DefaultConfig()
+	if !config.RequireChallenge {
+		t.Fatal("AUTH_REQUIRE_CHALLENGE not applied")
+	}
+	config.Username, config.Password = "testuser", "testpass"
+	a := NewAuthenticator(config)
+
+	payload, _ := proto.Marshal(&pb.AuthRequest{Username: "testuser", Password: "testpass"})
+	_, err := a.Authenticate(context.Background(), testPeer("127.0.0.1:5000"), &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload})
+	if !errors.Is(err, ErrChallengeRequired) {
+		t.Fatalf("expected ErrChallengeRequired, got %v", err)
+	}
+}
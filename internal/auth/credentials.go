@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hash algorithms.
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+var (
+	// ErrUnsupportedHash indicates a stored hash in an unknown format.
+	ErrUnsupportedHash = errors.New("unsupported password hash")
+
+	// ErrMalformedHash indicates a stored hash that cannot be parsed.
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// HashParams controls the cost of newly created password hashes. Existing
+// hashes carry their own parameters and verify regardless of these values.
+type HashParams struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+// DefaultHashParams returns argon2id parameters following the RFC 9106
+// second recommended option, overridable via PASSWORD_HASH_* variables.
+func DefaultHashParams() HashParams {
+	p := HashParams{
+		Algorithm:     HashArgon2id,
+		BcryptCost:    bcrypt.DefaultCost,
+		Argon2Time:    3,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+
+	if v := os.Getenv("PASSWORD_HASH_ALGORITHM"); v != "" {
+		p.Algorithm = strings.ToLower(v)
+	}
+	if v := os.Getenv("PASSWORD_HASH_BCRYPT_COST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= bcrypt.MinCost && n <= bcrypt.MaxCost {
+			p.BcryptCost = n
+		}
+	}
+	if v := os.Getenv("PASSWORD_HASH_ARGON2_TIME"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil && n > 0 {
+			p.Argon2Time = uint32(n)
+		}
+	}
+	if v := os.Getenv("PASSWORD_HASH_ARGON2_MEMORY_KB"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil && n >= 8 {
+			p.Argon2Memory = uint32(n)
+		}
+	}
+	if v := os.Getenv("PASSWORD_HASH_ARGON2_THREADS"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 8); err == nil && n > 0 {
+			p.Argon2Threads = uint8(n)
+		}
+	}
+
+	return p
+}
+
+// HashPassword hashes password with params, producing a bcrypt string or a
+// PHC-format argon2id string ($argon2id$v=19$m=...,t=...,p=...$salt$hash).
+func HashPassword(password string, params HashParams) (string, error) {
+	switch params.Algorithm {
+	case HashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), params.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("bcrypt: %w", err)
+		}
+		return string(hash), nil
+	case HashArgon2id:
+		salt := make([]byte, params.Argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("failed to generate salt: %w", err)
+		}
+		key := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, params.Argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+			params.Argon2Memory, params.Argon2Time, params.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedHash, params.Algorithm)
+	}
+}
+
+// VerifyPassword reports whether password matches encoded, comparing in
+// constant time. The algorithm is detected from the hash prefix.
+func VerifyPassword(encoded, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		return true, nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(encoded, password)
+	default:
+		return false, ErrUnsupportedHash
+	}
+}
+
+// verifyArgon2id re-derives the key with the hash's own parameters.
+func verifyArgon2id(encoded, password string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrMalformedHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrMalformedHash
+	}
+	// argon2.IDKey panics on zero time or threads
+	if memory == 0 || time == 0 || threads == 0 {
+		return false, ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, ErrMalformedHash
+	}
+
+	derived := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1, nil
+}
+
+// constantTimeEqual compares two strings without leaking where they differ.
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/protobuf/proto"
+)
+
+// fastHashParams keeps test hashing cheap.
+func fastHashParams(algorithm string) HashParams {
+	return HashParams{
+		Algorithm:     algorithm,
+		BcryptCost:    bcrypt.MinCost,
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+}
+
+func TestHashAndVerifyPassword(t *testing.T) {
+	for _, algorithm := range []string{HashBcrypt, HashArgon2id} {
+		t.Run(algorithm, func(t *testing.T) {
+			hash, err := HashPassword("s3cret", fastHashParams(algorithm))
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			if strings.Contains(hash, "s3cret") {
+				t.Fatal("hash contains the plaintext password")
+			}
+
+			ok, err := VerifyPassword(hash, "s3cret")
+			if err != nil || !ok {
+				t.Fatalf("VerifyPassword(correct) = %v, %v", ok, err)
+			}
+			ok, err = VerifyPassword(hash, "wrong")
+			if err != nil || ok {
+				t.Fatalf("VerifyPassword(wrong) = %v, %v", ok, err)
+			}
+		})
+	}
+
+	if _, err := HashPassword("x", HashParams{Algorithm: "md5"}); !errors.Is(err, ErrUnsupportedHash) {
+		t.Errorf("expected ErrUnsupportedHash, got %v", err)
+	}
+}
+
+func TestVerifyPasswordRejectsBadHashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{name: "plaintext", encoded: "s3cret", wantErr: ErrUnsupportedHash},
+		{name: "truncated argon2id", encoded: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA", wantErr: ErrMalformedHash},
+		{name: "wrong argon2 version", encoded: "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$a2V5", wantErr: ErrMalformedHash},
+		{name: "bad argon2 params", encoded: "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5", wantErr: ErrMalformedHash},
+		{name: "zero argon2 memory", encoded: "$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5", wantErr: ErrMalformedHash},
+		{name: "zero argon2 time", encoded: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5", wantErr: ErrMalformedHash},
+		{name: "zero argon2 threads", encoded: "$argon2id$v=19$m=64,t=1,p=0$c2FsdA$a2V5", wantErr: ErrMalformedHash},
+		{name: "truncated bcrypt", encoded: "$2a$10$short", wantErr: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyPassword(tt.encoded, "s3cret")
+			if ok || !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyPassword() = %v, %v; want error %v", ok, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultHashParamsEnv(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "BCRYPT")
+	t.Setenv("PASSWORD_HASH_BCRYPT_COST", "12")
+	t.Setenv("PASSWORD_HASH_ARGON2_TIME", "2")
+	t.Setenv("PASSWORD_HASH_ARGON2_MEMORY_KB", "4")
+	t.Setenv("PASSWORD_HASH_ARGON2_THREADS", "2")
+
+	p := DefaultHashParams()
+	if p.Algorithm != HashBcrypt || p.BcryptCost != 12 || p.Argon2Time != 2 || p.Argon2Threads != 2 {
+		t.Errorf("unexpected params: %+v", p)
+	}
+	if p.Argon2Memory != 64*1024 {
+		t.Errorf("memory below the argon2 minimum should be ignored, got %d", p.Argon2Memory)
+	}
+}
+
+func TestAuthenticatorPasswordHash(t *testing.T) {
+	hash, err := HashPassword("testpass", fastHashParams(HashArgon2id))
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	a := NewAuthenticator(&Config{
+		Username:        "testuser",
+		PasswordHash:    hash,
+		MaxAttempts:     10,
+		RateLimitWindow: time.Minute,
+	})
+
+	authenticate := func(addr, password string) error {
+		payload, _ := proto.Marshal(&pb.AuthRequest{Username: "testuser", Password: password})
+		_, err := a.Authenticate(context.Background(), testPeer(addr), &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload})
+		return err
+	}
+
+	if err := authenticate("127.0.0.1:5000", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if err := authenticate("127.0.0.1:5000", "testpass"); err != nil {
+		t.Fatalf("hashed credentials rejected: %v", err)
+	}
+
+	// Challenge-response needs the plaintext secret as its HMAC key
+	payload, _ := proto.Marshal(&pb.AuthRequest{Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256})
+	_, err = a.IssueChallenge(testPeer("127.0.0.1:5001"), &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload})
+	if !errors.Is(err, ErrUnsupportedMechanism) {
+		t.Errorf("expected ErrUnsupportedMechanism, got %v", err)
+	}
+}
+
+func TestAuthenticatorRotateCredentials(t *testing.T) {
+	a := NewAuthenticator(&Config{Username: "svc", Password: "old", MaxAttempts: 100, RateLimitWindow: time.Minute})
+	ctx := context.Background()
+	authFrame := func(req *pb.AuthRequest) *protocol.Frame {
+		payload, _ := proto.Marshal(req)
+		return &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}
+	}
+	login := func(conn, password string) (*Session, error) {
+		return a.Authenticate(ctx, testPeer(conn), authFrame(&pb.AuthRequest{Username: "svc", Password: password}))
+	}
+
+	a.RotateCredentials(Credentials{Username: "svc", Password: "new"}, time.Minute)
+	if session, err := login("c1", "new"); err != nil || session.PreviousCredentials {
+		t.Fatalf("new password: session %+v, err %v", session, err)
+	}
+	if session, err := login("c2", "old"); err != nil || !session.PreviousCredentials {
+		t.Fatalf("old password during grace: session %+v, err %v", session, err)
+	}
+	if _, err := login("c3", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: %v", err)
+	}
+
+	// Challenge-response accepts either password too
+	challenge, err := a.IssueChallenge(testPeer("c4"), authFrame(&pb.AuthRequest{Username: "svc", Mechanism: protocol.AuthMechanismHMACSHA256}))
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	answer := authFrame(&pb.AuthRequest{Username: "svc", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: ChallengeResponse("old", challenge.Nonce)})
+	if session, err := a.Authenticate(ctx, testPeer("c4"), answer); err != nil || !session.PreviousCredentials {
+		t.Fatalf("old password challenge: session %+v, err %v", session, err)
+	}
+
+	// Rotating to the same credentials keeps the grace period
+	until := a.RotationGraceUntil()
+	a.RotateCredentials(Credentials{Username: "svc", Password: "new"}, time.Minute)
+	if got := a.RotationGraceUntil(); !got.Equal(until) {
+		t.Fatalf("grace moved to %v on a no-op rotation", got)
+	}
+
+	// The grace period ends
+	a.previous.Load().until = time.Now()
+	if _, err := login("c5", "old"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("old password after grace: %v", err)
+	}
+	if !a.RotationGraceUntil().IsZero() {
+		t.Fatal("expired grace period still reported")
+	}
+
+	// SetCredentials replaces at once
+	a.RotateCredentials(Credentials{Username: "svc", Password: "newer"}, time.Minute)
+	a.SetCredentials(Credentials{Username: "svc", Password: "newest"})
+	if _, err := login("c6", "newer"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("replaced password after SetCredentials: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LockoutError refuses an AUTH attempt for a username locked out after
+// repeated failures. It matches ErrRateLimited.
+type LockoutError struct {
+	Username   string
+	RetryAfter time.Duration // Until the lockout expires
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("username %q locked out for %s", e.Username, e.RetryAfter)
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// LockoutHandler is told when a username is locked out, with its consecutive
+// failures and how long the lockout lasts.
+type LockoutHandler func(username string, failures int, duration time.Duration)
+
+// userLockout tracks consecutive failures per username, whatever the source
+// IP, and locks a username out once they reach threshold. Each further
+// failure doubles the lockout, from base up to max. Failures are forgotten on
+// success or after max without another.
+type userLockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	users     map[string]*lockoutRecord
+	lastSweep time.Time
+}
+
+type lockoutRecord struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// newUserLockout returns nil, which never locks anyone out, unless threshold
+// is positive.
+func newUserLockout(threshold int, base, maxLockout time.Duration) *userLockout {
+	if threshold <= 0 {
+		return nil
+	}
+	if base <= 0 {
+		base = time.Minute
+	}
+	if maxLockout < base {
+		maxLockout = base
+	}
+	return &userLockout{
+		threshold: threshold,
+		base:      base,
+		max:       maxLockout,
+		now:       time.Now,
+		users:     make(map[string]*lockoutRecord),
+	}
+}
+
+// retryAfter returns how long username remains locked out, or 0.
+func (l *userLockout) retryAfter(username string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	record, ok := l.users[username]
+	if !ok {
+		return 0
+	}
+	return max(0, record.lockedUntil.Sub(l.now()))
+}
+
+// recordFailure counts a failed attempt for username and returns the
+// lockout it starts, or 0.
+func (l *userLockout) recordFailure(username string) (failures int, lockout time.Duration) {
+	if l == nil || username == "" {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	record, ok := l.users[username]
+	if !ok || now.Sub(record.lastFailure) > l.max {
+		record = &lockoutRecord{}
+		l.users[username] = record
+	}
+	record.failures++
+	record.lastFailure = now
+	if record.failures < l.threshold {
+		return record.failures, 0
+	}
+
+	// Compare before shifting: base<<shift overflows long before the
+	// failure count stops growing
+	lockout = l.max
+	if shift := record.failures - l.threshold; l.base <= l.max>>shift {
+		lockout = l.base << shift
+	}
+	record.lockedUntil = now.Add(lockout)
+	return record.failures, lockout
+}
+
+// reset forgets username's failures after a successful login.
+func (l *userLockout) reset(username string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.users, username)
+}
+
+// locked returns the number of usernames currently locked out.
+func (l *userLockout) locked() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	n := 0
+	for _, record := range l.users {
+		if now.Before(record.lockedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// sweep drops idle, unlocked records at most once per max, so usernames
+// sprayed by an attacker do not accumulate. Called with mu held.
+func (l *userLockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.max {
+		return
+	}
+	l.lastSweep = now
+	for username, record := range l.users {
+		if now.Sub(record.lastFailure) > l.max && !now.Before(record.lockedUntil) {
+			delete(l.users, username)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUserLockoutBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newUserLockout(3, time.Minute, 10*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 1; i < 3; i++ {
+		if _, d := l.recordFailure("alice"); d != 0 {
+			t.Fatalf("failure %d locked out for %v before the threshold", i, d)
+		}
+	}
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute} {
+		if _, d := l.recordFailure("alice"); d != want {
+			t.Fatalf("lockout = %v, want %v", d, want)
+		}
+		if got := l.retryAfter("alice"); got != want {
+			t.Fatalf("retryAfter = %v, want %v", got, want)
+		}
+		now = now.Add(want)
+	}
+	if l.retryAfter("bob") != 0 {
+		t.Fatal("lockout leaked to another username")
+	}
+
+	// Failures are forgotten after a quiet period, and on success
+	now = now.Add(11 * time.Minute)
+	if failures, d := l.recordFailure("alice"); failures != 1 || d != 0 {
+		t.Fatalf("failures = %d, lockout = %v after a quiet period", failures, d)
+	}
+	l.recordFailure("alice")
+	l.reset("alice")
+	if failures, _ := l.recordFailure("alice"); failures != 1 {
+		t.Fatalf("failures = %d after reset", failures)
+	}
+}
+
+func TestUserLockoutCapsLargeFailureCounts(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newUserLockout(1, time.Minute, 24*time.Hour)
+	l.now = func() time.Time { return now }
+
+	// Retrying as each lockout ends keeps the record, so the count only grows
+	for i := 1; i <= 200; i++ {
+		_, d := l.recordFailure("alice")
+		if d <= 0 || d > 24*time.Hour {
+			t.Fatalf("failure %d: lockout = %v, want within (0, 24h]", i, d)
+		}
+		if i > 12 && d != 24*time.Hour {
+			t.Fatalf("failure %d: lockout = %v, want the 24h maximum", i, d)
+		}
+		now = now.Add(d)
+	}
+}
+
+func TestUserLockoutSweep(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newUserLockout(5, time.Minute, time.Hour)
+	l.now = func() time.Time { return now }
+	for i := 0; i < 100; i++ {
+		l.recordFailure(fmt.Sprintf("user-%d", i))
+	}
+	now = now.Add(2 * time.Hour)
+	l.recordFailure("alice")
+	if n := len(l.users); n != 1 {
+		t.Fatalf("%d records after sweep, want 1", n)
+	}
+}
+
+func TestUserLockoutDisabled(t *testing.T) {
+	l := newUserLockout(0, time.Minute, time.Hour)
+	if l != nil {
+		t.Fatal("zero threshold should disable lockout")
+	}
+	if _, d := l.recordFailure("alice"); d != 0 || l.retryAfter("alice") != 0 || l.locked() != 0 {
+		t.Fatal("disabled lockout locked a username")
+	}
+}
+
+func TestAuthenticatorLocksOutUsernameAcrossIPs(t *testing.T) {
+	a := NewAuthenticator(&Config{
+		Username:         "alice",
+		Password:         "secret",
+		MaxAttempts:      100,
+		RateLimitWindow:  time.Minute,
+		LockoutThreshold: 3,
+		LockoutBase:      time.Minute,
+		LockoutMax:       time.Hour,
+	})
+	var lockouts []time.Duration
+	a.OnLockout(func(username string, failures int, d time.Duration) {
+		if username != "alice" || failures != 3 {
+			t.Errorf("lockout reported for %s after %d failures", username, failures)
+		}
+		lockouts = append(lockouts, d)
+	})
+	ctx := context.Background()
+	authFrame := func(password string) *protocol.Frame {
+		payload, _ := proto.Marshal(&pb.AuthRequest{Username: "alice", Password: password})
+		return &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}
+	}
+
+	// Each guess comes from a different IP, so per-IP limiting never trips
+	for i := 0; i < 3; i++ {
+		peer := testPeer(fmt.Sprintf("198.51.100.%d:4000", i))
+		if _, err := a.Authenticate(ctx, peer, authFrame("guess")); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("guess %d: %v", i, err)
+		}
+	}
+	if len(lockouts) != 1 || lockouts[0] != time.Minute {
+		t.Fatalf("lockouts = %v, want one of 1m", lockouts)
+	}
+	if n := a.LockedUsers(); n != 1 {
+		t.Fatalf("LockedUsers = %d, want 1", n)
+	}
+
+	// Even the right password is refused until the lockout ends
+	_, err := a.Authenticate(ctx, testPeer("203.0.113.1:4000"), authFrame("secret"))
+	var lockout *LockoutError
+	if !errors.As(err, &lockout) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("locked out login: %v", err)
+	}
+	if lockout.RetryAfter <= 0 || lockout.RetryAfter > time.Minute {
+		t.Fatalf("RetryAfter = %v", lockout.RetryAfter)
+	}
+
+	a.lockout.mu.Lock()
+	a.lockout.users["alice"].lockedUntil = time.Now()
+	a.lockout.mu.Unlock()
+	if _, err := a.Authenticate(ctx, testPeer("203.0.113.1:4000"), authFrame("secret")); err != nil {
+		t.Fatalf("login after lockout: %v", err)
+	}
+	if _, ok := a.lockout.users["alice"]; ok {
+		t.Fatal("successful login kept the failure record")
+	}
+}
@@ -100,6 +100,19 @@ func (rl *RateLimiter) RecordFailure(clientAddr string) {
 	}
 }
 
+// RetryAfter returns how long a blocked client must wait before its next
+// attempt is allowed, or 0 if it is not blocked.
+func (rl *RateLimiter) RetryAfter(clientAddr string) time.Duration {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	
+	record, exists := rl.attempts[clientAddr]
+	if !exists || !record.blocked {
+		return 0
+	}
+	return max(0, time.Until(record.blockUntil))
+}
+
 // Reset resets the rate limiter for a client after successful authentication.
 func (rl *RateLimiter) Reset(clientAddr string) {
 	rl.mu.Lock()
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// sessionShards is the number of independently locked shards in a
+// sessionStore. A power of two, so the shard is a mask of the key hash.
+const sessionShards = 64
+
+// Peer identifies the connection an AUTH request arrived on. Sessions and
+// challenges belong to the connection, so clients sharing an IP, or a
+// reused ip:port, never see each other's state; rate limiting stays per IP.
+type Peer struct {
+	ConnID string // Unique for the connection's lifetime
+	Addr   string // Remote address as ip:port
+}
+
+// sessionStore holds sessions and outstanding challenges by connection ID,
+// sharded so that connections authenticating and disconnecting at high
+// churn only contend within a shard.
+type sessionStore struct {
+	shards [sessionShards]sessionShard
+}
+
+type sessionShard struct {
+	mu         sync.Mutex
+	sessions   map[string]*Session
+	challenges map[string]*Challenge
+}
+
+// shard returns the shard for connID, hashed with FNV-1a.
+func (s *sessionStore) shard(connID string) *sessionShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(connID); i++ {
+		h ^= uint32(connID[i])
+		h *= 16777619
+	}
+	return &s.shards[h&(sessionShards-1)]
+}
+
+func (s *sessionStore) session(connID string) (*Session, bool) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	session, ok := sh.sessions[connID]
+	return session, ok
+}
+
+func (s *sessionStore) setSession(connID string, session *Session) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.sessions == nil {
+		sh.sessions = make(map[string]*Session)
+	}
+	sh.sessions[connID] = session
+}
+
+// touch updates the session's last activity time, if there is one.
+func (s *sessionStore) touch(connID string, at time.Time) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if session, ok := sh.sessions[connID]; ok {
+		session.LastActivity = at
+	}
+}
+
+func (s *sessionStore) setChallenge(connID string, challenge *Challenge) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.challenges == nil {
+		sh.challenges = make(map[string]*Challenge)
+	}
+	sh.challenges[connID] = challenge
+}
+
+// takeChallenge removes and returns the connection's challenge; they are
+// single use.
+func (s *sessionStore) takeChallenge(connID string) (*Challenge, bool) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	challenge, ok := sh.challenges[connID]
+	delete(sh.challenges, connID)
+	return challenge, ok
+}
+
+// removeSession forgets the connection's session but not its challenge.
+func (s *sessionStore) removeSession(connID string) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.sessions, connID)
+}
+
+// remove forgets the connection's session and challenge.
+func (s *sessionStore) remove(connID string) {
+	sh := s.shard(connID)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.sessions, connID)
+	delete(sh.challenges, connID)
+}
+
+// len returns the number of sessions.
+func (s *sessionStore) len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.sessions)
+		sh.mu.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// testPeer is a connection whose ID is its address, for tests that only
+// ever open one connection per address.
+func testPeer(addr string) Peer {
+	return Peer{ConnID: addr, Addr: addr}
+}
+
+func TestSessionsKeyedByConnection(t *testing.T) {
+	a := NewAuthenticator(&Config{
+		Username:        "testuser",
+		Password:        "testpass",
+		MaxAttempts:     10,
+		RateLimitWindow: time.Minute,
+	})
+	ctx := context.Background()
+	authFrame := func(req *pb.AuthRequest) *protocol.Frame {
+		payload, _ := proto.Marshal(req)
+		return &protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload}
+	}
+
+	// Two clients behind one NAT address
+	first := Peer{ConnID: "conn-1", Addr: "203.0.113.7:40000"}
+	second := Peer{ConnID: "conn-2", Addr: "203.0.113.7:40000"}
+
+	challenge, err := a.IssueChallenge(first, authFrame(&pb.AuthRequest{Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256}))
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	answer := authFrame(&pb.AuthRequest{
+		Username: "testuser", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: ChallengeResponse("testpass", challenge.Nonce),
+	})
+	if _, err := a.Authenticate(ctx, second, answer); !errors.Is(err, ErrNoChallenge) {
+		t.Fatalf("another connection answered the challenge: %v", err)
+	}
+	if _, err := a.Authenticate(ctx, first, answer); err != nil {
+		t.Fatalf("challenge auth failed: %v", err)
+	}
+
+	if a.IsAuthenticated(second.ConnID) {
+		t.Fatal("session leaked to another connection on the same address")
+	}
+	if _, err := a.Authenticate(ctx, second, authFrame(&pb.AuthRequest{Username: "testuser", Password: "testpass"})); err != nil {
+		t.Fatalf("second connection rejected: %v", err)
+	}
+	if got := a.SessionCount(); got != 2 {
+		t.Fatalf("SessionCount = %d, want 2", got)
+	}
+
+	a.RemoveSession(first.ConnID)
+	if a.IsAuthenticated(first.ConnID) || !a.IsAuthenticated(second.ConnID) {
+		t.Fatal("RemoveSession touched the wrong connection")
+	}
+}
+
+func TestSessionStoreConcurrentChurn(t *testing.T) {
+	var s sessionStore
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := fmt.Sprintf("%d-%d", w, i)
+				s.setChallenge(id, &Challenge{})
+				s.setSession(id, &Session{Authenticated: true})
+				s.touch(id, time.Now())
+				if _, ok := s.takeChallenge(id); !ok {
+					t.Errorf("challenge for %s lost", id)
+				}
+				s.remove(id)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if n := s.len(); n != 0 {
+		t.Fatalf("len = %d after churn", n)
+	}
+}
+
+func BenchmarkSessionStoreChurn(b *testing.B) {
+	var s sessionStore
+	var mu sync.Mutex
+	n := 0
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		n++
+		prefix := fmt.Sprint(n)
+		mu.Unlock()
+		for i := 0; pb.Next(); i++ {
+			id := prefix + "-" + fmt.Sprint(i)
+			s.setSession(id, &Session{Authenticated: true})
+			s.remove(id)
+		}
+	})
+}
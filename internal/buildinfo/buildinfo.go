@@ -0,0 +1,46 @@
+// Package buildinfo reports the version the binary was built from. The
+// variables are set at link time, for example:
+//
+//	go build -ldflags "-X github.com/furkansarikaya/tick-storm/internal/buildinfo.Version=v1.2.3"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X by the Makefile, build scripts and Dockerfile.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build info. A commit that was not injected falls back to
+// the VCS revision Go embeds when building from a checkout.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit == "unknown" {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				if s.Key == "vcs.revision" && s.Value != "" {
+					info.Commit = s.Value
+				}
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,210 @@
+package conformance
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// unsupportedVersion is a frame version no implementation supports.
+const unsupportedVersion = 0x7F
+
+// Check is one protocol requirement. run returns what it observed.
+type Check struct {
+	Name        string
+	Description string
+	run         func(ctx context.Context, r *runner) (string, error)
+}
+
+// Checks are run in this order.
+var Checks = []Check{
+	{"auth_required_first", "a frame other than AUTH before authentication is rejected with AUTH_REQUIRED", checkAuthFirst},
+	{"auth_invalid_credentials", "wrong credentials are rejected with INVALID_AUTH", checkInvalidCredentials},
+	{"auth_accepted", "valid credentials are acknowledged with an AUTH ACK", checkAuthAccepted},
+	{"heartbeat_pong", "a heartbeat is answered with a PONG echoing its sequence and timestamp", checkHeartbeatPong},
+	{"checksum_rejected", "a frame with a bad CRC32C is rejected with CHECKSUM_FAILED", checkChecksum},
+	{"oversized_frame_rejected", "a frame over the size limit is rejected before its payload is read", checkOversized},
+	{"unsupported_version_rejected", "a frame with an unsupported protocol version is rejected", checkVersion},
+	{"heartbeat_timeout", "a silent client is disconnected with HEARTBEAT_TIMEOUT after the heartbeat timeout", checkHeartbeatTimeout},
+}
+
+func checkAuthFirst(ctx context.Context, r *runner) (string, error) {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	if _, err := c.sendHeartbeat(); err != nil {
+		return "", err
+	}
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED); err != nil {
+		return "", err
+	}
+	return c.unusable(r.cfg.CloseWait)
+}
+
+func checkInvalidCredentials(ctx context.Context, r *runner) (string, error) {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	err = c.send(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: r.cfg.Username,
+		Password: r.cfg.Password + "-wrong",
+		ClientId: "conformance",
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_INVALID_AUTH); err != nil {
+		return "", err
+	}
+	return c.unusable(r.cfg.CloseWait)
+}
+
+func checkAuthAccepted(ctx context.Context, r *runner) (string, error) {
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.Close()
+	return "", nil
+}
+
+func checkHeartbeatPong(ctx context.Context, r *runner) (string, error) {
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	var rtt time.Duration
+	for i := 0; i < 2; i++ {
+		sent := time.Now()
+		hb, err := c.sendHeartbeat()
+		if err != nil {
+			return "", err
+		}
+		var pong pb.HeartbeatResponse
+		if err := c.expect(protocol.MessageTypePong, &pong); err != nil {
+			return "", err
+		}
+		rtt = time.Since(sent)
+		if pong.Sequence != hb.Sequence {
+			return "", fmt.Errorf("PONG sequence %d, want %d", pong.Sequence, hb.Sequence)
+		}
+		if pong.ClientTimestampMs != hb.TimestampMs {
+			return "", fmt.Errorf("PONG echoed timestamp %d, want %d", pong.ClientTimestampMs, hb.TimestampMs)
+		}
+		if pong.ServerTimestampMs == 0 {
+			return "", fmt.Errorf("PONG without a server timestamp")
+		}
+	}
+	return fmt.Sprintf("rtt %s", rtt.Round(time.Microsecond)), nil
+}
+
+func checkChecksum(ctx context.Context, r *runner) (string, error) {
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{
+		TimestampMs: time.Now().UnixMilli(),
+		Sequence:    1,
+	})
+	if err != nil {
+		return "", err
+	}
+	data, err := frame.Marshal()
+	if err != nil {
+		return "", err
+	}
+	data[len(data)-1] ^= 0xFF
+	if err := c.sendRaw(data); err != nil {
+		return "", err
+	}
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_CHECKSUM_FAILED); err != nil {
+		return "", err
+	}
+	return c.unusable(r.cfg.CloseWait)
+}
+
+func checkOversized(ctx context.Context, r *runner) (string, error) {
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	// Only the header: the server must reject the length without waiting
+	// for a payload that never comes
+	header := []byte{protocol.MagicByte1, protocol.MagicByte2, protocol.ProtocolVersion, byte(protocol.MessageTypeHeartbeat), 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], r.cfg.MaxMessageSize+1)
+	if err := c.sendRaw(header); err != nil {
+		return "", err
+	}
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_MESSAGE_TOO_LARGE, pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE); err != nil {
+		return "", err
+	}
+	return c.unusable(r.cfg.CloseWait)
+}
+
+func checkVersion(ctx context.Context, r *runner) (string, error) {
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{
+		TimestampMs: time.Now().UnixMilli(),
+		Sequence:    1,
+	})
+	if err != nil {
+		return "", err
+	}
+	frame.Version = unsupportedVersion
+	data, err := frame.Marshal()
+	if err != nil {
+		return "", err
+	}
+	if err := c.sendRaw(data); err != nil {
+		return "", err
+	}
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_PROTOCOL_VERSION, pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE); err != nil {
+		return "", err
+	}
+	return c.unusable(r.cfg.CloseWait)
+}
+
+func checkHeartbeatTimeout(ctx context.Context, r *runner) (string, error) {
+	timeout := r.cfg.HeartbeatTimeout
+	if timeout <= 0 {
+		return "", fmt.Errorf("%w: heartbeat timeout not configured", errSkipped)
+	}
+	c, err := r.authenticated(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	// Allow a tenth of the timeout early, for timers started before the ACK
+	// was sent, and the response timeout late
+	start := time.Now()
+	c.timeout = timeout + r.cfg.Timeout
+	if _, err := c.expectError(pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT); err != nil {
+		return "", err
+	}
+	elapsed := time.Since(start)
+	if elapsed < timeout-timeout/10 {
+		return "", fmt.Errorf("disconnected after %s, before the %s timeout", elapsed.Round(time.Millisecond), timeout)
+	}
+	return fmt.Sprintf("disconnected after %s", elapsed.Round(time.Millisecond)), nil
+}
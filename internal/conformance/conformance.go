@@ -0,0 +1,180 @@
+// Package conformance checks a running server against the Tick-Storm wire
+// protocol: AUTH-first enforcement, frame integrity, size and version
+// rejection, and heartbeat handling. It only speaks the protocol, so it can
+// validate any implementation, not just this one.
+package conformance
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// errSkipped marks a check that does not apply to the configuration.
+var errSkipped = errors.New("skipped")
+
+// Config holds the target and the server settings the checks depend on.
+type Config struct {
+	Addr     string
+	Username string
+	Password string
+	TLS      *tls.Config // nil dials plain TCP
+
+	Timeout         time.Duration // How long to wait for each response
+	CloseWait       time.Duration // How long a rejected connection is watched for further responses
+	ConnectInterval time.Duration // Pause between connections, for servers that limit connection rate
+
+	// Server limits. A zero HeartbeatTimeout skips the timeout check, which
+	// takes that long to run.
+	MaxMessageSize   uint32
+	HeartbeatTimeout time.Duration
+
+	// Dial overrides Addr and TLS, e.g. for in-memory connections.
+	Dial func(ctx context.Context) (net.Conn, error)
+}
+
+// DefaultConfig returns the configuration for a local server using the
+// STREAM_USER and STREAM_PASS credentials.
+func DefaultConfig() *Config {
+	return &Config{
+		Addr:            "localhost:8080",
+		Username:        os.Getenv("STREAM_USER"),
+		Password:        os.Getenv("STREAM_PASS"),
+		Timeout:         5 * time.Second,
+		CloseWait:       time.Second,
+		ConnectInterval: 200 * time.Millisecond,
+		MaxMessageSize:  protocol.DefaultMaxMessageSize,
+	}
+}
+
+// Status is the outcome of one check.
+type Status string
+
+// Check outcomes.
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Result is the outcome of one check with what was observed.
+type Result struct {
+	Check    string
+	Status   Status
+	Detail   string
+	Duration time.Duration
+}
+
+// Report collects the results of a run.
+type Report struct {
+	Target  string
+	Results []Result
+}
+
+// Failed returns the number of failed checks.
+func (r *Report) Failed() int {
+	failed := 0
+	for _, res := range r.Results {
+		if res.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Print writes the report as a table followed by a summary line.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Conformance report for %s\n\n", r.Target)
+	counts := map[Status]int{}
+	for _, res := range r.Results {
+		counts[res.Status]++
+		fmt.Fprintf(w, "%-4s  %-28s %8s  %s\n", res.Status, res.Check, res.Duration.Round(time.Millisecond), res.Detail)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", counts[StatusPass], counts[StatusFail], counts[StatusSkip])
+}
+
+// Run executes the checks whose names match filter (nil runs all) in order.
+func Run(ctx context.Context, cfg *Config, filter *regexp.Regexp) *Report {
+	r := &runner{cfg: cfg}
+	report := &Report{Target: cfg.Addr}
+	if cfg.Dial != nil {
+		report.Target = "custom dialer"
+	}
+	for _, check := range Checks {
+		if filter != nil && !filter.MatchString(check.Name) {
+			continue
+		}
+		start := time.Now()
+		detail, err := check.run(ctx, r)
+		res := Result{Check: check.Name, Status: StatusPass, Detail: detail, Duration: time.Since(start)}
+		switch {
+		case errors.Is(err, errSkipped):
+			res.Status, res.Detail = StatusSkip, strings.TrimPrefix(err.Error(), errSkipped.Error()+": ")
+		case err != nil:
+			res.Status, res.Detail = StatusFail, err.Error()
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// runner opens the connections the checks use.
+type runner struct {
+	cfg      *Config
+	lastDial time.Time
+}
+
+// dial opens a connection, keeping ConnectInterval since the last one.
+func (r *runner) dial(ctx context.Context) (*conn, error) {
+	if wait := r.cfg.ConnectInterval - time.Since(r.lastDial); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	r.lastDial = time.Now()
+
+	var nc net.Conn
+	var err error
+	switch {
+	case r.cfg.Dial != nil:
+		nc, err = r.cfg.Dial(ctx)
+	case r.cfg.TLS != nil:
+		d := tls.Dialer{Config: r.cfg.TLS}
+		nc, err = d.DialContext(ctx, "tcp", r.cfg.Addr)
+	default:
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", r.cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	return &conn{
+		Conn:    nc,
+		reader:  protocol.NewFrameReader(nc, r.cfg.MaxMessageSize),
+		timeout: r.cfg.Timeout,
+	}, nil
+}
+
+// authenticated dials and authenticates.
+func (r *runner) authenticated(ctx context.Context) (*conn, error) {
+	c, err := r.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.auth(r.cfg.Username, r.cfg.Password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
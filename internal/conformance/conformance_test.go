@@ -0,0 +1,60 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/server"
+	"github.com/furkansarikaya/tick-storm/internal/testutil"
+)
+
+func harnessConfig(t *testing.T, configure ...func(*server.Config)) *Config {
+	h := testutil.Start(t, append([]func(*server.Config){func(c *server.Config) {
+		// The timeout check waits in real time
+		c.Clock = server.RealClock()
+		c.HeartbeatInterval = 100 * time.Millisecond
+		c.HeartbeatTimeout = 300 * time.Millisecond
+	}}, configure...)...)
+
+	cfg := DefaultConfig()
+	cfg.Username, cfg.Password = testutil.Username, testutil.Password
+	cfg.Timeout = time.Second
+	cfg.CloseWait = 50 * time.Millisecond
+	cfg.ConnectInterval = 0
+	cfg.HeartbeatTimeout = h.Config.HeartbeatTimeout
+	cfg.Dial = func(context.Context) (net.Conn, error) { return h.Listener.Dial() }
+	return cfg
+}
+
+func TestServerConforms(t *testing.T) {
+	report := Run(context.Background(), harnessConfig(t), nil)
+	require.Len(t, report.Results, len(Checks))
+	for _, res := range report.Results {
+		assert.Equal(t, StatusPass, res.Status, "%s: %s", res.Check, res.Detail)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Contains(t, out.String(), "8 passed, 0 failed, 0 skipped")
+}
+
+func TestRunReportsFailuresAndSkips(t *testing.T) {
+	cfg := harnessConfig(t)
+	cfg.Password = "not-the-password"
+	cfg.HeartbeatTimeout = 0
+
+	report := Run(context.Background(), cfg, regexp.MustCompile(`^(auth_accepted|heartbeat_timeout)$`))
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, StatusFail, report.Results[0].Status)
+	assert.Contains(t, report.Results[0].Detail, "INVALID_AUTH")
+	assert.Equal(t, StatusSkip, report.Results[1].Status)
+	assert.Equal(t, "heartbeat timeout not configured", report.Results[1].Detail)
+	assert.Equal(t, 1, report.Failed())
+}
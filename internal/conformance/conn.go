@@ -0,0 +1,153 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// conn is a client connection with response expectations.
+type conn struct {
+	net.Conn
+	reader    *protocol.FrameReader
+	timeout   time.Duration
+	heartbeat uint64 // Sequence of the last heartbeat sent
+}
+
+// send writes msg as a frame of msgType.
+func (c *conn) send(msgType protocol.MessageType, msg proto.Message) error {
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	if err != nil {
+		return err
+	}
+	data, err := frame.Marshal()
+	if err != nil {
+		return err
+	}
+	return c.sendRaw(data)
+}
+
+// sendRaw writes bytes as they are, for malformed frames.
+func (c *conn) sendRaw(data []byte) error {
+	c.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.Write(data); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
+
+// sendHeartbeat sends the next heartbeat stamped with the local time.
+func (c *conn) sendHeartbeat() (*pb.HeartbeatRequest, error) {
+	c.heartbeat++
+	hb := &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: c.heartbeat}
+	return hb, c.send(protocol.MessageTypeHeartbeat, hb)
+}
+
+// read waits up to wait for the next frame.
+func (c *conn) read(wait time.Duration) (*protocol.Frame, error) {
+	c.SetReadDeadline(time.Now().Add(wait))
+	return c.reader.ReadFrame()
+}
+
+// expect reads the next frame, requires it to be of msgType and decodes it
+// into msg. An unexpected ERROR is reported with its code.
+func (c *conn) expect(msgType protocol.MessageType, msg proto.Message) error {
+	frame, err := c.read(c.timeout)
+	if err != nil {
+		return fmt.Errorf("no response: %w", err)
+	}
+	if frame.Type != msgType {
+		if frame.Type == protocol.MessageTypeError {
+			var resp pb.ErrorResponse
+			if proto.Unmarshal(frame.Payload, &resp) == nil {
+				return fmt.Errorf("expected %s, got %s: %s", typeName(msgType), resp.Code, resp.Message)
+			}
+		}
+		return fmt.Errorf("expected %s, got %s", typeName(msgType), typeName(frame.Type))
+	}
+	if err := proto.Unmarshal(frame.Payload, msg); err != nil {
+		return fmt.Errorf("undecodable %s payload: %w", typeName(msgType), err)
+	}
+	return nil
+}
+
+// expectError reads an ERROR frame carrying one of codes.
+func (c *conn) expectError(codes ...pb.ErrorCode) (*pb.ErrorResponse, error) {
+	var resp pb.ErrorResponse
+	if err := c.expect(protocol.MessageTypeError, &resp); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if resp.Code == code {
+			return &resp, nil
+		}
+	}
+	return nil, fmt.Errorf("expected error %v, got %s: %s", codes, resp.Code, resp.Message)
+}
+
+// auth authenticates and requires an AUTH ACK.
+func (c *conn) auth(username, password string) error {
+	err := c.send(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: username,
+		Password: password,
+		ClientId: "conformance",
+		Version:  "1.0.0",
+	})
+	if err != nil {
+		return err
+	}
+	var ack pb.AckResponse
+	if err := c.expect(protocol.MessageTypeACK, &ack); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	if !ack.Success || ack.AckType != pb.MessageType_MESSAGE_TYPE_AUTH {
+		return fmt.Errorf("auth: ACK success=%v type=%s", ack.Success, ack.AckType)
+	}
+	return nil
+}
+
+// unusable checks that a rejected connection is no longer served: a
+// heartbeat sent after the rejection must get no PONG within wait. It
+// describes what the server did with the connection.
+func (c *conn) unusable(wait time.Duration) (string, error) {
+	if _, err := c.sendHeartbeat(); err != nil {
+		return "server closed the connection", nil
+	}
+	frame, err := c.read(wait)
+	var netErr net.Error
+	switch {
+	case err == nil && frame.Type == protocol.MessageTypeError:
+		return "server answered further frames with errors", nil
+	case err == nil:
+		return "", fmt.Errorf("connection still served after rejection: got %s", typeName(frame.Type))
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "connection left open but no longer served", nil
+	case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed), errors.Is(err, io.ErrClosedPipe):
+		return "server closed the connection", nil
+	}
+	return "connection failed: " + err.Error(), nil
+}
+
+// typeName names a message type for reports.
+func typeName(t protocol.MessageType) string {
+	names := map[protocol.MessageType]string{
+		protocol.MessageTypeAuth:      "AUTH",
+		protocol.MessageTypeSubscribe: "SUBSCRIBE",
+		protocol.MessageTypeHeartbeat: "HEARTBEAT",
+		protocol.MessageTypeDataBatch: "DATA_BATCH",
+		protocol.MessageTypeError:     "ERROR",
+		protocol.MessageTypeACK:       "ACK",
+		protocol.MessageTypePong:      "PONG",
+	}
+	if name, ok := names[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type 0x%02X", uint8(t))
+}
@@ -0,0 +1,142 @@
+// Package logging builds the process-wide structured logger from
+// configuration: level, text or JSON output, an optional rotated log file and
+// sampling of high-frequency debug messages.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config controls how the logger is built.
+type Config struct {
+	Level  slog.Level
+	Format string // FormatText or FormatJSON
+
+	// Log file; empty writes to stderr. The file is rotated once it reaches
+	// MaxSizeMB, keeping MaxBackups old files (0 keeps none).
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+
+	// Debug messages are sampled per message text: the first SampleFirst in
+	// each SampleInterval are logged, then every SampleThereafter-th.
+	// SampleFirst of 0 disables sampling.
+	SampleFirst      int
+	SampleThereafter int
+	SampleInterval   time.Duration
+}
+
+// DefaultConfig returns the logging defaults: info level text on stderr, no
+// sampling.
+func DefaultConfig() *Config {
+	return &Config{
+		Level:            slog.LevelInfo,
+		Format:           FormatText,
+		MaxSizeMB:        100,
+		MaxBackups:       5,
+		SampleThereafter: 100,
+		SampleInterval:   time.Second,
+	}
+}
+
+// LoadConfigFromEnv overrides config with LOG_* environment variables.
+// Invalid values are left at their current setting and reported in the
+// returned error, so they can be logged once the logger exists.
+func LoadConfigFromEnv(config *Config) error {
+	var errs []error
+	invalid := func(name, value string) {
+		errs = append(errs, fmt.Errorf("ignoring invalid %s %q", name, value))
+	}
+	positiveInt := func(name string, dst *int, allowZero bool) {
+		v := os.Getenv(name)
+		if v == "" {
+			return
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (n == 0 && !allowZero) {
+			invalid(name, v)
+			return
+		}
+		*dst = n
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			invalid("LOG_LEVEL", v)
+		} else {
+			config.Level = level
+		}
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		switch f := strings.ToLower(v); f {
+		case FormatText, FormatJSON:
+			config.Format = f
+		default:
+			invalid("LOG_FORMAT", v)
+		}
+	}
+	if v, ok := os.LookupEnv("LOG_FILE"); ok {
+		config.File = v
+	}
+	positiveInt("LOG_MAX_SIZE_MB", &config.MaxSizeMB, false)
+	positiveInt("LOG_MAX_BACKUPS", &config.MaxBackups, true)
+	positiveInt("LOG_SAMPLE_FIRST", &config.SampleFirst, true)
+	positiveInt("LOG_SAMPLE_THEREAFTER", &config.SampleThereafter, false)
+	if v := os.Getenv("LOG_SAMPLE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			config.SampleInterval = d
+		} else {
+			invalid("LOG_SAMPLE_INTERVAL", v)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// New builds a logger from config. Output goes to stderr unless config.File
+// is set; the returned closer releases the file and is safe to call when
+// logging to stderr.
+func New(config *Config) (*slog.Logger, io.Closer, error) {
+	var out io.WriteCloser = nopCloser{os.Stderr}
+	if config.File != "" {
+		f, err := OpenRotatingFile(config.File, int64(config.MaxSizeMB)*1024*1024, config.MaxBackups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open log file: %w", err)
+		}
+		out = f
+	}
+	return slog.New(NewHandler(config, out)), out, nil
+}
+
+// NewHandler returns the handler New writes through, for callers supplying
+// their own writer.
+func NewHandler(config *Config, w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: config.Level}
+	var h slog.Handler
+	if config.Format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	if config.SampleFirst > 0 {
+		h = newSamplingHandler(h, config.SampleFirst, config.SampleThereafter, config.SampleInterval)
+	}
+	return h
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "JSON")
+	t.Setenv("LOG_FILE", "/var/log/tick-storm.log")
+	t.Setenv("LOG_MAX_BACKUPS", "0")
+	t.Setenv("LOG_SAMPLE_FIRST", "10")
+	t.Setenv("LOG_SAMPLE_INTERVAL", "bogus")
+
+	config := DefaultConfig()
+	err := LoadConfigFromEnv(config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LOG_SAMPLE_INTERVAL")
+
+	assert.Equal(t, slog.LevelDebug, config.Level)
+	assert.Equal(t, FormatJSON, config.Format)
+	assert.Equal(t, "/var/log/tick-storm.log", config.File)
+	assert.Equal(t, 0, config.MaxBackups)
+	assert.Equal(t, 10, config.SampleFirst)
+	assert.Equal(t, time.Second, config.SampleInterval, "invalid values keep the default")
+}
+
+func TestNewHandlerFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.Format = FormatJSON
+	config.Level = slog.LevelWarn
+	logger := slog.New(NewHandler(config, &buf))
+
+	logger.Info("dropped")
+	logger.Warn("kept", "connection_id", "c1")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "kept", record["msg"])
+	assert.Equal(t, "c1", record["connection_id"])
+}
+
+func TestSamplingThinsRepeatedDebugMessages(t *testing.T) {
+	var buf bytes.Buffer
+	config := DefaultConfig()
+	config.Level = slog.LevelDebug
+	config.SampleFirst = 2
+	config.SampleThereafter = 5
+	h := NewHandler(config, &buf).(*samplingHandler)
+	now := time.Unix(0, 0)
+	h.sampler.now = func() time.Time { return now }
+
+	// Loggers derived per connection share the sampler
+	a := slog.New(h).With("connection_id", "a")
+	b := slog.New(h).With("connection_id", "b")
+	for i := 0; i < 6; i++ {
+		a.Debug("tick generated")
+		b.Debug("tick generated")
+	}
+	a.Info("subscribed")
+	assert.Equal(t, 4, strings.Count(buf.String(), "tick generated"), "the first 2 of 12, then the 7th and 12th")
+	assert.Contains(t, buf.String(), "subscribed", "info is never sampled")
+
+	buf.Reset()
+	now = now.Add(config.SampleInterval)
+	a.Debug("tick generated")
+	assert.Contains(t, buf.String(), "tick generated", "counts reset each interval")
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	rf, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		_, err := rf.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, rf.Close())
+
+	read := func(p string) string {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "dddddd\n", read(path))
+	assert.Equal(t, "cccccc\n", read(path+".1"))
+	assert.Equal(t, "bbbbbb\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3", "only MaxBackups files are kept")
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// samplingHandler thins debug records that repeat the same message, such as
+// per-frame or per-tick logging, so enabling debug on a busy server does not
+// flood the output. Info and above always pass through.
+type samplingHandler struct {
+	next    slog.Handler
+	sampler *sampler
+}
+
+// sampler holds counts shared by a handler and everything derived from it
+// with WithAttrs or WithGroup.
+type sampler struct {
+	first      int
+	thereafter int
+	interval   time.Duration
+	now        func() time.Time
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+}
+
+func newSamplingHandler(next slog.Handler, first, thereafter int, interval time.Duration) *samplingHandler {
+	return &samplingHandler{
+		next: next,
+		sampler: &sampler{
+			first:      first,
+			thereafter: thereafter,
+			interval:   interval,
+			now:        time.Now,
+			counts:     make(map[string]int),
+		},
+	}
+}
+
+// allow counts msg in the current window and reports whether to log it.
+func (s *sampler) allow(msg string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := s.now(); now.Sub(s.window) >= s.interval {
+		s.window = now
+		clear(s.counts)
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	return n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0)
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo && !h.sampler.allow(r.Message) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{next: h.next.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{next: h.next.WithGroup(name), sampler: h.sampler}
+}
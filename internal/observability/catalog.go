@@ -0,0 +1,106 @@
+// Package observability generates Grafana dashboards and Prometheus alert
+// rules from the metrics the server registers, so the artifacts cannot
+// reference metrics that no longer exist.
+package observability
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+// Catalog indexes server metrics by name.
+type Catalog struct {
+	metrics []server.MetricInfo
+	byName  map[string]server.MetricInfo
+}
+
+// NewCatalog builds a catalog from the metrics registered by a PrometheusMetrics.
+func NewCatalog(metrics []server.MetricInfo) *Catalog {
+	c := &Catalog{
+		metrics: append([]server.MetricInfo(nil), metrics...),
+		byName:  make(map[string]server.MetricInfo, len(metrics)),
+	}
+	sort.Slice(c.metrics, func(i, j int) bool { return c.metrics[i].Name < c.metrics[j].Name })
+	for _, m := range c.metrics {
+		c.byName[m.Name] = m
+	}
+	return c
+}
+
+// ServerCatalog returns the catalog of every metric the server can export,
+// including those registered only once the server runs.
+func ServerCatalog() *Catalog {
+	pm := server.NewPrometheusMetrics()
+	// Callbacks only run at scrape time
+	pm.RegisterQoSMetrics("", nil, nil)
+	pm.RegisterConnMemoryMetrics("", nil)
+	pm.RegisterWriteErrorMetrics("", nil)
+	pm.RegisterEgressMetrics("", nil)
+	pm.RegisterBatchTunerMetrics("", nil)
+	pm.RegisterSLOMetrics("", nil)
+	pm.RegisterTLSHandshakeMetrics("", nil)
+	pm.RegisterAuthLockoutMetrics("", nil)
+	pm.RegisterConnectionStageMetrics("", nil)
+	pm.RegisterClusterMetrics("", nil)
+	pm.RegisterReplicationMetrics("", nil)
+	return NewCatalog(pm.Catalog())
+}
+
+// Metrics returns the cataloged metrics sorted by name.
+func (c *Catalog) Metrics() []server.MetricInfo {
+	return c.metrics
+}
+
+// Lookup returns the metric with the given name.
+func (c *Catalog) Lookup(name string) (server.MetricInfo, bool) {
+	m, ok := c.byName[name]
+	return m, ok
+}
+
+// metricRef matches series names in PromQL, with histogram suffixes split off.
+var metricRef = regexp.MustCompile(`\b(tick_storm_[a-z0-9_]+?)(_bucket|_sum|_count)?\b`)
+
+// Unknown returns the tick_storm_* metrics referenced in text, such as a
+// rules file or dashboard, that the catalog does not contain.
+func (c *Catalog) Unknown(text string) []string {
+	seen := make(map[string]bool)
+	var unknown []string
+	for _, match := range metricRef.FindAllStringSubmatch(text, -1) {
+		name := match[1]
+		if _, ok := c.byName[name]; !ok && match[2] != "" {
+			// A metric legitimately named *_count without a histogram
+			name = match[0]
+		}
+		if _, ok := c.byName[name]; ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// metricFunc returns a template function that resolves a metric name and
+// fails generation if it is not in the catalog.
+func (c *Catalog) metricFunc() func(string) (string, error) {
+	return func(name string) (string, error) {
+		if _, ok := c.byName[name]; !ok {
+			return "", fmt.Errorf("metric %q is not registered", name)
+		}
+		return name, nil
+	}
+}
+
+// section groups a metric by the word after the tick_storm_ prefix.
+func section(name string) string {
+	name = strings.TrimPrefix(name, "tick_storm_")
+	if i := strings.IndexByte(name, '_'); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,228 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+// DashboardUID is the stable Grafana UID of the generated dashboard, so alert
+// annotations and bookmarks keep resolving across regenerations.
+const DashboardUID = "tickstorm-overview"
+
+const (
+	panelWidth  = 12
+	panelHeight = 8
+	rateWindow  = "5m"
+)
+
+// Grafana dashboard JSON model, limited to the fields the generator sets.
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	Refresh       string     `json:"refresh"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Editable      bool       `json:"editable"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	GridPos     gridPos      `json:"gridPos"`
+	Collapsed   *bool        `json:"collapsed,omitempty"`
+	Datasource  *datasource  `json:"datasource,omitempty"`
+	FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+	Targets     []target     `json:"targets,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type target struct {
+	RefID        string      `json:"refId"`
+	Expr         string      `json:"expr"`
+	LegendFormat string      `json:"legendFormat"`
+	Datasource   *datasource `json:"datasource"`
+}
+
+var promDatasource = &datasource{Type: "prometheus", UID: "${datasource}"}
+
+// GenerateDashboard renders a Grafana dashboard with one panel per cataloged
+// metric, grouped into rows by metric name.
+func GenerateDashboard(c *Catalog) ([]byte, error) {
+	d := dashboard{
+		UID:           DashboardUID,
+		Title:         "TickStorm Overview",
+		Description:   strings.TrimSpace(generatedHeader("")),
+		Tags:          []string{"tickstorm", "generated"},
+		Timezone:      "browser",
+		Refresh:       "30s",
+		SchemaVersion: 39,
+		Editable:      false,
+		Time:          timeRange{From: "now-1h", To: "now"},
+		Templating: templating{List: []variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+	}
+
+	id, y := 1, 0
+	current := ""
+	var x int
+	for _, m := range c.Metrics() {
+		if s := section(m.Name); s != current {
+			if x != 0 {
+				y += panelHeight
+				x = 0
+			}
+			current = s
+			collapsed := false
+			d.Panels = append(d.Panels, panel{
+				ID: id, Type: "row", Title: strings.ToUpper(s[:1]) + s[1:],
+				GridPos: gridPos{H: 1, W: 24, X: 0, Y: y}, Collapsed: &collapsed,
+			})
+			id++
+			y++
+		}
+		d.Panels = append(d.Panels, metricPanel(id, m, gridPos{H: panelHeight, W: panelWidth, X: x, Y: y}))
+		id++
+		x += panelWidth
+		if x >= 24 {
+			x = 0
+			y += panelHeight
+		}
+	}
+
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// metricPanel builds a time series panel whose query suits the metric type.
+func metricPanel(id int, m server.MetricInfo, pos gridPos) panel {
+	by := groupingLabels(m.Labels)
+	legend := "{{instance}}"
+	if len(by) > 0 {
+		parts := make([]string, len(by))
+		for i, l := range by {
+			parts[i] = "{{" + l + "}}"
+		}
+		legend = strings.Join(parts, " ")
+	}
+
+	var targets []target
+	switch m.Type {
+	case server.MetricTypeCounter:
+		targets = []target{{
+			Expr:         aggregate(by, fmt.Sprintf("rate(%s[%s])", m.Name, rateWindow)),
+			LegendFormat: legend,
+		}}
+	case server.MetricTypeGauge:
+		targets = []target{{Expr: aggregate(by, m.Name), LegendFormat: legend}}
+	case server.MetricTypeHistogram:
+		le := append([]string{"le"}, by...)
+		for _, q := range []string{"0.5", "0.95", "0.99"} {
+			targets = append(targets, target{
+				Expr: fmt.Sprintf("histogram_quantile(%s, %s)", q,
+					aggregate(le, fmt.Sprintf("rate(%s_bucket[%s])", m.Name, rateWindow))),
+				LegendFormat: "p" + strings.TrimPrefix(q, "0.") + " " + legend,
+			})
+		}
+	}
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+		targets[i].Datasource = promDatasource
+	}
+
+	return panel{
+		ID:          id,
+		Type:        "timeseries",
+		Title:       m.Help,
+		Description: fmt.Sprintf("%s (%s)", m.Name, m.Type),
+		GridPos:     pos,
+		Datasource:  promDatasource,
+		FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: unit(m)}},
+		Targets:     targets,
+	}
+}
+
+// groupingLabels drops instance_id, which Prometheus' own instance label
+// already distinguishes, and keeps the labels worth splitting series by.
+func groupingLabels(labels []string) []string {
+	var by []string
+	for _, l := range labels {
+		if l != "instance_id" {
+			by = append(by, l)
+		}
+	}
+	return by
+}
+
+func aggregate(by []string, expr string) string {
+	if len(by) == 0 {
+		return fmt.Sprintf("sum(%s)", expr)
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", strings.Join(by, ", "), expr)
+}
+
+// unit picks a Grafana unit from the Prometheus base-unit suffix.
+func unit(m server.MetricInfo) string {
+	name := strings.TrimSuffix(m.Name, "_total")
+	switch {
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	case strings.Contains(name, "bytes"):
+		if m.Type == server.MetricTypeCounter {
+			return "Bps"
+		}
+		return "bytes"
+	case m.Type == server.MetricTypeCounter:
+		return "ops"
+	default:
+		return "short"
+	}
+}
@@ -0,0 +1,104 @@
+package observability
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+func TestServerCatalogIncludesRuntimeMetrics(t *testing.T) {
+	c := ServerCatalog()
+
+	m, ok := c.Lookup("tick_storm_publish_latency_seconds")
+	require.True(t, ok)
+	assert.Equal(t, server.MetricTypeHistogram, m.Type)
+
+	m, ok = c.Lookup("tick_storm_qos_dropped_total")
+	require.True(t, ok, "metrics registered at server start are cataloged")
+	assert.Equal(t, []string{"class", "instance_id"}, m.Labels)
+}
+
+func TestCatalogUnknown(t *testing.T) {
+	c := NewCatalog([]server.MetricInfo{
+		{Name: "tick_storm_latency_seconds", Type: server.MetricTypeHistogram},
+		{Name: "tick_storm_items_count", Type: server.MetricTypeGauge},
+	})
+
+	text := `rate(tick_storm_latency_seconds_bucket[5m]) + tick_storm_items_count +
+tick_storm_removed_total + tick_storm_latency_seconds_sum`
+	assert.Equal(t, []string{"tick_storm_removed_total"}, c.Unknown(text))
+}
+
+func TestGenerateAlertRulesRejectsUnregisteredMetric(t *testing.T) {
+	c := NewCatalog([]server.MetricInfo{{Name: "tick_storm_heartbeat_timeouts_total"}})
+	_, err := GenerateAlertRules(c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not registered")
+}
+
+func TestGenerateAlertRules(t *testing.T) {
+	c := ServerCatalog()
+	out, err := GenerateAlertRules(c)
+	require.NoError(t, err)
+
+	var rules rulesFile
+	require.NoError(t, yaml.Unmarshal(out, &rules))
+	require.Len(t, rules.Groups, len(alertGroups))
+	for _, g := range rules.Groups {
+		for _, r := range g.Rules {
+			assert.Empty(t, c.Unknown(r.Expr), r.Alert)
+			assert.NotEmpty(t, r.Labels["severity"], r.Alert)
+		}
+	}
+}
+
+func TestGenerateDashboardCoversCatalog(t *testing.T) {
+	c := ServerCatalog()
+	out, err := GenerateDashboard(c)
+	require.NoError(t, err)
+
+	var d dashboard
+	require.NoError(t, json.Unmarshal(out, &d))
+	assert.Equal(t, DashboardUID, d.UID)
+
+	panels := make(map[string]panel)
+	for _, p := range d.Panels {
+		if p.Type != "row" {
+			panels[p.Description] = p
+		}
+	}
+	for _, m := range c.Metrics() {
+		p, ok := panels[m.Name+" ("+string(m.Type)+")"]
+		if assert.True(t, ok, "no panel for %s", m.Name) {
+			assert.NotEmpty(t, p.Targets)
+		}
+	}
+
+	latency := panels["tick_storm_publish_latency_seconds (histogram)"]
+	require.Len(t, latency.Targets, 3)
+	assert.Equal(t, "histogram_quantile(0.95, sum by (le) (rate(tick_storm_publish_latency_seconds_bucket[5m])))",
+		latency.Targets[1].Expr)
+	assert.Equal(t, "s", latency.FieldConfig.Defaults.Unit)
+}
+
+// TestCommittedArtifactsUpToDate fails when metrics change without
+// regenerating the files under monitoring/.
+func TestCommittedArtifactsUpToDate(t *testing.T) {
+	c := ServerCatalog()
+	for path, gen := range map[string]func(*Catalog) ([]byte, error){
+		"../../monitoring/grafana/tickstorm-overview.json": GenerateDashboard,
+		"../../monitoring/prometheus-rules.yml":            GenerateAlertRules,
+	} {
+		committed, err := os.ReadFile(path)
+		require.NoError(t, err)
+		want, err := gen(c)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(committed), "%s is stale; run make metrics-artifacts", path)
+	}
+}
@@ -0,0 +1,337 @@
+package observability
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ruleGroup is a set of alert rules evaluated together.
+type ruleGroup struct {
+	name     string
+	interval time.Duration
+	rules    []alertRule
+}
+
+// alertRule is an alert whose expression names metrics with {{metric "..."}}.
+type alertRule struct {
+	alert       string
+	expr        string
+	forDuration time.Duration
+	severity    string
+	component   string
+	summary     string
+	description string
+	runbook     string
+}
+
+const runbookBaseURL = "https://runbooks.tickstorm.io/"
+
+var alertGroups = []ruleGroup{
+	{name: "tickstorm.heartbeat", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert: "TickStormHeartbeatTimeoutRate",
+			expr: `(
+  rate({{metric "tick_storm_heartbeat_timeouts_total"}}[5m]) /
+  rate({{metric "tick_storm_heartbeats_recv_total"}}[5m])
+) * 100 > 1`,
+			forDuration: 2 * time.Minute, severity: "critical", component: "heartbeat",
+			summary:     "TickStorm heartbeat timeout rate is {{ $value }}%",
+			description: "Heartbeat timeout rate has exceeded 1% for more than 2 minutes on instance {{ $labels.instance }}",
+			runbook:     "heartbeat-timeouts",
+		},
+		{
+			alert: "TickStormConnectionDropRate",
+			expr: `(
+  rate({{metric "tick_storm_connection_errors_total"}}{error_type="connection_dropped"}[5m]) /
+  rate({{metric "tick_storm_total_connections_total"}}[5m])
+) * 100 > 0.5`,
+			forDuration: 5 * time.Minute, severity: "high", component: "connection",
+			summary:     "TickStorm connection drop rate is {{ $value }}%",
+			description: "Connection drop rate has exceeded 0.5% for more than 5 minutes on instance {{ $labels.instance }}",
+			runbook:     "connection-drops",
+		},
+		{
+			alert: "TickStormServiceDown",
+			expr: `{{metric "tick_storm_active_connections"}} == 0 and
+rate({{metric "tick_storm_total_connections_total"}}[5m]) == 0`,
+			forDuration: time.Minute, severity: "critical", component: "service",
+			summary:     "TickStorm service appears to be down",
+			description: "No active connections and no new connections for more than 1 minute on instance {{ $labels.instance }}",
+			runbook:     "service-down",
+		},
+	}},
+	{name: "tickstorm.performance", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert:       "TickStormPublishLatencyHigh",
+			expr:        `histogram_quantile(0.95, rate({{metric "tick_storm_publish_latency_seconds"}}_bucket[5m])) * 1000 > 5`,
+			forDuration: 3 * time.Minute, severity: "high", component: "performance",
+			summary:     "TickStorm publish latency p95 is {{ $value }}ms",
+			description: "95th percentile publish latency has exceeded 5ms for more than 3 minutes on instance {{ $labels.instance }}",
+			runbook:     "high-latency",
+		},
+		{
+			alert:       "TickStormMessageProcessingLatencyHigh",
+			expr:        `histogram_quantile(0.95, rate({{metric "tick_storm_message_processing_duration_seconds"}}_bucket[5m])) * 1000 > 2`,
+			forDuration: 3 * time.Minute, severity: "medium", component: "performance",
+			summary:     "TickStorm message processing latency p95 is {{ $value }}ms",
+			description: "95th percentile message processing duration has exceeded 2ms for more than 3 minutes on instance {{ $labels.instance }}",
+			runbook:     "message-processing",
+		},
+		{
+			alert: "TickStormWriteTimeoutRate",
+			expr: `(
+  rate({{metric "tick_storm_write_timeouts_total"}}[5m]) /
+  rate({{metric "tick_storm_messages_sent_total"}}[5m])
+) * 100 > 0.1`,
+			forDuration: 2 * time.Minute, severity: "high", component: "performance",
+			summary:     "TickStorm write timeout rate is {{ $value }}%",
+			description: "Write timeout rate has exceeded 0.1% for more than 2 minutes on instance {{ $labels.instance }}",
+			runbook:     "write-timeouts",
+		},
+	}},
+	{name: "tickstorm.authentication", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert: "TickStormAuthFailureRate",
+			expr: `(
+  rate({{metric "tick_storm_auth_failures_total"}}[5m]) /
+  (rate({{metric "tick_storm_auth_success_total"}}[5m]) + rate({{metric "tick_storm_auth_failures_total"}}[5m]))
+) * 100 > 5`,
+			forDuration: 3 * time.Minute, severity: "high", component: "authentication",
+			summary:     "TickStorm authentication failure rate is {{ $value }}%",
+			description: "Authentication failure rate has exceeded 5% for more than 3 minutes on instance {{ $labels.instance }}",
+			runbook:     "auth-failures",
+		},
+		{
+			alert: "TickStormAuthRateLimitedRate",
+			expr: `(
+  rate({{metric "tick_storm_auth_rate_limited_total"}}[5m]) /
+  (rate({{metric "tick_storm_auth_success_total"}}[5m]) + rate({{metric "tick_storm_auth_failures_total"}}[5m]) + rate({{metric "tick_storm_auth_rate_limited_total"}}[5m]))
+) * 100 > 2`,
+			forDuration: 5 * time.Minute, severity: "medium", component: "authentication",
+			summary:     "TickStorm authentication rate limiting is {{ $value }}%",
+			description: "Authentication rate limiting has exceeded 2% for more than 5 minutes on instance {{ $labels.instance }}",
+			runbook:     "auth-rate-limiting",
+		},
+		{
+			alert:       "TickStormAuthUsernameLockedOut",
+			expr:        `{{metric "tick_storm_auth_locked_users"}} > 0`,
+			forDuration: time.Minute, severity: "medium", component: "authentication",
+			summary:     "TickStorm has {{ $value }} usernames locked out",
+			description: "Usernames have been locked out after repeated authentication failures on instance {{ $labels.instance }}, which may indicate a brute force attempt across IPs",
+			runbook:     "auth-failures",
+		},
+	}},
+	{name: "tickstorm.resources", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert:       "TickStormMemoryUsageHigh",
+			expr:        `({{metric "tick_storm_memory_usage_bytes"}} / (1024 * 1024 * 1024)) > 0.8`,
+			forDuration: 5 * time.Minute, severity: "high", component: "resources",
+			summary:     "TickStorm memory usage is {{ $value }}GB",
+			description: "Memory usage has exceeded 800MB for more than 5 minutes on instance {{ $labels.instance }}",
+			runbook:     "high-memory",
+		},
+		{
+			alert:       "TickStormGoroutineCountHigh",
+			expr:        `{{metric "tick_storm_goroutines"}} > 10000`,
+			forDuration: 5 * time.Minute, severity: "medium", component: "resources",
+			summary:     "TickStorm goroutine count is {{ $value }}",
+			description: "Goroutine count has exceeded 10,000 for more than 5 minutes on instance {{ $labels.instance }}",
+			runbook:     "high-goroutines",
+		},
+		{
+			alert:       "TickStormActiveConnectionsNearCapacity",
+			expr:        `{{metric "tick_storm_active_connections"}} > 90000`,
+			forDuration: 2 * time.Minute, severity: "high", component: "capacity",
+			summary:     "TickStorm active connections is {{ $value }}",
+			description: "Active connections have exceeded 90,000 (90% of capacity) for more than 2 minutes on instance {{ $labels.instance }}",
+			runbook:     "capacity-planning",
+		},
+		{
+			alert:       "TickStormQoSDropping",
+			expr:        `sum by (class) (rate({{metric "tick_storm_qos_dropped_total"}}[5m])) > 0`,
+			forDuration: 5 * time.Minute, severity: "medium", component: "qos",
+			summary:     "TickStorm is dropping {{ $value }} writes/s for priority class {{ $labels.class }}",
+			description: "Backpressure has refused writes for priority class {{ $labels.class }} for more than 5 minutes",
+			runbook:     "qos-drops",
+		},
+	}},
+	{name: "tickstorm.slo", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert:       "TickStormSLOFastBurn",
+			expr:        `{{metric "tick_storm_slo_burn_rate"}}{window="5m"} > 14.4`,
+			forDuration: 2 * time.Minute, severity: "critical", component: "slo",
+			summary:     "TickStorm delivery SLO burning {{ $value }}x the allowed error rate",
+			description: "Batches are missing the delivery SLO fast enough to spend the error budget within hours on instance {{ $labels.instance }}",
+			runbook:     "slo-burn",
+		},
+		{
+			alert:       "TickStormSLOBudgetExhausted",
+			expr:        `{{metric "tick_storm_slo_error_budget_remaining"}} <= 0`,
+			forDuration: 5 * time.Minute, severity: "high", component: "slo",
+			summary:     "TickStorm delivery SLO error budget exhausted",
+			description: "The delivery SLO error budget for the rolling window is spent and health reports degraded on instance {{ $labels.instance }}",
+			runbook:     "slo-burn",
+		},
+	}},
+	{name: "tickstorm.errors", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert: "TickStormErrorRate",
+			expr: `(
+  sum(rate({{metric "tick_storm_errors_total"}}[5m])) /
+  sum(rate({{metric "tick_storm_total_connections_total"}}[5m]))
+) * 100 > 1`,
+			forDuration: 3 * time.Minute, severity: "high", component: "errors",
+			summary:     "TickStorm error rate is {{ $value }}%",
+			description: "Overall error rate has exceeded 1% for more than 3 minutes on instance {{ $labels.instance }}",
+			runbook:     "error-rate",
+		},
+		{
+			alert: "TickStormProtocolErrorRate",
+			expr: `(
+  rate({{metric "tick_storm_protocol_errors_total"}}[5m]) /
+  rate({{metric "tick_storm_messages_recv_total"}}[5m])
+) * 100 > 0.5`,
+			forDuration: 3 * time.Minute, severity: "medium", component: "protocol",
+			summary:     "TickStorm protocol error rate is {{ $value }}%",
+			description: "Protocol error rate has exceeded 0.5% for more than 3 minutes on instance {{ $labels.instance }}",
+			runbook:     "protocol-errors",
+		},
+	}},
+	{name: "tickstorm.business", interval: 60 * time.Second, rules: []alertRule{
+		{
+			alert:       "TickStormMessageThroughputLow",
+			expr:        `rate({{metric "tick_storm_messages_sent_total"}}[5m]) < 10000`,
+			forDuration: 10 * time.Minute, severity: "medium", component: "business",
+			summary:     "TickStorm message throughput is {{ $value }} msg/s",
+			description: "Message throughput has fallen below 10,000 msg/s for more than 10 minutes on instance {{ $labels.instance }}",
+			runbook:     "low-throughput",
+		},
+		{
+			alert: "TickStormNoMessagesSent",
+			expr: `rate({{metric "tick_storm_messages_sent_total"}}[10m]) == 0 and
+{{metric "tick_storm_active_connections"}} > 0`,
+			forDuration: 5 * time.Minute, severity: "high", component: "business",
+			summary:     "TickStorm is not sending any messages",
+			description: "No messages have been sent for 5 minutes despite having {{ $value }} active connections on instance {{ $labels.instance }}",
+			runbook:     "no-messages",
+		},
+	}},
+	{name: "tickstorm.instance", interval: 30 * time.Second, rules: []alertRule{
+		{
+			alert:       "TickStormInstanceDown",
+			expr:        `up{job="tickstorm"} == 0`,
+			forDuration: time.Minute, severity: "critical", component: "instance",
+			summary:     "TickStorm instance is down",
+			description: "TickStorm instance {{ $labels.instance }} has been unreachable for more than 1 minute",
+			runbook:     "instance-down",
+		},
+		{
+			alert: "TickStormMetricsScrapeFailure",
+			expr: `up{job="tickstorm"} == 0 or
+increase(prometheus_target_scrapes_exceeded_sample_limit_total[5m]) > 0`,
+			forDuration: 3 * time.Minute, severity: "medium", component: "monitoring",
+			summary:     "TickStorm metrics scraping is failing",
+			description: "Prometheus cannot scrape metrics from TickStorm instance {{ $labels.instance }} for more than 3 minutes",
+			runbook:     "metrics-scraping",
+		},
+	}},
+}
+
+// Prometheus rule file layout.
+type rulesFile struct {
+	Groups []rulesFileGroup `yaml:"groups"`
+}
+
+type rulesFileGroup struct {
+	Name     string          `yaml:"name"`
+	Interval string          `yaml:"interval"`
+	Rules    []rulesFileRule `yaml:"rules"`
+}
+
+type rulesFileRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// GenerateAlertRules renders the alert rules as a Prometheus rule file. It
+// fails if any rule references a metric missing from the catalog.
+func GenerateAlertRules(c *Catalog) ([]byte, error) {
+	funcs := template.FuncMap{"metric": c.metricFunc()}
+	var out rulesFile
+	for _, g := range alertGroups {
+		group := rulesFileGroup{Name: g.name, Interval: promDuration(g.interval)}
+		for _, r := range g.rules {
+			expr, err := renderExpr(r.expr, funcs)
+			if err != nil {
+				return nil, fmt.Errorf("alert %s: %w", r.alert, err)
+			}
+			group.Rules = append(group.Rules, rulesFileRule{
+				Alert: r.alert,
+				Expr:  expr,
+				For:   promDuration(r.forDuration),
+				Labels: map[string]string{
+					"severity":  r.severity,
+					"service":   "tickstorm",
+					"component": r.component,
+				},
+				Annotations: map[string]string{
+					"summary":       r.summary,
+					"description":   r.description,
+					"runbook_url":   runbookBaseURL + r.runbook,
+					"dashboard_url": "{{ $externalURL }}/d/" + DashboardUID,
+				},
+			})
+		}
+		out.Groups = append(out.Groups, group)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(generatedHeader("#"))
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderExpr resolves {{metric}} references. Alert templating such as
+// {{ $value }} lives only in annotations, which are not rendered here.
+func renderExpr(expr string, funcs template.FuncMap) (string, error) {
+	tmpl, err := template.New("expr").Funcs(funcs).Option("missingkey=error").Parse(expr)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// promDuration formats d the way Prometheus configuration spells durations.
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+func generatedHeader(comment string) string {
+	return comment + " Code generated by metrics-tool from the metrics registered in internal/server. DO NOT EDIT.\n"
+}
@@ -30,13 +30,30 @@ const (
 	DefaultMaxMessageSize = 64 * 1024
 
 	// Message types
-	MessageTypeAuth      MessageType = 0x01
-	MessageTypeSubscribe MessageType = 0x02
-	MessageTypeHeartbeat MessageType = 0x03
-	MessageTypeDataBatch MessageType = 0x04
-	MessageTypeError     MessageType = 0x05
-	MessageTypeACK       MessageType = 0x06
-	MessageTypePong      MessageType = 0x07
+	MessageTypeAuth               MessageType = 0x01
+	MessageTypeSubscribe          MessageType = 0x02
+	MessageTypeHeartbeat          MessageType = 0x03
+	MessageTypeDataBatch          MessageType = 0x04
+	MessageTypeError              MessageType = 0x05
+	MessageTypeACK                MessageType = 0x06
+	MessageTypePong               MessageType = 0x07
+	MessageTypeBatchAck           MessageType = 0x08
+	MessageTypeGapFill            MessageType = 0x09
+	MessageTypeAuthChallenge      MessageType = 0x0A
+	MessageTypeInfo               MessageType = 0x0B
+	MessageTypeResume             MessageType = 0x0C
+	MessageTypeSymbolList         MessageType = 0x0D
+	MessageTypeSubscriptionUpdate MessageType = 0x0E
+	MessageTypePublish            MessageType = 0x0F
+	MessageTypeStreamStatus       MessageType = 0x10
+	MessageTypeHistory            MessageType = 0x11
+	MessageTypeKeepalive          MessageType = 0x12
+
+	// Replication message types, exchanged between server instances only.
+	// 0x80 and up are reserved for them.
+	MessageTypeReplicaSync  MessageType = 0x80
+	MessageTypeReplicaBatch MessageType = 0x81
+	MessageTypeReplicaState MessageType = 0x82
 )
 
 var (
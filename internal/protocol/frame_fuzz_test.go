@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// fuzzSeedFrames returns well-formed frames used to seed the fuzz corpus.
+func fuzzSeedFrames(f *testing.F) [][]byte {
+	f.Helper()
+
+	var seeds [][]byte
+	for _, frame := range []Frame{
+		{Version: ProtocolVersion, Type: MessageTypeAuth, Payload: []byte{0x0a, 0x04, 'u', 's', 'e', 'r'}},
+		{Version: ProtocolVersion, Type: MessageTypeSubscribe, Payload: []byte{0x08, 0x01}},
+		{Version: ProtocolVersion, Type: MessageTypeHeartbeat, Payload: []byte{}},
+		{Version: ProtocolVersion, Type: MessageTypeDataBatch, Payload: bytes.Repeat([]byte{0xAB}, 128)},
+	} {
+		data, err := frame.Marshal()
+		if err != nil {
+			f.Fatalf("failed to marshal seed frame: %v", err)
+		}
+		seeds = append(seeds, data)
+	}
+	return seeds
+}
+
+// verifyChecksum reports whether the trailing CRC32C of a raw frame matches its contents.
+func verifyChecksum(data []byte) bool {
+	if len(data) < MinFrameSize {
+		return false
+	}
+	checksumStart := len(data) - CRCSize
+	provided := binary.BigEndian.Uint32(data[checksumStart:])
+	return provided == crc32.Checksum(data[:checksumStart], crc32.MakeTable(crc32.Castagnoli))
+}
+
+func FuzzFrameUnmarshal(f *testing.F) {
+	for _, seed := range fuzzSeedFrames(f) {
+		f.Add(seed)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{MagicByte1, MagicByte2, ProtocolVersion, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var frame Frame
+		if err := frame.Unmarshal(data); err != nil {
+			return
+		}
+
+		// A successfully decoded frame must never bypass the checksum or size limits
+		if !verifyChecksum(data) {
+			t.Fatalf("frame accepted with invalid checksum: %x", data)
+		}
+		if len(frame.Payload) > DefaultMaxMessageSize {
+			t.Fatalf("frame accepted with oversized payload: %d bytes", len(frame.Payload))
+		}
+
+		// Re-encoding must reproduce the original bytes
+		encoded, err := frame.Marshal()
+		if err != nil {
+			t.Fatalf("failed to re-marshal decoded frame: %v", err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("round trip mismatch:\n got  %x\n want %x", encoded, data)
+		}
+	})
+}
+
+func FuzzFrameReaderReadFrame(f *testing.F) {
+	seeds := fuzzSeedFrames(f)
+	for _, seed := range seeds {
+		f.Add(seed, uint32(0))
+	}
+	// Two frames back to back and a small reader limit
+	f.Add(append(append([]byte{}, seeds[0]...), seeds[1]...), uint32(16))
+
+	f.Fuzz(func(t *testing.T, data []byte, maxMessageSize uint32) {
+		// Keep the limit bounded so the fuzzer exercises the size check instead of allocating
+		maxMessageSize %= DefaultMaxMessageSize + 1
+
+		reader := NewFrameReader(bytes.NewReader(data), maxMessageSize)
+		limit := maxMessageSize
+		if limit == 0 {
+			limit = DefaultMaxMessageSize
+		}
+
+		consumed := 0
+		for {
+			frame, err := reader.ReadFrame()
+			if err != nil {
+				if errors.Is(err, ErrMessageTooLarge) && consumed+FrameHeaderSize > len(data) {
+					t.Fatalf("size limit reported without a full header")
+				}
+				return
+			}
+
+			size := FrameHeaderSize + len(frame.Payload) + CRCSize
+			if uint32(len(frame.Payload)) > limit {
+				t.Fatalf("reader returned %d byte payload above limit %d", len(frame.Payload), limit)
+			}
+			if consumed+size > len(data) {
+				t.Fatalf("reader returned a frame larger than its input")
+			}
+			if !verifyChecksum(data[consumed : consumed+size]) {
+				t.Fatalf("reader accepted frame with invalid checksum")
+			}
+			consumed += size
+		}
+	})
+}
@@ -0,0 +1,425 @@
+// Package golden defines the wire-format fixtures: one encoded frame per
+// message shape, plus malformed frames a decoder must reject. The fixtures
+// are committed under api/fixtures so that client SDKs in other languages,
+// and later server versions, can check byte-level compatibility.
+package golden
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// ManifestFile indexes the fixtures in the output directory.
+const ManifestFile = "manifest.json"
+
+// fixtureTime is the timestamp every fixture uses, so the bytes never change.
+const fixtureTime = 1700000000000
+
+// Fixture is one encoded frame.
+type Fixture struct {
+	Name        string
+	Description string
+	Type        protocol.MessageType
+	Message     proto.Message
+
+	// Corrupt, if set, damages the encoded frame, which decoders must then
+	// reject with Err.
+	Corrupt func(frame []byte) []byte
+	Err     error
+}
+
+// File is the name of the fixture's frame in the output directory.
+func (f Fixture) File() string {
+	return f.Name + ".bin"
+}
+
+// Encode returns the frame bytes.
+func (f Fixture) Encode() ([]byte, error) {
+	frame, err := protocol.MarshalMessage(f.Type, f.Message)
+	if err != nil {
+		return nil, err
+	}
+	data, err := frame.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if f.Corrupt != nil {
+		data = f.Corrupt(data)
+	}
+	return data, nil
+}
+
+// Fixtures returns every fixture. Map fields hold at most one entry, since
+// protobuf does not order map entries on the wire.
+func Fixtures() []Fixture {
+	tick := func(symbol string, price float64) *pb.Tick {
+		return &pb.Tick{
+			Symbol:      symbol,
+			TimestampMs: fixtureTime,
+			Price:       price,
+			Volume:      1500,
+			Bid:         price - 0.01,
+			Ask:         price + 0.01,
+			BidSize:     300,
+			AskSize:     200,
+			Mode:        pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+		}
+	}
+	corruptCRC := func(frame []byte) []byte {
+		frame[len(frame)-1] ^= 0xFF
+		return frame
+	}
+
+	return []Fixture{
+		{
+			Name:        "auth_password",
+			Description: "AUTH with username and password",
+			Type:        protocol.MessageTypeAuth,
+			Message: &pb.AuthRequest{
+				Username:  "demo",
+				Password:  "secret",
+				ClientId:  "sdk-fixture",
+				Version:   "1.0.0",
+				RequestId: "req-1",
+			},
+		},
+		{
+			Name:        "auth_hmac",
+			Description: "AUTH answering an AUTH_CHALLENGE with an HMAC-SHA256 response",
+			Type:        protocol.MessageTypeAuth,
+			Message: &pb.AuthRequest{
+				Username:  "demo",
+				Mechanism: "hmac-sha256",
+				Response:  bytes.Repeat([]byte{0xAB}, 32),
+			},
+		},
+		{
+			Name:        "auth_challenge",
+			Description: "AUTH_CHALLENGE carrying a nonce",
+			Type:        protocol.MessageTypeAuthChallenge,
+			Message: &pb.AuthChallenge{
+				Mechanism:   "hmac-sha256",
+				Nonce:       bytes.Repeat([]byte{0x5A}, 16),
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "subscribe",
+			Description: "SUBSCRIBE to second ticks of two symbols with at-least-once delivery",
+			Type:        protocol.MessageTypeSubscribe,
+			Message: &pb.SubscribeRequest{
+				Mode:         pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				Symbols:      []string{"AAPL", "MSFT"},
+				StartTimeMs:  fixtureTime,
+				Metadata:     map[string]string{"client": "fixture"},
+				DeliveryMode: pb.DeliveryMode_DELIVERY_MODE_AT_LEAST_ONCE,
+				RequestId:    "req-2",
+			},
+		},
+		{
+			Name:        "subscription_update",
+			Description: "SUBSCRIPTION_UPDATE adding and removing symbols",
+			Type:        protocol.MessageTypeSubscriptionUpdate,
+			Message: &pb.SubscriptionUpdateRequest{
+				AddSymbols:    []string{"GOOG"},
+				RemoveSymbols: []string{"MSFT"},
+				TimestampMs:   fixtureTime,
+			},
+		},
+		{
+			Name:        "heartbeat",
+			Description: "HEARTBEAT reporting the last PONG",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message: &pb.HeartbeatRequest{
+				TimestampMs:           fixtureTime,
+				Sequence:              7,
+				PongServerTimestampMs: fixtureTime - 15000,
+				PongReceivedMs:        fixtureTime - 14990,
+			},
+		},
+		{
+			Name:        "heartbeat_empty",
+			Description: "HEARTBEAT with every field at its default, so an empty payload",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message:     &pb.HeartbeatRequest{},
+		},
+		{
+			Name:        "pong",
+			Description: "PONG echoing a heartbeat",
+			Type:        protocol.MessageTypePong,
+			Message: &pb.HeartbeatResponse{
+				ClientTimestampMs: fixtureTime,
+				ServerTimestampMs: fixtureTime + 3,
+				Sequence:          7,
+			},
+		},
+		{
+			Name:        "data_batch",
+			Description: "DATA_BATCH of two ticks",
+			Type:        protocol.MessageTypeDataBatch,
+			Message: &pb.DataBatch{
+				Ticks:            []*pb.Tick{tick("AAPL", 189.25), tick("MSFT", 402.5)},
+				BatchTimestampMs: fixtureTime,
+				BatchSequence:    42,
+				PublishSequence:  1042,
+			},
+		},
+		{
+			Name:        "data_batch_snapshot",
+			Description: "DATA_BATCH snapshot whose tick carries metadata",
+			Type:        protocol.MessageTypeDataBatch,
+			Message: &pb.DataBatch{
+				Ticks: []*pb.Tick{func() *pb.Tick {
+					t := tick("AAPL", 189.25)
+					t.Metadata = map[string]string{"venue": "XNAS"}
+					return t
+				}()},
+				BatchTimestampMs: fixtureTime,
+				BatchSequence:    1,
+				IsSnapshot:       true,
+			},
+		},
+		{
+			Name:        "batch_ack",
+			Description: "BATCH_ACK of the highest contiguous batch",
+			Type:        protocol.MessageTypeBatchAck,
+			Message:     &pb.BatchAck{BatchSequence: 42, TimestampMs: fixtureTime},
+		},
+		{
+			Name:        "gap_fill",
+			Description: "GAP_FILL after the last batch received",
+			Type:        protocol.MessageTypeGapFill,
+			Message:     &pb.GapFillRequest{LastSequence: 40, TimestampMs: fixtureTime},
+		},
+		{
+			Name:        "resume",
+			Description: "RESUME of a parked subscription",
+			Type:        protocol.MessageTypeResume,
+			Message: &pb.ResumeRequest{
+				Token:        "3f9c2a7e5b1d4c68",
+				LastSequence: 42,
+				TimestampMs:  fixtureTime,
+			},
+		},
+		{
+			Name:        "symbol_list_request",
+			Description: "SYMBOL_LIST request filtered by prefix and mode",
+			Type:        protocol.MessageTypeSymbolList,
+			Message: &pb.SymbolListRequest{
+				Prefix:      "A",
+				Modes:       []pb.SubscriptionMode{pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND},
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "symbol_list_response",
+			Description: "SYMBOL_LIST response describing one symbol",
+			Type:        protocol.MessageTypeSymbolList,
+			Message: &pb.SymbolListResponse{
+				Symbols: []*pb.SymbolInfo{{
+					Symbol:      "AAPL",
+					Description: "Apple Inc.",
+					TickSize:    0.01,
+					Modes: []pb.SubscriptionMode{
+						pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+						pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+					},
+					Currency: "USD",
+				}},
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "publish",
+			Description: "PUBLISH of one tick by a producer",
+			Type:        protocol.MessageTypePublish,
+			Message: &pb.PublishRequest{
+				Ticks:       []*pb.Tick{tick("AAPL", 189.26)},
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "ack",
+			Description: "ACK of a subscription carrying its resume token",
+			Type:        protocol.MessageTypeACK,
+			Message: &pb.AckResponse{
+				AckType:       pb.MessageType_MESSAGE_TYPE_SUBSCRIBE,
+				Success:       true,
+				Message:       "subscribed",
+				TimestampMs:   fixtureTime,
+				Metadata:      map[string]string{"resume_token": "3f9c2a7e5b1d4c68"},
+				CorrelationId: "req-2",
+			},
+		},
+		{
+			Name:        "error",
+			Description: "ERROR asking the client to retry later",
+			Type:        protocol.MessageTypeError,
+			Message: &pb.ErrorResponse{
+				Code:          pb.ErrorCode_ERROR_CODE_RATE_LIMITED,
+				Message:       "rate limited",
+				Details:       "too many subscriptions",
+				TimestampMs:   fixtureTime,
+				RetryAfterMs:  1000,
+				Retryable:     true,
+				CorrelationId: "req-2",
+			},
+		},
+		{
+			Name:        "info",
+			Description: "INFO warning about clock skew",
+			Type:        protocol.MessageTypeInfo,
+			Message: &pb.InfoMessage{
+				Code:        pb.InfoCode_INFO_CODE_CLOCK_SKEW,
+				Message:     "client clock is ahead of the server",
+				Metadata:    map[string]string{"skew_ms": "2500"},
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "stream_status",
+			Description: "STREAM_STATUS reporting a trading halt",
+			Type:        protocol.MessageTypeStreamStatus,
+			Message: &pb.StreamStatus{
+				Symbol:      "EURUSD",
+				State:       pb.StreamState_STREAM_STATE_HALTED,
+				Reason:      "volatility halt",
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "history_request",
+			Description: "HISTORY asking for the last 5 minutes of EURUSD",
+			Type:        protocol.MessageTypeHistory,
+			Message: &pb.HistoryRequest{
+				Symbols:     []string{"EURUSD"},
+				Minutes:     5,
+				Mode:        pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				RequestId:   "req-history",
+				TimestampMs: fixtureTime,
+			},
+		},
+		{
+			Name:        "keepalive",
+			Description: "KEEPALIVE sent by the server on an idle connection, with an empty payload",
+			Type:        protocol.MessageTypeKeepalive,
+			Message:     &pb.Keepalive{},
+		},
+		{
+			Name:        "invalid_checksum",
+			Description: "HEARTBEAT whose CRC32C has been flipped",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message:     &pb.HeartbeatRequest{TimestampMs: fixtureTime, Sequence: 1},
+			Corrupt:     corruptCRC,
+			Err:         protocol.ErrInvalidChecksum,
+		},
+		{
+			Name:        "invalid_magic",
+			Description: "HEARTBEAT with wrong magic bytes",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message:     &pb.HeartbeatRequest{TimestampMs: fixtureTime, Sequence: 1},
+			Corrupt: func(frame []byte) []byte {
+				frame[0], frame[1] = 'G', 'E'
+				return frame
+			},
+			Err: protocol.ErrInvalidMagic,
+		},
+		{
+			Name:        "unsupported_version",
+			Description: "HEARTBEAT with an unsupported protocol version and a valid CRC32C",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message:     &pb.HeartbeatRequest{TimestampMs: fixtureTime, Sequence: 1},
+			Corrupt: func(frame []byte) []byte {
+				frame[2] = 0x7F
+				return resign(frame)
+			},
+			Err: protocol.ErrUnsupportedVersion,
+		},
+		{
+			Name:        "truncated",
+			Description: "HEARTBEAT missing the last byte of its CRC32C",
+			Type:        protocol.MessageTypeHeartbeat,
+			Message:     &pb.HeartbeatRequest{TimestampMs: fixtureTime, Sequence: 1},
+			Corrupt: func(frame []byte) []byte {
+				return frame[:len(frame)-1]
+			},
+			Err: protocol.ErrIncompleteFrame,
+		},
+	}
+}
+
+// resign recomputes the CRC32C after a header change.
+func resign(frame []byte) []byte {
+	end := len(frame) - protocol.CRCSize
+	sum := crc32.Checksum(frame[:end], crc32.MakeTable(crc32.Castagnoli))
+	frame[end], frame[end+1], frame[end+2], frame[end+3] = byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum)
+	return frame
+}
+
+// manifestEntry describes one fixture for implementations in any language.
+type manifestEntry struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	File        string          `json:"file"`
+	Type        uint8           `json:"type"`
+	TypeName    string          `json:"type_name"`
+	Message     string          `json:"message"`
+	Fields      json.RawMessage `json:"fields"`
+	Size        int             `json:"size"`
+	Hex         string          `json:"hex"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Files returns the output directory's contents: each fixture's frame and
+// the manifest, which gives the message each frame carries as proto3 JSON.
+func Files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var manifest []manifestEntry
+	for _, f := range Fixtures() {
+		data, err := f.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		fields, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(f.Message)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		// protojson varies its whitespace between runs
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, fields); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		entry := manifestEntry{
+			Name:        f.Name,
+			Description: f.Description,
+			File:        f.File(),
+			Type:        uint8(f.Type),
+			TypeName:    protocol.ConvertToProtobufMessageType(f.Type).String(),
+			Message:     string(f.Message.ProtoReflect().Descriptor().FullName()),
+			Fields:      compact.Bytes(),
+			Size:        len(data),
+			Hex:         hex.EncodeToString(data),
+		}
+		if f.Err != nil {
+			entry.Error = f.Err.Error()
+		}
+		files[f.File()] = data
+		manifest = append(manifest, entry)
+	}
+
+	out, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[ManifestFile] = append(out, '\n')
+	return files, nil
+}
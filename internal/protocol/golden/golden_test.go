@@ -0,0 +1,66 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+const fixtureDir = "../../../api/fixtures"
+
+// TestCommittedFixturesUpToDate fails when the encoder, the schema or the
+// fixtures change without the committed files being regenerated.
+func TestCommittedFixturesUpToDate(t *testing.T) {
+	files, err := Files()
+	require.NoError(t, err)
+	for name, want := range files {
+		committed, err := os.ReadFile(filepath.Join(fixtureDir, name))
+		require.NoError(t, err, "%s is missing; run make wire-fixtures", name)
+		assert.Equal(t, want, committed, "%s is stale; run make wire-fixtures", name)
+	}
+}
+
+// TestCommittedFixturesDecode decodes the committed bytes, not freshly
+// encoded ones, so a decoder change that breaks old frames is caught.
+func TestCommittedFixturesDecode(t *testing.T) {
+	for _, f := range Fixtures() {
+		t.Run(f.Name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(fixtureDir, f.File()))
+			require.NoError(t, err)
+
+			var frame protocol.Frame
+			err = frame.Unmarshal(data)
+			if f.Err != nil {
+				assert.ErrorIs(t, err, f.Err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, f.Type, frame.Type)
+
+			msg := f.Message.ProtoReflect().New().Interface()
+			require.NoError(t, protocol.UnmarshalMessage(&frame, msg))
+			assert.True(t, proto.Equal(f.Message, msg), "decoded %v, want %v", msg, f.Message)
+		})
+	}
+}
+
+func TestFixturesCoverEveryMessageType(t *testing.T) {
+	covered := make(map[protocol.MessageType]bool)
+	names := make(map[string]bool)
+	for _, f := range Fixtures() {
+		assert.False(t, names[f.Name], "duplicate fixture %s", f.Name)
+		names[f.Name] = true
+		if f.Err == nil {
+			covered[f.Type] = true
+		}
+	}
+	for t2 := protocol.MessageTypeAuth; t2 <= protocol.MessageTypeKeepalive; t2++ {
+		assert.True(t, covered[t2], "no valid fixture for message type 0x%02X", uint8(t2))
+	}
+}
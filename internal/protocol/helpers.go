@@ -22,6 +22,34 @@ func ConvertPBMessageType(pbType pb.MessageType) MessageType {
 		return MessageTypeACK
 	case pb.MessageType_MESSAGE_TYPE_PONG:
 		return MessageTypePong
+	case pb.MessageType_MESSAGE_TYPE_BATCH_ACK:
+		return MessageTypeBatchAck
+	case pb.MessageType_MESSAGE_TYPE_GAP_FILL:
+		return MessageTypeGapFill
+	case pb.MessageType_MESSAGE_TYPE_AUTH_CHALLENGE:
+		return MessageTypeAuthChallenge
+	case pb.MessageType_MESSAGE_TYPE_INFO:
+		return MessageTypeInfo
+	case pb.MessageType_MESSAGE_TYPE_RESUME:
+		return MessageTypeResume
+	case pb.MessageType_MESSAGE_TYPE_SYMBOL_LIST:
+		return MessageTypeSymbolList
+	case pb.MessageType_MESSAGE_TYPE_SUBSCRIPTION_UPDATE:
+		return MessageTypeSubscriptionUpdate
+	case pb.MessageType_MESSAGE_TYPE_PUBLISH:
+		return MessageTypePublish
+	case pb.MessageType_MESSAGE_TYPE_STREAM_STATUS:
+		return MessageTypeStreamStatus
+	case pb.MessageType_MESSAGE_TYPE_HISTORY:
+		return MessageTypeHistory
+	case pb.MessageType_MESSAGE_TYPE_KEEPALIVE:
+		return MessageTypeKeepalive
+	case pb.MessageType_MESSAGE_TYPE_REPLICA_SYNC:
+		return MessageTypeReplicaSync
+	case pb.MessageType_MESSAGE_TYPE_REPLICA_BATCH:
+		return MessageTypeReplicaBatch
+	case pb.MessageType_MESSAGE_TYPE_REPLICA_STATE:
+		return MessageTypeReplicaState
 	default:
 		return 0
 	}
@@ -44,7 +72,54 @@ func ConvertToProtobufMessageType(msgType MessageType) pb.MessageType {
 		return pb.MessageType_MESSAGE_TYPE_ACK
 	case MessageTypePong:
 		return pb.MessageType_MESSAGE_TYPE_PONG
+	case MessageTypeBatchAck:
+		return pb.MessageType_MESSAGE_TYPE_BATCH_ACK
+	case MessageTypeGapFill:
+		return pb.MessageType_MESSAGE_TYPE_GAP_FILL
+	case MessageTypeAuthChallenge:
+		return pb.MessageType_MESSAGE_TYPE_AUTH_CHALLENGE
+	case MessageTypeInfo:
+		return pb.MessageType_MESSAGE_TYPE_INFO
+	case MessageTypeResume:
+		return pb.MessageType_MESSAGE_TYPE_RESUME
+	case MessageTypeSymbolList:
+		return pb.MessageType_MESSAGE_TYPE_SYMBOL_LIST
+	case MessageTypeSubscriptionUpdate:
+		return pb.MessageType_MESSAGE_TYPE_SUBSCRIPTION_UPDATE
+	case MessageTypePublish:
+		return pb.MessageType_MESSAGE_TYPE_PUBLISH
+	case MessageTypeStreamStatus:
+		return pb.MessageType_MESSAGE_TYPE_STREAM_STATUS
+	case MessageTypeHistory:
+		return pb.MessageType_MESSAGE_TYPE_HISTORY
+	case MessageTypeKeepalive:
+		return pb.MessageType_MESSAGE_TYPE_KEEPALIVE
+	case MessageTypeReplicaSync:
+		return pb.MessageType_MESSAGE_TYPE_REPLICA_SYNC
+	case MessageTypeReplicaBatch:
+		return pb.MessageType_MESSAGE_TYPE_REPLICA_BATCH
+	case MessageTypeReplicaState:
+		return pb.MessageType_MESSAGE_TYPE_REPLICA_STATE
 	default:
 		return pb.MessageType_MESSAGE_TYPE_UNSPECIFIED
 	}
 }
+
+// IsRetryableError reports whether a request failing with code may succeed
+// when retried unchanged, possibly on a new connection after the error's
+// retry_after_ms. Errors caused by the request itself are not retryable.
+func IsRetryableError(code pb.ErrorCode) bool {
+	switch code {
+	case pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT,
+		pb.ErrorCode_ERROR_CODE_CHECKSUM_FAILED,
+		pb.ErrorCode_ERROR_CODE_RATE_LIMITED,
+		pb.ErrorCode_ERROR_CODE_INTERNAL_ERROR,
+		pb.ErrorCode_ERROR_CODE_SERVER_BUSY,
+		pb.ErrorCode_ERROR_CODE_SLOW_CONSUMER,
+		pb.ErrorCode_ERROR_CODE_QUOTA_EXCEEDED,
+		pb.ErrorCode_ERROR_CODE_SERVER_DRAINING:
+		return true
+	default:
+		return false
+	}
+}
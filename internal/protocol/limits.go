@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds on configurable limits, keeping validation meaningful and the
+// per-message work bounded.
+const (
+	maxLimitSymbolsCount  = 10000
+	maxLimitTicksPerBatch = 100000
+	maxLimitTimestampAge  = 30 * 24 * time.Hour
+	maxLimitFutureSkew    = time.Hour
+)
+
+// DefaultMaxTimestampSkew is how far in the future a timestamp may be.
+const DefaultMaxTimestampSkew = 5 * time.Minute
+
+// Limits are the validation bounds that vary by venue: crypto and FX differ
+// in price and volume ranges and in batch sizes.
+type Limits struct {
+	MaxSymbolsCount  int     // Symbols per subscribe or update request
+	MaxTicksPerBatch int     // Ticks per published or delivered batch
+	MinPrice         float64 // Inclusive price, bid and ask range
+	MaxPrice         float64
+	MinVolume        float64 // Inclusive volume range
+	MaxVolume        float64
+	MaxTimestampAge  time.Duration // How old a timestamp may be
+	MaxTimestampSkew time.Duration // How far in the future a timestamp may be
+}
+
+// DefaultLimits returns the built-in limits.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxSymbolsCount:  MaxSymbolsCount,
+		MaxTicksPerBatch: MaxTicksPerBatch,
+		MinPrice:         MinPrice,
+		MaxPrice:         MaxPrice,
+		MinVolume:        MinVolume,
+		MaxVolume:        MaxVolume,
+		MaxTimestampAge:  MaxTimestampAge,
+		MaxTimestampSkew: DefaultMaxTimestampSkew,
+	}
+}
+
+// Validate reports the first limit outside its sane bounds.
+func (l Limits) Validate() error {
+	if l.MaxSymbolsCount < 1 || l.MaxSymbolsCount > maxLimitSymbolsCount {
+		return fmt.Errorf("max symbols count must be between 1 and %d", maxLimitSymbolsCount)
+	}
+	if l.MaxTicksPerBatch < 1 || l.MaxTicksPerBatch > maxLimitTicksPerBatch {
+		return fmt.Errorf("max ticks per batch must be between 1 and %d", maxLimitTicksPerBatch)
+	}
+	if l.MinPrice <= 0 || l.MaxPrice <= l.MinPrice {
+		return fmt.Errorf("price range must be positive with min below max")
+	}
+	if l.MinVolume < 0 || l.MaxVolume <= l.MinVolume {
+		return fmt.Errorf("volume range must be non-negative with min below max")
+	}
+	if l.MaxTimestampAge < time.Minute || l.MaxTimestampAge > maxLimitTimestampAge {
+		return fmt.Errorf("max timestamp age must be between 1m and %s", maxLimitTimestampAge)
+	}
+	if l.MaxTimestampSkew < 0 || l.MaxTimestampSkew > maxLimitFutureSkew {
+		return fmt.Errorf("max timestamp skew must be between 0 and %s", maxLimitFutureSkew)
+	}
+	return nil
+}
+
+var activeLimits atomic.Pointer[Limits]
+
+func init() {
+	defaults := DefaultLimits()
+	activeLimits.Store(&defaults)
+}
+
+// CurrentLimits returns the limits validation applies.
+func CurrentLimits() Limits {
+	return *activeLimits.Load()
+}
+
+// SetLimits replaces the limits validation applies, process-wide.
+func SetLimits(l Limits) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	activeLimits.Store(&l)
+	return nil
+}
@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestLimitsValidate(t *testing.T) {
+	assert.NoError(t, DefaultLimits().Validate())
+
+	for name, mutate := range map[string]func(*Limits){
+		"no symbols":      func(l *Limits) { l.MaxSymbolsCount = 0 },
+		"huge batches":    func(l *Limits) { l.MaxTicksPerBatch = maxLimitTicksPerBatch + 1 },
+		"zero min price":  func(l *Limits) { l.MinPrice = 0 },
+		"inverted prices": func(l *Limits) { l.MaxPrice = l.MinPrice },
+		"negative volume": func(l *Limits) { l.MinVolume = -1 },
+		"short age":       func(l *Limits) { l.MaxTimestampAge = time.Second },
+		"large skew":      func(l *Limits) { l.MaxTimestampSkew = 2 * time.Hour },
+	} {
+		limits := DefaultLimits()
+		mutate(&limits)
+		assert.Error(t, limits.Validate(), name)
+		assert.Error(t, SetLimits(limits), name)
+	}
+	assert.Equal(t, DefaultLimits(), CurrentLimits(), "rejected limits are not applied")
+}
+
+func TestSetLimits(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetLimits(DefaultLimits())) })
+
+	btc := &pb.Tick{Symbol: "BTCUSD", Price: 2500000, Volume: 0.5, TimestampMs: time.Now().UnixMilli(), Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	assert.ErrorIs(t, ValidateTick(btc), ErrInvalidRange)
+
+	crypto := DefaultLimits()
+	crypto.MinPrice = 1e-8
+	crypto.MaxPrice = 1e8
+	crypto.MaxTicksPerBatch = 2
+	crypto.MaxTimestampSkew = 0
+	require.NoError(t, SetLimits(crypto))
+	assert.NoError(t, ValidateTick(btc))
+
+	assert.ErrorIs(t, ValidatePublishRequest(&pb.PublishRequest{Ticks: []*pb.Tick{btc, btc, btc}}), ErrTooManyEntries)
+	future := &pb.Tick{Symbol: "BTCUSD", Price: 1, TimestampMs: time.Now().Add(time.Minute).UnixMilli(), Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	assert.ErrorIs(t, ValidateTick(future), ErrInvalidTimestamp)
+}
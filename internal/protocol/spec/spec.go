@@ -0,0 +1,261 @@
+// Package spec describes the wire protocol in machine-readable form and
+// packages it with the .proto schema and the golden fixtures into a
+// versioned bundle, from which client SDKs in other languages are generated
+// and tested.
+package spec
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/api"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/golden"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// DescriptorFile is the descriptor's name inside the bundle.
+const DescriptorFile = "descriptor.json"
+
+// Descriptor is the framing specification.
+type Descriptor struct {
+	ProtocolVersion     uint8         `json:"protocol_version"`
+	MinSupportedVersion uint8         `json:"min_supported_version"`
+	MaxSupportedVersion uint8         `json:"max_supported_version"`
+	ProtoPackage        string        `json:"proto_package"`
+	ProtoFiles          []string      `json:"proto_files"`
+	SchemaDigest        string        `json:"schema_digest"` // sha256 over the .proto files in order
+	Framing             Framing       `json:"framing"`
+	MessageTypes        []MessageType `json:"message_types"`
+}
+
+// Framing is the frame layout: a fixed header, the protobuf payload and a
+// checksum trailer.
+type Framing struct {
+	ByteOrder      string   `json:"byte_order"`
+	Magic          string   `json:"magic"` // Hex
+	HeaderSize     int      `json:"header_size"`
+	Header         []Field  `json:"header"`
+	TrailerSize    int      `json:"trailer_size"`
+	Checksum       Checksum `json:"checksum"`
+	MaxPayloadSize int      `json:"max_payload_size"`
+}
+
+// Field is one header field.
+type Field struct {
+	Name        string `json:"name"`
+	Offset      int    `json:"offset"`
+	Size        int    `json:"size"`
+	Description string `json:"description"`
+}
+
+// Checksum describes the trailer.
+type Checksum struct {
+	Algorithm  string `json:"algorithm"`
+	Polynomial string `json:"polynomial"` // Reversed representation
+	Covers     string `json:"covers"`
+}
+
+// MessageType maps a frame type byte to the protobuf messages its payload
+// may hold.
+type MessageType struct {
+	Value    uint8    `json:"value"`
+	Name     string   `json:"name"`
+	Messages []string `json:"messages"`
+}
+
+// payloads lists the payload messages of each frame type. SYMBOL_LIST
+// carries the request one way and the response the other.
+var payloads = map[protocol.MessageType][]proto.Message{
+	protocol.MessageTypeAuth:               {&pb.AuthRequest{}},
+	protocol.MessageTypeSubscribe:          {&pb.SubscribeRequest{}},
+	protocol.MessageTypeHeartbeat:          {&pb.HeartbeatRequest{}},
+	protocol.MessageTypeDataBatch:          {&pb.DataBatch{}},
+	protocol.MessageTypeError:              {&pb.ErrorResponse{}},
+	protocol.MessageTypeACK:                {&pb.AckResponse{}},
+	protocol.MessageTypePong:               {&pb.HeartbeatResponse{}},
+	protocol.MessageTypeBatchAck:           {&pb.BatchAck{}},
+	protocol.MessageTypeGapFill:            {&pb.GapFillRequest{}},
+	protocol.MessageTypeAuthChallenge:      {&pb.AuthChallenge{}},
+	protocol.MessageTypeInfo:               {&pb.InfoMessage{}},
+	protocol.MessageTypeResume:             {&pb.ResumeRequest{}},
+	protocol.MessageTypeSymbolList:         {&pb.SymbolListRequest{}, &pb.SymbolListResponse{}},
+	protocol.MessageTypeSubscriptionUpdate: {&pb.SubscriptionUpdateRequest{}},
+	protocol.MessageTypePublish:            {&pb.PublishRequest{}},
+	protocol.MessageTypeStreamStatus:       {&pb.StreamStatus{}},
+	protocol.MessageTypeHistory:            {&pb.HistoryRequest{}},
+	protocol.MessageTypeKeepalive:          {&pb.Keepalive{}},
+}
+
+// Describe returns the descriptor of the protocol this build speaks.
+func Describe() (*Descriptor, error) {
+	files, err := protoFiles()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.New()
+	d := &Descriptor{
+		ProtocolVersion:     protocol.CurrentProtocolVersion,
+		MinSupportedVersion: protocol.MinSupportedVersion,
+		MaxSupportedVersion: protocol.MaxSupportedVersion,
+		ProtoPackage:        string(pb.File_protocol_proto.Package()),
+		Framing: Framing{
+			ByteOrder:  "big-endian",
+			Magic:      hex.EncodeToString(protocol.MagicBytes[:]),
+			HeaderSize: protocol.FrameHeaderSize,
+			Header: []Field{
+				{"magic", 0, 2, "fixed bytes identifying a frame"},
+				{"version", 2, 1, "protocol version of the frame"},
+				{"type", 3, 1, "message type; see message_types"},
+				{"length", 4, 4, "payload length in bytes, at most max_payload_size"},
+			},
+			TrailerSize: protocol.CRCSize,
+			Checksum: Checksum{
+				Algorithm:  "crc32c",
+				Polynomial: "0x82f63b78",
+				Covers:     "header and payload",
+			},
+			MaxPayloadSize: protocol.DefaultMaxMessageSize,
+		},
+	}
+	for _, f := range files {
+		d.ProtoFiles = append(d.ProtoFiles, f.name)
+		digest.Write(f.data)
+	}
+	d.SchemaDigest = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+
+	values := pb.MessageType(0).Descriptor().Values()
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		msgs, ok := payloads[protocol.MessageType(v.Number())]
+		if !ok {
+			continue
+		}
+		mt := MessageType{Value: uint8(v.Number()), Name: string(v.Name())}
+		for _, m := range msgs {
+			mt.Messages = append(mt.Messages, string(m.ProtoReflect().Descriptor().FullName()))
+		}
+		d.MessageTypes = append(d.MessageTypes, mt)
+	}
+	return d, nil
+}
+
+// BundleName returns the bundle's file name, versioned by protocol version.
+func BundleName() string {
+	return fmt.Sprintf("tickstorm-protocol-v%d.tar.gz", protocol.CurrentProtocolVersion)
+}
+
+var (
+	bundleOnce sync.Once
+	bundle     []byte
+	bundleErr  error
+)
+
+// Bundle returns the gzipped tar holding the descriptor, the .proto files
+// under proto/ and the golden fixtures under fixtures/, all in one top-level
+// directory. The bytes depend only on their contents, so equal bundles
+// have equal digests.
+func Bundle() ([]byte, error) {
+	bundleOnce.Do(func() { bundle, bundleErr = buildBundle() })
+	return bundle, bundleErr
+}
+
+func buildBundle() ([]byte, error) {
+	d, err := Describe()
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string][]byte{DescriptorFile: append(descriptor, '\n')}
+
+	files, err := protoFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		entries[path.Join("proto", f.name)] = f.data
+	}
+	fixtures, err := golden.Files()
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range fixtures {
+		entries[path.Join("fixtures", name)] = data
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	root := fmt.Sprintf("tickstorm-protocol-v%d", protocol.CurrentProtocolVersion)
+	for _, name := range names {
+		hdr := &tar.Header{
+			Name:    path.Join(root, name),
+			Mode:    0o644,
+			Size:    int64(len(entries[name])),
+			ModTime: time.Unix(0, 0),
+			Format:  tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(entries[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type protoFile struct {
+	name string
+	data []byte
+}
+
+// protoFiles reads the embedded .proto files in name order.
+func protoFiles() ([]protoFile, error) {
+	matches, err := fs.Glob(api.Proto, "proto/*.proto")
+	if err != nil {
+		return nil, err
+	}
+	var files []protoFile
+	for _, m := range matches {
+		data, err := fs.ReadFile(api.Proto, m)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, protoFile{name: path.Base(m), data: data})
+	}
+	return files, nil
+}
+
+// Digest returns the bundle's sha256 in hex, for ETags and checksums files.
+func Digest(bundle []byte) string {
+	sum := sha256.Sum256(bundle)
+	return hex.EncodeToString(sum[:])
+}
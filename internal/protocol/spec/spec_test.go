@@ -0,0 +1,91 @@
+package spec
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/golden"
+)
+
+func TestDescribeMatchesFraming(t *testing.T) {
+	d, err := Describe()
+	require.NoError(t, err)
+
+	frame, err := golden.Fixtures()[0].Encode()
+	require.NoError(t, err)
+	f := d.Framing
+	assert.Equal(t, "f57d", f.Magic)
+
+	// The header fields tile the header and locate the fixture's bytes
+	end := 0
+	for _, field := range f.Header {
+		assert.Equal(t, end, field.Offset, field.Name)
+		end += field.Size
+	}
+	assert.Equal(t, f.HeaderSize, end)
+	assert.Equal(t, len(frame), f.HeaderSize+int(binary.BigEndian.Uint32(frame[4:8]))+f.TrailerSize)
+
+	covered := make(map[uint8]bool)
+	for _, mt := range d.MessageTypes {
+		assert.NotEmpty(t, mt.Messages, mt.Name)
+		for _, m := range mt.Messages {
+			assert.True(t, strings.HasPrefix(m, d.ProtoPackage+"."), m)
+		}
+		covered[mt.Value] = true
+	}
+	for mt := protocol.MessageTypeAuth; mt <= protocol.MessageTypeKeepalive; mt++ {
+		assert.True(t, covered[uint8(mt)], "message type 0x%02X is not described", uint8(mt))
+	}
+}
+
+func TestBundleContents(t *testing.T) {
+	bundle, err := Bundle()
+	require.NoError(t, err)
+	again, err := buildBundle()
+	require.NoError(t, err)
+	assert.Equal(t, bundle, again, "the bundle must be reproducible")
+
+	zr, err := gzip.NewReader(bytes.NewReader(bundle))
+	require.NoError(t, err)
+	tr := tar.NewReader(zr)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = data
+	}
+
+	root := "tickstorm-protocol-v1/"
+	proto, err := os.ReadFile("../../../api/proto/protocol.proto")
+	require.NoError(t, err)
+	assert.Equal(t, proto, files[root+"proto/protocol.proto"])
+
+	var d Descriptor
+	require.NoError(t, json.Unmarshal(files[root+DescriptorFile], &d))
+	want, err := Describe()
+	require.NoError(t, err)
+	assert.Equal(t, *want, d)
+
+	fixtures, err := golden.Files()
+	require.NoError(t, err)
+	for name, data := range fixtures {
+		assert.Equal(t, data, files[root+"fixtures/"+name], name)
+	}
+	assert.Len(t, files, 2+len(fixtures))
+}
@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"sync"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// DefaultSymbolTableSize bounds the shared symbol table.
+const DefaultSymbolTableSize = 65536
+
+// SymbolTable interns symbol strings so every tick and subscription naming
+// the same instrument shares one copy instead of the one each decode
+// allocates. Only well-formed symbols are interned, and the table stops
+// growing at its size limit, so unexpected feeds cannot grow it without
+// bound.
+type SymbolTable struct {
+	mu      sync.RWMutex
+	symbols map[string]string
+	max     int
+}
+
+// NewSymbolTable creates a table holding at most max symbols, seeded with
+// known so the common instruments never take the write lock.
+func NewSymbolTable(max int, known ...string) *SymbolTable {
+	t := &SymbolTable{symbols: make(map[string]string, len(known)), max: max}
+	for _, symbol := range known {
+		t.Intern(symbol)
+	}
+	return t
+}
+
+// Intern returns the table's copy of symbol, adding it when there is room.
+func (t *SymbolTable) Intern(symbol string) string {
+	t.mu.RLock()
+	interned, ok := t.symbols[symbol]
+	t.mu.RUnlock()
+	if ok {
+		return interned
+	}
+	if !IsValidSymbol(symbol) {
+		return symbol
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if interned, ok := t.symbols[symbol]; ok {
+		return interned
+	}
+	if len(t.symbols) >= t.max {
+		return symbol
+	}
+	t.symbols[symbol] = symbol
+	return symbol
+}
+
+// InternTicks replaces each tick's symbol with the interned copy.
+func (t *SymbolTable) InternTicks(ticks []*pb.Tick) {
+	for _, tick := range ticks {
+		tick.Symbol = t.Intern(tick.Symbol)
+	}
+}
+
+// Len returns the number of interned symbols.
+func (t *SymbolTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.symbols)
+}
+
+// Symbols is the table shared by the decode paths.
+var Symbols = NewSymbolTable(DefaultSymbolTableSize)
@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestIsValidSymbol(t *testing.T) {
+	for _, symbol := range []string{"EURUSD", "BRK.B", "ES_F", "BTC-USD", "X", strings.Repeat("A", MaxSymbolLength)} {
+		assert.True(t, IsValidSymbol(symbol), symbol)
+	}
+	for _, symbol := range []string{"", "eurusd", "EUR USD", "EUR/USD", "ÉUR", strings.Repeat("A", MaxSymbolLength+1)} {
+		assert.False(t, IsValidSymbol(symbol), symbol)
+	}
+}
+
+func TestSymbolTableInterns(t *testing.T) {
+	table := NewSymbolTable(2, "EURUSD")
+	assert.Equal(t, 1, table.Len())
+
+	// Distinct allocations of the same symbol come back as one copy
+	a := table.Intern(string([]byte("GBPUSD")))
+	b := table.Intern(string([]byte("GBPUSD")))
+	assert.Equal(t, unsafe.StringData(a), unsafe.StringData(b))
+
+	ticks := []*pb.Tick{{Symbol: string([]byte("EURUSD"))}, {Symbol: string([]byte("EURUSD"))}}
+	table.InternTicks(ticks)
+	assert.Equal(t, unsafe.StringData(ticks[0].Symbol), unsafe.StringData(ticks[1].Symbol))
+
+	// Full tables and malformed symbols pass strings through untouched
+	assert.Equal(t, "USDJPY", table.Intern("USDJPY"))
+	assert.Equal(t, "bad symbol", table.Intern("bad symbol"))
+	assert.Equal(t, 2, table.Len())
+}
+
+func BenchmarkSymbolTableIntern(b *testing.B) {
+	table := NewSymbolTable(DefaultSymbolTableSize, "EURUSD")
+	raw := []byte("EURUSD")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = table.Intern(string(raw))
+	}
+}
@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+)
+
+// MetadataTraceparent is the AUTH and SUBSCRIBE metadata key carrying a W3C
+// trace context, so the server's logs and exemplars join the client's trace.
+const MetadataTraceparent = "traceparent"
+
+// ErrInvalidTraceparent is returned for a traceparent that does not follow
+// the W3C Trace Context format.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceContext is a parsed W3C traceparent.
+type TraceContext struct {
+	TraceID  string // 32 lowercase hex digits
+	ParentID string // 16 lowercase hex digits; the client's span
+	Sampled  bool
+}
+
+// ParseTraceparent parses a W3C traceparent header value:
+// version-traceid-parentid-flags. Versions after 00 may append fields, which
+// are ignored; version ff and all-zero IDs are invalid.
+func ParseTraceparent(s string) (TraceContext, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || !lowerHex(parts[0], 2) || !lowerHex(parts[1], 32) ||
+		!lowerHex(parts[2], 16) || !lowerHex(parts[3], 2) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	flags := fromHex(parts[3][0])<<4 | fromHex(parts[3][1])
+	return TraceContext{TraceID: parts[1], ParentID: parts[2], Sampled: flags&1 == 1}, nil
+}
+
+// lowerHex reports whether s is n lowercase hex digits.
+func lowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func fromHex(c byte) byte {
+	if c >= 'a' {
+		return c - 'a' + 10
+	}
+	return c - '0'
+}
@@ -10,11 +10,14 @@ import (
 	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 )
 
-// Validation constants
+// Validation constants. The symbol count, batch size, price, volume and
+// timestamp age constants are defaults; see Limits.
 const (
 	MaxUsernameLength    = 64
 	MaxPasswordLength    = 128
 	MaxClientIDLength    = 64
+	MaxResumeTokenLength = 64
+	MaxRequestIDLength   = 64
 	MaxVersionLength     = 32
 	MaxSymbolLength      = 16
 	MaxSymbolsCount      = 100
@@ -24,6 +27,7 @@ const (
 	MaxMessageLength     = 512
 	MaxDetailsLength     = 1024
 	MaxTicksPerBatch     = 1000
+	MaxHistoryMinutes    = 24 * 60
 	MinPrice             = 0.0001
 	MaxPrice             = 1000000.0
 	MinVolume            = 0.0
@@ -31,6 +35,13 @@ const (
 	MaxTimestampAge      = 24 * time.Hour // Max age for timestamps
 )
 
+// Challenge-response authentication
+const (
+	AuthMechanismHMACSHA256 = "hmac-sha256"
+	AuthNonceSize           = 32
+	AuthResponseSize        = 32 // SHA-256 digest length
+)
+
 var (
 	// ErrValidation indicates validation failure
 	ErrValidation = errors.New("validation failed")
@@ -44,12 +55,42 @@ var (
 	ErrTooManyEntries    = errors.New("too many entries")
 	ErrInvalidRange      = errors.New("value out of valid range")
 	
-	// Regex patterns for validation
-	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-	symbolPattern   = regexp.MustCompile(`^[A-Z0-9._-]+$`)
+	// Version strings are validated once per connection, so a regex is fine
 	versionPattern  = regexp.MustCompile(`^[0-9]+\.[0-9]+(\.[0-9]+)?$`)
+	
+	// Byte classes for the hot-path username and symbol checks
+	usernameChars = charSet("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-")
+	symbolChars   = charSet("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._-")
 )
 
+// charSet returns a lookup table of the bytes in chars.
+func charSet(chars string) *[256]bool {
+	var set [256]bool
+	for i := 0; i < len(chars); i++ {
+		set[chars[i]] = true
+	}
+	return &set
+}
+
+// onlyChars reports whether s is non-empty and made only of bytes in set.
+func onlyChars(s string, set *[256]bool) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !set[s[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValidSymbol reports whether symbol is well formed: 1 to MaxSymbolLength
+// upper-case letters, digits, dots, underscores or hyphens.
+func IsValidSymbol(symbol string) bool {
+	return len(symbol) <= MaxSymbolLength && onlyChars(symbol, symbolChars)
+}
+
 // ValidationError represents a validation error with context
 type ValidationError struct {
 	Field   string
@@ -82,12 +123,22 @@ func ValidateAuthRequest(req *pb.AuthRequest) error {
 	if len(req.Username) > MaxUsernameLength {
 		return &ValidationError{Field: "username", Message: "username too long", Value: len(req.Username), Err: ErrFieldTooLong}
 	}
-	if !usernamePattern.MatchString(req.Username) {
+	if !onlyChars(req.Username, usernameChars) {
 		return &ValidationError{Field: "username", Message: "username contains invalid characters", Value: req.Username, Err: ErrInvalidFieldValue}
 	}
 
-	// Password validation
-	if strings.TrimSpace(req.Password) == "" {
+	// Challenge-response requests carry a mechanism instead of a password
+	if req.Mechanism != "" {
+		if req.Mechanism != AuthMechanismHMACSHA256 {
+			return &ValidationError{Field: "mechanism", Message: "unsupported auth mechanism", Value: req.Mechanism, Err: ErrInvalidFieldValue}
+		}
+		if len(req.Response) != 0 && len(req.Response) != AuthResponseSize {
+			return &ValidationError{Field: "response", Message: "invalid challenge response length", Value: len(req.Response), Err: ErrInvalidFieldValue}
+		}
+		if req.Password != "" {
+			return &ValidationError{Field: "password", Message: "password must not be sent with a challenge mechanism", Err: ErrInvalidFieldValue}
+		}
+	} else if strings.TrimSpace(req.Password) == "" {
 		return &ValidationError{Field: "password", Message: "password is required", Err: ErrRequiredField}
 	}
 	if len(req.Password) > MaxPasswordLength {
@@ -101,6 +152,13 @@ func ValidateAuthRequest(req *pb.AuthRequest) error {
 		}
 	}
 
+	if len(req.RequestId) > MaxRequestIDLength {
+		return &ValidationError{Field: "request_id", Message: "request ID too long", Value: len(req.RequestId), Err: ErrFieldTooLong}
+	}
+	if err := validateMetadata(req.Metadata, "metadata"); err != nil {
+		return err
+	}
+
 	// Optional version validation
 	if req.Version != "" {
 		if len(req.Version) > MaxVersionLength {
@@ -129,19 +187,22 @@ func ValidateSubscribeRequest(req *pb.SubscribeRequest) error {
 	}
 
 	// Symbols validation
-	if len(req.Symbols) > MaxSymbolsCount {
+	if len(req.Symbols) > activeLimits.Load().MaxSymbolsCount {
 		return &ValidationError{Field: "symbols", Message: "too many symbols", Value: len(req.Symbols), Err: ErrTooManyEntries}
 	}
-	for i, symbol := range req.Symbols {
-		if strings.TrimSpace(symbol) == "" {
-			return &ValidationError{Field: fmt.Sprintf("symbols[%d]", i), Message: "symbol cannot be empty", Err: ErrRequiredField}
-		}
-		if len(symbol) > MaxSymbolLength {
-			return &ValidationError{Field: fmt.Sprintf("symbols[%d]", i), Message: "symbol too long", Value: len(symbol), Err: ErrFieldTooLong}
-		}
-		if !symbolPattern.MatchString(symbol) {
-			return &ValidationError{Field: fmt.Sprintf("symbols[%d]", i), Message: "invalid symbol format", Value: symbol, Err: ErrInvalidFieldValue}
-		}
+	if err := validateSymbols(req.Symbols, "symbols"); err != nil {
+		return err
+	}
+
+	if len(req.RequestId) > MaxRequestIDLength {
+		return &ValidationError{Field: "request_id", Message: "request ID too long", Value: len(req.RequestId), Err: ErrFieldTooLong}
+	}
+
+	// Delivery mode validation
+	switch req.DeliveryMode {
+	case pb.DeliveryMode_DELIVERY_MODE_UNSPECIFIED, pb.DeliveryMode_DELIVERY_MODE_AT_MOST_ONCE, pb.DeliveryMode_DELIVERY_MODE_AT_LEAST_ONCE:
+	default:
+		return &ValidationError{Field: "delivery_mode", Message: "invalid delivery mode", Value: req.DeliveryMode, Err: ErrInvalidEnum}
 	}
 
 	// Start time validation
@@ -159,6 +220,78 @@ func ValidateSubscribeRequest(req *pb.SubscribeRequest) error {
 	return nil
 }
 
+// validateSymbols validates each symbol in a list
+func validateSymbols(symbols []string, field string) error {
+	for i, symbol := range symbols {
+		if strings.TrimSpace(symbol) == "" {
+			return &ValidationError{Field: fmt.Sprintf("%s[%d]", field, i), Message: "symbol cannot be empty", Err: ErrRequiredField}
+		}
+		if len(symbol) > MaxSymbolLength {
+			return &ValidationError{Field: fmt.Sprintf("%s[%d]", field, i), Message: "symbol too long", Value: len(symbol), Err: ErrFieldTooLong}
+		}
+		if !onlyChars(symbol, symbolChars) {
+			return &ValidationError{Field: fmt.Sprintf("%s[%d]", field, i), Message: "invalid symbol format", Value: symbol, Err: ErrInvalidFieldValue}
+		}
+	}
+	return nil
+}
+
+// ValidateSubscriptionUpdate validates a subscription update request
+func ValidateSubscriptionUpdate(req *pb.SubscriptionUpdateRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if len(req.AddSymbols) == 0 && len(req.RemoveSymbols) == 0 {
+		return &ValidationError{Field: "add_symbols", Message: "update must add or remove at least one symbol", Err: ErrRequiredField}
+	}
+	maxSymbols := activeLimits.Load().MaxSymbolsCount
+	if len(req.AddSymbols) > maxSymbols {
+		return &ValidationError{Field: "add_symbols", Message: "too many symbols", Value: len(req.AddSymbols), Err: ErrTooManyEntries}
+	}
+	if len(req.RemoveSymbols) > maxSymbols {
+		return &ValidationError{Field: "remove_symbols", Message: "too many symbols", Value: len(req.RemoveSymbols), Err: ErrTooManyEntries}
+	}
+	if err := validateSymbols(req.AddSymbols, "add_symbols"); err != nil {
+		return err
+	}
+	if err := validateSymbols(req.RemoveSymbols, "remove_symbols"); err != nil {
+		return err
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
+// ValidatePublishRequest validates a producer's publish request and each of
+// its ticks
+func ValidatePublishRequest(req *pb.PublishRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if len(req.Ticks) == 0 {
+		return &ValidationError{Field: "ticks", Message: "publish must contain at least one tick", Err: ErrRequiredField}
+	}
+	if len(req.Ticks) > activeLimits.Load().MaxTicksPerBatch {
+		return &ValidationError{Field: "ticks", Message: "too many ticks in publish", Value: len(req.Ticks), Err: ErrTooManyEntries}
+	}
+	for i, tick := range req.Ticks {
+		if err := ValidateTick(tick); err != nil {
+			return &ValidationError{Field: fmt.Sprintf("ticks[%d]", i), Message: err.Error(), Err: err}
+		}
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
 // ValidateHeartbeatRequest validates a heartbeat request
 func ValidateHeartbeatRequest(req *pb.HeartbeatRequest) error {
 	if req == nil {
@@ -176,6 +309,132 @@ func ValidateHeartbeatRequest(req *pb.HeartbeatRequest) error {
 	return nil
 }
 
+// ValidateBatchAck validates a batch acknowledgment
+func ValidateBatchAck(ack *pb.BatchAck) error {
+	if ack == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if ack.BatchSequence == 0 {
+		return &ValidationError{Field: "batch_sequence", Message: "batch sequence is required", Err: ErrRequiredField}
+	}
+
+	return nil
+}
+
+// ValidateGapFillRequest validates a gap-fill request
+func ValidateGapFillRequest(req *pb.GapFillRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
+// ValidateResumeRequest validates a resume request
+func ValidateResumeRequest(req *pb.ResumeRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if req.Token == "" {
+		return &ValidationError{Field: "token", Message: "resume token is required", Err: ErrRequiredField}
+	}
+	if len(req.Token) > MaxResumeTokenLength {
+		return &ValidationError{Field: "token", Message: fmt.Sprintf("resume token exceeds %d characters", MaxResumeTokenLength), Value: len(req.Token), Err: ErrFieldTooLong}
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
+// ValidateSymbolListRequest validates a symbol directory request
+func ValidateSymbolListRequest(req *pb.SymbolListRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if len(req.Prefix) > MaxSymbolLength {
+		return &ValidationError{Field: "prefix", Message: "prefix too long", Value: len(req.Prefix), Err: ErrFieldTooLong}
+	}
+	for i, mode := range req.Modes {
+		if mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND && mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE {
+			return &ValidationError{Field: fmt.Sprintf("modes[%d]", i), Message: "invalid subscription mode", Value: mode, Err: ErrInvalidEnum}
+		}
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
+// ValidateHistoryRequest validates a request to replay recent ticks
+func ValidateHistoryRequest(req *pb.HistoryRequest) error {
+	if req == nil {
+		return &ValidationError{Field: "request", Message: "request cannot be nil", Err: ErrRequiredField}
+	}
+
+	if len(req.Symbols) == 0 {
+		return &ValidationError{Field: "symbols", Message: "at least one symbol is required", Err: ErrRequiredField}
+	}
+	if len(req.Symbols) > activeLimits.Load().MaxSymbolsCount {
+		return &ValidationError{Field: "symbols", Message: "too many symbols", Value: len(req.Symbols), Err: ErrTooManyEntries}
+	}
+	if err := validateSymbols(req.Symbols, "symbols"); err != nil {
+		return err
+	}
+
+	if req.Minutes == 0 || req.Minutes > MaxHistoryMinutes {
+		return &ValidationError{Field: "minutes", Message: fmt.Sprintf("minutes must be between 1 and %d", MaxHistoryMinutes), Value: req.Minutes, Err: ErrInvalidFieldValue}
+	}
+	if req.Mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED &&
+		req.Mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND && req.Mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE {
+		return &ValidationError{Field: "mode", Message: "invalid subscription mode", Value: req.Mode, Err: ErrInvalidEnum}
+	}
+
+	if len(req.RequestId) > MaxRequestIDLength {
+		return &ValidationError{Field: "request_id", Message: "request ID too long", Value: len(req.RequestId), Err: ErrFieldTooLong}
+	}
+
+	if req.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: req.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
+// ValidateStreamStatus validates a symbol stream status report
+func ValidateStreamStatus(status *pb.StreamStatus) error {
+	if status == nil {
+		return &ValidationError{Field: "status", Message: "status cannot be nil", Err: ErrRequiredField}
+	}
+
+	if status.Symbol == "" || !IsValidSymbol(status.Symbol) {
+		return &ValidationError{Field: "symbol", Message: "invalid symbol", Value: status.Symbol, Err: ErrInvalidFieldValue}
+	}
+	if _, ok := pb.StreamState_name[int32(status.State)]; !ok || status.State == pb.StreamState_STREAM_STATE_UNSPECIFIED {
+		return &ValidationError{Field: "state", Message: "invalid stream state", Value: status.State, Err: ErrInvalidEnum}
+	}
+	if len(status.Reason) > MaxMessageLength {
+		return &ValidationError{Field: "reason", Message: "reason too long", Value: len(status.Reason), Err: ErrFieldTooLong}
+	}
+
+	if status.TimestampMs < 0 {
+		return &ValidationError{Field: "timestamp_ms", Message: "timestamp cannot be negative", Value: status.TimestampMs, Err: ErrInvalidFieldValue}
+	}
+
+	return nil
+}
+
 // ValidateDataBatch validates a data batch message
 func ValidateDataBatch(batch *pb.DataBatch) error {
 	if batch == nil {
@@ -186,7 +445,7 @@ func ValidateDataBatch(batch *pb.DataBatch) error {
 	if len(batch.Ticks) == 0 {
 		return &ValidationError{Field: "ticks", Message: "batch must contain at least one tick", Err: ErrRequiredField}
 	}
-	if len(batch.Ticks) > MaxTicksPerBatch {
+	if len(batch.Ticks) > activeLimits.Load().MaxTicksPerBatch {
 		return &ValidationError{Field: "ticks", Message: "too many ticks in batch", Value: len(batch.Ticks), Err: ErrTooManyEntries}
 	}
 
@@ -221,7 +480,7 @@ func ValidateTick(tick *pb.Tick) error {
 	if len(tick.Symbol) > MaxSymbolLength {
 		return &ValidationError{Field: "symbol", Message: "symbol too long", Value: len(tick.Symbol), Err: ErrFieldTooLong}
 	}
-	if !symbolPattern.MatchString(tick.Symbol) {
+	if !onlyChars(tick.Symbol, symbolChars) {
 		return &ValidationError{Field: "symbol", Message: "invalid symbol format", Value: tick.Symbol, Err: ErrInvalidFieldValue}
 	}
 
@@ -234,20 +493,21 @@ func ValidateTick(tick *pb.Tick) error {
 	}
 
 	// Price validation
-	if tick.Price < MinPrice || tick.Price > MaxPrice {
+	limits := activeLimits.Load()
+	if tick.Price < limits.MinPrice || tick.Price > limits.MaxPrice {
 		return &ValidationError{Field: "price", Message: "price out of valid range", Value: tick.Price, Err: ErrInvalidRange}
 	}
 
 	// Volume validation
-	if tick.Volume < MinVolume || tick.Volume > MaxVolume {
+	if tick.Volume < limits.MinVolume || tick.Volume > limits.MaxVolume {
 		return &ValidationError{Field: "volume", Message: "volume out of valid range", Value: tick.Volume, Err: ErrInvalidRange}
 	}
 
 	// Bid/Ask validation
-	if tick.Bid != 0 && (tick.Bid < MinPrice || tick.Bid > MaxPrice) {
+	if tick.Bid != 0 && (tick.Bid < limits.MinPrice || tick.Bid > limits.MaxPrice) {
 		return &ValidationError{Field: "bid", Message: "bid price out of valid range", Value: tick.Bid, Err: ErrInvalidRange}
 	}
-	if tick.Ask != 0 && (tick.Ask < MinPrice || tick.Ask > MaxPrice) {
+	if tick.Ask != 0 && (tick.Ask < limits.MinPrice || tick.Ask > limits.MaxPrice) {
 		return &ValidationError{Field: "ask", Message: "ask price out of valid range", Value: tick.Ask, Err: ErrInvalidRange}
 	}
 
@@ -375,15 +635,14 @@ func validateTimestamp(timestampMs int64, fieldName string) error {
 
 	// Check if timestamp is too far in the past or future
 	now := time.Now().UnixMilli()
-	maxAge := MaxTimestampAge.Milliseconds()
+	limits := activeLimits.Load()
 	
-	if timestampMs < now-maxAge {
+	if timestampMs < now-limits.MaxTimestampAge.Milliseconds() {
 		return &ValidationError{Field: fieldName, Message: "timestamp too old", Value: timestampMs, Err: ErrInvalidTimestamp}
 	}
 	
-	// Allow some future tolerance (5 minutes)
-	futureThreshold := 5 * time.Minute.Milliseconds()
-	if timestampMs > now+futureThreshold {
+	// Allow some future tolerance for clock skew
+	if timestampMs > now+limits.MaxTimestampSkew.Milliseconds() {
 		return &ValidationError{Field: fieldName, Message: "timestamp too far in future", Value: timestampMs, Err: ErrInvalidTimestamp}
 	}
 
@@ -429,7 +688,11 @@ func SanitizeString(input string) string {
 func ValidateMessageType(msgType MessageType) error {
 	switch msgType {
 	case MessageTypeAuth, MessageTypeSubscribe, MessageTypeHeartbeat, 
-		 MessageTypeDataBatch, MessageTypeError, MessageTypeACK, MessageTypePong:
+		 MessageTypeDataBatch, MessageTypeError, MessageTypeACK, MessageTypePong,
+		 MessageTypeBatchAck, MessageTypeGapFill, MessageTypeAuthChallenge, MessageTypeInfo,
+		 MessageTypeResume, MessageTypeSymbolList, MessageTypeSubscriptionUpdate, MessageTypePublish,
+		 MessageTypeStreamStatus, MessageTypeHistory, MessageTypeKeepalive,
+		 MessageTypeReplicaSync, MessageTypeReplicaBatch, MessageTypeReplicaState:
 		return nil
 	default:
 		return &ValidationError{Field: "message_type", Message: "unknown message type", Value: msgType, Err: ErrInvalidFieldValue}
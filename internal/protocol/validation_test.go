@@ -33,6 +33,62 @@ func TestValidateAuthRequest(t *testing.T) {
 			wantErr: true,
 			errType: ErrRequiredField,
 		},
+		{
+			name: "challenge request without password",
+			req: &pb.AuthRequest{
+				Username:  "testuser",
+				Mechanism: AuthMechanismHMACSHA256,
+			},
+			wantErr: false,
+		},
+		{
+			name: "challenge response",
+			req: &pb.AuthRequest{
+				Username:  "testuser",
+				Mechanism: AuthMechanismHMACSHA256,
+				Response:  make([]byte, AuthResponseSize),
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported mechanism",
+			req: &pb.AuthRequest{
+				Username:  "testuser",
+				Mechanism: "md5",
+			},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name: "challenge response with password",
+			req: &pb.AuthRequest{
+				Username:  "testuser",
+				Password:  "testpass",
+				Mechanism: AuthMechanismHMACSHA256,
+			},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name: "request ID too long",
+			req: &pb.AuthRequest{
+				Username:  "testuser",
+				Password:  "testpass",
+				RequestId: strings.Repeat("r", MaxRequestIDLength+1),
+			},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
+		{
+			name: "metadata value too long",
+			req: &pb.AuthRequest{
+				Username: "testuser",
+				Password: "testpass",
+				Metadata: map[string]string{MetadataTraceparent: strings.Repeat("0", MaxMetadataValLength+1)},
+			},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
 		{
 			name: "empty username",
 			req: &pb.AuthRequest{
@@ -130,6 +186,15 @@ func TestValidateSubscribeRequest(t *testing.T) {
 			wantErr: true,
 			errType: ErrRequiredField,
 		},
+		{
+			name: "request ID too long",
+			req: &pb.SubscribeRequest{
+				Mode:      pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				RequestId: strings.Repeat("r", MaxRequestIDLength+1),
+			},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
 		{
 			name: "unspecified mode",
 			req: &pb.SubscribeRequest{
@@ -165,6 +230,23 @@ func TestValidateSubscribeRequest(t *testing.T) {
 			wantErr: true,
 			errType: ErrInvalidTimestamp,
 		},
+		{
+			name: "at-least-once delivery",
+			req: &pb.SubscribeRequest{
+				Mode:         pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				DeliveryMode: pb.DeliveryMode_DELIVERY_MODE_AT_LEAST_ONCE,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid delivery mode",
+			req: &pb.SubscribeRequest{
+				Mode:         pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				DeliveryMode: pb.DeliveryMode(99),
+			},
+			wantErr: true,
+			errType: ErrInvalidEnum,
+		},
 	}
 
 	for _, tt := range tests {
@@ -240,6 +322,341 @@ func TestValidateHeartbeatRequest(t *testing.T) {
 	}
 }
 
+func TestValidateResumeRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *pb.ResumeRequest
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "valid resume request",
+			req:     &pb.ResumeRequest{Token: "3f2a9c", LastSequence: 42},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "missing token",
+			req:     &pb.ResumeRequest{LastSequence: 1},
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "token too long",
+			req:     &pb.ResumeRequest{Token: strings.Repeat("a", MaxResumeTokenLength+1)},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResumeRequest(tt.req)
+			if tt.wantErr {
+				require.Error(t, err)
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, validationErr.Err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateSubscriptionUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *pb.SubscriptionUpdateRequest
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "add and remove",
+			req:     &pb.SubscriptionUpdateRequest{AddSymbols: []string{"EURUSD"}, RemoveSymbols: []string{"GBPUSD"}},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "empty update",
+			req:     &pb.SubscriptionUpdateRequest{},
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "invalid symbol",
+			req:     &pb.SubscriptionUpdateRequest{RemoveSymbols: []string{"bad@symbol"}},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name:    "too many symbols",
+			req:     &pb.SubscriptionUpdateRequest{AddSymbols: make([]string, MaxSymbolsCount+1)},
+			wantErr: true,
+			errType: ErrTooManyEntries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSubscriptionUpdate(tt.req)
+			if tt.wantErr {
+				require.Error(t, err)
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, validationErr.Err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidatePublishRequest(t *testing.T) {
+	tick := func(symbol string, price float64) *pb.Tick {
+		return &pb.Tick{Symbol: symbol, Price: price, TimestampMs: time.Now().UnixMilli(), Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	}
+	tests := []struct {
+		name    string
+		req     *pb.PublishRequest
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "valid ticks",
+			req:     &pb.PublishRequest{Ticks: []*pb.Tick{tick("EURUSD", 1.08), tick("GBPUSD", 1.27)}},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "no ticks",
+			req:     &pb.PublishRequest{},
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "invalid tick",
+			req:     &pb.PublishRequest{Ticks: []*pb.Tick{tick("EURUSD", 1.08), tick("EURUSD", -1)}},
+			wantErr: true,
+			errType: ErrInvalidRange,
+		},
+		{
+			name:    "too many ticks",
+			req:     &pb.PublishRequest{Ticks: make([]*pb.Tick, MaxTicksPerBatch+1)},
+			wantErr: true,
+			errType: ErrTooManyEntries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePublishRequest(tt.req)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateSymbolListRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *pb.SymbolListRequest
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "empty request lists everything",
+			req:     &pb.SymbolListRequest{},
+			wantErr: false,
+		},
+		{
+			name:    "prefix and modes",
+			req:     &pb.SymbolListRequest{Prefix: "FX.", Modes: []pb.SubscriptionMode{pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "prefix too long",
+			req:     &pb.SymbolListRequest{Prefix: strings.Repeat("A", MaxSymbolLength+1)},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
+		{
+			name:    "unspecified mode",
+			req:     &pb.SymbolListRequest{Modes: []pb.SubscriptionMode{pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED}},
+			wantErr: true,
+			errType: ErrInvalidEnum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSymbolListRequest(tt.req)
+			if tt.wantErr {
+				require.Error(t, err)
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, validationErr.Err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateHistoryRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *pb.HistoryRequest
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "valid request",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: 5, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE},
+			wantErr: false,
+		},
+		{
+			name:    "mode defaults to the subscription's",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: MaxHistoryMinutes},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "no symbols",
+			req:     &pb.HistoryRequest{Minutes: 5},
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "invalid symbol",
+			req:     &pb.HistoryRequest{Symbols: []string{"EUR USD"}, Minutes: 5},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name:    "zero minutes",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name:    "more than a day",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: MaxHistoryMinutes + 1},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name:    "invalid mode",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: 5, Mode: 7},
+			wantErr: true,
+			errType: ErrInvalidEnum,
+		},
+		{
+			name:    "request ID too long",
+			req:     &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: 5, RequestId: strings.Repeat("r", MaxRequestIDLength+1)},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHistoryRequest(tt.req)
+			if tt.wantErr {
+				require.Error(t, err)
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, validationErr.Err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateStreamStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  *pb.StreamStatus
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "halt",
+			status:  &pb.StreamStatus{Symbol: "EURUSD", State: pb.StreamState_STREAM_STATE_HALTED, Reason: "volatility halt"},
+			wantErr: false,
+		},
+		{
+			name:    "nil status",
+			status:  nil,
+			wantErr: true,
+			errType: ErrRequiredField,
+		},
+		{
+			name:    "missing symbol",
+			status:  &pb.StreamStatus{State: pb.StreamState_STREAM_STATE_LIVE},
+			wantErr: true,
+			errType: ErrInvalidFieldValue,
+		},
+		{
+			name:    "unspecified state",
+			status:  &pb.StreamStatus{Symbol: "EURUSD"},
+			wantErr: true,
+			errType: ErrInvalidEnum,
+		},
+		{
+			name:    "reason too long",
+			status:  &pb.StreamStatus{Symbol: "EURUSD", State: pb.StreamState_STREAM_STATE_STALE, Reason: strings.Repeat("r", MaxMessageLength+1)},
+			wantErr: true,
+			errType: ErrFieldTooLong,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateStreamStatus(tt.status)
+			if tt.wantErr {
+				require.Error(t, err)
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.ErrorIs(t, validationErr.Err, tt.errType)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestValidateTick(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -474,6 +891,18 @@ func TestValidateErrorResponse(t *testing.T) {
 	}
 }
 
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_RATE_LIMITED))
+	assert.True(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_SERVER_BUSY))
+	assert.True(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_SLOW_CONSUMER))
+	assert.True(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_QUOTA_EXCEEDED))
+	assert.True(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_SERVER_DRAINING))
+	assert.False(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_NOT_ENTITLED))
+	assert.False(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION))
+	assert.False(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_INVALID_AUTH))
+	assert.False(t, IsRetryableError(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION))
+}
+
 func TestValidateMessageType(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -487,6 +916,16 @@ func TestValidateMessageType(t *testing.T) {
 		{name: "error", msgType: MessageTypeError, wantErr: false},
 		{name: "ack", msgType: MessageTypeACK, wantErr: false},
 		{name: "pong", msgType: MessageTypePong, wantErr: false},
+		{name: "batch_ack", msgType: MessageTypeBatchAck, wantErr: false},
+		{name: "gap_fill", msgType: MessageTypeGapFill, wantErr: false},
+		{name: "auth_challenge", msgType: MessageTypeAuthChallenge, wantErr: false},
+		{name: "info", msgType: MessageTypeInfo, wantErr: false},
+		{name: "resume", msgType: MessageTypeResume, wantErr: false},
+		{name: "symbol_list", msgType: MessageTypeSymbolList, wantErr: false},
+		{name: "subscription_update", msgType: MessageTypeSubscriptionUpdate, wantErr: false},
+		{name: "publish", msgType: MessageTypePublish, wantErr: false},
+		{name: "stream_status", msgType: MessageTypeStreamStatus, wantErr: false},
+		{name: "replica_batch", msgType: MessageTypeReplicaBatch, wantErr: false},
 		{name: "invalid", msgType: MessageType(99), wantErr: true},
 	}
 
@@ -47,6 +47,8 @@ type VersionFeatures struct {
 	RateLimiting     bool
 	Compression      bool
 	TLS              bool
+	ChallengeAuth    bool // HMAC challenge-response via AUTH_CHALLENGE
+	SessionResume    bool // RESUME of a parked subscription after a disconnect
 	
 	// Performance features
 	AsyncWrites      bool
@@ -71,6 +73,8 @@ var SupportedVersions = map[uint8]*Version{
 			RateLimiting:     true,
 			Compression:      false, // Not implemented yet
 			TLS:              false, // Not implemented yet
+			ChallengeAuth:    true,
+			SessionResume:    true,
 			AsyncWrites:      true,
 			ObjectPooling:    true,
 			TCPOptimizations: true,
@@ -199,6 +203,10 @@ func HasFeature(version uint8, feature string) bool {
 		return features.Compression
 	case "tls":
 		return features.TLS
+	case "challenge_auth":
+		return features.ChallengeAuth
+	case "session_resume":
+		return features.SessionResume
 	case "async_writes":
 		return features.AsyncWrites
 	case "object_pooling":
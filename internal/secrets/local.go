@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// EnvProvider reads each key from the environment variable of the same name.
+type EnvProvider struct{}
+
+// Name implements Provider.
+func (EnvProvider) Name() string { return "env" }
+
+// Fetch implements Provider.
+func (EnvProvider) Fetch(_ context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if v, ok := os.LookupEnv(key); ok && v != "" {
+			values[key] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+// FileProvider reads each key from a file of the same name in Dir, the
+// layout used by Kubernetes and Docker secret mounts. Files are re-read on
+// every fetch, so updating the mount rotates the secret.
+type FileProvider struct {
+	Dir string
+}
+
+// Name implements Provider.
+func (p *FileProvider) Name() string { return "file" }
+
+// Fetch implements Provider.
+func (p *FileProvider) Fetch(_ context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := os.ReadFile(filepath.Join(p.Dir, key))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		// Editors and `echo` append a newline that is not part of the secret
+		values[key] = bytes.TrimRight(data, "\r\n")
+	}
+	return values, nil
+}
@@ -0,0 +1,200 @@
+// Package secrets loads credentials and key material from pluggable backends
+// and keeps them fresh so they can be rotated without restarting the server.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Well-known secret keys consumed by the server.
+const (
+	KeyStreamUser     = "STREAM_USER"
+	KeyStreamPass     = "STREAM_PASS"
+	KeyStreamPassHash = "STREAM_PASS_HASH"
+	KeyTLSCert        = "TLS_CERT" // PEM certificate chain
+	KeyTLSKey         = "TLS_KEY"  // PEM private key
+)
+
+var (
+	// ErrUnknownProvider indicates an unsupported SECRETS_PROVIDER value.
+	ErrUnknownProvider = errors.New("unknown secrets provider")
+
+	// ErrProviderConfig indicates a provider is missing required settings.
+	ErrProviderConfig = errors.New("incomplete secrets provider configuration")
+)
+
+// Provider fetches secret values from a backend. Keys the backend does not
+// hold are omitted from the result rather than reported as errors.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// NewProviderFromEnv builds the provider named by name ("env", "file",
+// "vault" or "aws") from its environment variables.
+func NewProviderFromEnv(name string) (Provider, error) {
+	switch name {
+	case "env":
+		return EnvProvider{}, nil
+	case "file":
+		dir := os.Getenv("SECRETS_DIR")
+		if dir == "" {
+			dir = "/run/secrets"
+		}
+		return &FileProvider{Dir: dir}, nil
+	case "vault":
+		return NewVaultProviderFromEnv()
+	case "aws":
+		return NewAWSProviderFromEnv()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+}
+
+// Store caches secrets from a provider, refreshing them periodically. A
+// failed refresh keeps serving the last good values.
+type Store struct {
+	provider Provider
+	keys     []string
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu       sync.RWMutex
+	values   map[string][]byte
+	onChange []func(map[string][]byte)
+
+	refreshes   uint64
+	failures    uint64
+	rotations   uint64
+	lastRefresh atomic.Int64 // Unix nanoseconds of the last successful refresh
+}
+
+// NewStore creates a store for keys. interval <= 0 disables periodic refresh.
+func NewStore(provider Provider, keys []string, interval time.Duration, logger *slog.Logger) *Store {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Store{
+		provider: provider,
+		keys:     keys,
+		interval: interval,
+		logger:   logger,
+		values:   make(map[string][]byte),
+	}
+}
+
+// OnChange registers fn to receive a snapshot of all values whenever a
+// refresh changes any of them.
+func (s *Store) OnChange(fn func(values map[string][]byte)) {
+	s.mu.Lock()
+	s.onChange = append(s.onChange, fn)
+	s.mu.Unlock()
+}
+
+// Get returns the cached value for key.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Values returns a snapshot of all cached values.
+func (s *Store) Values() map[string][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	values := make(map[string][]byte, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Refresh fetches all keys and, if anything changed, swaps them in as one
+// set and notifies OnChange callbacks.
+func (s *Store) Refresh(ctx context.Context) (bool, error) {
+	atomic.AddUint64(&s.refreshes, 1)
+	values, err := s.provider.Fetch(ctx, s.keys)
+	if err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		return false, fmt.Errorf("%s: %w", s.provider.Name(), err)
+	}
+	s.lastRefresh.Store(time.Now().UnixNano())
+
+	s.mu.Lock()
+	if equalValues(s.values, values) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.values = values
+	callbacks := append([]func(map[string][]byte){}, s.onChange...)
+	s.mu.Unlock()
+
+	atomic.AddUint64(&s.rotations, 1)
+	for _, fn := range callbacks {
+		fn(values)
+	}
+	return true, nil
+}
+
+// Run refreshes on the configured interval until ctx is cancelled.
+func (s *Store) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Refresh(ctx)
+			if err != nil {
+				s.logger.Warn("secrets refresh failed, keeping previous values",
+					"provider", s.provider.Name(), "error", err)
+				continue
+			}
+			if changed {
+				s.logger.Info("secrets rotated", "provider", s.provider.Name())
+			}
+		}
+	}
+}
+
+// GetStats returns refresh statistics.
+func (s *Store) GetStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"provider":        s.provider.Name(),
+		"refreshes_total": atomic.LoadUint64(&s.refreshes),
+		"failures_total":  atomic.LoadUint64(&s.failures),
+		"rotations_total": atomic.LoadUint64(&s.rotations),
+	}
+	if ts := s.lastRefresh.Load(); ts > 0 {
+		stats["last_refresh"] = time.Unix(0, ts).UTC().Format(time.RFC3339)
+	}
+	return stats
+}
+
+// equalValues reports whether two value sets hold the same keys and bytes.
+func equalValues(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok || !bytes.Equal(v, w) {
+			return false
+		}
+	}
+	return true
+}
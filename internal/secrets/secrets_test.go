@@ -0,0 +1,209 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticProvider serves values from a map that tests can swap.
+type staticProvider struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) Fetch(_ context.Context, keys []string) (map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	out := make(map[string][]byte)
+	for _, k := range keys {
+		if v, ok := p.values[k]; ok {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (p *staticProvider) set(values map[string][]byte, err error) {
+	p.mu.Lock()
+	p.values, p.err = values, err
+	p.mu.Unlock()
+}
+
+func TestStoreRefreshAndRotation(t *testing.T) {
+	p := &staticProvider{values: map[string][]byte{KeyStreamUser: []byte("alice"), KeyStreamPass: []byte("one")}}
+	store := NewStore(p, []string{KeyStreamUser, KeyStreamPass}, 0, nil)
+
+	var notified []map[string][]byte
+	store.OnChange(func(v map[string][]byte) { notified = append(notified, v) })
+
+	changed, err := store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	v, ok := store.Get(KeyStreamPass)
+	require.True(t, ok)
+	assert.Equal(t, "one", string(v))
+
+	// Unchanged values do not notify
+	changed, err = store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Len(t, notified, 1)
+
+	// A failed refresh keeps the last good values
+	p.set(nil, errors.New("backend down"))
+	_, err = store.Refresh(context.Background())
+	assert.Error(t, err)
+	v, _ = store.Get(KeyStreamPass)
+	assert.Equal(t, "one", string(v))
+
+	p.set(map[string][]byte{KeyStreamUser: []byte("alice"), KeyStreamPass: []byte("two")}, nil)
+	changed, err = store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	require.Len(t, notified, 2)
+	assert.Equal(t, "two", string(notified[1][KeyStreamPass]))
+
+	stats := store.GetStats()
+	assert.Equal(t, uint64(4), stats["refreshes_total"])
+	assert.Equal(t, uint64(1), stats["failures_total"])
+	assert.Equal(t, uint64(2), stats["rotations_total"])
+}
+
+func TestStoreRunRefreshesPeriodically(t *testing.T) {
+	p := &staticProvider{values: map[string][]byte{KeyStreamPass: []byte("one")}}
+	store := NewStore(p, []string{KeyStreamPass}, 10*time.Millisecond, nil)
+	_, err := store.Refresh(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Run(ctx)
+
+	p.set(map[string][]byte{KeyStreamPass: []byte("two")}, nil)
+	assert.Eventually(t, func() bool {
+		v, _ := store.Get(KeyStreamPass)
+		return string(v) == "two"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestEnvAndFileProviders(t *testing.T) {
+	t.Setenv(KeyStreamUser, "env-user")
+	values, err := EnvProvider{}.Fetch(context.Background(), []string{KeyStreamUser, "TICK_STORM_UNSET_SECRET"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{KeyStreamUser: []byte("env-user")}, values)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, KeyStreamPass), []byte("file-pass\n"), 0o600))
+	values, err = (&FileProvider{Dir: dir}).Fetch(context.Background(), []string{KeyStreamPass, KeyTLSKey})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{KeyStreamPass: []byte("file-pass")}, values)
+}
+
+func TestNewProviderFromEnv(t *testing.T) {
+	p, err := NewProviderFromEnv("env")
+	require.NoError(t, err)
+	assert.Equal(t, "env", p.Name())
+
+	t.Setenv("VAULT_ADDR", "")
+	_, err = NewProviderFromEnv("vault")
+	assert.ErrorIs(t, err, ErrProviderConfig)
+
+	t.Setenv("AWS_REGION", "")
+	_, err = NewProviderFromEnv("aws")
+	assert.ErrorIs(t, err, ErrProviderConfig)
+
+	_, err = NewProviderFromEnv("consul")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+}
+
+func TestVaultProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/tick-storm" || r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "ops", r.Header.Get("X-Vault-Namespace"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"STREAM_USER": "vault-user", "STREAM_PASS": "vault-pass", "other": "x"},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	p := &VaultProvider{Addr: srv.URL, Token: "s.token", Namespace: "ops", Mount: "secret", Path: "tick-storm"}
+	values, err := p.Fetch(context.Background(), []string{KeyStreamUser, KeyStreamPass, KeyTLSCert})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{KeyStreamUser: []byte("vault-user"), KeyStreamPass: []byte("vault-pass")}, values)
+
+	p.Token = "wrong"
+	_, err = p.Fetch(context.Background(), []string{KeyStreamUser})
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestAWSProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "20240102T030405Z", r.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20240102/eu-west-1/secretsmanager/aws4_request, "), auth)
+		assert.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, ")
+
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "prod/tick-storm", req["SecretId"])
+
+		secret, _ := json.Marshal(map[string]string{"STREAM_USER": "aws-user", "STREAM_PASS": "aws-pass"})
+		_ = json.NewEncoder(w).Encode(map[string]string{"SecretString": string(secret)})
+	}))
+	defer srv.Close()
+
+	p := &AWSProvider{
+		Region:          "eu-west-1",
+		SecretID:        "prod/tick-storm",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "session",
+		Endpoint:        srv.URL,
+		now:             func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	}
+	values, err := p.Fetch(context.Background(), []string{KeyStreamUser, KeyStreamPass})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{KeyStreamUser: []byte("aws-user"), KeyStreamPass: []byte("aws-pass")}, values)
+}
+
+// TestSignV4ReferenceVector checks the signer against the IAM ListUsers
+// example from the AWS Signature Version 4 documentation.
+func TestSignV4ReferenceVector(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signV4(req, nil, "iam", "us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
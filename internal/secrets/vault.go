@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultProvider reads keys from one HashiCorp Vault KV version 2 secret,
+// e.g. mount "secret" and path "tick-storm" holding STREAM_USER, STREAM_PASS.
+type VaultProvider struct {
+	Addr      string // e.g. https://vault.example.com:8200
+	Token     string
+	Namespace string // Vault Enterprise namespace (optional)
+	Mount     string
+	Path      string
+	Client    *http.Client
+}
+
+// NewVaultProviderFromEnv builds a provider from VAULT_ADDR, VAULT_TOKEN,
+// VAULT_NAMESPACE, VAULT_MOUNT (default "secret") and VAULT_SECRET_PATH
+// (default "tick-storm").
+func NewVaultProviderFromEnv() (*VaultProvider, error) {
+	p := &VaultProvider{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Mount:     "secret",
+		Path:      "tick-storm",
+	}
+	if v := os.Getenv("VAULT_MOUNT"); v != "" {
+		p.Mount = v
+	}
+	if v := os.Getenv("VAULT_SECRET_PATH"); v != "" {
+		p.Path = v
+	}
+	if p.Addr == "" || p.Token == "" {
+		return nil, fmt.Errorf("%w: VAULT_ADDR and VAULT_TOKEN are required", ErrProviderConfig)
+	}
+	return p, nil
+}
+
+// Name implements Provider.
+func (p *VaultProvider) Name() string { return "vault" }
+
+// Fetch implements Provider.
+func (p *VaultProvider) Fetch(ctx context.Context, keys []string) (map[string][]byte, error) {
+	endpoint := strings.TrimRight(p.Addr, "/") + "/v1/" +
+		url.PathEscape(strings.Trim(p.Mount, "/")) + "/data/" + strings.Trim(p.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("decode vault response: %w", err)
+	}
+	return pickStrings(secret.Data.Data, keys), nil
+}
+
+func (p *VaultProvider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// pickStrings returns the string values of keys present in data.
+func pickStrings(data map[string]interface{}, keys []string) map[string][]byte {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if s, ok := data[key].(string); ok && s != "" {
+			values[key] = []byte(s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Accept errors are retried after a delay doubling from acceptBackoffMin up
+// to acceptBackoffMax while Accept keeps failing.
+const (
+	acceptBackoffMin = 5 * time.Millisecond
+	acceptBackoffMax = time.Second
+)
+
+// nextAcceptBackoff returns the delay after a failed Accept that followed a
+// delay of prev (0 after a successful Accept).
+func nextAcceptBackoff(prev time.Duration) time.Duration {
+	if prev == 0 {
+		return acceptBackoffMin
+	}
+	if next := 2 * prev; next < acceptBackoffMax {
+		return next
+	}
+	return acceptBackoffMax
+}
+
+// acceptErrorReason classifies an Accept error for metrics and logs.
+func acceptErrorReason(err error) string {
+	var ne net.Error
+	switch {
+	case errors.Is(err, syscall.EMFILE), errors.Is(err, syscall.ENFILE):
+		return "fd_exhausted"
+	case errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.ECONNRESET):
+		return "aborted"
+	case errors.As(err, &ne) && ne.Timeout():
+		return "timeout"
+	}
+	return "other"
+}
+
+// acceptFailed records an Accept error on l that will be retried after
+// backoff. Running out of file descriptors puts the breach handler into
+// degradation straight away rather than at its next check.
+func (s *Server) acceptFailed(l *serverListener, err error, backoff time.Duration) {
+	reason := acceptErrorReason(err)
+	if reason == "fd_exhausted" && s.breachHandler != nil {
+		s.breachHandler.ReportFDExhaustion()
+	}
+	s.prometheusMetrics.IncrementAcceptErrors(s.instanceID, l.name, reason)
+	s.logger.Warn("accept failed, retrying",
+		"listener", l.name,
+		"reason", reason,
+		"error", err,
+		"retry_in", backoff,
+	)
+}
+
+// acceptLoopExited records that l stopped accepting connections.
+func (s *Server) acceptLoopExited(l *serverListener, reason string, err error) {
+	s.prometheusMetrics.IncrementAcceptLoopExits(s.instanceID, l.name, reason)
+	if reason == "shutdown" {
+		s.logger.Info("accept loop stopped", "listener", l.name)
+		return
+	}
+	s.logger.Error("accept loop exited, listener no longer accepting connections",
+		"listener", l.name,
+		"reason", reason,
+		"error", err,
+	)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingListener returns the queued errors from Accept, then net.ErrClosed.
+type failingListener struct {
+	net.Listener
+	errs chan error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	select {
+	case err := <-l.errs:
+		return nil, err
+	default:
+		return nil, net.ErrClosed
+	}
+}
+
+func TestNextAcceptBackoff(t *testing.T) {
+	var got []time.Duration
+	var d time.Duration
+	for i := 0; i < 10; i++ {
+		d = nextAcceptBackoff(d)
+		got = append(got, d)
+	}
+	assert.Equal(t, acceptBackoffMin, got[0])
+	assert.Equal(t, 2*acceptBackoffMin, got[1])
+	assert.Equal(t, acceptBackoffMax, got[len(got)-1])
+}
+
+func TestAcceptErrorReason(t *testing.T) {
+	opErr := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", errno)}
+	}
+	assert.Equal(t, "fd_exhausted", acceptErrorReason(opErr(syscall.EMFILE)))
+	assert.Equal(t, "fd_exhausted", acceptErrorReason(opErr(syscall.ENFILE)))
+	assert.Equal(t, "aborted", acceptErrorReason(opErr(syscall.ECONNABORTED)))
+	assert.Equal(t, "other", acceptErrorReason(errors.New("boom")))
+}
+
+func TestAcceptLoopBacksOffAndReportsExit(t *testing.T) {
+	srv := NewServer(DefaultConfig())
+	defer srv.Stop(context.Background())
+
+	emfile := &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	l := &serverListener{name: "test", Listener: &failingListener{errs: make(chan error, 3)}}
+	for i := 0; i < 3; i++ {
+		l.Listener.(*failingListener).errs <- emfile
+	}
+	errorsBefore := counterValue(t, srv.prometheusMetrics.acceptErrors.WithLabelValues(srv.instanceID, "test", "fd_exhausted"))
+	exitsBefore := counterValue(t, srv.prometheusMetrics.acceptLoopExits.WithLabelValues(srv.instanceID, "test", "listener_closed"))
+
+	done := make(chan struct{})
+	start := time.Now()
+	srv.wg.Add(1)
+	go func() {
+		srv.acceptLoop(l)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept loop did not exit on a closed listener")
+	}
+
+	// 5ms + 10ms + 20ms of backoff before the listener reports closed
+	assert.GreaterOrEqual(t, time.Since(start), 35*time.Millisecond)
+	assert.Equal(t, errorsBefore+3, counterValue(t, srv.prometheusMetrics.acceptErrors.WithLabelValues(srv.instanceID, "test", "fd_exhausted")))
+	assert.Equal(t, exitsBefore+1, counterValue(t, srv.prometheusMetrics.acceptLoopExits.WithLabelValues(srv.instanceID, "test", "listener_closed")))
+	require.True(t, srv.breachHandler.ShouldRejectConnection(), "EMFILE puts the breach handler into degradation")
+}
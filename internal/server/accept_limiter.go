@@ -0,0 +1,92 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AcceptLimiter caps the rate at which the accept loops admit connections
+// across all listeners, so a reconnect storm cannot monopolize CPU with TLS
+// handshakes and authentication. It is a token bucket refilled at rate tokens
+// per second up to burst; unlike the DDoS protection it ignores the client
+// address entirely.
+type AcceptLimiter struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// Metrics
+	accepted uint64
+	rejected uint64
+}
+
+// NewAcceptLimiter creates a limiter admitting rate connections per second
+// with bursts of up to burst. A burst of 0 defaults to one second's worth
+// of connections.
+func NewAcceptLimiter(rate float64, burst int, clock Clock) *AcceptLimiter {
+	b := float64(burst)
+	if b <= 0 {
+		b = max(1, math.Ceil(rate))
+	}
+	return &AcceptLimiter{
+		rate:   rate,
+		burst:  b,
+		clock:  clock,
+		tokens: b,
+		last:   clock.Now(),
+	}
+}
+
+// Allow takes a token for one connection, reporting false when the bucket is
+// empty. A nil limiter allows everything.
+func (l *AcceptLimiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN takes n tokens at once, reporting false and taking none when fewer
+// are available. A nil limiter allows everything.
+func (l *AcceptLimiter) AllowN(n int) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	now := l.clock.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = min(l.burst, l.tokens+elapsed.Seconds()*l.rate)
+		l.last = now
+	}
+	ok := l.tokens >= float64(n)
+	if ok {
+		l.tokens -= float64(n)
+	}
+	l.mu.Unlock()
+
+	if ok {
+		atomic.AddUint64(&l.accepted, 1)
+	} else {
+		atomic.AddUint64(&l.rejected, 1)
+	}
+	return ok
+}
+
+// GetStats returns accept limiter statistics for Server.GetStats.
+func (l *AcceptLimiter) GetStats() map[string]interface{} {
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+
+	return map[string]interface{}{
+		"rate":           l.rate,
+		"burst":          int(l.burst),
+		"tokens":         tokens,
+		"accepted_total": atomic.LoadUint64(&l.accepted),
+		"rejected_total": atomic.LoadUint64(&l.rejected),
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptLimiterBurstThenRate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	l := NewAcceptLimiter(10, 3, clock)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow(), "burst connection %d", i)
+	}
+	assert.False(t, l.Allow(), "bucket is empty after the burst")
+
+	clock.Advance(100 * time.Millisecond)
+	assert.True(t, l.Allow(), "one token refills every 100ms")
+	assert.False(t, l.Allow())
+
+	clock.Advance(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow())
+	}
+	assert.False(t, l.Allow(), "refill is capped at the burst")
+
+	stats := l.GetStats()
+	assert.Equal(t, uint64(7), stats["accepted_total"])
+	assert.Equal(t, uint64(3), stats["rejected_total"])
+}
+
+func TestAcceptLimiterAllowN(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	l := NewAcceptLimiter(10, 5, clock)
+
+	assert.True(t, l.AllowN(4))
+	assert.False(t, l.AllowN(2), "partial takes are refused")
+	assert.True(t, l.AllowN(1), "a refused take leaves the tokens")
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, l.AllowN(5))
+}
+
+func TestAcceptLimiterDefaults(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	assert.Equal(t, 50, NewAcceptLimiter(50, 0, clock).GetStats()["burst"], "burst defaults to one second of connections")
+	assert.Equal(t, 1, NewAcceptLimiter(0.5, 0, clock).GetStats()["burst"])
+
+	var disabled *AcceptLimiter
+	assert.True(t, disabled.Allow())
+}
+
+func TestServerRejectsAcceptsOverRate(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.AcceptRate = 0.001
+	config.AcceptBurst = 1
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	first, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+
+	second, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "connection over the accept rate is closed")
+
+	first.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = first.Read(make([]byte, 1))
+	var ne net.Error
+	require.ErrorAs(t, err, &ne)
+	assert.True(t, ne.Timeout(), "connection within the burst stays open")
+
+	stats := server.GetStats()["accept_limiter"].(map[string]interface{})
+	assert.Equal(t, uint64(1), stats["rejected_total"])
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// banRequest is the body accepted by POST /admin/bans.
+type banRequest struct {
+	IP     string `json:"ip"`
+	TTL    string `json:"ttl,omitempty"` // Go duration, e.g. "10m"; empty means permanent
+	Reason string `json:"reason,omitempty"`
+}
+
+// drainRequest is the body accepted by POST /admin/drain.
+type drainRequest struct {
+	Percent     float64 `json:"percent,omitempty"`      // Default 100
+	StepPercent float64 `json:"step_percent,omitempty"` // Default all at once
+	Interval    string  `json:"interval,omitempty"`     // Go duration; default 1m
+	Class       string  `json:"class,omitempty"`        // Priority class; empty matches all
+	Grace       string  `json:"grace,omitempty"`        // Go duration; default 2s
+}
+
+// broadcastRequest is the body accepted by POST /admin/broadcast.
+type broadcastRequest struct {
+	Notice
+	Filter BroadcastFilter `json:"filter"`
+}
+
+// registerAdminRoutes mounts admin endpoints on mux when an admin token is configured.
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+	if s.config.AdminToken == "" {
+		return
+	}
+	mux.Handle("/admin/bans", s.requireAdmin(http.HandlerFunc(s.handleAdminBans)))
+	mux.Handle("/admin/drain", s.requireAdmin(http.HandlerFunc(s.handleAdminDrain)))
+	mux.Handle("/admin/usage", s.requireAdmin(http.HandlerFunc(s.handleAdminUsage)))
+	mux.Handle("/admin/broadcast", s.requireAdmin(http.HandlerFunc(s.handleAdminBroadcast)))
+	mux.Handle("/admin/cluster", s.requireAdmin(http.HandlerFunc(s.handleAdminCluster)))
+}
+
+// requireAdmin rejects requests without the configured bearer token.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAdminBans lists (GET), adds (POST), and removes (DELETE ?ip=) runtime bans.
+func (s *Server) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	if s.ipFilter == nil {
+		http.Error(w, "IP filter not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.ipFilter.Bans())
+
+	case http.MethodPost:
+		var req banRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		ip := net.ParseIP(strings.TrimSpace(req.IP))
+		if ip == nil {
+			http.Error(w, "invalid ip", http.StatusBadRequest)
+			return
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			d, err := time.ParseDuration(req.TTL)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+		ban, err := s.ipFilter.Ban(ip, ttl, req.Reason)
+		if err != nil {
+			s.logger.Error("failed to persist ban list", "error", err)
+		}
+		s.logger.Warn("IP banned via admin API", "ip", ban.IP, "ttl", ttl, "reason", req.Reason)
+		writeJSON(w, http.StatusCreated, ban)
+
+	case http.MethodDelete:
+		ip := net.ParseIP(strings.TrimSpace(r.URL.Query().Get("ip")))
+		if ip == nil {
+			http.Error(w, "invalid ip", http.StatusBadRequest)
+			return
+		}
+		removed, err := s.ipFilter.Unban(ip)
+		if err != nil {
+			s.logger.Error("failed to persist ban list", "error", err)
+		}
+		if !removed {
+			http.Error(w, "ban not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Info("IP unbanned via admin API", "ip", ip.String())
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminDrain reports (GET), starts (POST) and cancels (DELETE) a cohort drain.
+func (s *Server) handleAdminDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		status, ok := s.DrainStatus()
+		if !ok {
+			http.Error(w, "no drain has run", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+
+	case http.MethodPost:
+		req := drainRequest{Percent: 100, Interval: "1m", Grace: "2s"}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		plan := DrainPlan{Percent: req.Percent, StepPercent: req.StepPercent}
+		var err error
+		if plan.Interval, err = time.ParseDuration(req.Interval); err != nil {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+		if plan.Grace, err = time.ParseDuration(req.Grace); err != nil {
+			http.Error(w, "invalid grace", http.StatusBadRequest)
+			return
+		}
+		if req.Class != "" {
+			if plan.Class, err = ParsePriorityClass(req.Class); err != nil {
+				http.Error(w, "invalid class", http.StatusBadRequest)
+				return
+			}
+		}
+		status, err := s.StartDrain(plan)
+		switch {
+		case errors.Is(err, ErrDrainActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, status)
+
+	case http.MethodDelete:
+		if !s.CancelDrain() {
+			http.Error(w, "no drain in progress", http.StatusNotFound)
+			return
+		}
+		s.logger.Info("cohort drain cancelled via admin API")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminUsage lists (GET) closed usage records, optionally filtered by
+// ?user= and ?since= (RFC 3339).
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.usage == nil {
+		http.Error(w, "usage accounting disabled", http.StatusNotFound)
+		return
+	}
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	writeJSON(w, http.StatusOK, s.usage.Records(since, r.URL.Query().Get("user")))
+}
+
+// handleAdminCluster reports every instance known by gossip, with fleet-wide
+// connection totals.
+func (s *Server) handleAdminCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cluster == nil {
+		http.Error(w, "cluster membership disabled", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.cluster.Summary())
+}
+
+// handleAdminBroadcast pushes a notice (POST) to the connections matching
+// its filter.
+func (s *Server) handleAdminBroadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req broadcastRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16384)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	sent, err := s.Broadcast(req.Notice, req.Filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"sent": sent})
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
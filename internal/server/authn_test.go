@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
+)
+
+func TestServerUsesCustomAuthenticator(t *testing.T) {
+	metas := make(chan authn.ConnMeta, 3)
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.Authenticator = authn.AuthenticatorFunc(func(ctx context.Context, meta authn.ConnMeta, req authn.Request) (authn.Session, error) {
+		metas <- meta
+		_, hasDeadline := ctx.Deadline()
+		switch {
+		case !hasDeadline:
+			return authn.Session{}, errors.New("no auth deadline")
+		case req.Password == "token":
+			return authn.Session{Username: "ldap:" + req.Username, Attributes: map[string]string{"group": "traders"}}, nil
+		case req.Password == "down":
+			return authn.Session{}, authn.ErrUnavailable
+		}
+		return authn.Session{}, authn.ErrInvalidCredentials
+	})
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	authenticate := func(password string) *protocol.Frame {
+		// Stay under the per-IP connection burst limit
+		time.Sleep(150 * time.Millisecond)
+		client, err := net.Dial("tcp", server.listener.Addr().String())
+		require.NoError(t, err)
+		defer client.Close()
+		client.SetDeadline(time.Now().Add(2 * time.Second))
+
+		frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "alice", Password: password, ClientId: "c1"})
+		require.NoError(t, err)
+		require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+		resp, err := protocol.NewFrameReader(client, protocol.DefaultMaxMessageSize).ReadFrame()
+		require.NoError(t, err)
+
+		meta := <-metas
+		assert.Equal(t, client.LocalAddr().String(), meta.RemoteAddr)
+		assert.Equal(t, client.RemoteAddr().String(), meta.LocalAddr)
+		return resp
+	}
+	errorCode := func(frame *protocol.Frame) pb.ErrorCode {
+		require.Equal(t, protocol.MessageTypeError, frame.Type)
+		var resp pb.ErrorResponse
+		require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+		return resp.Code
+	}
+
+	assert.Equal(t, protocol.MessageTypeACK, authenticate("token").Type)
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_INVALID_AUTH, errorCode(authenticate("wrong")))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_SERVER_BUSY, errorCode(authenticate("down")))
+
+	stats := server.GetStats()
+	assert.Equal(t, uint64(1), stats["auth_success"])
+	assert.Equal(t, uint64(1), stats["auth_failures"], "an unavailable backend is not a client failure")
+}
+
+func TestAuthPeerDistinctPerConnection(t *testing.T) {
+	config := DefaultConfig()
+	// net.Pipe connections all report the same remote address
+	first := stalledConnection(t, config)
+	second := stalledConnection(t, config)
+	require.Equal(t, first.RemoteAddr(), second.RemoteAddr())
+
+	assert.NotEqual(t, first.ID(), second.ID())
+	assert.Equal(t, first.authPeer().Addr, second.authPeer().Addr)
+	assert.NotEqual(t, first.authPeer().ConnID, second.authPeer().ConnID)
+}
@@ -3,7 +3,6 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"os"
 	"strconv"
@@ -11,15 +10,11 @@ import (
 	"time"
 )
 
-const (
-	contentTypeJSON = "application/json"
-	contentTypeText = "text/plain"
-)
+const contentTypeJSON = "application/json"
 
 // AutoScalingConfig contains configuration for auto-scaling integration
 type AutoScalingConfig struct {
 	Enabled                bool    `json:"enabled"`
-	MetricsPort           int     `json:"metrics_port"`
 	ScaleUpThreshold      float64 `json:"scale_up_threshold"`
 	ScaleDownThreshold    float64 `json:"scale_down_threshold"`
 	ConnectionsPerInstance int     `json:"connections_per_instance"`
@@ -41,7 +36,8 @@ type AutoScalingMetrics struct {
 	Timestamp              string  `json:"timestamp"`
 }
 
-// initAutoScaling initializes auto-scaling support
+// initAutoScaling initializes auto-scaling support. Its endpoints are served
+// by the ops server.
 func (s *Server) initAutoScaling() {
 	config := s.getAutoScalingConfig()
 	if !config.Enabled {
@@ -49,16 +45,12 @@ func (s *Server) initAutoScaling() {
 	}
 
 	s.logger.Info("initializing auto-scaling support", "config", config)
-	
-	// Start metrics server for HPA custom metrics
-	go s.startAutoScalingMetricsServer(config.MetricsPort)
 }
 
 // getAutoScalingConfig loads auto-scaling configuration from environment
 func (s *Server) getAutoScalingConfig() AutoScalingConfig {
 	config := AutoScalingConfig{
 		Enabled:                getEnvBool("AUTOSCALING_ENABLED", false),
-		MetricsPort:           getEnvInt("AUTOSCALING_METRICS_PORT", 9090),
 		ScaleUpThreshold:      getEnvFloat("AUTOSCALING_SCALE_UP_THRESHOLD", 0.8),
 		ScaleDownThreshold:    getEnvFloat("AUTOSCALING_SCALE_DOWN_THRESHOLD", 0.3),
 		ConnectionsPerInstance: getEnvInt("AUTOSCALING_CONNECTIONS_PER_INSTANCE", 80000),
@@ -69,63 +61,6 @@ func (s *Server) getAutoScalingConfig() AutoScalingConfig {
 	return config
 }
 
-// startAutoScalingMetricsServer starts HTTP server for auto-scaling metrics
-func (s *Server) startAutoScalingMetricsServer(port int) {
-	mux := http.NewServeMux()
-	
-	// Prometheus-style metrics endpoint
-	mux.HandleFunc("/metrics", s.handlePrometheusMetrics)
-	
-	// Custom metrics for HPA
-	mux.HandleFunc("/autoscaling/metrics", s.handleAutoScalingMetrics)
-	
-	// Scale recommendations endpoint
-	mux.HandleFunc("/autoscaling/recommendations", s.handleScaleRecommendations)
-	
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
-	}
-	
-	s.logger.Info("starting auto-scaling metrics server", "port", port)
-	
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		s.logger.Error("auto-scaling metrics server failed", "error", err)
-	}
-}
-
-// handlePrometheusMetrics serves Prometheus-compatible metrics
-func (s *Server) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", contentTypeJSON)
-	
-	instanceMetrics := s.GetInstanceMetrics()
-	
-	fmt.Fprintf(w, "# HELP tick_storm_active_connections Current number of active connections\n")
-	fmt.Fprintf(w, "# TYPE tick_storm_active_connections gauge\n")
-	fmt.Fprintf(w, "tick_storm_active_connections{instance_id=\"%s\"} %d\n", 
-		s.instanceID, atomic.LoadInt32(&s.activeConns))
-	
-	fmt.Fprintf(w, "# HELP tick_storm_total_connections Total number of connections handled\n")
-	fmt.Fprintf(w, "# TYPE tick_storm_total_connections counter\n")
-	fmt.Fprintf(w, "tick_storm_total_connections{instance_id=\"%s\"} %d\n", 
-		s.instanceID, atomic.LoadUint64(&s.totalConns))
-	
-	fmt.Fprintf(w, "# HELP tick_storm_memory_usage_bytes Memory usage in bytes\n")
-	fmt.Fprintf(w, "# TYPE tick_storm_memory_usage_bytes gauge\n")
-	fmt.Fprintf(w, "tick_storm_memory_usage_bytes{instance_id=\"%s\"} %d\n", 
-		s.instanceID, int64(instanceMetrics["memory_alloc_mb"].(uint64)*1024*1024))
-	
-	fmt.Fprintf(w, "# HELP tick_storm_goroutines Number of goroutines\n")
-	fmt.Fprintf(w, "# TYPE tick_storm_goroutines gauge\n")
-	fmt.Fprintf(w, "tick_storm_goroutines{instance_id=\"%s\"} %d\n", 
-		s.instanceID, instanceMetrics["goroutines"])
-	
-	fmt.Fprintf(w, "# HELP tick_storm_uptime_seconds Server uptime in seconds\n")
-	fmt.Fprintf(w, "# TYPE tick_storm_uptime_seconds gauge\n")
-	fmt.Fprintf(w, "tick_storm_uptime_seconds{instance_id=\"%s\"} %.2f\n", 
-		s.instanceID, instanceMetrics["uptime_seconds"])
-}
-
 // handleAutoScalingMetrics serves custom metrics for HPA
 func (s *Server) handleAutoScalingMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", contentTypeJSON)
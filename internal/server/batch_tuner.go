@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchTuner adapts the default batch window to fan-out load. Every batch
+// written to a client counts as a flush; when the server-wide flush rate
+// reaches the target the window doubles, so each connection sends fewer,
+// larger batches, and when the rate falls below a quarter of the target the
+// window halves again for lower latency. The gap between the two thresholds
+// keeps the window from flapping, since doubling it roughly halves the rate.
+type BatchTuner struct {
+	min, max   time.Duration
+	targetRate float64 // Flushes per second at which the window widens
+	logger     *slog.Logger
+	clock      Clock
+
+	flushes atomic.Uint64
+	window  atomic.Int64 // Current window in nanoseconds
+
+	mu          sync.Mutex
+	lastFlushes uint64
+	lastAdjust  time.Time
+	rate        float64
+	adjustments uint64
+}
+
+// NewBatchTuner creates a tuner starting at initial, clamped to [min, max].
+func NewBatchTuner(initial, min, max time.Duration, targetRate int, clock Clock, logger *slog.Logger) *BatchTuner {
+	if min <= 0 {
+		min = time.Millisecond
+	}
+	if max < min {
+		max = min
+	}
+	if targetRate <= 0 {
+		targetRate = 1
+	}
+	t := &BatchTuner{
+		min:        min,
+		max:        max,
+		targetRate: float64(targetRate),
+		logger:     logger.With("component", "batch_tuner"),
+		clock:      clock,
+		lastAdjust: clock.Now(),
+	}
+	t.window.Store(int64(t.clamp(initial)))
+	return t
+}
+
+// Start adjusts the window every interval until ctx is cancelled.
+func (t *BatchTuner) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := t.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				t.Adjust()
+			}
+		}
+	}()
+}
+
+// Window returns the batch window currently in effect.
+func (t *BatchTuner) Window() time.Duration {
+	return time.Duration(t.window.Load())
+}
+
+// RecordFlush counts one batch written to a client.
+func (t *BatchTuner) RecordFlush() {
+	t.flushes.Add(1)
+}
+
+// Adjust measures the flush rate since the last call and moves the window.
+func (t *BatchTuner) Adjust() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	elapsed := now.Sub(t.lastAdjust)
+	if elapsed <= 0 {
+		return
+	}
+	flushes := t.flushes.Load()
+	t.rate = float64(flushes-t.lastFlushes) / elapsed.Seconds()
+	t.lastFlushes, t.lastAdjust = flushes, now
+
+	current := t.Window()
+	next := current
+	switch {
+	case t.rate >= t.targetRate:
+		next = t.clamp(current * 2)
+	case t.rate < t.targetRate/4:
+		next = t.clamp(current / 2)
+	}
+	if next == current {
+		return
+	}
+	t.window.Store(int64(next))
+	t.adjustments++
+	t.logger.Debug("adjusted batch window",
+		"flush_rate", t.rate,
+		"target_rate", t.targetRate,
+		"batch_window", next,
+		"previous_batch_window", current,
+	)
+}
+
+func (t *BatchTuner) clamp(d time.Duration) time.Duration {
+	if d < t.min {
+		return t.min
+	}
+	if d > t.max {
+		return t.max
+	}
+	return d
+}
+
+// GetStats returns the tuner's state for Server.GetStats.
+func (t *BatchTuner) GetStats() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return map[string]interface{}{
+		"batch_window_ms": float64(t.Window()) / float64(time.Millisecond),
+		"min_window_ms":   float64(t.min) / float64(time.Millisecond),
+		"max_window_ms":   float64(t.max) / float64(time.Millisecond),
+		"flush_rate":      t.rate,
+		"target_rate":     t.targetRate,
+		"adjustments":     t.adjustments,
+	}
+}
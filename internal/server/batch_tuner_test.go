@@ -0,0 +1,78 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBatchTuner(clock *FakeClock) *BatchTuner {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewBatchTuner(5*time.Millisecond, time.Millisecond, 20*time.Millisecond, 100, clock, logger)
+}
+
+// flushFor records rate flushes per second over one second and adjusts.
+func flushFor(t *BatchTuner, clock *FakeClock, rate int) {
+	for i := 0; i < rate; i++ {
+		t.RecordFlush()
+	}
+	clock.Advance(time.Second)
+	t.Adjust()
+}
+
+func TestBatchTunerWidensUnderLoad(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	tuner := newTestBatchTuner(clock)
+
+	flushFor(tuner, clock, 150)
+	assert.Equal(t, 10*time.Millisecond, tuner.Window())
+	flushFor(tuner, clock, 100)
+	assert.Equal(t, 20*time.Millisecond, tuner.Window())
+	flushFor(tuner, clock, 500)
+	assert.Equal(t, 20*time.Millisecond, tuner.Window(), "the window stays within the maximum")
+}
+
+func TestBatchTunerHoldsWithinBand(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	tuner := newTestBatchTuner(clock)
+
+	flushFor(tuner, clock, 50)
+	flushFor(tuner, clock, 25)
+	assert.Equal(t, 5*time.Millisecond, tuner.Window())
+	assert.Equal(t, uint64(0), tuner.GetStats()["adjustments"])
+}
+
+func TestBatchTunerTightensWhenIdle(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	tuner := newTestBatchTuner(clock)
+
+	flushFor(tuner, clock, 10)
+	assert.Equal(t, 2500*time.Microsecond, tuner.Window())
+	flushFor(tuner, clock, 0)
+	flushFor(tuner, clock, 0)
+	assert.Equal(t, time.Millisecond, tuner.Window(), "the window stays within the minimum")
+}
+
+func TestBatchSettingsUseTunedWindow(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	tuner := newTestBatchTuner(clock)
+	flushFor(tuner, clock, 150)
+
+	config := DefaultConfig()
+	conn := stalledConnection(t, config)
+	h := &ConnectionHandler{conn: conn, config: config, server: &Server{batchTuner: tuner}}
+
+	window, _ := h.batchSettings(config.BatchWindow, config.MaxBatchSize)
+	assert.Equal(t, 10*time.Millisecond, window)
+
+	// A subscription that asked for its own window keeps it
+	opts, err := ResolveDeliveryOptions(map[string]string{MetadataBatchWindowMS: "50"}, config)
+	require.NoError(t, err)
+	require.NoError(t, conn.SetSubscription(&Subscription{Options: opts}))
+	window, _ = h.batchSettings(config.BatchWindow, config.MaxBatchSize)
+	assert.Equal(t, 50*time.Millisecond, window)
+}
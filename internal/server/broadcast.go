@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// ErrInvalidNotice is returned by Broadcast for a notice clients would
+// reject.
+var ErrInvalidNotice = errors.New("invalid notice")
+
+// Notice is an operational message pushed to clients as an INFO frame with
+// INFO_CODE_NOTICE, such as a maintenance window or a symbol halt.
+type Notice struct {
+	Kind     string            `json:"kind"`               // Sent as metadata kind, e.g. "maintenance"
+	Message  string            `json:"message"`            // Human-readable text
+	Metadata map[string]string `json:"metadata,omitempty"` // Details, e.g. start and end times
+}
+
+// BroadcastFilter selects the connections a notice goes to. Empty fields
+// match every connection; set fields must all match.
+type BroadcastFilter struct {
+	User   string `json:"user,omitempty"`   // Authenticated username
+	Symbol string `json:"symbol,omitempty"` // Subscribed symbol; all-symbol subscriptions match
+	Tenant string `json:"tenant,omitempty"` // Tenant name
+}
+
+// matches reports whether conn is selected by the filter.
+func (f BroadcastFilter) matches(conn *Connection) bool {
+	if f.User != "" {
+		session := conn.Session()
+		if session == nil || session.Username != f.User {
+			return false
+		}
+	}
+	if f.Symbol != "" {
+		sub := conn.GetSubscription()
+		if sub == nil || !sub.wantsSymbol(f.Symbol) {
+			return false
+		}
+	}
+	if f.Tenant != "" {
+		tenant := conn.Tenant()
+		if tenant == nil || tenant.Name() != f.Tenant {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the notice against the limits clients apply to INFO
+// frames.
+func (n Notice) validate() error {
+	if strings.TrimSpace(n.Kind) == "" || len(n.Kind) > protocol.MaxMetadataValLength {
+		return fmt.Errorf("%w: kind must be 1-%d bytes", ErrInvalidNotice, protocol.MaxMetadataValLength)
+	}
+	if strings.TrimSpace(n.Message) == "" || len(n.Message) > protocol.MaxMessageLength {
+		return fmt.Errorf("%w: message must be 1-%d bytes", ErrInvalidNotice, protocol.MaxMessageLength)
+	}
+	if len(n.Metadata) >= protocol.MaxMetadataEntries {
+		return fmt.Errorf("%w: at most %d metadata entries", ErrInvalidNotice, protocol.MaxMetadataEntries-1)
+	}
+	for key, value := range n.Metadata {
+		if key == "" || len(key) > protocol.MaxMetadataKeyLength || len(value) > protocol.MaxMetadataValLength {
+			return fmt.Errorf("%w: metadata %q exceeds key or value limits", ErrInvalidNotice, key)
+		}
+	}
+	return nil
+}
+
+// Broadcast sends notice to every authenticated connection the filter
+// selects, through each connection's write queue, and returns how many it
+// was queued for. Connections whose queue is full or closed are skipped.
+func (s *Server) Broadcast(notice Notice, filter BroadcastFilter) (int, error) {
+	if err := notice.validate(); err != nil {
+		return 0, err
+	}
+	metadata := make(map[string]string, len(notice.Metadata)+1)
+	for key, value := range notice.Metadata {
+		metadata[key] = value
+	}
+	metadata["kind"] = notice.Kind
+
+	conns := s.connections.filter(func(conn *Connection) bool {
+		return conn.IsAuthenticated() && filter.matches(conn)
+	})
+	sent := 0
+	for _, conn := range conns {
+		if err := conn.SendInfo(pb.InfoCode_INFO_CODE_NOTICE, notice.Message, metadata); err != nil {
+			s.logger.Debug("failed to send notice", "connection_id", conn.ID(), "error", err)
+			continue
+		}
+		sent++
+	}
+	s.logger.Info("notice broadcast",
+		"kind", notice.Kind,
+		"user", filter.User,
+		"symbol", filter.Symbol,
+		"tenant", filter.Tenant,
+		"matched", len(conns),
+		"sent", sent,
+	)
+	return sent, nil
+}
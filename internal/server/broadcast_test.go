@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// broadcastTestServer returns drainTestServer's connections a, b and c
+// authenticated as alice, alice and bob; a subscribes to EURUSD and b to all
+// symbols. Connection d never authenticates.
+func broadcastTestServer(t *testing.T) (*Server, func() map[string]*pb.InfoMessage) {
+	t.Helper()
+	srv, _, received := drainTestServer(t, 4)
+	for id, user := range map[string]string{"a": "alice", "b": "alice", "c": "bob"} {
+		conn, ok := srv.connections.get(id)
+		require.True(t, ok)
+		conn.SetAuthenticated(&auth.Session{Username: user, Authenticated: true})
+	}
+	a, _ := srv.connections.get("a")
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	sub.setSymbols([]string{"EURUSD"})
+	require.NoError(t, a.SetSubscription(sub))
+	b, _ := srv.connections.get("b")
+	require.NoError(t, b.SetSubscription(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)))
+	return srv, received
+}
+
+func receivedIDs(t *testing.T, received func() map[string]*pb.InfoMessage, want int) []string {
+	t.Helper()
+	require.Eventually(t, func() bool { return len(received()) == want }, time.Second, time.Millisecond)
+	var ids []string
+	for id, info := range received() {
+		assert.Equal(t, pb.InfoCode_INFO_CODE_NOTICE, info.Code)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestBroadcastFilters(t *testing.T) {
+	notice := Notice{Kind: "symbol_halt", Message: "EURUSD halted", Metadata: map[string]string{"symbol": "EURUSD"}}
+
+	tests := []struct {
+		name   string
+		filter BroadcastFilter
+		want   []string
+	}{
+		{"all authenticated", BroadcastFilter{}, []string{"a", "b", "c"}},
+		{"user", BroadcastFilter{User: "alice"}, []string{"a", "b"}},
+		{"symbol", BroadcastFilter{Symbol: "EURUSD"}, []string{"a", "b"}},
+		{"symbol not subscribed", BroadcastFilter{Symbol: "XAUUSD"}, []string{"b"}},
+		{"user and symbol", BroadcastFilter{User: "bob", Symbol: "EURUSD"}, nil},
+		{"tenant without tenants", BroadcastFilter{Tenant: "acme"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, received := broadcastTestServer(t)
+			sent, err := srv.Broadcast(notice, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), sent)
+			assert.ElementsMatch(t, tt.want, receivedIDs(t, received, len(tt.want)))
+			for _, info := range received() {
+				assert.Equal(t, "symbol_halt", info.Metadata["kind"])
+				assert.Equal(t, "EURUSD", info.Metadata["symbol"])
+			}
+		})
+	}
+}
+
+func TestBroadcastRejectsInvalidNotice(t *testing.T) {
+	srv, _ := broadcastTestServer(t)
+	for _, notice := range []Notice{
+		{Message: "no kind"},
+		{Kind: "maintenance"},
+		{Kind: "maintenance", Message: strings.Repeat("x", 513)},
+		{Kind: "maintenance", Message: "m", Metadata: map[string]string{"": "empty key"}},
+	} {
+		_, err := srv.Broadcast(notice, BroadcastFilter{})
+		assert.ErrorIs(t, err, ErrInvalidNotice, "%+v", notice)
+	}
+}
+
+func TestAdminBroadcastEndpoint(t *testing.T) {
+	srv, received := broadcastTestServer(t)
+	srv.config.AdminToken = "secret"
+	mux := http.NewServeMux()
+	srv.registerAdminRoutes(mux)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/broadcast", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, `{"message":"no kind"}`).Code)
+	rec := do(http.MethodPost, `{"kind":"maintenance","message":"restart at 22:00 UTC","metadata":{"starts_at":"2026-10-16T22:00:00Z"},"filter":{"user":"bob"}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"sent":1}`, rec.Body.String())
+	assert.Equal(t, []string{"c"}, receivedIDs(t, received, 1))
+	assert.Equal(t, "restart at 22:00 UTC", received()["c"].Message)
+}
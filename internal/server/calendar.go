@@ -0,0 +1,385 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// How out-of-session ticks are handled.
+const (
+	SessionPolicyPause = "pause" // Drop ticks while the session is closed
+	SessionPolicyFlag  = "flag"  // Deliver ticks tagged with session=closed metadata
+)
+
+const (
+	// How often the calendar checks for session opens and closes
+	sessionCheckInterval = time.Second
+	// Symbols whose session lookup is cached
+	sessionSymbolCacheSize = 10000
+)
+
+// TradingSession describes when one symbol group trades.
+type TradingSession struct {
+	Group          string
+	SymbolPrefixes []string       // Symbols in the group; empty matches symbols no other group claims
+	Location       *time.Location // Time zone of Open, Close, Days and Holidays (nil is UTC)
+	Days           []time.Weekday // Days the session opens; empty means every day
+	Open, Close    time.Duration  // Offsets from local midnight; Close <= Open runs past midnight
+	Holidays       []string       // Local dates (YYYY-MM-DD) the session does not open
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseSessionSpecs parses TRADING_SESSIONS entries separated by ';', each of
+// the form "group[,symbols=P|Q][,tz=Zone][,days=mon-fri|sun][,hours=HH:MM-HH:MM]
+// [,holidays=YYYY-MM-DD|...]".
+func parseSessionSpecs(spec string) ([]TradingSession, error) {
+	var sessions []TradingSession
+	for _, raw := range strings.Split(spec, ";") {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ",")
+		ts := TradingSession{Group: strings.TrimSpace(parts[0]), Location: time.UTC, Close: 24 * time.Hour}
+		if ts.Group == "" || strings.Contains(ts.Group, "=") {
+			return nil, fmt.Errorf("invalid trading session %q: expected a group name first", entry)
+		}
+
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "symbols":
+				ts.SymbolPrefixes = strings.Split(value, "|")
+			case "tz":
+				loc, err := time.LoadLocation(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid tz in trading session %q: %w", ts.Group, err)
+				}
+				ts.Location = loc
+			case "days":
+				days, err := parseWeekdays(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid days in trading session %q: %w", ts.Group, err)
+				}
+				ts.Days = days
+			case "hours":
+				from, to, ok := strings.Cut(value, "-")
+				open, err1 := parseClockTime(from)
+				closing, err2 := parseClockTime(to)
+				if !ok || err1 != nil || err2 != nil {
+					return nil, fmt.Errorf("invalid hours in trading session %q: expected HH:MM-HH:MM", ts.Group)
+				}
+				ts.Open, ts.Close = open, closing
+			case "holidays":
+				for _, day := range strings.Split(value, "|") {
+					if _, err := time.Parse(time.DateOnly, day); err != nil {
+						return nil, fmt.Errorf("invalid holiday %q in trading session %q", day, ts.Group)
+					}
+					ts.Holidays = append(ts.Holidays, day)
+				}
+			default:
+				return nil, fmt.Errorf("unknown option %q in trading session %q", key, ts.Group)
+			}
+		}
+		sessions = append(sessions, ts)
+	}
+	return sessions, nil
+}
+
+// parseWeekdays parses '|'-separated day names and ranges such as "mon-fri".
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, item := range strings.Split(strings.ToLower(value), "|") {
+		from, to, isRange := strings.Cut(item, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return nil, fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClockTime parses HH:MM into an offset from midnight; 24:00 is allowed.
+func parseClockTime(value string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(value, "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("time %q out of range", value)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// tradingSession is a TradingSession prepared for lookups.
+type tradingSession struct {
+	TradingSession
+	days     map[time.Weekday]bool // nil means every day
+	holidays map[string]bool
+
+	open atomic.Bool // State last seen by the monitor
+}
+
+// tradesOn reports whether the session opens on the local date of day.
+func (s *tradingSession) tradesOn(day time.Time) bool {
+	if s.days != nil && !s.days[day.Weekday()] {
+		return false
+	}
+	return !s.holidays[day.Format(time.DateOnly)]
+}
+
+// isOpen reports whether the session is open at t.
+func (s *tradingSession) isOpen(t time.Time) bool {
+	local := t.In(s.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.Location)
+	offset := local.Sub(midnight)
+	if s.Open < s.Close {
+		return s.tradesOn(local) && offset >= s.Open && offset < s.Close
+	}
+	// Overnight session: opened today, or opened yesterday and not yet closed
+	if offset >= s.Open {
+		return s.tradesOn(local)
+	}
+	return offset < s.Close && s.tradesOn(midnight.AddDate(0, 0, -1))
+}
+
+// TradingCalendar gates tick delivery on per-group trading sessions.
+// Symbols outside every group trade around the clock.
+type TradingCalendar struct {
+	policy   string
+	sessions []*tradingSession
+	fallback *tradingSession // Group without symbol prefixes, if any
+
+	mu       sync.RWMutex
+	bySymbol map[string]*tradingSession // Lookup cache; nil entries trade around the clock
+
+	paused      atomic.Uint64
+	flagged     atomic.Uint64
+	transitions atomic.Uint64
+}
+
+// NewTradingCalendar prepares sessions for lookups under policy.
+func NewTradingCalendar(sessions []TradingSession, policy string, now time.Time) (*TradingCalendar, error) {
+	switch policy {
+	case SessionPolicyPause, SessionPolicyFlag:
+	default:
+		return nil, fmt.Errorf("unknown session policy %q", policy)
+	}
+
+	c := &TradingCalendar{policy: policy, bySymbol: make(map[string]*tradingSession)}
+	groups := make(map[string]bool, len(sessions))
+	for _, ts := range sessions {
+		if groups[ts.Group] {
+			return nil, fmt.Errorf("duplicate trading session %q", ts.Group)
+		}
+		groups[ts.Group] = true
+		if ts.Location == nil {
+			ts.Location = time.UTC
+		}
+
+		s := &tradingSession{TradingSession: ts, holidays: make(map[string]bool, len(ts.Holidays))}
+		if len(ts.Days) > 0 {
+			s.days = make(map[time.Weekday]bool, len(ts.Days))
+			for _, day := range ts.Days {
+				s.days[day] = true
+			}
+		}
+		for _, day := range ts.Holidays {
+			s.holidays[day] = true
+		}
+		if len(ts.SymbolPrefixes) == 0 {
+			if c.fallback != nil {
+				return nil, fmt.Errorf("trading sessions %q and %q both match all symbols", c.fallback.Group, ts.Group)
+			}
+			c.fallback = s
+		}
+		s.open.Store(s.isOpen(now))
+		c.sessions = append(c.sessions, s)
+	}
+	return c, nil
+}
+
+// sessionFor returns the session of the group claiming symbol by its longest
+// prefix, or nil when symbol trades around the clock.
+func (c *TradingCalendar) sessionFor(symbol string) *tradingSession {
+	c.mu.RLock()
+	s, ok := c.bySymbol[symbol]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = c.fallback
+	longest := -1
+	for _, candidate := range c.sessions {
+		for _, prefix := range candidate.SymbolPrefixes {
+			if len(prefix) > longest && strings.HasPrefix(symbol, prefix) {
+				s, longest = candidate, len(prefix)
+			}
+		}
+	}
+	c.mu.Lock()
+	// Bound the cache for sources that invent symbols
+	if len(c.bySymbol) < sessionSymbolCacheSize {
+		c.bySymbol[symbol] = s
+	}
+	c.mu.Unlock()
+	return s
+}
+
+// IsOpen reports whether symbol's session is open at t.
+func (c *TradingCalendar) IsOpen(symbol string, t time.Time) bool {
+	s := c.sessionFor(symbol)
+	return s == nil || s.isOpen(t)
+}
+
+// Gate applies the session policy to ticks generated at now: out-of-session
+// ticks are dropped under pause, or copied and tagged under flag.
+func (c *TradingCalendar) Gate(ticks []*pb.Tick, now time.Time) []*pb.Tick {
+	var gated []*pb.Tick
+	for i, tick := range ticks {
+		if c.IsOpen(tick.Symbol, now) {
+			if gated != nil {
+				gated = append(gated, tick)
+			}
+			continue
+		}
+		if gated == nil {
+			gated = append(make([]*pb.Tick, 0, len(ticks)), ticks[:i]...)
+		}
+		if c.policy == SessionPolicyPause {
+			c.paused.Add(1)
+			continue
+		}
+		c.flagged.Add(1)
+		// Sources may share ticks between subscriptions
+		flagged := proto.Clone(tick).(*pb.Tick)
+		if flagged.Metadata == nil {
+			flagged.Metadata = make(map[string]string, 1)
+		}
+		flagged.Metadata["session"] = "closed"
+		gated = append(gated, flagged)
+	}
+	if gated == nil {
+		return ticks
+	}
+	return gated
+}
+
+// Start checks sessions every sessionCheckInterval until ctx is done, calling
+// notify for each session that opens or closes.
+func (c *TradingCalendar) Start(ctx context.Context, clock Clock, notify func(group string, open bool)) {
+	go func() {
+		ticker := clock.NewTicker(sessionCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				c.checkTransitions(clock.Now(), notify)
+			}
+		}
+	}()
+}
+
+// checkTransitions calls notify for sessions whose state changed since the
+// previous check.
+func (c *TradingCalendar) checkTransitions(now time.Time, notify func(group string, open bool)) {
+	for _, s := range c.sessions {
+		open := s.isOpen(now)
+		if s.open.Swap(open) != open {
+			c.transitions.Add(1)
+			notify(s.Group, open)
+		}
+	}
+}
+
+// coversGroup reports whether a subscription to symbols (empty meaning all)
+// receives ticks from group.
+func (c *TradingCalendar) coversGroup(symbols []string, group string) bool {
+	if len(symbols) == 0 {
+		return true
+	}
+	for _, symbol := range symbols {
+		if s := c.sessionFor(symbol); s != nil && s.Group == group {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStats returns session states and gating counters.
+func (c *TradingCalendar) GetStats() map[string]interface{} {
+	sessions := make(map[string]bool, len(c.sessions))
+	for _, s := range c.sessions {
+		sessions[s.Group] = s.open.Load()
+	}
+	return map[string]interface{}{
+		"policy":            c.policy,
+		"sessions_open":     sessions,
+		"paused_ticks":      c.paused.Load(),
+		"flagged_ticks":     c.flagged.Load(),
+		"transitions_total": c.transitions.Load(),
+	}
+}
+
+// notifySessionChange tells subscribed connections receiving group's symbols
+// that its session opened or closed.
+func (s *Server) notifySessionChange(group string, open bool) {
+	code, message := pb.InfoCode_INFO_CODE_SESSION_CLOSED, "trading session closed"
+	if open {
+		code, message = pb.InfoCode_INFO_CODE_SESSION_OPEN, "trading session opened"
+	}
+	s.logger.Info(message, "group", group)
+
+	conns := s.connections.filter(func(conn *Connection) bool {
+		sub := conn.GetSubscription()
+		return sub != nil && s.calendar.coversGroup(sub.CurrentSymbols(), group)
+	})
+
+	metadata := map[string]string{"group": group}
+	for _, conn := range conns {
+		if err := conn.SendInfo(code, message, metadata); err != nil {
+			s.logger.Debug("failed to send session status", "group", group, "error", err)
+		}
+	}
+}
+
+// logSessions reports the initial state of each trading session.
+func (c *TradingCalendar) logSessions(logger *slog.Logger) {
+	for _, s := range c.sessions {
+		logger.Info("trading session configured",
+			"group", s.Group,
+			"symbols", s.SymbolPrefixes,
+			"tz", s.Location.String(),
+			"open", s.open.Load(),
+		)
+	}
+}
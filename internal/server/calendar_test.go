@@ -0,0 +1,129 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestParseSessionSpecs(t *testing.T) {
+	sessions, err := parseSessionSpecs("equities,symbols=EQ.|ETF.,tz=America/New_York,days=mon-fri,hours=09:30-16:00,holidays=2026-12-25; fx,symbols=FX.,days=sun-fri,hours=22:00-22:00")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	eq := sessions[0]
+	assert.Equal(t, "equities", eq.Group)
+	assert.Equal(t, []string{"EQ.", "ETF."}, eq.SymbolPrefixes)
+	assert.Equal(t, "America/New_York", eq.Location.String())
+	assert.Equal(t, []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, eq.Days)
+	assert.Equal(t, 9*time.Hour+30*time.Minute, eq.Open)
+	assert.Equal(t, 16*time.Hour, eq.Close)
+	assert.Equal(t, []string{"2026-12-25"}, eq.Holidays)
+	assert.Len(t, sessions[1].Days, 6)
+
+	for _, spec := range []string{
+		"symbols=EQ.",
+		"eq,tz=Mars/Olympus",
+		"eq,days=mon-xyz",
+		"eq,hours=9-16",
+		"eq,hours=09:00-25:00",
+		"eq,holidays=12/25",
+		"eq,color=blue",
+	} {
+		_, err := parseSessionSpecs(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestTradingCalendarIsOpen(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	weekdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	cal, err := NewTradingCalendar([]TradingSession{
+		{Group: "equities", SymbolPrefixes: []string{"EQ."}, Location: ny, Days: weekdays,
+			Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour, Holidays: []string{"2026-12-25"}},
+		{Group: "fx", SymbolPrefixes: []string{"FX."}, Days: weekdays, Open: 22 * time.Hour, Close: 22 * time.Hour},
+	}, SessionPolicyPause, time.Now())
+	require.NoError(t, err)
+
+	at := func(loc *time.Location, value string) time.Time {
+		ts, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		require.NoError(t, err)
+		return ts
+	}
+
+	// Thursday 2026-12-24 and the Christmas holiday after it
+	assert.True(t, cal.IsOpen("EQ.ACME", at(ny, "2026-12-24 10:00")))
+	assert.False(t, cal.IsOpen("EQ.ACME", at(ny, "2026-12-24 09:29")))
+	assert.False(t, cal.IsOpen("EQ.ACME", at(ny, "2026-12-24 16:00")))
+	assert.False(t, cal.IsOpen("EQ.ACME", at(ny, "2026-12-25 10:00")))
+	assert.False(t, cal.IsOpen("EQ.ACME", at(ny, "2026-12-26 10:00")), "Saturday")
+
+	// Overnight session opened Friday 22:00 UTC runs until Saturday 22:00
+	assert.True(t, cal.IsOpen("FX.EURUSD", at(time.UTC, "2026-12-26 21:59")))
+	assert.False(t, cal.IsOpen("FX.EURUSD", at(time.UTC, "2026-12-26 22:00")))
+	assert.False(t, cal.IsOpen("FX.EURUSD", at(time.UTC, "2026-12-27 12:00")), "Sunday")
+	assert.True(t, cal.IsOpen("FX.EURUSD", at(time.UTC, "2026-12-28 23:00")))
+
+	// Symbols outside every group are always open
+	assert.True(t, cal.IsOpen("BTCUSD", at(time.UTC, "2026-12-27 12:00")))
+}
+
+func TestTradingCalendarGate(t *testing.T) {
+	sessions := []TradingSession{{Group: "closed", SymbolPrefixes: []string{"EQ."}, Open: time.Hour, Close: time.Hour + time.Minute}}
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	ticks := []*pb.Tick{{Symbol: "EQ.ACME", Price: 1}, {Symbol: "BTCUSD", Price: 2}}
+
+	pause, err := NewTradingCalendar(sessions, SessionPolicyPause, now)
+	require.NoError(t, err)
+	gated := pause.Gate(ticks, now)
+	require.Len(t, gated, 1)
+	assert.Equal(t, "BTCUSD", gated[0].Symbol)
+
+	flag, err := NewTradingCalendar(sessions, SessionPolicyFlag, now)
+	require.NoError(t, err)
+	gated = flag.Gate(ticks, now)
+	require.Len(t, gated, 2)
+	assert.Equal(t, "closed", gated[0].Metadata["session"])
+	assert.Nil(t, ticks[0].Metadata, "source ticks are not modified")
+	assert.Same(t, ticks[1], gated[1])
+
+	stats := pause.GetStats()
+	assert.Equal(t, uint64(1), stats["paused_ticks"])
+	assert.Equal(t, uint64(1), flag.GetStats()["flagged_ticks"])
+
+	_, err = NewTradingCalendar(sessions, "drop", now)
+	assert.Error(t, err)
+}
+
+func TestTradingCalendarTransitions(t *testing.T) {
+	start := time.Date(2026, 1, 5, 8, 59, 0, 0, time.UTC)
+	cal, err := NewTradingCalendar([]TradingSession{
+		{Group: "equities", SymbolPrefixes: []string{"EQ."}, Open: 9 * time.Hour, Close: 17 * time.Hour},
+		{Group: "crypto"},
+	}, SessionPolicyPause, start)
+	require.NoError(t, err)
+
+	type change struct {
+		group string
+		open  bool
+	}
+	var changes []change
+	notify := func(group string, open bool) { changes = append(changes, change{group, open}) }
+
+	cal.checkTransitions(start.Add(30*time.Second), notify)
+	assert.Empty(t, changes)
+	cal.checkTransitions(start.Add(time.Minute), notify)
+	cal.checkTransitions(start.Add(2*time.Minute), notify)
+	cal.checkTransitions(start.Add(8*time.Hour+time.Minute), notify)
+	assert.Equal(t, []change{{"equities", true}, {"equities", false}}, changes)
+
+	assert.True(t, cal.coversGroup(nil, "equities"))
+	assert.True(t, cal.coversGroup([]string{"EQ.ACME"}, "equities"))
+	assert.False(t, cal.coversGroup([]string{"EQ.ACME"}, "crypto"))
+	assert.True(t, cal.coversGroup([]string{"BTCUSD"}, "crypto"), "unclaimed symbols fall back to the catch-all group")
+}
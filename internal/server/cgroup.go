@@ -0,0 +1,163 @@
+package server
+
+import (
+	"bufio"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ContainerLimits are the memory and CPU limits imposed on the process by its
+// cgroup. Zero values mean no limit was found.
+type ContainerLimits struct {
+	CgroupVersion int     // 1 or 2; 0 when no cgroup limits were readable
+	MemoryBytes   int64   // memory.max / memory.limit_in_bytes
+	CPUs          float64 // CPU quota divided by period
+}
+
+// cgroupV1Unlimited is the smallest value cgroup v1 reports for an unset
+// memory limit (the page-rounded maximum int64).
+const cgroupV1Unlimited = math.MaxInt64 &^ (1<<12 - 1)
+
+// Defaults used when no container limit is detected, and the scale used to
+// derive a goroutine ceiling from the memory limit.
+const (
+	defaultResourceMemoryMB = 1024
+	goroutinesPerMemoryMB   = 50
+	minResourceGoroutines   = 10000
+)
+
+// detectContainerLimits reads limits from the cgroup filesystem mounted at
+// root, for the process whose /proc/<pid>/cgroup content is in procCgroup.
+func detectContainerLimits(root, procCgroup string) ContainerLimits {
+	paths := parseProcCgroup(procCgroup)
+
+	// cgroup v2 has a single unified hierarchy with cgroup.controllers at its root
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		dir := cgroupDir(root, paths[""], "memory.max")
+		limits := ContainerLimits{CgroupVersion: 2}
+		if v, ok := readCgroupValue(filepath.Join(dir, "memory.max")); ok {
+			limits.MemoryBytes = v
+		}
+		if fields := readCgroupFields(filepath.Join(cgroupDir(root, paths[""], "cpu.max"), "cpu.max")); len(fields) == 2 {
+			quota, errQ := strconv.ParseInt(fields[0], 10, 64)
+			period, errP := strconv.ParseInt(fields[1], 10, 64)
+			if errQ == nil && errP == nil && quota > 0 && period > 0 {
+				limits.CPUs = float64(quota) / float64(period)
+			}
+		}
+		return limits
+	}
+
+	var limits ContainerLimits
+	memDir := cgroupDir(filepath.Join(root, "memory"), paths["memory"], "memory.limit_in_bytes")
+	if v, ok := readCgroupValue(filepath.Join(memDir, "memory.limit_in_bytes")); ok {
+		limits.CgroupVersion = 1
+		if v < cgroupV1Unlimited {
+			limits.MemoryBytes = v
+		}
+	}
+	cpuDir := cgroupDir(filepath.Join(root, "cpu"), paths["cpu"], "cpu.cfs_quota_us")
+	quota, okQ := readCgroupValue(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	period, okP := readCgroupValue(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if okQ && okP {
+		limits.CgroupVersion = 1
+		if quota > 0 && period > 0 {
+			limits.CPUs = float64(quota) / float64(period)
+		}
+	}
+	return limits
+}
+
+// parseProcCgroup maps each controller to the process's cgroup path. The
+// cgroup v2 hierarchy is keyed by "".
+func parseProcCgroup(content string) map[string]string {
+	paths := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// cgroupDir returns the process's own cgroup directory under mount when it
+// holds file, falling back to mount itself, as seen inside containers where
+// the cgroup is mounted at the root.
+func cgroupDir(mount, path, file string) string {
+	if path != "" && path != "/" {
+		dir := filepath.Join(mount, path)
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			return dir
+		}
+	}
+	return mount
+}
+
+func readCgroupFields(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+// readCgroupValue reads a single integer; "max" reports no limit.
+func readCgroupValue(path string) (int64, bool) {
+	fields := readCgroupFields(path)
+	if len(fields) != 1 {
+		return 0, false
+	}
+	if fields[0] == "max" {
+		return 0, true
+	}
+	v, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// resourceLimitsFor derives the resource monitor's limits from the container
+// limits, falling back to the built-in defaults where none were found.
+func resourceLimitsFor(container ContainerLimits, maxConnections int) ResourceLimits {
+	memoryMB := int64(defaultResourceMemoryMB)
+	if container.MemoryBytes > 0 {
+		memoryMB = container.MemoryBytes / (1024 * 1024)
+	}
+	return ResourceLimits{
+		MaxMemoryMB:        memoryMB,
+		MaxFileDescriptors: 65536, // 64K file descriptors
+		MaxGoroutines:      max(memoryMB*goroutinesPerMemoryMB, minResourceGoroutines),
+		MaxConnections:     int64(maxConnections),
+		WarningThreshold:   0.8, // 80% warning
+		CriticalThreshold:  0.9, // 90% critical
+	}
+}
+
+// applyContainerCPULimit lowers GOMAXPROCS to the container's CPU quota, which
+// the Go runtime does not detect itself, unless GOMAXPROCS is set explicitly.
+func applyContainerCPULimit(container ContainerLimits, logger *slog.Logger) {
+	if container.CPUs <= 0 || os.Getenv("GOMAXPROCS") != "" {
+		return
+	}
+	procs := max(int(math.Ceil(container.CPUs)), 1)
+	if procs < runtime.GOMAXPROCS(0) {
+		runtime.GOMAXPROCS(procs)
+		logger.Info("set GOMAXPROCS from container CPU quota", "value", procs)
+	}
+}
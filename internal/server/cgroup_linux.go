@@ -0,0 +1,12 @@
+package server
+
+import "os"
+
+// DetectContainerLimits reads the process's cgroup memory and CPU limits.
+func DetectContainerLimits() ContainerLimits {
+	procCgroup, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ContainerLimits{}
+	}
+	return detectContainerLimits("/sys/fs/cgroup", string(procCgroup))
+}
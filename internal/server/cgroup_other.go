@@ -0,0 +1,8 @@
+//go:build !linux
+
+package server
+
+// DetectContainerLimits is unsupported outside Linux and reports no limits.
+func DetectContainerLimits() ContainerLimits {
+	return ContainerLimits{}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCgroupFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestDetectContainerLimitsV2(t *testing.T) {
+	root := t.TempDir()
+	writeCgroupFiles(t, root, map[string]string{
+		"cgroup.controllers":       "cpu memory",
+		"kubepods/pod1/memory.max": "536870912\n",
+		"kubepods/pod1/cpu.max":    "150000 100000\n",
+		"memory.max":               "max\n",
+	})
+
+	limits := detectContainerLimits(root, "0::/kubepods/pod1\n")
+	assert.Equal(t, ContainerLimits{CgroupVersion: 2, MemoryBytes: 512 << 20, CPUs: 1.5}, limits)
+
+	// Inside a container the cgroup is mounted at the root
+	limits = detectContainerLimits(root, "0::/\n")
+	assert.Equal(t, ContainerLimits{CgroupVersion: 2}, limits, "max means unlimited")
+}
+
+func TestDetectContainerLimitsV1(t *testing.T) {
+	root := t.TempDir()
+	writeCgroupFiles(t, root, map[string]string{
+		"memory/memory.limit_in_bytes": "2147483648\n",
+		"cpu/cpu.cfs_quota_us":         "-1\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+	})
+	procCgroup := "4:memory:/docker/abc\n2:cpu,cpuacct:/docker/abc\n0::/\n"
+
+	assert.Equal(t, ContainerLimits{CgroupVersion: 1, MemoryBytes: 2 << 30},
+		detectContainerLimits(root, procCgroup))
+
+	writeCgroupFiles(t, root, map[string]string{
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+	})
+	assert.Equal(t, ContainerLimits{CgroupVersion: 1}, detectContainerLimits(root, procCgroup))
+}
+
+func TestDetectContainerLimitsNone(t *testing.T) {
+	assert.Equal(t, ContainerLimits{}, detectContainerLimits(t.TempDir(), ""))
+}
+
+func TestResourceLimitsFor(t *testing.T) {
+	limits := resourceLimitsFor(ContainerLimits{}, 1000)
+	assert.Equal(t, int64(1024), limits.MaxMemoryMB)
+	assert.Equal(t, int64(51200), limits.MaxGoroutines)
+	assert.Equal(t, int64(1000), limits.MaxConnections)
+
+	limits = resourceLimitsFor(ContainerLimits{MemoryBytes: 4 << 30}, 1000)
+	assert.Equal(t, int64(4096), limits.MaxMemoryMB)
+	assert.Equal(t, int64(4096*goroutinesPerMemoryMB), limits.MaxGoroutines)
+
+	limits = resourceLimitsFor(ContainerLimits{MemoryBytes: 64 << 20}, 1000)
+	assert.Equal(t, int64(minResourceGoroutines), limits.MaxGoroutines)
+}
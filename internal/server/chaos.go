@@ -0,0 +1,189 @@
+package server
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChaosConfig controls connection-level fault injection. It is intended for
+// staging and resilience testing only and must never be enabled in production.
+type ChaosConfig struct {
+	Enabled bool
+
+	// Percentage (0-100) of accepted connections that are subject to faults
+	ConnectionPercent float64
+
+	// Per-operation fault probabilities (0.0-1.0)
+	WriteDelayProbability float64
+	DropProbability       float64
+	CorruptProbability    float64
+	ReadStallProbability  float64
+
+	// Fault magnitudes
+	WriteDelay time.Duration
+	ReadStall  time.Duration
+
+	// Seed for reproducible runs (0 uses the current time)
+	Seed int64
+}
+
+// DefaultChaosConfig returns a disabled chaos configuration.
+func DefaultChaosConfig() *ChaosConfig {
+	return &ChaosConfig{
+		Enabled:           false,
+		ConnectionPercent: 10,
+		WriteDelay:        200 * time.Millisecond,
+		ReadStall:         2 * time.Second,
+	}
+}
+
+// LoadChaosConfigFromEnv loads chaos configuration from environment variables.
+func LoadChaosConfigFromEnv(cfg *ChaosConfig) {
+	if enabled := os.Getenv("CHAOS_ENABLED"); enabled != "" {
+		cfg.Enabled = strings.ToLower(enabled) == "true"
+	}
+
+	if v := os.Getenv("CHAOS_CONNECTION_PERCENT"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 100 {
+			cfg.ConnectionPercent = f
+		}
+	}
+
+	loadProbability := func(key string, dst *float64) {
+		if v := os.Getenv(key); v != "" {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+				*dst = f
+			}
+		}
+	}
+	loadProbability("CHAOS_WRITE_DELAY_PROBABILITY", &cfg.WriteDelayProbability)
+	loadProbability("CHAOS_DROP_PROBABILITY", &cfg.DropProbability)
+	loadProbability("CHAOS_CORRUPT_PROBABILITY", &cfg.CorruptProbability)
+	loadProbability("CHAOS_READ_STALL_PROBABILITY", &cfg.ReadStallProbability)
+
+	if v := os.Getenv("CHAOS_WRITE_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.WriteDelay = d
+		}
+	}
+	if v := os.Getenv("CHAOS_READ_STALL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.ReadStall = d
+		}
+	}
+	if v := os.Getenv("CHAOS_SEED"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.Seed = n
+		}
+	}
+}
+
+// ChaosInjector selects connections for fault injection and tracks injected faults.
+type ChaosInjector struct {
+	config *ChaosConfig
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	// Metrics
+	connectionsAffected uint64
+	writesDelayed       uint64
+	framesDropped       uint64
+	framesCorrupted     uint64
+	readsStalled        uint64
+}
+
+// NewChaosInjector creates a new chaos injector.
+func NewChaosInjector(config *ChaosConfig) *ChaosInjector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &ChaosInjector{
+		config: config,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// roll returns true with the given probability.
+func (ci *ChaosInjector) roll(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	return ci.rng.Float64() < probability
+}
+
+// Wrap returns conn wrapped with fault injection if it is selected for chaos,
+// or conn unchanged otherwise.
+func (ci *ChaosInjector) Wrap(conn net.Conn) net.Conn {
+	if ci == nil || !ci.config.Enabled || !ci.roll(ci.config.ConnectionPercent/100) {
+		return conn
+	}
+	atomic.AddUint64(&ci.connectionsAffected, 1)
+	return &chaosConn{Conn: conn, injector: ci}
+}
+
+// GetStats returns chaos injection statistics.
+func (ci *ChaosInjector) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":              ci.config.Enabled,
+		"connection_percent":   ci.config.ConnectionPercent,
+		"connections_affected": atomic.LoadUint64(&ci.connectionsAffected),
+		"writes_delayed":       atomic.LoadUint64(&ci.writesDelayed),
+		"frames_dropped":       atomic.LoadUint64(&ci.framesDropped),
+		"frames_corrupted":     atomic.LoadUint64(&ci.framesCorrupted),
+		"reads_stalled":        atomic.LoadUint64(&ci.readsStalled),
+	}
+}
+
+// chaosConn injects faults into reads and writes of the wrapped connection.
+// FrameWriter issues one Write per frame, so write faults apply to whole frames.
+type chaosConn struct {
+	net.Conn
+	injector *ChaosInjector
+}
+
+// Write delays, drops, or corrupts the outgoing frame before writing it.
+func (c *chaosConn) Write(b []byte) (int, error) {
+	ci := c.injector
+	cfg := ci.config
+
+	if ci.roll(cfg.WriteDelayProbability) {
+		atomic.AddUint64(&ci.writesDelayed, 1)
+		time.Sleep(cfg.WriteDelay)
+	}
+
+	if ci.roll(cfg.DropProbability) {
+		atomic.AddUint64(&ci.framesDropped, 1)
+		return len(b), nil
+	}
+
+	if len(b) > 0 && ci.roll(cfg.CorruptProbability) {
+		atomic.AddUint64(&ci.framesCorrupted, 1)
+		corrupted := make([]byte, len(b))
+		copy(corrupted, b)
+		// Flip bits in the trailing CRC so the frame fails checksum validation
+		corrupted[len(corrupted)-1] ^= 0xFF
+		return c.Conn.Write(corrupted)
+	}
+
+	return c.Conn.Write(b)
+}
+
+// Read stalls before reading from the wrapped connection.
+func (c *chaosConn) Read(b []byte) (int, error) {
+	ci := c.injector
+	if ci.roll(ci.config.ReadStallProbability) {
+		atomic.AddUint64(&ci.readsStalled, 1)
+		time.Sleep(ci.config.ReadStall)
+	}
+	return c.Conn.Read(b)
+}
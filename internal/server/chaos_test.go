@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func newChaosPipe(t *testing.T, cfg *ChaosConfig) (net.Conn, net.Conn, *ChaosInjector) {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.ConnectionPercent = 100
+	cfg.Seed = 1
+
+	injector := NewChaosInjector(cfg)
+	serverSide, clientSide := net.Pipe()
+	t.Cleanup(func() {
+		serverSide.Close()
+		clientSide.Close()
+	})
+
+	wrapped := injector.Wrap(serverSide)
+	_, ok := wrapped.(*chaosConn)
+	require.True(t, ok, "connection should be wrapped at 100%%")
+	return wrapped, clientSide, injector
+}
+
+func TestChaosWrapSelection(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer serverSide.Close()
+	defer clientSide.Close()
+
+	disabled := NewChaosInjector(DefaultChaosConfig())
+	assert.Equal(t, serverSide, disabled.Wrap(serverSide))
+
+	cfg := DefaultChaosConfig()
+	cfg.Enabled = true
+	cfg.ConnectionPercent = 0
+	assert.Equal(t, serverSide, NewChaosInjector(cfg).Wrap(serverSide))
+
+	var nilInjector *ChaosInjector
+	assert.Equal(t, serverSide, nilInjector.Wrap(serverSide))
+}
+
+func TestChaosCorruptsChecksum(t *testing.T) {
+	cfg := DefaultChaosConfig()
+	cfg.CorruptProbability = 1
+	wrapped, clientSide, injector := newChaosPipe(t, cfg)
+
+	go func() {
+		_ = protocol.NewFrameWriter(wrapped).WriteFrame(&protocol.Frame{
+			Version: protocol.ProtocolVersion,
+			Type:    protocol.MessageTypeHeartbeat,
+			Payload: []byte("ping"),
+		})
+	}()
+
+	_, err := protocol.NewFrameReader(clientSide, 0).ReadFrame()
+	assert.True(t, errors.Is(err, protocol.ErrInvalidChecksum), "expected checksum error, got %v", err)
+	assert.Equal(t, uint64(1), injector.GetStats()["frames_corrupted"])
+}
+
+func TestChaosDropsFrames(t *testing.T) {
+	cfg := DefaultChaosConfig()
+	cfg.DropProbability = 1
+	wrapped, clientSide, injector := newChaosPipe(t, cfg)
+
+	n, err := wrapped.Write([]byte("dropped"))
+	require.NoError(t, err)
+	assert.Equal(t, len("dropped"), n)
+
+	clientSide.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	buf := make([]byte, 16)
+	_, err = clientSide.Read(buf)
+	assert.Error(t, err, "dropped frame must not reach the peer")
+	assert.Equal(t, uint64(1), injector.GetStats()["frames_dropped"])
+}
+
+func TestChaosStallsReads(t *testing.T) {
+	cfg := DefaultChaosConfig()
+	cfg.ReadStallProbability = 1
+	cfg.ReadStall = 50 * time.Millisecond
+	wrapped, clientSide, injector := newChaosPipe(t, cfg)
+
+	go clientSide.Write([]byte("x"))
+
+	start := time.Now()
+	buf := make([]byte, 1)
+	_, err := wrapped.Read(buf)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), cfg.ReadStall)
+	assert.Equal(t, uint64(1), injector.GetStats()["reads_stalled"])
+}
+
+func TestLoadChaosConfigFromEnv(t *testing.T) {
+	t.Setenv("CHAOS_ENABLED", "true")
+	t.Setenv("CHAOS_CONNECTION_PERCENT", "25")
+	t.Setenv("CHAOS_DROP_PROBABILITY", "0.5")
+	t.Setenv("CHAOS_CORRUPT_PROBABILITY", "1.5") // out of range, ignored
+	t.Setenv("CHAOS_WRITE_DELAY", "1s")
+	t.Setenv("CHAOS_SEED", "42")
+
+	cfg := DefaultChaosConfig()
+	LoadChaosConfigFromEnv(cfg)
+
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, 25.0, cfg.ConnectionPercent)
+	assert.Equal(t, 0.5, cfg.DropProbability)
+	assert.Equal(t, 0.0, cfg.CorruptProbability)
+	assert.Equal(t, time.Second, cfg.WriteDelay)
+	assert.Equal(t, int64(42), cfg.Seed)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the time source for tick generation, batching, and heartbeats.
+// Production code uses the wall clock; tests can substitute a FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer mirrors the subset of time.Timer used by the server.
+type Timer interface {
+	// C returns the channel the timer fires on; nil for AfterFunc timers.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker mirrors the subset of time.Ticker used by the server.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock returns a Clock backed by the time package.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time        { return r.t.C }
+func (r realTimer) Stop() bool                 { return r.t.Stop() }
+func (r realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// clock returns the configured clock, defaulting to the wall clock.
+func (c *Config) clock() Clock {
+	if c == nil || c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+// FakeClock is a manually advanced Clock for deterministic tests.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// NewFakeClock creates a fake clock starting at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// fakeWaiter backs fake timers and tickers.
+type fakeWaiter struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration // Non-zero for tickers
+	ch     chan time.Time
+	fn     func()
+	active bool
+}
+
+// Now returns the fake current time.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a timer that fires when the clock is advanced past d.
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	return f.schedule(d, 0, make(chan time.Time, 1), nil)
+}
+
+// AfterFunc creates a timer that runs fn when the clock is advanced past d.
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.schedule(d, 0, nil, fn)
+}
+
+// NewTicker creates a ticker that fires every d of advanced time.
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.schedule(d, d, make(chan time.Time, 1), nil)}
+}
+
+func (f *FakeClock) schedule(d, period time.Duration, ch chan time.Time, fn func()) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{clock: f, when: f.now.Add(d), period: period, ch: ch, fn: fn, active: true}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+// Advance moves the clock forward by d, firing due timers and tickers in order.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].when.Before(f.waiters[j].when) })
+		if len(f.waiters) == 0 || f.waiters[0].when.After(target) {
+			break
+		}
+
+		w := f.waiters[0]
+		f.now = w.when
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			w.active = false
+			f.waiters = f.waiters[1:]
+		}
+
+		now := f.now
+		f.mu.Unlock()
+		if w.fn != nil {
+			w.fn()
+		} else {
+			// Like time.Ticker, drop ticks the receiver has not kept up with
+			select {
+			case w.ch <- now:
+			default:
+			}
+		}
+		f.mu.Lock()
+	}
+	f.now = target
+	f.mu.Unlock()
+}
+
+// remove unschedules w; the caller must hold f.mu.
+func (f *FakeClock) remove(w *fakeWaiter) bool {
+	wasActive := w.active
+	w.active = false
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			break
+		}
+	}
+	return wasActive
+}
+
+func (w *fakeWaiter) C() <-chan time.Time { return w.ch }
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+// fakeTicker adapts fakeWaiter to the Ticker interface.
+type fakeTicker struct{ w *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.w.ch }
+func (t fakeTicker) Stop()               { t.w.Stop() }
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	f := w.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wasActive := f.remove(w)
+	w.when = f.now.Add(d)
+	w.active = true
+	f.waiters = append(f.waiters, w)
+	return wasActive
+}
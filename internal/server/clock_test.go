@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+var fakeEpoch = time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC)
+
+func TestFakeClockTimersAndTickers(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+
+	timer := clock.NewTimer(10 * time.Second)
+	ticker := clock.NewTicker(3 * time.Second)
+	fired := 0
+	clock.AfterFunc(5*time.Second, func() { fired++ })
+
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, fakeEpoch.Add(4*time.Second), clock.Now())
+	assert.Equal(t, fakeEpoch.Add(3*time.Second), <-ticker.C())
+	assert.Zero(t, fired)
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, 1, fired)
+	assert.Equal(t, fakeEpoch.Add(6*time.Second), <-ticker.C())
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	// Reset pushes the deadline relative to the current fake time
+	assert.True(t, timer.Reset(10*time.Second))
+	clock.Advance(5 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("reset timer fired early")
+	default:
+	}
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, fakeEpoch.Add(16*time.Second), <-timer.C())
+	assert.False(t, timer.Stop(), "fired timer is no longer active")
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		// At most the single buffered tick from before Stop
+	default:
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker kept firing")
+	default:
+	}
+}
+
+func TestTickGenerationUsesConfiguredClock(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	config := DefaultConfig()
+	config.Clock = clock
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnection(serverSide, config)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &ConnectionHandler{
+		conn:     conn,
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+		dataChan: make(chan []*pb.Tick, 10),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	defer handler.Close()
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE)
+	handler.workers.Add(1)
+	go func() {
+		defer handler.workers.Done()
+		handler.startDataGeneration(sub)
+	}()
+
+	// Wait for the generator to register its ticker, then advance a full minute
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) > 0
+	}, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+
+	select {
+	case ticks := <-handler.dataChan:
+		require.Len(t, ticks, 1)
+		assert.Equal(t, fakeEpoch.Add(time.Minute).UnixMilli(), ticks[0].TimestampMs)
+	case <-time.After(time.Second):
+		t.Fatal("no tick generated after advancing the fake clock")
+	}
+}
+
+func TestPublishSequenceOrdersAcrossConnections(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(fakeEpoch)
+
+	type peer struct {
+		conn   *Connection
+		reader *protocol.FrameReader
+		client net.Conn
+	}
+	peers := make([]peer, 2)
+	for i := range peers {
+		serverSide, clientSide := net.Pipe()
+		peers[i] = peer{
+			conn:   NewConnection(serverSide, config),
+			reader: protocol.NewFrameReader(clientSide, config.MaxMessageSize),
+			client: clientSide,
+		}
+		defer peers[i].conn.Close()
+		defer clientSide.Close()
+	}
+
+	var last uint64
+	for round := 0; round < 3; round++ {
+		for _, p := range peers {
+			go p.conn.SendDataBatch([]*pb.Tick{{Symbol: "EURUSD"}})
+			p.client.SetReadDeadline(time.Now().Add(time.Second))
+			frame, err := p.reader.ReadFrame()
+			require.NoError(t, err)
+
+			var batch pb.DataBatch
+			require.NoError(t, proto.Unmarshal(frame.Payload, &batch))
+			assert.Greater(t, batch.PublishSequence, last, "publish sequence is global and increasing")
+			assert.Equal(t, uint32(round+1), batch.BatchSequence, "batch sequence stays per connection")
+			assert.Equal(t, fakeEpoch.UnixMilli(), batch.BatchTimestampMs)
+			last = batch.PublishSequence
+		}
+	}
+}
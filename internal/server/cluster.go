@@ -0,0 +1,393 @@
+// Package server implements gossip-based peer discovery between instances.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clusterFanout is the number of peers gossiped with each round.
+const clusterFanout = 3
+
+// Member statuses, from how long ago a peer's heartbeat last advanced.
+const (
+	MemberAlive   = "alive"
+	MemberSuspect = "suspect" // Silent for PeerTimeout
+	MemberDead    = "dead"    // Silent for 3x PeerTimeout; no longer gossiped
+)
+
+// ClusterMember is the state an instance gossips about itself. Heartbeat
+// grows every round, so the highest heartbeat seen is the freshest state.
+type ClusterMember struct {
+	ID        string   `json:"id"`
+	Addr      string   `json:"addr"` // Ops server address peers gossip to
+	Version   string   `json:"version"`
+	Heartbeat uint64   `json:"heartbeat"`
+	Load      LBWeight `json:"load"`
+}
+
+// MemberStatus is a member as seen from this instance.
+type MemberStatus struct {
+	ClusterMember
+	Status   string    `json:"status"`
+	Self     bool      `json:"self,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// ClusterSummary is the fleet-wide view served on /admin/cluster.
+type ClusterSummary struct {
+	Self              string         `json:"self"`
+	Members           []MemberStatus `json:"members"`
+	Alive             int            `json:"alive"`
+	Suspect           int            `json:"suspect"`
+	Dead              int            `json:"dead"`
+	Ready             int            `json:"ready"`
+	ActiveConnections int64          `json:"active_connections"` // Across alive and suspect members
+	MaxConnections    int64          `json:"max_connections"`
+}
+
+// ClusterStats are the membership counters exported as metrics.
+type ClusterStats struct {
+	Alive          int
+	Suspect        int
+	Dead           int
+	GossipFailures uint64
+}
+
+// gossipMessage is the body exchanged on /cluster/gossip: each side sends
+// every member it knows of that is not dead, and merges the other's.
+type gossipMessage struct {
+	Members []ClusterMember `json:"members"`
+}
+
+type clusterPeer struct {
+	ClusterMember
+	seen time.Time // When Heartbeat last advanced, by the local clock
+}
+
+// Cluster discovers other instances by gossip over their ops servers. Each
+// round an instance bumps its heartbeat and swaps member lists with a few
+// random peers (or its seeds while it knows none), so state spreads through
+// the fleet in a number of rounds logarithmic in its size.
+type Cluster struct {
+	addr        string
+	seeds       []string
+	secret      string
+	peerTimeout time.Duration
+	clock       Clock
+	client      *http.Client
+	local       func() ClusterMember // Current state of this instance
+
+	mu        sync.Mutex
+	heartbeat uint64
+	peers     map[string]*clusterPeer // By member ID, excluding this instance
+
+	failures atomic.Uint64
+}
+
+// NewCluster creates the membership of an instance reachable at addr. local
+// reports the instance's own state; its heartbeat is filled in by Cluster.
+// Gossip requests carry secret as a bearer token when it is set.
+func NewCluster(addr string, seeds []string, secret string, peerTimeout time.Duration, clock Clock, local func() ClusterMember) *Cluster {
+	if peerTimeout <= 0 {
+		peerTimeout = 10 * time.Second
+	}
+	return &Cluster{
+		addr:        addr,
+		seeds:       seeds,
+		secret:      secret,
+		peerTimeout: peerTimeout,
+		clock:       clock,
+		client:      &http.Client{Timeout: peerTimeout / 2},
+		local:       local,
+		peers:       make(map[string]*clusterPeer),
+	}
+}
+
+// self returns this instance's state with the current heartbeat.
+func (c *Cluster) self() ClusterMember {
+	m := c.local()
+	m.Addr = c.addr
+	c.mu.Lock()
+	m.Heartbeat = c.heartbeat
+	c.mu.Unlock()
+	return m
+}
+
+// status classifies a peer by how long its heartbeat has been still.
+func (c *Cluster) status(p *clusterPeer, now time.Time) string {
+	switch silent := now.Sub(p.seen); {
+	case silent >= 3*c.peerTimeout:
+		return MemberDead
+	case silent >= c.peerTimeout:
+		return MemberSuspect
+	}
+	return MemberAlive
+}
+
+// state returns the members to gossip: this instance and every peer not dead.
+func (c *Cluster) state() gossipMessage {
+	msg := gossipMessage{Members: []ClusterMember{c.self()}}
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.peers {
+		if c.status(p, now) != MemberDead {
+			msg.Members = append(msg.Members, p.ClusterMember)
+		}
+	}
+	return msg
+}
+
+// Merge records the members of a gossip message whose heartbeat is newer
+// than the one known. Dead peers are forgotten after 6x PeerTimeout; by then
+// every instance has stopped gossiping them, so they cannot come back from a
+// stale list.
+func (c *Cluster) Merge(members []ClusterMember) {
+	self := c.local().ID
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range members {
+		if m.ID == "" || m.ID == self {
+			continue
+		}
+		p, ok := c.peers[m.ID]
+		if !ok {
+			c.peers[m.ID] = &clusterPeer{ClusterMember: m, seen: now}
+			continue
+		}
+		if m.Heartbeat > p.Heartbeat {
+			p.ClusterMember, p.seen = m, now
+		}
+	}
+	for id, p := range c.peers {
+		if now.Sub(p.seen) >= 6*c.peerTimeout {
+			delete(c.peers, id)
+		}
+	}
+}
+
+// targets picks whom to gossip with this round: up to clusterFanout random
+// live or suspect peers, or every seed while no peer is known.
+func (c *Cluster) targets() []string {
+	now := c.clock.Now()
+	c.mu.Lock()
+	var addrs []string
+	for _, p := range c.peers {
+		if c.status(p, now) != MemberDead && p.Addr != "" {
+			addrs = append(addrs, p.Addr)
+		}
+	}
+	c.mu.Unlock()
+	if len(addrs) > 0 {
+		rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+		return addrs[:min(clusterFanout, len(addrs))]
+	}
+	var seeds []string
+	for _, seed := range c.seeds {
+		if seed != c.addr {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds
+}
+
+// Gossip runs one round: it bumps the heartbeat and exchanges state with
+// the round's targets.
+func (c *Cluster) Gossip(ctx context.Context) error {
+	c.mu.Lock()
+	c.heartbeat++
+	c.mu.Unlock()
+
+	var errs []string
+	for _, addr := range c.targets() {
+		if err := c.exchange(ctx, addr); err != nil {
+			c.failures.Add(1)
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("gossip failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// exchange sends this instance's state to the peer at addr and merges its reply.
+func (c *Cluster) exchange(ctx context.Context, addr string) error {
+	body, err := json.Marshal(c.state())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+"/cluster/gossip", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, "application/json")
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", addr, resp.StatusCode)
+	}
+	var reply gossipMessage
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&reply); err != nil {
+		return fmt.Errorf("%s: %w", addr, err)
+	}
+	c.Merge(reply.Members)
+	return nil
+}
+
+// ServeHTTP answers a peer's gossip with this instance's state after merging
+// the peer's.
+func (c *Cluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var msg gossipMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&msg); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	c.Merge(msg.Members)
+	writeJSON(w, http.StatusOK, c.state())
+}
+
+// requireSecret rejects requests without the cluster secret, when one is set.
+func (c *Cluster) requireSecret(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if c.secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Addr returns the ops address of the member id, or "" if it is unknown.
+func (c *Cluster) Addr(id string) string {
+	if id == c.local().ID {
+		return c.addr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.peers[id]; ok {
+		return p.Addr
+	}
+	return ""
+}
+
+// Summary returns every known member, this instance first, and fleet totals.
+func (c *Cluster) Summary() ClusterSummary {
+	self := c.self()
+	now := c.clock.Now()
+	sum := ClusterSummary{
+		Self:    self.ID,
+		Members: []MemberStatus{{ClusterMember: self, Status: MemberAlive, Self: true, LastSeen: now}},
+	}
+	c.mu.Lock()
+	peers := make([]MemberStatus, 0, len(c.peers))
+	for _, p := range c.peers {
+		peers = append(peers, MemberStatus{ClusterMember: p.ClusterMember, Status: c.status(p, now), LastSeen: p.seen})
+	}
+	c.mu.Unlock()
+	sort.Slice(peers, func(i, j int) bool { return peers[i].ID < peers[j].ID })
+	sum.Members = append(sum.Members, peers...)
+
+	for _, m := range sum.Members {
+		switch m.Status {
+		case MemberAlive:
+			sum.Alive++
+		case MemberSuspect:
+			sum.Suspect++
+		case MemberDead:
+			sum.Dead++
+			continue
+		}
+		if m.Load.Ready {
+			sum.Ready++
+		}
+		sum.ActiveConnections += int64(m.Load.ActiveConnections)
+		sum.MaxConnections += int64(m.Load.MaxConnections)
+	}
+	return sum
+}
+
+// Stats returns membership counts, this instance included, for metrics.
+func (c *Cluster) Stats() ClusterStats {
+	stats := ClusterStats{Alive: 1, GossipFailures: c.failures.Load()}
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range c.peers {
+		switch c.status(p, now) {
+		case MemberAlive:
+			stats.Alive++
+		case MemberSuspect:
+			stats.Suspect++
+		default:
+			stats.Dead++
+		}
+	}
+	return stats
+}
+
+// GetStats returns cluster statistics.
+func (c *Cluster) GetStats() map[string]interface{} {
+	stats := c.Stats()
+	return map[string]interface{}{
+		"addr":            c.addr,
+		"seeds":           len(c.seeds),
+		"alive":           stats.Alive,
+		"suspect":         stats.Suspect,
+		"dead":            stats.Dead,
+		"gossip_failures": stats.GossipFailures,
+	}
+}
+
+// Start gossips every interval until ctx is done.
+func (c *Cluster) Start(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	go func() {
+		ticker := c.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if err := c.Gossip(ctx); err != nil && ctx.Err() == nil {
+					logger.Debug("cluster gossip round failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// clusterMember reports this instance's state for gossip.
+func (s *Server) clusterMember() ClusterMember {
+	return ClusterMember{
+		ID:      s.instanceID,
+		Version: s.GetVersion(),
+		Load:    s.LBWeight(),
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testNode struct {
+	cluster *Cluster
+	server  *httptest.Server
+	addr    string
+	conns   int32
+}
+
+// startNodes runs n cluster members on httptest servers, each seeded with
+// the first, sharing clock.
+func startNodes(t *testing.T, n int, clock Clock, secret string) []*testNode {
+	t.Helper()
+	nodes := make([]*testNode, n)
+	var seed string
+	for i := range nodes {
+		node := &testNode{}
+		id := string(rune('a' + i))
+		mux := http.NewServeMux()
+		node.server = httptest.NewServer(mux)
+		t.Cleanup(node.server.Close)
+		node.addr = strings.TrimPrefix(node.server.URL, "http://")
+		if i == 0 {
+			seed = node.addr
+		}
+		node.cluster = NewCluster(node.addr, []string{seed}, secret, 10*time.Second, clock, func() ClusterMember {
+			return ClusterMember{ID: id, Version: "test", Load: LBWeight{InstanceID: id, Ready: true, Weight: 50,
+				ActiveConnections: node.conns, MaxConnections: 100}}
+		})
+		mux.Handle("/cluster/gossip", node.cluster.requireSecret(node.cluster))
+		nodes[i] = node
+	}
+	return nodes
+}
+
+func gossipAll(t *testing.T, nodes []*testNode, rounds int) {
+	t.Helper()
+	for r := 0; r < rounds; r++ {
+		for _, node := range nodes {
+			require.NoError(t, node.cluster.Gossip(context.Background()))
+		}
+	}
+}
+
+func memberIDs(sum ClusterSummary) map[string]string {
+	ids := make(map[string]string)
+	for _, m := range sum.Members {
+		ids[m.ID] = m.Status
+	}
+	return ids
+}
+
+func TestClusterDiscoversPeersThroughSeed(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	nodes := startNodes(t, 4, clock, "s3cret")
+	nodes[1].conns, nodes[2].conns, nodes[3].conns = 10, 20, 30
+
+	// The seed learns of every node in the first round; the others learn of
+	// each other from it and from peers in later rounds
+	gossipAll(t, nodes, 4)
+	for _, node := range nodes {
+		sum := node.cluster.Summary()
+		assert.Equal(t, map[string]string{"a": MemberAlive, "b": MemberAlive, "c": MemberAlive, "d": MemberAlive}, memberIDs(sum))
+		assert.True(t, sum.Members[0].Self)
+		assert.Equal(t, 4, sum.Alive)
+		assert.Equal(t, 4, sum.Ready)
+		assert.Equal(t, int64(60), sum.ActiveConnections)
+		assert.Equal(t, int64(400), sum.MaxConnections)
+	}
+
+	// Load changes spread with the heartbeat
+	nodes[3].conns = 40
+	gossipAll(t, nodes, 4)
+	assert.Equal(t, int64(70), nodes[1].cluster.Summary().ActiveConnections)
+}
+
+func TestClusterMarksSilentPeers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	nodes := startNodes(t, 3, clock, "")
+	gossipAll(t, nodes, 3)
+
+	// c goes away; a and b keep exchanging their own heartbeats
+	nodes[2].server.Close()
+	live := nodes[:2]
+	gossipLive := func() {
+		for _, node := range live {
+			// Exchanges with c fail until it is dead
+			_ = node.cluster.Gossip(context.Background())
+		}
+	}
+	clock.Advance(10 * time.Second)
+	gossipLive()
+	assert.Equal(t, MemberSuspect, memberIDs(nodes[0].cluster.Summary())["c"])
+
+	clock.Advance(20 * time.Second)
+	gossipLive()
+	sum := nodes[0].cluster.Summary()
+	assert.Equal(t, MemberDead, memberIDs(sum)["c"])
+	assert.Equal(t, 2, sum.Alive)
+	assert.Equal(t, 1, sum.Dead)
+	assert.Equal(t, int64(200), sum.MaxConnections, "dead members are left out of the totals")
+	assert.Equal(t, 2, nodes[0].cluster.Stats().Alive)
+	assert.Equal(t, 1, nodes[0].cluster.Stats().Dead)
+
+	// Dead members are not gossiped and are eventually forgotten
+	for _, m := range nodes[1].cluster.state().Members {
+		assert.NotEqual(t, "c", m.ID)
+	}
+	clock.Advance(30 * time.Second)
+	gossipAll(t, live, 1)
+	assert.NotContains(t, memberIDs(nodes[0].cluster.Summary()), "c")
+
+	// A restarted member rejoins through the seed
+	require.NoError(t, nodes[2].cluster.Gossip(context.Background()))
+	assert.Equal(t, MemberAlive, memberIDs(nodes[0].cluster.Summary())["c"])
+}
+
+func TestClusterRejectsWrongSecret(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	nodes := startNodes(t, 2, clock, "s3cret")
+	nodes[1].cluster.secret = "guess"
+
+	err := nodes[1].cluster.Gossip(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.Equal(t, 1, nodes[0].cluster.Stats().Alive, "the seed learned nothing")
+	assert.Equal(t, uint64(1), nodes[1].cluster.Stats().GossipFailures)
+}
+
+func TestAdminClusterEndpoint(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminToken = "admin"
+	srv := NewServer(config)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/cluster", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		srv.opsHandler().ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusNotFound, get().Code, "disabled without CLUSTER_ADVERTISE_ADDR")
+
+	srv.cluster = NewCluster("10.0.0.1:9090", nil, "", time.Second, config.clock(), srv.clusterMember)
+	rec := get()
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"self":"`+srv.GetInstanceID()+`"`)
+	assert.Contains(t, rec.Body.String(), `"addr":"10.0.0.1:9090"`)
+	assert.Contains(t, rec.Body.String(), `"affinity_token":"`+srv.AffinityToken()+`"`)
+}
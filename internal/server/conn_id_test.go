@@ -0,0 +1,24 @@
+package server
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var uuidV7 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewConnectionID(t *testing.T) {
+	seen := make(map[string]bool)
+	prev := ""
+	for i := 0; i < 10000; i++ {
+		id := newConnectionID()
+		assert.Regexp(t, uuidV7, id)
+		assert.False(t, seen[id], "duplicate connection ID %s", id)
+		seen[id] = true
+		// The leading 48 bits are the timestamp, so IDs never sort backwards
+		assert.GreaterOrEqual(t, id[:13], prev, "IDs must sort by creation time")
+		prev = id[:13]
+	}
+}
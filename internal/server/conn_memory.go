@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"sync/atomic"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// approxTickBytes is the approximate size of one tick, used wherever memory
+// or traffic is estimated without encoding the tick.
+const approxTickBytes = 64
+
+// ErrMemoryBudgetExceeded is returned when a write would take a connection
+// past its memory budget. The client is treated as slow and disconnected.
+var ErrMemoryBudgetExceeded = errors.New("connection memory budget exceeded - slow client detected")
+
+// ConnMemory is the approximate memory held on behalf of one connection.
+type ConnMemory struct {
+	WriteQueue   int64 // Encoded frames waiting to be written
+	PendingBatch int64 // Ticks collected for the next batch
+	History      int64 // Sent batches retained for gap-fill
+}
+
+// Total returns the sum of all components.
+func (m ConnMemory) Total() int64 {
+	return m.WriteQueue + m.PendingBatch + m.History
+}
+
+// Memory returns the connection's current memory estimate.
+func (c *Connection) Memory() ConnMemory {
+	m := ConnMemory{
+		WriteQueue:   atomic.LoadInt64(&c.queuedBytes),
+		PendingBatch: atomic.LoadInt64(&c.pendingTicks) * approxTickBytes,
+	}
+	if c.history != nil {
+		m.History = c.history.Bytes()
+	}
+	return m
+}
+
+// setPendingTicks records the size of the batch being collected.
+func (c *Connection) setPendingTicks(n int) {
+	atomic.StoreInt64(&c.pendingTicks, int64(n))
+}
+
+// overMemoryBudget reports whether holding extra more bytes would exceed
+// Config.MaxConnMemoryBytes.
+func (c *Connection) overMemoryBudget(extra int64) bool {
+	budget := c.config.MaxConnMemoryBytes
+	return budget > 0 && c.Memory().Total()+extra > budget
+}
+
+// enqueued charges a queued frame to the connection.
+func (c *Connection) enqueued(item *WriteQueueItem) {
+	c.raiseHighWater(atomic.AddInt32(&c.writeQueueLen, 1))
+	atomic.AddInt64(&c.queuedBytes, item.size)
+}
+
+// unqueued releases a frame that never reached the write loop.
+func (c *Connection) unqueued(item *WriteQueueItem) {
+	atomic.AddInt32(&c.writeQueueLen, -1)
+	atomic.AddInt64(&c.queuedBytes, -item.size)
+}
+
+// dequeued releases a frame the write loop has finished with.
+func (c *Connection) dequeued(item *WriteQueueItem) {
+	c.pools.PutFrame(item.frame)
+	c.unqueued(item)
+}
+
+func frameWireSize(frame *protocol.Frame) int64 {
+	return int64(protocol.FrameHeaderSize + len(frame.Payload) + protocol.CRCSize)
+}
+
+// ConnMemoryStats aggregates memory across connections.
+type ConnMemoryStats struct {
+	Connections    int
+	TotalBytes     int64
+	P99Bytes       int64
+	MaxBytes       int64
+	BudgetExceeded uint64 // Connections dropped for exceeding the budget
+}
+
+// connMemoryStats returns memory use across all registered connections.
+func (s *Server) connMemoryStats() ConnMemoryStats {
+	var totals []int64
+	s.connections.each(func(conn *Connection) {
+		totals = append(totals, conn.Memory().Total())
+	})
+
+	stats := ConnMemoryStats{
+		Connections:    len(totals),
+		BudgetExceeded: atomic.LoadUint64(&s.memoryBudgetExceeded),
+	}
+	if len(totals) == 0 {
+		return stats
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	for _, t := range totals {
+		stats.TotalBytes += t
+	}
+	stats.P99Bytes = totals[(len(totals)*99-1)/100]
+	stats.MaxBytes = totals[len(totals)-1]
+	return stats
+}
+
+// GetStats returns the stats as a map for Server.GetStats.
+func (st ConnMemoryStats) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"connections":     st.Connections,
+		"total_bytes":     st.TotalBytes,
+		"p99_bytes":       st.P99Bytes,
+		"max_bytes":       st.MaxBytes,
+		"budget_exceeded": st.BudgetExceeded,
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// stalledConnection returns a connection whose peer never reads, so written
+// frames stay in the write queue.
+func stalledConnection(t *testing.T, config *Config) *Connection {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	t.Cleanup(func() {
+		clientSide.Close()
+		conn.Close()
+	})
+	return conn
+}
+
+func testFrame(payloadSize int) *protocol.Frame {
+	return &protocol.Frame{Type: protocol.MessageTypeDataBatch, Payload: make([]byte, payloadSize)}
+}
+
+func TestConnectionMemoryTracksWriteQueue(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnMemoryBytes = 0
+	conn := stalledConnection(t, config)
+
+	// Frames stay charged while the write loop is blocked on the first one
+	require.NoError(t, conn.WriteFrameAsync(testFrame(100)))
+	require.NoError(t, conn.WriteFrameAsync(testFrame(1000)))
+	require.NoError(t, conn.WriteFrameAsync(testFrame(1000)))
+	assert.Equal(t, frameWireSize(testFrame(100))+2*frameWireSize(testFrame(1000)), conn.Memory().WriteQueue)
+
+	conn.setPendingTicks(10)
+	assert.Equal(t, int64(10*approxTickBytes), conn.Memory().PendingBatch)
+	assert.Equal(t, conn.Memory().WriteQueue+10*approxTickBytes, conn.Memory().Total())
+}
+
+func TestConnectionMemoryBudgetRefusesWrites(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnMemoryBytes = 3000
+	conn := stalledConnection(t, config)
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = conn.WriteFrameAsync(testFrame(1000))
+	}
+	assert.ErrorIs(t, err, ErrMemoryBudgetExceeded)
+	assert.LessOrEqual(t, conn.Memory().Total(), config.MaxConnMemoryBytes)
+}
+
+func TestDeliveryDisconnectsConnectionOverBudget(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnMemoryBytes = 10 * approxTickBytes
+	config.BatchWindow = time.Hour
+	config.MaxBatchSize = 1000
+	conn := stalledConnection(t, config)
+	require.NoError(t, conn.SetSubscription(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)))
+
+	srv := &Server{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &ConnectionHandler{
+		conn:       conn,
+		config:     config,
+		ctx:        ctx,
+		cancel:     cancel,
+		dataChan:   make(chan []*pb.Tick, 10),
+		batchTimer: config.clock().NewTimer(time.Hour),
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		server:     srv,
+	}
+	errChan := make(chan error, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.deliveryLoop(ctx, errChan)
+	}()
+
+	ticks := make([]*pb.Tick, 6)
+	for i := range ticks {
+		ticks[i] = &pb.Tick{Symbol: "EURUSD", Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	}
+	handler.dataChan <- ticks
+	handler.dataChan <- ticks
+
+	select {
+	case err := <-errChan:
+		assert.ErrorIs(t, err, ErrMemoryBudgetExceeded)
+		assert.True(t, isSlowConsumer(err), "reported to the client as SLOW_CONSUMER")
+	case <-time.After(time.Second):
+		t.Fatal("connection over its memory budget was not disconnected")
+	}
+	<-done
+	assert.Equal(t, uint64(1), srv.memoryBudgetExceeded)
+}
+
+func TestBatchHistoryBytes(t *testing.T) {
+	h := NewBatchHistory(2)
+	batch := func(n int) *pb.DataBatch { return &pb.DataBatch{Ticks: make([]*pb.Tick, n)} }
+
+	h.Record(batch(3))
+	h.Record(batch(5))
+	assert.Equal(t, int64(8*approxTickBytes), h.Bytes())
+
+	h.Record(batch(1)) // Overwrites the 3-tick batch
+	assert.Equal(t, int64(6*approxTickBytes), h.Bytes())
+}
+
+func TestConnMemoryStatsPercentile(t *testing.T) {
+	config := DefaultConfig()
+	srv := &Server{}
+	for i := 1; i <= 100; i++ {
+		conn := &Connection{id: fmt.Sprint(i), config: config}
+		conn.setPendingTicks(i)
+		srv.connections.add(conn)
+	}
+
+	stats := srv.connMemoryStats()
+	assert.Equal(t, 100, stats.Connections)
+	assert.Equal(t, int64(5050*approxTickBytes), stats.TotalBytes)
+	assert.Equal(t, int64(99*approxTickBytes), stats.P99Bytes)
+	assert.Equal(t, int64(100*approxTickBytes), stats.MaxBytes)
+}
@@ -0,0 +1,103 @@
+package server
+
+import "sync"
+
+// connRegistryShards is the number of independently locked maps in a
+// connRegistry. A power of two, so the shard is a mask of the ID hash.
+const connRegistryShards = 64
+
+// connRegistry holds the server's live connections, sharded by a hash of the
+// connection ID so that registering and unregistering at high churn, and
+// stats walks, only contend within a shard. The zero value is ready to use.
+type connRegistry struct {
+	shards [connRegistryShards]connShard
+}
+
+type connShard struct {
+	mu    sync.RWMutex
+	conns map[string]*Connection
+}
+
+// shard returns the shard for id, hashed with FNV-1a.
+func (r *connRegistry) shard(id string) *connShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return &r.shards[h&(connRegistryShards-1)]
+}
+
+// add registers conn under its ID.
+func (r *connRegistry) add(conn *Connection) {
+	sh := r.shard(conn.ID())
+	sh.mu.Lock()
+	if sh.conns == nil {
+		sh.conns = make(map[string]*Connection)
+	}
+	sh.conns[conn.ID()] = conn
+	sh.mu.Unlock()
+}
+
+// remove unregisters conn.
+func (r *connRegistry) remove(conn *Connection) {
+	sh := r.shard(conn.ID())
+	sh.mu.Lock()
+	delete(sh.conns, conn.ID())
+	sh.mu.Unlock()
+}
+
+// get returns the connection registered as id.
+func (r *connRegistry) get(id string) (*Connection, bool) {
+	sh := r.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	conn, ok := sh.conns[id]
+	return conn, ok
+}
+
+// len returns the number of registered connections.
+func (r *connRegistry) len() int {
+	n := 0
+	for i := range r.shards {
+		sh := &r.shards[i]
+		sh.mu.RLock()
+		n += len(sh.conns)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// each calls fn for every registered connection, holding one shard's read
+// lock at a time, so fn must not block or touch the registry. The walk is
+// not a snapshot: connections added or removed meanwhile may be missed.
+func (r *connRegistry) each(fn func(*Connection)) {
+	for i := range r.shards {
+		sh := &r.shards[i]
+		sh.mu.RLock()
+		for _, conn := range sh.conns {
+			fn(conn)
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+// snapshot returns the registered connections for work that may block, such
+// as writing to or closing them.
+func (r *connRegistry) snapshot() []*Connection {
+	conns := make([]*Connection, 0, r.len())
+	r.each(func(conn *Connection) { conns = append(conns, conn) })
+	return conns
+}
+
+// filter returns the registered connections keep accepts, for broadcasts to
+// a subset of clients.
+func (r *connRegistry) filter(keep func(*Connection) bool) []*Connection {
+	var conns []*Connection
+	r.each(func(conn *Connection) {
+		if keep(conn) {
+			conns = append(conns, conn)
+		}
+	})
+	return conns
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnRegistry(t *testing.T) {
+	var r connRegistry
+	for i := 0; i < 1000; i++ {
+		r.add(&Connection{id: fmt.Sprint(i)})
+	}
+	assert.Equal(t, 1000, r.len())
+
+	conn, ok := r.get("42")
+	assert.True(t, ok)
+	assert.Equal(t, "42", conn.ID())
+
+	r.remove(conn)
+	_, ok = r.get("42")
+	assert.False(t, ok)
+	assert.Len(t, r.snapshot(), 999)
+
+	even := r.filter(func(c *Connection) bool { return c.ID()[len(c.ID())-1]%2 == 0 })
+	assert.Len(t, even, 499)
+
+	used := 0
+	for i := range r.shards {
+		if len(r.shards[i].conns) > 0 {
+			used++
+		}
+	}
+	assert.Equal(t, connRegistryShards, used, "IDs spread over every shard")
+}
+
+func TestConnRegistryConcurrentChurn(t *testing.T) {
+	var r connRegistry
+	var walked atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				conn := &Connection{id: fmt.Sprintf("%d-%d", w, i)}
+				r.add(conn)
+				r.each(func(*Connection) { walked.Add(1) })
+				r.remove(conn)
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Zero(t, r.len())
+	assert.Positive(t, walked.Load())
+}
+
+func BenchmarkConnRegistryChurn(b *testing.B) {
+	var r connRegistry
+	var n atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conn := &Connection{id: fmt.Sprint(n.Add(1))}
+			r.add(conn)
+			r.remove(conn)
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStage is a step of the connection lifecycle. Connections move forward
+// through connect → tls → auth → subscribe → streaming; plaintext
+// connections skip tls.
+type ConnStage int
+
+const (
+	StageConnect ConnStage = iota // Accepted, nothing exchanged yet
+	StageTLS                      // TLS handshake
+	StageAuth                     // Waiting for a valid AUTH
+	StageSubscribe                // Authenticated, waiting for SUBSCRIBE or RESUME
+	StageStreaming                // Subscribed and receiving data
+	numConnStages
+)
+
+// ConnStages lists every stage in lifecycle order.
+var ConnStages = []ConnStage{StageConnect, StageTLS, StageAuth, StageSubscribe, StageStreaming}
+
+var connStageNames = [numConnStages]string{"connect", "tls", "auth", "subscribe", "streaming"}
+
+// String returns the stage's metrics label.
+func (s ConnStage) String() string {
+	if s < 0 || s >= numConnStages {
+		return "unknown"
+	}
+	return connStageNames[s]
+}
+
+// How a connection ended, recorded against the stage it was in.
+const (
+	StageEndClosed  = "closed"  // Client hung up or the server shut down
+	StageEndError   = "error"   // Protocol, authentication or I/O error
+	StageEndTimeout = "timeout" // The stage deadline expired
+)
+
+// StageEndReasons lists every end reason.
+var StageEndReasons = []string{StageEndClosed, StageEndError, StageEndTimeout}
+
+var (
+	// ErrStageTimeout is returned when a connection stays in a stage past
+	// its deadline.
+	ErrStageTimeout = errors.New("connection stage deadline exceeded")
+
+	// ErrStageTransition is returned when a connection would skip or repeat
+	// a stage, which means a protocol handler let a frame through out of
+	// order.
+	ErrStageTransition = errors.New("invalid connection stage transition")
+)
+
+// StageTracker enforces per-stage deadlines and counts how many connections
+// are in each stage and in which stage they ended.
+type StageTracker struct {
+	clock    Clock
+	timeouts [numConnStages]time.Duration // 0 means the stage has no deadline
+
+	active     [numConnStages]int64
+	ended      [numConnStages][3]uint64 // Indexed by StageEndReasons
+	violations uint64
+}
+
+// NewStageTracker creates a tracker using the server's stage deadlines. The
+// TLS handshake deadline is enforced by the handshaker itself.
+func NewStageTracker(config *Config) *StageTracker {
+	t := &StageTracker{clock: config.clock()}
+	t.timeouts[StageAuth] = config.AuthTimeout
+	t.timeouts[StageSubscribe] = config.SubscribeTimeout
+	return t
+}
+
+// Begin starts tracking a newly accepted connection in StageConnect.
+func (t *StageTracker) Begin() *connStages {
+	atomic.AddInt64(&t.active[StageConnect], 1)
+	return &connStages{tracker: t, stage: StageConnect}
+}
+
+// Active returns the number of connections currently in stage.
+func (t *StageTracker) Active(stage ConnStage) int64 {
+	return atomic.LoadInt64(&t.active[stage])
+}
+
+// Ended returns the number of connections that ended in stage for reason.
+func (t *StageTracker) Ended(stage ConnStage, reason string) uint64 {
+	for i, r := range StageEndReasons {
+		if r == reason {
+			return atomic.LoadUint64(&t.ended[stage][i])
+		}
+	}
+	return 0
+}
+
+// Violations returns the number of rejected out-of-order transitions.
+func (t *StageTracker) Violations() uint64 {
+	return atomic.LoadUint64(&t.violations)
+}
+
+// GetStats returns per-stage counts for Server.GetStats.
+func (t *StageTracker) GetStats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"transition_violations": t.Violations(),
+	}
+	for _, stage := range ConnStages {
+		s := map[string]interface{}{
+			"active": t.Active(stage),
+		}
+		if d := t.timeouts[stage]; d > 0 {
+			s["timeout_ms"] = d.Milliseconds()
+		}
+		for _, reason := range StageEndReasons {
+			s["ended_"+reason] = t.Ended(stage, reason)
+		}
+		stats[stage.String()] = s
+	}
+	return stats
+}
+
+// connStages is one connection's position in the lifecycle. Entering a stage
+// with a deadline arms a timer that calls the timeout handler, which is
+// expected to close the connection.
+type connStages struct {
+	tracker *StageTracker
+
+	mu        sync.Mutex
+	stage     ConnStage
+	timer     Timer
+	onTimeout func(ConnStage)
+	timedOut  bool
+	ended     bool
+	endReason string
+}
+
+// OnTimeout sets the function called when a stage deadline expires. It runs
+// on a timer goroutine.
+func (c *connStages) OnTimeout(f func(ConnStage)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.onTimeout = f
+	c.mu.Unlock()
+}
+
+// Enter moves the connection to next and arms next's deadline. Only the next
+// stage in order may be entered, except that plaintext connections go
+// straight from connect to auth. A nil receiver does nothing.
+func (c *connStages) Enter(next ConnStage) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ended || !(next == c.stage+1 || (c.stage == StageConnect && next == StageAuth)) {
+		atomic.AddUint64(&c.tracker.violations, 1)
+		return fmt.Errorf("%w: %s to %s", ErrStageTransition, c.stage, next)
+	}
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	atomic.AddInt64(&c.tracker.active[c.stage], -1)
+	atomic.AddInt64(&c.tracker.active[next], 1)
+	c.stage = next
+
+	if d := c.tracker.timeouts[next]; d > 0 {
+		c.timer = c.tracker.clock.AfterFunc(d, func() { c.expire(next) })
+	}
+	return nil
+}
+
+// expire handles stage's deadline unless the connection has moved on.
+func (c *connStages) expire(stage ConnStage) {
+	c.mu.Lock()
+	if c.ended || c.stage != stage {
+		c.mu.Unlock()
+		return
+	}
+	c.timedOut = true
+	onTimeout := c.onTimeout
+	c.mu.Unlock()
+
+	if onTimeout != nil {
+		onTimeout(stage)
+	}
+}
+
+// Stage returns the current stage.
+func (c *connStages) Stage() ConnStage {
+	if c == nil {
+		return StageConnect
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stage
+}
+
+// TimedOut reports whether a stage deadline expired.
+func (c *connStages) TimedOut() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.timedOut
+}
+
+// End records that the connection finished in its current stage, classifying
+// the error that ended it, and returns the stage and reason. Later calls
+// return the first result.
+func (c *connStages) End(err error) (ConnStage, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ended {
+		return c.stage, c.endReason
+	}
+	c.ended = true
+
+	c.endReason = StageEndError
+	switch {
+	case c.timedOut, errors.Is(err, ErrHandshakeTimeout), errors.Is(err, ErrHeartbeatTimeout):
+		c.endReason = StageEndTimeout
+	case err == nil, errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed), errors.Is(err, context.Canceled):
+		c.endReason = StageEndClosed
+	}
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	atomic.AddInt64(&c.tracker.active[c.stage], -1)
+	for i, r := range StageEndReasons {
+		if r == c.endReason {
+			atomic.AddUint64(&c.tracker.ended[c.stage][i], 1)
+		}
+	}
+	return c.stage, c.endReason
+}
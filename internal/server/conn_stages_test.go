@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func newTestStageTracker(clock Clock) *StageTracker {
+	config := DefaultConfig()
+	config.Clock = clock
+	config.AuthTimeout = 5 * time.Second
+	config.SubscribeTimeout = 10 * time.Second
+	return NewStageTracker(config)
+}
+
+func TestConnStagesTransitions(t *testing.T) {
+	tracker := newTestStageTracker(NewFakeClock(time.Unix(0, 0)))
+
+	c := tracker.Begin()
+	require.NoError(t, c.Enter(StageAuth), "plaintext connections skip tls")
+	assert.ErrorIs(t, c.Enter(StageStreaming), ErrStageTransition, "subscribe cannot be skipped")
+	assert.ErrorIs(t, c.Enter(StageAuth), ErrStageTransition, "stages cannot repeat")
+	require.NoError(t, c.Enter(StageSubscribe))
+	require.NoError(t, c.Enter(StageStreaming))
+
+	assert.Equal(t, StageStreaming, c.Stage())
+	assert.Equal(t, int64(1), tracker.Active(StageStreaming))
+	assert.Equal(t, int64(0), tracker.Active(StageConnect))
+	assert.Equal(t, uint64(2), tracker.Violations())
+
+	stage, reason := c.End(io.EOF)
+	assert.Equal(t, StageStreaming, stage)
+	assert.Equal(t, StageEndClosed, reason)
+	assert.Equal(t, int64(0), tracker.Active(StageStreaming))
+	assert.Equal(t, uint64(1), tracker.Ended(StageStreaming, StageEndClosed))
+
+	c.End(assert.AnError)
+	assert.Equal(t, uint64(0), tracker.Ended(StageStreaming, StageEndError), "only the first End is counted")
+	assert.ErrorIs(t, c.Enter(StageStreaming), ErrStageTransition)
+
+	var untracked *connStages
+	assert.NoError(t, untracked.Enter(StageAuth))
+	assert.False(t, untracked.TimedOut())
+}
+
+func TestConnStagesDeadline(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	tracker := newTestStageTracker(clock)
+
+	var expired []ConnStage
+	c := tracker.Begin()
+	c.OnTimeout(func(stage ConnStage) { expired = append(expired, stage) })
+
+	require.NoError(t, c.Enter(StageAuth))
+	clock.Advance(4 * time.Second)
+	require.NoError(t, c.Enter(StageSubscribe), "entering the next stage disarms the auth deadline")
+	clock.Advance(4 * time.Second)
+	assert.Empty(t, expired)
+	assert.False(t, c.TimedOut())
+
+	clock.Advance(6 * time.Second)
+	assert.Equal(t, []ConnStage{StageSubscribe}, expired)
+	assert.True(t, c.TimedOut())
+
+	stage, reason := c.End(net.ErrClosed)
+	assert.Equal(t, StageSubscribe, stage)
+	assert.Equal(t, StageEndTimeout, reason, "a timed-out stage ends as a timeout whatever the read error")
+
+	stats := tracker.GetStats()["subscribe"].(map[string]interface{})
+	assert.Equal(t, uint64(1), stats["ended_timeout"])
+	assert.Equal(t, int64(10000), stats["timeout_ms"])
+}
+
+func TestConnStagesEndReasons(t *testing.T) {
+	tracker := newTestStageTracker(NewFakeClock(time.Unix(0, 0)))
+
+	tests := []struct {
+		err    error
+		reason string
+	}{
+		{nil, StageEndClosed},
+		{context.Canceled, StageEndClosed},
+		{ErrHandshakeTimeout, StageEndTimeout},
+		{ErrHeartbeatTimeout, StageEndTimeout},
+		{protocol.ErrInvalidMagic, StageEndError},
+	}
+	for _, tt := range tests {
+		_, reason := tracker.Begin().End(tt.err)
+		assert.Equal(t, tt.reason, reason, "error %v", tt.err)
+	}
+	assert.Equal(t, uint64(2), tracker.Ended(StageConnect, StageEndTimeout))
+}
+
+func TestServerEnforcesAuthStageDeadline(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.AuthTimeout = 100 * time.Millisecond
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	client, err := net.Dial("tcp", server.listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	frame, err := protocol.NewFrameReader(client, protocol.DefaultMaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeError, frame.Type)
+	var resp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED, resp.Code)
+	assert.Equal(t, "authentication timeout", resp.Message)
+
+	_, err = client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	assert.Eventually(t, func() bool {
+		return server.stages.Ended(StageAuth, StageEndTimeout) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(0), server.stages.Active(StageAuth))
+}
@@ -2,7 +2,9 @@
 package server
 
 import (
+	"crypto/x509"
 	"fmt"
+	"log/slog"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -11,12 +13,18 @@ import (
 	"github.com/furkansarikaya/tick-storm/internal/auth"
 	"github.com/furkansarikaya/tick-storm/internal/protocol"
 	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
 	"google.golang.org/protobuf/proto"
 )
 
+// publishSequence orders data batches across every connection in the process,
+// letting clients and tests verify cross-connection ordering.
+var publishSequence atomic.Uint64
+
 // WriteQueueItem represents an item in the write queue
 type WriteQueueItem struct {
 	frame    *protocol.Frame
+	size     int64 // Wire size, charged to the connection's memory while queued
 	deadline time.Time
 	done     chan error
 }
@@ -24,6 +32,8 @@ type WriteQueueItem struct {
 // Connection represents a client connection.
 type Connection struct {
 	id            string
+	trace         atomic.Pointer[protocol.TraceContext] // Exemplar and log correlation; the client's when it sent a traceparent
+	logger        *slog.Logger
 	conn          net.Conn
 	reader        *protocol.FrameReader
 	writer        *protocol.FrameWriter
@@ -39,23 +49,79 @@ type Connection struct {
 	closed        atomic.Bool
 	subscription  *Subscription
 	
+	// Data batch sequencing and recent history for gap-fill
+	batchSeq      uint32
+	history       *BatchHistory
+	
 	// Write queue for async writes
 	writeQueue    chan *WriteQueueItem
 	writeQueueWg  sync.WaitGroup
 	
+	// Disk overflow for the write queue; nil unless the class may spill
+	spill         atomic.Pointer[frameSpill]
+	
+	// QoS class, set after authentication
+	priority      atomic.Pointer[connPriority]
+	
+	// Set once a cohort drain has told the client the connection is closing
+	drainNotified atomic.Bool
+	
+	// request_id of the AUTH or SUBSCRIBE being handled, echoed in its ACK or ERROR
+	correlation   atomic.Pointer[string]
+	
+	// Client's TLS fingerprint; nil for plaintext or when fingerprinting is off
+	tlsFingerprint *TLSFingerprint
+	
+	// SNI server name from the TLS handshake; empty for plaintext
+	serverName    string
+	
+	// Client certificate chain when mutual TLS is enabled
+	peerCertificates []*x509.Certificate
+	
+	// Tenant, set after authentication
+	tenant        atomic.Pointer[Tenant]
+	
+	// Egress shaping, set after authentication; nil when unlimited
+	egress        atomic.Pointer[connEgress]
+	
+	// Closed by Close, waking a write loop held back by egress shaping
+	closing       chan struct{}
+	
+	// Lifecycle stage and its deadline; nil for connections not accepted by a Server
+	stages        *connStages
+	
+	// Failed writes by class, shared across connections; nil for connections
+	// not accepted by a Server
+	writeErrors   *writeErrorCounters
+	
+	// Latest heartbeat RTT and client clock skew
+	timing        heartbeatTiming
+	
 	// Metrics
 	messagesRecv  uint64
 	messagesSent  uint64
 	bytesRecv     uint64
 	bytesSent     uint64
 	lastActivity  time.Time
+	lastWrite     atomic.Int64 // Unix nanoseconds of the last frame written to the socket
 	writeQueueLen int32 // Atomic counter for queue length
+	
+	// Write queue occupancy (see WriteQueueStats)
+	queueHighWater int32
+	avgWriteNanos  int64
+	
+	// Approximate memory held for this connection (see Memory)
+	queuedBytes   int64
+	pendingTicks  int64
 }
 
 // NewConnection creates a new connection wrapper.
 func NewConnection(conn net.Conn, config *Config) *Connection {
-	id := fmt.Sprintf("%s-%d", conn.RemoteAddr().String(), time.Now().UnixNano())
-	
+	return newConnection(conn, config, newConnectionID())
+}
+
+// newConnection creates a connection wrapper identified by id.
+func newConnection(conn net.Conn, config *Config, id string) *Connection {
 	// Apply TCP optimizations
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
 		// Enable TCP_NODELAY to disable Nagle's algorithm for low latency
@@ -74,15 +140,23 @@ func NewConnection(conn net.Conn, config *Config) *Connection {
 	
 	c := &Connection{
 		id:           id,
+		logger:       config.logger().With("connection_id", id, "remote_addr", conn.RemoteAddr().String()),
 		conn:         conn,
 		reader:       protocol.NewFrameReader(conn, config.MaxMessageSize),
 		writer:       protocol.NewFrameWriter(conn),
 		config:       config,
 		pools:        GetGlobalPools(),
 		writeQueue:   make(chan *WriteQueueItem, config.MaxWriteQueueSize),
+		closing:      make(chan struct{}),
 		lastActivity: time.Now(),
 	}
 	
+	c.trace.Store(&protocol.TraceContext{TraceID: newTraceID()})
+	c.lastWrite.Store(c.now().UnixNano())
+	if config.GapFillBufferSize > 0 {
+		c.history = NewBatchHistory(config.GapFillBufferSize)
+	}
+	
 	// Start async write loop
 	c.writeQueueWg.Add(1)
 	go c.writeLoop()
@@ -95,6 +169,27 @@ func (c *Connection) ID() string {
 	return c.id
 }
 
+// Logger returns the logger for lines about this connection, tagged with its
+// ID and remote address, and with the client's trace once it joined one.
+func (c *Connection) Logger() *slog.Logger {
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default().With("connection_id", c.id)
+	}
+	if tc := c.trace.Load(); tc != nil && tc.ParentID != "" {
+		logger = logger.With("trace_id", tc.TraceID, "parent_span_id", tc.ParentID)
+	}
+	return logger
+}
+
+// TraceID returns the trace ID attached to this connection's publish metrics.
+func (c *Connection) TraceID() string {
+	if tc := c.trace.Load(); tc != nil {
+		return tc.TraceID
+	}
+	return ""
+}
+
 // RemoteAddr returns the remote address.
 func (c *Connection) RemoteAddr() string {
 	if c == nil || c.conn == nil {
@@ -120,6 +215,14 @@ func (c *Connection) IsAuthenticated() bool {
 	return c.authenticated
 }
 
+// Session returns the authenticated session, or nil before authentication.
+func (c *Connection) Session() *auth.Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	return c.session
+}
+
 // SetSubscription sets the connection's subscription.
 func (c *Connection) SetSubscription(sub *Subscription) error {
 	c.mu.Lock()
@@ -178,13 +281,34 @@ func (c *Connection) SendMessage(msgType protocol.MessageType, msg proto.Message
 	return c.WriteFrame(frame)
 }
 
-// SendAuthSuccess sends an authentication success ACK.
-func (c *Connection) SendAuthSuccess() error {
+// setCorrelationID sets the request_id echoed in ACK and ERROR responses
+// until it is cleared with an empty id.
+func (c *Connection) setCorrelationID(id string) {
+	if id == "" {
+		c.correlation.Store(nil)
+		return
+	}
+	c.correlation.Store(&id)
+}
+
+// correlationID returns the request_id of the request being handled.
+func (c *Connection) correlationID() string {
+	if id := c.correlation.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
+
+// SendAuthSuccess sends an authentication success ACK carrying metadata,
+// such as the server version.
+func (c *Connection) SendAuthSuccess(metadata map[string]string) error {
 	ack := &pb.AckResponse{
 		AckType: pb.MessageType_MESSAGE_TYPE_AUTH,
 		Success: true,
 		Message: "Authentication successful",
 		TimestampMs: time.Now().UnixMilli(),
+		Metadata: metadata,
+		CorrelationId: c.correlationID(),
 	}
 	
 	frame, err := protocol.MarshalMessage(protocol.MessageTypeACK, ack)
@@ -196,13 +320,7 @@ func (c *Connection) SendAuthSuccess() error {
 
 // SendAuthError sends an authentication error message.
 func (c *Connection) SendAuthError() error {
-	errMsg := &pb.ErrorResponse{
-		Code:        pb.ErrorCode_ERROR_CODE_INVALID_AUTH,
-		Message:     "Authentication failed",
-		TimestampMs: time.Now().UnixMilli(),
-	}
-	
-	frame, err := protocol.MarshalMessage(protocol.MessageTypeError, errMsg)
+	frame, err := c.errorFrame(pb.ErrorCode_ERROR_CODE_INVALID_AUTH, "Authentication failed", "", 0)
 	if err != nil {
 		return err
 	}
@@ -216,16 +334,9 @@ func (c *Connection) SendError(code pb.ErrorCode, message string) error {
 
 // SendErrorWithDetails sends an error message with detailed information.
 func (c *Connection) SendErrorWithDetails(code pb.ErrorCode, message, details string) error {
-	errMsg := &pb.ErrorResponse{
-		Code:        code,
-		Message:     message,
-		Details:     details,
-		TimestampMs: time.Now().UnixMilli(),
-	}
-	
-	frame, err := protocol.MarshalMessage(protocol.MessageTypeError, errMsg)
+	frame, err := c.errorFrame(code, message, details, 0)
 	if err != nil {
-		return fmt.Errorf("failed to marshal error response: %w", err)
+		return err
 	}
 	return c.WriteFrame(frame)
 }
@@ -236,6 +347,61 @@ func (c *Connection) SendErrorCode(code pb.ErrorCode) error {
 	return c.SendErrorWithDetails(code, message, details)
 }
 
+// SendRetryableError sends a predefined error telling the client how long
+// to back off before retrying or reconnecting.
+func (c *Connection) SendRetryableError(code pb.ErrorCode, retryAfter time.Duration) error {
+	message, details := getStandardErrorMessage(code)
+	frame, err := c.errorFrame(code, message, details, retryAfter)
+	if err != nil {
+		return err
+	}
+	return c.WriteFrame(frame)
+}
+
+// SendErrorCodeSync sends a predefined error with a retry hint and waits
+// until it is written, for errors sent just before the server closes the
+// connection.
+func (c *Connection) SendErrorCodeSync(code pb.ErrorCode, retryAfter time.Duration) error {
+	message, details := getStandardErrorMessage(code)
+	frame, err := c.errorFrame(code, message, details, retryAfter)
+	if err != nil {
+		return err
+	}
+	return c.WriteFrameSync(frame)
+}
+
+// errorFrame builds an ERROR frame answering the request being handled.
+func (c *Connection) errorFrame(code pb.ErrorCode, message, details string, retryAfter time.Duration) (*protocol.Frame, error) {
+	return marshalErrorResponse(newErrorResponse(code, message, details, retryAfter, c.correlationID()))
+}
+
+// errorFrame builds an ERROR frame that answers no particular request.
+func errorFrame(code pb.ErrorCode, message, details string, retryAfter time.Duration) (*protocol.Frame, error) {
+	return marshalErrorResponse(newErrorResponse(code, message, details, retryAfter, ""))
+}
+
+// newErrorResponse builds an error response. Errors with a retry hint are
+// retryable whatever their code.
+func newErrorResponse(code pb.ErrorCode, message, details string, retryAfter time.Duration, correlationID string) *pb.ErrorResponse {
+	return &pb.ErrorResponse{
+		Code:          code,
+		Message:       message,
+		Details:       details,
+		TimestampMs:   time.Now().UnixMilli(),
+		RetryAfterMs:  retryAfter.Milliseconds(),
+		Retryable:     retryAfter > 0 || protocol.IsRetryableError(code),
+		CorrelationId: correlationID,
+	}
+}
+
+func marshalErrorResponse(errMsg *pb.ErrorResponse) (*protocol.Frame, error) {
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeError, errMsg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal error response: %w", err)
+	}
+	return frame, nil
+}
+
 // getStandardErrorMessage returns standard error messages and details for error codes.
 func getStandardErrorMessage(code pb.ErrorCode) (message, details string) {
 	switch code {
@@ -265,18 +431,38 @@ func getStandardErrorMessage(code pb.ErrorCode) (message, details string) {
 		return "Rate limited", "Too many requests sent within the allowed time window"
 	case pb.ErrorCode_ERROR_CODE_INTERNAL_ERROR:
 		return "Internal server error", "An unexpected error occurred on the server"
+	case pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE:
+		return "Gap unavailable", "Requested batches are no longer retained; resubscribe to resume"
+	case pb.ErrorCode_ERROR_CODE_RESUME_FAILED:
+		return "Resume failed", "No resumable subscription for this token; send SUBSCRIBE instead"
+	case pb.ErrorCode_ERROR_CODE_SERVER_BUSY:
+		return "Server busy", "Connection closed to shed load; reconnect later"
+	case pb.ErrorCode_ERROR_CODE_NOT_PRODUCER:
+		return "Not a producer", "Connection is not allowed to publish ticks"
+	case pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION:
+		return "Duplicate session", "Another connection is authenticated with this client_id"
+	case pb.ErrorCode_ERROR_CODE_SLOW_CONSUMER:
+		return "Slow consumer", "Client did not read data fast enough and was disconnected"
+	case pb.ErrorCode_ERROR_CODE_QUOTA_EXCEEDED:
+		return "Quota exceeded", "Connection quota reached; retry after retry_after_ms"
+	case pb.ErrorCode_ERROR_CODE_SERVER_DRAINING:
+		return "Server draining", "Connection closed by a drain; reconnect after retry_after_ms"
+	case pb.ErrorCode_ERROR_CODE_NOT_ENTITLED:
+		return "Not entitled", "Requested symbols are not included in this account's entitlements"
 	default:
 		return "Unknown error", "An unrecognized error code was encountered"
 	}
 }
 
-// SendSubscriptionConfirmed sends subscription confirmation.
-func (c *Connection) SendSubscriptionConfirmed() error {
+// SendSubscriptionConfirmed sends subscription confirmation with the effective settings.
+func (c *Connection) SendSubscriptionConfirmed(metadata map[string]string) error {
 	ack := &pb.AckResponse{
 		AckType: pb.MessageType_MESSAGE_TYPE_SUBSCRIBE,
 		Success: true,
 		Message: "Subscription confirmed",
 		TimestampMs: time.Now().UnixMilli(),
+		Metadata: metadata,
+		CorrelationId: c.correlationID(),
 	}
 	
 	frame, err := protocol.MarshalMessage(protocol.MessageTypeACK, ack)
@@ -286,11 +472,49 @@ func (c *Connection) SendSubscriptionConfirmed() error {
 	return c.WriteFrame(frame)
 }
 
+// SendResumeConfirmed acknowledges a RESUME with the restored settings.
+func (c *Connection) SendResumeConfirmed(metadata map[string]string) error {
+	ack := &pb.AckResponse{
+		AckType:     pb.MessageType_MESSAGE_TYPE_RESUME,
+		Success:     true,
+		Message:     "Subscription resumed",
+		TimestampMs: time.Now().UnixMilli(),
+		Metadata:    metadata,
+	}
+	return c.SendMessage(protocol.MessageTypeACK, ack)
+}
+
+// now returns the current time from the configured clock.
+func (c *Connection) now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.config.clock().Now()
+}
+
+// SendHistoryComplete acknowledges a HISTORY request once its batches are sent.
+func (c *Connection) SendHistoryComplete(metadata map[string]string) error {
+	return c.SendMessage(protocol.MessageTypeACK, &pb.AckResponse{
+		AckType:       pb.MessageType_MESSAGE_TYPE_HISTORY,
+		Success:       true,
+		Message:       "History complete",
+		TimestampMs:   time.Now().UnixMilli(),
+		Metadata:      metadata,
+		CorrelationId: c.correlationID(),
+	})
+}
+
 // SendPong sends a pong response.
 func (c *Connection) SendPong(clientTimestamp int64, sequence uint64) error {
+	return c.sendPongAt(clientTimestamp, c.now().UnixMilli(), sequence)
+}
+
+// sendPongAt sends a pong stamped with serverTimestamp, which the client
+// echoes back for RTT and clock skew estimation.
+func (c *Connection) sendPongAt(clientTimestamp, serverTimestamp int64, sequence uint64) error {
 	pong := &pb.HeartbeatResponse{
 		ClientTimestampMs: clientTimestamp,
-		ServerTimestampMs: time.Now().UnixMilli(),
+		ServerTimestampMs: serverTimestamp,
 		Sequence:          sequence,
 	}
 	
@@ -301,22 +525,96 @@ func (c *Connection) SendPong(clientTimestamp int64, sequence uint64) error {
 	return c.WriteFrame(frame)
 }
 
+// SendInfo sends an informational notice that does not affect the session.
+func (c *Connection) SendInfo(code pb.InfoCode, message string, metadata map[string]string) error {
+	info := &pb.InfoMessage{
+		Code:        code,
+		Message:     message,
+		Metadata:    metadata,
+		TimestampMs: c.now().UnixMilli(),
+	}
+	return c.SendMessage(protocol.MessageTypeInfo, info)
+}
+
+// SendKeepalive queues an empty KEEPALIVE frame.
+func (c *Connection) SendKeepalive() error {
+	return c.SendMessage(protocol.MessageTypeKeepalive, &pb.Keepalive{})
+}
+
+// idleFor returns how long it has been since a frame was written to the
+// socket, or zero while frames are waiting in the write queue.
+func (c *Connection) idleFor() time.Duration {
+	if c.QueueLen() > 0 {
+		return 0
+	}
+	return c.now().Sub(time.Unix(0, c.lastWrite.Load()))
+}
+
 // SendDataBatch sends a batch of tick data.
 func (c *Connection) SendDataBatch(ticks []*pb.Tick) error {
 	if len(ticks) == 0 {
 		return nil
 	}
 	
+	// Copy ticks: callers reuse their slice, but the batch may be retained for redelivery
 	batch := &pb.DataBatch{
-		Ticks:            ticks,
-		BatchTimestampMs: time.Now().UnixMilli(),
-		BatchSequence:    uint32(atomic.AddUint64(&c.messagesSent, 1)),
+		Ticks:            append([]*pb.Tick(nil), ticks...),
+		BatchTimestampMs: c.now().UnixMilli(),
+		PublishSequence:  publishSequence.Add(1),
 		IsSnapshot:       false,
 	}
+	if sub := c.GetSubscription(); sub != nil && sub.Retention != nil {
+		// At-least-once: the retention buffer owns sequencing so it survives reconnects
+		sub.Retention.Append(batch)
+	} else {
+		batch.BatchSequence = atomic.AddUint32(&c.batchSeq, 1)
+	}
 	
-	// Update metrics
-	atomic.AddUint64(&c.bytesSent, uint64(len(ticks)*64)) // Approximate bytes per tick
-	
+	return c.sendBatch(batch)
+}
+
+// SendSnapshot sends the latest tick of each subscribed symbol as a snapshot
+// batch. Snapshots are outside the batch sequence and are not retained for
+// gap-fill or redelivery.
+func (c *Connection) SendSnapshot(ticks []*pb.Tick) error {
+	batch := &pb.DataBatch{
+		Ticks:            ticks,
+		BatchTimestampMs: c.now().UnixMilli(),
+		PublishSequence:  publishSequence.Add(1),
+		IsSnapshot:       true,
+	}
+	return c.SendMessage(protocol.MessageTypeDataBatch, batch)
+}
+
+// sendSnapshotSync sends ticks as a snapshot batch and waits until it is
+// written.
+func (c *Connection) sendSnapshotSync(ticks []*pb.Tick) error {
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeDataBatch, &pb.DataBatch{
+		Ticks:            ticks,
+		BatchTimestampMs: c.now().UnixMilli(),
+		PublishSequence:  publishSequence.Add(1),
+		IsSnapshot:       true,
+	})
+	if err != nil {
+		return err
+	}
+	return c.WriteFrameSync(frame)
+}
+
+// adoptSequencing continues the batch sequence and history of a resumed
+// subscription. It must be called before the connection sends any batch.
+func (c *Connection) adoptSequencing(seq uint32, history *BatchHistory) {
+	atomic.StoreUint32(&c.batchSeq, seq)
+	if history != nil {
+		c.history = history
+	}
+}
+
+// sendBatch sends an already-sequenced batch and records it for gap-fill.
+func (c *Connection) sendBatch(batch *pb.DataBatch) error {
+	if c.history != nil {
+		c.history.Record(batch)
+	}
 	return c.SendMessage(protocol.MessageTypeDataBatch, batch)
 }
 
@@ -334,56 +632,134 @@ func (c *Connection) SetWriteDeadline(t time.Time) error {
 func (c *Connection) writeLoop() {
 	defer c.writeQueueWg.Done()
 	
-	for item := range c.writeQueue {
-		// Check if connection is closed
-		if c.closed.Load() {
-			if item.done != nil {
-				item.done <- fmt.Errorf("connection closed")
-				close(item.done)
+	for {
+		select {
+		case item, ok := <-c.writeQueue:
+			if !ok {
+				return
+			}
+			if err := c.writeItem(item); err != nil {
+				// Stop on error to prevent further writes
+				return
 			}
-			c.pools.PutFrame(item.frame)
-			atomic.AddInt32(&c.writeQueueLen, -1)
-			continue
+		case <-c.spillReady():
 		}
 		
-		// Check if deadline has passed
-		if time.Now().After(item.deadline) {
-			if item.done != nil {
-				item.done <- fmt.Errorf("write deadline exceeded")
-				close(item.done)
+		// Spilled frames follow everything already queued in memory
+		if len(c.writeQueue) == 0 {
+			if err := c.replaySpill(); err != nil {
+				return
 			}
-			c.pools.PutFrame(item.frame)
-			atomic.AddInt32(&c.writeQueueLen, -1)
-			continue
 		}
-		
-		// Set write deadline
-		c.conn.SetWriteDeadline(item.deadline)
-		
-		// Write frame
-		err := c.writer.WriteFrame(item.frame)
-		
-		// Update metrics
-		if err == nil {
-			atomic.AddUint64(&c.messagesSent, 1)
-			atomic.AddUint64(&c.bytesSent, uint64(len(item.frame.Payload)+protocol.FrameHeaderSize+protocol.CRCSize))
+	}
+}
+
+// writeItem writes one queued frame and signals its waiter, if any. It
+// returns the socket error that should stop the write loop.
+func (c *Connection) writeItem(item *WriteQueueItem) error {
+	// Return frame to pool
+	defer c.dequeued(item)
+	
+	// Check if connection is closed
+	if c.closed.Load() {
+		if item.done != nil {
+			item.done <- fmt.Errorf("connection closed")
+			close(item.done)
 		}
-		
-		// Signal completion
+		return nil
+	}
+	
+	// Hold the frame back while the connection or its tenant is over its
+	// egress rate
+	if !c.shapeEgress(item.size) {
 		if item.done != nil {
-			item.done <- err
+			item.done <- fmt.Errorf("connection closed")
 			close(item.done)
 		}
-		
-		// Return frame to pool
-		c.pools.PutFrame(item.frame)
-		atomic.AddInt32(&c.writeQueueLen, -1)
-		
-		// Break on error to prevent further writes
-		if err != nil {
-			break
+		return nil
+	}
+	
+	// Check if deadline has passed
+	if time.Now().After(item.deadline) {
+		c.writeErrors.expired()
+		if item.done != nil {
+			item.done <- ErrWriteDeadlineExceeded
+			close(item.done)
+		}
+		return nil
+	}
+	
+	err := c.writeNow(item.frame, item.deadline)
+	
+	// Signal completion
+	if item.done != nil {
+		item.done <- err
+		close(item.done)
+	}
+	return err
+}
+
+// writeNow writes frame to the socket and counts it as sent.
+func (c *Connection) writeNow(frame *protocol.Frame, deadline time.Time) error {
+	c.conn.SetWriteDeadline(deadline)
+	start := time.Now()
+	if err := c.writer.WriteFrame(frame); err != nil {
+		if !c.closed.Load() {
+			c.writeErrors.record(err)
+		}
+		return err
+	}
+	c.recordWrite(time.Since(start))
+	c.lastWrite.Store(c.now().UnixNano())
+	atomic.AddUint64(&c.messagesSent, 1)
+	atomic.AddUint64(&c.bytesSent, uint64(frameWireSize(frame)))
+	return nil
+}
+
+// spillReady returns the channel signalling spilled frames, or nil when the
+// connection does not spill.
+func (c *Connection) spillReady() <-chan struct{} {
+	if sp := c.spill.Load(); sp != nil {
+		return sp.ready
+	}
+	return nil
+}
+
+// replaySpill writes spilled frames to the socket until the spill is empty.
+func (c *Connection) replaySpill() error {
+	sp := c.spill.Load()
+	if sp == nil {
+		return nil
+	}
+	for !c.closed.Load() {
+		frame, err := sp.pop()
+		if err != nil || frame == nil {
+			return err
+		}
+		if !c.shapeEgress(frameWireSize(frame)) {
+			return nil
+		}
+		deadline := time.Now().Add(c.config.writeDeadline())
+		if err := c.writeNow(frame, deadline); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// enableSpill lets the connection overflow its write queue to a bounded
+// file in dir instead of refusing frames.
+func (c *Connection) enableSpill(dir string, maxBytes int64, counters *spillCounters) {
+	c.spill.CompareAndSwap(nil, newFrameSpill(dir, maxBytes, counters))
+}
+
+// SpillPending returns the frames and bytes waiting in the connection's disk
+// spill.
+func (c *Connection) SpillPending() (frames int, bytes int64) {
+	if sp := c.spill.Load(); sp != nil {
+		return sp.pending()
+	}
+	return 0, 0
 }
 
 // WriteFrameAsync writes a frame asynchronously through the write queue
@@ -396,27 +772,149 @@ func (c *Connection) WriteFrameAsync(frame *protocol.Frame) error {
 		return fmt.Errorf("connection closed")
 	}
 	
-	// Check queue capacity for backpressure
+	// Check queue capacity for backpressure; lower priority classes get a
+	// smaller share of the queue while the server is constrained
+	limit := c.config.MaxWriteQueueSize
+	p := c.priority.Load()
+	if p != nil {
+		limit = p.sched.queueLimit(p.class, limit)
+	}
 	queueLen := atomic.LoadInt32(&c.writeQueueLen)
-	if int(queueLen) >= c.config.MaxWriteQueueSize {
-		return fmt.Errorf("write queue full - slow client detected")
+	queueFull := int(queueLen) >= limit
+	size := frameWireSize(frame)
+	overBudget := !queueFull && c.overMemoryBudget(size)
+	
+	// Connections allowed to spill overflow to disk instead of dropping
+	sp := c.spill.Load()
+	if sp != nil {
+		if spilled, err := sp.offer(frame, queueFull || overBudget); spilled {
+			return err
+		}
+	}
+	
+	if queueFull {
+		if p != nil {
+			atomic.AddUint64(&p.class.dropped, 1)
+		}
+		c.writeErrors.record(ErrWriteQueueFull)
+		return fmt.Errorf("%w - slow client detected", ErrWriteQueueFull)
+	}
+	if overBudget {
+		return ErrMemoryBudgetExceeded
 	}
 	
-	deadline := time.Now().Add(time.Duration(c.config.WriteDeadlineMS) * time.Millisecond)
+	deadline := time.Now().Add(c.config.writeDeadline())
 	item := &WriteQueueItem{
 		frame:    frame,
+		size:     size,
 		deadline: deadline,
 	}
 	
-	atomic.AddInt32(&c.writeQueueLen, 1)
+	c.enqueued(item)
 	
 	select {
 	case c.writeQueue <- item:
 		return nil
 	default:
-		atomic.AddInt32(&c.writeQueueLen, -1)
-		return fmt.Errorf("write queue full")
+		c.unqueued(item)
+		if sp != nil {
+			if _, err := sp.offer(frame, true); err == nil {
+				return nil
+			}
+		}
+		if p != nil {
+			atomic.AddUint64(&p.class.dropped, 1)
+		}
+		c.writeErrors.record(ErrWriteQueueFull)
+		return ErrWriteQueueFull
+	}
+}
+
+// SetPriority assigns the connection to class under sched.
+func (c *Connection) SetPriority(sched *QoSScheduler, class PriorityClass) {
+	if sched == nil {
+		return
+	}
+	if qc := sched.classes[class]; qc != nil {
+		c.priority.Store(&connPriority{sched: sched, class: qc})
+	}
+}
+
+// Priority returns the connection's QoS class, or "" if none is assigned.
+func (c *Connection) Priority() PriorityClass {
+	if p := c.priority.Load(); p != nil {
+		return p.class.name
+	}
+	return ""
+}
+
+// ServerName returns the TLS server name the client asked for, if any.
+func (c *Connection) ServerName() string {
+	return c.serverName
+}
+
+// authMeta describes the connection to a custom authenticator.
+func (c *Connection) authMeta() authn.ConnMeta {
+	return authn.ConnMeta{
+		RemoteAddr:       c.RemoteAddr(),
+		LocalAddr:        c.conn.LocalAddr().String(),
+		ServerName:       c.serverName,
+		PeerCertificates: c.peerCertificates,
+	}
+}
+
+// authPeer identifies the connection to the built-in authenticator.
+func (c *Connection) authPeer() auth.Peer {
+	return auth.Peer{ConnID: c.ID(), Addr: c.RemoteAddr()}
+}
+
+// setEgress applies egress shaping to the frames written from now on.
+func (c *Connection) setEgress(e *connEgress) {
+	c.egress.Store(e)
+}
+
+// shapeEgress waits until a frame of size bytes may be written without
+// taking the connection or its tenant over its egress rate, then for the
+// connection's fair share of the global budget. It reports false if the
+// connection closed meanwhile.
+func (c *Connection) shapeEgress(size int64) bool {
+	e := c.egress.Load()
+	if e == nil {
+		return true
+	}
+	if delay := e.reserve(size); delay > 0 {
+		timer := c.config.clock().NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-c.closing:
+			timer.Stop()
+			return false
+		}
+	}
+	return e.acquireGlobal(size, c.closing)
+}
+
+// SetTenant binds the connection to tenant.
+func (c *Connection) SetTenant(tenant *Tenant) {
+	c.tenant.Store(tenant)
+}
+
+// Tenant returns the connection's tenant, or nil before authentication.
+func (c *Connection) Tenant() *Tenant {
+	return c.tenant.Load()
+}
+
+// QueueLen returns the number of frames waiting in the write queue.
+func (c *Connection) QueueLen() int32 {
+	return atomic.LoadInt32(&c.writeQueueLen)
+}
+
+// scaleBatchWindow stretches window for lower priority classes under pressure.
+func (c *Connection) scaleBatchWindow(window time.Duration) time.Duration {
+	if p := c.priority.Load(); p != nil {
+		return p.sched.batchWindow(p.class, window)
 	}
+	return window
 }
 
 // WriteFrameSync writes a frame synchronously with deadline
@@ -425,23 +923,26 @@ func (c *Connection) WriteFrameSync(frame *protocol.Frame) error {
 		return fmt.Errorf("connection closed")
 	}
 	
-	deadline := time.Now().Add(time.Duration(c.config.WriteDeadlineMS) * time.Millisecond)
+	deadline := time.Now().Add(c.config.writeDeadline())
 	done := make(chan error, 1)
 	
 	item := &WriteQueueItem{
 		frame:    frame,
+		size:     frameWireSize(frame),
 		deadline: deadline,
 		done:     done,
 	}
 	
-	atomic.AddInt32(&c.writeQueueLen, 1)
+	c.enqueued(item)
 	
 	select {
 	case c.writeQueue <- item:
 		return <-done
-	case <-time.After(time.Duration(c.config.WriteDeadlineMS) * time.Millisecond):
-		atomic.AddInt32(&c.writeQueueLen, -1)
-		return fmt.Errorf("write timeout")
+	case <-time.After(c.config.writeDeadline()):
+		// The queue stayed full until the frame's deadline
+		c.unqueued(item)
+		c.writeErrors.record(ErrWriteQueueFull)
+		return fmt.Errorf("%w - frame not queued before its write deadline", ErrWriteQueueFull)
 	}
 }
 
@@ -449,9 +950,13 @@ func (c *Connection) WriteFrameSync(frame *protocol.Frame) error {
 func (c *Connection) Close() error {
 	if c.closed.CompareAndSwap(false, true) {
 		// Close write queue
+		close(c.closing)
 		close(c.writeQueue)
 		// Wait for write loop to finish
 		c.writeQueueWg.Wait()
+		if sp := c.spill.Load(); sp != nil {
+			sp.close()
+		}
 		return c.conn.Close()
 	}
 	return nil
@@ -459,13 +964,26 @@ func (c *Connection) Close() error {
 
 // ...
 
+// LastActivity returns when a frame was last read from the client.
+func (c *Connection) LastActivity() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastActivity
+}
+
+// trafficCounters returns the frames and bytes sent and received so far.
+func (c *Connection) trafficCounters() (msgsSent, msgsRecv, bytesSent, bytesRecv uint64) {
+	return atomic.LoadUint64(&c.messagesSent), atomic.LoadUint64(&c.messagesRecv),
+		atomic.LoadUint64(&c.bytesSent), atomic.LoadUint64(&c.bytesRecv)
+}
+
 // GetStats returns connection statistics.
 func (c *Connection) GetStats() map[string]interface{} {
 	c.mu.RLock()
 	lastActivity := c.lastActivity
 	c.mu.RUnlock()
 	
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"id":             c.id,
 		"remote_addr":    c.RemoteAddr(),
 		"authenticated":  c.IsAuthenticated(),
@@ -475,13 +993,44 @@ func (c *Connection) GetStats() map[string]interface{} {
 		"bytes_sent":     atomic.LoadUint64(&c.bytesSent),
 		"last_activity":  lastActivity,
 		"has_subscription": c.GetSubscription() != nil,
+		"priority":       string(c.Priority()),
 	}
+	if tenant := c.Tenant(); tenant != nil {
+		stats["tenant"] = tenant.Name()
+	}
+	if frames, bytes := c.SpillPending(); frames > 0 {
+		stats["spill_frames"] = frames
+		stats["spill_bytes"] = bytes
+	}
+	if rtt, skew, ok := c.HeartbeatTiming(); ok {
+		stats["heartbeat_rtt_ms"] = float64(rtt) / float64(time.Millisecond)
+		stats["clock_skew_ms"] = float64(skew) / float64(time.Millisecond)
+	}
+	if fp := c.tlsFingerprint; fp != nil {
+		stats["tls_ja3"] = fp.JA3
+		stats["tls_ja3_hash"] = fp.JA3Hash
+	}
+	return stats
 }
 
 // Subscription represents a client subscription.
 type Subscription struct {
-	Mode      pb.SubscriptionMode
-	CreatedAt time.Time
+	Mode         pb.SubscriptionMode
+	DeliveryMode pb.DeliveryMode
+	Options      DeliveryOptions
+	CreatedAt    time.Time
+
+	// Symbols the client asked for; empty subscribes to all. SUBSCRIPTION_UPDATE
+	// changes them while streaming, so read them with CurrentSymbols
+	Symbols []string
+	symbolsMu   sync.RWMutex
+	symbolIndex map[string]struct{} // Built by setSymbols; nil scans Symbols
+
+	// Retention holds unacknowledged batches for at-least-once delivery; nil otherwise
+	Retention *RetentionBuffer
+
+	// ResumeToken lets a reconnecting client RESUME this subscription; empty when disabled
+	ResumeToken string
 }
 
 // NewSubscription creates a new subscription.
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestSubscribeEchoesRequestID(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, client, done := startHandle(t, ctx, config)
+	reader := protocol.NewFrameReader(client, config.MaxMessageSize)
+	writer := protocol.NewFrameWriter(client)
+	subscribe := func(requestID string) *protocol.Frame {
+		frame, err := protocol.MarshalMessage(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{
+			Mode:      pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+			RequestId: requestID,
+		})
+		require.NoError(t, err)
+		client.SetDeadline(time.Now().Add(time.Second))
+		require.NoError(t, writer.WriteFrame(frame))
+		resp, err := reader.ReadFrame()
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := subscribe("sub-1")
+	require.Equal(t, protocol.MessageTypeACK, resp.Type)
+	var ack pb.AckResponse
+	require.NoError(t, proto.Unmarshal(resp.Payload, &ack))
+	assert.Equal(t, "sub-1", ack.CorrelationId)
+
+	resp = subscribe("sub-2")
+	require.Equal(t, protocol.MessageTypeError, resp.Type)
+	var errResp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(resp.Payload, &errResp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_ALREADY_SUBSCRIBED, errResp.Code)
+	assert.Equal(t, "sub-2", errResp.CorrelationId)
+	assert.False(t, errResp.Retryable)
+
+	assert.Empty(t, handler.conn.correlationID(), "cleared once the request is answered")
+
+	cancel()
+	client.Close()
+	<-done
+}
+
+func TestErrorResponseRetryable(t *testing.T) {
+	resp := newErrorResponse(pb.ErrorCode_ERROR_CODE_RATE_LIMITED, "", "", 0, "")
+	assert.True(t, resp.Retryable)
+	resp = newErrorResponse(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION, "", "", 0, "")
+	assert.False(t, resp.Retryable)
+	resp = newErrorResponse(pb.ErrorCode_ERROR_CODE_INVALID_AUTH, "", "", time.Second, "auth-1")
+	assert.True(t, resp.Retryable, "a retry hint makes any error retryable")
+	assert.Equal(t, "auth-1", resp.CorrelationId)
+}
+
+func TestNoteAuthRequest(t *testing.T) {
+	conn := &Connection{}
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "u", Password: "p", RequestId: "auth-1",
+		Metadata: map[string]string{protocol.MetadataTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}})
+	require.NoError(t, err)
+	noteAuthRequest(conn, frame)
+	assert.Equal(t, "auth-1", conn.correlationID())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", conn.TraceID(), "the client's trace is joined")
+
+	frame, err = protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{})
+	require.NoError(t, err)
+	noteAuthRequest(conn, frame)
+	assert.Empty(t, conn.correlationID())
+}
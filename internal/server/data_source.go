@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// DataSource produces the ticks streamed to subscribers. The server calls
+// Stream once per subscription on its own goroutine.
+type DataSource interface {
+	// Stream passes ticks for subscription to emit until ctx is done. emit
+	// never blocks: ticks the connection cannot queue are dropped.
+	Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick))
+}
+
+// SymbolDirectory is implemented by data sources that can describe the
+// symbols they publish. SYMBOL_LIST requests are answered from it; without
+// one the server lists the symbols it has seen ticks for.
+type SymbolDirectory interface {
+	Symbols(ctx context.Context) ([]*pb.SymbolInfo, error)
+}
+
+// StreamStatusSource is implemented by data sources that report changes in
+// a symbol's stream condition: trading halts and resumes, and stale data.
+// The server calls WatchStatus once on its own goroutine when it starts;
+// report forwards each change to the symbol's subscribers and rejects
+// malformed statuses.
+type StreamStatusSource interface {
+	WatchStatus(ctx context.Context, report func(*pb.StreamStatus) error)
+}
+
+// DataSourceChecker is implemented by data sources that depend on an
+// upstream feed. Preflight calls Check to confirm the upstream is reachable.
+type DataSourceChecker interface {
+	Check(ctx context.Context) error
+}
+
+// dataSource returns the source subscriptions stream from: the replicated
+// source when ingest is leader-elected, else the upstream source.
+func (c *Config) dataSource() DataSource {
+	if c.Replication != nil {
+		return c.Replication.source()
+	}
+	return c.upstreamSource()
+}
+
+// upstreamSource returns the configured source, then the ingestion hub, file
+// replay or the market simulator when configured, defaulting to synthetic
+// ticks.
+func (c *Config) upstreamSource() DataSource {
+	if c.DataSource != nil {
+		return c.DataSource
+	}
+	if c.Ingest != nil {
+		return c.Ingest.source(c.clock())
+	}
+	if c.Replay != nil {
+		return c.Replay.source(c.clock(), c.logger())
+	}
+	if c.Simulation != nil {
+		return c.Simulation.source(c.clock(), c.modeSchedule())
+	}
+	return syntheticSource{clock: c.clock(), schedule: c.modeSchedule()}
+}
+
+// dataSourceName names the source dataSource returns, for logs and metrics.
+func (c *Config) dataSourceName() string {
+	switch {
+	case c.DataSource != nil:
+		return fmt.Sprintf("%T", c.DataSource)
+	case c.Ingest != nil:
+		return "ingest"
+	case c.Replay != nil:
+		return "replay"
+	case c.Simulation != nil:
+		return "simulation"
+	}
+	return "synthetic"
+}
+
+// syntheticSource emits one random tick per interval of the subscription
+// mode. It stands in for a market data feed in development and tests.
+type syntheticSource struct {
+	clock    Clock
+	schedule modeSchedule
+}
+
+func (s syntheticSource) Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick)) {
+	var period time.Duration
+	switch subscription.Mode {
+	case pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND:
+		period = 1 * time.Second
+	case pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE:
+		period = 1 * time.Minute
+	default:
+		return
+	}
+	ticker := s.schedule.ticker(s.clock, period)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			emit([]*pb.Tick{{
+				Symbol:      fmt.Sprintf("TICK_%d", i),
+				Price:       100.0 + rand.Float64()*10,
+				Volume:      float64(rand.Intn(1000)),
+				TimestampMs: ticker.Fired().UnixMilli(),
+				Mode:        subscription.Mode,
+			}})
+		}
+	}
+}
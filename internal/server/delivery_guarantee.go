@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// RetentionBuffer holds unacknowledged batches for an at-least-once subscriber.
+// Sequence numbers are assigned by the buffer so they stay contiguous across
+// reconnects of the same client.
+type RetentionBuffer struct {
+	mu         sync.Mutex
+	batches    []*pb.DataBatch // Ordered by BatchSequence
+	nextSeq    uint32
+	maxBatches int
+
+	// Attachment tracking for expiry
+	attached   int
+	detachedAt time.Time
+
+	// Metrics
+	overflowed uint64
+}
+
+// NewRetentionBuffer creates a new retention buffer bounded to maxBatches.
+func NewRetentionBuffer(maxBatches int) *RetentionBuffer {
+	if maxBatches <= 0 {
+		maxBatches = 1000
+	}
+	return &RetentionBuffer{
+		batches:    make([]*pb.DataBatch, 0, maxBatches),
+		nextSeq:    1,
+		maxBatches: maxBatches,
+	}
+}
+
+// Append assigns batch the next sequence number and retains it until
+// acknowledged. When the buffer is full the oldest batch is evicted and
+// counted as overflowed.
+func (rb *RetentionBuffer) Append(batch *pb.DataBatch) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	batch.BatchSequence = rb.nextSeq
+	rb.nextSeq++
+
+	if len(rb.batches) >= rb.maxBatches {
+		rb.batches[0] = nil
+		rb.batches = rb.batches[1:]
+		atomic.AddUint64(&rb.overflowed, 1)
+	}
+	rb.batches = append(rb.batches, batch)
+}
+
+// Ack releases all retained batches with a sequence at or below seq and
+// returns how many were released.
+func (rb *RetentionBuffer) Ack(seq uint32) int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	n := 0
+	for n < len(rb.batches) && rb.batches[n].BatchSequence <= seq {
+		rb.batches[n] = nil
+		n++
+	}
+	rb.batches = rb.batches[n:]
+	return n
+}
+
+// Unacked returns the retained batches in sequence order.
+func (rb *RetentionBuffer) Unacked() []*pb.DataBatch {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	out := make([]*pb.DataBatch, len(rb.batches))
+	copy(out, rb.batches)
+	return out
+}
+
+// Len returns the number of unacknowledged batches.
+func (rb *RetentionBuffer) Len() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return len(rb.batches)
+}
+
+// LastSequence returns the most recently assigned sequence number.
+func (rb *RetentionBuffer) LastSequence() uint32 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.nextSeq - 1
+}
+
+// RetentionStore keeps retention buffers for at-least-once subscribers keyed
+// by client identity, so a reconnecting client resumes its unacked batches.
+type RetentionStore struct {
+	mu         sync.Mutex
+	buffers    map[string]*RetentionBuffer
+	maxBatches int
+	ttl        time.Duration
+
+	// Metrics
+	redelivered uint64
+	acked       uint64
+	expired     uint64
+}
+
+// NewRetentionStore creates a new retention store.
+func NewRetentionStore(maxBatches int, ttl time.Duration) *RetentionStore {
+	return &RetentionStore{
+		buffers:    make(map[string]*RetentionBuffer),
+		maxBatches: maxBatches,
+		ttl:        ttl,
+	}
+}
+
+// retentionKey derives the store key for an authenticated session. Returns
+// an empty key when the client did not supply a client ID.
+func retentionKey(session *auth.Session) string {
+	if session == nil || session.ClientID == "" {
+		return ""
+	}
+	return session.Username + "/" + session.ClientID
+}
+
+// Acquire returns the buffer for key, creating it if needed, and marks it attached.
+func (rs *RetentionStore) Acquire(key string) *RetentionBuffer {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	buf, ok := rs.buffers[key]
+	if !ok {
+		buf = NewRetentionBuffer(rs.maxBatches)
+		rs.buffers[key] = buf
+	}
+	buf.mu.Lock()
+	buf.attached++
+	buf.mu.Unlock()
+	return buf
+}
+
+// Release marks a buffer as detached from a connection; it expires after the TTL
+// unless reacquired.
+func (rs *RetentionStore) Release(buf *RetentionBuffer) {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	if buf.attached > 0 {
+		buf.attached--
+	}
+	if buf.attached == 0 {
+		buf.detachedAt = time.Now()
+	}
+}
+
+// Cleanup removes detached buffers idle for longer than the TTL.
+func (rs *RetentionStore) Cleanup(now time.Time) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for key, buf := range rs.buffers {
+		buf.mu.Lock()
+		expired := buf.attached == 0 && now.Sub(buf.detachedAt) > rs.ttl
+		buf.mu.Unlock()
+		if expired {
+			delete(rs.buffers, key)
+			atomic.AddUint64(&rs.expired, 1)
+		}
+	}
+}
+
+// StartCleanupRoutine periodically expires detached buffers until ctx is done.
+func (rs *RetentionStore) StartCleanupRoutine(ctx context.Context) {
+	interval := rs.ttl / 2
+	if interval <= 0 || interval > time.Minute {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				rs.Cleanup(now)
+			}
+		}
+	}()
+}
+
+// GetStats returns retention store statistics.
+func (rs *RetentionStore) GetStats() map[string]interface{} {
+	rs.mu.Lock()
+	sessions := len(rs.buffers)
+	var unacked int
+	var overflowed uint64
+	for _, buf := range rs.buffers {
+		unacked += buf.Len()
+		overflowed += atomic.LoadUint64(&buf.overflowed)
+	}
+	rs.mu.Unlock()
+
+	return map[string]interface{}{
+		"sessions":            sessions,
+		"unacked_batches":     unacked,
+		"overflowed_batches":  overflowed,
+		"redelivered_batches": atomic.LoadUint64(&rs.redelivered),
+		"acked_batches":       atomic.LoadUint64(&rs.acked),
+		"expired_sessions":    atomic.LoadUint64(&rs.expired),
+		"max_unacked_batches": rs.maxBatches,
+		"retention_ttl":       rs.ttl.String(),
+	}
+}
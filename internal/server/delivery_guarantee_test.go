@@ -0,0 +1,91 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func sequences(batches []*pb.DataBatch) []uint32 {
+	out := make([]uint32, 0, len(batches))
+	for _, b := range batches {
+		out = append(out, b.BatchSequence)
+	}
+	return out
+}
+
+func TestRetentionBufferSequencingAndAck(t *testing.T) {
+	buf := NewRetentionBuffer(10)
+
+	for i := 0; i < 5; i++ {
+		batch := &pb.DataBatch{Ticks: []*pb.Tick{{Symbol: "EURUSD"}}}
+		buf.Append(batch)
+		assert.Equal(t, uint32(i+1), batch.BatchSequence)
+	}
+	assert.Equal(t, []uint32{1, 2, 3, 4, 5}, sequences(buf.Unacked()))
+
+	// Acks are cumulative
+	assert.Equal(t, 3, buf.Ack(3))
+	assert.Equal(t, []uint32{4, 5}, sequences(buf.Unacked()))
+
+	// Stale and duplicate acks release nothing
+	assert.Equal(t, 0, buf.Ack(2))
+	assert.Equal(t, 2, buf.Len())
+
+	assert.Equal(t, 2, buf.Ack(100))
+	assert.Zero(t, buf.Len())
+	assert.Equal(t, uint32(5), buf.LastSequence())
+}
+
+func TestRetentionBufferOverflowDropsOldest(t *testing.T) {
+	buf := NewRetentionBuffer(3)
+	for i := 0; i < 5; i++ {
+		buf.Append(&pb.DataBatch{})
+	}
+
+	assert.Equal(t, []uint32{3, 4, 5}, sequences(buf.Unacked()))
+	assert.Equal(t, uint64(2), buf.overflowed)
+}
+
+func TestRetentionStoreResumeAndExpiry(t *testing.T) {
+	store := NewRetentionStore(10, time.Minute)
+	key := retentionKey(&auth.Session{Username: "alice", ClientID: "c1"})
+	require.Equal(t, "alice/c1", key)
+	assert.Empty(t, retentionKey(&auth.Session{Username: "alice"}))
+
+	first := store.Acquire(key)
+	first.Append(&pb.DataBatch{})
+	first.Append(&pb.DataBatch{})
+	store.Release(first)
+
+	// A reconnect within the TTL resumes the same buffer and sequence
+	second := store.Acquire(key)
+	assert.Same(t, first, second)
+	assert.Equal(t, 2, second.Len())
+	next := &pb.DataBatch{}
+	second.Append(next)
+	assert.Equal(t, uint32(3), next.BatchSequence)
+
+	// Attached buffers never expire
+	store.Cleanup(time.Now().Add(time.Hour))
+	assert.Equal(t, 1, store.GetStats()["sessions"])
+
+	store.Release(second)
+	store.Cleanup(time.Now().Add(30 * time.Second))
+	assert.Equal(t, 1, store.GetStats()["sessions"])
+
+	store.Cleanup(time.Now().Add(2 * time.Minute))
+	stats := store.GetStats()
+	assert.Equal(t, 0, stats["sessions"])
+	assert.Equal(t, uint64(1), stats["expired_sessions"])
+
+	// Expired clients start a fresh sequence
+	fresh := &pb.DataBatch{}
+	store.Acquire(key).Append(fresh)
+	assert.Equal(t, uint32(1), fresh.BatchSequence)
+}
@@ -2,12 +2,32 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 )
 
+var (
+	// ErrBackpressure is returned when a connection's data channel stays
+	// nearly full, the client reading too slowly to keep up.
+	ErrBackpressure = errors.New("connection backpressure exceeded threshold")
+
+	// ErrWriteQueueFull is returned when a frame finds the connection's
+	// write queue full.
+	ErrWriteQueueFull = errors.New("write queue full")
+)
+
+// isSlowConsumer reports whether err disconnects a client for reading too
+// slowly, which is reported to it as ERROR_CODE_SLOW_CONSUMER.
+func isSlowConsumer(err error) bool {
+	return errors.Is(err, ErrBackpressure) ||
+		errors.Is(err, ErrWriteQueueFull) ||
+		errors.Is(err, ErrMemoryBudgetExceeded) ||
+		errors.Is(err, ErrSpillFull)
+}
+
 // deliveryLoop handles data delivery with micro-batching.
 func (h *ConnectionHandler) deliveryLoop(ctx context.Context, errChan chan<- error) {
 	// Configurable batching parameters
@@ -44,18 +64,23 @@ func (h *ConnectionHandler) deliveryLoop(ctx context.Context, errChan chan<- err
 			}
 			
 			// Add ticks to pending batch
-			h.pendingBatch = append(h.pendingBatch, filteredTicks...)
+			if err := h.appendPending(filteredTicks); err != nil {
+				h.reportSlowClient(err, errChan)
+				return
+			}
 			
 			// Reset consecutive drops on successful data reception
 			consecutiveDrops = 0
 			
-			// Reset batch timer
+			// Subscription metadata may override the server defaults
+			batchWindow, maxBatchSize := h.batchSettings(batchWindow, maxBatchSize)
+			
+			// Reset batch timer; it fires on this goroutine's select so the
+			// flush never races with appends to the pending batch
 			if h.batchTimer != nil {
 				h.batchTimer.Stop()
 			}
-			h.batchTimer = time.AfterFunc(batchWindow, func() {
-				h.flushBatch(errChan)
-			})
+			h.batchTimer = h.config.clock().NewTimer(batchWindow)
 			
 			// Check if batch is full
 			if len(h.pendingBatch) >= maxBatchSize {
@@ -63,7 +88,7 @@ func (h *ConnectionHandler) deliveryLoop(ctx context.Context, errChan chan<- err
 				h.flushBatch(errChan)
 			}
 			
-		case <-h.batchTimer.C:
+		case <-h.batchTimer.C():
 			// Timer expired, flush batch
 			h.flushBatch(errChan)
 			
@@ -74,7 +99,10 @@ func (h *ConnectionHandler) deliveryLoop(ctx context.Context, errChan chan<- err
 				// Process normally
 				filteredTicks := h.filterTicksBySubscription(ticks)
 				if len(filteredTicks) > 0 {
-					h.pendingBatch = append(h.pendingBatch, filteredTicks...)
+					if err := h.appendPending(filteredTicks); err != nil {
+						h.reportSlowClient(err, errChan)
+						return
+					}
 				}
 			default:
 				// Data channel is empty, check for backpressure
@@ -92,7 +120,7 @@ func (h *ConnectionHandler) deliveryLoop(ctx context.Context, errChan chan<- err
 							"consecutive_drops", consecutiveDrops,
 						)
 						select {
-						case errChan <- fmt.Errorf("connection backpressure exceeded threshold"):
+						case errChan <- ErrBackpressure:
 						default:
 						}
 						return
@@ -110,20 +138,130 @@ func (h *ConnectionHandler) flushBatch(errChan chan<- error) {
 		return
 	}
 	
+	batch := h.pendingBatch
+	if sub := h.conn.GetSubscription(); sub != nil && sub.Options.Conflate {
+		batch = conflateTicks(batch)
+	}
+	
+	// The ticks are charged to the write queue once encoded
+	h.conn.setPendingTicks(0)
+	
+	if !h.validateOutbound(batch) {
+		h.pendingBatch = h.pendingBatch[:0]
+		return
+	}
+	
 	// Send batch
-	if err := h.conn.SendDataBatch(h.pendingBatch); err != nil {
+	if err := h.conn.SendDataBatch(batch); err != nil {
+		if errors.Is(err, ErrMemoryBudgetExceeded) {
+			h.reportSlowClient(err, errChan)
+			return
+		}
+		h.recordSLO(false, 0)
 		select {
 		case errChan <- err:
 		default:
 		}
 		return
 	}
+	latency := h.batchLatency(batch)
+	h.recordSLO(true, latency)
+	if h.server != nil && h.server.batchTuner != nil {
+		h.server.batchTuner.RecordFlush()
+	}
+	h.recordPublish(batch, latency)
 	
 	// Clear pending batch
 	h.pendingBatch = h.pendingBatch[:0]
 }
 
-// filterTicksBySubscription filters ticks based on the connection's subscription mode.
+// appendPending adds ticks to the pending batch unless that would take the
+// connection past its memory budget.
+func (h *ConnectionHandler) appendPending(ticks []*pb.Tick) error {
+	if h.conn.overMemoryBudget(int64(len(ticks)) * approxTickBytes) {
+		return ErrMemoryBudgetExceeded
+	}
+	h.pendingBatch = append(h.pendingBatch, ticks...)
+	h.conn.setPendingTicks(len(h.pendingBatch))
+	return nil
+}
+
+// reportSlowClient applies the slow-client policy to a connection over its
+// memory budget: it is counted and the handler is told to disconnect it.
+func (h *ConnectionHandler) reportSlowClient(err error, errChan chan<- error) {
+	mem := h.conn.Memory()
+	h.logger.Warn("connection memory budget exceeded, disconnecting slow client",
+		"budget_bytes", h.config.MaxConnMemoryBytes,
+		"write_queue_bytes", mem.WriteQueue,
+		"pending_batch_bytes", mem.PendingBatch,
+		"history_bytes", mem.History,
+	)
+	GlobalMetrics.IncrementSlowClients()
+	if h.server != nil {
+		atomic.AddUint64(&h.server.memoryBudgetExceeded, 1)
+	}
+	select {
+	case errChan <- err:
+	default:
+	}
+}
+
+// batchLatency runs from the oldest tick's timestamp to now, the hand-off
+// to the connection.
+func (h *ConnectionHandler) batchLatency(batch []*pb.Tick) time.Duration {
+	oldest := batch[0].TimestampMs
+	for _, tick := range batch[1:] {
+		if tick.TimestampMs < oldest {
+			oldest = tick.TimestampMs
+		}
+	}
+	latency := h.config.clock().Now().Sub(time.UnixMilli(oldest))
+	if latency < 0 {
+		latency = 0
+	}
+	return latency
+}
+
+// recordPublish reports a sent batch to Prometheus.
+func (h *ConnectionHandler) recordPublish(batch []*pb.Tick, latency time.Duration) {
+	if h.server == nil || h.server.prometheusMetrics == nil {
+		return
+	}
+	h.server.prometheusMetrics.RecordPublish(batch, latency, h.conn.TraceID())
+	h.server.prometheusMetrics.ObserveWriteQueue(h.server.instanceID, h.conn.WriteQueueStats())
+	if tenant := h.conn.Tenant(); tenant != nil {
+		h.server.prometheusMetrics.AddTenantTicks(h.server.instanceID, tenant.Name(), len(batch))
+	}
+}
+
+// recordSLO counts a delivery attempt against the server's SLO.
+func (h *ConnectionHandler) recordSLO(delivered bool, latency time.Duration) {
+	if h.server != nil && h.server.slo != nil {
+		h.server.slo.Record(delivered, latency)
+	}
+}
+
+// batchSettings returns the subscription's batching overrides, falling back to
+// the given defaults, with the window stretched for low-priority connections
+// under pressure. With adaptive batching the tuned window replaces the
+// default; a subscription asking for a different window keeps its own.
+func (h *ConnectionHandler) batchSettings(window time.Duration, maxSize int) (time.Duration, int) {
+	effective := window
+	if h.server != nil && h.server.batchTuner != nil {
+		effective = h.server.batchTuner.Window()
+	}
+	if sub := h.conn.GetSubscription(); sub != nil {
+		if sub.Options.BatchWindow > 0 && sub.Options.BatchWindow != window {
+			effective = sub.Options.BatchWindow
+		}
+		if sub.Options.MaxBatchSize > 0 {
+			maxSize = sub.Options.MaxBatchSize
+		}
+	}
+	return h.conn.scaleBatchWindow(effective), maxSize
+}
+
+// filterTicksBySubscription filters ticks based on the connection's subscription mode and symbols.
 func (h *ConnectionHandler) filterTicksBySubscription(ticks []*pb.Tick) []*pb.Tick {
 	subscription := h.conn.GetSubscription()
 	if subscription == nil {
@@ -134,7 +272,7 @@ func (h *ConnectionHandler) filterTicksBySubscription(ticks []*pb.Tick) []*pb.Ti
 	// Filter ticks that match the subscription mode
 	filtered := make([]*pb.Tick, 0, len(ticks))
 	for _, tick := range ticks {
-		if tick.Mode == subscription.Mode {
+		if tick.Mode == subscription.Mode && subscription.wantsSymbol(tick.Symbol) {
 			filtered = append(filtered, tick)
 		}
 	}
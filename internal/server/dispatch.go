@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// ErrFrameRateLimited is returned when a client sends frames faster than
+// MaxFramesPerSecond. The frame is dropped but the connection stays open.
+var ErrFrameRateLimited = errors.New("frame rate limit exceeded")
+
+// FrameHandler processes one frame received on an authenticated connection.
+// Returning an error ends the connection after the client is told why.
+type FrameHandler func(ctx context.Context, h *ConnectionHandler, frame *protocol.Frame) error
+
+// FrameMiddleware wraps a FrameHandler, for example to validate, time, trace
+// or rate-limit frames.
+type FrameMiddleware func(messageType string, next FrameHandler) FrameHandler
+
+// Dispatcher routes frames to a handler per message type through a chain of
+// middleware. Handlers and middleware must be registered before the server
+// starts accepting connections.
+type Dispatcher struct {
+	handlers   map[protocol.MessageType]frameRoute
+	middleware []FrameMiddleware
+	chains     map[protocol.MessageType]FrameHandler // Handlers wrapped in middleware, rebuilt on registration
+}
+
+type frameRoute struct {
+	name    string
+	handler FrameHandler
+}
+
+// NewDispatcher creates a dispatcher with the built-in message handlers and
+// no middleware.
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{handlers: make(map[protocol.MessageType]frameRoute)}
+	d.Handle(protocol.MessageTypeHeartbeat, "heartbeat", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleHeartbeat(f)
+	})
+	d.Handle(protocol.MessageTypeSubscribe, "subscribe", func(ctx context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleSubscribe(ctx, f)
+	})
+	d.Handle(protocol.MessageTypeBatchAck, "batch_ack", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleBatchAck(f)
+	})
+	d.Handle(protocol.MessageTypeGapFill, "gap_fill", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleGapFill(f)
+	})
+	d.Handle(protocol.MessageTypeResume, "resume", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleResume(f)
+	})
+	d.Handle(protocol.MessageTypeSubscriptionUpdate, "subscription_update", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleSubscriptionUpdate(f)
+	})
+	d.Handle(protocol.MessageTypeSymbolList, "symbol_list", func(ctx context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleSymbolList(ctx, f)
+	})
+	d.Handle(protocol.MessageTypeHistory, "history", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleHistory(f)
+	})
+	d.Handle(protocol.MessageTypePublish, "publish", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handlePublish(f)
+	})
+	d.Handle(protocol.MessageTypeAuth, "auth", func(ctx context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		return h.handleAuth(ctx, f)
+	})
+	return d
+}
+
+// Handle registers fn for frames of msgType, replacing any existing handler.
+// name labels the message type in metrics and logs.
+func (d *Dispatcher) Handle(msgType protocol.MessageType, name string, fn FrameHandler) {
+	d.handlers[msgType] = frameRoute{name: name, handler: fn}
+	d.build()
+}
+
+// Use appends middleware. The first middleware registered runs outermost.
+func (d *Dispatcher) Use(mw ...FrameMiddleware) {
+	d.middleware = append(d.middleware, mw...)
+	d.build()
+}
+
+// Dispatch runs the handler registered for frame's type. Unregistered types
+// are rejected without reaching the middleware.
+func (d *Dispatcher) Dispatch(ctx context.Context, h *ConnectionHandler, frame *protocol.Frame) error {
+	fn, ok := d.chains[frame.Type]
+	if !ok {
+		err := protocol.ValidateMessageType(frame.Type)
+		if err == nil {
+			// A known type the server only sends, such as DATA_BATCH
+			err = protocol.ErrInvalidMessageType
+		}
+		h.logger.Error("invalid message type received",
+			"type", frame.Type,
+			"error", err,
+			"remote_addr", h.conn.RemoteAddr(),
+		)
+		return err
+	}
+	return fn(ctx, h, frame)
+}
+
+// build wraps every handler in the middleware chain.
+func (d *Dispatcher) build() {
+	chains := make(map[protocol.MessageType]FrameHandler, len(d.handlers))
+	for msgType, route := range d.handlers {
+		fn := route.handler
+		for i := len(d.middleware) - 1; i >= 0; i-- {
+			fn = d.middleware[i](route.name, fn)
+		}
+		chains[msgType] = fn
+	}
+	d.chains = chains
+}
+
+// MessageTypes returns the registered message types with their names.
+func (d *Dispatcher) MessageTypes() map[protocol.MessageType]string {
+	types := make(map[protocol.MessageType]string, len(d.handlers))
+	for msgType, route := range d.handlers {
+		types[msgType] = route.name
+	}
+	return types
+}
+
+// traceFrames logs every frame with its handling time and outcome. Handler
+// loggers already carry the connection and trace IDs.
+func traceFrames(messageType string, next FrameHandler) FrameHandler {
+	return func(ctx context.Context, h *ConnectionHandler, frame *protocol.Frame) error {
+		if !h.logger.Enabled(ctx, slog.LevelDebug) {
+			return next(ctx, h, frame)
+		}
+		start := time.Now()
+		err := next(ctx, h, frame)
+		h.logger.Debug("frame handled",
+			"message_type", messageType,
+			"payload_bytes", len(frame.Payload),
+			"duration", time.Since(start),
+			"error", err,
+		)
+		return err
+	}
+}
+
+// frameMetrics counts received frames by type and times their handling.
+func frameMetrics(pm *PrometheusMetrics) FrameMiddleware {
+	return func(messageType string, next FrameHandler) FrameHandler {
+		return func(ctx context.Context, h *ConnectionHandler, frame *protocol.Frame) error {
+			pm.IncrementMessagesReceived(messageType)
+			start := time.Now()
+			err := next(ctx, h, frame)
+			pm.RecordMessageProcessingDuration(time.Since(start))
+			return err
+		}
+	}
+}
+
+// limitFrameRate drops frames beyond the connection's MaxFramesPerSecond.
+func limitFrameRate(messageType string, next FrameHandler) FrameHandler {
+	return func(ctx context.Context, h *ConnectionHandler, frame *protocol.Frame) error {
+		if !h.frameLimiter.Allow() {
+			h.logger.Warn("frame rate limit exceeded", "message_type", messageType)
+			return ErrFrameRateLimited
+		}
+		return next(ctx, h, frame)
+	}
+}
+
+// defaultDispatcher serves handlers built without a Server.
+var defaultDispatcher = NewDispatcher()
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+const testMessageType protocol.MessageType = 0x40
+
+func TestDispatcherRunsMiddlewareAroundCustomHandlers(t *testing.T) {
+	handler := newFuzzHandler(t)
+	d := NewDispatcher()
+
+	var calls []string
+	record := func(label string) FrameMiddleware {
+		return func(messageType string, next FrameHandler) FrameHandler {
+			return func(ctx context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+				calls = append(calls, label+":"+messageType)
+				return next(ctx, h, f)
+			}
+		}
+	}
+	d.Use(record("outer"), record("inner"))
+	d.Handle(testMessageType, "custom", func(_ context.Context, h *ConnectionHandler, f *protocol.Frame) error {
+		assert.Same(t, handler.Conn(), h.Conn())
+		calls = append(calls, "handler:"+string(f.Payload))
+		return nil
+	})
+
+	require.NoError(t, d.Dispatch(context.Background(), handler, &protocol.Frame{Type: testMessageType, Payload: []byte("x")}))
+	assert.Equal(t, []string{"outer:custom", "inner:custom", "handler:x"}, calls)
+	assert.Equal(t, "custom", d.MessageTypes()[testMessageType])
+	assert.Equal(t, "heartbeat", d.MessageTypes()[protocol.MessageTypeHeartbeat])
+
+	calls = nil
+	err := d.Dispatch(context.Background(), handler, &protocol.Frame{Type: protocol.MessageTypeAuth})
+	assert.ErrorIs(t, err, protocol.ErrInvalidSequence)
+	assert.Equal(t, []string{"outer:auth", "inner:auth"}, calls)
+}
+
+func TestDispatcherRejectsUnregisteredTypes(t *testing.T) {
+	handler := newFuzzHandler(t)
+	d := NewDispatcher()
+
+	err := d.Dispatch(context.Background(), handler, &protocol.Frame{Type: protocol.MessageTypeDataBatch})
+	assert.ErrorIs(t, err, protocol.ErrInvalidMessageType, "server-to-client types are not accepted")
+
+	err = d.Dispatch(context.Background(), handler, &protocol.Frame{Type: testMessageType})
+	assert.ErrorIs(t, err, protocol.ErrInvalidFieldValue, "unknown types fail validation")
+}
+
+func TestLimitFrameRateDropsExcessFrames(t *testing.T) {
+	handler := newFuzzHandler(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+	handler.frameLimiter = NewAcceptLimiter(2, 2, clock)
+
+	handled := 0
+	d := NewDispatcher()
+	d.Use(limitFrameRate)
+	d.Handle(testMessageType, "custom", func(context.Context, *ConnectionHandler, *protocol.Frame) error {
+		handled++
+		return nil
+	})
+
+	frame := &protocol.Frame{Type: testMessageType}
+	require.NoError(t, d.Dispatch(context.Background(), handler, frame))
+	require.NoError(t, d.Dispatch(context.Background(), handler, frame))
+	assert.ErrorIs(t, d.Dispatch(context.Background(), handler, frame), ErrFrameRateLimited)
+	assert.Equal(t, 2, handled, "the excess frame never reaches its handler")
+
+	clock.Advance(500 * time.Millisecond)
+	assert.NoError(t, d.Dispatch(context.Background(), handler, frame))
+}
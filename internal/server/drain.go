@@ -0,0 +1,213 @@
+// Package server implements gradual cohort draining for rolling restarts.
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Errors returned by StartDrain.
+var (
+	ErrDrainActive  = errors.New("a drain is already in progress")
+	ErrInvalidDrain = errors.New("invalid drain plan")
+)
+
+// DrainPlan describes which connections to drain and how fast.
+type DrainPlan struct {
+	Percent     float64       // Share of matching connections to drain, 0-100
+	StepPercent float64       // Share drained per Interval; 0 drains all at once
+	Interval    time.Duration // Time between steps
+	Class       PriorityClass // Only connections in this class; empty matches all
+	Grace       time.Duration // Time between the SERVER_CLOSING notice and the close
+}
+
+// DrainStatus reports the progress of a drain.
+type DrainStatus struct {
+	Active    bool          `json:"active"`
+	Class     PriorityClass `json:"class,omitempty"`
+	Target    int           `json:"target"`    // Connections the drain will close
+	StepSize  int           `json:"step_size"` // Connections closed per step
+	Interval  time.Duration `json:"interval"`
+	Drained   int           `json:"drained"`
+	StartedAt time.Time     `json:"started_at"`
+	EndedAt   time.Time     `json:"ended_at"`
+	Cancelled bool          `json:"cancelled,omitempty"`
+}
+
+// cohortDrain is a drain in progress.
+type cohortDrain struct {
+	plan   DrainPlan
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status DrainStatus
+}
+
+func (d *cohortDrain) Status() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+// StartDrain begins draining connections according to plan. Each selected
+// connection is sent an INFO_CODE_SERVER_CLOSING notice carrying a random
+// retry_after_ms within the step interval, then closed after the grace
+// period, so clients reconnect to other instances spread over time rather
+// than all at once. Connections are taken lowest priority first.
+func (s *Server) StartDrain(plan DrainPlan) (DrainStatus, error) {
+	if plan.Percent <= 0 || plan.Percent > 100 || plan.StepPercent < 0 || plan.StepPercent > 100 ||
+		plan.Interval < 0 || plan.Grace < 0 {
+		return DrainStatus{}, ErrInvalidDrain
+	}
+	if plan.StepPercent == 0 || plan.StepPercent > plan.Percent {
+		plan.StepPercent = plan.Percent
+	}
+
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.drain != nil && s.drain.Status().Active {
+		return DrainStatus{}, ErrDrainActive
+	}
+
+	matching := len(s.drainCandidates(plan.Class))
+	ctx, cancel := context.WithCancel(s.ctx)
+	d := &cohortDrain{
+		plan:   plan,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: DrainStatus{
+			Active:    true,
+			Class:     plan.Class,
+			Target:    int(math.Ceil(float64(matching) * plan.Percent / 100)),
+			StepSize:  max(1, int(math.Ceil(float64(matching)*plan.StepPercent/100))),
+			Interval:  plan.Interval,
+			StartedAt: s.config.clock().Now(),
+		},
+	}
+	s.drain = d
+
+	s.logger.Info("cohort drain started",
+		"class", string(plan.Class),
+		"target", d.status.Target,
+		"step_size", d.status.StepSize,
+		"interval", plan.Interval,
+	)
+	go s.runDrain(ctx, d)
+	return d.Status(), nil
+}
+
+// CancelDrain stops the drain in progress. Connections already notified
+// still close. It reports whether a drain was active.
+func (s *Server) CancelDrain() bool {
+	s.drainMu.Lock()
+	d := s.drain
+	s.drainMu.Unlock()
+	if d == nil || !d.Status().Active {
+		return false
+	}
+	d.mu.Lock()
+	d.status.Cancelled = true
+	d.mu.Unlock()
+	d.cancel()
+	<-d.done
+	return true
+}
+
+// DrainStatus returns the current or most recent drain, if any.
+func (s *Server) DrainStatus() (DrainStatus, bool) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.drain == nil {
+		return DrainStatus{}, false
+	}
+	return s.drain.Status(), true
+}
+
+func (s *Server) runDrain(ctx context.Context, d *cohortDrain) {
+	defer close(d.done)
+	clock := s.config.clock()
+	for {
+		st := d.Status()
+		n := min(st.StepSize, st.Target-st.Drained)
+		drained := 0
+		if n > 0 && ctx.Err() == nil {
+			drained = s.drainConnections(n, d.plan)
+		}
+
+		d.mu.Lock()
+		d.status.Drained += drained
+		finished := d.status.Drained >= d.status.Target || drained == 0
+		d.mu.Unlock()
+		if finished {
+			break
+		}
+
+		timer := clock.NewTimer(d.plan.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+
+	d.mu.Lock()
+	d.status.Active = false
+	d.status.EndedAt = clock.Now()
+	st := d.status
+	d.mu.Unlock()
+	d.cancel()
+	s.logger.Info("cohort drain finished", "drained", st.Drained, "target", st.Target, "cancelled", st.Cancelled)
+}
+
+// drainCandidates returns the connections in class that have not been told
+// to close, in shedOrder.
+func (s *Server) drainCandidates(class PriorityClass) []*Connection {
+	conns := s.connections.filter(func(conn *Connection) bool {
+		return (class == "" || conn.Priority() == class) && !conn.drainNotified.Load()
+	})
+	shedOrder(conns)
+	return conns
+}
+
+// drainConnections notifies and schedules the close of up to n connections.
+func (s *Server) drainConnections(n int, plan DrainPlan) int {
+	clock := s.config.clock()
+	drained := 0
+	for _, conn := range s.drainCandidates(plan.Class) {
+		if drained == n {
+			break
+		}
+		if !conn.drainNotified.CompareAndSwap(false, true) {
+			continue
+		}
+		drained++
+
+		// Spread reconnects across the step so they do not land together
+		var retryAfter time.Duration
+		if plan.Interval > 0 {
+			retryAfter = time.Duration(rand.Int63n(int64(plan.Interval)))
+		}
+		class := conn.Priority()
+		_ = conn.SendInfo(pb.InfoCode_INFO_CODE_SERVER_CLOSING, "server is draining connections", map[string]string{
+			"reason":             "drain",
+			"grace_ms":           strconv.FormatInt(plan.Grace.Milliseconds(), 10),
+			"retry_after_ms":     strconv.FormatInt(retryAfter.Milliseconds(), 10),
+		})
+		if s.prometheusMetrics != nil {
+			s.prometheusMetrics.IncrementConnectionsDrained(s.instanceID, class)
+		}
+		clock.AfterFunc(plan.Grace, func() {
+			_ = conn.SendErrorCodeSync(pb.ErrorCode_ERROR_CODE_SERVER_DRAINING, retryAfter)
+			conn.Close()
+		})
+	}
+	return drained
+}
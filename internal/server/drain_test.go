@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// drainTestServer returns a server with n bronze connections whose clients
+// record SERVER_CLOSING notices.
+func drainTestServer(t *testing.T, n int) (*Server, *FakeClock, func() map[string]*pb.InfoMessage) {
+	t.Helper()
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	config := DefaultConfig()
+	config.Clock = clock
+	sched, err := NewQoSScheduler(config, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := &Server{
+		config: config,
+		ctx:    ctx,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	var mu sync.Mutex
+	notices := make(map[string]*pb.InfoMessage)
+	for i := 0; i < n; i++ {
+		serverSide, clientSide := net.Pipe()
+		conn := NewConnection(serverSide, config)
+		conn.id = string(rune('a' + i))
+		conn.SetPriority(sched, PriorityBronze)
+		srv.connections.add(conn)
+		t.Cleanup(func() {
+			clientSide.Close()
+			conn.Close()
+		})
+		go func(id string) {
+			reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+			for {
+				frame, err := reader.ReadFrame()
+				if err != nil {
+					return
+				}
+				var info pb.InfoMessage
+				if frame.Type == protocol.MessageTypeInfo && protocol.UnmarshalMessage(frame, &info) == nil {
+					mu.Lock()
+					notices[id] = &info
+					mu.Unlock()
+				}
+			}
+		}(conn.id)
+	}
+	received := func() map[string]*pb.InfoMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		out := make(map[string]*pb.InfoMessage, len(notices))
+		for id, info := range notices {
+			out[id] = info
+		}
+		return out
+	}
+	return srv, clock, received
+}
+
+func closedCount(srv *Server) int {
+	n := 0
+	srv.connections.each(func(conn *Connection) {
+		if conn.closed.Load() {
+			n++
+		}
+	})
+	return n
+}
+
+// waitForTimers waits until the drain has scheduled n fake timers.
+func waitForTimers(t *testing.T, clock *FakeClock, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestCohortDrainClosesConnectionsInSteps(t *testing.T) {
+	srv, clock, received := drainTestServer(t, 10)
+
+	status, err := srv.StartDrain(DrainPlan{Percent: 50, StepPercent: 20, Interval: time.Minute, Grace: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, 5, status.Target)
+	assert.Equal(t, 2, status.StepSize)
+
+	// First step: two notices, two grace timers and the step timer
+	waitForTimers(t, clock, 3)
+	require.Eventually(t, func() bool { return len(received()) == 2 }, time.Second, time.Millisecond)
+	for _, info := range received() {
+		assert.Equal(t, pb.InfoCode_INFO_CODE_SERVER_CLOSING, info.Code)
+		assert.Equal(t, "1000", info.Metadata["grace_ms"])
+		assert.NotEmpty(t, info.Metadata["retry_after_ms"])
+	}
+	assert.Equal(t, 0, closedCount(srv), "connections stay open for the grace period")
+	clock.Advance(time.Second)
+	assert.Equal(t, 2, closedCount(srv))
+
+	clock.Advance(time.Minute)
+	waitForTimers(t, clock, 3)
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		st, _ := srv.DrainStatus()
+		return !st.Active
+	}, time.Second, time.Millisecond)
+	clock.Advance(time.Second)
+
+	st, _ := srv.DrainStatus()
+	assert.Equal(t, 5, st.Drained)
+	assert.Equal(t, 5, closedCount(srv))
+	assert.Len(t, received(), 5)
+}
+
+func TestDrainClosesWithServerDraining(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	config := DefaultConfig()
+	config.Clock = clock
+	srv := &Server{config: config, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	srv.connections.add(conn)
+	t.Cleanup(func() {
+		clientSide.Close()
+		conn.Close()
+	})
+
+	go srv.drainConnections(1, DrainPlan{Grace: time.Second, Interval: time.Minute})
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeInfo, frame.Type)
+
+	waitForTimers(t, clock, 1)
+	go clock.Advance(time.Second)
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	var resp pb.ErrorResponse
+	require.NoError(t, protocol.UnmarshalMessage(frame, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_SERVER_DRAINING, resp.Code)
+	assert.True(t, resp.Retryable)
+	require.Eventually(t, func() bool { return conn.closed.Load() }, time.Second, time.Millisecond)
+}
+
+func TestCohortDrainFiltersByClassAndCancels(t *testing.T) {
+	srv, clock, _ := drainTestServer(t, 4)
+
+	status, err := srv.StartDrain(DrainPlan{Percent: 100, Class: PriorityGold, Interval: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Target, "no gold connections")
+	require.Eventually(t, func() bool {
+		st, _ := srv.DrainStatus()
+		return !st.Active
+	}, time.Second, time.Millisecond)
+
+	_, err = srv.StartDrain(DrainPlan{Percent: 100, StepPercent: 25, Interval: time.Minute})
+	require.NoError(t, err)
+	_, err = srv.StartDrain(DrainPlan{Percent: 100})
+	assert.ErrorIs(t, err, ErrDrainActive)
+
+	waitForTimers(t, clock, 2)
+	assert.True(t, srv.CancelDrain())
+	st, _ := srv.DrainStatus()
+	assert.False(t, st.Active)
+	assert.True(t, st.Cancelled)
+	assert.Equal(t, 1, st.Drained)
+	assert.False(t, srv.CancelDrain())
+}
+
+func TestAdminDrainEndpoint(t *testing.T) {
+	srv, _, _ := drainTestServer(t, 2)
+	srv.config.AdminToken = "secret"
+	mux := http.NewServeMux()
+	srv.registerAdminRoutes(mux)
+
+	do := func(method, body string) int {
+		req := httptest.NewRequest(method, "/admin/drain", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, ""))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, `{"percent":150}`))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, `{"class":"platinum"}`))
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, `{"percent":100,"grace":"0s"}`))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, ""))
+	require.Eventually(t, func() bool {
+		st, _ := srv.DrainStatus()
+		return !st.Active
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// How a connection authenticating with the client_id of a connected session
+// is handled. Sessions are matched on username and client_id; connections
+// without a client_id are never duplicates.
+const (
+	ClientPolicyAllow    = "allow"    // Both connections stay up
+	ClientPolicyReject   = "reject"   // The new connection is refused with DUPLICATE_SESSION
+	ClientPolicyTakeover = "takeover" // Last login wins: the old connection gets DUPLICATE_SESSION and is closed
+)
+
+// ErrDuplicateSession is returned when a connection is refused because its
+// client_id is already connected.
+var ErrDuplicateSession = errors.New("client_id already connected")
+
+// clientRegistry tracks the connection holding each username/client_id.
+type clientRegistry struct {
+	mu    sync.Mutex
+	conns map[string]*Connection
+}
+
+// claim registers conn under key. When another live connection holds the key
+// it is replaced and returned if takeover is set; otherwise it is kept and
+// returned with ok false.
+func (r *clientRegistry) claim(key string, conn *Connection, takeover bool) (prev *Connection, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conns == nil {
+		r.conns = make(map[string]*Connection)
+	}
+	prev = r.conns[key]
+	if prev != nil && prev.closed.Load() {
+		prev = nil
+	}
+	if prev != nil && !takeover {
+		return prev, false
+	}
+	r.conns[key] = conn
+	return prev, true
+}
+
+// release forgets key if conn still holds it.
+func (r *clientRegistry) release(key string, conn *Connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conns[key] == conn {
+		delete(r.conns, key)
+	}
+}
+
+// claimClientID applies Config.DuplicateClientPolicy to an authenticated
+// connection, refusing it with DUPLICATE_SESSION or evicting the connection
+// it replaces. The returned func releases the claim on disconnect.
+func (s *Server) claimClientID(conn *Connection) (func(), error) {
+	policy := s.config.DuplicateClientPolicy
+	key := retentionKey(conn.Session())
+	if key == "" || (policy != ClientPolicyReject && policy != ClientPolicyTakeover) {
+		return func() {}, nil
+	}
+
+	prev, ok := s.clients.claim(key, conn, policy == ClientPolicyTakeover)
+	if !ok {
+		conn.Logger().Warn("refused duplicate client session",
+			"client_id", conn.Session().ClientID,
+			"existing_connection_id", prev.ID(),
+		)
+		_ = conn.SendErrorCodeSync(pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION, 0)
+		return nil, ErrDuplicateSession
+	}
+	if prev != nil {
+		prev.Logger().Info("client session taken over by a new connection",
+			"client_id", conn.Session().ClientID,
+			"new_connection_id", conn.ID(),
+		)
+		// Writing the error may block on a slow client; keep it off the new session
+		go func() {
+			_ = prev.SendErrorCodeSync(pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION, 0)
+			prev.Close()
+		}()
+	}
+	return func() { s.clients.release(key, conn) }, nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func duplicateClientServer(t *testing.T, policy string) *Server {
+	t.Helper()
+	config := DefaultConfig()
+	config.DuplicateClientPolicy = policy
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &Server{
+		config: config,
+		ctx:    ctx,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// duplicateClientConn returns a connection authenticated as user/clientID
+// and a channel of the error codes it is sent.
+func duplicateClientConn(t *testing.T, srv *Server, user, clientID string) (*Connection, <-chan pb.ErrorCode) {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, srv.config)
+	conn.SetAuthenticated(&auth.Session{Username: user, ClientID: clientID, Authenticated: true})
+	t.Cleanup(func() {
+		clientSide.Close()
+		conn.Close()
+	})
+	codes := make(chan pb.ErrorCode, 4)
+	go func() {
+		reader := protocol.NewFrameReader(clientSide, srv.config.MaxMessageSize)
+		for {
+			frame, err := reader.ReadFrame()
+			if err != nil {
+				return
+			}
+			var resp pb.ErrorResponse
+			if frame.Type == protocol.MessageTypeError && protocol.UnmarshalMessage(frame, &resp) == nil {
+				codes <- resp.Code
+			}
+		}
+	}()
+	return conn, codes
+}
+
+func TestDuplicateClientReject(t *testing.T) {
+	srv := duplicateClientServer(t, ClientPolicyReject)
+	first, firstCodes := duplicateClientConn(t, srv, "alice", "desk-1")
+	second, secondCodes := duplicateClientConn(t, srv, "alice", "desk-1")
+
+	release, err := srv.claimClientID(first)
+	require.NoError(t, err)
+
+	_, err = srv.claimClientID(second)
+	assert.ErrorIs(t, err, ErrDuplicateSession)
+	select {
+	case code := <-secondCodes:
+		assert.Equal(t, pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION, code)
+	case <-time.After(time.Second):
+		t.Fatal("refused connection was not sent DUPLICATE_SESSION")
+	}
+	assert.False(t, first.closed.Load())
+	assert.Empty(t, firstCodes)
+
+	// Another client_id or user is not a duplicate
+	other, _ := duplicateClientConn(t, srv, "alice", "desk-2")
+	_, err = srv.claimClientID(other)
+	assert.NoError(t, err)
+	bob, _ := duplicateClientConn(t, srv, "bob", "desk-1")
+	_, err = srv.claimClientID(bob)
+	assert.NoError(t, err)
+
+	// The client_id is free again once the first connection ends
+	release()
+	_, err = srv.claimClientID(second)
+	assert.NoError(t, err)
+}
+
+func TestDuplicateClientTakeover(t *testing.T) {
+	srv := duplicateClientServer(t, ClientPolicyTakeover)
+	first, firstCodes := duplicateClientConn(t, srv, "alice", "desk-1")
+	second, secondCodes := duplicateClientConn(t, srv, "alice", "desk-1")
+
+	releaseFirst, err := srv.claimClientID(first)
+	require.NoError(t, err)
+	_, err = srv.claimClientID(second)
+	require.NoError(t, err)
+
+	select {
+	case code := <-firstCodes:
+		assert.Equal(t, pb.ErrorCode_ERROR_CODE_DUPLICATE_SESSION, code)
+	case <-time.After(time.Second):
+		t.Fatal("evicted connection was not sent DUPLICATE_SESSION")
+	}
+	require.Eventually(t, first.closed.Load, time.Second, time.Millisecond)
+	assert.False(t, second.closed.Load())
+	assert.Empty(t, secondCodes)
+
+	// The evicted connection ending must not release the new one's claim
+	releaseFirst()
+	third, _ := duplicateClientConn(t, srv, "alice", "desk-1")
+	srv.config.DuplicateClientPolicy = ClientPolicyReject
+	_, err = srv.claimClientID(third)
+	assert.ErrorIs(t, err, ErrDuplicateSession)
+}
+
+func TestDuplicateClientAllow(t *testing.T) {
+	srv := duplicateClientServer(t, ClientPolicyAllow)
+	first, _ := duplicateClientConn(t, srv, "alice", "desk-1")
+	second, _ := duplicateClientConn(t, srv, "alice", "desk-1")
+	_, err := srv.claimClientID(first)
+	require.NoError(t, err)
+	_, err = srv.claimClientID(second)
+	require.NoError(t, err)
+	assert.False(t, first.closed.Load())
+
+	// Connections without a client_id are never tracked
+	srv.config.DuplicateClientPolicy = ClientPolicyReject
+	anon1, _ := duplicateClientConn(t, srv, "alice", "")
+	anon2, _ := duplicateClientConn(t, srv, "alice", "")
+	_, err = srv.claimClientID(anon1)
+	require.NoError(t, err)
+	_, err = srv.claimClientID(anon2)
+	assert.NoError(t, err)
+}
+
+func TestLoadDuplicateClientPolicyFromEnv(t *testing.T) {
+	t.Setenv("DUPLICATE_CLIENT_POLICY", "Takeover")
+	config := DefaultConfig()
+	LoadConfigFromEnv(config)
+	assert.Equal(t, ClientPolicyTakeover, config.DuplicateClientPolicy)
+
+	t.Setenv("DUPLICATE_CLIENT_POLICY", "kick")
+	config = DefaultConfig()
+	LoadConfigFromEnv(config)
+	assert.Equal(t, ClientPolicyAllow, config.DuplicateClientPolicy)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// egressShaper is a token bucket of bytes refilled at rate per second up to
+// burst. Unlike AcceptLimiter it never refuses: a write larger than the
+// tokens left takes the bucket into debt, and the writer waits for the debt
+// to be repaid, so frames keep their order and are only ever delayed.
+type egressShaper struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newEgressShaper creates a shaper for rate bytes per second with bursts of
+// up to burst bytes, or nil when rate is not positive. A burst of 0 defaults
+// to one second's worth of bytes.
+func newEgressShaper(rate float64, burst int, clock Clock) *egressShaper {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Ceil(rate)
+	}
+	return &egressShaper{rate: rate, burst: b, clock: clock, tokens: b, last: clock.Now()}
+}
+
+// reserve takes n bytes from the bucket and returns how long the writer must
+// wait before sending them. A nil shaper never delays.
+func (s *egressShaper) reserve(n int64) time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = min(s.burst, s.tokens+elapsed.Seconds()*s.rate)
+		s.last = now
+	}
+	s.tokens -= float64(n)
+	if s.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-s.tokens / s.rate * float64(time.Second))
+}
+
+// parseUserEgressRates parses "username=bytes_per_second" entries.
+func parseUserEgressRates(items []string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(items))
+	for _, item := range items {
+		user, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(user) == "" {
+			return nil, fmt.Errorf("invalid user egress rate %q: expected username=bytes_per_second", item)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid egress rate for user %q", strings.TrimSpace(user))
+		}
+		rates[strings.TrimSpace(user)] = rate
+	}
+	return rates, nil
+}
+
+// EgressLimits shapes what each authenticated connection sends: every
+// connection has its own bucket at the user's rate, or EgressRate for users
+// without one, connections of a tenant with an egress_rate also share the
+// tenant's bucket, and all connections share the global budget fairly.
+type EgressLimits struct {
+	rate   float64
+	burst  int
+	users  map[string]float64
+	clock  Clock
+	global *fairEgress // nil without a global budget
+
+	delayed atomic.Uint64 // Frames held back by a shaper
+	delay   atomic.Int64  // Total time frames were held back, in nanoseconds
+}
+
+// NewEgressLimits builds the per-connection limits from config.
+func NewEgressLimits(config *Config) (*EgressLimits, error) {
+	users, err := parseUserEgressRates(config.UserEgressRates)
+	if err != nil {
+		return nil, err
+	}
+	return &EgressLimits{
+		rate:   config.EgressRate,
+		burst:  config.EgressBurst,
+		users:  users,
+		clock:  config.clock(),
+		global: newFairEgress(config.GlobalEgressRate, config.GlobalEgressBurst, config.clock()),
+	}, nil
+}
+
+// forSession returns the shaping for a connection of username in tenant, or
+// nil when nothing limits it. weight is the connection's share of the global
+// budget relative to other connections.
+func (l *EgressLimits) forSession(username string, tenant *Tenant, weight float64) *connEgress {
+	rate, ok := l.users[username]
+	if !ok {
+		rate = l.rate
+	}
+	e := &connEgress{conn: newEgressShaper(rate, l.burst, l.clock), flow: l.global.flow(weight), limits: l}
+	if tenant != nil {
+		e.tenant = tenant.egress
+	}
+	if e.conn == nil && e.tenant == nil && e.flow == nil {
+		return nil
+	}
+	return e
+}
+
+// EgressStats summarizes shaping across connections.
+type EgressStats struct {
+	DelayedWrites uint64
+	Delay         time.Duration
+	Global        FairEgressStats
+}
+
+// Stats returns the shaping so far.
+func (l *EgressLimits) Stats() EgressStats {
+	return EgressStats{
+		DelayedWrites: l.delayed.Load(),
+		Delay:         time.Duration(l.delay.Load()),
+		Global:        l.global.Stats(),
+	}
+}
+
+// GetStats returns the limits and shaping for Server.GetStats.
+func (l *EgressLimits) GetStats() map[string]interface{} {
+	st := l.Stats()
+	return map[string]interface{}{
+		"rate_bytes":           l.rate,
+		"user_rates":           len(l.users),
+		"delayed_writes_total": st.DelayedWrites,
+		"delay_seconds_total":  st.Delay.Seconds(),
+		"global": map[string]interface{}{
+			"rate_bytes":           l.globalRate(),
+			"bytes_total":          st.Global.Bytes,
+			"delayed_writes_total": st.Global.DelayedWrites,
+			"delay_seconds_total":  st.Global.Delay.Seconds(),
+			"waiting":              st.Global.Waiting,
+		},
+	}
+}
+
+// globalRate returns the global budget in bytes per second, or 0.
+func (l *EgressLimits) globalRate() float64 {
+	if l.global == nil {
+		return 0
+	}
+	return l.global.rate
+}
+
+// connEgress is the shaping applied to one connection.
+type connEgress struct {
+	conn   *egressShaper // nil when the connection is not limited
+	tenant *egressShaper // Shared by the tenant's connections; nil when unlimited
+	flow   *fairFlow     // Share of the global budget; nil without one
+	limits *EgressLimits
+}
+
+// reserve charges n bytes to both buckets and returns the longer wait.
+func (e *connEgress) reserve(n int64) time.Duration {
+	delay := max(e.conn.reserve(n), e.tenant.reserve(n))
+	if delay > 0 {
+		e.limits.delayed.Add(1)
+		e.limits.delay.Add(int64(delay))
+	}
+	return delay
+}
+
+// acquireGlobal waits for the connection's fair share of the global budget
+// to cover n bytes. It reports false if cancel closes first.
+func (e *connEgress) acquireGlobal(n int64, cancel <-chan struct{}) bool {
+	if e.flow == nil {
+		return true
+	}
+	return e.limits.global.acquire(e.flow, n, cancel)
+}
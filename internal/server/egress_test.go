@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func TestEgressShaperDelaysOverRate(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	assert.Nil(t, newEgressShaper(0, 100, clock))
+
+	s := newEgressShaper(1000, 500, clock)
+	assert.Zero(t, s.reserve(500), "the burst is sent at once")
+	assert.Equal(t, 200*time.Millisecond, s.reserve(200))
+
+	// The debt is repaid before the bucket refills
+	clock.Advance(200 * time.Millisecond)
+	assert.Zero(t, s.reserve(0))
+	clock.Advance(time.Hour)
+	assert.Zero(t, s.reserve(500), "refills stop at the burst")
+	assert.Equal(t, time.Millisecond, s.reserve(1))
+
+	// A burst of 0 is one second's worth
+	assert.Equal(t, 1000.0, newEgressShaper(1000, 0, clock).burst)
+}
+
+func TestParseUserEgressRates(t *testing.T) {
+	rates, err := parseUserEgressRates([]string{"alice=1000", " bob = 2.5e6 "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"alice": 1000, "bob": 2.5e6}, rates)
+
+	for _, bad := range []string{"alice", "=10", "alice=fast", "alice=-1"} {
+		_, err := parseUserEgressRates([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEgressLimitsForSession(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	config := DefaultConfig()
+	config.Clock = clock
+	config.UserEgressRates = []string{"heavy=100"}
+	limits, err := NewEgressLimits(config)
+	require.NoError(t, err)
+	assert.Nil(t, limits.forSession("alice", nil, 1), "unlimited without a rate")
+
+	registry, err := NewTenantRegistry([]TenantConfig{{Name: "acme", EgressRate: 1000, EgressBurst: 1000}}, clock)
+	require.NoError(t, err)
+	tenant := registry.tenants["acme"]
+
+	// Connections of a tenant share its bucket
+	a, b := limits.forSession("alice", tenant, 1), limits.forSession("bob", tenant, 1)
+	assert.Zero(t, a.reserve(1000))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(500))
+
+	// A user's own rate applies on top
+	heavy := limits.forSession("heavy", nil, 1)
+	assert.Zero(t, heavy.reserve(100))
+	assert.Equal(t, time.Second, heavy.reserve(100))
+	assert.Equal(t, EgressStats{DelayedWrites: 2, Delay: 1500 * time.Millisecond}, limits.Stats())
+}
+
+func TestConnectionShapesEgress(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	config := DefaultConfig()
+	config.Clock = clock
+	frame := &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.MessageTypeHeartbeat}
+	size := frameWireSize(frame)
+	config.EgressRate = 1
+	config.EgressBurst = int(size)
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnection(serverSide, config)
+	defer conn.Close()
+	limits, err := NewEgressLimits(config)
+	require.NoError(t, err)
+	conn.setEgress(limits.forSession("alice", nil, 1))
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+
+	// The first frame fits the burst
+	require.NoError(t, conn.WriteFrame(frame))
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = reader.ReadFrame()
+	require.NoError(t, err)
+
+	// The second waits until the debt is repaid
+	require.NoError(t, conn.WriteFrame(frame))
+	clientSide.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = reader.ReadFrame()
+	require.Error(t, err, "nothing is sent while over the egress rate")
+
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.waiters) > 0
+	}, time.Second, time.Millisecond)
+	clock.Advance(time.Duration(size) * time.Second)
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = reader.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), limits.Stats().DelayedWrites)
+}
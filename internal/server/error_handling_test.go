@@ -112,23 +112,11 @@ func TestGetStandardErrorMessage(t *testing.T) {
 
 // TestErrorCodeCoverage ensures all error codes have standard messages
 func TestErrorCodeCoverage(t *testing.T) {
-	errorCodes := []pb.ErrorCode{
-		pb.ErrorCode_ERROR_CODE_INVALID_AUTH,
-		pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED,
-		pb.ErrorCode_ERROR_CODE_ALREADY_AUTHENTICATED,
-		pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
-		pb.ErrorCode_ERROR_CODE_ALREADY_SUBSCRIBED,
-		pb.ErrorCode_ERROR_CODE_NOT_SUBSCRIBED,
-		pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT,
-		pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE,
-		pb.ErrorCode_ERROR_CODE_CHECKSUM_FAILED,
-		pb.ErrorCode_ERROR_CODE_PROTOCOL_VERSION,
-		pb.ErrorCode_ERROR_CODE_MESSAGE_TOO_LARGE,
-		pb.ErrorCode_ERROR_CODE_RATE_LIMITED,
-		pb.ErrorCode_ERROR_CODE_INTERNAL_ERROR,
-	}
-
-	for _, code := range errorCodes {
+	for value := range pb.ErrorCode_name {
+		code := pb.ErrorCode(value)
+		if code == pb.ErrorCode_ERROR_CODE_UNSPECIFIED {
+			continue
+		}
 		t.Run(code.String(), func(t *testing.T) {
 			message, details := getStandardErrorMessage(code)
 			assert.NotEmpty(t, message, "Error code %s should have a message", code.String())
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fairEgress is the server-wide outbound budget: a token bucket of bytes
+// shared by every connection and handed out by self-clocked weighted fair
+// queuing. Each write is tagged with a virtual finish time advanced by its
+// size over its connection's weight, and waiting writes are released in tag
+// order as the bucket refills. Under saturation every backlogged connection
+// therefore gets bandwidth in proportion to its weight, instead of whichever
+// enqueues first taking it all.
+type fairEgress struct {
+	rate  float64
+	burst float64
+	clock Clock
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	vtime   float64 // Finish tag of the write last released
+	seq     uint64  // Breaks ties between equal tags in arrival order
+	waiting fairQueue
+	timer   Timer // Releases the head of waiting once the bucket covers it
+
+	bytes   atomic.Uint64 // Bytes released
+	delayed atomic.Uint64 // Writes that had to wait for their share
+	delay   atomic.Int64  // Total time writes waited, in nanoseconds
+}
+
+// fairFlow is one connection's place in the fair queue.
+type fairFlow struct {
+	weight float64
+	finish float64 // Tag of the flow's last released write; guarded by fairEgress.mu
+}
+
+// fairRequest is a write waiting for its share.
+type fairRequest struct {
+	flow  *fairFlow
+	tag   float64
+	seq   uint64
+	n     float64
+	ready chan struct{}
+	index int // Position in the queue, -1 once released
+}
+
+// fairQueue orders waiting writes by tag, implementing heap.Interface.
+type fairQueue []*fairRequest
+
+func (q fairQueue) Len() int { return len(q) }
+
+func (q fairQueue) Less(i, j int) bool {
+	if q[i].tag != q[j].tag {
+		return q[i].tag < q[j].tag
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q fairQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *fairQueue) Push(x any) {
+	r := x.(*fairRequest)
+	r.index = len(*q)
+	*q = append(*q, r)
+}
+
+func (q *fairQueue) Pop() any {
+	old := *q
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	r.index = -1
+	*q = old[:len(old)-1]
+	return r
+}
+
+// newFairEgress creates a budget of rate bytes per second with bursts of up
+// to burst bytes, or nil when rate is not positive. A burst of 0 defaults to
+// one second's worth of bytes.
+func newFairEgress(rate float64, burst int, clock Clock) *fairEgress {
+	shaper := newEgressShaper(rate, burst, clock)
+	if shaper == nil {
+		return nil
+	}
+	return &fairEgress{rate: rate, burst: shaper.burst, clock: clock, tokens: shaper.burst, last: clock.Now()}
+}
+
+// flow creates a connection's flow with weight, which defaults to 1.
+func (f *fairEgress) flow(weight float64) *fairFlow {
+	if f == nil {
+		return nil
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return &fairFlow{weight: weight}
+}
+
+// acquire waits until flow's fair share of the budget covers a write of n
+// bytes. It reports false, giving up its place, if cancel closes first.
+func (f *fairEgress) acquire(flow *fairFlow, n int64, cancel <-chan struct{}) bool {
+	f.mu.Lock()
+	// The flow only moves on to tag once the write is released, so a
+	// cancelled write leaves later ones where they were
+	tag := max(f.vtime, flow.finish) + float64(n)/flow.weight
+	f.refill()
+	if f.waiting.Len() == 0 && f.tokens >= min(float64(n), f.burst) {
+		f.release(flow, tag, float64(n))
+		f.mu.Unlock()
+		return true
+	}
+
+	f.seq++
+	r := &fairRequest{flow: flow, tag: tag, seq: f.seq, n: float64(n), ready: make(chan struct{})}
+	heap.Push(&f.waiting, r)
+	f.dispatch()
+	f.mu.Unlock()
+
+	start := f.clock.Now()
+	select {
+	case <-r.ready:
+		f.delayed.Add(1)
+		f.delay.Add(int64(f.clock.Now().Sub(start)))
+		return true
+	case <-cancel:
+		f.mu.Lock()
+		if r.index >= 0 {
+			heap.Remove(&f.waiting, r.index)
+			f.dispatch()
+		}
+		f.mu.Unlock()
+		return false
+	}
+}
+
+// refill adds the tokens earned since the last call; f.mu must be held.
+func (f *fairEgress) refill() {
+	now := f.clock.Now()
+	if elapsed := now.Sub(f.last); elapsed > 0 {
+		f.tokens = min(f.burst, f.tokens+elapsed.Seconds()*f.rate)
+		f.last = now
+	}
+}
+
+// release charges a write of n bytes with tag to the bucket and advances
+// flow to it; f.mu must be held.
+func (f *fairEgress) release(flow *fairFlow, tag, n float64) {
+	flow.finish = max(flow.finish, tag)
+	f.tokens -= n
+	f.vtime = max(f.vtime, tag)
+	f.bytes.Add(uint64(n))
+}
+
+// dispatch releases waiting writes in tag order while the bucket covers them
+// and arms the timer for the next one; f.mu must be held. A write larger than
+// the burst is released once the bucket is full.
+func (f *fairEgress) dispatch() {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.refill()
+	for f.waiting.Len() > 0 {
+		head := f.waiting[0]
+		need := min(head.n, f.burst)
+		if f.tokens < need {
+			wait := time.Duration((need - f.tokens) / f.rate * float64(time.Second))
+			f.timer = f.clock.AfterFunc(max(wait, time.Millisecond), f.onTimer)
+			return
+		}
+		heap.Pop(&f.waiting)
+		f.release(head.flow, head.tag, head.n)
+		close(head.ready)
+	}
+}
+
+func (f *fairEgress) onTimer() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dispatch()
+}
+
+// FairEgressStats summarizes the global egress budget.
+type FairEgressStats struct {
+	Bytes         uint64
+	DelayedWrites uint64
+	Delay         time.Duration
+	Waiting       int // Writes currently waiting for their share
+}
+
+// Stats returns the budget's usage so far; zero for a nil budget.
+func (f *fairEgress) Stats() FairEgressStats {
+	if f == nil {
+		return FairEgressStats{}
+	}
+	f.mu.Lock()
+	waiting := f.waiting.Len()
+	f.mu.Unlock()
+	return FairEgressStats{
+		Bytes:         f.bytes.Load(),
+		DelayedWrites: f.delayed.Load(),
+		Delay:         time.Duration(f.delay.Load()),
+		Waiting:       waiting,
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForWaiting blocks until n writes are queued on f.
+func waitForWaiting(t *testing.T, f *fairEgress, n int) {
+	require.Eventually(t, func() bool {
+		return f.Stats().Waiting == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestFairEgressSharesByWeight(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	assert.Nil(t, newFairEgress(0, 0, clock))
+	f := newFairEgress(1000, 100, clock)
+
+	// Two connections stay backlogged with 100-byte frames
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var sent [2]atomic.Int64
+	for i, weight := range []float64{2, 1} {
+		flow := f.flow(weight)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f.acquire(flow, 100, stop) {
+				sent[i].Add(1)
+			}
+		}()
+	}
+
+	// The bucket refills one frame's worth at a time
+	for range 30 {
+		waitForWaiting(t, f, 2)
+		clock.Advance(100 * time.Millisecond)
+	}
+	waitForWaiting(t, f, 2)
+	close(stop)
+	wg.Wait()
+
+	heavy, light := sent[0].Load(), sent[1].Load()
+	assert.Equal(t, int64(31), heavy+light, "one burst plus one frame per refill")
+	assert.InDelta(t, 2*light, heavy, 2, "released in proportion to weight")
+	st := f.Stats()
+	assert.Equal(t, uint64(3100), st.Bytes)
+	assert.Equal(t, uint64(30), st.DelayedWrites)
+	assert.Zero(t, st.Waiting, "cancelled writes give up their place")
+}
+
+func TestFairEgressReleasesOversizedWriteWhenFull(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	f := newFairEgress(100, 100, clock)
+	flow := f.flow(0)
+	assert.Equal(t, 1.0, flow.weight, "weights default to 1")
+
+	require.True(t, f.acquire(flow, 100, nil))
+	done := make(chan bool)
+	go func() { done <- f.acquire(flow, 500, nil) }()
+	waitForWaiting(t, f, 1)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("released before the bucket refilled")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	assert.True(t, <-done)
+}
+
+func TestFairEgressCancelKeepsFlowTag(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	f := newFairEgress(1000, 100, clock)
+	flow := f.flow(1)
+
+	require.True(t, f.acquire(flow, 100, nil))
+	assert.Equal(t, 100.0, flow.finish)
+
+	cancel := make(chan struct{})
+	done := make(chan bool)
+	go func() { done <- f.acquire(flow, 10000, cancel) }()
+	waitForWaiting(t, f, 1)
+	close(cancel)
+	assert.False(t, <-done)
+	assert.Equal(t, 100.0, flow.finish, "a cancelled write is not charged to its flow")
+
+	clock.Advance(100 * time.Millisecond)
+	require.True(t, f.acquire(flow, 100, nil))
+	assert.Equal(t, 200.0, flow.finish)
+}
+
+func TestQoSEgressWeight(t *testing.T) {
+	var none *QoSScheduler
+	assert.Equal(t, 1.0, none.EgressWeight(PriorityGold))
+
+	sched, err := NewQoSScheduler(DefaultConfig(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, sched.EgressWeight(PriorityGold))
+	assert.Equal(t, 1.0, sched.EgressWeight(PriorityBronze))
+	assert.Equal(t, 1.0, sched.EgressWeight(""))
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// fanoutSubscriber is one subscription receiving broadcast ticks.
+type fanoutSubscriber struct {
+	subscription *Subscription
+	emit         func([]*pb.Tick)
+}
+
+// tickFanout broadcasts ticks from a single feed to every subscription
+// streaming from it. The zero value is ready to use.
+type tickFanout struct {
+	mu   sync.RWMutex
+	subs map[*fanoutSubscriber]struct{}
+
+	delivered atomic.Uint64 // Ticks handed to subscriptions
+}
+
+// Stream registers subscription for broadcast ticks until ctx is done.
+func (f *tickFanout) Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick)) {
+	sub := &fanoutSubscriber{subscription: subscription, emit: emit}
+	f.mu.Lock()
+	if f.subs == nil {
+		f.subs = make(map[*fanoutSubscriber]struct{})
+	}
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	f.mu.Lock()
+	delete(f.subs, sub)
+	f.mu.Unlock()
+}
+
+// broadcast emits to each subscription the ticks in its mode and symbols.
+func (f *tickFanout) broadcast(ticks []*pb.Tick) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for sub := range f.subs {
+		var batch []*pb.Tick
+		for _, tick := range ticks {
+			if tick.Mode == sub.subscription.Mode && sub.subscription.wantsSymbol(tick.Symbol) {
+				batch = append(batch, tick)
+			}
+		}
+		if len(batch) > 0 {
+			sub.emit(batch)
+			f.delivered.Add(uint64(len(batch)))
+		}
+	}
+}
+
+// subscribers returns the number of subscriptions streaming.
+func (f *tickFanout) subscribers() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.subs)
+}
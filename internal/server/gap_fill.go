@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+var (
+	// ErrGapUnavailable is returned when requested batches have aged out of the history.
+	ErrGapUnavailable = errors.New("requested batches are no longer available")
+)
+
+// BatchHistory is a fixed-size ring of recently sent batches used to answer
+// gap-fill requests without a full resubscribe.
+type BatchHistory struct {
+	mu    sync.Mutex
+	ring  []*pb.DataBatch
+	next  int // Index of the slot to overwrite next
+	count int
+	ticks int // Ticks across retained batches, for memory accounting
+}
+
+// NewBatchHistory creates a history holding up to size batches.
+func NewBatchHistory(size int) *BatchHistory {
+	if size <= 0 {
+		size = 256
+	}
+	return &BatchHistory{
+		ring: make([]*pb.DataBatch, size),
+	}
+}
+
+// Record stores a sent batch, overwriting the oldest when full.
+func (h *BatchHistory) Record(batch *pb.DataBatch) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old := h.ring[h.next]; old != nil {
+		h.ticks -= len(old.Ticks)
+	}
+	h.ring[h.next] = batch
+	h.ticks += len(batch.Ticks)
+	h.next = (h.next + 1) % len(h.ring)
+	if h.count < len(h.ring) {
+		h.count++
+	}
+}
+
+// Since returns retained batches with a sequence greater than lastSeq, in
+// send order. It returns ErrGapUnavailable if batches after lastSeq were sent
+// but have already been overwritten.
+func (h *BatchHistory) Since(lastSeq uint32) ([]*pb.DataBatch, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 {
+		return nil, nil
+	}
+
+	start := (h.next - h.count + len(h.ring)) % len(h.ring)
+	oldest := h.ring[start].BatchSequence
+	if lastSeq+1 < oldest {
+		return nil, ErrGapUnavailable
+	}
+
+	var out []*pb.DataBatch
+	for i := 0; i < h.count; i++ {
+		batch := h.ring[(start+i)%len(h.ring)]
+		if batch.BatchSequence > lastSeq {
+			out = append(out, batch)
+		}
+	}
+	return out, nil
+}
+
+// Len returns the number of retained batches.
+func (h *BatchHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Bytes returns the approximate memory held by retained batches.
+func (h *BatchHistory) Bytes() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return int64(h.ticks) * approxTickBytes
+}
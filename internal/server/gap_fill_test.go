@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestBatchHistorySince(t *testing.T) {
+	h := NewBatchHistory(4)
+
+	got, err := h.Since(0)
+	require.NoError(t, err)
+	assert.Empty(t, got, "empty history has nothing to replay")
+
+	for seq := uint32(1); seq <= 3; seq++ {
+		h.Record(&pb.DataBatch{BatchSequence: seq})
+	}
+	got, err = h.Since(1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3}, sequences(got))
+
+	got, err = h.Since(3)
+	require.NoError(t, err)
+	assert.Empty(t, got, "client is up to date")
+
+	// Wrap the ring: 1 and 2 are overwritten
+	for seq := uint32(4); seq <= 6; seq++ {
+		h.Record(&pb.DataBatch{BatchSequence: seq})
+	}
+	assert.Equal(t, 4, h.Len())
+
+	got, err = h.Since(2)
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{3, 4, 5, 6}, sequences(got))
+
+	_, err = h.Since(1)
+	assert.ErrorIs(t, err, ErrGapUnavailable)
+}
+
+func TestHandleGapFillReplaysMissingBatches(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	config := DefaultConfig()
+	config.GapFillBufferSize = 2
+	conn := NewConnection(serverSide, config)
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	require.NoError(t, conn.SetSubscription(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)))
+
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	readBatch := func() *pb.DataBatch {
+		clientSide.SetReadDeadline(time.Now().Add(time.Second))
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		require.Equal(t, protocol.MessageTypeDataBatch, frame.Type)
+		var batch pb.DataBatch
+		require.NoError(t, proto.Unmarshal(frame.Payload, &batch))
+		return &batch
+	}
+
+	for i := 1; i <= 3; i++ {
+		go conn.SendDataBatch([]*pb.Tick{{Symbol: "EURUSD"}})
+		assert.Equal(t, uint32(i), readBatch().BatchSequence, "batch sequences are contiguous")
+	}
+
+	gapFill := func(last uint32) *protocol.Frame {
+		payload, err := proto.Marshal(&pb.GapFillRequest{LastSequence: last})
+		require.NoError(t, err)
+		return &protocol.Frame{Type: protocol.MessageTypeGapFill, Payload: payload}
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- handler.processFrame(ctx, gapFill(1)) }()
+	assert.Equal(t, uint32(2), readBatch().BatchSequence)
+	assert.Equal(t, uint32(3), readBatch().BatchSequence)
+	require.NoError(t, <-errCh)
+
+	// Batch 1 has been overwritten in the two-slot history
+	go func() { errCh <- handler.processFrame(ctx, gapFill(0)) }()
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeError, frame.Type)
+	var resp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE, resp.Code)
+	require.NoError(t, <-errCh)
+}
@@ -0,0 +1,200 @@
+// Package server implements adaptive garbage collector tuning under memory pressure.
+package server
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// gcPressure is how close memory use is to the resource monitor's limit.
+type gcPressure int
+
+const (
+	gcPressureNormal gcPressure = iota
+	gcPressureWarning
+	gcPressureCritical
+)
+
+func (p gcPressure) String() string {
+	switch p {
+	case gcPressureWarning:
+		return "warning"
+	case gcPressureCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// gcRelaxMargin is how far usage must fall below a threshold before the tuner
+// steps back down, so it does not flap around the boundary.
+const gcRelaxMargin = 0.05
+
+// Lowest GOGC values the tuner will set at each pressure level.
+const (
+	minWarningGCPercent  = 25
+	minCriticalGCPercent = 10
+)
+
+// GCTuner lowers GOGC and tightens GOMEMLIMIT as memory use approaches the
+// resource monitor's warning and critical thresholds, spending CPU on more
+// frequent collections to keep headroom. The startup values are restored once
+// usage recovers.
+type GCTuner struct {
+	monitor *ResourceMonitor
+	logger  *slog.Logger
+
+	// Values in effect when the tuner was created
+	baseGCPercent   int
+	baseMemoryLimit int64
+
+	mu          sync.Mutex
+	pressure    gcPressure
+	gcPercent   int
+	memoryLimit int64
+	adjustments uint64
+
+	// Replaced in tests so the process-wide settings are left alone
+	setGCPercent   func(int) int
+	setMemoryLimit func(int64) int64
+}
+
+// NewGCTuner creates a tuner driven by monitor's memory usage.
+func NewGCTuner(monitor *ResourceMonitor, logger *slog.Logger) *GCTuner {
+	t := &GCTuner{
+		monitor:        monitor,
+		logger:         logger.With("component", "gc_tuner"),
+		setGCPercent:   debug.SetGCPercent,
+		setMemoryLimit: debug.SetMemoryLimit,
+	}
+	t.captureBaseline()
+	return t
+}
+
+// captureBaseline records the current GOGC and GOMEMLIMIT, including any set
+// through the environment.
+func (t *GCTuner) captureBaseline() {
+	t.baseGCPercent = t.setGCPercent(100)
+	t.setGCPercent(t.baseGCPercent)
+	t.baseMemoryLimit = t.setMemoryLimit(-1) // Negative reads without changing
+	t.gcPercent = t.baseGCPercent
+	t.memoryLimit = t.baseMemoryLimit
+}
+
+// Start adjusts the collector every interval until ctx is cancelled, then
+// restores the baseline.
+func (t *GCTuner) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				t.apply(gcPressureNormal, 0)
+				return
+			case <-ticker.C:
+				t.Adjust()
+			}
+		}
+	}()
+}
+
+// Adjust re-evaluates memory pressure and updates the collector if the
+// pressure level changed.
+func (t *GCTuner) Adjust() {
+	usage := t.monitor.GetResourceUsage()["memory"]
+
+	t.mu.Lock()
+	current := t.pressure
+	t.mu.Unlock()
+
+	t.apply(t.nextPressure(current, usage), usage)
+}
+
+// nextPressure maps usage (a fraction of the memory limit) to a pressure
+// level, stepping down only once usage is clear of the threshold.
+func (t *GCTuner) nextPressure(current gcPressure, usage float64) gcPressure {
+	t.monitor.mutex.RLock()
+	warning, critical := t.monitor.warningThreshold, t.monitor.criticalThreshold
+	t.monitor.mutex.RUnlock()
+
+	switch {
+	case usage >= critical:
+		return gcPressureCritical
+	case current == gcPressureCritical && usage > critical-gcRelaxMargin:
+		return gcPressureCritical
+	case usage >= warning:
+		return gcPressureWarning
+	case current >= gcPressureWarning && usage > warning-gcRelaxMargin:
+		return gcPressureWarning
+	default:
+		return gcPressureNormal
+	}
+}
+
+// settingsFor returns the GOGC and GOMEMLIMIT to use at pressure p.
+func (t *GCTuner) settingsFor(p gcPressure) (int, int64) {
+	if p == gcPressureNormal {
+		return t.baseGCPercent, t.baseMemoryLimit
+	}
+
+	t.monitor.mutex.RLock()
+	maxBytes := t.monitor.maxMemoryMB * 1024 * 1024
+	critical := t.monitor.criticalThreshold
+	t.monitor.mutex.RUnlock()
+
+	gcPercent, floor, limit := t.baseGCPercent/2, minWarningGCPercent, maxBytes
+	if p == gcPressureCritical {
+		// Collect hard enough to stay below the critical threshold
+		gcPercent, floor, limit = t.baseGCPercent/4, minCriticalGCPercent, int64(float64(maxBytes)*critical)
+	}
+	if t.baseGCPercent < 0 {
+		gcPercent = t.baseGCPercent // GOGC=off relies on the memory limit alone
+	} else if gcPercent < floor {
+		gcPercent = min(floor, t.baseGCPercent)
+	}
+	if limit <= 0 || limit > t.baseMemoryLimit {
+		limit = t.baseMemoryLimit // Never loosen an operator-set limit
+	}
+	return gcPercent, limit
+}
+
+func (t *GCTuner) apply(p gcPressure, usage float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p == t.pressure {
+		return
+	}
+	gcPercent, limit := t.settingsFor(p)
+	t.setGCPercent(gcPercent)
+	t.setMemoryLimit(limit)
+
+	t.logger.Info("adjusted garbage collector",
+		"pressure", p.String(),
+		"previous_pressure", t.pressure.String(),
+		"memory_usage", usage,
+		"gc_percent", gcPercent,
+		"previous_gc_percent", t.gcPercent,
+		"memory_limit_bytes", limit,
+		"previous_memory_limit_bytes", t.memoryLimit,
+	)
+	t.pressure, t.gcPercent, t.memoryLimit = p, gcPercent, limit
+	t.adjustments++
+}
+
+// GetStats returns the tuner's current settings for Server.GetStats.
+func (t *GCTuner) GetStats() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return map[string]interface{}{
+		"pressure":           t.pressure.String(),
+		"gc_percent":         t.gcPercent,
+		"memory_limit_bytes": t.memoryLimit,
+		"adjustments":        t.adjustments,
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGCTuner returns a tuner whose collector settings are recorded rather
+// than applied, starting from GOGC=100 and no memory limit.
+func fakeGCTuner(maxMemoryMB int64) (*GCTuner, *int, *int64) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	monitor := NewResourceMonitor(ResourceLimits{
+		MaxMemoryMB:       maxMemoryMB,
+		WarningThreshold:  0.8,
+		CriticalThreshold: 0.9,
+	}, logger)
+	gcPercent, memoryLimit := 100, int64(math.MaxInt64)
+	t := &GCTuner{
+		monitor: monitor,
+		logger:  logger,
+		setGCPercent: func(v int) int {
+			old := gcPercent
+			gcPercent = v
+			return old
+		},
+		setMemoryLimit: func(v int64) int64 {
+			old := memoryLimit
+			if v >= 0 {
+				memoryLimit = v
+			}
+			return old
+		},
+	}
+	t.captureBaseline()
+	return t, &gcPercent, &memoryLimit
+}
+
+func TestGCTunerTightensUnderPressure(t *testing.T) {
+	tuner, gcPercent, memoryLimit := fakeGCTuner(1000)
+	setUsage := func(mb int64) {
+		atomic.StoreInt64(&tuner.monitor.currentMemoryMB, mb)
+		tuner.Adjust()
+	}
+
+	setUsage(500)
+	assert.Equal(t, 100, *gcPercent)
+	assert.Equal(t, int64(math.MaxInt64), *memoryLimit)
+
+	setUsage(820)
+	assert.Equal(t, 50, *gcPercent)
+	assert.Equal(t, int64(1000<<20), *memoryLimit)
+
+	setUsage(950)
+	assert.Equal(t, 25, *gcPercent)
+	assert.Equal(t, int64(float64(1000<<20)*0.9), *memoryLimit)
+
+	// Inside the relax margin the tuner holds its level
+	setUsage(870)
+	assert.Equal(t, 25, *gcPercent)
+
+	setUsage(700)
+	assert.Equal(t, 100, *gcPercent)
+	assert.Equal(t, int64(math.MaxInt64), *memoryLimit)
+	assert.Equal(t, uint64(3), tuner.GetStats()["adjustments"])
+}
+
+func TestGCTunerKeepsOperatorSettings(t *testing.T) {
+	tuner, gcPercent, memoryLimit := fakeGCTuner(1000)
+	*gcPercent, *memoryLimit = 20, 512<<20
+	tuner.captureBaseline()
+
+	atomic.StoreInt64(&tuner.monitor.currentMemoryMB, 950)
+	tuner.Adjust()
+	assert.Equal(t, 10, *gcPercent, "GOGC is still lowered, down to its floor")
+	assert.Equal(t, int64(512<<20), *memoryLimit, "a tighter GOMEMLIMIT is never loosened")
+}
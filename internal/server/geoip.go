@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoRegionUnknown labels clients whose IP cannot be resolved to a country.
+const GeoRegionUnknown = "unknown"
+
+var (
+	// ErrInvalidGeoIPDatabase is returned when a GeoIP database cannot be parsed.
+	ErrInvalidGeoIPDatabase = errors.New("invalid GeoIP database")
+)
+
+// GeoIPResolver maps an IP address to an ISO 3166-1 alpha-2 country code.
+// Implementations can wrap a MaxMind reader or any other lookup service.
+type GeoIPResolver interface {
+	Country(ip net.IP) (string, bool)
+}
+
+// geoRange is a contiguous block of addresses assigned to one country.
+type geoRange struct {
+	start   [16]byte
+	end     [16]byte
+	country string
+}
+
+// GeoIPDatabase is an in-memory GeoIPResolver backed by network/country
+// records, such as a GeoLite2 country CSV export.
+type GeoIPDatabase struct {
+	ranges []geoRange // Sorted by start; networks must not overlap
+}
+
+// LoadGeoIPDatabase reads a GeoIP database from a CSV file.
+func LoadGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	defer f.Close()
+	return ParseGeoIPDatabase(f)
+}
+
+// ParseGeoIPDatabase parses CSV records of the form "network,country_code".
+// If the first row is a header containing "network" and "country_iso_code"
+// columns, those columns are used instead, so GeoLite2-style exports with
+// extra columns load directly. Rows without a country code are skipped.
+func ParseGeoIPDatabase(r io.Reader) (*GeoIPDatabase, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGeoIPDatabase, err)
+	}
+
+	netCol, countryCol := 0, 1
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "network") {
+		countryCol = -1
+		for i, name := range records[0] {
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "network":
+				netCol = i
+			case "country_iso_code", "country":
+				countryCol = i
+			}
+		}
+		if countryCol < 0 {
+			return nil, fmt.Errorf("%w: header has no country_iso_code column", ErrInvalidGeoIPDatabase)
+		}
+		records = records[1:]
+	}
+
+	db := &GeoIPDatabase{ranges: make([]geoRange, 0, len(records))}
+	for i, rec := range records {
+		if len(rec) <= netCol || len(rec) <= countryCol {
+			return nil, fmt.Errorf("%w: row %d has too few columns", ErrInvalidGeoIPDatabase, i+1)
+		}
+		country := strings.ToUpper(strings.TrimSpace(rec[countryCol]))
+		if country == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(rec[netCol]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidGeoIPDatabase, i+1, err)
+		}
+		db.ranges = append(db.ranges, networkRange(network, country))
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+	return db, nil
+}
+
+// networkRange converts a CIDR to its first and last address in 16-byte form.
+func networkRange(network *net.IPNet, country string) geoRange {
+	var r geoRange
+	r.country = country
+
+	ip := network.IP.To16()
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		// Align the IPv4 mask with the IPv4-mapped IPv6 representation
+		full := net.CIDRMask(96, 128)
+		copy(full[12:], mask)
+		mask = full
+	}
+	for i := 0; i < net.IPv6len; i++ {
+		r.start[i] = ip[i] & mask[i]
+		r.end[i] = ip[i] | ^mask[i]
+	}
+	return r
+}
+
+// Country returns the country code for ip.
+func (db *GeoIPDatabase) Country(ip net.IP) (string, bool) {
+	if db == nil || ip == nil {
+		return "", false
+	}
+	var key [16]byte
+	copy(key[:], ip.To16())
+
+	// Find the last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start[:], key[:]) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(key[:], db.ranges[i].end[:]) > 0 {
+		return "", false
+	}
+	return db.ranges[i].country, true
+}
+
+// Len returns the number of networks loaded.
+func (db *GeoIPDatabase) Len() int {
+	return len(db.ranges)
+}
+
+// GeoPolicy tags connections with a region and enforces per-country access rules.
+type GeoPolicy struct {
+	resolver GeoIPResolver
+	allow    map[string]bool
+	deny     map[string]bool
+}
+
+// NewGeoPolicy creates a policy. Deny takes precedence over allow; when allow
+// is non-empty only the listed countries are admitted. Country codes are
+// case-insensitive and "unknown" matches unresolved addresses.
+func NewGeoPolicy(resolver GeoIPResolver, allow, deny []string) *GeoPolicy {
+	return &GeoPolicy{
+		resolver: resolver,
+		allow:    countrySet(allow),
+		deny:     countrySet(deny),
+	}
+}
+
+func countrySet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			set[c] = true
+		}
+	}
+	return set
+}
+
+// Region returns the country code for ip, or GeoRegionUnknown.
+func (p *GeoPolicy) Region(ip net.IP) string {
+	if p == nil || p.resolver == nil {
+		return GeoRegionUnknown
+	}
+	if country, ok := p.resolver.Country(ip); ok && country != "" {
+		return strings.ToUpper(country)
+	}
+	return GeoRegionUnknown
+}
+
+// Allow reports whether connections from region are permitted.
+func (p *GeoPolicy) Allow(region string) bool {
+	if p == nil {
+		return true
+	}
+	key := strings.ToUpper(region)
+	if p.deny[key] {
+		return false
+	}
+	if len(p.allow) > 0 {
+		return p.allow[key]
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoIPDatabaseLookup(t *testing.T) {
+	db, err := ParseGeoIPDatabase(strings.NewReader(`# test data
+203.0.113.0/24,de
+198.51.100.0/25,US
+198.51.100.128/25,CA
+2001:db8::/32,JP
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.Len())
+
+	cases := map[string]string{
+		"203.0.113.77":   "DE",
+		"198.51.100.1":   "US",
+		"198.51.100.127": "US",
+		"198.51.100.128": "CA",
+		"2001:db8::1":    "JP",
+	}
+	for ip, want := range cases {
+		got, ok := db.Country(net.ParseIP(ip))
+		assert.True(t, ok, ip)
+		assert.Equal(t, want, got, ip)
+	}
+
+	for _, ip := range []string{"192.0.2.1", "10.0.0.1", "2001:db9::1"} {
+		_, ok := db.Country(net.ParseIP(ip))
+		assert.False(t, ok, ip)
+	}
+}
+
+func TestGeoIPDatabaseGeoLiteHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.csv")
+	data := "network,geoname_id,country_iso_code,is_anonymous_proxy\n" +
+		"203.0.113.0/24,2921044,DE,0\n" +
+		"192.0.2.0/24,,,1\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	db, err := LoadGeoIPDatabase(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.Len(), "rows without a country are skipped")
+
+	country, ok := db.Country(net.ParseIP("203.0.113.5"))
+	assert.True(t, ok)
+	assert.Equal(t, "DE", country)
+}
+
+func TestGeoIPDatabaseInvalid(t *testing.T) {
+	_, err := ParseGeoIPDatabase(strings.NewReader("not-a-cidr,US\n"))
+	assert.ErrorIs(t, err, ErrInvalidGeoIPDatabase)
+
+	_, err = ParseGeoIPDatabase(strings.NewReader("network,geoname_id\n203.0.113.0/24,1\n"))
+	assert.ErrorIs(t, err, ErrInvalidGeoIPDatabase)
+
+	_, err = LoadGeoIPDatabase(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestGeoPolicy(t *testing.T) {
+	db, err := ParseGeoIPDatabase(strings.NewReader("203.0.113.0/24,DE\n198.51.100.0/24,US\n"))
+	require.NoError(t, err)
+
+	deny := NewGeoPolicy(db, nil, []string{"us"})
+	assert.Equal(t, "DE", deny.Region(net.ParseIP("203.0.113.1")))
+	assert.Equal(t, GeoRegionUnknown, deny.Region(net.ParseIP("10.1.1.1")))
+	assert.True(t, deny.Allow("DE"))
+	assert.False(t, deny.Allow("US"))
+	assert.True(t, deny.Allow(GeoRegionUnknown))
+
+	allow := NewGeoPolicy(db, []string{"DE", "FR"}, []string{"FR"})
+	assert.True(t, allow.Allow("DE"))
+	assert.False(t, allow.Allow("FR"), "deny takes precedence over allow")
+	assert.False(t, allow.Allow("US"))
+	assert.False(t, allow.Allow(GeoRegionUnknown))
+
+	private := NewGeoPolicy(db, []string{"DE", "unknown"}, nil)
+	assert.True(t, private.Allow(GeoRegionUnknown), "unresolved addresses can be allowlisted")
+
+	var disabled *GeoPolicy
+	assert.True(t, disabled.Allow("US"))
+	assert.Equal(t, GeoRegionUnknown, disabled.Region(net.ParseIP("203.0.113.1")))
+}
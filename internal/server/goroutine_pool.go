@@ -12,6 +12,7 @@ import (
 // GoroutinePool manages a pool of worker goroutines for connection handling
 type GoroutinePool struct {
 	workers    int32
+	busy       int32 // Workers running a task
 	maxWorkers int32
 	minWorkers int32
 	taskQueue  chan func()
@@ -61,6 +62,13 @@ func NewGoroutinePool(minWorkers, maxWorkers int) *GoroutinePool {
 
 // Submit submits a task to the pool
 func (p *GoroutinePool) Submit(task func()) bool {
+	// Connection tasks run for the connection's lifetime, so a task queued
+	// while every worker is busy could wait indefinitely. Start a worker for
+	// it, or leave it to the caller when the pool is at its maximum.
+	if p.idleWorkers() <= 0 && !p.scaleUp() {
+		return false
+	}
+	
 	select {
 	case p.taskQueue <- task:
 		atomic.AddUint64(&p.totalTasks, 1)
@@ -87,6 +95,11 @@ func (p *GoroutinePool) Submit(task func()) bool {
 	}
 }
 
+// idleWorkers returns the number of workers free for another task.
+func (p *GoroutinePool) idleWorkers() int32 {
+	return atomic.LoadInt32(&p.workers) - atomic.LoadInt32(&p.busy) - atomic.LoadInt32(&p.queuedTasks)
+}
+
 // startWorker starts a new worker goroutine
 func (p *GoroutinePool) startWorker() {
 	if atomic.LoadInt32(&p.workers) >= p.maxWorkers {
@@ -111,11 +124,13 @@ func (p *GoroutinePool) startWorker() {
 					atomic.AddInt32(&p.queuedTasks, -1)
 					
 					// Execute task
+					atomic.AddInt32(&p.busy, 1)
 					func() {
 						defer func() {
 							if r := recover(); r != nil {
 								// Log panic recovery
 							}
+							atomic.AddInt32(&p.busy, -1)
 							atomic.AddUint64(&p.completedTasks, 1)
 						}()
 						task()
@@ -6,7 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -25,28 +25,38 @@ type ConnectionHandler struct {
 	conn           *Connection
 	config         *Config
 	subscription   *Subscription
-	lastHeartbeat  time.Time
-	heartbeatTimer *time.Timer
+	heartbeat      *HeartbeatMonitor
 	ctx            context.Context
 	cancel         context.CancelFunc
 	authenticated  bool
 	pendingBatch   []*pb.Tick
 	dataChan       chan []*pb.Tick
-	batchTimer     *time.Timer
+	batchTimer     Timer
 	logger         *slog.Logger
-	subscriptionTimer *time.Timer  // Timer for subscription timeout
 	server         *Server
+	lastPong       pongRecord // Timestamps of the last PONG, for RTT/skew estimation
+	skewWarned     bool       // Clock skew notice already sent
+	workers        sync.WaitGroup // Delivery and tick generation goroutines
+	frameLimiter   *AcceptLimiter // Inbound frame rate; nil when unlimited
+	frameStrikes   int            // Recoverable frame errors so far
+	sessionTimer   Timer          // Session age, then reauth grace; nil without MaxSessionAge
+	reauthPending  bool           // REAUTH_REQUIRED sent, awaiting AUTH
 }
 
 // NewConnectionHandler creates a new connection handler.
 // Optionally accepts a Server pointer to enable metric updates.
 func NewConnectionHandler(conn *Connection, config *Config, srv ...*Server) *ConnectionHandler {
-	logger := slog.Default().With(
+	base := config.logger()
+	if len(srv) > 0 && srv[0] != nil && srv[0].logger != nil {
+		base = srv[0].logger
+	}
+	logger := base.With(
 		"connection_id", conn.ID(),
 		"remote_addr", conn.RemoteAddr(),
-	)
+	).With(conn.traceAttrs()...)
 	
 	ctx, cancel := context.WithCancel(context.Background())
+	clock := config.clock()
 	
 	handler := &ConnectionHandler{
 		conn:           conn,
@@ -54,46 +64,95 @@ func NewConnectionHandler(conn *Connection, config *Config, srv ...*Server) *Con
 		ctx:            ctx,
 		cancel:         cancel,
 		dataChan:       make(chan []*pb.Tick, 100),
-		batchTimer:     time.NewTimer(5 * time.Millisecond),
+		batchTimer:     clock.NewTimer(5 * time.Millisecond),
 		pendingBatch:   make([]*pb.Tick, 0, 100),
 		logger:         logger,
 		authenticated:  conn.IsAuthenticated(),
-		lastHeartbeat:  time.Now(), // Initialize to current time
 		server:         nil,
 	}
 	
 	if len(srv) > 0 && srv[0] != nil {
 		handler.server = srv[0]
 	}
+	if config.MaxFramesPerSecond > 0 {
+		handler.frameLimiter = NewAcceptLimiter(config.MaxFramesPerSecond, config.FrameBurst, clock)
+	}
 	
-	// Initialize heartbeat timer - client must send heartbeat within timeout period
-	handler.heartbeatTimer = time.AfterFunc(config.HeartbeatTimeout, func() {
-		handler.handleHeartbeatTimeout()
-	})
-	
-	handler.logger.Info("heartbeat mechanism initialized",
-		"heartbeat_interval", config.HeartbeatInterval,
-		"heartbeat_timeout", config.HeartbeatTimeout,
-	)
+	// Client must send a heartbeat within the timeout once Handle starts the monitor
+	timers := clock
+	if handler.server != nil {
+		timers = handler.server.timers()
+	}
+	handler.heartbeat = NewHeartbeatMonitor(timers, config.HeartbeatInterval, config.HeartbeatTimeout,
+		handler.handleHeartbeatTimeout)
 	
 	return handler
 }
 
 // Handle handles the connection after authentication.
 func (h *ConnectionHandler) Handle(ctx context.Context) error {
+	clock := h.config.clock()
+	
+	// Background goroutines run on h.ctx: stop them when the caller cancels
+	// or Handle returns
+	stopOnCancel := context.AfterFunc(ctx, h.cancel)
+	defer stopOnCancel()
+	
+	// Park the subscription for resume, or release its retention buffer, once
+	// nothing else can send on this connection
+	defer h.detachSubscription()
+	defer h.Close()
+	
 	// Start heartbeat monitoring
-	h.heartbeatTimer = time.NewTimer(h.config.HeartbeatTimeout)
-	defer h.heartbeatTimer.Stop()
+	h.heartbeat.Start()
+	defer h.heartbeat.Stop()
+	h.logger.Info("heartbeat monitoring started",
+		"heartbeat_interval", h.config.HeartbeatInterval,
+		"heartbeat_timeout", h.config.HeartbeatTimeout,
+	)
 	
 	// Start batch timer
-	h.batchTimer = time.NewTimer(5 * time.Millisecond) // Default batch window
+	h.batchTimer = clock.NewTimer(5 * time.Millisecond) // Default batch window
 	defer h.batchTimer.Stop()
 	
 	// Create error channel for goroutines
 	errChan := make(chan error, 2)
 	
 	// Start data delivery goroutine
-	go h.deliveryLoop(ctx, errChan)
+	h.workers.Add(1)
+	go func() {
+		defer h.workers.Done()
+		defer h.recoverPanic("delivery")
+		h.deliveryLoop(h.ctx, errChan)
+	}()
+	
+	// Keep middleboxes from dropping connections idle between batches
+	if interval := h.config.FrameKeepaliveInterval; interval > 0 {
+		h.workers.Add(1)
+		go func() {
+			defer h.workers.Done()
+			defer h.recoverPanic("keepalive")
+			h.keepaliveLoop(h.ctx, interval)
+		}()
+	}
+	
+	// Frames are read on their own goroutine so cancellation, heartbeat
+	// timeouts and delivery errors are noticed while a read is blocked
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer func() {
+		stopReading()
+		// Unblock a pending read so the reader goroutine exits
+		h.conn.SetReadDeadline(time.Now())
+	}()
+	frames := make(chan readResult)
+	next := make(chan struct{})
+	go h.readLoop(readCtx, frames, next)
+	
+	// Sessions past MaxSessionAge must authenticate again
+	sessionExpired := h.sessionExpiry()
+	if h.sessionTimer != nil {
+		defer h.sessionTimer.Stop()
+	}
 	
 	// Main message processing loop
 	for {
@@ -101,115 +160,228 @@ func (h *ConnectionHandler) Handle(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 			
-		case <-h.heartbeatTimer.C:
-			// Heartbeat timeout
-			h.conn.SendError(pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT, "heartbeat timeout")
-			return fmt.Errorf("heartbeat timeout")
+		case <-h.heartbeat.Expired():
+			// The monitor has already notified the client and closed the connection
+			return ErrHeartbeatTimeout
 			
 		case err := <-errChan:
+			if isSlowConsumer(err) {
+				_ = h.conn.SendErrorCodeSync(pb.ErrorCode_ERROR_CODE_SLOW_CONSUMER, 0)
+			}
 			return err
 			
-		default:
-			// Set read deadline for next message
-			h.conn.SetReadDeadline(time.Now().Add(h.config.ReadTimeout))
+		case <-sessionExpired:
+			if err := h.handleSessionExpiry(); err != nil {
+				return err
+			}
 			
-			// Read next frame
-			frame, err := h.conn.ReadFrame()
-			if err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					return nil
+		case res := <-frames:
+			if res.err != nil {
+				return h.handleReadError(res.err)
+			}
+			frame := res.frame
+			
+			// First frame must be auth when not yet authenticated
+			if !h.authenticated && frame.Type != protocol.MessageTypeAuth {
+				if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED, "first frame must be auth"); sendErr != nil {
+					return sendErr
 				}
-				
-				// Log specific error types with appropriate detail
-				if errors.Is(err, protocol.ErrInvalidChecksum) {
-					h.logger.Error("checksum validation failed", 
-						"error", err,
-						"remote_addr", h.conn.RemoteAddr(),
-					)
-					if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_CHECKSUM_FAILED, "frame checksum validation failed"); sendErr != nil {
-						h.logger.Error(errorSendFailedMsg, "error", sendErr)
+				return fmt.Errorf("first frame must be auth")
+			}
+			
+			// Process the frame
+			if err := h.processFrame(ctx, frame); errors.Is(err, ErrFrameRateLimited) {
+				// Drop the frame but keep the connection
+				retryAfter := time.Duration(float64(time.Second) / h.config.MaxFramesPerSecond)
+				if sendErr := h.conn.SendRetryableError(pb.ErrorCode_ERROR_CODE_RATE_LIMITED, retryAfter); sendErr != nil {
+					return sendErr
+				}
+			} else if err != nil {
+				// Map protocol errors to specific error codes for client clarity
+				if errors.Is(err, protocol.ErrInvalidSequence) && frame.Type == protocol.MessageTypeAuth {
+					// Duplicate AUTH attempt
+					code := pb.ErrorCode_ERROR_CODE_ALREADY_AUTHENTICATED
+					if !h.authenticated {
+						code = pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED
+					}
+					if sendErr := h.conn.SendErrorCode(code); sendErr != nil {
+						return sendErr
+					}
+					// Increment server auth failures for duplicate AUTH on authenticated connection
+					if h.authenticated && h.server != nil {
+						atomic.AddUint64(&h.server.authFailures, 1)
+					}
+				} else if errors.Is(err, ErrReauthFailed) {
+					// The client has already been told why
+				} else if reason := recoverableFrameError(err); reason != "" && h.frameStrikes < h.config.MaxFrameStrikes {
+					// NACK the frame but keep the connection
+					if sendErr := h.rejectFrame(err, reason); sendErr != nil {
+						return sendErr
 					}
-				} else if errors.Is(err, protocol.ErrInvalidMagic) {
-					h.logger.Error("invalid magic bytes received", 
-						"error", err,
-						"remote_addr", h.conn.RemoteAddr(),
-					)
-					if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, "invalid frame format"); sendErr != nil {
-						h.logger.Error(errorSendFailedMsg, "error", sendErr)
+					select {
+					case next <- struct{}{}:
+					case <-ctx.Done():
+						return ctx.Err()
 					}
+					continue
 				} else {
-					h.logger.Error("frame read error", 
-						"error", err,
-						"remote_addr", h.conn.RemoteAddr(),
-					)
 					if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, err.Error()); sendErr != nil {
-						h.logger.Error(errorSendFailedMsg, "error", sendErr)
+						return sendErr
 					}
 				}
 				return err
 			}
 			
-			// First frame must be auth when not yet authenticated
-            if !h.authenticated && frame.Type != protocol.MessageTypeAuth {
-                if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED, "first frame must be auth"); sendErr != nil {
-                    return sendErr
-                }
-                return fmt.Errorf("first frame must be auth")
-            }
-            
-            // Process the frame
-            if err := h.processFrame(ctx, frame); err != nil {
-                // Map protocol errors to specific error codes for client clarity
-                if errors.Is(err, protocol.ErrInvalidSequence) && frame.Type == protocol.MessageTypeAuth {
-                    // Duplicate AUTH attempt
-                    code := pb.ErrorCode_ERROR_CODE_ALREADY_AUTHENTICATED
-                    if !h.authenticated {
-                        code = pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED
-                    }
-                    if sendErr := h.conn.SendErrorCode(code); sendErr != nil {
-                        return sendErr
-                    }
-                    // Increment server auth failures for duplicate AUTH on authenticated connection
-                    if h.authenticated && h.server != nil {
-                        atomic.AddUint64(&h.server.authFailures, 1)
-                    }
-                } else {
-                    if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, err.Error()); sendErr != nil {
-                        return sendErr
-                    }
-                }
-                return err
-            }
+			// Let the reader continue now that this frame's state changes are
+			// applied; the reader stops on its own once ctx is cancelled
+			select {
+			case next <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 	}
 }
 
-// processFrame processes an incoming frame.
-func (h *ConnectionHandler) processFrame(ctx context.Context, frame *protocol.Frame) error {
-	// Validate message type first
-	if err := protocol.ValidateMessageType(frame.Type); err != nil {
-		h.logger.Error("invalid message type received", 
-			"type", frame.Type,
-			"error", err,
-			"remote_addr", h.conn.RemoteAddr(),
-		)
-		return err
+// Close stops the delivery and tick generation goroutines and waits for them
+// to exit. The connection itself is left open.
+func (h *ConnectionHandler) Close() {
+	if h.cancel != nil {
+		h.cancel()
 	}
+	h.workers.Wait()
+}
 
-	switch frame.Type {
-	case protocol.MessageTypeHeartbeat:
-		return h.handleHeartbeat(frame)
-		
-	case protocol.MessageTypeSubscribe:
-		return h.handleSubscribe(frame)
+// readResult carries one frame, or the read error, from readLoop.
+type readResult struct {
+	frame *protocol.Frame
+	err   error
+}
+
+// readLoop reads frames until an error or ctx is cancelled. After each frame
+// it waits on next, so the read deadline reflects the state the previous
+// frame left behind (e.g. a new subscription).
+func (h *ConnectionHandler) readLoop(ctx context.Context, frames chan<- readResult, next <-chan struct{}) {
+	defer h.recoverPanic("reader")
+	for {
+		h.conn.SetReadDeadline(time.Now().Add(h.readTimeout()))
+		frame, err := h.conn.ReadFrame()
 		
-	case protocol.MessageTypeAuth:
-		// AUTH is only allowed as first frame
-		return protocol.ErrInvalidSequence
+		select {
+		case frames <- readResult{frame: frame, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
 		
+		select {
+		case <-next:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleReadError reports a failed read to the client where useful and
+// returns the error that ends Handle.
+func (h *ConnectionHandler) handleReadError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	select {
+	case <-h.heartbeat.Expired():
+		// Read failed because the timeout closed the connection
+		return ErrHeartbeatTimeout
 	default:
-		return protocol.ErrInvalidMessageType
 	}
+	if h.conn.stages.TimedOut() {
+		// The stage timer has already notified the client and closed the connection
+		return ErrStageTimeout
+	}
+	
+	// Log specific error types with appropriate detail
+	if errors.Is(err, protocol.ErrInvalidChecksum) {
+		h.logger.Error("checksum validation failed", 
+			"error", err,
+			"remote_addr", h.conn.RemoteAddr(),
+		)
+		if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_CHECKSUM_FAILED, "frame checksum validation failed"); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+	} else if errors.Is(err, protocol.ErrInvalidMagic) {
+		h.logger.Error("invalid magic bytes received", 
+			"error", err,
+			"remote_addr", h.conn.RemoteAddr(),
+		)
+		if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, "invalid frame format"); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+	} else {
+		h.logger.Error("frame read error", 
+			"error", err,
+			"remote_addr", h.conn.RemoteAddr(),
+		)
+		if sendErr := h.conn.SendError(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, err.Error()); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+	}
+	return err
+}
+
+// recoverableFrameError classifies errors that reject a single frame without
+// leaving the connection in a bad state: unknown message types and payloads
+// that fail to decode or validate. It returns "" for any other error.
+func recoverableFrameError(err error) string {
+	var vErr *protocol.ValidationError
+	switch {
+	case errors.Is(err, protocol.ErrInvalidMessageType):
+		return "unknown_type"
+	case errors.As(err, &vErr) && vErr.Field == "message_type":
+		return "unknown_type"
+	case errors.Is(err, proto.Error):
+		return "malformed_payload"
+	case vErr != nil:
+		return "invalid_payload"
+	}
+	return ""
+}
+
+// rejectFrame answers a recoverable frame error with an ERROR frame, counts
+// a strike and keeps the connection open.
+func (h *ConnectionHandler) rejectFrame(err error, reason string) error {
+	h.frameStrikes++
+	remaining := h.config.MaxFrameStrikes - h.frameStrikes
+	h.logger.Warn("frame rejected, connection kept",
+		"reason", reason,
+		"error", err,
+		"strikes", h.frameStrikes,
+	)
+	if h.server != nil && h.server.prometheusMetrics != nil {
+		h.server.prometheusMetrics.IncrementProtocolErrors(h.server.instanceID, reason)
+	}
+	return h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, err.Error(),
+		fmt.Sprintf("frame rejected; %d more invalid frames are tolerated before disconnect", remaining))
+}
+
+// processFrame routes an incoming frame through the server's dispatcher.
+func (h *ConnectionHandler) processFrame(ctx context.Context, frame *protocol.Frame) error {
+	dispatcher := defaultDispatcher
+	if h.server != nil && h.server.dispatcher != nil {
+		dispatcher = h.server.dispatcher
+	}
+	return dispatcher.Dispatch(ctx, h, frame)
+}
+
+// Conn returns the client connection, for custom frame handlers to reply on.
+func (h *ConnectionHandler) Conn() *Connection {
+	return h.conn
+}
+
+// Logger returns the connection's logger.
+func (h *ConnectionHandler) Logger() *slog.Logger {
+	return h.logger
 }
 
 // handleHeartbeat handles a heartbeat message.
@@ -231,25 +403,22 @@ func (h *ConnectionHandler) handleHeartbeat(frame *protocol.Frame) error {
 		// Normalize error message for expected test case: zero or invalid timestamp
 		var vErr *protocol.ValidationError
 		if errors.As(err, &vErr) && vErr.Field == "timestamp_ms" {
-			return fmt.Errorf("invalid heartbeat timestamp")
+			return fmt.Errorf("invalid heartbeat timestamp: %w", err)
 		}
 		return fmt.Errorf("heartbeat validation failed: %w", err)
 	}
 	
-	now := time.Now()
+	now := h.config.clock().Now()
 	
-	// Check for heartbeat flooding (prevent too frequent heartbeats)
-	if !h.lastHeartbeat.IsZero() {
-		timeSinceLastHeartbeat := now.Sub(h.lastHeartbeat)
-		minHeartbeatInterval := h.config.HeartbeatInterval / 2 // Allow up to 2x frequency
-		
-		if timeSinceLastHeartbeat < minHeartbeatInterval {
+	// Record the heartbeat, resetting the timeout, and check for flooding
+	if h.heartbeat != nil {
+		if sinceLast, flooding := h.heartbeat.Beat(); flooding {
+			// Don't return error, just log and continue to prevent DoS
 			h.logger.Warn("heartbeat flooding detected",
-				"time_since_last", timeSinceLastHeartbeat,
-				"min_interval", minHeartbeatInterval,
+				"time_since_last", sinceLast,
+				"min_interval", h.config.HeartbeatInterval/2,
 				"sequence", hb.Sequence,
 			)
-			// Don't return error, just log and continue to prevent DoS
 		}
 	}
 	
@@ -261,29 +430,37 @@ func (h *ConnectionHandler) handleHeartbeat(frame *protocol.Frame) error {
 		"server_time", now,
 	)
 	
-	// Update last heartbeat time
-	h.lastHeartbeat = now
-	
-	// Reset heartbeat timeout timer
-	if h.heartbeatTimer != nil {
-		h.heartbeatTimer.Reset(h.config.HeartbeatTimeout)
-		h.logger.Debug("heartbeat timer reset",
-			"timeout", h.config.HeartbeatTimeout,
-		)
-	}
+	// The client echoes the previous PONG, closing an RTT/skew sample
+	h.recordHeartbeatTiming(&hb)
 	
 	// Send pong response with server timestamp
-	return h.conn.SendPong(hb.TimestampMs, hb.Sequence)
+	h.lastPong = pongRecord{clientSentMs: hb.TimestampMs, serverSentMs: now.UnixMilli()}
+	return h.conn.sendPongAt(hb.TimestampMs, h.lastPong.serverSentMs, hb.Sequence)
 }
 
-// handleHeartbeatTimeout handles heartbeat timeout by closing the connection.
+// handleHeartbeatTimeout notifies the client and closes the connection. It
+// runs on the heartbeat monitor's timer.
 func (h *ConnectionHandler) handleHeartbeatTimeout() {
+	last := h.heartbeat.LastHeartbeat()
 	h.logger.Error("heartbeat timeout - closing connection",
-		"last_heartbeat", h.lastHeartbeat,
+		"last_heartbeat", last,
 		"timeout", h.config.HeartbeatTimeout,
-		"time_since_last", time.Since(h.lastHeartbeat),
+		"time_since_last", h.config.clock().Now().Sub(last),
 	)
 	
+	// Written synchronously: Close discards frames still in the write queue
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeError, &pb.ErrorResponse{
+		Code:        pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT,
+		Message:     "heartbeat timeout",
+		TimestampMs: time.Now().UnixMilli(),
+	})
+	if err == nil {
+		err = h.conn.WriteFrameSync(frame)
+	}
+	if err != nil {
+		h.logger.Debug(errorSendFailedMsg, "error", err)
+	}
+	
 	// Cancel the connection context to trigger graceful shutdown
 	if h.cancel != nil {
 		h.cancel()
@@ -298,7 +475,7 @@ func (h *ConnectionHandler) handleHeartbeatTimeout() {
 }
 
 // handleSubscribe handles a subscription request.
-func (h *ConnectionHandler) handleSubscribe(frame *protocol.Frame) error {
+func (h *ConnectionHandler) handleSubscribe(ctx context.Context, frame *protocol.Frame) error {
 	var sub pb.SubscribeRequest
 	if err := proto.Unmarshal(frame.Payload, &sub); err != nil {
 		h.logger.Error("failed to unmarshal subscribe request",
@@ -312,6 +489,14 @@ func (h *ConnectionHandler) handleSubscribe(frame *protocol.Frame) error {
 		}
 		return fmt.Errorf("failed to unmarshal subscribe: %w", err)
 	}
+	h.conn.setCorrelationID(sub.RequestId)
+	defer h.conn.setCorrelationID("")
+	if tc, ok := h.conn.joinTrace(sub.Metadata); ok {
+		h.logger.Info("subscription joined client trace",
+			"client_trace_id", tc.TraceID,
+			"parent_span_id", tc.ParentID,
+		)
+	}
 	
 	// Validate subscription request
 	if err := protocol.ValidateSubscribeRequest(&sub); err != nil {
@@ -327,11 +512,17 @@ func (h *ConnectionHandler) handleSubscribe(frame *protocol.Frame) error {
 		return fmt.Errorf("subscription validation failed: %w", err)
 	}
 	
+	// Symbols must lie in the tenant's namespaces
+	if err := h.checkTenantSymbols(sub.Symbols); err != nil {
+		return err
+	}
+	
 	// Log subscription attempt
 	h.logger.Info("subscription request received",
 		"mode", sub.Mode.String(),
 		"symbols", sub.Symbols,
 		"start_time_ms", sub.StartTimeMs,
+		"delivery_mode", sub.DeliveryMode.String(),
 	)
 	
 	// Validate subscription mode (redundant check, but kept for backward compatibility)
@@ -375,26 +566,71 @@ func (h *ConnectionHandler) handleSubscribe(frame *protocol.Frame) error {
 		return protocol.ErrAlreadySubscribed
 	}
 	
+	// Resolve per-subscription delivery options from metadata
+	options, err := ResolveDeliveryOptions(sub.Metadata, h.config)
+	if err != nil {
+		if sendErr := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
+			"Invalid subscription options", err.Error()); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return err
+	}
+	
 	// Create subscription
 	subscription := NewSubscription(sub.Mode)
+	subscription.DeliveryMode = sub.DeliveryMode
+	subscription.Options = options
+	subscription.setSymbols(sub.Symbols)
+	
+	// Let lifecycle hooks veto the subscription
+	if h.server != nil {
+		if err := h.server.hooks.runSubscribe(ctx, h.conn, subscription); err != nil {
+			h.logger.Info("subscription vetoed", "error", err)
+			if sendErr := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
+				"Subscription rejected", vetoReason(err)); sendErr != nil {
+				h.logger.Error(errorSendFailedMsg, "error", sendErr)
+			}
+			return err
+		}
+	}
+	if sub.DeliveryMode == pb.DeliveryMode_DELIVERY_MODE_AT_LEAST_ONCE {
+		key := retentionKey(h.conn.Session())
+		if h.server == nil || h.server.retention == nil || key == "" {
+			if err := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
+				"At-least-once delivery unavailable",
+				"At-least-once delivery requires a client_id in the AUTH request"); err != nil {
+				h.logger.Error(errorSendFailedMsg, "error", err)
+			}
+			return protocol.ErrInvalidSubscription
+		}
+		subscription.Retention = h.server.retention.Acquire(key)
+	}
+	if h.server != nil && h.server.resume != nil {
+		if token, err := newResumeToken(); err == nil {
+			subscription.ResumeToken = token
+		} else {
+			h.logger.Warn("failed to issue resume token", "error", err)
+		}
+	}
 	if err := h.conn.SetSubscription(subscription); err != nil {
+		if subscription.Retention != nil {
+			h.server.retention.Release(subscription.Retention)
+		}
 		h.logger.Error("failed to set subscription",
 			"error", err,
 		)
 		return err
 	}
-	
-	// Set up subscription timeout (30 seconds to receive first data)
-	if h.subscriptionTimer != nil {
-		h.subscriptionTimer.Stop()
+	h.enterStreaming()
+	
+	// Send subscription confirmation echoing the effective settings
+	ackMetadata := options.Metadata()
+	ackMetadata["delivery_mode"] = sub.DeliveryMode.String()
+	if subscription.ResumeToken != "" {
+		ackMetadata["resume_token"] = subscription.ResumeToken
+		ackMetadata["resume_grace"] = h.config.ResumeGracePeriod.String()
 	}
-	h.subscriptionTimer = time.AfterFunc(30*time.Second, func() {
-		h.logger.Warn("subscription timeout - no data generated within 30 seconds")
-		// Could implement additional handling here if needed
-	})
-	
-	// Send subscription confirmation
-	if err := h.conn.SendSubscriptionConfirmed(); err != nil {
+	if err := h.conn.SendSubscriptionConfirmed(ackMetadata); err != nil {
 		h.logger.Error("failed to send subscription confirmation",
 			"error", err,
 		)
@@ -405,76 +641,342 @@ func (h *ConnectionHandler) handleSubscribe(frame *protocol.Frame) error {
 	h.logger.Info("subscription confirmed",
 		"mode", sub.Mode.String(),
 		"created_at", subscription.CreatedAt,
+		"max_batch_size", options.MaxBatchSize,
+		"batch_window", options.BatchWindow,
+		"conflate", options.Conflate,
 	)
 	
+	// Send the last value of each symbol before live updates begin
+	if err := h.sendSnapshot(subscription.Mode, subscription.CurrentSymbols()); err != nil {
+		h.logger.Error("failed to send snapshot", "error", err)
+		return err
+	}
+	
+	// Redeliver batches the client had not acknowledged before reconnecting
+	if subscription.Retention != nil {
+		if err := h.redeliverUnacked(subscription.Retention); err != nil {
+			return err
+		}
+	}
+	
 	// Start data generation based on subscription mode
-	go h.startDataGeneration(subscription)
+	h.startGenerator(subscription)
 	
 	return nil
 }
 
-// startDataGeneration starts generating tick data based on subscription.
-func (h *ConnectionHandler) startDataGeneration(subscription *Subscription) {
-	var ticker *time.Ticker
+// checkTenantSymbols refuses symbols outside the tenant's namespaces,
+// telling the client which one was refused.
+func (h *ConnectionHandler) checkTenantSymbols(symbols []string) error {
+	tenant := h.conn.Tenant()
+	if tenant == nil {
+		return nil
+	}
+	for _, symbol := range symbols {
+		if tenant.AllowsSymbol(symbol) {
+			continue
+		}
+		h.logger.Warn("subscription outside tenant namespace",
+			"tenant", tenant.Name(),
+			"symbol", symbol,
+		)
+		if err := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_NOT_ENTITLED,
+			"Symbol not available",
+			fmt.Sprintf("Symbol %q is outside tenant %q's namespaces", symbol, tenant.Name())); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return fmt.Errorf("%w: symbol %q outside tenant namespace", protocol.ErrInvalidSubscription, symbol)
+	}
+	return nil
+}
+
+// enterStreaming moves the connection out of the subscribe stage, ending its
+// subscribe deadline.
+func (h *ConnectionHandler) enterStreaming() {
+	if err := h.conn.stages.Enter(StageStreaming); err != nil {
+		h.logger.Warn("connection stage violation", "error", err)
+	}
+}
+
+// startGenerator runs tick generation for subscription until Close.
+func (h *ConnectionHandler) startGenerator(subscription *Subscription) {
+	h.workers.Add(1)
+	go func() {
+		defer h.workers.Done()
+		defer h.recoverPanic("generator")
+		h.startDataGeneration(subscription)
+	}()
+}
+
+// redeliverUnacked resends retained batches in sequence order.
+func (h *ConnectionHandler) redeliverUnacked(buf *RetentionBuffer) error {
+	pending := buf.Unacked()
+	if len(pending) == 0 {
+		return nil
+	}
 	
-	switch subscription.Mode {
-	case pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND:
-		ticker = time.NewTicker(1 * time.Second)
-		h.logger.Info("starting tick generation", "mode", "SECOND", "interval", "1s")
-	case pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE:
-		ticker = time.NewTicker(1 * time.Minute)
-		h.logger.Info("starting tick generation", "mode", "MINUTE", "interval", "1m")
-	default:
-		h.logger.Error("invalid subscription mode for data generation", "mode", subscription.Mode.String())
-		return
+	h.logger.Info("redelivering unacknowledged batches",
+		"count", len(pending),
+		"first_sequence", pending[0].BatchSequence,
+		"last_sequence", pending[len(pending)-1].BatchSequence,
+	)
+	
+	for _, batch := range pending {
+		if err := h.conn.sendBatch(batch); err != nil {
+			return fmt.Errorf("failed to redeliver batch %d: %w", batch.BatchSequence, err)
+		}
+		atomic.AddUint64(&h.server.retention.redelivered, 1)
+	}
+	return nil
+}
+
+// handleBatchAck handles a cumulative batch acknowledgment.
+func (h *ConnectionHandler) handleBatchAck(frame *protocol.Frame) error {
+	var ack pb.BatchAck
+	if err := proto.Unmarshal(frame.Payload, &ack); err != nil {
+		return fmt.Errorf("failed to unmarshal batch ack: %w", err)
 	}
 	
-	defer ticker.Stop()
-	defer func() {
-		if h.subscriptionTimer != nil {
-			h.subscriptionTimer.Stop()
+	if err := protocol.ValidateBatchAck(&ack); err != nil {
+		return fmt.Errorf("batch ack validation failed: %w", err)
+	}
+	
+	sub := h.conn.GetSubscription()
+	if sub == nil || sub.Retention == nil {
+		// ACKs are harmless for at-most-once subscriptions
+		h.logger.Debug("ignoring batch ack without at-least-once subscription",
+			"batch_sequence", ack.BatchSequence,
+		)
+		return nil
+	}
+	
+	released := sub.Retention.Ack(ack.BatchSequence)
+	if h.server != nil && h.server.retention != nil {
+		atomic.AddUint64(&h.server.retention.acked, uint64(released))
+	}
+	h.logger.Debug("batch ack received",
+		"batch_sequence", ack.BatchSequence,
+		"released", released,
+	)
+	return nil
+}
+
+// handleGapFill replays batches sent after the client's last received sequence.
+func (h *ConnectionHandler) handleGapFill(frame *protocol.Frame) error {
+	var req pb.GapFillRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal gap fill request: %w", err)
+	}
+	
+	if err := protocol.ValidateGapFillRequest(&req); err != nil {
+		return fmt.Errorf("gap fill validation failed: %w", err)
+	}
+	
+	if h.conn.GetSubscription() == nil {
+		if err := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_NOT_SUBSCRIBED); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
 		}
-		h.logger.Info("stopping tick generation", "mode", subscription.Mode.String())
-	}()
+		return nil
+	}
 	
-	var i int
-	for {
-		select {
-		case <-ticker.C:
-			// Reset subscription timeout on successful data generation
-			if h.subscriptionTimer != nil {
-				h.subscriptionTimer.Stop()
+	if h.conn.history == nil {
+		if err := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE,
+			"Gap fill disabled", "Server does not retain batch history"); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return nil
+	}
+	
+	missing, err := h.conn.history.Since(req.LastSequence)
+	if err != nil {
+		h.logger.Warn("gap fill request outside retained history",
+			"last_sequence", req.LastSequence,
+		)
+		if sendErr := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return nil
+	}
+	
+	h.logger.Debug("gap fill request",
+		"last_sequence", req.LastSequence,
+		"replayed", len(missing),
+	)
+	
+	for _, batch := range missing {
+		if err := h.conn.SendMessage(protocol.MessageTypeDataBatch, batch); err != nil {
+			return fmt.Errorf("failed to replay batch %d: %w", batch.BatchSequence, err)
+		}
+	}
+	return nil
+}
+
+// handleResume restores a subscription parked when the client's previous
+// connection dropped, replays what it missed and continues the stream.
+func (h *ConnectionHandler) handleResume(frame *protocol.Frame) error {
+	var req pb.ResumeRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal resume request: %w", err)
+	}
+	
+	if err := protocol.ValidateResumeRequest(&req); err != nil {
+		return fmt.Errorf("resume validation failed: %w", err)
+	}
+	
+	if h.conn.GetSubscription() != nil {
+		if err := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_ALREADY_SUBSCRIBED); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return protocol.ErrAlreadySubscribed
+	}
+	
+	// A failed resume leaves the connection open so the client can SUBSCRIBE
+	if h.server == nil || h.server.resume == nil {
+		if err := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_RESUME_FAILED,
+			"Resume disabled", "Server does not keep subscriptions after a disconnect"); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return nil
+	}
+	
+	var username string
+	if session := h.conn.Session(); session != nil {
+		username = session.Username
+	}
+	parked, err := h.server.resume.Take(req.Token, username, h.config.clock().Now())
+	if err != nil {
+		h.logger.Info("resume rejected", "error", err)
+		if sendErr := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_RESUME_FAILED); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return nil
+	}
+	
+	subscription := parked.sub
+	subscription.ResumeToken, err = newResumeToken()
+	if err != nil {
+		h.logger.Warn("failed to issue resume token", "error", err)
+	}
+	h.conn.adoptSequencing(parked.batchSeq, parked.history)
+	if err := h.conn.SetSubscription(subscription); err != nil {
+		return err
+	}
+	h.enterStreaming()
+	
+	ackMetadata := subscription.Options.Metadata()
+	ackMetadata["mode"] = subscription.Mode.String()
+	ackMetadata["delivery_mode"] = subscription.DeliveryMode.String()
+	if subscription.ResumeToken != "" {
+		ackMetadata["resume_token"] = subscription.ResumeToken
+		ackMetadata["resume_grace"] = h.config.ResumeGracePeriod.String()
+	}
+	if err := h.conn.SendResumeConfirmed(ackMetadata); err != nil {
+		return err
+	}
+	
+	h.logger.Info("subscription resumed",
+		"mode", subscription.Mode.String(),
+		"last_sequence", req.LastSequence,
+		"parked_for", h.config.clock().Now().Sub(parked.parkedAt),
+	)
+	
+	// Replay batches sent after the client's last one
+	if subscription.Retention != nil {
+		released := subscription.Retention.Ack(req.LastSequence)
+		if h.server.retention != nil {
+			atomic.AddUint64(&h.server.retention.acked, uint64(released))
+		}
+		if err := h.redeliverUnacked(subscription.Retention); err != nil {
+			return err
+		}
+	} else if parked.history != nil {
+		missing, err := parked.history.Since(req.LastSequence)
+		if err != nil {
+			if sendErr := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE); sendErr != nil {
+				h.logger.Error(errorSendFailedMsg, "error", sendErr)
 			}
-			
-			// Generate tick data (placeholder - in production, get real data)
-			tick := &pb.Tick{
-				Symbol:      fmt.Sprintf("TICK_%d", i),
-				Price:       100.0 + rand.Float64()*10,
-				Volume:      float64(rand.Intn(1000)),
-				TimestampMs: time.Now().UnixMilli(),
-				Mode:        subscription.Mode,
+		}
+		for _, batch := range missing {
+			if err := h.conn.SendMessage(protocol.MessageTypeDataBatch, batch); err != nil {
+				return fmt.Errorf("failed to replay batch %d: %w", batch.BatchSequence, err)
 			}
-			
-			// Send to data channel for batching
-			select {
-			case h.dataChan <- []*pb.Tick{tick}:
-				h.logger.Debug("tick generated",
-					"symbol", tick.Symbol,
-					"price", tick.Price,
-					"mode", subscription.Mode.String(),
-				)
-				i++
-			default:
-				// Channel full, drop tick (or handle backpressure)
-				h.logger.Warn("data channel full, dropping tick",
-					"symbol", tick.Symbol,
-				)
-			
-		case <-time.After(time.Second):
-			// Connection closed
-			return
 		}
 	}
+	
+	h.startGenerator(subscription)
+	return nil
 }
 
+// detachSubscription parks a resumable subscription when the connection
+// ends, or releases its retention buffer otherwise.
+func (h *ConnectionHandler) detachSubscription() {
+	sub := h.conn.GetSubscription()
+	if sub == nil || sub.ResumeToken == "" || h.server == nil || h.server.resume == nil {
+		h.releaseRetention()
+		return
+	}
+	
+	var username string
+	if session := h.conn.Session(); session != nil {
+		username = session.Username
+	}
+	h.server.resume.Park(sub.ResumeToken, &parkedSubscription{
+		username: username,
+		sub:      sub,
+		batchSeq: atomic.LoadUint32(&h.conn.batchSeq),
+		history:  h.conn.history,
+		parkedAt: h.config.clock().Now(),
+	})
+	h.logger.Debug("subscription parked for resume", "grace", h.config.ResumeGracePeriod)
+}
+
+// releaseRetention detaches the subscription's retention buffer, if any.
+func (h *ConnectionHandler) releaseRetention() {
+	sub := h.conn.GetSubscription()
+	if sub == nil || sub.Retention == nil || h.server == nil || h.server.retention == nil {
+		return
+	}
+	h.server.retention.Release(sub.Retention)
+}
+
+// startDataGeneration streams ticks for subscription from the configured
+// data source until the handler closes.
+func (h *ConnectionHandler) startDataGeneration(subscription *Subscription) {
+	switch subscription.Mode {
+	case pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE:
+	default:
+		h.logger.Error("invalid subscription mode for data generation", "mode", subscription.Mode.String())
+		return
+	}
+	
+	ctx := h.ctx
+	if ctx == nil {
+		// Handlers built without a context stream until the process exits
+		ctx = context.Background()
+	}
+	
+	h.logger.Info("starting tick generation", "mode", subscription.Mode.String())
+	defer h.logger.Info("stopping tick generation", "mode", subscription.Mode.String())
+	h.config.dataSource().Stream(ctx, subscription, h.emitTicks)
+}
+
+// emitTicks queues ticks for batching, dropping them when the queue is full.
+// Dropped ticks still update the last-value cache and tick history. Ticks outside their
+// trading session are held back or flagged first.
+func (h *ConnectionHandler) emitTicks(ticks []*pb.Tick) {
+	if h.server != nil && h.server.calendar != nil {
+		if ticks = h.server.calendar.Gate(ticks, h.conn.now()); len(ticks) == 0 {
+			return
+		}
+	}
+	if h.server != nil {
+		h.server.recordTicks(ticks)
+	}
+	select {
+	case h.dataChan <- ticks:
+		h.logger.Debug("ticks generated", "count", len(ticks))
+	default:
+		// Channel full, drop ticks (or handle backpressure)
+		h.logger.Warn("data channel full, dropping ticks", "count", len(ticks))
+	}
 }
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// newFuzzHandler creates a handler over an in-memory pipe whose peer discards all output.
+func newFuzzHandler(t *testing.T) *ConnectionHandler {
+	t.Helper()
+
+	serverSide, clientSide := net.Pipe()
+	go func() {
+		_, _ = io.Copy(io.Discard, clientSide)
+	}()
+
+	config := DefaultConfig()
+	conn := NewConnection(serverSide, config)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+		dataChan:      make(chan []*pb.Tick, 100),
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		conn.Close()
+		clientSide.Close()
+	})
+	return handler
+}
+
+func FuzzProcessFrame(f *testing.F) {
+	sub, _ := proto.Marshal(&pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+	hb, _ := proto.Marshal(&pb.HeartbeatRequest{TimestampMs: 1700000000000, Sequence: 1})
+	authReq, _ := proto.Marshal(&pb.AuthRequest{Username: "user", Password: "pass"})
+
+	f.Add(uint8(protocol.MessageTypeSubscribe), sub)
+	f.Add(uint8(protocol.MessageTypeHeartbeat), hb)
+	f.Add(uint8(protocol.MessageTypeAuth), authReq)
+	f.Add(uint8(protocol.MessageTypeSubscribe), []byte{0x08, 0x7F, 0x12, 0x80})
+	f.Add(uint8(0xFF), []byte{})
+
+	f.Fuzz(func(t *testing.T, msgType uint8, payload []byte) {
+		handler := newFuzzHandler(t)
+		frame := &protocol.Frame{
+			Version: protocol.ProtocolVersion,
+			Type:    protocol.MessageType(msgType),
+			Payload: payload,
+		}
+
+		// Errors are expected for malformed input; only panics are failures
+		_ = handler.processFrame(handler.ctx, frame)
+	})
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// startHandle runs Handle on an authenticated pipe connection and returns
+// the handler, the client end and Handle's result.
+func startHandle(t *testing.T, ctx context.Context, config *Config) (*ConnectionHandler, net.Conn, <-chan error) {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	conn.SetAuthenticated(nil)
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	handler := NewConnectionHandler(conn, config)
+	handler.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	done := make(chan error, 1)
+	go func() { done <- handler.Handle(ctx) }()
+	return handler, clientSide, done
+}
+
+func TestHandleReturnsOnCancelDuringRead(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, done := startHandle(t, ctx, config)
+
+	// Handle is blocked waiting for a frame that never arrives
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Handle did not observe cancellation while a read was pending")
+	}
+}
+
+func TestHandleReturnsOnCancelDuringProcessFrame(t *testing.T) {
+	// A frame that completes normally, and one rejected with a strike
+	for name, result := range map[string]error{
+		"handled":  nil,
+		"rejected": &protocol.ValidationError{Field: "sequence", Message: "bad", Err: protocol.ErrInvalidFieldValue},
+	} {
+		t.Run(name, func(t *testing.T) {
+			testHandleReturnsOnCancelDuringProcessFrame(t, result)
+		})
+	}
+}
+
+// testHandleReturnsOnCancelDuringProcessFrame cancels Handle while a frame
+// whose handler returns result is being processed.
+func testHandleReturnsOnCancelDuringProcessFrame(t *testing.T, result error) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+	config.MaxFrameStrikes = 3
+
+	// The heartbeat handler holds the frame until released, ignoring ctx
+	entered, release := make(chan struct{}), make(chan struct{})
+	d := NewDispatcher()
+	d.Handle(protocol.MessageTypeHeartbeat, "heartbeat", func(context.Context, *ConnectionHandler, *protocol.Frame) error {
+		close(entered)
+		<-release
+		return result
+	})
+
+	serverSide, client := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	conn.SetAuthenticated(nil)
+	t.Cleanup(func() {
+		conn.Close()
+		client.Close()
+	})
+	handler := NewConnectionHandler(conn, config, &Server{config: config, dispatcher: d})
+	handler.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- handler.Handle(ctx) }()
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 1})
+	require.NoError(t, err)
+	client.SetDeadline(time.Now().Add(time.Second))
+	require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+	<-entered
+
+	// The reader sees the cancellation and stops before the frame completes
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Handle did not return after cancellation during processFrame")
+	}
+}
+
+func TestHandleProcessesFramesInOrder(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client, done := startHandle(t, ctx, config)
+	reader := protocol.NewFrameReader(client, config.MaxMessageSize)
+	writer := protocol.NewFrameWriter(client)
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		frame, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat,
+			&pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: seq})
+		require.NoError(t, err)
+		client.SetDeadline(time.Now().Add(time.Second))
+		require.NoError(t, writer.WriteFrame(frame))
+
+		resp, err := reader.ReadFrame()
+		require.NoError(t, err)
+		require.Equal(t, protocol.MessageTypePong, resp.Type)
+		var pong pb.HeartbeatResponse
+		require.NoError(t, proto.Unmarshal(resp.Payload, &pong))
+		assert.Equal(t, seq, pong.Sequence)
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+// activeTickers counts the tickers registered on a fake clock.
+func activeTickers(clock *FakeClock) int {
+	clock.mu.Lock()
+	defer clock.mu.Unlock()
+	n := 0
+	for _, w := range clock.waiters {
+		if w.period > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHandleStopsDataGenerationOnDisconnect(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultConfig()
+	config.Clock = clock
+
+	handler, client, done := startHandle(t, context.Background(), config)
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeSubscribe,
+		&pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+	require.NoError(t, err)
+	client.SetWriteDeadline(time.Now().Add(time.Second))
+	require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+	go io.Copy(io.Discard, client)
+
+	require.Eventually(t, func() bool { return activeTickers(clock) == 1 },
+		time.Second, time.Millisecond, "subscription starts a tick generator")
+
+	// The client goes away
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Handle did not return after the client disconnected")
+	}
+
+	// Handle has already waited for its goroutines; a second Close must not block
+	closed := make(chan struct{})
+	go func() {
+		handler.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("handler goroutines still running after Close")
+	}
+	assert.Zero(t, activeTickers(clock), "generator ticker stopped")
+}
+
+func TestCloseStopsStandaloneGenerator(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := DefaultConfig()
+	config.Clock = clock
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &ConnectionHandler{
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+		dataChan: make(chan []*pb.Tick, 1),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	handler.workers.Add(1)
+	go func() {
+		defer handler.workers.Done()
+		handler.startDataGeneration(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE))
+	}()
+	require.Eventually(t, func() bool { return activeTickers(clock) == 1 }, time.Second, time.Millisecond)
+
+	handler.Close()
+	assert.Zero(t, activeTickers(clock))
+}
+
+func TestSubscribeRejectsSymbolsOutsideTenantNamespace(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, client, done := startHandle(t, ctx, config)
+	handler.conn.SetTenant(&Tenant{name: "acme", symbols: []string{"ACME"}})
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{
+		Mode:    pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+		Symbols: []string{"ACMEWIDGET", "GLOBEXGIZMO"},
+	})
+	require.NoError(t, err)
+	client.SetDeadline(time.Now().Add(time.Second))
+	require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+
+	resp, err := protocol.NewFrameReader(client, config.MaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeError, resp.Type)
+	var errResp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(resp.Payload, &errResp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_NOT_ENTITLED, errResp.Code)
+	assert.Contains(t, errResp.Details, "GLOBEXGIZMO")
+	assert.Nil(t, handler.conn.GetSubscription())
+
+	cancel()
+	<-done
+}
+
+func TestHandleToleratesFrameStrikes(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+	config.MaxFrameStrikes = 2
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, client, done := startHandle(t, ctx, config)
+	reader := protocol.NewFrameReader(client, config.MaxMessageSize)
+	writer := protocol.NewFrameWriter(client)
+	send := func(frame *protocol.Frame) *protocol.Frame {
+		client.SetDeadline(time.Now().Add(time.Second))
+		require.NoError(t, writer.WriteFrame(frame))
+		resp, err := reader.ReadFrame()
+		require.NoError(t, err)
+		return resp
+	}
+	rejected := func(resp *protocol.Frame) *pb.ErrorResponse {
+		require.Equal(t, protocol.MessageTypeError, resp.Type)
+		var errResp pb.ErrorResponse
+		require.NoError(t, proto.Unmarshal(resp.Payload, &errResp))
+		assert.Equal(t, pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, errResp.Code)
+		return &errResp
+	}
+
+	errResp := rejected(send(&protocol.Frame{Version: protocol.ProtocolVersion, Type: 0x7F}))
+	assert.Contains(t, errResp.Details, "1 more invalid frames")
+	rejected(send(&protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.MessageTypeHeartbeat, Payload: []byte{0xFF, 0xFF}}))
+
+	// Valid frames are still served between strikes
+	heartbeat, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 1})
+	require.NoError(t, err)
+	assert.Equal(t, protocol.MessageTypePong, send(heartbeat).Type)
+
+	rejected(send(&protocol.Frame{Version: protocol.ProtocolVersion, Type: 0x7F}))
+	select {
+	case err := <-done:
+		assert.Error(t, err, "the strike after the last tolerated one closes the connection")
+	case <-time.After(time.Second):
+		t.Fatal("connection survived more strikes than allowed")
+	}
+}
+
+func TestRecoverableFrameError(t *testing.T) {
+	assert.Equal(t, "unknown_type", recoverableFrameError(protocol.ValidateMessageType(0x7F)))
+	assert.Equal(t, "unknown_type", recoverableFrameError(protocol.ErrInvalidMessageType))
+	assert.Equal(t, "malformed_payload", recoverableFrameError(fmt.Errorf("failed to unmarshal: %w", proto.Unmarshal([]byte{0xFF}, &pb.HeartbeatRequest{}))))
+	assert.Equal(t, "invalid_payload", recoverableFrameError(fmt.Errorf("subscription validation failed: %w", protocol.ValidateSubscribeRequest(&pb.SubscribeRequest{}))))
+	assert.Empty(t, recoverableFrameError(protocol.ErrInvalidSequence))
+	assert.Empty(t, recoverableFrameError(io.EOF))
+}
@@ -3,7 +3,6 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"runtime"
 	"sync/atomic"
@@ -98,12 +97,18 @@ func (hc *HealthChecker) GetHealth() *HealthCheck {
 	hc.checkResourceLimits(health)
 	hc.checkConnectivity(health)
 	hc.checkAuthentication(health)
+	hc.checkSLO(health)
 
 	return health
 }
 
 // determineOverallStatus determines the overall health status
 func (hc *HealthChecker) determineOverallStatus() HealthStatus {
+	// A draining server is still alive; only readiness reports it
+	if hc.server.draining.Load() {
+		return HealthStatusDegraded
+	}
+	
 	// Check if server is closed
 	if hc.server.closed.Load() {
 		return HealthStatusUnhealthy
@@ -114,6 +119,11 @@ func (hc *HealthChecker) determineOverallStatus() HealthStatus {
 		return HealthStatusDegraded
 	}
 
+	// Delivery SLO error budget spent
+	if hc.server.slo != nil && hc.server.slo.Status().BudgetExhausted {
+		return HealthStatusDegraded
+	}
+
 	// Check connection limits
 	activeConns := atomic.LoadInt32(&hc.server.activeConns)
 	maxConns := int32(hc.server.config.MaxConnections)
@@ -130,6 +140,14 @@ func (hc *HealthChecker) determineOverallStatus() HealthStatus {
 
 // checkServerStatus checks basic server status
 func (hc *HealthChecker) checkServerStatus(health *HealthCheck) {
+	if hc.server.draining.Load() {
+		health.Checks["server"] = CheckResult{
+			Status:  HealthStatusDegraded,
+			Message: "Server is draining",
+		}
+		return
+	}
+	
 	if hc.server.closed.Load() {
 		health.Checks["server"] = CheckResult{
 			Status:  HealthStatusUnhealthy,
@@ -190,6 +208,29 @@ func (hc *HealthChecker) checkResourceLimits(health *HealthCheck) {
 	}
 }
 
+// checkSLO checks the delivery SLO error budget
+func (hc *HealthChecker) checkSLO(health *HealthCheck) {
+	if hc.server.slo == nil {
+		return
+	}
+
+	status := hc.server.slo.Status()
+	if status.BudgetExhausted {
+		health.Checks["slo"] = CheckResult{
+			Status:  HealthStatusDegraded,
+			Message: "Delivery SLO error budget exhausted",
+			Details: status,
+		}
+		return
+	}
+
+	health.Checks["slo"] = CheckResult{
+		Status:  HealthStatusHealthy,
+		Message: "Delivery SLO within error budget",
+		Details: status,
+	}
+}
+
 // checkConnectivity checks network connectivity
 func (hc *HealthChecker) checkConnectivity(health *HealthCheck) {
 	activeConns := atomic.LoadInt32(&hc.server.activeConns)
@@ -280,44 +321,47 @@ func (hc *HealthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(health)
 }
 
-// IsHealthy returns true if the server is healthy
-func (hc *HealthChecker) IsHealthy() bool {
-	return hc.determineOverallStatus() != HealthStatusUnhealthy
-}
-
-// bToMb converts bytes to megabytes
-func bToMb(b uint64) uint64 {
-	return b / 1024 / 1024
+// Readiness reports whether the server should receive new connections, and
+// why not. It turns false as soon as a drain begins, before the listeners
+// close, and while resource breaches cause new connections to be rejected.
+func (hc *HealthChecker) Readiness() (bool, string) {
+	switch {
+	case hc.server.draining.Load():
+		return false, "draining"
+	case hc.server.closed.Load():
+		return false, "closed"
+	case hc.server.breachHandler != nil && hc.server.breachHandler.ShouldRejectConnection():
+		return false, hc.server.breachHandler.GetRejectionReason()
+	}
+	return true, ""
 }
 
-// StartHealthCheckServer starts an HTTP server for health checks
-func (s *Server) StartHealthCheckServer(port int) error {
-	if s.healthChecker == nil {
-		s.healthChecker = NewHealthChecker(s)
+// ServeReady implements the readiness probe: 200 when ready, 503 otherwise.
+func (hc *HealthChecker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/health", s.healthChecker)
-	mux.Handle("/healthz", s.healthChecker) // Kubernetes style
-	mux.Handle("/ready", s.healthChecker)   // Readiness probe
-
-	// Simple ping endpoint
-	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+	ready, reason := hc.Readiness()
+	w.Header().Set("Content-Type", "application/json")
+	if ready {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("pong"))
-	})
-
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready":  ready,
+		"reason": reason,
+	})
+}
 
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Error("health check server failed", "error", err)
-		}
-	}()
+// IsHealthy returns true if the server is healthy
+func (hc *HealthChecker) IsHealthy() bool {
+	return hc.determineOverallStatus() != HealthStatusUnhealthy
+}
 
-	s.logger.Info("health check server started", "port", port)
-	return nil
+// bToMb converts bytes to megabytes
+func bToMb(b uint64) uint64 {
+	return b / 1024 / 1024
 }
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probe(handler http.HandlerFunc) int {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec.Code
+}
+
+func TestReadinessFailsWhenDrainBegins(t *testing.T) {
+	config := DefaultConfig()
+	config.DrainReadinessDelay = 200 * time.Millisecond
+	srv := NewServer(config)
+	hc := srv.healthChecker
+
+	assert.Equal(t, http.StatusOK, probe(hc.ServeReady))
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Shutdown(context.Background()) }()
+
+	assert.Eventually(t, func() bool { return probe(hc.ServeReady) == http.StatusServiceUnavailable },
+		100*time.Millisecond, 5*time.Millisecond, "readiness fails before the listeners close")
+	_, reason := hc.Readiness()
+	assert.Equal(t, "draining", reason)
+	assert.Equal(t, http.StatusOK, probe(hc.ServeHTTP), "liveness stays up while draining")
+
+	assert.NoError(t, <-done)
+	assert.Equal(t, http.StatusServiceUnavailable, probe(hc.ServeReady))
+}
+
+func TestReadinessFailsWhileRejectingConnections(t *testing.T) {
+	srv := NewServer(DefaultConfig())
+	defer srv.Stop(context.Background())
+
+	srv.breachHandler.handleFDBreach(95)
+	ready, reason := srv.healthChecker.Readiness()
+	assert.False(t, ready)
+	assert.Equal(t, "server file descriptor limit exceeded", reason)
+	assert.Equal(t, http.StatusOK, probe(srv.healthChecker.ServeHTTP))
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrHeartbeatTimeout indicates the client stopped sending heartbeats.
+var ErrHeartbeatTimeout = errors.New("heartbeat timeout")
+
+// HeartbeatMonitor tracks client heartbeats on one connection with a single
+// timeout timer. The timer is armed by Start, pushed back by every Beat and
+// released by Stop; if it fires, onTimeout runs once and Expired is closed.
+type HeartbeatMonitor struct {
+	clock     Clock
+	interval  time.Duration
+	timeout   time.Duration
+	onTimeout func()
+
+	mu      sync.Mutex
+	timer   Timer
+	last    time.Time
+	stopped bool
+
+	expired    chan struct{}
+	expireOnce sync.Once
+}
+
+// NewHeartbeatMonitor creates a monitor expecting a heartbeat every interval
+// and expiring after timeout without one. onTimeout may be nil.
+func NewHeartbeatMonitor(clock Clock, interval, timeout time.Duration, onTimeout func()) *HeartbeatMonitor {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &HeartbeatMonitor{
+		clock:     clock,
+		interval:  interval,
+		timeout:   timeout,
+		onTimeout: onTimeout,
+		expired:   make(chan struct{}),
+	}
+}
+
+// Start arms the timeout timer. Calling Start again, or after Stop, is a no-op.
+func (m *HeartbeatMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer != nil || m.stopped {
+		return
+	}
+	m.last = m.clock.Now()
+	m.timer = m.clock.AfterFunc(m.timeout, m.expire)
+}
+
+// Stop disarms the timer. It reports whether the monitor was running and
+// had not yet expired.
+func (m *HeartbeatMonitor) Stop() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return false
+	}
+	m.stopped = true
+	if m.timer == nil {
+		return false
+	}
+	return m.timer.Stop()
+}
+
+// Beat records a heartbeat and resets the timeout. It returns the time since
+// the previous heartbeat and whether the client is sending them more than
+// twice as often as the configured interval.
+func (m *HeartbeatMonitor) Beat() (sinceLast time.Duration, flooding bool) {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.last.IsZero() {
+		sinceLast = now.Sub(m.last)
+		flooding = sinceLast < m.interval/2
+	}
+	m.last = now
+	if m.timer != nil && !m.stopped && !m.isExpired() {
+		m.timer.Reset(m.timeout)
+	}
+	return sinceLast, flooding
+}
+
+// LastHeartbeat returns when the last heartbeat arrived, or when monitoring
+// started if none has.
+func (m *HeartbeatMonitor) LastHeartbeat() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Expired is closed when the timeout fires.
+func (m *HeartbeatMonitor) Expired() <-chan struct{} {
+	return m.expired
+}
+
+func (m *HeartbeatMonitor) isExpired() bool {
+	select {
+	case <-m.expired:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *HeartbeatMonitor) expire() {
+	m.mu.Lock()
+	// A Beat that raced with the firing timer has already re-armed it
+	skip := m.stopped || m.clock.Now().Sub(m.last) < m.timeout
+	m.mu.Unlock()
+	if skip {
+		return
+	}
+	m.expireOnce.Do(func() {
+		close(m.expired)
+		if m.onTimeout != nil {
+			m.onTimeout()
+		}
+	})
+}
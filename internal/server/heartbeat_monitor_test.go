@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// newTestHeartbeatMonitor returns a started monitor with no timeout action.
+func newTestHeartbeatMonitor(tb testing.TB, config *Config) *HeartbeatMonitor {
+	m := NewHeartbeatMonitor(config.clock(), config.HeartbeatInterval, config.HeartbeatTimeout, nil)
+	m.Start()
+	tb.Cleanup(func() { m.Stop() })
+	return m
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestHeartbeatMonitorTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	fired := 0
+	m := NewHeartbeatMonitor(clock, 15*time.Second, 20*time.Second, func() { fired++ })
+
+	// Nothing is armed before Start
+	clock.Advance(time.Minute)
+	assert.False(t, isClosed(m.Expired()))
+
+	m.Start()
+	m.Start() // A second Start must not arm another timer
+	clock.Advance(19 * time.Second)
+	assert.False(t, isClosed(m.Expired()))
+
+	clock.Advance(time.Second)
+	assert.True(t, isClosed(m.Expired()))
+	assert.Equal(t, 1, fired)
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, fired, "the timeout fires once")
+	assert.False(t, m.Stop(), "an expired monitor was not running")
+}
+
+func TestHeartbeatMonitorReset(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	m := NewHeartbeatMonitor(clock, 15*time.Second, 20*time.Second, nil)
+	m.Start()
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(15 * time.Second)
+		m.Beat()
+	}
+	assert.False(t, isClosed(m.Expired()), "regular heartbeats keep the connection alive")
+	assert.Equal(t, clock.Now(), m.LastHeartbeat())
+
+	clock.Advance(20 * time.Second)
+	assert.True(t, isClosed(m.Expired()))
+}
+
+func TestHeartbeatMonitorFlooding(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	m := NewHeartbeatMonitor(clock, 10*time.Second, 20*time.Second, nil)
+	m.Start()
+
+	clock.Advance(6 * time.Second)
+	since, flooding := m.Beat()
+	assert.Equal(t, 6*time.Second, since)
+	assert.False(t, flooding)
+
+	clock.Advance(4 * time.Second)
+	since, flooding = m.Beat()
+	assert.Equal(t, 4*time.Second, since)
+	assert.True(t, flooding, "heartbeats more than twice per interval")
+
+	assert.False(t, isClosed(m.Expired()), "flooding does not expire the monitor")
+}
+
+func TestHeartbeatMonitorStop(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	fired := false
+	m := NewHeartbeatMonitor(clock, 15*time.Second, 20*time.Second, func() { fired = true })
+	m.Start()
+
+	assert.True(t, m.Stop())
+	assert.False(t, m.Stop())
+	m.Start() // Restarting a stopped monitor is a no-op
+	m.Beat()
+
+	clock.Advance(time.Minute)
+	assert.False(t, fired)
+	assert.False(t, isClosed(m.Expired()))
+}
+
+func TestHandleHeartbeatTimeout(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	clock := NewFakeClock(time.Now())
+	config := DefaultConfig()
+	config.Clock = clock
+	conn := NewConnection(serverSide, config)
+	conn.SetAuthenticated(nil)
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	handler := NewConnectionHandler(conn, config)
+	handler.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	done := make(chan error, 1)
+	go func() { done <- handler.Handle(context.Background()) }()
+
+	// Wait for Handle to arm the monitor before advancing the clock
+	require.Eventually(t, func() bool { return !handler.heartbeat.LastHeartbeat().IsZero() },
+		time.Second, time.Millisecond)
+	go clock.Advance(config.HeartbeatTimeout)
+
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := protocol.NewFrameReader(clientSide, config.MaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeError, frame.Type)
+	var resp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT, resp.Code)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrHeartbeatTimeout)
+	case <-time.After(time.Second):
+		t.Fatal("Handle did not return after the heartbeat timeout")
+	}
+}
@@ -21,9 +21,9 @@ func TestHeartbeatValidation(t *testing.T) {
 	// Create a minimal handler for testing (without network connection)
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	handler := &ConnectionHandler{
-		config:        config,
-		lastHeartbeat: time.Now(),
-		logger:        logger,
+		config:    config,
+		heartbeat: newTestHeartbeatMonitor(t, config),
+		logger:    logger,
 	}
 	
 	// Test valid heartbeat
@@ -91,9 +91,9 @@ func TestHeartbeatFloodingDetection(t *testing.T) {
 	
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	handler := &ConnectionHandler{
-		config:        config,
-		lastHeartbeat: time.Now(),
-		logger:        logger,
+		config:    config,
+		heartbeat: newTestHeartbeatMonitor(t, config),
+		logger:    logger,
 	}
 	
 	// Send first heartbeat
@@ -136,13 +136,14 @@ func TestHeartbeatStateTracking(t *testing.T) {
 	initialTime := time.Now()
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	handler := &ConnectionHandler{
-		config:        config,
-		lastHeartbeat: initialTime,
-		logger:        logger,
+		config:    config,
+		heartbeat: newTestHeartbeatMonitor(t, config),
+		logger:    logger,
 	}
 	
 	// Check initial state
-	assert.Equal(t, initialTime, handler.lastHeartbeat, "Initial heartbeat time should be set")
+	assert.False(t, handler.heartbeat.LastHeartbeat().Before(initialTime), "Initial heartbeat time should be set")
+	initialTime = handler.heartbeat.LastHeartbeat()
 	
 	// Wait a bit to ensure time difference
 	time.Sleep(10 * time.Millisecond)
@@ -165,7 +166,7 @@ func TestHeartbeatStateTracking(t *testing.T) {
 	_ = handler.handleHeartbeat(frame)
 	
 	// Check state was updated (even though the call failed)
-	assert.True(t, handler.lastHeartbeat.After(initialTime), "Last heartbeat time should be updated")
+	assert.True(t, handler.heartbeat.LastHeartbeat().After(initialTime), "Last heartbeat time should be updated")
 }
 
 func BenchmarkHeartbeatProcessing(b *testing.B) {
@@ -173,9 +174,9 @@ func BenchmarkHeartbeatProcessing(b *testing.B) {
 	
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	handler := &ConnectionHandler{
-		config:        config,
-		lastHeartbeat: time.Now(),
-		logger:        logger,
+		config:    config,
+		heartbeat: newTestHeartbeatMonitor(b, config),
+		logger:    logger,
 	}
 	
 	// Prepare heartbeat frame
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// pongRecord remembers the timestamps of the last PONG so the client's echo
+// in its next HEARTBEAT completes an NTP-style exchange.
+type pongRecord struct {
+	clientSentMs int64 // HEARTBEAT timestamp_ms (client clock)
+	serverSentMs int64 // PONG server_timestamp_ms (server clock)
+}
+
+// estimateClockTiming derives round-trip time and client clock skew from one
+// heartbeat exchange, treating server receive and send as simultaneous.
+// Positive skew means the client clock is ahead of the server's.
+func estimateClockTiming(last pongRecord, clientReceivedMs int64) (rtt, skew time.Duration, ok bool) {
+	rttMs := clientReceivedMs - last.clientSentMs
+	if last.clientSentMs == 0 || last.serverSentMs == 0 || rttMs < 0 {
+		return 0, 0, false
+	}
+	// Offset of the client clock at the midpoint of the exchange
+	skewMs := float64(last.clientSentMs+clientReceivedMs)/2 - float64(last.serverSentMs)
+	return time.Duration(rttMs) * time.Millisecond, time.Duration(skewMs * float64(time.Millisecond)), true
+}
+
+// heartbeatTiming holds the latest RTT and skew measured on a connection.
+type heartbeatTiming struct {
+	rtt     atomic.Int64 // nanoseconds
+	skew    atomic.Int64 // nanoseconds
+	samples atomic.Uint64
+}
+
+func (t *heartbeatTiming) record(rtt, skew time.Duration) {
+	t.rtt.Store(int64(rtt))
+	t.skew.Store(int64(skew))
+	t.samples.Add(1)
+}
+
+// HeartbeatTiming returns the connection's latest RTT and client clock skew,
+// and false until a client has echoed a PONG.
+func (c *Connection) HeartbeatTiming() (rtt, skew time.Duration, ok bool) {
+	if c.timing.samples.Load() == 0 {
+		return 0, 0, false
+	}
+	return time.Duration(c.timing.rtt.Load()), time.Duration(c.timing.skew.Load()), true
+}
+
+// recordHeartbeatTiming completes the exchange started by the last PONG and
+// warns the client once if its clock is off by more than the threshold.
+func (h *ConnectionHandler) recordHeartbeatTiming(hb *pb.HeartbeatRequest) {
+	if hb.PongReceivedMs == 0 || hb.PongServerTimestampMs != h.lastPong.serverSentMs {
+		return
+	}
+	rtt, skew, ok := estimateClockTiming(h.lastPong, hb.PongReceivedMs)
+	if !ok {
+		return
+	}
+	h.conn.timing.record(rtt, skew)
+	if h.server != nil && h.server.prometheusMetrics != nil {
+		h.server.prometheusMetrics.ObserveHeartbeatTiming(rtt, skew)
+	}
+
+	threshold := h.config.ClockSkewWarnThreshold
+	if threshold <= 0 || h.skewWarned || (skew < threshold && skew > -threshold) {
+		return
+	}
+	h.skewWarned = true
+	h.logger.Warn("client clock skew exceeds threshold",
+		"skew", skew,
+		"rtt", rtt,
+		"threshold", threshold,
+	)
+	if err := h.conn.SendInfo(pb.InfoCode_INFO_CODE_CLOCK_SKEW, "client clock skew exceeds threshold", map[string]string{
+		"skew_ms":      strconv.FormatInt(skew.Milliseconds(), 10),
+		"rtt_ms":       strconv.FormatInt(rtt.Milliseconds(), 10),
+		"threshold_ms": strconv.FormatInt(threshold.Milliseconds(), 10),
+	}); err != nil {
+		h.logger.Warn("failed to send clock skew notice", "error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestEstimateClockTiming(t *testing.T) {
+	// Client clock 2s ahead, 10ms each way
+	rtt, skew, ok := estimateClockTiming(pongRecord{clientSentMs: 3_990, serverSentMs: 2_000}, 4_010)
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, rtt)
+	assert.Equal(t, 2*time.Second, skew)
+
+	// Client clock behind
+	_, skew, ok = estimateClockTiming(pongRecord{clientSentMs: 490, serverSentMs: 1_000}, 510)
+	require.True(t, ok)
+	assert.Equal(t, -500*time.Millisecond, skew)
+
+	// A receive time before the send time is not a valid sample
+	_, _, ok = estimateClockTiming(pongRecord{clientSentMs: 1_000, serverSentMs: 1_000}, 999)
+	assert.False(t, ok)
+}
+
+func TestHeartbeatReportsRTTAndClockSkew(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	base := time.Now().UnixMilli()
+	clock := NewFakeClock(time.UnixMilli(base))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.ClockSkewWarnThreshold = time.Second
+	conn := NewConnection(serverSide, config)
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	handler := &ConnectionHandler{
+		conn:   conn,
+		config: config,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	readFrame := func(want protocol.MessageType, msg proto.Message) {
+		t.Helper()
+		clientSide.SetReadDeadline(time.Now().Add(time.Second))
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		require.Equal(t, want, frame.Type)
+		require.NoError(t, proto.Unmarshal(frame.Payload, msg))
+	}
+	heartbeat := func(hb *pb.HeartbeatRequest) <-chan error {
+		payload, err := proto.Marshal(hb)
+		require.NoError(t, err)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- handler.handleHeartbeat(&protocol.Frame{Type: protocol.MessageTypeHeartbeat, Payload: payload})
+		}()
+		return errCh
+	}
+
+	// Client clock runs 3s ahead with a 10ms one-way delay
+	errCh := heartbeat(&pb.HeartbeatRequest{TimestampMs: base + 2990, Sequence: 1})
+	var pong pb.HeartbeatResponse
+	readFrame(protocol.MessageTypePong, &pong)
+	require.NoError(t, <-errCh)
+	assert.Equal(t, base, pong.ServerTimestampMs)
+
+	_, _, ok := conn.HeartbeatTiming()
+	assert.False(t, ok, "no sample until the client echoes a PONG")
+
+	clock.Advance(config.HeartbeatInterval)
+	errCh = heartbeat(&pb.HeartbeatRequest{
+		TimestampMs:           base + 17990,
+		Sequence:              2,
+		PongServerTimestampMs: pong.ServerTimestampMs,
+		PongReceivedMs:        base + 3010,
+	})
+	var info pb.InfoMessage
+	readFrame(protocol.MessageTypeInfo, &info)
+	readFrame(protocol.MessageTypePong, &pong)
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, pb.InfoCode_INFO_CODE_CLOCK_SKEW, info.Code)
+	assert.Equal(t, "3000", info.Metadata["skew_ms"])
+	assert.Equal(t, "20", info.Metadata["rtt_ms"])
+
+	rtt, skew, ok := conn.HeartbeatTiming()
+	require.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, rtt)
+	assert.Equal(t, 3*time.Second, skew)
+
+	stats := conn.GetStats()
+	assert.Equal(t, 20.0, stats["heartbeat_rtt_ms"])
+	assert.Equal(t, 3000.0, stats["clock_skew_ms"])
+
+	// The notice is sent once per connection; a stale echo is ignored
+	clock.Advance(config.HeartbeatInterval)
+	errCh = heartbeat(&pb.HeartbeatRequest{
+		TimestampMs:           base + 32990,
+		Sequence:              3,
+		PongServerTimestampMs: 1,
+		PongReceivedMs:        base + 18010,
+	})
+	readFrame(protocol.MessageTypePong, &pong)
+	require.NoError(t, <-errCh)
+}
+
+func TestClockSkewThresholdFromEnv(t *testing.T) {
+	t.Setenv("CLOCK_SKEW_WARN_THRESHOLD", "250ms")
+	cfg := DefaultConfig()
+	LoadConfigFromEnv(cfg)
+	assert.Equal(t, 250*time.Millisecond, cfg.ClockSkewWarnThreshold)
+}
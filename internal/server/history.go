@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// TickHistory keeps the recent ticks of each symbol and subscription mode,
+// up to window old and maxTicks per series, to answer HISTORY requests.
+type TickHistory struct {
+	window     time.Duration
+	maxTicks   int
+	maxSymbols int
+
+	mu     sync.RWMutex
+	series map[lastValueKey][]*pb.Tick // Oldest first
+
+	dropped atomic.Uint64 // Ticks for new series refused once maxSymbols is reached
+	served  atomic.Uint64 // Requests answered
+}
+
+// NewTickHistory creates a history covering window, holding up to maxTicks
+// per symbol and mode and up to maxSymbols series (0 means unbounded).
+func NewTickHistory(window time.Duration, maxTicks, maxSymbols int) *TickHistory {
+	if maxTicks <= 0 {
+		maxTicks = 3600
+	}
+	return &TickHistory{
+		window:     window,
+		maxTicks:   maxTicks,
+		maxSymbols: maxSymbols,
+		series:     make(map[lastValueKey][]*pb.Tick),
+	}
+}
+
+// Window returns how far back the history reaches.
+func (th *TickHistory) Window() time.Duration {
+	return th.window
+}
+
+// Update appends ticks to their series. Every connection streaming the data
+// source reports the same ticks, so a tick no newer than the last one
+// recorded for its series is taken as a repeat and skipped.
+func (th *TickHistory) Update(ticks []*pb.Tick) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	for _, tick := range ticks {
+		if tick.Symbol == "" {
+			continue
+		}
+		key := lastValueKey{mode: tick.Mode, symbol: tick.Symbol}
+		series, ok := th.series[key]
+		if !ok && th.maxSymbols > 0 && len(th.series) >= th.maxSymbols {
+			th.dropped.Add(1)
+			continue
+		}
+		if n := len(series); n > 0 && series[n-1].TimestampMs >= tick.TimestampMs {
+			continue
+		}
+		series = append(series, tick)
+
+		// Age out ticks older than the window, and the oldest past maxTicks
+		cutoff := tick.TimestampMs - th.window.Milliseconds()
+		start := max(0, len(series)-th.maxTicks)
+		for start < len(series) && series[start].TimestampMs < cutoff {
+			start++
+		}
+		// Appending past capacity copies the live ticks, so the dropped head
+		// is released then
+		th.series[key] = series[start:]
+	}
+}
+
+// Range returns the ticks in mode of each of symbols from sinceMs on, oldest
+// first. Ticks with the same timestamp keep the order of symbols.
+func (th *TickHistory) Range(mode pb.SubscriptionMode, symbols []string, sinceMs int64) []*pb.Tick {
+	th.mu.RLock()
+	var ticks []*pb.Tick
+	for _, symbol := range symbols {
+		series := th.series[lastValueKey{mode: mode, symbol: symbol}]
+		i := sort.Search(len(series), func(i int) bool { return series[i].TimestampMs >= sinceMs })
+		ticks = append(ticks, series[i:]...)
+	}
+	th.mu.RUnlock()
+
+	sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].TimestampMs < ticks[j].TimestampMs })
+	return ticks
+}
+
+// GetStats returns tick history statistics.
+func (th *TickHistory) GetStats() map[string]interface{} {
+	th.mu.RLock()
+	symbols, ticks := len(th.series), 0
+	for _, series := range th.series {
+		ticks += len(series)
+	}
+	th.mu.RUnlock()
+	return map[string]interface{}{
+		"symbols":        symbols,
+		"ticks":          ticks,
+		"window":         th.window.String(),
+		"max_ticks":      th.maxTicks,
+		"max_symbols":    th.maxSymbols,
+		"dropped_total":  th.dropped.Load(),
+		"requests_total": th.served.Load(),
+	}
+}
+
+// handleHistory answers a HISTORY request with the recent ticks of the
+// requested symbols as snapshot batches, then an ACK. Each batch is written
+// before the next is built, so a long history is paced by the client and
+// live batches queued meanwhile go out between them.
+func (h *ConnectionHandler) handleHistory(frame *protocol.Frame) error {
+	var req pb.HistoryRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal history request: %w", err)
+	}
+	if err := protocol.ValidateHistoryRequest(&req); err != nil {
+		return fmt.Errorf("history validation failed: %w", err)
+	}
+	h.conn.setCorrelationID(req.RequestId)
+	defer h.conn.setCorrelationID("")
+
+	if h.server == nil || h.server.history == nil {
+		h.countHistory("refused")
+		if err := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE,
+			"History disabled", "Server does not retain tick history"); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return nil
+	}
+	if err := h.checkTenantSymbols(req.Symbols); err != nil {
+		// The client was told which symbol was refused
+		h.countHistory("refused")
+		return nil
+	}
+
+	mode := req.Mode
+	if mode == pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED {
+		mode = pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+		if sub := h.conn.GetSubscription(); sub != nil {
+			mode = sub.Mode
+		}
+	}
+	history := h.server.history
+	span := min(time.Duration(req.Minutes)*time.Minute, history.Window())
+	since := h.conn.now().Add(-span).UnixMilli()
+	ticks := history.Range(mode, req.Symbols, since)
+
+	batchSize := h.config.MaxBatchSize
+	if batchSize <= 0 || batchSize > protocol.MaxTicksPerBatch {
+		batchSize = protocol.MaxTicksPerBatch
+	}
+	batches := 0
+	for start := 0; start < len(ticks); start += batchSize {
+		end := min(start+batchSize, len(ticks))
+		if err := h.conn.sendSnapshotSync(ticks[start:end]); err != nil {
+			return fmt.Errorf("failed to send history: %w", err)
+		}
+		batches++
+	}
+
+	history.served.Add(1)
+	if len(ticks) > 0 {
+		h.countHistory("served")
+	} else {
+		h.countHistory("empty")
+	}
+	h.logger.Debug("history request",
+		"symbols", req.Symbols,
+		"mode", mode.String(),
+		"span", span,
+		"ticks", len(ticks),
+	)
+	return h.conn.SendHistoryComplete(map[string]string{
+		"ticks":   strconv.Itoa(len(ticks)),
+		"batches": strconv.Itoa(batches),
+		"from_ms": strconv.FormatInt(since, 10),
+	})
+}
+
+// countHistory counts a HISTORY request outcome.
+func (h *ConnectionHandler) countHistory(result string) {
+	if h.server != nil && h.server.prometheusMetrics != nil {
+		h.server.prometheusMetrics.IncrementHistoryRequests(h.server.instanceID, result)
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func historyTick(symbol string, ms int64) *pb.Tick {
+	return &pb.Tick{Symbol: symbol, TimestampMs: ms, Price: 1, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+}
+
+func tickTimes(ticks []*pb.Tick) []string {
+	out := make([]string, len(ticks))
+	for i, tick := range ticks {
+		out[i] = fmt.Sprintf("%s@%d", tick.Symbol, tick.TimestampMs)
+	}
+	return out
+}
+
+func TestTickHistoryWindowAndLimit(t *testing.T) {
+	second := pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	th := NewTickHistory(10*time.Second, 5, 0)
+	for ms := int64(0); ms <= 20_000; ms += 1000 {
+		th.Update([]*pb.Tick{historyTick("EURUSD", ms)})
+	}
+	// maxTicks keeps the last five even though the window reaches further
+	assert.Equal(t, []string{"EURUSD@16000", "EURUSD@17000", "EURUSD@18000", "EURUSD@19000", "EURUSD@20000"},
+		tickTimes(th.Range(second, []string{"EURUSD"}, 0)))
+
+	// After a pause only ticks within the window of the newest remain
+	th.Update([]*pb.Tick{historyTick("EURUSD", 29_000)})
+	assert.Equal(t, []string{"EURUSD@19000", "EURUSD@20000", "EURUSD@29000"}, tickTimes(th.Range(second, []string{"EURUSD"}, 0)))
+	assert.Equal(t, []string{"EURUSD@29000"}, tickTimes(th.Range(second, []string{"EURUSD"}, 25_000)))
+	assert.Empty(t, th.Range(pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE, []string{"EURUSD"}, 0))
+}
+
+func TestTickHistoryMergesSymbolsAndSkipsRepeats(t *testing.T) {
+	second := pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	th := NewTickHistory(time.Minute, 100, 2)
+	batch := []*pb.Tick{historyTick("EURUSD", 1000), historyTick("GBPUSD", 1500), historyTick("EURUSD", 2000)}
+	// Two connections streaming the source report the same ticks
+	th.Update(batch)
+	th.Update(batch)
+	th.Update([]*pb.Tick{historyTick("USDJPY", 1000), historyTick("GBPUSD", 2000)})
+
+	assert.Equal(t, []string{"EURUSD@1000", "GBPUSD@1500", "EURUSD@2000", "GBPUSD@2000"},
+		tickTimes(th.Range(second, []string{"EURUSD", "GBPUSD", "USDJPY"}, 0)))
+	stats := th.GetStats()
+	assert.Equal(t, 2, stats["symbols"])
+	assert.Equal(t, 4, stats["ticks"])
+	assert.Equal(t, uint64(1), stats["dropped_total"], "a third symbol exceeds maxSymbols")
+}
+
+// requestHistory dispatches req on an authenticated connection and returns
+// the frames sent in answer, up to and including the ACK or ERROR.
+func requestHistory(t *testing.T, config *Config, srv *Server, req *pb.HistoryRequest) []*protocol.Frame {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		server:        srv,
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	payload, err := proto.Marshal(req)
+	require.NoError(t, err)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewDispatcher().Dispatch(context.Background(), handler, &protocol.Frame{Type: protocol.MessageTypeHistory, Payload: payload})
+	}()
+
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	var frames []*protocol.Frame
+	for {
+		clientSide.SetReadDeadline(time.Now().Add(time.Second))
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		frames = append(frames, frame)
+		if frame.Type != protocol.MessageTypeDataBatch {
+			break
+		}
+	}
+	require.NoError(t, <-errCh)
+	return frames
+}
+
+func historyServer(config *Config) *Server {
+	srv := &Server{
+		config:            config,
+		logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		prometheusMetrics: NewPrometheusMetricsWithRegistry(prometheus.NewRegistry()),
+	}
+	if config.HistoryWindow > 0 {
+		srv.history = NewTickHistory(config.HistoryWindow, config.HistoryMaxTicks, config.HistoryMaxSymbols)
+	}
+	return srv
+}
+
+func TestHistoryRequestSendsSnapshotBatches(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(now)
+	config.HistoryWindow = 10 * time.Minute
+	config.MaxBatchSize = 2
+	srv := historyServer(config)
+	for _, ago := range []time.Duration{20 * time.Minute, 4 * time.Minute, 3 * time.Minute, 2 * time.Minute, time.Minute} {
+		ms := now.Add(-ago).UnixMilli()
+		srv.history.Update([]*pb.Tick{historyTick("EURUSD", ms), historyTick("GBPUSD", ms)})
+	}
+
+	frames := requestHistory(t, config, srv, &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: 3, RequestId: "h1"})
+	require.Len(t, frames, 3)
+	var got []*pb.Tick
+	for _, frame := range frames[:2] {
+		var batch pb.DataBatch
+		require.NoError(t, protocol.UnmarshalMessage(frame, &batch))
+		assert.True(t, batch.IsSnapshot)
+		assert.Zero(t, batch.BatchSequence, "history is outside the live sequence")
+		got = append(got, batch.Ticks...)
+	}
+	assert.Equal(t, []string{
+		fmt.Sprintf("EURUSD@%d", now.Add(-3*time.Minute).UnixMilli()),
+		fmt.Sprintf("EURUSD@%d", now.Add(-2*time.Minute).UnixMilli()),
+		fmt.Sprintf("EURUSD@%d", now.Add(-time.Minute).UnixMilli()),
+	}, tickTimes(got))
+
+	var ack pb.AckResponse
+	require.NoError(t, protocol.UnmarshalMessage(frames[2], &ack))
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_HISTORY, ack.AckType)
+	assert.Equal(t, "h1", ack.CorrelationId)
+	assert.Equal(t, "3", ack.Metadata["ticks"])
+	assert.Equal(t, "2", ack.Metadata["batches"])
+
+	// Requests beyond the window are capped to it
+	frames = requestHistory(t, config, srv, &pb.HistoryRequest{Symbols: []string{"EURUSD", "GBPUSD"}, Minutes: 60})
+	require.Len(t, frames, 5)
+	require.NoError(t, protocol.UnmarshalMessage(frames[4], &ack))
+	assert.Equal(t, "8", ack.Metadata["ticks"])
+	assert.Equal(t, strconv.FormatInt(now.Add(-10*time.Minute).UnixMilli(), 10), ack.Metadata["from_ms"])
+	assert.Equal(t, 2.0, counterValue(t, srv.prometheusMetrics.historyRequests.WithLabelValues(srv.instanceID, "served")))
+}
+
+func TestHistoryRequestRefused(t *testing.T) {
+	config := DefaultConfig()
+	frames := requestHistory(t, config, historyServer(config), &pb.HistoryRequest{Symbols: []string{"EURUSD"}, Minutes: 5})
+	require.Len(t, frames, 1)
+	var resp pb.ErrorResponse
+	require.NoError(t, protocol.UnmarshalMessage(frames[0], &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_GAP_UNAVAILABLE, resp.Code)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrVetoed wraps the error a lifecycle hook rejected a connection or
+// subscription with.
+var ErrVetoed = errors.New("vetoed by lifecycle hook")
+
+// ConnectHook runs when a connection is accepted, after the TLS handshake and
+// before the client authenticates. An error closes the connection.
+type ConnectHook func(ctx context.Context, conn *Connection) error
+
+// AuthenticatedHook runs once the client's credentials are verified;
+// conn.Session() holds the new session. An error rejects the client with
+// INVALID_AUTH, using the error text as the details.
+type AuthenticatedHook func(ctx context.Context, conn *Connection) error
+
+// SubscribeHook runs before a SUBSCRIBE takes effect. An error rejects it with
+// INVALID_SUBSCRIPTION, using the error text as the details. Subscriptions
+// restored by RESUME do not run it again.
+type SubscribeHook func(ctx context.Context, conn *Connection, sub *Subscription) error
+
+// DisconnectHook runs when a connection that passed its connect hooks ends.
+// err is the reason, nil for a clean close.
+type DisconnectHook func(conn *Connection, err error)
+
+// Hooks holds the callbacks for connection lifecycle events. Hooks run on the
+// connection's goroutine in registration order, and the first error stops
+// the rest. Register them before the server starts.
+type Hooks struct {
+	connect       []ConnectHook
+	authenticated []AuthenticatedHook
+	subscribe     []SubscribeHook
+	disconnect    []DisconnectHook
+}
+
+// OnConnect registers fn for accepted connections.
+func (h *Hooks) OnConnect(fn ConnectHook) {
+	h.connect = append(h.connect, fn)
+}
+
+// OnAuthenticated registers fn for authenticated clients.
+func (h *Hooks) OnAuthenticated(fn AuthenticatedHook) {
+	h.authenticated = append(h.authenticated, fn)
+}
+
+// OnSubscribe registers fn for subscription requests.
+func (h *Hooks) OnSubscribe(fn SubscribeHook) {
+	h.subscribe = append(h.subscribe, fn)
+}
+
+// OnDisconnect registers fn for ended connections.
+func (h *Hooks) OnDisconnect(fn DisconnectHook) {
+	h.disconnect = append(h.disconnect, fn)
+}
+
+func (h *Hooks) runConnect(ctx context.Context, conn *Connection) error {
+	if h == nil {
+		return nil
+	}
+	for _, fn := range h.connect {
+		if err := fn(ctx, conn); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+func (h *Hooks) runAuthenticated(ctx context.Context, conn *Connection) error {
+	if h == nil {
+		return nil
+	}
+	for _, fn := range h.authenticated {
+		if err := fn(ctx, conn); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+func (h *Hooks) runSubscribe(ctx context.Context, conn *Connection, sub *Subscription) error {
+	if h == nil {
+		return nil
+	}
+	for _, fn := range h.subscribe {
+		if err := fn(ctx, conn, sub); err != nil {
+			return fmt.Errorf("%w: %w", ErrVetoed, err)
+		}
+	}
+	return nil
+}
+
+func (h *Hooks) runDisconnect(conn *Connection, err error) {
+	if h == nil {
+		return
+	}
+	for _, fn := range h.disconnect {
+		fn(conn, err)
+	}
+}
+
+// vetoReason returns the text of the hook error a veto wraps.
+func vetoReason(err error) string {
+	if wrapped, ok := err.(interface{ Unwrap() []error }); ok {
+		if errs := wrapped.Unwrap(); len(errs) == 2 {
+			return errs[1].Error()
+		}
+	}
+	return err.Error()
+}
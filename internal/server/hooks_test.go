@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
+)
+
+func TestLifecycleHooksVeto(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.Authenticator = authn.AuthenticatorFunc(func(_ context.Context, _ authn.ConnMeta, req authn.Request) (authn.Session, error) {
+		return authn.Session{Username: req.Username}, nil
+	})
+	server := NewServer(config)
+
+	var connects atomic.Int32
+	disconnects := make(chan error, 3)
+	server.Hooks().OnConnect(func(_ context.Context, conn *Connection) error {
+		if connects.Add(1) == 3 {
+			return errors.New("maintenance")
+		}
+		return nil
+	})
+	server.Hooks().OnAuthenticated(func(_ context.Context, conn *Connection) error {
+		if conn.Session().Username == "suspended" {
+			return errors.New("account suspended")
+		}
+		return nil
+	})
+	server.Hooks().OnSubscribe(func(_ context.Context, conn *Connection, sub *Subscription) error {
+		assert.Equal(t, "alice", conn.Session().Username)
+		return errors.New("symbol " + sub.Symbols[0] + " not licensed")
+	})
+	server.Hooks().OnDisconnect(func(_ *Connection, err error) {
+		disconnects <- err
+	})
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	dial := func() (net.Conn, *protocol.FrameReader, *protocol.FrameWriter) {
+		// Stay under the per-IP connection burst limit
+		time.Sleep(150 * time.Millisecond)
+		client, err := net.Dial("tcp", server.listener.Addr().String())
+		require.NoError(t, err)
+		client.SetDeadline(time.Now().Add(2 * time.Second))
+		return client, protocol.NewFrameReader(client, protocol.DefaultMaxMessageSize), protocol.NewFrameWriter(client)
+	}
+	send := func(w *protocol.FrameWriter, msgType protocol.MessageType, msg proto.Message) {
+		frame, err := protocol.MarshalMessage(msgType, msg)
+		require.NoError(t, err)
+		require.NoError(t, w.WriteFrame(frame))
+	}
+	readError := func(r *protocol.FrameReader) *pb.ErrorResponse {
+		frame, err := r.ReadFrame()
+		require.NoError(t, err)
+		require.Equal(t, protocol.MessageTypeError, frame.Type)
+		var resp pb.ErrorResponse
+		require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+		return &resp
+	}
+
+	// Subscription veto
+	client, r, w := dial()
+	defer client.Close()
+	send(w, protocol.MessageTypeAuth, &pb.AuthRequest{Username: "alice", Password: "x"})
+	frame, err := r.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeACK, frame.Type)
+	send(w, protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, Symbols: []string{"ACME"}})
+	resp := readError(r)
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION, resp.Code)
+	assert.Equal(t, "symbol ACME not licensed", resp.Details)
+	assert.ErrorIs(t, <-disconnects, ErrVetoed)
+
+	// Authentication veto
+	client, r, w = dial()
+	defer client.Close()
+	send(w, protocol.MessageTypeAuth, &pb.AuthRequest{Username: "suspended", Password: "x"})
+	resp = readError(r)
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_INVALID_AUTH, resp.Code)
+	assert.Equal(t, "account suspended", resp.Details)
+	assert.ErrorIs(t, <-disconnects, ErrVetoed)
+
+	// Connection veto: closed before authentication, without a disconnect callback
+	client, _, _ = dial()
+	defer client.Close()
+	_, err = client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Empty(t, disconnects)
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+var (
+	// ErrNotProducer is returned when a client that is not a configured
+	// producer publishes ticks.
+	ErrNotProducer = errors.New("not a registered producer")
+
+	// ErrProducerRateLimited is returned when a producer publishes ticks
+	// faster than IngestConfig.Rate allows.
+	ErrProducerRateLimited = errors.New("producer tick rate exceeded")
+)
+
+// ProducerConfig identifies an upstream system allowed to publish ticks.
+type ProducerConfig struct {
+	Name  string // Username allowed to send PUBLISH frames
+	Token string // Bearer token for POST /ingest; empty allows PUBLISH frames only
+}
+
+// IngestConfig enables the ingestion API: producers push ticks that are
+// broadcast to subscribers instead of generated ones.
+type IngestConfig struct {
+	Producers []ProducerConfig
+	Rate      float64 // Ticks per second per producer (0 disables)
+	Burst     int     // Defaults to the larger of one second's ticks and one full publish
+
+	once sync.Once
+	hub  *IngestHub
+}
+
+// parseProducerSpecs parses INGEST_PRODUCERS, comma-separated entries of the
+// form "name[:token]".
+func parseProducerSpecs(spec string) ([]ProducerConfig, error) {
+	var producers []ProducerConfig
+	for _, entry := range splitAndTrimCSV(spec) {
+		name, token, _ := strings.Cut(entry, ":")
+		if name == "" {
+			return nil, fmt.Errorf("invalid producer %q: expected name[:token]", entry)
+		}
+		producers = append(producers, ProducerConfig{Name: name, Token: token})
+	}
+	return producers, nil
+}
+
+// Validate reports the first invalid setting.
+func (c *IngestConfig) Validate() error {
+	if len(c.Producers) == 0 {
+		return fmt.Errorf("ingestion needs at least one producer")
+	}
+	names := make(map[string]bool, len(c.Producers))
+	tokens := make(map[string]bool, len(c.Producers))
+	for _, p := range c.Producers {
+		if names[p.Name] {
+			return fmt.Errorf("duplicate producer %q", p.Name)
+		}
+		names[p.Name] = true
+		if p.Token != "" {
+			if tokens[p.Token] {
+				return fmt.Errorf("producer %q reuses another producer's token", p.Name)
+			}
+			tokens[p.Token] = true
+		}
+	}
+	if c.Rate < 0 || c.Burst < 0 {
+		return fmt.Errorf("ingest rate and burst must not be negative")
+	}
+	return nil
+}
+
+// source returns the hub shared by every subscription and producer.
+func (c *IngestConfig) source(clock Clock) *IngestHub {
+	c.once.Do(func() {
+		c.hub = newIngestHub(c, clock)
+	})
+	return c.hub
+}
+
+// ingestProducer is one producer's rate limit and counters.
+type ingestProducer struct {
+	name     string
+	limiter  *AcceptLimiter // nil when the rate is unlimited
+	accepted atomic.Uint64  // Ticks broadcast
+	rejected atomic.Uint64  // Ticks refused as invalid or over the rate
+}
+
+// IngestHub broadcasts ticks published by producers to every subscription.
+// It is the data source while ingestion is enabled.
+type IngestHub struct {
+	tickFanout
+
+	producers map[string]*ingestProducer
+	byToken   map[string]*ingestProducer
+}
+
+func newIngestHub(config *IngestConfig, clock Clock) *IngestHub {
+	h := &IngestHub{
+		producers: make(map[string]*ingestProducer, len(config.Producers)),
+		byToken:   make(map[string]*ingestProducer),
+	}
+	burst := config.Burst
+	if burst == 0 {
+		burst = max(int(math.Ceil(config.Rate)), protocol.CurrentLimits().MaxTicksPerBatch)
+	}
+	for _, pc := range config.Producers {
+		p := &ingestProducer{name: pc.Name}
+		if config.Rate > 0 {
+			p.limiter = NewAcceptLimiter(config.Rate, burst, clock)
+		}
+		h.producers[pc.Name] = p
+		if pc.Token != "" {
+			h.byToken[pc.Token] = p
+		}
+	}
+	return h
+}
+
+// Publish validates req from producer and broadcasts its ticks to matching
+// subscriptions. Nothing is broadcast when any tick is invalid.
+func (h *IngestHub) Publish(producer string, req *pb.PublishRequest) error {
+	p, ok := h.producers[producer]
+	if !ok {
+		return ErrNotProducer
+	}
+	return h.publish(p, req)
+}
+
+func (h *IngestHub) publish(p *ingestProducer, req *pb.PublishRequest) error {
+	if err := protocol.ValidatePublishRequest(req); err != nil {
+		p.rejected.Add(uint64(len(req.GetTicks())))
+		return err
+	}
+	if !p.limiter.AllowN(len(req.Ticks)) {
+		p.rejected.Add(uint64(len(req.Ticks)))
+		return ErrProducerRateLimited
+	}
+	p.accepted.Add(uint64(len(req.Ticks)))
+	protocol.Symbols.InternTicks(req.Ticks)
+	h.broadcast(req.Ticks)
+	return nil
+}
+
+// GetStats returns per-producer and delivery counters.
+func (h *IngestHub) GetStats() map[string]interface{} {
+	producers := make(map[string]interface{}, len(h.producers))
+	for name, p := range h.producers {
+		producers[name] = map[string]interface{}{
+			"accepted_ticks": p.accepted.Load(),
+			"rejected_ticks": p.rejected.Load(),
+		}
+	}
+	return map[string]interface{}{
+		"producers":       producers,
+		"subscribers":     h.subscribers(),
+		"delivered_ticks": h.delivered.Load(),
+	}
+}
+
+// handlePublish broadcasts ticks sent by an authenticated producer. Refused
+// publishes are answered with an error frame and leave the connection open.
+func (h *ConnectionHandler) handlePublish(frame *protocol.Frame) error {
+	var req pb.PublishRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal publish request: %w", err)
+	}
+
+	var producer string
+	if session := h.conn.Session(); session != nil {
+		producer = session.Username
+	}
+	err := ErrNotProducer
+	if h.config.Ingest != nil {
+		err = h.config.Ingest.source(h.config.clock()).Publish(producer, &req)
+	}
+
+	var sendErr error
+	switch {
+	case err == nil:
+		// Producers that never subscribe are not held to the subscribe deadline
+		if h.conn.stages.Stage() == StageSubscribe {
+			h.enterStreaming()
+		}
+		return h.conn.SendMessage(protocol.MessageTypeACK, &pb.AckResponse{
+			AckType:     pb.MessageType_MESSAGE_TYPE_PUBLISH,
+			Success:     true,
+			TimestampMs: h.conn.now().UnixMilli(),
+			Metadata:    map[string]string{"accepted": strconv.Itoa(len(req.Ticks))},
+		})
+	case errors.Is(err, ErrNotProducer):
+		h.logger.Warn("publish from non-producer refused", "user", producer)
+		sendErr = h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_NOT_PRODUCER)
+	case errors.Is(err, ErrProducerRateLimited):
+		sendErr = h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_RATE_LIMITED)
+	default:
+		h.logger.Warn("invalid publish refused", "producer", producer, "error", err)
+		sendErr = h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_MESSAGE, "Invalid publish", err.Error())
+	}
+	if sendErr != nil {
+		h.logger.Error(errorSendFailedMsg, "error", sendErr)
+	}
+	return nil
+}
+
+// maxIngestBodyBytes bounds POST /ingest bodies; JSON is several times larger
+// than the binary frame carrying the same ticks.
+const maxIngestBodyBytes = 8 * protocol.DefaultMaxMessageSize
+
+// handleIngest accepts a JSON PublishRequest from a producer identified by
+// its bearer token, answering {"accepted": N}.
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hub := s.config.Ingest.source(s.config.clock())
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	producer, ok := hub.byToken[token]
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req pb.PublishRequest
+	if err := protojson.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	switch err := hub.publish(producer, &req); {
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]int{"accepted": len(req.Ticks)})
+	case errors.Is(err, ErrProducerRateLimited):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
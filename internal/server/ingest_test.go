@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func ingestTick(symbol string, mode pb.SubscriptionMode) *pb.Tick {
+	return &pb.Tick{Symbol: symbol, Price: 1.5, TimestampMs: time.Now().UnixMilli(), Mode: mode}
+}
+
+// streamIngest subscribes to hub and returns the ticks it emits.
+func streamIngest(t *testing.T, hub *IngestHub, sub *Subscription) <-chan []*pb.Tick {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	received := make(chan []*pb.Tick, 10)
+	before := hub.GetStats()["subscribers"].(int)
+	go hub.Stream(ctx, sub, func(ticks []*pb.Tick) { received <- ticks })
+	require.Eventually(t, func() bool { return hub.GetStats()["subscribers"].(int) == before+1 }, time.Second, time.Millisecond)
+	return received
+}
+
+func TestIngestHubBroadcasts(t *testing.T) {
+	second := pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	minute := pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE
+	config := &IngestConfig{Producers: []ProducerConfig{{Name: "feed"}}}
+	require.NoError(t, config.Validate())
+	hub := config.source(NewFakeClock(time.Now()))
+
+	all := streamIngest(t, hub, NewSubscription(second))
+	eurOnly := NewSubscription(second)
+	eurOnly.setSymbols([]string{"EURUSD"})
+	eur := streamIngest(t, hub, eurOnly)
+
+	require.NoError(t, hub.Publish("feed", &pb.PublishRequest{Ticks: []*pb.Tick{
+		ingestTick("EURUSD", second), ingestTick("GBPUSD", second), ingestTick("EURUSD", minute),
+	}}))
+	assert.Len(t, <-all, 2)
+	got := <-eur
+	require.Len(t, got, 1)
+	assert.Equal(t, "EURUSD", got[0].Symbol)
+
+	assert.ErrorIs(t, hub.Publish("intruder", &pb.PublishRequest{Ticks: []*pb.Tick{ingestTick("EURUSD", second)}}), ErrNotProducer)
+	bad := ingestTick("EURUSD", second)
+	bad.Price = -1
+	assert.ErrorIs(t, hub.Publish("feed", &pb.PublishRequest{Ticks: []*pb.Tick{ingestTick("GBPUSD", second), bad}}), protocol.ErrInvalidRange)
+	assert.Empty(t, all, "nothing from a rejected publish is broadcast")
+
+	stats := hub.GetStats()["producers"].(map[string]interface{})["feed"].(map[string]interface{})
+	assert.Equal(t, uint64(3), stats["accepted_ticks"])
+	assert.Equal(t, uint64(2), stats["rejected_ticks"])
+}
+
+func TestIngestHubRateLimitsProducers(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	config := &IngestConfig{Producers: []ProducerConfig{{Name: "a"}, {Name: "b"}}, Rate: 2, Burst: 3}
+	hub := config.source(clock)
+	publish := func(producer string, n int) error {
+		req := &pb.PublishRequest{}
+		for i := 0; i < n; i++ {
+			req.Ticks = append(req.Ticks, ingestTick("EURUSD", pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND))
+		}
+		return hub.Publish(producer, req)
+	}
+
+	require.NoError(t, publish("a", 3))
+	assert.ErrorIs(t, publish("a", 1), ErrProducerRateLimited)
+	require.NoError(t, publish("b", 3), "limits are per producer")
+	clock.Advance(time.Second)
+	require.NoError(t, publish("a", 2))
+}
+
+func TestHandlePublish(t *testing.T) {
+	config := DefaultConfig()
+	config.Ingest = &IngestConfig{Producers: []ProducerConfig{{Name: "feed"}}}
+	publish := func(username string, req *pb.PublishRequest) *protocol.Frame {
+		serverSide, clientSide := net.Pipe()
+		conn := NewConnection(serverSide, config)
+		conn.SetAuthenticated(&auth.Session{Username: username})
+		handler := &ConnectionHandler{conn: conn, config: config, authenticated: true, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+		t.Cleanup(func() {
+			conn.Close()
+			clientSide.Close()
+		})
+
+		payload, err := proto.Marshal(req)
+		require.NoError(t, err)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- NewDispatcher().Dispatch(context.Background(), handler, &protocol.Frame{Type: protocol.MessageTypePublish, Payload: payload})
+		}()
+		clientSide.SetReadDeadline(time.Now().Add(time.Second))
+		frame, err := protocol.NewFrameReader(clientSide, config.MaxMessageSize).ReadFrame()
+		require.NoError(t, err)
+		require.NoError(t, <-errCh, "refusals keep the connection open")
+		return frame
+	}
+	req := &pb.PublishRequest{Ticks: []*pb.Tick{ingestTick("EURUSD", pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)}}
+
+	frame := publish("feed", req)
+	require.Equal(t, protocol.MessageTypeACK, frame.Type)
+	var ack pb.AckResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &ack))
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_PUBLISH, ack.AckType)
+	assert.Equal(t, "1", ack.Metadata["accepted"])
+
+	frame = publish("reader", req)
+	require.Equal(t, protocol.MessageTypeError, frame.Type)
+	var errResp pb.ErrorResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &errResp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_NOT_PRODUCER, errResp.Code)
+}
+
+func TestIngestEndpoint(t *testing.T) {
+	config := DefaultConfig()
+	config.Ingest = &IngestConfig{Producers: []ProducerConfig{{Name: "feed", Token: "feed-token"}, {Name: "tcp-only"}}}
+	srv := &Server{config: config}
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/ingest", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.handleIngest(rec, req)
+		return rec
+	}
+	ts := time.Now().UnixMilli()
+	body := `{"ticks":[{"symbol":"EURUSD","price":1.08,"timestampMs":"` + strconv.FormatInt(ts, 10) + `","mode":"SUBSCRIPTION_MODE_SECOND"}]}`
+
+	rec := do(http.MethodPost, "feed-token", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"accepted":1}`, rec.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "wrong", body).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "feed-token", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "feed-token", `{"ticks":[{"symbol":"EURUSD"}]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "feed-token", `not json`).Code)
+}
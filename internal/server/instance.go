@@ -9,6 +9,8 @@ import (
 	"runtime"
 	"sync/atomic"
 	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/buildinfo"
 )
 
 // InstanceInfo contains information about the server instance
@@ -59,11 +61,31 @@ func (s *Server) GetInstanceInfo() InstanceInfo {
 
 // GetVersion returns the server version
 func (s *Server) GetVersion() string {
-	// Try to get from environment or build info
+	// APP_VERSION overrides the version injected at build time
 	if version := os.Getenv("APP_VERSION"); version != "" {
 		return version
 	}
-	return "1.0.0" // Default version
+	return buildinfo.Version
+}
+
+// BuildInfo returns the build the server is running, with the version as
+// reported by GetVersion.
+func (s *Server) BuildInfo() buildinfo.Info {
+	info := buildinfo.Get()
+	info.Version = s.GetVersion()
+	return info
+}
+
+// authAckMetadata is sent with the AUTH ACK so clients can log which server
+// build they connected to, and ask to reconnect to the same instance.
+func (s *Server) authAckMetadata() map[string]string {
+	info := s.BuildInfo()
+	return map[string]string{
+		"server_version": info.Version,
+		"server_commit":  info.Commit,
+		"instance_id":    s.instanceID,
+		"affinity_token": s.AffinityToken(),
+	}
 }
 
 // GetInstanceMetrics returns instance-specific metrics
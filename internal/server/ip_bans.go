@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrInvalidBanIP is returned when a ban targets an unparseable IP.
+	ErrInvalidBanIP = errors.New("invalid IP for ban")
+)
+
+// IPBan is a runtime block on a single IP, optionally expiring.
+type IPBan struct {
+	IP        string     `json:"ip"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil means permanent
+}
+
+// expired reports whether the ban no longer applies at now.
+func (b IPBan) expired(now time.Time) bool {
+	return b.ExpiresAt != nil && !now.Before(*b.ExpiresAt)
+}
+
+// banList holds runtime bans for an IPFilter.
+type banList struct {
+	mu   sync.RWMutex
+	bans map[string]IPBan
+	file string // Optional persistence path
+}
+
+// isBanned reports whether ip has an active ban.
+func (f *IPFilter) isBanned(ip net.IP) bool {
+	f.banList.mu.RLock()
+	defer f.banList.mu.RUnlock()
+
+	if len(f.banList.bans) == 0 {
+		return false
+	}
+	ban, ok := f.banList.bans[normalizeIP(ip).String()]
+	return ok && !ban.expired(time.Now())
+}
+
+// Ban blocks ip for ttl (0 means permanent) and persists the ban list if a
+// ban file is configured. Re-banning an IP replaces its previous ban.
+func (f *IPFilter) Ban(ip net.IP, ttl time.Duration, reason string) (IPBan, error) {
+	if ip == nil {
+		return IPBan{}, ErrInvalidBanIP
+	}
+
+	now := time.Now()
+	ban := IPBan{IP: normalizeIP(ip).String(), Reason: reason, CreatedAt: now}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		ban.ExpiresAt = &expires
+	}
+
+	f.banList.mu.Lock()
+	if f.banList.bans == nil {
+		f.banList.bans = make(map[string]IPBan)
+	}
+	f.banList.bans[ban.IP] = ban
+	f.banList.mu.Unlock()
+
+	return ban, f.saveBans()
+}
+
+// Unban removes the ban on ip, reporting whether one existed.
+func (f *IPFilter) Unban(ip net.IP) (bool, error) {
+	if ip == nil {
+		return false, ErrInvalidBanIP
+	}
+
+	f.banList.mu.Lock()
+	key := normalizeIP(ip).String()
+	_, existed := f.banList.bans[key]
+	delete(f.banList.bans, key)
+	f.banList.mu.Unlock()
+
+	if !existed {
+		return false, nil
+	}
+	return true, f.saveBans()
+}
+
+// Bans returns active bans sorted by IP, pruning expired entries.
+func (f *IPFilter) Bans() []IPBan {
+	now := time.Now()
+
+	f.banList.mu.Lock()
+	out := make([]IPBan, 0, len(f.banList.bans))
+	for key, ban := range f.banList.bans {
+		if ban.expired(now) {
+			delete(f.banList.bans, key)
+			continue
+		}
+		out = append(out, ban)
+	}
+	f.banList.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].IP < out[j].IP })
+	return out
+}
+
+// SetBanFile enables persistence to path and loads any bans already stored there.
+// A missing file is not an error.
+func (f *IPFilter) SetBanFile(path string) error {
+	f.banList.mu.Lock()
+	f.banList.file = path
+	f.banList.mu.Unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ban file: %w", err)
+	}
+
+	var bans []IPBan
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("failed to parse ban file: %w", err)
+	}
+
+	now := time.Now()
+	f.banList.mu.Lock()
+	if f.banList.bans == nil {
+		f.banList.bans = make(map[string]IPBan)
+	}
+	for _, ban := range bans {
+		ip := net.ParseIP(ban.IP)
+		if ip == nil || ban.expired(now) {
+			continue
+		}
+		ban.IP = normalizeIP(ip).String()
+		f.banList.bans[ban.IP] = ban
+	}
+	f.banList.mu.Unlock()
+	return nil
+}
+
+// saveBans writes active bans to the ban file atomically, if one is configured.
+func (f *IPFilter) saveBans() error {
+	f.banList.mu.RLock()
+	path := f.banList.file
+	f.banList.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(f.Bans(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bans-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to persist bans: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to persist bans: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to persist bans: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to persist bans: %w", err)
+	}
+	return nil
+}
+
+// AuthFailureBanner bans IPs that fail authentication repeatedly.
+type AuthFailureBanner struct {
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	threshold int
+	window    time.Duration
+	duration  time.Duration
+	filter    *IPFilter
+
+	// Metrics
+	autoBans uint64
+}
+
+// NewAuthFailureBanner creates a banner that bans for duration after threshold
+// failures within window.
+func NewAuthFailureBanner(filter *IPFilter, threshold int, window, duration time.Duration) *AuthFailureBanner {
+	return &AuthFailureBanner{
+		failures:  make(map[string][]time.Time),
+		threshold: threshold,
+		window:    window,
+		duration:  duration,
+		filter:    filter,
+	}
+}
+
+// RecordFailure notes an auth failure from ip and bans it once the threshold
+// is reached. It returns the ban when one was applied.
+func (b *AuthFailureBanner) RecordFailure(ip net.IP) (*IPBan, error) {
+	if b == nil || ip == nil {
+		return nil, nil
+	}
+	key := normalizeIP(ip).String()
+	now := time.Now()
+	cutoff := now.Add(-b.window)
+
+	b.mu.Lock()
+	recent := b.failures[key][:0]
+	for _, t := range b.failures[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	trip := len(recent) >= b.threshold
+	if trip {
+		delete(b.failures, key)
+	} else {
+		b.failures[key] = recent
+	}
+	b.mu.Unlock()
+
+	if !trip {
+		return nil, nil
+	}
+
+	atomic.AddUint64(&b.autoBans, 1)
+	ban, err := b.filter.Ban(ip, b.duration,
+		fmt.Sprintf("%d authentication failures within %s", b.threshold, b.window))
+	return &ban, err
+}
+
+// RecordSuccess clears the failure history for ip.
+func (b *AuthFailureBanner) RecordSuccess(ip net.IP) {
+	if b == nil || ip == nil {
+		return
+	}
+	b.mu.Lock()
+	delete(b.failures, normalizeIP(ip).String())
+	b.mu.Unlock()
+}
+
+// Cleanup drops failure histories older than the window.
+func (b *AuthFailureBanner) Cleanup() {
+	if b == nil {
+		return
+	}
+	cutoff := time.Now().Add(-b.window)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, times := range b.failures {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(b.failures, key)
+		}
+	}
+}
+
+// AutoBans returns how many bans the banner has applied.
+func (b *AuthFailureBanner) AutoBans() uint64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&b.autoBans)
+}
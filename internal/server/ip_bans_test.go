@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilterBanAndExpiry(t *testing.T) {
+	f, err := NewIPFilterFromStrings(nil, nil)
+	require.NoError(t, err)
+	ip := net.ParseIP("203.0.113.9")
+
+	_, err = f.Ban(ip, 50*time.Millisecond, "test")
+	require.NoError(t, err)
+	assert.False(t, f.Allow(ip))
+	assert.True(t, f.Allow(net.ParseIP("203.0.113.10")), "bans are per IP")
+	require.Len(t, f.Bans(), 1)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, f.Allow(ip), "ban expires after its TTL")
+	assert.Empty(t, f.Bans(), "expired bans are pruned from the list")
+
+	_, err = f.Ban(ip, 0, "permanent")
+	require.NoError(t, err)
+	assert.Nil(t, f.Bans()[0].ExpiresAt)
+	removed, err := f.Unban(ip)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	assert.True(t, f.Allow(ip))
+
+	removed, err = f.Unban(ip)
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestIPFilterBanPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+
+	first, err := NewIPFilterFromStrings(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, first.SetBanFile(path), "missing file is not an error")
+	_, err = first.Ban(net.ParseIP("198.51.100.1"), time.Hour, "abuse")
+	require.NoError(t, err)
+	_, err = first.Ban(net.ParseIP("2001:db8::1"), 0, "")
+	require.NoError(t, err)
+
+	// A restarted server reloads the bans
+	second, err := NewIPFilterFromStrings(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, second.SetBanFile(path))
+	assert.False(t, second.Allow(net.ParseIP("198.51.100.1")))
+	assert.False(t, second.Allow(net.ParseIP("2001:db8::1")))
+
+	bans := second.Bans()
+	require.Len(t, bans, 2)
+	assert.Equal(t, "abuse", bans[0].Reason)
+}
+
+func TestAuthFailureBanner(t *testing.T) {
+	f, err := NewIPFilterFromStrings(nil, nil)
+	require.NoError(t, err)
+	banner := NewAuthFailureBanner(f, 3, time.Minute, 10*time.Minute)
+	ip := net.ParseIP("192.0.2.44")
+
+	for i := 0; i < 2; i++ {
+		ban, err := banner.RecordFailure(ip)
+		require.NoError(t, err)
+		assert.Nil(t, ban)
+	}
+	banner.RecordSuccess(ip)
+
+	// A success resets the count
+	for i := 0; i < 2; i++ {
+		ban, _ := banner.RecordFailure(ip)
+		assert.Nil(t, ban)
+	}
+	ban, err := banner.RecordFailure(ip)
+	require.NoError(t, err)
+	require.NotNil(t, ban)
+	assert.Equal(t, "192.0.2.44", ban.IP)
+	assert.False(t, f.Allow(ip))
+	assert.Equal(t, uint64(1), banner.AutoBans())
+}
+
+func TestAdminBansAPI(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	ipf, err := NewIPFilterFromStrings(nil, nil)
+	require.NoError(t, err)
+	s := &Server{config: config, ipFilter: ipf, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/bans", "", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/admin/bans", "wrong", "").StatusCode)
+
+	resp := do(http.MethodPost, "/admin/bans", "secret", `{"ip":"203.0.113.7","ttl":"10m","reason":"manual"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.False(t, ipf.Allow(net.ParseIP("203.0.113.7")))
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/bans", "secret", `{"ip":"nope"}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/bans", "secret", `{"ip":"203.0.113.8","ttl":"soon"}`).StatusCode)
+
+	resp = do(http.MethodGet, "/admin/bans", "secret", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var bans []IPBan
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&bans))
+	require.Len(t, bans, 1)
+	assert.Equal(t, "manual", bans[0].Reason)
+	assert.NotNil(t, bans[0].ExpiresAt)
+
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/bans?ip=203.0.113.7", "secret", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/admin/bans?ip=203.0.113.7", "secret", "").StatusCode)
+	assert.True(t, ipf.Allow(net.ParseIP("203.0.113.7")))
+}
+
+func TestAdminRoutesDisabledWithoutToken(t *testing.T) {
+	s := &Server{config: DefaultConfig()}
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/bans", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PerIPLimitPolicy controls what happens when an IP reaches its connection ceiling.
+type PerIPLimitPolicy string
+
+const (
+	// PerIPPolicyReject refuses the new connection.
+	PerIPPolicyReject PerIPLimitPolicy = "reject"
+	// PerIPPolicyEvictOldest closes the IP's oldest connection to admit the new one.
+	PerIPPolicyEvictOldest PerIPLimitPolicy = "evict_oldest"
+)
+
+// ipLimitOverride assigns a custom ceiling to a network; limit 0 means unlimited.
+type ipLimitOverride struct {
+	network *net.IPNet
+	limit   int
+}
+
+// parseIPLimitOverrides parses entries of the form "CIDR=limit" or "IP=limit".
+// An entry without "=limit" exempts the network entirely.
+func parseIPLimitOverrides(items []string) ([]ipLimitOverride, error) {
+	var overrides []ipLimitOverride
+	for _, raw := range items {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+
+		target, limitStr, hasLimit := strings.Cut(entry, "=")
+		limit := 0
+		if hasLimit {
+			n, err := strconv.Atoi(strings.TrimSpace(limitStr))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid limit in override %q", entry)
+			}
+			limit = n
+		}
+
+		nets, err := parseCIDRList([]string{target})
+		if err != nil || len(nets) != 1 {
+			return nil, fmt.Errorf("invalid network in override %q", entry)
+		}
+		overrides = append(overrides, ipLimitOverride{network: nets[0], limit: limit})
+	}
+	return overrides, nil
+}
+
+// IPConnLimiter enforces a ceiling on concurrent connections per remote IP,
+// independent of the global MaxConnections cap.
+type IPConnLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	policy    PerIPLimitPolicy
+	overrides []ipLimitOverride
+	active    map[string][]net.Conn // Ordered oldest first
+
+	// Metrics
+	rejected uint64
+	evicted  uint64
+}
+
+// NewIPConnLimiter creates a limiter from server configuration.
+func NewIPConnLimiter(config *Config) (*IPConnLimiter, error) {
+	overrides, err := parseIPLimitOverrides(config.MaxConnsPerIPOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := config.PerIPLimitPolicy
+	switch policy {
+	case PerIPPolicyReject, PerIPPolicyEvictOldest:
+	case "":
+		policy = PerIPPolicyReject
+	default:
+		return nil, fmt.Errorf("unknown per-IP limit policy %q", policy)
+	}
+
+	return &IPConnLimiter{
+		limit:     config.MaxConnsPerIP,
+		policy:    policy,
+		overrides: overrides,
+		active:    make(map[string][]net.Conn),
+	}, nil
+}
+
+// limitFor returns the ceiling for ip; the most specific matching override wins.
+func (l *IPConnLimiter) limitFor(ip net.IP) int {
+	limit := l.limit
+	bestPrefix := -1
+	nip := normalizeIP(ip)
+	for _, o := range l.overrides {
+		if !o.network.Contains(nip) {
+			continue
+		}
+		if ones, _ := o.network.Mask.Size(); ones > bestPrefix {
+			bestPrefix = ones
+			limit = o.limit
+		}
+	}
+	return limit
+}
+
+// Acquire admits conn against its IP's ceiling. With the evict_oldest policy
+// the IP's oldest connection is closed to make room; otherwise it returns false.
+func (l *IPConnLimiter) Acquire(conn net.Conn) bool {
+	if l == nil {
+		return true
+	}
+	ip := remoteIP(conn)
+	if ip == nil {
+		return true
+	}
+	key := ip.String()
+
+	l.mu.Lock()
+	limit := l.limitFor(ip)
+	conns := l.active[key]
+	var victim net.Conn
+	if limit > 0 && len(conns) >= limit {
+		if l.policy != PerIPPolicyEvictOldest {
+			l.mu.Unlock()
+			atomic.AddUint64(&l.rejected, 1)
+			return false
+		}
+		victim = conns[0]
+		conns = conns[1:]
+	}
+	l.active[key] = append(conns, conn)
+	l.mu.Unlock()
+
+	if victim != nil {
+		atomic.AddUint64(&l.evicted, 1)
+		victim.Close()
+	}
+	return true
+}
+
+// Release removes conn from its IP's active set. Releasing an evicted or
+// unknown connection is a no-op.
+func (l *IPConnLimiter) Release(conn net.Conn) {
+	if l == nil {
+		return
+	}
+	ip := remoteIP(conn)
+	if ip == nil {
+		return
+	}
+	key := ip.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conns := l.active[key]
+	for i, c := range conns {
+		if c == conn {
+			conns = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(conns) == 0 {
+		delete(l.active, key)
+	} else {
+		l.active[key] = conns
+	}
+}
+
+// Count returns the number of tracked connections for ip.
+func (l *IPConnLimiter) Count(ip net.IP) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.active[normalizeIP(ip).String()])
+}
+
+// GetStats returns per-IP limiter statistics.
+func (l *IPConnLimiter) GetStats() map[string]interface{} {
+	l.mu.Lock()
+	trackedIPs := len(l.active)
+	busiest := 0
+	for _, conns := range l.active {
+		if len(conns) > busiest {
+			busiest = len(conns)
+		}
+	}
+	l.mu.Unlock()
+
+	return map[string]interface{}{
+		"limit":             l.limit,
+		"policy":            string(l.policy),
+		"overrides":         len(l.overrides),
+		"tracked_ips":       trackedIPs,
+		"max_active_per_ip": busiest,
+		"rejected_total":    atomic.LoadUint64(&l.rejected),
+		"evicted_total":     atomic.LoadUint64(&l.evicted),
+	}
+}
+
+// remoteIP extracts the normalized remote IP of conn, or nil if unavailable.
+func remoteIP(conn net.Conn) net.IP {
+	if conn == nil || conn.RemoteAddr() == nil {
+		return nil
+	}
+	return hostIP(conn.RemoteAddr().String())
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addrConn is a net.Conn stub with a fixed remote address that records Close.
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+	closed atomic.Bool
+}
+
+func newAddrConn(ip string) *addrConn {
+	return &addrConn{remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}}
+}
+
+func (c *addrConn) RemoteAddr() net.Addr { return c.remote }
+func (c *addrConn) Close() error         { c.closed.Store(true); return nil }
+
+func newTestLimiter(t *testing.T, limit int, policy PerIPLimitPolicy, overrides ...string) *IPConnLimiter {
+	t.Helper()
+	config := DefaultConfig()
+	config.MaxConnsPerIP = limit
+	config.PerIPLimitPolicy = policy
+	config.MaxConnsPerIPOverrides = overrides
+	limiter, err := NewIPConnLimiter(config)
+	require.NoError(t, err)
+	return limiter
+}
+
+func TestIPConnLimiterRejectPolicy(t *testing.T) {
+	limiter := newTestLimiter(t, 2, PerIPPolicyReject)
+
+	a, b, c := newAddrConn("203.0.113.5"), newAddrConn("203.0.113.5"), newAddrConn("203.0.113.5")
+	assert.True(t, limiter.Acquire(a))
+	assert.True(t, limiter.Acquire(b))
+	assert.False(t, limiter.Acquire(c), "third connection from the same IP is rejected")
+	assert.True(t, limiter.Acquire(newAddrConn("198.51.100.1")), "other IPs are unaffected")
+
+	limiter.Release(a)
+	assert.True(t, limiter.Acquire(c), "releasing frees a slot")
+	assert.Equal(t, 2, limiter.Count(net.ParseIP("203.0.113.5")))
+	assert.Equal(t, uint64(1), limiter.GetStats()["rejected_total"])
+}
+
+func TestIPConnLimiterEvictOldestPolicy(t *testing.T) {
+	limiter := newTestLimiter(t, 2, PerIPPolicyEvictOldest)
+
+	a, b, c := newAddrConn("203.0.113.5"), newAddrConn("203.0.113.5"), newAddrConn("203.0.113.5")
+	require.True(t, limiter.Acquire(a))
+	require.True(t, limiter.Acquire(b))
+	assert.True(t, limiter.Acquire(c))
+
+	assert.True(t, a.closed.Load(), "oldest connection is evicted")
+	assert.False(t, b.closed.Load())
+	assert.Equal(t, 2, limiter.Count(net.ParseIP("203.0.113.5")))
+
+	// The evicted connection's deferred release must not free another slot
+	limiter.Release(a)
+	assert.Equal(t, 2, limiter.Count(net.ParseIP("203.0.113.5")))
+	assert.Equal(t, uint64(1), limiter.GetStats()["evicted_total"])
+}
+
+func TestIPConnLimiterOverrides(t *testing.T) {
+	limiter := newTestLimiter(t, 1, PerIPPolicyReject,
+		"10.0.0.0/8=3",
+		"10.1.2.3",
+	)
+
+	// Broad override raises the ceiling
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.Acquire(newAddrConn("10.9.9.9")))
+	}
+	assert.False(t, limiter.Acquire(newAddrConn("10.9.9.9")))
+
+	// The more specific bare entry exempts the NAT gateway entirely
+	for i := 0; i < 10; i++ {
+		assert.True(t, limiter.Acquire(newAddrConn("10.1.2.3")))
+	}
+
+	// Default ceiling applies elsewhere
+	assert.True(t, limiter.Acquire(newAddrConn("192.0.2.1")))
+	assert.False(t, limiter.Acquire(newAddrConn("192.0.2.1")))
+}
+
+func TestNewIPConnLimiterInvalidConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnsPerIPOverrides = []string{"not-a-network=5"}
+	_, err := NewIPConnLimiter(config)
+	assert.Error(t, err)
+
+	config = DefaultConfig()
+	config.MaxConnsPerIPOverrides = []string{"10.0.0.0/8=-1"}
+	_, err = NewIPConnLimiter(config)
+	assert.Error(t, err)
+
+	config = DefaultConfig()
+	config.PerIPLimitPolicy = "drop_newest"
+	_, err = NewIPConnLimiter(config)
+	assert.Error(t, err)
+}
@@ -10,12 +10,16 @@ import (
 // IPFilter provides allowlist/blocklist based filtering for remote IPs.
 //
 // Rules:
+// - If the IP has an active runtime ban, it is rejected.
 // - If blocklist matches, the IP is rejected regardless of allowlist.
 // - If allowlist is empty, all IPs are allowed (unless blocked).
 // - If allowlist is non-empty, only IPs inside at least one allowed network are permitted.
 type IPFilter struct {
 	allow []*net.IPNet
 	block []*net.IPNet
+
+	// Runtime bans with optional expiry (see ip_bans.go)
+	banList banList
 }
 
 // NewIPFilterFromStrings constructs an IPFilter from string slices.
@@ -46,6 +50,11 @@ func (f *IPFilter) Allow(ip net.IP) bool {
 
 	nip := normalizeIP(ip)
 
+	// Runtime bans take precedence over static lists
+	if f.isBanned(nip) {
+		return false
+	}
+
 	// Blocklist takes precedence
 	for _, n := range f.block {
 		if n.Contains(nip) {
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// keepaliveLoop sends a KEEPALIVE frame whenever nothing has been written to
+// the connection for interval, so middleboxes that time out idle TCP flows
+// keep the connection open between sparse batches. It is independent of the
+// client's heartbeats, which only reset the read side.
+func (h *ConnectionHandler) keepaliveLoop(ctx context.Context, interval time.Duration) {
+	timer := h.config.clock().NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		}
+
+		idle := h.conn.idleFor()
+		if idle < interval {
+			timer.Reset(interval - idle)
+			continue
+		}
+		if err := h.conn.SendKeepalive(); err != nil {
+			h.logger.Debug("keepalive not sent", "error", err)
+		} else if h.server != nil {
+			h.server.prometheusMetrics.IncrementKeepalivesSent(h.server.instanceID)
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestKeepaliveSentOnlyWhenIdle(t *testing.T) {
+	const interval = 30 * time.Second
+	clock := NewFakeClock(fakeEpoch)
+	config := DefaultConfig()
+	config.Clock = clock
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnection(serverSide, config)
+	defer conn.Close()
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &ConnectionHandler{
+		conn:   conn,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.keepaliveLoop(ctx, interval)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// advance moves the clock once the loop is waiting on its timer
+	advance := func(d time.Duration) {
+		require.Eventually(t, func() bool {
+			clock.mu.Lock()
+			defer clock.mu.Unlock()
+			return len(clock.waiters) > 0
+		}, time.Second, time.Millisecond)
+		clock.Advance(d)
+	}
+	read := func() *protocol.Frame {
+		clientSide.SetReadDeadline(time.Now().Add(time.Second))
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		return frame
+	}
+
+	advance(interval)
+	frame := read()
+	assert.Equal(t, protocol.MessageTypeKeepalive, frame.Type)
+	assert.Empty(t, frame.Payload)
+
+	// Data written halfway through the interval postpones the next keepalive
+	advance(interval / 2)
+	require.NoError(t, conn.SendInfo(pb.InfoCode_INFO_CODE_NOTICE, "still here", nil))
+	assert.Equal(t, protocol.MessageTypeInfo, read().Type)
+	advance(interval / 2)
+	advance(interval/2 - time.Second)
+	clientSide.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := reader.ReadFrame()
+	require.Error(t, err, "nothing is sent while the connection has been idle less than the interval")
+
+	advance(time.Second)
+	assert.Equal(t, protocol.MessageTypeKeepalive, read().Type)
+}
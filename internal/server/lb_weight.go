@@ -0,0 +1,83 @@
+// Package server implements routing hints for load balancers and clients.
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// affinityHeader carries the affinity token on /lb/weight responses, so a
+// load balancer can learn which backend a token names from its probes.
+const affinityHeader = "X-Tick-Storm-Affinity"
+
+// LBWeight is served on /lb/weight for load balancers and client SDKs that
+// pick an instance by load.
+type LBWeight struct {
+	InstanceID        string `json:"instance_id"`
+	AffinityToken     string `json:"affinity_token"`
+	Weight            int    `json:"weight"` // 0-100; 0 takes the instance out of rotation
+	Ready             bool   `json:"ready"`
+	Reason            string `json:"reason,omitempty"`
+	ActiveConnections int32  `json:"active_connections"`
+	MaxConnections    int    `json:"max_connections"`
+}
+
+// AffinityToken returns the opaque token naming this instance. It is sent in
+// the AUTH ACK so a client reconnecting to resume its subscription can ask
+// the load balancer for the same instance. The token is derived from the
+// instance ID, so it is stable across restarts when INSTANCE_ID is set.
+func (s *Server) AffinityToken() string {
+	sum := sha256.Sum256([]byte(s.instanceID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// LBWeight reports the instance's routing weight: the share of
+// MaxConnections still free, scaled to 100. An instance that is not ready
+// or is full weighs 0; one that still has room weighs at least 1.
+func (s *Server) LBWeight() LBWeight {
+	if s.healthChecker == nil {
+		s.healthChecker = NewHealthChecker(s)
+	}
+	ready, reason := s.healthChecker.Readiness()
+	w := LBWeight{
+		InstanceID:        s.instanceID,
+		AffinityToken:     s.AffinityToken(),
+		Ready:             ready,
+		Reason:            reason,
+		ActiveConnections: atomic.LoadInt32(&s.activeConns),
+		MaxConnections:    s.config.MaxConnections,
+	}
+	if !ready {
+		return w
+	}
+
+	w.Weight = 100
+	if w.MaxConnections > 0 {
+		if free := w.MaxConnections - int(w.ActiveConnections); free > 0 {
+			w.Weight = max(1, free*100/w.MaxConnections)
+		} else {
+			w.Weight, w.Reason = 0, "full"
+		}
+	}
+	return w
+}
+
+// handleLBWeight serves the routing weight. Like /ready it answers 503 when
+// the instance should get no new connections, so it doubles as a probe.
+func (s *Server) handleLBWeight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	weight := s.LBWeight()
+	w.Header().Set(contentTypeHeader, "application/json")
+	w.Header().Set(affinityHeader, weight.AffinityToken)
+	if weight.Weight == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(weight)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffinityTokenIsStable(t *testing.T) {
+	a := &Server{instanceID: "pod-a"}
+	b := &Server{instanceID: "pod-b"}
+	assert.Equal(t, a.AffinityToken(), (&Server{instanceID: "pod-a"}).AffinityToken())
+	assert.NotEqual(t, a.AffinityToken(), b.AffinityToken())
+	assert.Len(t, a.AffinityToken(), 16)
+	assert.Equal(t, a.AffinityToken(), a.authAckMetadata()["affinity_token"])
+}
+
+func TestLBWeight(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnections = 200
+	srv := NewServer(config)
+
+	get := func() (int, LBWeight) {
+		rec := httptest.NewRecorder()
+		srv.opsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lb/weight", nil))
+		var w LBWeight
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &w))
+		assert.Equal(t, srv.AffinityToken(), rec.Header().Get(affinityHeader))
+		return rec.Code, w
+	}
+
+	code, w := get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 100, w.Weight)
+	assert.Equal(t, srv.GetInstanceID(), w.InstanceID)
+	assert.Equal(t, srv.AffinityToken(), w.AffinityToken)
+
+	srv.activeConns = 150
+	_, w = get()
+	assert.Equal(t, 25, w.Weight)
+
+	srv.activeConns = 199
+	_, w = get()
+	assert.Equal(t, 1, w.Weight, "an instance with room keeps a share")
+
+	srv.activeConns = 200
+	code, w = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Zero(t, w.Weight)
+	assert.Equal(t, "full", w.Reason)
+
+	srv.activeConns = 0
+	srv.draining.Store(true)
+	code, w = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Zero(t, w.Weight)
+	assert.False(t, w.Ready)
+	assert.Equal(t, "draining", w.Reason)
+}
@@ -0,0 +1,358 @@
+// Package server implements leader election for single-instance ingest.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leader election methods for INGEST_LEADER_ELECTION.
+const (
+	ElectionCluster    = "cluster"    // Lowest instance ID among alive cluster members
+	ElectionKubernetes = "kubernetes" // A coordination.k8s.io/v1 Lease
+)
+
+// LeaderElector decides which instance pulls from the upstream data source.
+type LeaderElector interface {
+	// Run maintains the election until ctx is done.
+	Run(ctx context.Context)
+	// Leader returns the instance ID of the current leader, or "" while
+	// there is none.
+	Leader() string
+}
+
+// clusterElector elects the alive cluster member with the lowest instance
+// ID. Members agree once gossip has converged; until then, and during a
+// partition, two instances may both lead, which duplicates upstream load but
+// loses no ticks.
+type clusterElector struct {
+	cluster *Cluster
+	clock   Clock
+	settle  time.Time // No leader before gossip has had time to find peers
+}
+
+func newClusterElector(cluster *Cluster, clock Clock) *clusterElector {
+	return &clusterElector{cluster: cluster, clock: clock, settle: clock.Now().Add(cluster.peerTimeout)}
+}
+
+func (e *clusterElector) Run(ctx context.Context) {}
+
+func (e *clusterElector) Leader() string {
+	if e.clock.Now().Before(e.settle) {
+		return ""
+	}
+	var leader string
+	for _, m := range e.cluster.Summary().Members {
+		if m.Status == MemberAlive && (leader == "" || m.ID < leader) {
+			leader = m.ID
+		}
+	}
+	return leader
+}
+
+// Service account files mounted into every pod.
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	leaseTimeFormat   = "2006-01-02T15:04:05.000000Z07:00" // metav1.MicroTime
+)
+
+// k8sLease is the subset of a coordination.k8s.io/v1 Lease used for election.
+type k8sLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions"`
+	} `json:"spec"`
+}
+
+// errLeaseConflict is returned when the Lease changed since it was read.
+var errLeaseConflict = errors.New("lease was updated concurrently")
+
+// LeaseElector elects a leader with a Kubernetes Lease, as client-go's
+// leaderelection does: the holder renews the Lease every third of its
+// duration, and others take it over once it has not changed for a full
+// duration by their own clock, so clock skew between nodes does not matter.
+type LeaseElector struct {
+	identity  string
+	namespace string
+	name      string
+	duration  time.Duration
+	apiURL    string
+	tokenFile string
+	client    *http.Client
+	clock     Clock
+	logger    *slog.Logger
+
+	mu         sync.Mutex
+	holder     string
+	held       time.Duration // Lease duration set by the holder
+	observed   string        // Holder and RenewTime of the Lease as last read
+	observedAt time.Time     // When observed last changed, by the local clock
+}
+
+// NewKubernetesLeaseElector creates an elector for the Lease name in
+// namespace, using the pod's service account. An empty namespace is the
+// pod's own.
+func NewKubernetesLeaseElector(identity, namespace, name string, duration time.Duration, clock Clock, logger *slog.Logger) (*LeaseElector, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in Kubernetes: KUBERNETES_SERVICE_HOST is not set")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid cluster CA")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}}
+	apiURL := "https://" + net.JoinHostPort(host, port)
+	return newLeaseElector(identity, namespace, name, duration, apiURL, serviceAccountDir+"/token", client, clock, logger), nil
+}
+
+func newLeaseElector(identity, namespace, name string, duration time.Duration, apiURL, tokenFile string, client *http.Client, clock Clock, logger *slog.Logger) *LeaseElector {
+	if duration <= 0 {
+		duration = 15 * time.Second
+	}
+	client.Timeout = duration / 3
+	return &LeaseElector{
+		identity:  identity,
+		namespace: namespace,
+		name:      name,
+		duration:  duration,
+		apiURL:    apiURL,
+		tokenFile: tokenFile,
+		client:    client,
+		clock:     clock,
+		logger:    logger,
+	}
+}
+
+// Leader returns the Lease holder while its Lease is current. This
+// instance steps down a third of the duration early when it cannot renew,
+// before any other instance may take the Lease over.
+func (e *LeaseElector) Leader() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	valid := e.held
+	if e.holder == e.identity {
+		valid = e.held * 2 / 3
+	}
+	if e.holder == "" || !e.clock.Now().Before(e.observedAt.Add(valid)) {
+		return ""
+	}
+	return e.holder
+}
+
+// Run tries to acquire or renew the Lease every third of its duration, and
+// releases it when ctx is done so another instance can lead at once.
+func (e *LeaseElector) Run(ctx context.Context) {
+	ticker := e.clock.NewTicker(e.duration / 3)
+	defer ticker.Stop()
+	for {
+		if err := e.TryAcquire(ctx); err != nil && ctx.Err() == nil && !errors.Is(err, errLeaseConflict) {
+			e.logger.Warn("ingest lease update failed", "lease", e.namespace+"/"+e.name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), e.duration/3)
+			e.Release(releaseCtx)
+			cancel()
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// TryAcquire reads the Lease and takes or renews it when it is free,
+// expired or already held by this instance.
+func (e *LeaseElector) TryAcquire(ctx context.Context) error {
+	lease, err := e.get(ctx)
+	now := e.clock.Now()
+	if errors.Is(err, os.ErrNotExist) {
+		lease = &k8sLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name, lease.Metadata.Namespace = e.name, e.namespace
+		e.claim(lease, now)
+		if err := e.write(ctx, http.MethodPost, lease); err != nil {
+			return err
+		}
+		e.observe(lease, now)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	e.observe(lease, now)
+	if holder := e.Leader(); holder != "" && holder != e.identity {
+		return nil // Held by another instance
+	}
+	e.claim(lease, now)
+	if err := e.write(ctx, http.MethodPut, lease); err != nil {
+		return err
+	}
+	e.observe(lease, now)
+	return nil
+}
+
+// Release gives the Lease up if this instance holds it.
+func (e *LeaseElector) Release(ctx context.Context) {
+	if e.Leader() != e.identity {
+		return
+	}
+	lease, err := e.get(ctx)
+	if err != nil || lease.Spec.HolderIdentity != e.identity {
+		return
+	}
+	lease.Spec.HolderIdentity = ""
+	lease.Spec.LeaseDurationSeconds = 1
+	if err := e.write(ctx, http.MethodPut, lease); err != nil {
+		e.logger.Warn("failed to release ingest lease", "error", err)
+		return
+	}
+	e.mu.Lock()
+	e.holder = ""
+	e.mu.Unlock()
+}
+
+// claim makes this instance the holder of lease as of now.
+func (e *LeaseElector) claim(lease *k8sLease, now time.Time) {
+	ts := now.UTC().Format(leaseTimeFormat)
+	if lease.Spec.HolderIdentity != e.identity {
+		lease.Spec.HolderIdentity = e.identity
+		lease.Spec.AcquireTime = ts
+		if lease.Metadata.ResourceVersion != "" {
+			lease.Spec.LeaseTransitions++
+		}
+	}
+	lease.Spec.RenewTime = ts
+	lease.Spec.LeaseDurationSeconds = int(e.duration.Seconds())
+}
+
+// observe records lease as read or written at now.
+func (e *LeaseElector) observe(lease *k8sLease, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	record := lease.Spec.HolderIdentity + "@" + lease.Spec.RenewTime
+	if record != e.observed {
+		e.observed, e.observedAt = record, now
+	}
+	e.holder = lease.Spec.HolderIdentity
+	e.held = time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second
+	if e.held <= 0 {
+		e.held = e.duration
+	}
+}
+
+func (e *LeaseElector) leaseURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", e.apiURL, e.namespace)
+}
+
+// get reads the Lease, returning os.ErrNotExist when there is none.
+func (e *LeaseElector) get(ctx context.Context) (*k8sLease, error) {
+	resp, err := e.do(ctx, http.MethodGet, e.leaseURL()+"/"+e.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, os.ErrNotExist
+	default:
+		return nil, fmt.Errorf("get lease: unexpected status %d", resp.StatusCode)
+	}
+	var lease k8sLease
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("get lease: %w", err)
+	}
+	return &lease, nil
+}
+
+// write creates (POST) or replaces (PUT) the Lease. A PUT carries the
+// resourceVersion read, so it fails with errLeaseConflict if another
+// instance wrote the Lease in between.
+func (e *LeaseElector) write(ctx context.Context, method string, lease *k8sLease) error {
+	url := e.leaseURL()
+	if method == http.MethodPut {
+		url += "/" + e.name
+	}
+	body, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var written k8sLease
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&written); err == nil {
+			lease.Metadata.ResourceVersion = written.Metadata.ResourceVersion
+		}
+		return nil
+	case http.StatusConflict:
+		return errLeaseConflict
+	}
+	return fmt.Errorf("%s lease: unexpected status %d", strings.ToLower(method), resp.StatusCode)
+}
+
+func (e *LeaseElector) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// Projected service account tokens rotate, so read the token every time
+	token, err := os.ReadFile(e.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set(contentTypeHeader, "application/json")
+	return e.client.Do(req)
+}
+
+// newLeaderElector creates the elector configured for leader-elected ingest.
+func (s *Server) newLeaderElector() (LeaderElector, error) {
+	r := s.config.Replication
+	if r.Election == ElectionCluster {
+		return newClusterElector(s.cluster, s.config.clock()), nil
+	}
+	name := r.LeaseName
+	if name == "" {
+		name = "tick-storm-ingest"
+	}
+	return NewKubernetesLeaseElector(s.instanceID, r.LeaseNamespace, name, r.LeaseDuration, s.config.clock(), s.logger)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLeaseAPI serves one Lease the way the Kubernetes API server does,
+// refusing writes with a stale resourceVersion.
+type fakeLeaseAPI struct {
+	mu      sync.Mutex
+	lease   *k8sLease
+	version int
+}
+
+func (f *fakeLeaseAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer sa-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body k8sLease
+	if r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case r.Method == http.MethodGet && f.lease == nil:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case r.Method == http.MethodPost && f.lease != nil,
+		r.Method == http.MethodPut && (f.lease == nil || body.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion):
+		w.WriteHeader(http.StatusConflict)
+		return
+	case r.Method != http.MethodGet:
+		f.version++
+		body.Metadata.ResourceVersion = strconv.Itoa(f.version)
+		f.lease = &body
+	}
+	writeJSON(w, http.StatusOK, f.lease)
+}
+
+func (f *fakeLeaseAPI) holder() (string, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lease.Spec.HolderIdentity, f.lease.Spec.LeaseTransitions
+}
+
+func newTestLeaseElectors(t *testing.T, clock Clock, ids ...string) (*fakeLeaseAPI, []*LeaseElector) {
+	t.Helper()
+	api := &fakeLeaseAPI{}
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	token := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(token, []byte("sa-token\n"), 0o600))
+
+	electors := make([]*LeaseElector, len(ids))
+	for i, id := range ids {
+		electors[i] = newLeaseElector(id, "markets", "tick-storm-ingest", 15*time.Second, ts.URL, token,
+			&http.Client{}, clock, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	return api, electors
+}
+
+func TestLeaseElectorTakesOverExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	api, electors := newTestLeaseElectors(t, clock, "a", "b")
+	a, b := electors[0], electors[1]
+
+	require.NoError(t, a.TryAcquire(ctx))
+	require.NoError(t, b.TryAcquire(ctx))
+	assert.Equal(t, "a", a.Leader())
+	assert.Equal(t, "a", b.Leader(), "b follows while a's lease is current")
+
+	// a renews every third of the duration
+	clock.Advance(5 * time.Second)
+	require.NoError(t, a.TryAcquire(ctx))
+	require.NoError(t, b.TryAcquire(ctx))
+
+	// a stops renewing: it steps down after two thirds of the duration, and b
+	// takes over a full duration after it saw the last renewal
+	clock.Advance(10 * time.Second)
+	assert.Empty(t, a.Leader())
+	require.NoError(t, b.TryAcquire(ctx))
+	assert.Equal(t, "a", b.Leader())
+	clock.Advance(5 * time.Second)
+	require.NoError(t, b.TryAcquire(ctx))
+	assert.Equal(t, "b", b.Leader())
+	holder, transitions := api.holder()
+	assert.Equal(t, "b", holder)
+	assert.Equal(t, 1, transitions)
+
+	require.NoError(t, a.TryAcquire(ctx))
+	assert.Equal(t, "b", a.Leader())
+}
+
+func TestLeaseElectorReleases(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	api, electors := newTestLeaseElectors(t, clock, "a", "b")
+	a, b := electors[0], electors[1]
+
+	require.NoError(t, a.TryAcquire(ctx))
+	a.Release(ctx)
+	assert.Empty(t, a.Leader())
+	require.NoError(t, b.TryAcquire(ctx))
+	assert.Equal(t, "b", b.Leader(), "a released lease is taken at once")
+	holder, _ := api.holder()
+	assert.Equal(t, "b", holder)
+}
+
+func TestLeaseElectorConflict(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	_, electors := newTestLeaseElectors(t, clock, "a", "b")
+	a, b := electors[0], electors[1]
+
+	require.NoError(t, a.TryAcquire(ctx))
+	stale, err := b.get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, a.TryAcquire(ctx))
+
+	b.claim(stale, clock.Now())
+	assert.ErrorIs(t, b.write(ctx, http.MethodPut, stale), errLeaseConflict)
+}
+
+func TestClusterElectorPicksLowestAliveID(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	nodes := startNodes(t, 3, clock, "")
+	electors := make([]*clusterElector, len(nodes))
+	for i, node := range nodes {
+		electors[i] = newClusterElector(node.cluster, clock)
+	}
+	gossipAll(t, nodes, 3)
+	assert.Empty(t, electors[2].Leader(), "no leader before membership settles")
+
+	clock.Advance(10 * time.Second)
+	gossipAll(t, nodes, 1)
+	for _, e := range electors {
+		assert.Equal(t, "a", e.Leader())
+	}
+
+	// a goes away; once it is suspect the next lowest leads
+	nodes[0].server.Close()
+	clock.Advance(10 * time.Second)
+	for _, node := range nodes[1:] {
+		err := node.cluster.Gossip(context.Background())
+		if err != nil {
+			assert.True(t, strings.Contains(err.Error(), nodes[0].addr), err.Error())
+		}
+	}
+	assert.Equal(t, "b", electors[1].Leader())
+	assert.Equal(t, "b", electors[2].Leader())
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrSocketOptionUnsupported is returned when socket tuning is requested
+	// on a platform that does not support it.
+	ErrSocketOptionUnsupported = errors.New("socket option not supported on this platform")
+)
+
+// ListenerFactory creates the server's listening socket. Custom factories can
+// hand out pre-bound or inherited sockets, or several SO_REUSEPORT listeners
+// for multi-accept-loop setups.
+type ListenerFactory func(network, address string) (net.Listener, error)
+
+// SocketOptions holds socket-level tuning applied to listeners.
+type SocketOptions struct {
+	ReusePort      bool          // SO_REUSEPORT: let several sockets bind the same address
+	FastOpenQueue  int           // TCP_FASTOPEN queue length (0 disables)
+	Backlog        int           // Accept queue length (0 keeps the system default)
+	TCPUserTimeout time.Duration // TCP_USER_TIMEOUT for accepted connections (0 disables)
+}
+
+// enabled reports whether any option differs from the system defaults.
+func (o SocketOptions) enabled() bool {
+	return o.ReusePort || o.FastOpenQueue > 0 || o.Backlog > 0 || o.TCPUserTimeout > 0
+}
+
+// socketOptions returns the socket tuning from configuration.
+func (c *Config) socketOptions() SocketOptions {
+	return SocketOptions{
+		ReusePort:      c.ReusePort,
+		FastOpenQueue:  c.TCPFastOpenQueue,
+		Backlog:        c.ListenBacklog,
+		TCPUserTimeout: c.TCPUserTimeout,
+	}
+}
+
+// NewListenerFactory returns a factory that applies opts to every listener.
+// Options are set before bind, so SO_REUSEPORT takes effect for the first
+// socket on an address too.
+func NewListenerFactory(opts SocketOptions) ListenerFactory {
+	return func(network, address string) (net.Listener, error) {
+		if !opts.enabled() {
+			return net.Listen(network, address)
+		}
+
+		lc := net.ListenConfig{
+			Control: func(_, _ string, c syscall.RawConn) error {
+				var sockErr error
+				if err := c.Control(func(fd uintptr) {
+					sockErr = applySocketOptions(fd, opts)
+				}); err != nil {
+					return err
+				}
+				return sockErr
+			},
+		}
+		l, err := lc.Listen(context.Background(), network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Backlog > 0 {
+			if err := setListenBacklog(l, opts.Backlog); err != nil {
+				l.Close()
+				return nil, fmt.Errorf("failed to set listen backlog: %w", err)
+			}
+		}
+		return l, nil
+	}
+}
+
+// listenerFactory returns the configured factory, defaulting to one built
+// from the socket options.
+func (c *Config) listenerFactory() ListenerFactory {
+	if c.ListenerFactory != nil {
+		return c.ListenerFactory
+	}
+	return NewListenerFactory(c.socketOptions())
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Socket option numbers the syscall package does not export on every architecture.
+const (
+	sockoptReusePort      = 0xf
+	sockoptTCPUserTimeout = 0x12
+	sockoptTCPFastOpen    = 0x17
+)
+
+// applySocketOptions sets opts on a socket before it is bound.
+func applySocketOptions(fd uintptr, opts SocketOptions) error {
+	s := int(fd)
+	if opts.ReusePort {
+		if err := syscall.SetsockoptInt(s, syscall.SOL_SOCKET, sockoptReusePort, 1); err != nil {
+			return fmt.Errorf("SO_REUSEPORT: %w", err)
+		}
+	}
+	if opts.FastOpenQueue > 0 {
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sockoptTCPFastOpen, opts.FastOpenQueue); err != nil {
+			return fmt.Errorf("TCP_FASTOPEN: %w", err)
+		}
+	}
+	if opts.TCPUserTimeout > 0 {
+		// Accepted sockets inherit the listener's user timeout
+		ms := int(opts.TCPUserTimeout.Milliseconds())
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_TCP, sockoptTCPUserTimeout, ms); err != nil {
+			return fmt.Errorf("TCP_USER_TIMEOUT: %w", err)
+		}
+	}
+	return nil
+}
+
+// setListenBacklog resizes the accept queue. Linux allows calling listen()
+// again on a listening socket to change its backlog.
+func setListenBacklog(l net.Listener, backlog int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return ErrSocketOptionUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var listenErr error
+	if err := raw.Control(func(fd uintptr) {
+		listenErr = syscall.Listen(int(fd), backlog)
+	}); err != nil {
+		return err
+	}
+	return listenErr
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// socketOption reads an integer option from a socket.
+func socketOption(t *testing.T, c syscall.Conn, level, opt int) int {
+	t.Helper()
+	raw, err := c.SyscallConn()
+	require.NoError(t, err)
+	var value int
+	var getErr error
+	require.NoError(t, raw.Control(func(fd uintptr) {
+		value, getErr = syscall.GetsockoptInt(int(fd), level, opt)
+	}))
+	require.NoError(t, getErr)
+	return value
+}
+
+func TestListenerFactoryReusePort(t *testing.T) {
+	factory := NewListenerFactory(SocketOptions{ReusePort: true, Backlog: 128})
+
+	first, err := factory("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer first.Close()
+
+	// A second accept loop can bind the same address
+	second, err := factory("tcp", first.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+
+	assert.Equal(t, 1, socketOption(t, first.(syscall.Conn), syscall.SOL_SOCKET, sockoptReusePort))
+
+	// Without SO_REUSEPORT the address is taken
+	_, err = NewListenerFactory(SocketOptions{})("tcp", first.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestListenerFactoryTCPOptions(t *testing.T) {
+	factory := NewListenerFactory(SocketOptions{
+		FastOpenQueue:  64,
+		TCPUserTimeout: 7 * time.Second,
+	})
+	l, err := factory("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	assert.Equal(t, 7000, socketOption(t, l.(syscall.Conn), syscall.IPPROTO_TCP, sockoptTCPUserTimeout))
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			time.Sleep(100 * time.Millisecond)
+			c.Close()
+		}
+	}()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, 7000, socketOption(t, conn.(syscall.Conn), syscall.IPPROTO_TCP, sockoptTCPUserTimeout),
+		"accepted connections inherit the user timeout")
+}
+
+func TestConfigListenerFactoryOverride(t *testing.T) {
+	var called string
+	config := DefaultConfig()
+	config.ListenerFactory = func(network, address string) (net.Listener, error) {
+		called = address
+		return net.Listen(network, "127.0.0.1:0")
+	}
+
+	l, err := config.listenerFactory()("tcp", ":9999")
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, ":9999", called)
+}
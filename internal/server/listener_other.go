@@ -0,0 +1,14 @@
+//go:build !linux
+
+package server
+
+import "net"
+
+// applySocketOptions rejects socket tuning outside Linux.
+func applySocketOptions(_ uintptr, _ SocketOptions) error {
+	return ErrSocketOptionUnsupported
+}
+
+func setListenBacklog(_ net.Listener, _ int) error {
+	return ErrSocketOptionUnsupported
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultListenerName identifies the listener bound to Config.ListenAddr.
+const DefaultListenerName = "default"
+
+// ListenerConfig describes an additional listener served alongside
+// Config.ListenAddr, e.g. plaintext for internal clients and TLS for external.
+type ListenerConfig struct {
+	Name           string
+	Addr           string
+	TLS            bool     // Serve TLS using the server's certificate settings
+	AllowCIDRs     []string // Applied in addition to the server-wide lists
+	BlockCIDRs     []string
+	MaxConnections int // Per-listener ceiling below MaxConnections (0 disables)
+}
+
+// parseListenerSpecs parses LISTENERS entries separated by ';', each of the form
+// "name=addr[,tls][,max_conns=N][,allow=CIDR|CIDR][,block=CIDR|CIDR]".
+func parseListenerSpecs(spec string) ([]ListenerConfig, error) {
+	var listeners []ListenerConfig
+	for _, raw := range strings.Split(spec, ";") {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ",")
+		name, addr, ok := strings.Cut(strings.TrimSpace(parts[0]), "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(addr) == "" {
+			return nil, fmt.Errorf("invalid listener %q: expected name=addr", entry)
+		}
+		lc := ListenerConfig{Name: strings.TrimSpace(name), Addr: strings.TrimSpace(addr)}
+
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "tls":
+				lc.TLS = true
+			case "max_conns":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid max_conns in listener %q", lc.Name)
+				}
+				lc.MaxConnections = n
+			case "allow":
+				lc.AllowCIDRs = strings.Split(value, "|")
+			case "block":
+				lc.BlockCIDRs = strings.Split(value, "|")
+			default:
+				return nil, fmt.Errorf("unknown option %q in listener %q", key, lc.Name)
+			}
+		}
+		listeners = append(listeners, lc)
+	}
+	return listeners, nil
+}
+
+// serverListener is a bound listener with its own admission policy and metrics.
+type serverListener struct {
+	net.Listener
+	name     string
+	tls      bool
+	ipFilter *IPFilter // Nil when the listener has no lists of its own
+	maxConns int
+
+	active   int32
+	accepted uint64
+	rejected uint64
+}
+
+// newServerListener builds the listener-level policy for lc around l.
+func newServerListener(l net.Listener, lc ListenerConfig) (*serverListener, error) {
+	sl := &serverListener{Listener: l, name: lc.Name, tls: lc.TLS, maxConns: lc.MaxConnections}
+	if len(lc.AllowCIDRs) > 0 || len(lc.BlockCIDRs) > 0 {
+		ipf, err := NewIPFilterFromStrings(lc.AllowCIDRs, lc.BlockCIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP filter for listener %q: %w", lc.Name, err)
+		}
+		sl.ipFilter = ipf
+	}
+	return sl, nil
+}
+
+// admit applies the listener's own IP filter and connection ceiling.
+func (l *serverListener) admit(conn net.Conn) bool {
+	if l == nil {
+		return true
+	}
+	if l.ipFilter != nil && !l.ipFilter.Allow(remoteIP(conn)) {
+		return false
+	}
+	return l.maxConns <= 0 || atomic.LoadInt32(&l.active) < int32(l.maxConns)
+}
+
+// GetStats returns per-listener statistics.
+func (l *serverListener) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"addr":               l.Addr().String(),
+		"tls":                l.tls,
+		"max_connections":    l.maxConns,
+		"active_connections": atomic.LoadInt32(&l.active),
+		"accepted_total":     atomic.LoadUint64(&l.accepted),
+		"rejected_total":     atomic.LoadUint64(&l.rejected),
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListenerSpecs(t *testing.T) {
+	listeners, err := parseListenerSpecs(
+		"internal=:9000,allow=10.0.0.0/8|192.168.0.0/16; external=0.0.0.0:8443,tls,max_conns=500,block=203.0.113.0/24")
+	require.NoError(t, err)
+	require.Len(t, listeners, 2)
+
+	assert.Equal(t, ListenerConfig{
+		Name:       "internal",
+		Addr:       ":9000",
+		AllowCIDRs: []string{"10.0.0.0/8", "192.168.0.0/16"},
+	}, listeners[0])
+	assert.Equal(t, ListenerConfig{
+		Name:           "external",
+		Addr:           "0.0.0.0:8443",
+		TLS:            true,
+		MaxConnections: 500,
+		BlockCIDRs:     []string{"203.0.113.0/24"},
+	}, listeners[1])
+
+	for _, bad := range []string{":9000", "internal=", "x=:1,max_conns=-1", "x=:1,ws"} {
+		_, err := parseListenerSpecs(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestServerListenerAdmit(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer base.Close()
+
+	l, err := newServerListener(base, ListenerConfig{Name: "internal", BlockCIDRs: []string{"203.0.113.0/24"}, MaxConnections: 1})
+	require.NoError(t, err)
+
+	assert.False(t, l.admit(newAddrConn("203.0.113.5")))
+	assert.True(t, l.admit(newAddrConn("198.51.100.1")))
+
+	l.active = 1
+	assert.False(t, l.admit(newAddrConn("198.51.100.1")), "listener ceiling reached")
+
+	_, err = newServerListener(base, ListenerConfig{Name: "bad", AllowCIDRs: []string{"nope"}})
+	assert.Error(t, err)
+}
+
+func TestServerMultipleListeners(t *testing.T) {
+	certFile, keyFile := generateTestCertificate(t)
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = &TLSConfig{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		MinVersion: tls.VersionTLS13,
+		MaxVersion: tls.VersionTLS13,
+	}
+	config.Listeners = []ListenerConfig{
+		{Name: "external", Addr: "127.0.0.1:0", TLS: true},
+		{Name: "blocked", Addr: "127.0.0.1:0", BlockCIDRs: []string{"127.0.0.0/8"}},
+	}
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+	require.Len(t, server.listeners, 3)
+
+	// Dials from one IP are spaced out to stay under the DDoS burst limit
+	addrOf := func(name string) string {
+		time.Sleep(150 * time.Millisecond)
+		for _, l := range server.listeners {
+			if l.name == name {
+				return l.Addr().String()
+			}
+		}
+		t.Fatalf("listener %q not found", name)
+		return ""
+	}
+
+	listenerStat := func(name, key string) interface{} {
+		stats := server.GetStats()["listeners"].(map[string]interface{})
+		return stats[name].(map[string]interface{})[key]
+	}
+
+	// Plaintext default listener; closed once counted so the handler frees
+	// its pool worker for the next connection
+	plain, err := net.Dial("tcp", addrOf(DefaultListenerName))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return listenerStat(DefaultListenerName, "accepted_total") == uint64(1)
+	}, 2*time.Second, 20*time.Millisecond)
+	plain.Close()
+
+	// TLS on the extra listener while the default stays plaintext
+	secure, err := tls.Dial("tcp", addrOf("external"), &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13})
+	require.NoError(t, err)
+	defer secure.Close()
+	assert.Eventually(t, func() bool {
+		return listenerStat("external", "active_connections") == int32(1)
+	}, 2*time.Second, 20*time.Millisecond)
+
+	// The listener's own blocklist rejects loopback clients
+	blocked, err := net.Dial("tcp", addrOf("blocked"))
+	require.NoError(t, err)
+	defer blocked.Close()
+	blocked.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = blocked.Read(make([]byte, 1))
+	assert.Error(t, err, "blocked listener closes the connection")
+	assert.Equal(t, uint64(1), listenerStat("blocked", "rejected_total"))
+	assert.Equal(t, uint64(0), listenerStat("blocked", "accepted_total"))
+}
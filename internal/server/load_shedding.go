@@ -0,0 +1,66 @@
+package server
+
+import (
+	"sort"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// shedRank orders connections for shedding; higher ranks go first.
+// Connections without a class have not authenticated yet.
+func shedRank(class PriorityClass) int {
+	switch class {
+	case PriorityGold:
+		return 0
+	case PrioritySilver:
+		return 1
+	case PriorityBronze:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// shedOrder sorts conns so the lowest priority class comes first and,
+// within a class, the connection idle the longest.
+func shedOrder(conns []*Connection) {
+	ranks := make(map[*Connection]int, len(conns))
+	for _, conn := range conns {
+		ranks[conn] = shedRank(conn.Priority())
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if ranks[conns[i]] != ranks[conns[j]] {
+			return ranks[conns[i]] > ranks[conns[j]]
+		}
+		return conns[i].LastActivity().Before(conns[j].LastActivity())
+	})
+}
+
+// shedConnections closes up to n connections with a SERVER_BUSY error to
+// relieve pressure on resource, in shedOrder. It returns how many
+// connections were closed.
+func (s *Server) shedConnections(n int, resource string) int {
+	order := s.connections.snapshot()
+
+	shedOrder(order)
+	if len(order) > n {
+		order = order[:n]
+	}
+
+	for _, conn := range order {
+		class := conn.Priority()
+		conn.Logger().Warn("shedding connection",
+			"priority", string(class),
+			"resource", resource,
+		)
+		if s.prometheusMetrics != nil {
+			s.prometheusMetrics.IncrementConnectionsShed(s.instanceID, resource, class)
+		}
+		// Writing the error may block on a slow client; keep it off the monitoring loop
+		go func() {
+			_ = conn.SendErrorCodeSync(pb.ErrorCode_ERROR_CODE_SERVER_BUSY, retryAfterHint(s.config.BusyRetryAfter))
+			conn.Close()
+		}()
+	}
+	return len(order)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestShedConnectionsPicksLowestPriorityIdleFirst(t *testing.T) {
+	config := DefaultConfig()
+	sched, err := NewQoSScheduler(config, nil)
+	require.NoError(t, err)
+	srv := &Server{
+		config: config,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	clients := make(map[string]net.Conn)
+	add := func(id string, class PriorityClass, idle time.Duration) {
+		serverSide, clientSide := net.Pipe()
+		conn := NewConnection(serverSide, config)
+		conn.id = id
+		conn.lastActivity = time.Now().Add(-idle)
+		conn.SetPriority(sched, class)
+		srv.connections.add(conn)
+		clients[id] = clientSide
+		t.Cleanup(func() {
+			clientSide.Close()
+			conn.Close()
+		})
+	}
+	add("gold-idle", PriorityGold, time.Hour)
+	add("bronze-busy", PriorityBronze, time.Second)
+	add("bronze-idle", PriorityBronze, time.Minute)
+	add("silver-idle", PrioritySilver, time.Hour)
+
+	assert.Equal(t, 2, srv.shedConnections(2, "memory"))
+
+	for _, id := range []string{"bronze-idle", "bronze-busy"} {
+		frame, err := protocol.NewFrameReader(clients[id], config.MaxMessageSize).ReadFrame()
+		require.NoError(t, err, id)
+		var resp pb.ErrorResponse
+		require.NoError(t, protocol.UnmarshalMessage(frame, &resp))
+		assert.Equal(t, pb.ErrorCode_ERROR_CODE_SERVER_BUSY, resp.Code, id)
+		assert.GreaterOrEqual(t, resp.RetryAfterMs, config.BusyRetryAfter.Milliseconds(), id)
+		conn, _ := srv.connections.get(id)
+		assert.Eventually(t, conn.closed.Load, time.Second, 10*time.Millisecond, id)
+	}
+	for _, id := range []string{"silver-idle", "gold-idle"} {
+		conn, _ := srv.connections.get(id)
+		assert.False(t, conn.closed.Load(), id)
+	}
+}
+
+func TestBreachHandlerShedsOnlyWhileBreached(t *testing.T) {
+	handler := NewResourceBreachHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	var calls []string
+	handler.SetLoadShedder(5, func(n int, resource string) int {
+		assert.Equal(t, 5, n)
+		calls = append(calls, resource)
+		return n
+	})
+
+	handler.shedLoad()
+	assert.Empty(t, calls)
+
+	handler.connectionBreach.Store(true)
+	handler.shedLoad()
+	assert.Empty(t, calls, "connection-count breaches only reject new connections")
+
+	handler.fdBreach.Store(true)
+	handler.shedLoad()
+	handler.memoryBreach.Store(true)
+	handler.shedLoad()
+	assert.Equal(t, []string{"file_descriptors", "memory"}, calls)
+	assert.Equal(t, uint64(10), handler.GetBreachStats()["connections_shed"])
+}
+
+func TestRetryAfterHintAddsJitter(t *testing.T) {
+	assert.Zero(t, retryAfterHint(0))
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 50; i++ {
+		d := retryAfterHint(time.Second)
+		assert.GreaterOrEqual(t, d, time.Second)
+		assert.Less(t, d, 1500*time.Millisecond)
+		seen[d] = true
+	}
+	assert.Greater(t, len(seen), 1, "hints are jittered")
+}
+
+func TestRejectConnectionSendsServerBusy(t *testing.T) {
+	handler := NewResourceBreachHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	handler.memoryBreach.Store(true)
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+
+	go handler.RejectConnection(serverSide, 7*time.Second)
+	frame, err := protocol.NewFrameReader(clientSide, protocol.DefaultMaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	var resp pb.ErrorResponse
+	require.NoError(t, protocol.UnmarshalMessage(frame, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_SERVER_BUSY, resp.Code)
+	assert.Equal(t, int64(7000), resp.RetryAfterMs)
+	assert.Equal(t, "server memory limit exceeded", resp.Details)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricType is the Prometheus type of a registered metric.
+type MetricType string
+
+const (
+	MetricTypeCounter   MetricType = "counter"
+	MetricTypeGauge     MetricType = "gauge"
+	MetricTypeHistogram MetricType = "histogram"
+)
+
+// MetricInfo describes a metric registered by PrometheusMetrics. It is the
+// source for generated dashboards and alert rules.
+type MetricInfo struct {
+	Name   string
+	Help   string
+	Type   MetricType
+	Labels []string
+}
+
+// metricCatalog records every metric as it is constructed.
+type metricCatalog struct {
+	mu      sync.Mutex
+	metrics map[string]MetricInfo
+}
+
+func (mc *metricCatalog) add(info MetricInfo) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.metrics == nil {
+		mc.metrics = make(map[string]MetricInfo)
+	}
+	mc.metrics[info.Name] = info
+}
+
+// Catalog returns the metrics registered so far, sorted by name.
+func (pm *PrometheusMetrics) Catalog() []MetricInfo {
+	pm.catalog.mu.Lock()
+	defer pm.catalog.mu.Unlock()
+	out := make([]MetricInfo, 0, len(pm.catalog.metrics))
+	for _, info := range pm.catalog.metrics {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func constLabelNames(labels prometheus.Labels) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (pm *PrometheusMetrics) newCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeCounter})
+	return prometheus.NewCounter(opts)
+}
+
+func (pm *PrometheusMetrics) newCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeCounter, Labels: labels})
+	return prometheus.NewCounterVec(opts, labels)
+}
+
+func (pm *PrometheusMetrics) newCounterFunc(opts prometheus.CounterOpts, fn func() float64) prometheus.CounterFunc {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeCounter, Labels: constLabelNames(opts.ConstLabels)})
+	return prometheus.NewCounterFunc(opts, fn)
+}
+
+func (pm *PrometheusMetrics) newGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeGauge})
+	return prometheus.NewGauge(opts)
+}
+
+func (pm *PrometheusMetrics) newGaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeGauge, Labels: labels})
+	return prometheus.NewGaugeVec(opts, labels)
+}
+
+func (pm *PrometheusMetrics) newGaugeFunc(opts prometheus.GaugeOpts, fn func() float64) prometheus.GaugeFunc {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeGauge, Labels: constLabelNames(opts.ConstLabels)})
+	return prometheus.NewGaugeFunc(opts, fn)
+}
+
+func (pm *PrometheusMetrics) newHistogram(opts prometheus.HistogramOpts) prometheus.Histogram {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeHistogram})
+	return prometheus.NewHistogram(opts)
+}
+
+func (pm *PrometheusMetrics) newHistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	pm.catalog.add(MetricInfo{Name: opts.Name, Help: opts.Help, Type: MetricTypeHistogram, Labels: labels})
+	return prometheus.NewHistogramVec(opts, labels)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	// ErrUnknownExporter indicates an unsupported METRICS_EXPORTER value.
+	ErrUnknownExporter = errors.New("unknown metrics exporter")
+
+	// ErrExporterConfig indicates an exporter is missing required settings.
+	ErrExporterConfig = errors.New("incomplete metrics exporter configuration")
+)
+
+// MetricsExporter pushes metrics to a backend that does not scrape the
+// Prometheus endpoint. It receives the same families the endpoint serves, so
+// both views report identical values.
+type MetricsExporter interface {
+	Name() string
+	Export(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+// NewMetricsExporterFromEnv builds the exporter named by name ("statsd" or
+// "otlp") from its environment variables.
+func NewMetricsExporterFromEnv(name string) (MetricsExporter, error) {
+	switch name {
+	case "statsd":
+		return NewStatsdExporterFromEnv()
+	case "otlp":
+		return NewOTLPExporterFromEnv()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, name)
+	}
+}
+
+// StartExporter pushes the registry to exp every interval until ctx is done,
+// with a final push on the way out so short-lived processes still report.
+func (pm *PrometheusMetrics) StartExporter(ctx context.Context, exp MetricsExporter, interval time.Duration, logger *slog.Logger) {
+	export := func(ctx context.Context) {
+		families, err := pm.registry.Gather()
+		if err != nil {
+			// Gather returns whatever it could collect alongside the error
+			logger.Warn("metrics gather incomplete", "exporter", exp.Name(), "error", err)
+		}
+		if err := exp.Export(ctx, families); err != nil {
+			logger.Warn("metrics export failed", "exporter", exp.Name(), "error", err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), interval)
+				export(flushCtx)
+				cancel()
+				return
+			case <-ticker.C:
+				export(ctx)
+			}
+		}
+	}()
+}
+
+// exporterInstance identifies this process to push backends.
+func exporterInstance() string {
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "unknown"
+}
+
+// seriesLabels returns a metric's label pairs as a map.
+func seriesLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	return labels
+}
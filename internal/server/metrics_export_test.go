@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gatherTestMetrics(t *testing.T, pm *PrometheusMetrics) []*dto.MetricFamily {
+	t.Helper()
+	families, err := pm.registry.Gather()
+	require.NoError(t, err)
+	return families
+}
+
+func TestNewMetricsExporterFromEnv(t *testing.T) {
+	_, err := NewMetricsExporterFromEnv("graphite")
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+
+	t.Setenv("STATSD_ADDR", "statsd.local:9125")
+	exp, err := NewMetricsExporterFromEnv("statsd")
+	require.NoError(t, err)
+	assert.Equal(t, "statsd.local:9125", exp.(*StatsdExporter).Addr)
+
+	_, err = NewMetricsExporterFromEnv("otlp")
+	assert.ErrorIs(t, err, ErrExporterConfig, "an OTLP endpoint is required")
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20abc, X-Tenant=t1")
+	exp, err = NewMetricsExporterFromEnv("otlp")
+	require.NoError(t, err)
+	otlp := exp.(*OTLPExporter)
+	assert.Equal(t, "http://collector:4318/v1/metrics", otlp.Endpoint)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer abc", "X-Tenant": "t1"}, otlp.Headers)
+}
+
+func TestStatsdExporterSendsCounterDeltas(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	read := func() []string {
+		buf := make([]byte, 64*1024)
+		var lines []string
+		pc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		for {
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+		}
+		sort.Strings(lines)
+		return lines
+	}
+
+	pm := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	exp := &StatsdExporter{Addr: pc.LocalAddr().String(), Prefix: "ts."}
+
+	pm.IncrementAuthFailure("i1", "invalid_credentials")
+	pm.IncrementAuthFailure("i1", "invalid_credentials")
+	pm.UpdateGoroutineCount(42)
+	require.NoError(t, exp.Export(context.Background(), gatherTestMetrics(t, pm)))
+	lines := read()
+	assert.Contains(t, lines, "ts.tick_storm_auth_failures_total:2|c|#instance_id:i1,reason:invalid_credentials")
+	assert.Contains(t, lines, "ts.tick_storm_goroutines:42|g")
+
+	pm.IncrementAuthFailure("i1", "invalid_credentials")
+	require.NoError(t, exp.Export(context.Background(), gatherTestMetrics(t, pm)))
+	lines = read()
+	assert.Contains(t, lines, "ts.tick_storm_auth_failures_total:1|c|#instance_id:i1,reason:invalid_credentials",
+		"counters are sent as the increase since the last push")
+}
+
+func TestOTLPExporterPostsMetrics(t *testing.T) {
+	var got otlpRequest
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "application/json", r.Header.Get(contentTypeHeader))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer collector.Close()
+
+	pm := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	pm.IncrementAuthSuccess("i1")
+	pm.RecordWriteLatency(2 * time.Millisecond)
+	pm.RecordWriteLatency(time.Minute)
+
+	exp := &OTLPExporter{
+		Endpoint:    collector.URL,
+		Headers:     map[string]string{"Authorization": "Bearer abc"},
+		ServiceName: "tick-storm",
+		InstanceID:  "host-1",
+	}
+	require.NoError(t, exp.Export(context.Background(), gatherTestMetrics(t, pm)))
+	assert.Equal(t, "Bearer abc", auth)
+
+	require.Len(t, got.ResourceMetrics, 1)
+	assert.Contains(t, got.ResourceMetrics[0].Resource.Attributes,
+		otlpAttribute{Key: "service.instance.id", Value: otlpValue{StringValue: "host-1"}})
+	metrics := make(map[string]otlpMetric)
+	for _, m := range got.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	success := metrics["tick_storm_auth_success_total"].Sum
+	require.NotNil(t, success)
+	assert.True(t, success.IsMonotonic)
+	require.Len(t, success.DataPoints, 1)
+	assert.Equal(t, 1.0, success.DataPoints[0].AsDouble)
+
+	latency := metrics["tick_storm_write_latency_seconds"].Histogram
+	require.NotNil(t, latency)
+	point := latency.DataPoints[0]
+	assert.Equal(t, "2", point.Count)
+	assert.Len(t, point.BucketCounts, len(point.ExplicitBounds)+1)
+	assert.Equal(t, "1", point.BucketCounts[len(point.BucketCounts)-1], "the minute-long write lands in +Inf")
+}
+
+func TestOTLPExporterReportsCollectorErrors(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer collector.Close()
+
+	exp := &OTLPExporter{Endpoint: collector.URL}
+	err := exp.Export(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
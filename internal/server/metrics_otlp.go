@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// OTLP aggregation temporality; Prometheus values are cumulative.
+const otlpTemporalityCumulative = 2
+
+// OTLPExporter pushes metrics to an OpenTelemetry collector using OTLP over
+// HTTP with the JSON encoding.
+type OTLPExporter struct {
+	Endpoint    string            // Full metrics URL, e.g. http://collector:4318/v1/metrics
+	Headers     map[string]string // Sent with every request, e.g. authentication
+	ServiceName string
+	InstanceID  string
+	Client      *http.Client
+
+	startTime time.Time
+}
+
+// NewOTLPExporterFromEnv builds an exporter from the standard OpenTelemetry
+// variables OTEL_EXPORTER_OTLP_METRICS_ENDPOINT (or OTEL_EXPORTER_OTLP_ENDPOINT
+// with /v1/metrics appended), OTEL_EXPORTER_OTLP_HEADERS ("k=v,k=v") and
+// OTEL_SERVICE_NAME (default "tick-storm").
+func NewOTLPExporterFromEnv() (*OTLPExporter, error) {
+	e := &OTLPExporter{
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+		Headers:     make(map[string]string),
+		ServiceName: "tick-storm",
+		InstanceID:  exporterInstance(),
+	}
+	if e.Endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			e.Endpoint = strings.TrimRight(base, "/") + "/v1/metrics"
+		}
+	}
+	if e.Endpoint == "" {
+		return nil, fmt.Errorf("%w: OTEL_EXPORTER_OTLP_METRICS_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT is required", ErrExporterConfig)
+	}
+	for _, pair := range splitAndTrimCSV(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid OTEL_EXPORTER_OTLP_HEADERS entry %q", ErrExporterConfig, pair)
+		}
+		// Values are percent-encoded per the OpenTelemetry specification
+		if unescaped, err := url.QueryUnescape(strings.TrimSpace(v)); err == nil {
+			v = unescaped
+		}
+		e.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		e.ServiceName = v
+	}
+	return e, nil
+}
+
+// Name implements MetricsExporter.
+func (e *OTLPExporter) Name() string { return "otlp" }
+
+// Export implements MetricsExporter.
+func (e *OTLPExporter) Export(ctx context.Context, families []*dto.MetricFamily) error {
+	if e.startTime.IsZero() {
+		e.startTime = time.Now()
+	}
+	body, err := json.Marshal(e.request(families, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// OTLP/JSON request layout (opentelemetry-proto ExportMetricsServiceRequest).
+// 64-bit integers are encoded as strings, as the JSON mapping requires.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (e *OTLPExporter) request(families []*dto.MetricFamily, now time.Time) otlpRequest {
+	start := strconv.FormatInt(e.startTime.UnixNano(), 10)
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, mf := range families {
+		metric := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+			for _, m := range mf.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, otlpNumberPoint{
+					Attributes: otlpAttributes(m), StartTimeUnixNano: start, TimeUnixNano: ts,
+					AsDouble: m.GetCounter().GetValue(),
+				})
+			}
+			metric.Sum = sum
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &otlpGauge{}
+			for _, m := range mf.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, otlpNumberPoint{
+					Attributes: otlpAttributes(m), TimeUnixNano: ts, AsDouble: value,
+				})
+			}
+			metric.Gauge = gauge
+		case dto.MetricType_HISTOGRAM:
+			hist := &otlpHistogram{AggregationTemporality: otlpTemporalityCumulative}
+			for _, m := range mf.GetMetric() {
+				hist.DataPoints = append(hist.DataPoints, otlpHistogramPointFrom(m, start, ts))
+			}
+			metric.Histogram = hist
+		default:
+			continue // Summaries are not registered by the server
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: e.ServiceName}},
+			{Key: "service.instance.id", Value: otlpValue{StringValue: e.InstanceID}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/furkansarikaya/tick-storm/internal/server"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+// otlpHistogramPointFrom converts Prometheus' cumulative buckets to OTLP's
+// per-bucket counts, with the +Inf bucket implied by the total count.
+func otlpHistogramPointFrom(m *dto.Metric, start, ts string) otlpHistogramPoint {
+	h := m.GetHistogram()
+	point := otlpHistogramPoint{
+		Attributes:        otlpAttributes(m),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      ts,
+		Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:               h.GetSampleSum(),
+	}
+	var below uint64
+	for _, b := range h.GetBucket() {
+		point.ExplicitBounds = append(point.ExplicitBounds, b.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-below, 10))
+		below = b.GetCumulativeCount()
+	}
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-below, 10))
+	return point
+}
+
+func otlpAttributes(m *dto.Metric) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		attrs = append(attrs, otlpAttribute{Key: lp.GetName(), Value: otlpValue{StringValue: lp.GetValue()}})
+	}
+	return attrs
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricsStateVersion is the format of the metrics state file.
+const metricsStateVersion = 1
+
+// MetricsState is the snapshot persisted to MetricsStateFile so restarts do
+// not zero out the counters used for reporting.
+type MetricsState struct {
+	Version    int             `json:"version"`
+	SavedAt    time.Time       `json:"saved_at"`
+	InstanceID string          `json:"instance_id"`
+	Counters   []CounterSample `json:"counters"`
+	Usage      []UsageRecord   `json:"usage,omitempty"` // Closed usage periods within retention
+}
+
+// CounterSample is the value of one counter series.
+type CounterSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// persistedCounters returns the cumulative business counters carried across
+// restarts, by metric name.
+func (pm *PrometheusMetrics) persistedCounters() map[string]*prometheus.CounterVec {
+	return map[string]*prometheus.CounterVec{
+		"tick_storm_total_connections_total":      pm.totalConnections,
+		"tick_storm_messages_sent_total":          pm.messagesSentTotal,
+		"tick_storm_messages_recv_total":          pm.messagesRecvTotal,
+		"tick_storm_bytes_sent_total":             pm.bytesSentTotal,
+		"tick_storm_bytes_recv_total":             pm.bytesRecvTotal,
+		"tick_storm_business_messages_sent_total": pm.messagesSent,
+		"tick_storm_symbol_ticks_published_total": pm.symbolTicksPublished,
+		"tick_storm_symbol_bytes_published_total": pm.symbolBytesPublished,
+		"tick_storm_tenant_ticks_delivered_total": pm.tenantTicks,
+	}
+}
+
+// CounterSnapshot returns the series of the persisted counters, sorted by
+// name.
+func (pm *PrometheusMetrics) CounterSnapshot() []CounterSample {
+	var samples []CounterSample
+	for name, vec := range pm.persistedCounters() {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			vec.Collect(ch)
+			close(ch)
+		}()
+		for metric := range ch {
+			var m dto.Metric
+			if err := metric.Write(&m); err != nil || m.Counter == nil {
+				continue
+			}
+			sample := CounterSample{Name: name, Value: m.Counter.GetValue()}
+			if len(m.Label) > 0 {
+				sample.Labels = make(map[string]string, len(m.Label))
+				for _, label := range m.Label {
+					sample.Labels[label.GetName()] = label.GetValue()
+				}
+			}
+			samples = append(samples, sample)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Name < samples[j].Name })
+	return samples
+}
+
+// RestoreCounters adds samples to the persisted counters. Series labelled
+// with an instance ID are attributed to instanceID, so the totals carry over
+// when the ID changes between runs. Samples of unknown counters or labels
+// are skipped.
+func (pm *PrometheusMetrics) RestoreCounters(instanceID string, samples []CounterSample) int {
+	counters := pm.persistedCounters()
+	restored := 0
+	for _, sample := range samples {
+		vec, ok := counters[sample.Name]
+		if !ok || sample.Value <= 0 {
+			continue
+		}
+		labels := prometheus.Labels{}
+		for name, value := range sample.Labels {
+			labels[name] = value
+		}
+		if _, ok := labels["instance_id"]; ok {
+			labels["instance_id"] = instanceID
+		}
+		counter, err := vec.GetMetricWith(labels)
+		if err != nil {
+			continue
+		}
+		counter.Add(sample.Value)
+		restored++
+	}
+	return restored
+}
+
+// RestoreRecords adds closed usage records from a previous run that are
+// still within retention ahead of those closed since.
+func (m *UsageMeter) RestoreRecords(records []UsageRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := m.clock.Now().Add(-m.retention)
+	restored := make([]UsageRecord, 0, len(records)+len(m.records))
+	for _, record := range records {
+		if !record.PeriodEnd.Before(cutoff) {
+			restored = append(restored, record)
+		}
+	}
+	m.records = append(restored, m.records...)
+}
+
+// loadMetricsState restores the counters and usage records saved in
+// MetricsStateFile. A missing file is not an error.
+func (s *Server) loadMetricsState() error {
+	data, err := os.ReadFile(s.config.MetricsStateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read metrics state file: %w", err)
+	}
+	var state MetricsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse metrics state file: %w", err)
+	}
+	if state.Version != metricsStateVersion {
+		return fmt.Errorf("unsupported metrics state version %d", state.Version)
+	}
+
+	restored := s.prometheusMetrics.RestoreCounters(s.instanceID, state.Counters)
+	if s.usage != nil {
+		s.usage.RestoreRecords(state.Usage)
+	}
+	s.logger.Info("metrics state restored",
+		"file", s.config.MetricsStateFile,
+		"saved_at", state.SavedAt,
+		"saved_by", state.InstanceID,
+		"series", restored,
+		"usage_records", len(state.Usage),
+	)
+	return nil
+}
+
+// saveMetricsState writes the counters and usage records to
+// MetricsStateFile atomically, once it has been restored from.
+func (s *Server) saveMetricsState() error {
+	if s.config.MetricsStateFile == "" || !s.metricsStateLoaded.Load() {
+		return nil
+	}
+	state := MetricsState{
+		Version:    metricsStateVersion,
+		SavedAt:    s.config.clock().Now().UTC(),
+		InstanceID: s.instanceID,
+		Counters:   s.prometheusMetrics.CounterSnapshot(),
+	}
+	if s.usage != nil {
+		state.Usage = s.usage.Records(time.Time{}, "")
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	path := s.config.MetricsStateFile
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to persist metrics state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to persist metrics state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to persist metrics state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to persist metrics state: %w", err)
+	}
+	return nil
+}
+
+// persistMetricsState saves the metrics state every interval until ctx is
+// done. Shutdown and Stop save it once more after connections have closed.
+func (s *Server) persistMetricsState(ctx context.Context, interval time.Duration) {
+	ticker := s.config.clock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.storeMetricsState()
+		}
+	}
+}
+
+// storeMetricsState saves the metrics state, logging failures.
+func (s *Server) storeMetricsState() {
+	if err := s.saveMetricsState(); err != nil {
+		s.logger.Warn("metrics state not saved", "file", s.config.MetricsStateFile, "error", err)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreCountersCarriesTotalsToNewInstance(t *testing.T) {
+	before := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	before.IncrementTotalConnections("old")
+	before.IncrementTotalConnections("old")
+	before.IncrementMessagesSent("data_batch", "second")
+	before.AddTenantTicks("old", "acme", 40)
+
+	after := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	after.IncrementTotalConnections("new")
+	samples := append(before.CounterSnapshot(), CounterSample{Name: "tick_storm_unknown_total", Value: 1})
+	assert.Equal(t, 3, after.RestoreCounters("new", samples))
+
+	assert.Equal(t, 3.0, counterValue(t, after.totalConnections.WithLabelValues("new")))
+	assert.Equal(t, 1.0, counterValue(t, after.messagesSentTotal.WithLabelValues("data_batch", "second")))
+	assert.Equal(t, 40.0, counterValue(t, after.tenantTicks.WithLabelValues("new", "acme")))
+}
+
+func TestMetricsStateSurvivesRestart(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.UsageInterval = time.Minute
+	config.MetricsStateFile = filepath.Join(t.TempDir(), "metrics-state.json")
+
+	first := NewServer(config)
+	require.NoError(t, first.loadMetricsState())
+	require.NoError(t, first.saveMetricsState())
+	_, err := os.Stat(config.MetricsStateFile)
+	assert.True(t, os.IsNotExist(err), "nothing is saved before the state is restored")
+
+	first.metricsStateLoaded.Store(true)
+	first.prometheusMetrics.IncrementTotalConnections(first.instanceID)
+	first.prometheusMetrics.AddBytesSent("tcp", 512)
+	first.usage.records = []UsageRecord{
+		{Username: "alice", PeriodEnd: clock.Now().Add(-48 * time.Hour), MessagesSent: 1}, // Past retention
+		{Username: "alice", PeriodEnd: clock.Now().Add(-time.Minute), MessagesSent: 7},
+	}
+	require.NoError(t, first.saveMetricsState())
+
+	second := NewServer(config)
+	require.NoError(t, second.loadMetricsState())
+	assert.Equal(t, 1.0, counterValue(t, second.prometheusMetrics.totalConnections.WithLabelValues(second.instanceID)))
+	assert.Equal(t, 512.0, counterValue(t, second.prometheusMetrics.bytesSentTotal.WithLabelValues("tcp")))
+	records := second.usage.Records(time.Time{}, "alice")
+	require.Len(t, records, 1)
+	assert.Equal(t, uint64(7), records[0].MessagesSent)
+}
+
+func TestLoadMetricsStateRejectsCorruptFile(t *testing.T) {
+	config := DefaultConfig()
+	config.MetricsStateFile = filepath.Join(t.TempDir(), "metrics-state.json")
+	require.NoError(t, os.WriteFile(config.MetricsStateFile, []byte("{"), 0o600))
+
+	err := NewServer(config).Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid metrics state file")
+}
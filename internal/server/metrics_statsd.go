@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdMaxPacket keeps datagrams under a typical Ethernet MTU.
+const statsdMaxPacket = 1432
+
+// StatsdExporter pushes metrics over UDP in the statsd line format with
+// DogStatsD tags, which Datadog, Telegraf and statsd_exporter all accept.
+// Counters and histogram sums/counts are sent as deltas since the previous
+// push; gauges are sent as absolute values.
+type StatsdExporter struct {
+	Addr   string // host:port of the statsd agent
+	Prefix string // Prepended to every metric name, e.g. "tickstorm."
+
+	mu       sync.Mutex
+	conn     net.Conn
+	previous map[string]float64 // Last cumulative value per counter series
+}
+
+// NewStatsdExporterFromEnv builds an exporter from STATSD_ADDR (default
+// "127.0.0.1:8125") and STATSD_PREFIX.
+func NewStatsdExporterFromEnv() (*StatsdExporter, error) {
+	e := &StatsdExporter{Addr: "127.0.0.1:8125", Prefix: os.Getenv("STATSD_PREFIX")}
+	if v := os.Getenv("STATSD_ADDR"); v != "" {
+		e.Addr = v
+	}
+	if _, _, err := net.SplitHostPort(e.Addr); err != nil {
+		return nil, fmt.Errorf("%w: STATSD_ADDR: %v", ErrExporterConfig, err)
+	}
+	return e, nil
+}
+
+// Name implements MetricsExporter.
+func (e *StatsdExporter) Name() string { return "statsd" }
+
+// Export implements MetricsExporter.
+func (e *StatsdExporter) Export(ctx context.Context, families []*dto.MetricFamily) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", e.Addr)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+	if e.previous == nil {
+		e.previous = make(map[string]float64)
+	}
+
+	var packet []byte
+	for _, line := range e.lines(families) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			if _, err := e.conn.Write(packet); err != nil {
+				return err
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		if _, err := e.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines converts families to statsd lines, advancing the counter baseline.
+func (e *StatsdExporter) lines(families []*dto.MetricFamily) []string {
+	var lines []string
+	for _, mf := range families {
+		name := e.Prefix + mf.GetName()
+		for _, m := range mf.GetMetric() {
+			tags := statsdTags(m)
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				if delta := e.delta(name, tags, m.GetCounter().GetValue()); delta > 0 {
+					lines = append(lines, statsdLine(name, delta, "c", tags))
+				}
+			case dto.MetricType_GAUGE:
+				lines = append(lines, statsdLine(name, m.GetGauge().GetValue(), "g", tags))
+			case dto.MetricType_UNTYPED:
+				lines = append(lines, statsdLine(name, m.GetUntyped().GetValue(), "g", tags))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				if delta := e.delta(name+"_count", tags, float64(h.GetSampleCount())); delta > 0 {
+					lines = append(lines, statsdLine(name+"_count", delta, "c", tags))
+				}
+				if delta := e.delta(name+"_sum", tags, h.GetSampleSum()); delta > 0 {
+					lines = append(lines, statsdLine(name+"_sum", delta, "c", tags))
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// delta returns the increase of a cumulative series since the last push. A
+// drop in value means the process restarted, so the full value is reported.
+func (e *StatsdExporter) delta(name, tags string, value float64) float64 {
+	key := name + "|" + tags
+	prev, seen := e.previous[key]
+	e.previous[key] = value
+	if !seen || value < prev {
+		return value
+	}
+	return value - prev
+}
+
+func statsdLine(name string, value float64, kind, tags string) string {
+	line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return line
+}
+
+// statsdTags renders labels as sorted DogStatsD tags, dropping characters the
+// line format reserves.
+func statsdTags(m *dto.Metric) string {
+	labels := seriesLabels(m)
+	tags := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		tags = append(tags, k+":"+strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_").Replace(v))
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
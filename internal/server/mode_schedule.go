@@ -0,0 +1,85 @@
+package server
+
+import (
+	"time"
+)
+
+// modeSchedule decides when a data source emits for a subscription mode.
+type modeSchedule struct {
+	align  bool          // Emit on wall-clock boundaries of the period
+	offset time.Duration // Shift from each boundary when aligned
+}
+
+// modeSchedule returns the schedule configured for ticker-driven sources.
+func (c *Config) modeSchedule() modeSchedule {
+	return modeSchedule{align: c.AlignModeBoundaries, offset: c.ModeAlignmentOffset}
+}
+
+// modeTicker fires once per emission period.
+type modeTicker interface {
+	C() <-chan time.Time
+	// Fired returns the timestamp to give ticks emitted for the tick just
+	// received from C.
+	Fired() time.Time
+	Stop()
+}
+
+// ticker returns a modeTicker firing every period. Aligned tickers fire at
+// multiples of period since the Unix epoch plus the offset, so every
+// subscription in a mode emits at the same instants whenever it started;
+// otherwise the period runs from now.
+func (s modeSchedule) ticker(clock Clock, period time.Duration) modeTicker {
+	if !s.align {
+		return &relativeTicker{clock: clock, ticker: clock.NewTicker(period)}
+	}
+	now := clock.Now()
+	b := &boundaryTicker{clock: clock, period: period, offset: s.offset % period}
+	b.timer = clock.NewTimer(b.next(now).Sub(now))
+	return b
+}
+
+// relativeTicker stamps ticks with the time they fire.
+type relativeTicker struct {
+	clock  Clock
+	ticker Ticker
+}
+
+func (t *relativeTicker) C() <-chan time.Time { return t.ticker.C() }
+func (t *relativeTicker) Fired() time.Time    { return t.clock.Now() }
+func (t *relativeTicker) Stop()               { t.ticker.Stop() }
+
+// boundaryTicker re-arms a timer for the next boundary each time it fires,
+// so wake-up delays never accumulate. Ticks are stamped with the boundary
+// they belong to, not the slightly later time the receiver got to them;
+// boundaries missed while the receiver was busy are skipped.
+type boundaryTicker struct {
+	clock  Clock
+	period time.Duration
+	offset time.Duration
+	timer  Timer
+}
+
+func (b *boundaryTicker) C() <-chan time.Time { return b.timer.C() }
+
+func (b *boundaryTicker) Fired() time.Time {
+	now := b.clock.Now()
+	b.timer.Reset(b.next(now).Sub(now))
+	return b.last(now)
+}
+
+func (b *boundaryTicker) Stop() { b.timer.Stop() }
+
+// last returns the latest boundary at or before t.
+func (b *boundaryTicker) last(t time.Time) time.Time {
+	ns := t.UnixNano() - int64(b.offset)
+	ns -= ns % int64(b.period)
+	if ns > t.UnixNano()-int64(b.offset) {
+		ns -= int64(b.period) // Before the epoch % rounds towards zero
+	}
+	return time.Unix(0, ns+int64(b.offset)).In(t.Location())
+}
+
+// next returns the first boundary after t.
+func (b *boundaryTicker) next(t time.Time) time.Time {
+	return b.last(t).Add(b.period)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestBoundaryTickerFiresOnWallClockBoundaries(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch.Add(1300 * time.Millisecond))
+	ticker := modeSchedule{align: true, offset: 250 * time.Millisecond}.ticker(clock, time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(900 * time.Millisecond) // 09:30:02.200
+	select {
+	case <-ticker.C():
+		t.Fatal("fired before the boundary")
+	default:
+	}
+
+	for _, want := range []time.Duration{2250, 3250, 4250} {
+		clock.Advance(time.Second)
+		<-ticker.C()
+		assert.Equal(t, fakeEpoch.Add(want*time.Millisecond), ticker.Fired())
+	}
+}
+
+func TestBoundaryTickerSkipsMissedBoundaries(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch.Add(10 * time.Second))
+	ticker := modeSchedule{align: true}.ticker(clock, time.Minute)
+	defer ticker.Stop()
+
+	clock.Advance(3 * time.Minute)
+	<-ticker.C()
+	assert.Equal(t, fakeEpoch.Add(3*time.Minute), ticker.Fired())
+
+	clock.Advance(time.Minute)
+	<-ticker.C()
+	assert.Equal(t, fakeEpoch.Add(4*time.Minute), ticker.Fired())
+}
+
+func TestRelativeTickerRunsFromStart(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch.Add(1300 * time.Millisecond))
+	ticker := modeSchedule{}.ticker(clock, time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(time.Second)
+	<-ticker.C()
+	assert.Equal(t, fakeEpoch.Add(2300*time.Millisecond), ticker.Fired())
+}
+
+func TestSyntheticSourceAlignsSubscribers(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch.Add(400 * time.Millisecond))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.AlignModeBoundaries = true
+	source := config.dataSource()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := &Subscription{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	first, second := make(chan []*pb.Tick, 1), make(chan []*pb.Tick, 1)
+	go source.Stream(ctx, sub, func(ticks []*pb.Tick) { first <- ticks })
+	waitForTimers(t, clock, 1)
+
+	clock.Advance(300 * time.Millisecond)
+	go source.Stream(ctx, sub, func(ticks []*pb.Tick) { second <- ticks })
+	waitForTimers(t, clock, 2)
+
+	clock.Advance(300 * time.Millisecond)
+	want := fakeEpoch.Add(time.Second).UnixMilli()
+	assert.Equal(t, want, (<-first)[0].TimestampMs)
+	assert.Equal(t, want, (<-second)[0].TimestampMs)
+
+	cancel()
+	waitForTimers(t, clock, 0)
+}
@@ -65,7 +65,7 @@ type LogAlertHandler struct {
 }
 
 // NewNetworkMonitor creates a new network monitor
-func NewNetworkMonitor() *NetworkMonitor {
+func NewNetworkMonitor(logger *slog.Logger) *NetworkMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &NetworkMonitor{
@@ -75,7 +75,7 @@ func NewNetworkMonitor() *NetworkMonitor {
 		maxFailedConnectionsRate:  0.5,   // Alert if > 50% connections fail
 		maxPortScanAttemptsPerMin: 100,   // Alert if > 100 port scans/min
 		alertCooldown:             5 * time.Minute,
-		logger:                    slog.Default().With("component", "network_monitor"),
+		logger:                    logger.With("component", "network_monitor"),
 		alertHandlers:             []AlertHandler{},
 	}
 }
@@ -0,0 +1,168 @@
+// Package server implements the operational HTTP server for probes, metrics and administration.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/spec"
+)
+
+// opsHandler returns the mux served on Config.OpsListenAddr: health and
+// readiness probes, Prometheus metrics, server stats, load balancer weight,
+// autoscaling data and admin endpoints.
+func (s *Server) opsHandler() http.Handler {
+	if s.healthChecker == nil {
+		s.healthChecker = NewHealthChecker(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(s.config.HealthPath, s.healthChecker)
+	if s.config.HealthPath != "/health" {
+		mux.Handle("/health", s.healthChecker)
+	}
+	mux.HandleFunc(s.config.ReadyPath, s.healthChecker.ServeReady)
+	mux.Handle(s.config.MetricsPath, s.prometheusMetrics.Handler())
+
+	// Simple ping endpoint
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("pong"))
+	})
+
+	mux.HandleFunc("/version", s.handleVersion)
+	mux.HandleFunc("/stats", s.handleStats)
+
+	// Routing weight and affinity token for load balancers
+	mux.HandleFunc("/lb/weight", s.handleLBWeight)
+
+	// Protocol descriptor and SDK bundle for client code generation
+	mux.HandleFunc("/protocol", s.handleProtocolDescriptor)
+	mux.HandleFunc("/protocol/"+spec.BundleName(), s.handleProtocolBundle)
+
+	// Custom metrics and scale recommendations for HPA
+	if s.getAutoScalingConfig().Enabled {
+		mux.HandleFunc("/autoscaling/metrics", s.handleAutoScalingMetrics)
+		mux.HandleFunc("/autoscaling/recommendations", s.handleScaleRecommendations)
+	}
+
+	// Gossip and tick replication between cluster members (protected by
+	// CLUSTER_SECRET)
+	if s.cluster != nil {
+		mux.Handle("/cluster/gossip", s.cluster.requireSecret(s.cluster))
+		if s.config.Replication != nil {
+			mux.Handle("/cluster/replicate", s.cluster.requireSecret(s.config.Replication.source()))
+		}
+	}
+
+	// Producer tick ingestion (token-protected per producer)
+	if s.config.Ingest != nil {
+		mux.HandleFunc("/ingest", s.handleIngest)
+	}
+	
+	// Admin endpoints (token-protected, disabled without ADMIN_TOKEN)
+	s.registerAdminRoutes(mux)
+	return mux
+}
+
+// handleVersion reports the build the server is running.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(contentTypeHeader, "application/json")
+	json.NewEncoder(w).Encode(s.BuildInfo())
+}
+
+// handleStats reports GetStats as JSON.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(contentTypeHeader, "application/json")
+	json.NewEncoder(w).Encode(s.GetStats())
+}
+
+// handleProtocolDescriptor serves the framing descriptor of the protocol
+// this build speaks.
+func (s *Server) handleProtocolDescriptor(w http.ResponseWriter, r *http.Request) {
+	d, err := spec.Describe()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// handleProtocolBundle serves the versioned protocol bundle. Its digest is
+// the ETag, so SDK builds can poll cheaply for schema changes.
+func (s *Server) handleProtocolBundle(w http.ResponseWriter, r *http.Request) {
+	bundle, err := spec.Bundle()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(contentTypeHeader, "application/gzip")
+	w.Header().Set("ETag", `"`+spec.Digest(bundle)+`"`)
+	w.Header().Set("Content-Disposition", "attachment; filename="+spec.BundleName())
+	http.ServeContent(w, r, spec.BundleName(), time.Time{}, bytes.NewReader(bundle))
+}
+
+// startOpsServer starts the ops HTTP server. An empty OpsListenAddr disables it.
+func (s *Server) startOpsServer() error {
+	if s.config.OpsListenAddr == "" {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.config.OpsListenAddr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           s.opsHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	s.mu.Lock()
+	s.opsServer = srv
+	s.opsAddr = ln.Addr().String()
+	s.mu.Unlock()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("ops server failed", "error", err)
+		}
+	}()
+
+	s.logger.Info("ops server started",
+		"addr", ln.Addr().String(),
+		"health_path", s.config.HealthPath,
+		"ready_path", s.config.ReadyPath,
+		"metrics_path", s.config.MetricsPath,
+	)
+	return nil
+}
+
+// stopOpsServer shuts the ops server down, waiting for in-flight requests
+// until ctx expires.
+func (s *Server) stopOpsServer(ctx context.Context) {
+	s.mu.Lock()
+	srv := s.opsServer
+	s.opsServer, s.opsAddr = nil, ""
+	s.mu.Unlock()
+
+	if srv == nil {
+		return
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		srv.Close()
+	}
+}
+
+// OpsAddr returns the address the ops server listens on, or "" if it is not
+// running.
+func (s *Server) OpsAddr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.opsAddr
+}
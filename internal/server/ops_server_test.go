@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/buildinfo"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/spec"
+)
+
+func TestOpsServerServesConfiguredPaths(t *testing.T) {
+	config := DefaultConfig()
+	config.OpsListenAddr = "127.0.0.1:0"
+	config.HealthPath = "/livez"
+	config.MetricsPath = "/internal/metrics"
+	srv := NewServer(config)
+	require.NoError(t, srv.startOpsServer())
+	base := "http://" + srv.OpsAddr()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(base + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, _ := get("/livez")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get("/health")
+	assert.Equal(t, http.StatusOK, code, "/health is kept as an alias")
+	code, _ = get("/ready")
+	assert.Equal(t, http.StatusOK, code)
+	code, body := get("/internal/metrics")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "tick_storm_write_timeouts_total")
+	code, _ = get("/metrics")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	require.NoError(t, srv.Stop(context.Background()))
+	_, err := http.Get(base + "/livez")
+	assert.Error(t, err, "the ops server stops with the server")
+	assert.Empty(t, srv.OpsAddr())
+}
+
+func TestOpsServerDisabled(t *testing.T) {
+	config := DefaultConfig()
+	config.OpsListenAddr = ""
+	srv := NewServer(config)
+	require.NoError(t, srv.startOpsServer())
+	assert.Empty(t, srv.OpsAddr())
+}
+
+func TestOpsServerReportsVersion(t *testing.T) {
+	t.Setenv("APP_VERSION", "")
+	config := DefaultConfig()
+	config.OpsListenAddr = "127.0.0.1:0"
+	srv := NewServer(config)
+	require.NoError(t, srv.startOpsServer())
+	defer srv.stopOpsServer(context.Background())
+	base := "http://" + srv.OpsAddr()
+
+	resp, err := http.Get(base + "/version")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var info buildinfo.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	assert.Equal(t, buildinfo.Version, info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+
+	resp, err = http.Get(base + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `tick_storm_build_info{build_date="`+info.BuildDate+`"`)
+}
+
+func TestOpsServerReportsStats(t *testing.T) {
+	config := DefaultConfig()
+	config.OpsListenAddr = "127.0.0.1:0"
+	srv := NewServer(config)
+	require.NoError(t, srv.startOpsServer())
+	defer srv.stopOpsServer(context.Background())
+
+	resp, err := http.Get("http://" + srv.OpsAddr() + "/stats")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var stats map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, float64(config.MaxConnections), stats["max_connections"])
+	assert.Contains(t, stats, "active_connections")
+}
+
+func TestOpsServerServesProtocolBundle(t *testing.T) {
+	config := DefaultConfig()
+	config.OpsListenAddr = "127.0.0.1:0"
+	srv := NewServer(config)
+	require.NoError(t, srv.startOpsServer())
+	defer srv.stopOpsServer(context.Background())
+	base := "http://" + srv.OpsAddr()
+
+	resp, err := http.Get(base + "/protocol")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var d spec.Descriptor
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&d))
+	assert.Equal(t, uint8(protocol.CurrentProtocolVersion), d.ProtocolVersion)
+
+	resp, err = http.Get(base + "/protocol/" + spec.BundleName())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	want, err := spec.Bundle()
+	require.NoError(t, err)
+	assert.Equal(t, want, body)
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, `"`+spec.Digest(want)+`"`, etag)
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/protocol/"+spec.BundleName(), nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
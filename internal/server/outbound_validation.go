@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Outbound validation modes.
+const (
+	OutboundValidationOff    = "off"    // Batches are sent unchecked
+	OutboundValidationSample = "sample" // A fraction of batches is checked
+	OutboundValidationFull   = "full"   // Every batch is checked
+)
+
+// outboundValidator checks batches against the protocol before they are
+// sent, catching bad data from the tick source. Invalid batches are dropped.
+type outboundValidator struct {
+	mode   string
+	rate   float64 // Fraction of batches checked in sample mode
+	source string  // Data source named in logs and metrics
+
+	checked atomic.Uint64
+	corrupt atomic.Uint64
+}
+
+// newOutboundValidator returns the validator for config, or nil when
+// validation is off.
+func newOutboundValidator(config *Config) (*outboundValidator, error) {
+	switch config.OutboundValidation {
+	case "", OutboundValidationOff:
+		return nil, nil
+	case OutboundValidationSample:
+		if config.OutboundValidationSampleRate <= 0 || config.OutboundValidationSampleRate > 1 {
+			return nil, fmt.Errorf("sample rate must be in (0, 1], got %v", config.OutboundValidationSampleRate)
+		}
+	case OutboundValidationFull:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", config.OutboundValidation)
+	}
+	return &outboundValidator{
+		mode:   config.OutboundValidation,
+		rate:   config.OutboundValidationSampleRate,
+		source: config.dataSourceName(),
+	}, nil
+}
+
+// Check validates ticks as the batch sent at now. Unsampled batches pass.
+func (v *outboundValidator) Check(ticks []*pb.Tick, now time.Time) error {
+	if v.mode == OutboundValidationSample && rand.Float64() >= v.rate {
+		return nil
+	}
+	v.checked.Add(1)
+	err := protocol.ValidateDataBatch(&pb.DataBatch{Ticks: ticks, BatchTimestampMs: now.UnixMilli()})
+	if err != nil {
+		v.corrupt.Add(1)
+	}
+	return err
+}
+
+// GetStats returns the mode and batch counters.
+func (v *outboundValidator) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":            v.mode,
+		"source":          v.source,
+		"checked_batches": v.checked.Load(),
+		"corrupt_batches": v.corrupt.Load(),
+	}
+}
+
+// validateOutbound reports whether batch may be sent. Corrupt batches are
+// counted and logged with the source that produced them.
+func (h *ConnectionHandler) validateOutbound(batch []*pb.Tick) bool {
+	if h.server == nil || h.server.outbound == nil {
+		return true
+	}
+	v := h.server.outbound
+	err := v.Check(batch, h.config.clock().Now())
+	if err == nil {
+		return true
+	}
+	h.logger.Error("dropping corrupt outbound batch",
+		"source", v.source,
+		"ticks", len(batch),
+		"error", err,
+	)
+	if h.server.prometheusMetrics != nil {
+		h.server.prometheusMetrics.IncrementCorruptBatches(h.server.instanceID, v.source)
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestNewOutboundValidator(t *testing.T) {
+	config := DefaultConfig()
+	v, err := newOutboundValidator(config)
+	require.NoError(t, err)
+	assert.Nil(t, v, "off by default")
+
+	config.OutboundValidation = OutboundValidationSample
+	config.OutboundValidationSampleRate = 0
+	_, err = newOutboundValidator(config)
+	assert.Error(t, err)
+
+	config.OutboundValidation = "strict"
+	_, err = newOutboundValidator(config)
+	assert.Error(t, err)
+
+	config.OutboundValidation = OutboundValidationFull
+	config.Replay = &ReplayConfig{}
+	v, err = newOutboundValidator(config)
+	require.NoError(t, err)
+	assert.Equal(t, "replay", v.source)
+}
+
+func TestOutboundValidatorSampling(t *testing.T) {
+	config := DefaultConfig()
+	config.OutboundValidation = OutboundValidationSample
+	config.OutboundValidationSampleRate = 0.5
+	v, err := newOutboundValidator(config)
+	require.NoError(t, err)
+
+	bad := []*pb.Tick{{Symbol: "EURUSD", Price: -1, TimestampMs: time.Now().UnixMilli()}}
+	var failed int
+	for i := 0; i < 1000; i++ {
+		if v.Check(bad, time.Now()) != nil {
+			failed++
+		}
+	}
+	assert.InDelta(t, 500, failed, 100)
+	assert.Equal(t, uint64(failed), v.GetStats()["checked_batches"])
+	assert.Equal(t, uint64(failed), v.GetStats()["corrupt_batches"])
+}
+
+func TestFlushBatchDropsCorruptBatch(t *testing.T) {
+	config := DefaultConfig()
+	config.OutboundValidation = OutboundValidationFull
+	outbound, err := newOutboundValidator(config)
+	require.NoError(t, err)
+	srv := &Server{config: config, outbound: outbound, prometheusMetrics: NewPrometheusMetrics()}
+
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+	handler := &ConnectionHandler{conn: conn, config: config, server: srv, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tick := func(symbol string) *pb.Tick {
+		return &pb.Tick{Symbol: symbol, Price: 1, TimestampMs: time.Now().UnixMilli(), Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}
+	}
+	errChan := make(chan error, 1)
+	handler.pendingBatch = []*pb.Tick{tick("eur usd")}
+	handler.flushBatch(errChan)
+	assert.Empty(t, handler.pendingBatch)
+
+	handler.pendingBatch = []*pb.Tick{tick("EURUSD")}
+	go handler.flushBatch(errChan)
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := protocol.NewFrameReader(clientSide, config.MaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeDataBatch, frame.Type)
+	var batch pb.DataBatch
+	require.NoError(t, proto.Unmarshal(frame.Payload, &batch))
+	require.Len(t, batch.Ticks, 1)
+	assert.Equal(t, "EURUSD", batch.Ticks[0].Symbol, "only the valid batch is sent")
+
+	stats := outbound.GetStats()
+	assert.Equal(t, uint64(2), stats["checked_batches"])
+	assert.Equal(t, uint64(1), stats["corrupt_batches"])
+	assert.Empty(t, errChan)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// ErrConnectionPanic is returned for a connection closed after a panic in
+// one of its goroutines.
+var ErrConnectionPanic = errors.New("connection goroutine panicked")
+
+// reportPanic logs a panic recovered from goroutine with its stack to the
+// connection's logger, counts it and returns the error the connection ends
+// with.
+func (s *Server) reportPanic(logger *slog.Logger, goroutine string, r interface{}) error {
+	err := fmt.Errorf("%w: %s: %v", ErrConnectionPanic, goroutine, r)
+	atomic.AddUint64(&s.connPanics, 1)
+	logger.Error("recovered panic, closing connection",
+		"goroutine", goroutine,
+		"panic", fmt.Sprint(r),
+		"stack", string(debug.Stack()),
+	)
+	if s.prometheusMetrics != nil {
+		s.prometheusMetrics.IncrementConnectionPanics(s.instanceID, goroutine)
+	}
+	return err
+}
+
+// recoverConnection ends a connection whose goroutine panicked, leaving the
+// accept loop and other connections running. Defer it directly so recover
+// sees the panic; endErr receives the panic as the connection's end reason.
+func (s *Server) recoverConnection(conn io.Closer, logger *slog.Logger, endErr *error) {
+	if r := recover(); r != nil {
+		*endErr = s.reportPanic(logger, "connection", r)
+		conn.Close()
+	}
+}
+
+// recoverPanic closes the connection when one of the handler's own
+// goroutines panics, which ends Handle. Defer it directly.
+func (h *ConnectionHandler) recoverPanic(goroutine string) {
+	if r := recover(); r != nil {
+		if h.server != nil {
+			h.server.reportPanic(h.logger, goroutine, r)
+		} else {
+			h.logger.Error("recovered panic, closing connection",
+				"goroutine", goroutine,
+				"panic", fmt.Sprint(r),
+				"stack", string(debug.Stack()),
+			)
+		}
+		h.conn.Close()
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
+)
+
+func TestPanicClosesOnlyOffendingConnection(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.Authenticator = authn.AuthenticatorFunc(func(_ context.Context, _ authn.ConnMeta, req authn.Request) (authn.Session, error) {
+		return authn.Session{Username: req.Username}, nil
+	})
+	server := NewServer(config)
+	server.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	server.Hooks().OnAuthenticated(func(_ context.Context, conn *Connection) error {
+		if conn.Session().Username == "boom" {
+			panic("hook bug")
+		}
+		return nil
+	})
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	auth := func(username string) (net.Conn, *protocol.FrameReader) {
+		// Stay under the per-IP connection burst limit
+		time.Sleep(150 * time.Millisecond)
+		client, err := net.Dial("tcp", server.listener.Addr().String())
+		require.NoError(t, err)
+		client.SetDeadline(time.Now().Add(2 * time.Second))
+		frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{Username: username, Password: "x"})
+		require.NoError(t, err)
+		require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+		return client, protocol.NewFrameReader(client, protocol.DefaultMaxMessageSize)
+	}
+
+	survivor, r := auth("alice")
+	defer survivor.Close()
+	frame, err := r.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeACK, frame.Type)
+
+	victim, r := auth("boom")
+	defer victim.Close()
+	_, err = r.ReadFrame()
+	assert.ErrorIs(t, err, io.EOF, "the panicking connection is closed")
+	assert.Equal(t, uint64(1), server.GetStats()["connection_panics"])
+
+	// The existing connection and the accept loop are unaffected
+	heartbeat, err := protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 1})
+	require.NoError(t, err)
+	require.NoError(t, protocol.NewFrameWriter(survivor).WriteFrame(heartbeat))
+	frame, err = protocol.NewFrameReader(survivor, protocol.DefaultMaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, protocol.MessageTypePong, frame.Type)
+
+	client, r := auth("bob")
+	defer client.Close()
+	frame, err = r.ReadFrame()
+	require.NoError(t, err)
+	var ack pb.AckResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &ack))
+	assert.True(t, ack.Success)
+}
+
+func TestHandlerGoroutinePanicClosesConnection(t *testing.T) {
+	config := DefaultConfig()
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnection(serverSide, config)
+	handler := &ConnectionHandler{conn: conn, config: config, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	func() {
+		defer handler.recoverPanic("delivery")
+		panic("delivery bug")
+	}()
+	assert.True(t, conn.closed.Load())
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/secrets"
+)
+
+const (
+	// preflightFDHeadroom is the file descriptors reserved beyond
+	// MaxConnections for listeners, the ops server, spill and log files.
+	preflightFDHeadroom = 1024
+
+	// preflightCertExpiryWarning is how close to expiry a certificate is
+	// reported as a warning.
+	preflightCertExpiryWarning = 14 * 24 * time.Hour
+)
+
+// PreflightStatus grades one preflight check.
+type PreflightStatus string
+
+const (
+	PreflightOK   PreflightStatus = "ok"
+	PreflightWarn PreflightStatus = "warn"
+	PreflightFail PreflightStatus = "fail"
+)
+
+// PreflightCheck is one line of a PreflightReport.
+type PreflightCheck struct {
+	Name   string
+	Status PreflightStatus
+	Detail string
+}
+
+// PreflightReport lists the outcome of every preflight check in the order
+// they ran.
+type PreflightReport struct {
+	Checks []PreflightCheck
+}
+
+func (r *PreflightReport) add(name string, status PreflightStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, PreflightCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// check records err as a failure, or detail when err is nil.
+func (r *PreflightReport) check(name string, err error, detail string) {
+	if err != nil {
+		r.add(name, PreflightFail, "%v", err)
+		return
+	}
+	r.add(name, PreflightOK, "%s", detail)
+}
+
+// Failed reports whether any check failed. Warnings do not fail preflight.
+func (r PreflightReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == PreflightFail {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteTo writes the report as one line per check followed by a summary.
+func (r PreflightReport) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	failed, warned := 0, 0
+	for _, c := range r.Checks {
+		switch c.Status {
+		case PreflightFail:
+			failed++
+		case PreflightWarn:
+			warned++
+		}
+		fmt.Fprintf(&b, "%-4s  %-20s  %s\n", strings.ToUpper(string(c.Status)), c.Name, c.Detail)
+	}
+	if failed > 0 {
+		fmt.Fprintf(&b, "preflight failed: %d of %d checks failed, %d warnings\n", failed, len(r.Checks), warned)
+	} else {
+		fmt.Fprintf(&b, "preflight passed: %d checks, %d warnings\n", len(r.Checks), warned)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Preflight checks config the way Start would without serving anything:
+// settings, TLS material, the file descriptor limit, that every listen
+// address is free, the tick source and the AUTH credentials. Ports are bound
+// and released straight away. ctx bounds calls to secrets backends and data
+// source upstreams.
+func Preflight(ctx context.Context, config *Config) PreflightReport {
+	var r PreflightReport
+
+	// Secrets override credentials and TLS material, so fetch them first
+	var secretValues map[string][]byte
+	if config.SecretsProvider != nil {
+		values, err := config.SecretsProvider.Fetch(ctx, secretKeys)
+		r.check("secrets", err, fmt.Sprintf("%s provider returned %d of %d keys",
+			config.SecretsProvider.Name(), len(values), len(secretKeys)))
+		secretValues = values
+	}
+
+	preflightSettings(&r, config)
+	preflightTLS(&r, config, secretValues)
+	preflightFileLimit(&r, config)
+	preflightListeners(&r, config)
+	preflightDataSource(ctx, &r, config)
+	preflightCredentials(&r, config, secretValues)
+	return r
+}
+
+// preflightSettings builds each component Start builds from config.
+func preflightSettings(r *PreflightReport, config *Config) {
+	_, err := config.validationLimits()
+	r.check("validation_limits", err, "within bounds")
+
+	_, err = NewIPFilterFromStrings(config.AllowCIDRs, config.BlockCIDRs)
+	r.check("ip_filter", err, fmt.Sprintf("%d allowed, %d blocked CIDRs", len(config.AllowCIDRs), len(config.BlockCIDRs)))
+
+	_, err = NewQoSScheduler(config, func() bool { return false })
+	r.check("priority_classes", err, "valid")
+
+	_, err = NewTenantRegistry(config.Tenants, config.clock())
+	r.check("tenants", err, fmt.Sprintf("%d configured", len(config.Tenants)))
+
+	if config.ProxyProtocol {
+		_, err = parseProxyTrustedNetworks(config.ProxyProtocolTrustedCIDRs)
+		r.check("proxy_protocol", err, fmt.Sprintf("%d trusted networks", len(config.ProxyProtocolTrustedCIDRs)))
+	}
+
+	if config.Replication != nil {
+		r.check("replication", config.validateReplication(), fmt.Sprintf("%s election", config.Replication.Election))
+	}
+
+	_, err = NewEgressLimits(config)
+	r.check("egress_shaping", err, fmt.Sprintf("%d user rates", len(config.UserEgressRates)))
+
+	_, err = newOutboundValidator(config)
+	r.check("outbound_validation", err, "valid")
+
+	if len(config.TradingSessions) > 0 {
+		_, err = NewTradingCalendar(config.TradingSessions, config.SessionPolicy, config.clock().Now())
+		r.check("trading_sessions", err, fmt.Sprintf("%d sessions", len(config.TradingSessions)))
+	}
+	if config.MaxConnsPerIP > 0 || len(config.MaxConnsPerIPOverrides) > 0 {
+		_, err = NewIPConnLimiter(config)
+		r.check("per_ip_limits", err, fmt.Sprintf("%d per IP, %d overrides", config.MaxConnsPerIP, len(config.MaxConnsPerIPOverrides)))
+	}
+	if config.GeoIP == nil && config.GeoIPDatabase != "" {
+		if db, err := LoadGeoIPDatabase(config.GeoIPDatabase); err != nil {
+			r.add("geoip", PreflightFail, "%v", err)
+		} else {
+			r.add("geoip", PreflightOK, "%d networks in %s", db.Len(), config.GeoIPDatabase)
+		}
+	}
+}
+
+// preflightTLS loads the certificates and CAs the listeners would use and
+// checks the default certificate's validity period.
+func preflightTLS(r *PreflightReport, config *Config, secretValues map[string][]byte) {
+	if config.TLS == nil || !config.TLS.Enabled {
+		r.add("tls", PreflightOK, "disabled")
+		return
+	}
+	if err := config.TLS.ValidateTLSConfig(); err != nil {
+		r.add("tls", PreflightFail, "%v", err)
+		return
+	}
+
+	// Build from a copy so the check neither opens the key log nor rebinds
+	// the caller's certificate source
+	cfg := *config.TLS
+	cfg.KeyLogFile = ""
+	var cert tls.Certificate
+	source := cfg.CertFile
+	switch {
+	case secretValues[secrets.KeyTLSCert] != nil:
+		var err error
+		cert, err = tls.X509KeyPair(secretValues[secrets.KeyTLSCert], secretValues[secrets.KeyTLSKey])
+		if err != nil {
+			r.add("tls", PreflightFail, "invalid TLS key pair from secrets: %v", err)
+			return
+		}
+		source = "secrets"
+		cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &cert, nil }
+	case cfg.GetCertificate == nil && cfg.CertFile != "" && cfg.KeyFile != "":
+		var err error
+		if cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			r.add("tls", PreflightFail, "failed to load server certificate: %v", err)
+			return
+		}
+	}
+	if _, err := cfg.BuildTLSConfig(); err != nil {
+		r.add("tls", PreflightFail, "%v", err)
+		return
+	}
+	if len(cert.Certificate) == 0 {
+		r.add("tls", PreflightOK, "certificate supplied at runtime")
+		return
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		r.add("tls", PreflightFail, "failed to parse certificate from %s: %v", source, err)
+		return
+	}
+	now := time.Now()
+	switch {
+	case now.Before(leaf.NotBefore):
+		r.add("tls", PreflightFail, "certificate from %s is not valid until %s", source, leaf.NotBefore.Format(time.RFC3339))
+	case now.After(leaf.NotAfter):
+		r.add("tls", PreflightFail, "certificate from %s expired at %s", source, leaf.NotAfter.Format(time.RFC3339))
+	case leaf.NotAfter.Sub(now) < preflightCertExpiryWarning:
+		r.add("tls", PreflightWarn, "certificate from %s expires at %s", source, leaf.NotAfter.Format(time.RFC3339))
+	default:
+		r.add("tls", PreflightOK, "certificate for %q from %s valid until %s, %d SNI certificates",
+			leaf.Subject.CommonName, source, leaf.NotAfter.Format(time.RFC3339), len(cfg.SNICertificates))
+	}
+}
+
+// preflightFileLimit checks that RLIMIT_NOFILE allows MaxConnections sockets
+// with headroom. Below MaxConnections the server cannot reach its configured
+// capacity.
+func preflightFileLimit(r *PreflightReport, config *Config) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		r.add("file_limit", PreflightWarn, "failed to read RLIMIT_NOFILE: %v", err)
+		return
+	}
+	if u := config.Ulimits; u != nil {
+		if err := NewResourceConstraints(config.logger()).ValidateResourceLimits(u); err != nil {
+			r.add("file_limit", PreflightFail, "invalid ULIMIT settings: %v", err)
+			return
+		}
+		// Start sets the soft limit, capped at the hard limit unless it has
+		// the privileges to raise that too
+		limit.Cur = min(u.MaxOpenFilesSoft, limit.Max)
+		if limit.Cur < u.MinOpenFiles {
+			r.add("file_limit", PreflightFail, "soft RLIMIT_NOFILE %d is below ULIMIT_MIN_OPEN_FILES %d (hard limit %d)",
+				limit.Cur, u.MinOpenFiles, limit.Max)
+			return
+		}
+	}
+	need := uint64(config.MaxConnections) + preflightFDHeadroom
+	switch {
+	case limit.Cur < uint64(config.MaxConnections):
+		r.add("file_limit", PreflightFail, "soft RLIMIT_NOFILE %d is below the %d connection limit (hard limit %d)",
+			limit.Cur, config.MaxConnections, limit.Max)
+	case limit.Cur < need:
+		r.add("file_limit", PreflightWarn, "soft RLIMIT_NOFILE %d leaves under %d descriptors beyond the %d connection limit",
+			limit.Cur, preflightFDHeadroom, config.MaxConnections)
+	default:
+		r.add("file_limit", PreflightOK, "soft RLIMIT_NOFILE %d covers the %d connection limit", limit.Cur, config.MaxConnections)
+	}
+}
+
+// preflightListeners binds and releases every address the server would
+// listen on.
+func preflightListeners(r *PreflightReport, config *Config) {
+	listen := func(name, addr string) {
+		l, err := config.listenerFactory()("tcp", addr)
+		if err != nil {
+			r.add(name, PreflightFail, "cannot listen on %s: %v", addr, err)
+			return
+		}
+		l.Close()
+		r.add(name, PreflightOK, "%s is free", addr)
+	}
+
+	if config.Listener != nil {
+		r.add("listen", PreflightOK, "listener supplied by the caller")
+	} else {
+		listen("listen", config.ListenAddr)
+	}
+	for _, lc := range config.Listeners {
+		listen("listen:"+lc.Name, lc.Addr)
+	}
+	if config.OpsListenAddr != "" {
+		listen("ops_listen", config.OpsListenAddr)
+	}
+}
+
+// preflightDataSource validates the tick source Start would use, reading
+// replay files and probing custom sources that implement DataSourceChecker.
+func preflightDataSource(ctx context.Context, r *PreflightReport, config *Config) {
+	name := config.dataSourceName()
+	var err error
+	switch {
+	case config.DataSource != nil:
+		checker, ok := config.DataSource.(DataSourceChecker)
+		if !ok {
+			r.add("data_source", PreflightOK, "%s (no connectivity check)", name)
+			return
+		}
+		err = checker.Check(ctx)
+	case config.Ingest != nil:
+		err = config.Ingest.Validate()
+	case config.Replay != nil:
+		if err = config.Replay.Validate(); err == nil {
+			_, err = config.Replay.load()
+		}
+	case config.Simulation != nil:
+		err = config.Simulation.Validate()
+	}
+	if err != nil {
+		r.add("data_source", PreflightFail, "%s: %v", name, err)
+		return
+	}
+	r.add("data_source", PreflightOK, "%s", name)
+}
+
+// preflightCredentials checks that built-in AUTH has a username and a usable
+// password or hash.
+func preflightCredentials(r *PreflightReport, config *Config, secretValues map[string][]byte) {
+	if config.Authenticator != nil {
+		r.add("credentials", PreflightOK, "custom authenticator %T", config.Authenticator)
+		return
+	}
+
+	authConfig := config.Auth
+	if authConfig == nil {
+		authConfig = auth.DefaultConfig()
+	}
+	creds := auth.Credentials{
+		Username:     authConfig.Username,
+		Password:     authConfig.Password,
+		PasswordHash: authConfig.PasswordHash,
+	}
+	if v, ok := secretValues[secrets.KeyStreamUser]; ok {
+		creds.Username = string(v)
+	}
+	if v, ok := secretValues[secrets.KeyStreamPass]; ok {
+		creds.Password = string(v)
+	}
+	if v, ok := secretValues[secrets.KeyStreamPassHash]; ok {
+		creds.PasswordHash = string(v)
+	}
+
+	switch {
+	case creds.Username == "":
+		r.add("credentials", PreflightFail, "STREAM_USER is not set")
+	case creds.Password == "" && creds.PasswordHash == "":
+		r.add("credentials", PreflightFail, "neither STREAM_PASS nor STREAM_PASS_HASH is set")
+	case authConfig.RequireChallenge && creds.Password == "":
+		r.add("credentials", PreflightFail, "AUTH_REQUIRE_CHALLENGE needs STREAM_PASS: challenge-response cannot use a hash")
+	case creds.PasswordHash != "":
+		if _, err := auth.VerifyPassword(creds.PasswordHash, ""); err != nil {
+			r.add("credentials", PreflightFail, "STREAM_PASS_HASH: %v", err)
+			return
+		}
+		r.add("credentials", PreflightOK, "user %q with password hash", creds.Username)
+	default:
+		r.add("credentials", PreflightOK, "user %q with password", creds.Username)
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+)
+
+func preflightConfig() *Config {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.OpsListenAddr = ""
+	config.TLS = nil
+	config.MaxConnections = 10
+	config.Auth = &auth.Config{Username: "testuser", Password: "testpass"}
+	return config
+}
+
+func preflightStatus(r PreflightReport, name string) PreflightStatus {
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c.Status
+		}
+	}
+	return ""
+}
+
+func TestPreflightPasses(t *testing.T) {
+	config := preflightConfig()
+	certFile, keyFile := generateTestCertificate(t)
+	config.TLS = &TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile,
+		MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS13}
+
+	report := Preflight(context.Background(), config)
+	assert.False(t, report.Failed(), "%+v", report.Checks)
+	for _, name := range []string{"validation_limits", "tls", "listen", "data_source", "credentials"} {
+		assert.Equal(t, PreflightOK, preflightStatus(report, name), name)
+	}
+
+	var out bytes.Buffer
+	_, err := report.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "preflight passed")
+}
+
+func TestPreflightReportsFailures(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	config := preflightConfig()
+	config.ListenAddr = busy.Addr().String()
+	config.Replay = &ReplayConfig{Path: filepath.Join(t.TempDir(), "missing.csv"), Speed: 1}
+	config.Auth = &auth.Config{Username: "testuser", PasswordHash: "not-a-hash"}
+	config.TLS = &TLSConfig{Enabled: true, CertFile: filepath.Join(t.TempDir(), "cert.pem"),
+		MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS13}
+	config.ProxyProtocol = true
+	config.Replication = &ReplicationConfig{Election: ElectionCluster}
+	config.ClusterAdvertiseAddr = "127.0.0.1:9090"
+
+	report := Preflight(context.Background(), config)
+	assert.True(t, report.Failed())
+	for _, name := range []string{"listen", "data_source", "credentials", "tls", "proxy_protocol", "replication"} {
+		assert.Equal(t, PreflightFail, preflightStatus(report, name), name)
+	}
+
+	var out bytes.Buffer
+	_, err = report.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "preflight failed: 6 of")
+}
+
+func TestPreflightCredentials(t *testing.T) {
+	hash, err := auth.HashPassword("testpass", auth.DefaultHashParams())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		auth *auth.Config
+		want PreflightStatus
+	}{
+		{"password", &auth.Config{Username: "u", Password: "p"}, PreflightOK},
+		{"hash", &auth.Config{Username: "u", PasswordHash: hash}, PreflightOK},
+		{"no user", &auth.Config{Password: "p"}, PreflightFail},
+		{"no secret", &auth.Config{Username: "u"}, PreflightFail},
+		{"challenge without password", &auth.Config{Username: "u", PasswordHash: hash, RequireChallenge: true}, PreflightFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := preflightConfig()
+			config.Auth = tt.auth
+			var r PreflightReport
+			preflightCredentials(&r, config, nil)
+			assert.Equal(t, tt.want, preflightStatus(r, "credentials"), "%+v", r.Checks)
+		})
+	}
+}
@@ -1,13 +1,16 @@
 package server
 
 import (
-	"fmt"
 	"net/http"
 	"runtime"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/buildinfo"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 )
 
 const (
@@ -19,9 +22,25 @@ const (
 type PrometheusMetrics struct {
 	// Connection metrics
 	activeConnections    *prometheus.GaugeVec
+	clientsByRegion      *prometheus.GaugeVec
+	listenerActive       *prometheus.GaugeVec
+	listenerConnections  *prometheus.CounterVec
 	totalConnections     *prometheus.CounterVec
 	connectionDuration   *prometheus.HistogramVec
 	connectionErrors     *prometheus.CounterVec
+	connectionPanics     *prometheus.CounterVec
+	connectionsShed      *prometheus.CounterVec
+	connectionsDrained   *prometheus.CounterVec
+	acceptRejected       *prometheus.CounterVec
+	acceptErrors         *prometheus.CounterVec
+	acceptLoopExits      *prometheus.CounterVec
+	tlsHandshakeFailures *prometheus.CounterVec
+	tlsSNIHandshakes     *prometheus.CounterVec
+	
+	// Tenant metrics
+	tenantConnections    *prometheus.GaugeVec
+	tenantRejected       *prometheus.CounterVec
+	tenantTicks          *prometheus.CounterVec
 	
 	// Message metrics
 	messagesSentTotal    *prometheus.CounterVec
@@ -33,18 +52,24 @@ type PrometheusMetrics struct {
 	publishLatency       prometheus.Histogram
 	writeLatency         prometheus.Histogram
 	messageProcessingDuration prometheus.Histogram
-	writeTimeouts        prometheus.Counter
-	writeDeadlineExceeded prometheus.Counter
+	writeQueueDepth      *prometheus.HistogramVec
+	writeQueueDrain      *prometheus.HistogramVec
+	writeQueueHighWater  *prometheus.HistogramVec
 	
 	// Authentication metrics
 	authSuccess          *prometheus.CounterVec
 	authFailures         *prometheus.CounterVec
 	authRateLimited      prometheus.Counter
+	authLockouts         *prometheus.CounterVec
+	reauths              *prometheus.CounterVec
 	
 	// Heartbeat metrics
 	heartbeatTimeouts    prometheus.Counter
 	heartbeatSent        *prometheus.CounterVec
+	keepalivesSent       *prometheus.CounterVec
 	heartbeatsRecv       prometheus.Counter
+	heartbeatRTT         prometheus.Histogram
+	clockSkew            prometheus.Histogram
 	
 	// Error metrics
 	errorsByType         *prometheus.CounterVec
@@ -54,10 +79,17 @@ type PrometheusMetrics struct {
 	memoryUsage          prometheus.Gauge
 	goroutineCount       prometheus.Gauge
 	gcDuration           prometheus.Histogram
+	buildInfo            *prometheus.GaugeVec
 	
 	// Business metrics
 	subscriptionCount    *prometheus.GaugeVec
 	messagesSent         *prometheus.CounterVec
+	symbolTicksPublished *prometheus.CounterVec
+	symbolBytesPublished *prometheus.CounterVec
+	corruptBatches       *prometheus.CounterVec
+	streamStatusChanges  *prometheus.CounterVec
+	historyRequests      *prometheus.CounterVec
+	symbols              *symbolLabels
 	
 	// Pool metrics
 	framePoolHits        prometheus.Counter
@@ -66,6 +98,7 @@ type PrometheusMetrics struct {
 	bufferPoolMisses     prometheus.Counter
 	
 	registry *prometheus.Registry
+	catalog  metricCatalog
 }
 
 // NewPrometheusMetrics creates a new PrometheusMetrics instance.
@@ -94,7 +127,7 @@ func NewPrometheusMetricsWithRegistry(registry *prometheus.Registry) *Prometheus
 
 func (pm *PrometheusMetrics) initializeMetrics() {
 	// Connection metrics
-	pm.activeConnections = prometheus.NewGaugeVec(
+	pm.activeConnections = pm.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "tick_storm_active_connections",
 			Help: "Number of active connections",
@@ -102,7 +135,111 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id"},
 	)
 	
-	pm.totalConnections = prometheus.NewCounterVec(
+	pm.clientsByRegion = pm.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tick_storm_clients_by_region",
+			Help: "Number of active connections by GeoIP country code",
+		},
+		[]string{"instance_id", "region"},
+	)
+	
+	pm.listenerActive = pm.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tick_storm_listener_active_connections",
+			Help: "Number of active connections per listener",
+		},
+		[]string{"instance_id", "listener"},
+	)
+	
+	pm.listenerConnections = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_listener_connections_total",
+			Help: "Connections per listener by admission result",
+		},
+		[]string{"instance_id", "listener", "result"},
+	)
+	
+	pm.connectionsShed = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_connections_shed_total",
+			Help: "Connections closed with SERVER_BUSY to relieve a critical resource breach",
+		},
+		[]string{"instance_id", "resource", "class"},
+	)
+	
+	pm.connectionsDrained = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_connections_drained_total",
+			Help: "Connections told to reconnect elsewhere and closed by an admin cohort drain",
+		},
+		[]string{"instance_id", "class"},
+	)
+	
+	pm.acceptRejected = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_accept_rejected_total",
+			Help: "Connections closed on accept for exceeding the global accept rate",
+		},
+		[]string{"instance_id", "listener"},
+	)
+	
+	pm.acceptErrors = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_accept_errors_total",
+			Help: "Failed accepts retried after a backoff, by reason (fd_exhausted, aborted, timeout, other)",
+		},
+		[]string{"instance_id", "listener", "reason"},
+	)
+	
+	pm.acceptLoopExits = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_accept_loop_exits_total",
+			Help: "Listeners that stopped accepting connections, by reason (shutdown, listener_closed)",
+		},
+		[]string{"instance_id", "listener", "reason"},
+	)
+	
+	pm.tlsHandshakeFailures = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_tls_handshake_failures_total",
+			Help: "TLS handshakes abandoned by reason: timeout, capacity (concurrency cap reached) or error",
+		},
+		[]string{"instance_id", "reason"},
+	)
+	
+	pm.tlsSNIHandshakes = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_tls_sni_handshakes_total",
+			Help: "Completed TLS handshakes by the SNI certificate host served, or default",
+		},
+		[]string{"instance_id", "server_name"},
+	)
+	
+	pm.tenantConnections = pm.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tick_storm_tenant_connections",
+			Help: "Authenticated connections per tenant",
+		},
+		[]string{"instance_id", "tenant"},
+	)
+	
+	pm.tenantRejected = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_tenant_rejected_total",
+			Help: "Sessions refused by tenant limits, by reason: quota or rate",
+		},
+		[]string{"instance_id", "tenant", "reason"},
+	)
+	
+	pm.tenantTicks = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_tenant_ticks_delivered_total",
+			Help: "Ticks delivered to each tenant's connections",
+		},
+		[]string{"instance_id", "tenant"},
+	)
+	
+	pm.totalConnections = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_total_connections_total",
 			Help: "Total number of connections processed",
@@ -110,7 +247,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id"},
 	)
 	
-	pm.connectionDuration = prometheus.NewHistogramVec(
+	pm.connectionDuration = pm.newHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "tick_storm_connection_duration_seconds",
 			Help:    "Connection duration in seconds",
@@ -119,7 +256,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id"},
 	)
 	
-	pm.connectionErrors = prometheus.NewCounterVec(
+	pm.connectionErrors = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_connection_errors_total",
 			Help: "Number of connection errors",
@@ -127,8 +264,16 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id", "error_type"},
 	)
 	
+	pm.connectionPanics = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_connection_panics_total",
+			Help: "Panics recovered in connection goroutines, by goroutine",
+		},
+		[]string{"instance_id", "goroutine"},
+	)
+	
 	// Message metrics
-	pm.messagesSentTotal = prometheus.NewCounterVec(
+	pm.messagesSentTotal = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_messages_sent_total",
 			Help: "Total messages sent by type",
@@ -136,7 +281,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"message_type", "subscription_mode"},
 	)
 	
-	pm.messagesRecvTotal = prometheus.NewCounterVec(
+	pm.messagesRecvTotal = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_messages_recv_total",
 			Help: "Total messages received by type",
@@ -144,7 +289,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"message_type"},
 	)
 	
-	pm.bytesSentTotal = prometheus.NewCounterVec(
+	pm.bytesSentTotal = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_bytes_sent_total",
 			Help: "Total bytes sent",
@@ -152,7 +297,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"connection_type"},
 	)
 	
-	pm.bytesRecvTotal = prometheus.NewCounterVec(
+	pm.bytesRecvTotal = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_bytes_recv_total",
 			Help: "Total bytes received",
@@ -161,7 +306,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 	)
 	
 	// Performance metrics
-	pm.publishLatency = prometheus.NewHistogram(
+	pm.publishLatency = pm.newHistogram(
 		prometheus.HistogramOpts{
 			Name:    "tick_storm_publish_latency_seconds",
 			Help:    "Latency of publish operations in seconds",
@@ -169,7 +314,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		},
 	)
 	
-	pm.writeLatency = prometheus.NewHistogram(
+	pm.writeLatency = pm.newHistogram(
 		prometheus.HistogramOpts{
 			Name:    "tick_storm_write_latency_seconds",
 			Help:    "Write latency in seconds",
@@ -177,30 +322,43 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		},
 	)
 	
-	pm.messageProcessingDuration = prometheus.NewHistogram(
+	pm.writeQueueDepth = pm.newHistogramVec(
 		prometheus.HistogramOpts{
-			Name:    "tick_storm_message_processing_duration_seconds",
-			Help:    "Message processing duration in seconds",
-			Buckets: prometheus.ExponentialBuckets(0.001, 2, 10),
+			Name:    "tick_storm_write_queue_depth",
+			Help:    "Frames in a connection's write queue after each batch is queued",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 11),
 		},
+		[]string{"instance_id"},
 	)
 	
-	pm.writeTimeouts = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Name: "tick_storm_write_timeouts_total",
-			Help: "Total write timeouts",
+	pm.writeQueueDrain = pm.newHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tick_storm_write_queue_drain_seconds",
+			Help:    "Estimated time to drain a connection's write queue after each batch is queued",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 15),
 		},
+		[]string{"instance_id"},
 	)
 	
-	pm.writeDeadlineExceeded = prometheus.NewCounter(
-		prometheus.CounterOpts{
-			Name: "tick_storm_write_deadline_exceeded_total",
-			Help: "Total write deadline exceeded errors",
+	pm.writeQueueHighWater = pm.newHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tick_storm_write_queue_high_water",
+			Help:    "Deepest a connection's write queue got, observed when the connection closes",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+		},
+		[]string{"instance_id"},
+	)
+	
+	pm.messageProcessingDuration = pm.newHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tick_storm_message_processing_duration_seconds",
+			Help:    "Message processing duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 10),
 		},
 	)
 	
 	// Authentication metrics
-	pm.authSuccess = prometheus.NewCounterVec(
+	pm.authSuccess = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_auth_success_total",
 			Help: "Number of successful authentications",
@@ -208,7 +366,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id"},
 	)
 	
-	pm.authFailures = prometheus.NewCounterVec(
+	pm.authFailures = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_auth_failures_total",
 			Help: "Number of authentication failures",
@@ -216,22 +374,38 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id", "reason"},
 	)
 	
-	pm.authRateLimited = prometheus.NewCounter(
+	pm.authRateLimited = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_auth_rate_limited_total",
 			Help: "Total rate limited authentication attempts",
 		},
 	)
 	
+	pm.authLockouts = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_auth_lockouts_total",
+			Help: "Usernames locked out after consecutive authentication failures",
+		},
+		[]string{"instance_id"},
+	)
+	
+	pm.reauths = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_reauth_total",
+			Help: "Session reauthentications by result (requested, succeeded, failed, expired)",
+		},
+		[]string{"instance_id", "result"},
+	)
+	
 	// Heartbeat metrics
-	pm.heartbeatTimeouts = prometheus.NewCounter(
+	pm.heartbeatTimeouts = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_heartbeat_timeouts_total",
 			Help: "Total heartbeat timeouts",
 		},
 	)
 	
-	pm.heartbeatSent = prometheus.NewCounterVec(
+	pm.heartbeatSent = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_heartbeat_sent_total",
 			Help: "Number of heartbeats sent",
@@ -239,15 +413,39 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id"},
 	)
 	
-	pm.heartbeatsRecv = prometheus.NewCounter(
+	pm.keepalivesSent = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_keepalives_sent_total",
+			Help: "KEEPALIVE frames sent to idle connections",
+		},
+		[]string{"instance_id"},
+	)
+	
+	pm.heartbeatsRecv = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_heartbeats_recv_total",
 			Help: "Total heartbeats received",
 		},
 	)
 	
+	pm.heartbeatRTT = pm.newHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tick_storm_heartbeat_rtt_seconds",
+			Help:    "Client round-trip time measured over heartbeat exchanges in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		},
+	)
+	
+	pm.clockSkew = pm.newHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tick_storm_client_clock_skew_seconds",
+			Help:    "Absolute client clock skew relative to the server in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+	)
+	
 	// Error metrics
-	pm.errorsByType = prometheus.NewCounterVec(
+	pm.errorsByType = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_errors_total",
 			Help: "Total errors by type",
@@ -255,7 +453,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"error_type", "error_code"},
 	)
 	
-	pm.protocolErrors = prometheus.NewCounterVec(
+	pm.protocolErrors = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_protocol_errors_total",
 			Help: "Number of protocol errors",
@@ -264,21 +462,21 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 	)
 	
 	// Resource metrics
-	pm.memoryUsage = prometheus.NewGauge(
+	pm.memoryUsage = pm.newGauge(
 		prometheus.GaugeOpts{
 			Name: "tick_storm_memory_usage_bytes",
 			Help: "Current memory usage in bytes",
 		},
 	)
 	
-	pm.goroutineCount = prometheus.NewGauge(
+	pm.goroutineCount = pm.newGauge(
 		prometheus.GaugeOpts{
 			Name: "tick_storm_goroutines",
 			Help: "Current number of goroutines",
 		},
 	)
 	
-	pm.gcDuration = prometheus.NewHistogram(
+	pm.gcDuration = pm.newHistogram(
 		prometheus.HistogramOpts{
 			Name:    "tick_storm_gc_duration_seconds",
 			Help:    "Garbage collection duration in seconds",
@@ -286,8 +484,18 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		},
 	)
 	
+	pm.buildInfo = pm.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tick_storm_build_info",
+			Help: "Build the server was compiled from; always 1",
+		},
+		[]string{"version", "commit", "build_date", "go_version"},
+	)
+	info := buildinfo.Get()
+	pm.buildInfo.WithLabelValues(info.Version, info.Commit, info.BuildDate, info.GoVersion).Set(1)
+	
 	// Business metrics
-	pm.subscriptionCount = prometheus.NewGaugeVec(
+	pm.subscriptionCount = pm.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "tick_storm_subscriptions_current",
 			Help: "Current number of subscriptions",
@@ -295,7 +503,7 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id", "symbol"},
 	)
 	
-	pm.messagesSent = prometheus.NewCounterVec(
+	pm.messagesSent = pm.newCounterVec(
 		prometheus.CounterOpts{
 			Name: "tick_storm_business_messages_sent_total",
 			Help: "Total messages sent to clients",
@@ -303,29 +511,70 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 		[]string{"instance_id", "symbol"},
 	)
 	
+	pm.symbolTicksPublished = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_symbol_ticks_published_total",
+			Help: "Ticks published to clients by symbol",
+		},
+		[]string{"symbol"},
+	)
+	
+	pm.symbolBytesPublished = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_symbol_bytes_published_total",
+			Help: "Encoded tick bytes published to clients by symbol",
+		},
+		[]string{"symbol"},
+	)
+	pm.symbols = newSymbolLabels(nil, DefaultConfig().MetricsMaxSymbols)
+	
+	pm.corruptBatches = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_corrupt_batches_total",
+			Help: "Outbound batches dropped by outbound validation, by data source",
+		},
+		[]string{"instance_id", "source"},
+	)
+	
+	pm.streamStatusChanges = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_stream_status_changes_total",
+			Help: "Symbol stream condition changes reported by the data source, by new state (live, halted, stale)",
+		},
+		[]string{"instance_id", "state"},
+	)
+	
+	pm.historyRequests = pm.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "tick_storm_history_requests_total",
+			Help: "HISTORY requests by result (served, empty, refused)",
+		},
+		[]string{"instance_id", "result"},
+	)
+	
 	// Pool metrics
-	pm.framePoolHits = prometheus.NewCounter(
+	pm.framePoolHits = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_frame_pool_hits_total",
 			Help: "Total frame pool hits",
 		},
 	)
 	
-	pm.framePoolMisses = prometheus.NewCounter(
+	pm.framePoolMisses = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_frame_pool_misses_total",
 			Help: "Total frame pool misses",
 		},
 	)
 	
-	pm.bufferPoolHits = prometheus.NewCounter(
+	pm.bufferPoolHits = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_buffer_pool_hits_total",
 			Help: "Total buffer pool hits",
 		},
 	)
 	
-	pm.bufferPoolMisses = prometheus.NewCounter(
+	pm.bufferPoolMisses = pm.newCounter(
 		prometheus.CounterOpts{
 			Name: "tick_storm_buffer_pool_misses_total",
 			Help: "Total buffer pool misses",
@@ -336,31 +585,57 @@ func (pm *PrometheusMetrics) initializeMetrics() {
 func (pm *PrometheusMetrics) registerMetrics() {
 	pm.registry.MustRegister(
 		pm.activeConnections,
+		pm.clientsByRegion,
+		pm.listenerActive,
+		pm.listenerConnections,
 		pm.totalConnections,
 		pm.connectionDuration,
 		pm.connectionErrors,
+		pm.connectionPanics,
+		pm.connectionsShed,
+		pm.connectionsDrained,
+		pm.acceptRejected,
+		pm.acceptErrors,
+		pm.acceptLoopExits,
+		pm.tlsHandshakeFailures,
+		pm.tlsSNIHandshakes,
+		pm.tenantConnections,
+		pm.tenantRejected,
+		pm.tenantTicks,
 		pm.messagesSentTotal,
 		pm.messagesRecvTotal,
 		pm.bytesSentTotal,
 		pm.bytesRecvTotal,
 		pm.publishLatency,
 		pm.writeLatency,
+		pm.writeQueueDepth,
+		pm.writeQueueDrain,
+		pm.writeQueueHighWater,
 		pm.messageProcessingDuration,
-		pm.writeTimeouts,
-		pm.writeDeadlineExceeded,
 		pm.authSuccess,
 		pm.authFailures,
 		pm.authRateLimited,
+		pm.authLockouts,
+		pm.reauths,
 		pm.heartbeatTimeouts,
 		pm.heartbeatSent,
+		pm.keepalivesSent,
 		pm.heartbeatsRecv,
+		pm.heartbeatRTT,
+		pm.clockSkew,
 		pm.errorsByType,
 		pm.protocolErrors,
 		pm.memoryUsage,
 		pm.goroutineCount,
 		pm.gcDuration,
+		pm.buildInfo,
 		pm.subscriptionCount,
 		pm.messagesSent,
+		pm.symbolTicksPublished,
+		pm.symbolBytesPublished,
+		pm.corruptBatches,
+		pm.streamStatusChanges,
+		pm.historyRequests,
 		pm.framePoolHits,
 		pm.framePoolMisses,
 		pm.bufferPoolHits,
@@ -377,6 +652,295 @@ func (pm *PrometheusMetrics) DecrementActiveConnections(instanceID string) {
 	pm.activeConnections.WithLabelValues(instanceID).Dec()
 }
 
+func (pm *PrometheusMetrics) IncrementClientsByRegion(instanceID, region string) {
+	pm.clientsByRegion.WithLabelValues(instanceID, region).Inc()
+}
+
+func (pm *PrometheusMetrics) DecrementClientsByRegion(instanceID, region string) {
+	pm.clientsByRegion.WithLabelValues(instanceID, region).Dec()
+}
+
+func (pm *PrometheusMetrics) IncrementListenerActiveConnections(instanceID, listener string) {
+	pm.listenerActive.WithLabelValues(instanceID, listener).Inc()
+}
+
+func (pm *PrometheusMetrics) DecrementListenerActiveConnections(instanceID, listener string) {
+	pm.listenerActive.WithLabelValues(instanceID, listener).Dec()
+}
+
+func (pm *PrometheusMetrics) IncrementListenerConnections(instanceID, listener, result string) {
+	pm.listenerConnections.WithLabelValues(instanceID, listener, result).Inc()
+}
+
+// RegisterQoSMetrics exposes per-priority-class queue depth, connection
+// counts and drops, read from the scheduler at scrape time.
+func (pm *PrometheusMetrics) RegisterQoSMetrics(instanceID string, sched *QoSScheduler, usage func() map[PriorityClass]ClassUsage) {
+	for _, class := range PriorityClasses {
+		class := class
+		labels := prometheus.Labels{"instance_id": instanceID, "class": string(class)}
+		pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+			Name:        "tick_storm_qos_connections",
+			Help:        "Authenticated connections per priority class",
+			ConstLabels: labels,
+		}, func() float64 { return float64(usage()[class].Connections) }))
+		pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+			Name:        "tick_storm_qos_queue_depth",
+			Help:        "Frames waiting in write queues per priority class",
+			ConstLabels: labels,
+		}, func() float64 { return float64(usage()[class].QueueDepth) }))
+		pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+			Name:        "tick_storm_qos_dropped_total",
+			Help:        "Writes refused by backpressure per priority class",
+			ConstLabels: labels,
+		}, func() float64 { return float64(sched.Dropped(class)) }))
+	}
+}
+
+// RegisterConnMemoryMetrics exposes aggregate and p99 per-connection memory,
+// read from the server at scrape time.
+func (pm *PrometheusMetrics) RegisterConnMemoryMetrics(instanceID string, stats func() ConnMemoryStats) {
+	labels := prometheus.Labels{"instance_id": instanceID}
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_connection_memory_bytes",
+		Help:        "Approximate memory held by all connections' write queues, pending batches and history",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().TotalBytes) }))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_connection_memory_p99_bytes",
+		Help:        "99th percentile of approximate memory held per connection",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().P99Bytes) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_connection_memory_budget_exceeded_total",
+		Help:        "Connections dropped as slow clients for exceeding their memory budget",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().BudgetExceeded) }))
+}
+
+// RegisterWriteErrorMetrics exports failed writes by class, read from the
+// server at scrape time.
+func (pm *PrometheusMetrics) RegisterWriteErrorMetrics(instanceID string, stats func() WriteErrorStats) {
+	labels := prometheus.Labels{"instance_id": instanceID}
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_write_deadline_exceeded_total",
+		Help:        "Frames dropped because their write deadline passed in the write queue",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().DeadlineExceeded) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_write_timeouts_total",
+		Help:        "Socket writes that ran past the write deadline",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Timeouts) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_write_queue_full_total",
+		Help:        "Frames refused because the connection's write queue was full",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().QueueFull) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_write_connection_resets_total",
+		Help:        "Socket writes failed because the peer reset or closed the connection",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().ConnectionResets) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_write_errors_other_total",
+		Help:        "Socket writes failed for any other reason",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Other) }))
+}
+
+// RegisterEgressMetrics exports how often and for how long egress shaping
+// and the global egress budget held frames back, read from the server at
+// scrape time.
+func (pm *PrometheusMetrics) RegisterEgressMetrics(instanceID string, stats func() EgressStats) {
+	labels := prometheus.Labels{"instance_id": instanceID}
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_egress_delayed_writes_total",
+		Help:        "Frames held back because the connection, user or tenant was over its egress rate",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().DelayedWrites) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_egress_delay_seconds_total",
+		Help:        "Total time frames were held back by egress shaping",
+		ConstLabels: labels,
+	}, func() float64 { return stats().Delay.Seconds() }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_global_egress_bytes_total",
+		Help:        "Bytes released by the global egress budget",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Global.Bytes) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_global_egress_delayed_writes_total",
+		Help:        "Frames that waited for their connection's fair share of the global egress budget",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Global.DelayedWrites) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_global_egress_delay_seconds_total",
+		Help:        "Total time frames waited for the global egress budget",
+		ConstLabels: labels,
+	}, func() float64 { return stats().Global.Delay.Seconds() }))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_global_egress_waiting",
+		Help:        "Frames currently waiting for the global egress budget",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Global.Waiting) }))
+}
+
+// RegisterBatchTunerMetrics exports the adaptive batch window, read at
+// scrape time.
+func (pm *PrometheusMetrics) RegisterBatchTunerMetrics(instanceID string, window func() time.Duration) {
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_batch_window_seconds",
+		Help:        "Default batch window currently chosen by adaptive batching",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID},
+	}, func() float64 { return window().Seconds() }))
+}
+
+// RegisterAuthLockoutMetrics exports the number of usernames currently
+// locked out, read at scrape time.
+func (pm *PrometheusMetrics) RegisterAuthLockoutMetrics(instanceID string, locked func() int) {
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_auth_locked_users",
+		Help:        "Usernames currently locked out by AUTH_LOCKOUT_THRESHOLD",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID},
+	}, func() float64 { return float64(locked()) }))
+}
+
+// RegisterTLSHandshakeMetrics exports the number of TLS handshakes in
+// progress, read at scrape time.
+func (pm *PrometheusMetrics) RegisterTLSHandshakeMetrics(instanceID string, inProgress func() int64) {
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_tls_handshakes_in_progress",
+		Help:        "TLS handshakes currently running, bounded by TLS_MAX_CONCURRENT_HANDSHAKES",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID},
+	}, func() float64 { return float64(inProgress()) }))
+}
+
+// RegisterConnectionStageMetrics exports how many connections are in each
+// lifecycle stage and how many ended in it, read from stages at scrape time.
+func (pm *PrometheusMetrics) RegisterConnectionStageMetrics(instanceID string, stages *StageTracker) {
+	for _, stage := range ConnStages {
+		stage := stage
+		pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+			Name:        "tick_storm_connections_in_stage",
+			Help:        "Connections currently in each lifecycle stage (connect, tls, auth, subscribe, streaming)",
+			ConstLabels: prometheus.Labels{"instance_id": instanceID, "stage": stage.String()},
+		}, func() float64 { return float64(stages.Active(stage)) }))
+		for _, reason := range StageEndReasons {
+			reason := reason
+			pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+				Name:        "tick_storm_connection_stage_ends_total",
+				Help:        "Connections that ended in each lifecycle stage, by reason (closed, error, timeout)",
+				ConstLabels: prometheus.Labels{"instance_id": instanceID, "stage": stage.String(), "reason": reason},
+			}, func() float64 { return float64(stages.Ended(stage, reason)) }))
+		}
+	}
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_connection_stage_violations_total",
+		Help:        "Out-of-order lifecycle stage transitions, each a protocol handling bug",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID},
+	}, func() float64 { return float64(stages.Violations()) }))
+}
+
+// RegisterClusterMetrics exports cluster membership counts, read from stats
+// at scrape time.
+func (pm *PrometheusMetrics) RegisterClusterMetrics(instanceID string, stats func() ClusterStats) {
+	statuses := map[string]func(ClusterStats) int{
+		MemberAlive:   func(s ClusterStats) int { return s.Alive },
+		MemberSuspect: func(s ClusterStats) int { return s.Suspect },
+		MemberDead:    func(s ClusterStats) int { return s.Dead },
+	}
+	for status, count := range statuses {
+		count := count
+		pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+			Name:        "tick_storm_cluster_members",
+			Help:        "Instances known by cluster gossip, this one included, by status (alive, suspect, dead)",
+			ConstLabels: prometheus.Labels{"instance_id": instanceID, "status": status},
+		}, func() float64 { return float64(count(stats())) }))
+	}
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_cluster_gossip_failures_total",
+		Help:        "Gossip exchanges with a peer or seed that failed",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID},
+	}, func() float64 { return float64(stats().GossipFailures) }))
+}
+
+// RegisterReplicationMetrics exports leader-elected ingest state, read from
+// stats at scrape time.
+func (pm *PrometheusMetrics) RegisterReplicationMetrics(instanceID string, stats func() ReplicationStats) {
+	labels := prometheus.Labels{"instance_id": instanceID}
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_ingest_leader",
+		Help:        "1 while this instance is the elected ingest leader pulling from the data source",
+		ConstLabels: labels,
+	}, func() float64 {
+		if stats().Leader {
+			return 1
+		}
+		return 0
+	}))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_replication_followers",
+		Help:        "Followers streaming replicated ticks from this instance as ingest leader",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().Followers) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_pulled_ticks_total",
+		Help:        "Ticks pulled from the data source as ingest leader",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().PulledTicks) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_received_ticks_total",
+		Help:        "Ticks received from the ingest leader as follower",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().ReplicatedTicks) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_followers_dropped_total",
+		Help:        "Followers disconnected for falling INGEST_FOLLOWER_BUFFER batches behind",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().DroppedFollowers) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_stream_errors_total",
+		Help:        "Replication streams from the ingest leader that failed or broke",
+		ConstLabels: labels,
+	}, func() float64 { return float64(stats().StreamErrors) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_syncs_total",
+		Help:        "Follower streams opened on this instance as ingest leader, resumed from the backlog or started over from the full state",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID, "kind": "resume"},
+	}, func() float64 { return float64(stats().Resumes) }))
+	pm.registry.Register(pm.newCounterFunc(prometheus.CounterOpts{
+		Name:        "tick_storm_replication_syncs_total",
+		Help:        "Follower streams opened on this instance as ingest leader, resumed from the backlog or started over from the full state",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID, "kind": "resync"},
+	}, func() float64 { return float64(stats().Resyncs) }))
+}
+
+// RegisterSLOMetrics exports delivery SLO compliance, read from status at
+// scrape time.
+func (pm *PrometheusMetrics) RegisterSLOMetrics(instanceID string, status func() SLOStatus) {
+	labels := prometheus.Labels{"instance_id": instanceID}
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_slo_success_ratio",
+		Help:        "Fraction of batches delivered within the SLO latency threshold over the SLO window",
+		ConstLabels: labels,
+	}, func() float64 { return status().SuccessRatio }))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_slo_error_budget_remaining",
+		Help:        "Fraction of the SLO window's error budget left; negative once overspent",
+		ConstLabels: labels,
+	}, func() float64 { return status().ErrorBudgetRemaining }))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_slo_burn_rate",
+		Help:        "Error rate as a multiple of the rate the SLO allows, over the whole SLO window or the last 5m",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID, "window": "slo"},
+	}, func() float64 { return status().BurnRate }))
+	pm.registry.Register(pm.newGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tick_storm_slo_burn_rate",
+		Help:        "Error rate as a multiple of the rate the SLO allows, over the whole SLO window or the last 5m",
+		ConstLabels: prometheus.Labels{"instance_id": instanceID, "window": "5m"},
+	}, func() float64 { return status().FastBurnRate }))
+}
+
 func (pm *PrometheusMetrics) IncrementTotalConnections(instanceID string) {
 	pm.totalConnections.WithLabelValues(instanceID).Inc()
 }
@@ -389,7 +953,57 @@ func (pm *PrometheusMetrics) IncrementConnectionErrors(instanceID, errorType str
 	pm.connectionErrors.WithLabelValues(instanceID, errorType).Inc()
 }
 
+// IncrementConnectionPanics counts a panic recovered in a connection's
+// goroutine.
+func (pm *PrometheusMetrics) IncrementConnectionPanics(instanceID, goroutine string) {
+	pm.connectionPanics.WithLabelValues(instanceID, goroutine).Inc()
+}
+
 // Authentication metric methods
+func (pm *PrometheusMetrics) IncrementConnectionsShed(instanceID, resource string, class PriorityClass) {
+	pm.connectionsShed.WithLabelValues(instanceID, resource, string(class)).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementConnectionsDrained(instanceID string, class PriorityClass) {
+	pm.connectionsDrained.WithLabelValues(instanceID, string(class)).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementAcceptRejected(instanceID, listener string) {
+	pm.acceptRejected.WithLabelValues(instanceID, listener).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementAcceptErrors(instanceID, listener, reason string) {
+	pm.acceptErrors.WithLabelValues(instanceID, listener, reason).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementAcceptLoopExits(instanceID, listener, reason string) {
+	pm.acceptLoopExits.WithLabelValues(instanceID, listener, reason).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementTLSHandshakeFailures(instanceID, reason string) {
+	pm.tlsHandshakeFailures.WithLabelValues(instanceID, reason).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementTLSHandshakesBySNI(instanceID, serverName string) {
+	pm.tlsSNIHandshakes.WithLabelValues(instanceID, serverName).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementTenantConnections(instanceID, tenant string) {
+	pm.tenantConnections.WithLabelValues(instanceID, tenant).Inc()
+}
+
+func (pm *PrometheusMetrics) DecrementTenantConnections(instanceID, tenant string) {
+	pm.tenantConnections.WithLabelValues(instanceID, tenant).Dec()
+}
+
+func (pm *PrometheusMetrics) IncrementTenantRejected(instanceID, tenant, reason string) {
+	pm.tenantRejected.WithLabelValues(instanceID, tenant, reason).Inc()
+}
+
+func (pm *PrometheusMetrics) AddTenantTicks(instanceID, tenant string, ticks int) {
+	pm.tenantTicks.WithLabelValues(instanceID, tenant).Add(float64(ticks))
+}
+
 func (pm *PrometheusMetrics) IncrementAuthSuccess(instanceID string) {
 	pm.authSuccess.WithLabelValues(instanceID).Inc()
 }
@@ -402,6 +1016,14 @@ func (pm *PrometheusMetrics) IncrementAuthRateLimited(instanceID string) {
 	pm.authRateLimited.Inc()
 }
 
+func (pm *PrometheusMetrics) IncrementAuthLockouts(instanceID string) {
+	pm.authLockouts.WithLabelValues(instanceID).Inc()
+}
+
+func (pm *PrometheusMetrics) IncrementReauth(instanceID, result string) {
+	pm.reauths.WithLabelValues(instanceID, result).Inc()
+}
+
 // Message metric methods
 func (pm *PrometheusMetrics) IncrementMessagesSent(messageType, subscriptionMode string) {
 	pm.messagesSentTotal.WithLabelValues(messageType, subscriptionMode).Inc()
@@ -424,20 +1046,63 @@ func (pm *PrometheusMetrics) RecordPublishLatency(duration time.Duration) {
 	pm.publishLatency.Observe(duration.Seconds())
 }
 
+// RecordPublish observes the latency of a published batch, with traceID as
+// an exemplar when set, and counts its ticks and bytes per symbol.
+func (pm *PrometheusMetrics) RecordPublish(ticks []*pb.Tick, latency time.Duration, traceID string) {
+	if observer, ok := pm.publishLatency.(prometheus.ExemplarObserver); ok && traceID != "" {
+		observer.ObserveWithExemplar(latency.Seconds(), prometheus.Labels{"trace_id": traceID})
+	} else {
+		pm.publishLatency.Observe(latency.Seconds())
+	}
+	
+	for _, tick := range ticks {
+		symbol := pm.symbols.label(tick.Symbol)
+		pm.symbolTicksPublished.WithLabelValues(symbol).Inc()
+		pm.symbolBytesPublished.WithLabelValues(symbol).Add(float64(proto.Size(tick)))
+	}
+}
+
+// IncrementCorruptBatches counts an outbound batch from source that failed
+// validation.
+func (pm *PrometheusMetrics) IncrementCorruptBatches(instanceID, source string) {
+	pm.corruptBatches.WithLabelValues(instanceID, source).Inc()
+}
+
+// IncrementStreamStatusChanges counts a symbol entering state.
+func (pm *PrometheusMetrics) IncrementStreamStatusChanges(instanceID, state string) {
+	pm.streamStatusChanges.WithLabelValues(instanceID, state).Inc()
+}
+
+// IncrementHistoryRequests counts a HISTORY request by result.
+func (pm *PrometheusMetrics) IncrementHistoryRequests(instanceID, result string) {
+	pm.historyRequests.WithLabelValues(instanceID, result).Inc()
+}
+
+// SetSymbolLabels limits per-symbol metrics to symbols, or to the first max
+// symbols published when the list is empty.
+func (pm *PrometheusMetrics) SetSymbolLabels(symbols []string, max int) {
+	pm.symbols = newSymbolLabels(symbols, max)
+}
+
 func (pm *PrometheusMetrics) RecordWriteLatency(duration time.Duration) {
 	pm.writeLatency.Observe(duration.Seconds())
 }
 
-func (pm *PrometheusMetrics) RecordMessageProcessingDuration(duration time.Duration) {
-	pm.messageProcessingDuration.Observe(duration.Seconds())
+// ObserveWriteQueue records a connection's write queue depth and estimated
+// drain time.
+func (pm *PrometheusMetrics) ObserveWriteQueue(instanceID string, st WriteQueueStats) {
+	pm.writeQueueDepth.WithLabelValues(instanceID).Observe(float64(st.Depth))
+	pm.writeQueueDrain.WithLabelValues(instanceID).Observe(st.EstimatedDrain.Seconds())
 }
 
-func (pm *PrometheusMetrics) IncrementWriteTimeouts() {
-	pm.writeTimeouts.Inc()
+// ObserveWriteQueueHighWater records the deepest a closed connection's write
+// queue got.
+func (pm *PrometheusMetrics) ObserveWriteQueueHighWater(instanceID string, highWater int) {
+	pm.writeQueueHighWater.WithLabelValues(instanceID).Observe(float64(highWater))
 }
 
-func (pm *PrometheusMetrics) IncrementWriteDeadlineExceeded() {
-	pm.writeDeadlineExceeded.Inc()
+func (pm *PrometheusMetrics) RecordMessageProcessingDuration(duration time.Duration) {
+	pm.messageProcessingDuration.Observe(duration.Seconds())
 }
 
 // Heartbeat metric methods
@@ -449,10 +1114,22 @@ func (pm *PrometheusMetrics) IncrementHeartbeatSent(instanceID string) {
 	pm.heartbeatSent.WithLabelValues(instanceID).Inc()
 }
 
+func (pm *PrometheusMetrics) IncrementKeepalivesSent(instanceID string) {
+	pm.keepalivesSent.WithLabelValues(instanceID).Inc()
+}
+
 func (pm *PrometheusMetrics) IncrementHeartbeatsReceived() {
 	pm.heartbeatsRecv.Inc()
 }
 
+func (pm *PrometheusMetrics) ObserveHeartbeatTiming(rtt, skew time.Duration) {
+	pm.heartbeatRTT.Observe(rtt.Seconds())
+	if skew < 0 {
+		skew = -skew
+	}
+	pm.clockSkew.Observe(skew.Seconds())
+}
+
 // Error metric methods
 func (pm *PrometheusMetrics) IncrementErrorsByType(errorType, errorCode string) {
 	pm.errorsByType.WithLabelValues(errorType, errorCode).Inc()
@@ -501,17 +1178,10 @@ func (pm *PrometheusMetrics) IncrementBufferPoolMisses() {
 	pm.bufferPoolMisses.Inc()
 }
 
-// StartMetricsServer starts the Prometheus metrics HTTP server.
-func (pm *PrometheusMetrics) StartMetricsServer(port int) error {
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(pm.registry, promhttp.HandlerOpts{}))
-	
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
-	}
-	
-	return server.ListenAndServe()
+// Handler serves the registry in the Prometheus and OpenMetrics formats.
+func (pm *PrometheusMetrics) Handler() http.Handler {
+	// OpenMetrics is the only exposition format that carries exemplars
+	return promhttp.HandlerFor(pm.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
 }
 
 // StartMetricsCollector starts collecting system metrics periodically.
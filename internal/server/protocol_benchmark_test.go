@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// The benchmarks below drive a running server with the traffic it sees in
+// production: authenticated clients subscribe over TCP and read DATA_BATCH
+// frames while a producer publishes ticks. Nothing sleeps on the measured
+// path; batches flush as soon as a publish fills them.
+
+const (
+	benchProducer   = "bench-feed"
+	benchUser       = "bench"
+	benchPassword   = "bench-secret"
+	ticksPerPublish = 10
+)
+
+// startBenchServer starts a server on loopback that broadcasts the ticks
+// published to the returned hub.
+func startBenchServer(b *testing.B) (*Server, *IngestHub) {
+	b.Helper()
+	b.Setenv("STREAM_USER", benchUser)
+	b.Setenv("STREAM_PASS", benchPassword)
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	config.MaxBatchSize = ticksPerPublish
+	config.HeartbeatTimeout = time.Hour
+	config.Ingest = &IngestConfig{Producers: []ProducerConfig{{Name: benchProducer}}}
+	if config.TLS != nil {
+		config.TLS.Enabled = false
+	}
+
+	srv := NewServer(config)
+	require.NoError(b, srv.Start())
+	b.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Stop(ctx)
+	})
+	return srv, config.Ingest.source(config.clock())
+}
+
+// benchClient is a client connection speaking the frame protocol.
+type benchClient struct {
+	conn   net.Conn
+	reader *protocol.FrameReader
+	writer *protocol.FrameWriter
+}
+
+// dialBench connects client id from its own loopback address, so per-IP
+// limits see distinct hosts, falling back to the default address.
+func dialBench(b *testing.B, addr string, id int) *benchClient {
+	b.Helper()
+	d := net.Dialer{Timeout: 5 * time.Second, LocalAddr: &net.TCPAddr{IP: net.IPv4(127, byte(1+id/250), byte(id%250), 3)}}
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		conn, err = net.DialTimeout("tcp", addr, 5*time.Second)
+	}
+	require.NoError(b, err)
+	return &benchClient{conn: conn, reader: protocol.NewFrameReader(conn, 0), writer: protocol.NewFrameWriter(conn)}
+}
+
+// request sends msg and requires an ACK in reply.
+func (c *benchClient) request(b *testing.B, msgType protocol.MessageType, msg proto.Message) {
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	require.NoError(b, err)
+	require.NoError(b, c.writer.WriteFrame(frame))
+	reply, err := c.reader.ReadFrame()
+	require.NoError(b, err)
+	require.Equal(b, protocol.MessageTypeACK, reply.Type, "reply to message type %d", msgType)
+}
+
+// authSubscribe runs AUTH and SUBSCRIBE as client id.
+func (c *benchClient) authSubscribe(b *testing.B, id int) {
+	c.request(b, protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: benchUser,
+		Password: benchPassword,
+		ClientId: fmt.Sprintf("bench-%d", id),
+		Version:  "1.0.0",
+	})
+	c.request(b, protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+}
+
+// consume reads frames until the connection closes, signalling received
+// for every DATA_BATCH.
+func (c *benchClient) consume(received chan<- struct{}) {
+	for {
+		frame, err := c.reader.ReadFrame()
+		if err != nil {
+			return
+		}
+		if frame.Type == protocol.MessageTypeDataBatch {
+			received <- struct{}{}
+		}
+	}
+}
+
+// benchPublish returns a publish of ticksPerPublish fresh ticks.
+func benchPublish() *pb.PublishRequest {
+	req := &pb.PublishRequest{Ticks: make([]*pb.Tick, ticksPerPublish)}
+	for i := range req.Ticks {
+		req.Ticks[i] = ingestTick(fmt.Sprintf("SYM%d", i), pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	}
+	return req
+}
+
+// BenchmarkPublishFanout measures the time from a producer's publish until
+// every subscriber has read the resulting batch, and the allocations per
+// tick delivered across the server and clients.
+func BenchmarkPublishFanout(b *testing.B) {
+	for _, subscribers := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("Subscribers-%d", subscribers), func(b *testing.B) {
+			srv, hub := startBenchServer(b)
+			received := make(chan struct{}, subscribers)
+			for id := range subscribers {
+				c := dialBench(b, srv.ListenAddr(), id)
+				b.Cleanup(func() { c.conn.Close() })
+				c.authSubscribe(b, id)
+				go c.consume(received)
+			}
+			require.Eventually(b, func() bool {
+				return hub.GetStats()["subscribers"].(int) == subscribers
+			}, 5*time.Second, time.Millisecond)
+
+			latencies := make([]time.Duration, 0, b.N)
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				start := time.Now()
+				require.NoError(b, hub.Publish(benchProducer, benchPublish()))
+				for range subscribers {
+					<-received
+				}
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+
+			slices.Sort(latencies)
+			percentile := func(p float64) float64 {
+				return float64(latencies[int(p*float64(len(latencies)-1))].Nanoseconds())
+			}
+			ticks := float64(b.N * subscribers * ticksPerPublish)
+			b.ReportMetric(percentile(0.50), "p50-ns/publish")
+			b.ReportMetric(percentile(0.99), "p99-ns/publish")
+			b.ReportMetric(float64(after.Mallocs-before.Mallocs)/ticks, "allocs/tick")
+			b.ReportMetric(ticks/b.Elapsed().Seconds(), "ticks/s")
+		})
+	}
+}
+
+// BenchmarkSessionSetup measures a client's connect, AUTH and SUBSCRIBE
+// round trips.
+func BenchmarkSessionSetup(b *testing.B) {
+	srv, _ := startBenchServer(b)
+	addr := srv.ListenAddr()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		c := dialBench(b, addr, i)
+		c.authSubscribe(b, i)
+		c.conn.Close()
+	}
+}
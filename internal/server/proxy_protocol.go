@@ -0,0 +1,275 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidProxyHeader is returned when a trusted peer sends a malformed
+	// or missing PROXY protocol header.
+	ErrInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+
+	// ErrNoTrustedProxies is returned when PROXY protocol is enabled without
+	// trusted networks. Trusting every peer would let any client that reaches
+	// the port directly claim an arbitrary source address.
+	ErrNoTrustedProxies = errors.New("PROXY protocol requires trusted networks (PROXY_PROTOCOL_TRUSTED_CIDRS)")
+)
+
+// proxyV2Signature prefixes every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	proxyV1MaxLength = 107 // Longest valid v1 line, including CRLF
+	proxyV2HeaderLen = 16
+)
+
+// proxyListener wraps a listener whose peers (typically HAProxy or an NLB)
+// prefix each connection with a PROXY protocol v1 or v2 header.
+type proxyListener struct {
+	net.Listener
+	trusted []*net.IPNet // Never empty
+	timeout time.Duration
+}
+
+// newProxyListener wraps l. Only peers within trusted send headers; others are
+// passed through unchanged.
+func newProxyListener(l net.Listener, trusted []string, timeout time.Duration) (net.Listener, error) {
+	nets, err := parseProxyTrustedNetworks(trusted)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyListener{Listener: l, trusted: nets, timeout: timeout}, nil
+}
+
+// parseProxyTrustedNetworks parses the networks allowed to send PROXY
+// headers, of which there must be at least one.
+func parseProxyTrustedNetworks(trusted []string) ([]*net.IPNet, error) {
+	nets, err := parseCIDRList(trusted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol trusted networks: %w", err)
+	}
+	if len(nets) == 0 {
+		return nil, ErrNoTrustedProxies
+	}
+	return nets, nil
+}
+
+// Accept returns the next connection without reading its header, so a slow
+// peer cannot stall the accept loop.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.timeout}, nil
+}
+
+func (l *proxyListener) isTrusted(addr net.Addr) bool {
+	ip := hostIP(addr.String())
+	if ip == nil {
+		return false
+	}
+	for _, n := range l.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn reports the client addresses carried in the PROXY header. The
+// header is parsed on first use.
+type proxyConn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	err    error
+	remote net.Addr
+	local  net.Addr
+}
+
+// parse reads the PROXY header once, bounded by the header timeout.
+func (c *proxyConn) parse() error {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+			defer c.Conn.SetReadDeadline(time.Time{})
+		}
+		c.remote, c.local, c.err = readProxyHeader(c.reader)
+	})
+	return c.err
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.parse(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the peer's address
+// when the header carried none.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.parse() == nil && c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the header, if present.
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.parse() == nil && c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// checkProxyHeader forces header parsing for conn, unwrapping TLS if needed.
+// Connections not accepted through a proxyListener always pass.
+func checkProxyHeader(conn net.Conn) error {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.parse()
+	}
+	return nil
+}
+
+// unwrapProxyConn returns the raw connection beneath a proxyConn.
+func unwrapProxyConn(conn net.Conn) net.Conn {
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.Conn
+	}
+	return conn
+}
+
+// readProxyHeader parses a v1 or v2 header. Nil addresses mean the header
+// carried none (v1 UNKNOWN, v2 LOCAL, or a non-IP address family).
+func readProxyHeader(r *bufio.Reader) (src, dst net.Addr, err error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+	switch first[0] {
+	case 'P':
+		return readProxyV1(r)
+	case proxyV2Signature[0]:
+		return readProxyV2(r)
+	default:
+		return nil, nil, fmt.Errorf("%w: missing header", ErrInvalidProxyHeader)
+	}
+}
+
+// readProxyV1 parses "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n".
+func readProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, fmt.Errorf("%w: v1 line too long or not CRLF-terminated", ErrInvalidProxyHeader)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, nil, fmt.Errorf("%w: malformed v1 line", ErrInvalidProxyHeader)
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if (fields[1] != "TCP4" && fields[1] != "TCP6") || len(fields) != 6 {
+		return nil, nil, fmt.Errorf("%w: malformed v1 line", ErrInvalidProxyHeader)
+	}
+
+	src, err := proxyV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := proxyV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func proxyV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	p, err := strconv.ParseUint(port, 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("%w: bad address %s:%s", ErrInvalidProxyHeader, host, port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readProxyV2 parses the binary v2 header, skipping any TLVs.
+func readProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+	if !bytes.Equal(header[:12], proxyV2Signature) {
+		return nil, nil, fmt.Errorf("%w: bad v2 signature", ErrInvalidProxyHeader)
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyHeader, header[12]>>4)
+	}
+	command := header[12] & 0x0F
+	family := header[13] >> 4
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidProxyHeader, err)
+	}
+
+	switch command {
+	case 0x0: // LOCAL: health checks from the proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown command %d", ErrInvalidProxyHeader, command)
+	}
+
+	switch family {
+	case 0x1: // AF_INET
+		if length < 12 {
+			return nil, nil, fmt.Errorf("%w: short IPv4 address block", ErrInvalidProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))},
+			nil
+	case 0x2: // AF_INET6
+		if length < 36 {
+			return nil, nil, fmt.Errorf("%w: short IPv6 address block", ErrInvalidProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))},
+			nil
+	default: // AF_UNSPEC or AF_UNIX carry no usable client IP
+		return nil, nil, nil
+	}
+}
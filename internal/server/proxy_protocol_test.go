@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proxyV2Header builds a v2 PROXY header for TCP over IPv4 with an optional TLV block.
+func proxyV2Header(src, dst net.IP, sport, dport uint16, tlv []byte) []byte {
+	addrs := make([]byte, 12)
+	copy(addrs[0:4], src.To4())
+	copy(addrs[4:8], dst.To4())
+	binary.BigEndian.PutUint16(addrs[8:10], sport)
+	binary.BigEndian.PutUint16(addrs[10:12], dport)
+	addrs = append(addrs, tlv...)
+
+	h := append([]byte{}, proxyV2Signature...)
+	h = append(h, 0x21, 0x11, 0, 0) // v2 PROXY, AF_INET STREAM
+	binary.BigEndian.PutUint16(h[14:16], uint16(len(addrs)))
+	return append(h, addrs...)
+}
+
+func parseHeader(t *testing.T, raw string) (net.Addr, net.Addr, error) {
+	t.Helper()
+	return readProxyHeader(bufio.NewReader(strings.NewReader(raw)))
+}
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	src, dst, err := parseHeader(t, "PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\r\npayload")
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", src.String())
+	assert.Equal(t, "10.0.0.1:8080", dst.String())
+
+	src, _, err = parseHeader(t, "PROXY TCP6 2001:db8::7 2001:db8::1 4000 8080\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::7]:4000", src.String())
+
+	src, dst, err = parseHeader(t, "PROXY UNKNOWN\r\n")
+	require.NoError(t, err)
+	assert.Nil(t, src)
+	assert.Nil(t, dst)
+
+	for _, bad := range []string{
+		"PROXY TCP4 203.0.113.7 10.0.0.1 56324\r\n",
+		"PROXY TCP4 not-an-ip 10.0.0.1 1 2\r\n",
+		"PROXY TCP4 203.0.113.7 10.0.0.1 70000 8080\r\n",
+		"PROXY TCP4 203.0.113.7 10.0.0.1 1 2\n",
+		"PROXY " + strings.Repeat("x", 120) + "\r\n",
+		"GET / HTTP/1.1\r\n",
+	} {
+		_, _, err := parseHeader(t, bad)
+		assert.ErrorIs(t, err, ErrInvalidProxyHeader, bad)
+	}
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	tlv := []byte{0x04, 0x00, 0x02, 'h', 'i'} // PP2_TYPE_NOOP
+	raw := proxyV2Header(net.ParseIP("198.51.100.9"), net.ParseIP("10.0.0.1"), 41000, 8080, tlv)
+	r := bufio.NewReader(strings.NewReader(string(raw) + "rest"))
+
+	src, dst, err := readProxyHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.9:41000", src.String())
+	assert.Equal(t, "10.0.0.1:8080", dst.String())
+	rest, _ := io.ReadAll(r)
+	assert.Equal(t, "rest", string(rest), "TLVs are consumed with the header")
+
+	// LOCAL command carries no client address
+	local := append(append([]byte{}, proxyV2Signature...), 0x20, 0x00, 0, 0)
+	src, _, err = readProxyHeader(bufio.NewReader(strings.NewReader(string(local))))
+	require.NoError(t, err)
+	assert.Nil(t, src)
+
+	badVersion := append([]byte{}, raw...)
+	badVersion[12] = 0x31
+	_, _, err = readProxyHeader(bufio.NewReader(strings.NewReader(string(badVersion))))
+	assert.ErrorIs(t, err, ErrInvalidProxyHeader)
+
+	truncated := raw[:20]
+	_, _, err = readProxyHeader(bufio.NewReader(strings.NewReader(string(truncated))))
+	assert.ErrorIs(t, err, ErrInvalidProxyHeader)
+}
+
+func TestProxyListener(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := newProxyListener(base, []string{"127.0.0.0/8"}, time.Second)
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", base.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\r\nhello"))
+		time.Sleep(100 * time.Millisecond)
+	}()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, checkProxyHeader(conn))
+	assert.Equal(t, "203.0.113.7:56324", conn.RemoteAddr().String())
+	assert.Equal(t, "203.0.113.7", remoteIP(conn).String())
+	assert.IsType(t, &net.TCPConn{}, unwrapProxyConn(conn))
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+}
+
+func TestProxyListenerUntrustedPeer(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := newProxyListener(base, []string{"192.0.2.0/24"}, time.Second)
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		c, err := net.Dial("tcp", base.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 8080\r\n"))
+		time.Sleep(100 * time.Millisecond)
+	}()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Headers from untrusted peers are not honored
+	assert.NoError(t, checkProxyHeader(conn))
+	assert.Equal(t, "127.0.0.1", remoteIP(conn).String())
+}
+
+func TestProxyListenerHeaderTimeout(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err := newProxyListener(base, []string{"127.0.0.0/8"}, 50*time.Millisecond)
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", base.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.ErrorIs(t, checkProxyHeader(conn), ErrInvalidProxyHeader)
+	assert.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String(), "falls back to the peer address")
+}
+
+func TestNewProxyListenerInvalidCIDR(t *testing.T) {
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer base.Close()
+
+	_, err = newProxyListener(base, []string{"not-a-network"}, time.Second)
+	assert.Error(t, err)
+	_, err = newProxyListener(base, nil, time.Second)
+	assert.ErrorIs(t, err, ErrNoTrustedProxies, "every peer is never trusted")
+}
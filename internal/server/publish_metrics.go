@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// otherSymbol is the label for symbols without their own metric series.
+const otherSymbol = "other"
+
+// symbolLabels bounds the cardinality of per-symbol metrics. A configured
+// symbol list is used as-is; otherwise the first max distinct symbols seen
+// keep their own label for the life of the process.
+type symbolLabels struct {
+	mu      sync.RWMutex
+	allowed map[string]struct{}
+	fixed   bool
+	max     int
+}
+
+func newSymbolLabels(symbols []string, max int) *symbolLabels {
+	sl := &symbolLabels{
+		allowed: make(map[string]struct{}, len(symbols)),
+		fixed:   len(symbols) > 0,
+		max:     max,
+	}
+	for _, s := range symbols {
+		sl.allowed[s] = struct{}{}
+	}
+	return sl
+}
+
+// label returns the metric label for symbol.
+func (sl *symbolLabels) label(symbol string) string {
+	sl.mu.RLock()
+	_, ok := sl.allowed[symbol]
+	full := sl.fixed || len(sl.allowed) >= sl.max
+	sl.mu.RUnlock()
+	if ok {
+		return symbol
+	}
+	if full || symbol == otherSymbol {
+		return otherSymbol
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if _, ok := sl.allowed[symbol]; !ok {
+		if len(sl.allowed) >= sl.max {
+			return otherSymbol
+		}
+		sl.allowed[symbol] = struct{}{}
+	}
+	return symbol
+}
+
+// newTraceID returns a random W3C trace ID (32 lowercase hex digits).
+func newTraceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
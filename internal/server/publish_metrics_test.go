@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestSymbolLabelsBoundCardinality(t *testing.T) {
+	admitted := newSymbolLabels(nil, 2)
+	assert.Equal(t, "EURUSD", admitted.label("EURUSD"))
+	assert.Equal(t, "GBPUSD", admitted.label("GBPUSD"))
+	assert.Equal(t, otherSymbol, admitted.label("USDJPY"))
+	assert.Equal(t, "EURUSD", admitted.label("EURUSD"), "admitted symbols keep their label")
+
+	fixed := newSymbolLabels([]string{"USDJPY"}, 2)
+	assert.Equal(t, "USDJPY", fixed.label("USDJPY"))
+	assert.Equal(t, otherSymbol, fixed.label("EURUSD"), "a configured list admits nothing else")
+}
+
+func TestRecordPublishPerSymbolWithExemplar(t *testing.T) {
+	pm := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	pm.SetSymbolLabels([]string{"EURUSD"}, 10)
+
+	ticks := []*pb.Tick{{Symbol: "EURUSD", Price: 1.1}, {Symbol: "EURUSD", Price: 1.2}, {Symbol: "XAUUSD", Price: 2000}}
+	traceID := newTraceID()
+	require.Len(t, traceID, 32)
+	pm.RecordPublish(ticks, 3*time.Millisecond, traceID)
+
+	assert.Equal(t, 2.0, counterValue(t, pm.symbolTicksPublished.WithLabelValues("EURUSD")))
+	assert.Equal(t, 1.0, counterValue(t, pm.symbolTicksPublished.WithLabelValues(otherSymbol)))
+	assert.Positive(t, counterValue(t, pm.symbolBytesPublished.WithLabelValues("EURUSD")))
+
+	families, err := pm.registry.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, mf := range families {
+		if mf.GetName() != "tick_storm_publish_latency_seconds" {
+			continue
+		}
+		h := mf.GetMetric()[0].GetHistogram()
+		assert.Equal(t, uint64(1), h.GetSampleCount())
+		for _, b := range h.GetBucket() {
+			if ex := b.GetExemplar(); ex != nil {
+				found = true
+				assert.Equal(t, "trace_id", ex.GetLabel()[0].GetName())
+				assert.Equal(t, traceID, ex.GetLabel()[0].GetValue())
+			}
+		}
+	}
+	assert.True(t, found, "publish latency should carry a trace_id exemplar")
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PriorityClass ranks connections for scheduling under resource pressure.
+type PriorityClass string
+
+const (
+	PriorityGold   PriorityClass = "gold"
+	PrioritySilver PriorityClass = "silver"
+	PriorityBronze PriorityClass = "bronze"
+)
+
+// PriorityClasses lists every class from highest to lowest priority.
+var PriorityClasses = []PriorityClass{PriorityGold, PrioritySilver, PriorityBronze}
+
+// ParsePriorityClass parses a class name case-insensitively.
+func ParsePriorityClass(s string) (PriorityClass, error) {
+	class := PriorityClass(strings.ToLower(strings.TrimSpace(s)))
+	for _, c := range PriorityClasses {
+		if class == c {
+			return class, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority class %q", s)
+}
+
+// parseUserPriorities parses "username=class" entries.
+func parseUserPriorities(items []string) (map[string]PriorityClass, error) {
+	classes := make(map[string]PriorityClass, len(items))
+	for _, item := range items {
+		user, name, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(user) == "" {
+			return nil, fmt.Errorf("invalid user priority %q: expected username=class", item)
+		}
+		class, err := ParsePriorityClass(name)
+		if err != nil {
+			return nil, err
+		}
+		classes[strings.TrimSpace(user)] = class
+	}
+	return classes, nil
+}
+
+// QoSPolicy controls how a class is treated while the server is under pressure.
+type QoSPolicy struct {
+	QueueShare        float64 // Fraction of MaxWriteQueueSize the class may fill
+	BatchWindowFactor int     // Multiplier on the batch window, reducing writes
+	EgressWeight      float64 // Share of the global egress budget relative to other classes
+}
+
+// DefaultQoSPolicies returns the built-in per-class policies.
+func DefaultQoSPolicies() map[PriorityClass]QoSPolicy {
+	return map[PriorityClass]QoSPolicy{
+		PriorityGold:   {QueueShare: 1.0, BatchWindowFactor: 1, EgressWeight: 4},
+		PrioritySilver: {QueueShare: 0.5, BatchWindowFactor: 2, EgressWeight: 2},
+		PriorityBronze: {QueueShare: 0.25, BatchWindowFactor: 4, EgressWeight: 1},
+	}
+}
+
+// qosClass holds a class's policy and drop counter.
+type qosClass struct {
+	name    PriorityClass
+	policy  QoSPolicy
+	dropped uint64
+}
+
+// connPriority binds a connection to its scheduler and class.
+type connPriority struct {
+	sched *QoSScheduler
+	class *qosClass
+}
+
+// QoSScheduler assigns connections to priority classes and, while resources
+// are constrained, favors higher classes by shrinking the write queues and
+// stretching the batch windows of lower ones.
+type QoSScheduler struct {
+	classes      map[PriorityClass]*qosClass
+	users        map[string]PriorityClass
+	defaultClass PriorityClass
+	pressure     func() bool
+}
+
+// NewQoSScheduler creates a scheduler. pressure reports whether resources are
+// currently constrained; nil means never.
+func NewQoSScheduler(config *Config, pressure func() bool) (*QoSScheduler, error) {
+	users, err := parseUserPriorities(config.UserPriorityClasses)
+	if err != nil {
+		return nil, err
+	}
+	defaultClass := PrioritySilver
+	if config.DefaultPriorityClass != "" {
+		if defaultClass, err = ParsePriorityClass(string(config.DefaultPriorityClass)); err != nil {
+			return nil, err
+		}
+	}
+
+	policies := DefaultQoSPolicies()
+	for class, policy := range config.QoSPolicies {
+		policies[class] = policy
+	}
+
+	s := &QoSScheduler{
+		classes:      make(map[PriorityClass]*qosClass, len(PriorityClasses)),
+		users:        users,
+		defaultClass: defaultClass,
+		pressure:     pressure,
+	}
+	for _, class := range PriorityClasses {
+		s.classes[class] = &qosClass{name: class, policy: policies[class]}
+	}
+	return s, nil
+}
+
+// ClassFor returns the priority class configured for username.
+func (s *QoSScheduler) ClassFor(username string) PriorityClass {
+	if class, ok := s.users[username]; ok {
+		return class
+	}
+	return s.defaultClass
+}
+
+// constrained reports whether lower classes should currently yield.
+func (s *QoSScheduler) constrained() bool {
+	return s != nil && s.pressure != nil && s.pressure()
+}
+
+// queueLimit returns the write queue capacity available to class.
+func (s *QoSScheduler) queueLimit(class *qosClass, max int) int {
+	if class == nil || !s.constrained() {
+		return max
+	}
+	limit := int(float64(max) * class.policy.QueueShare)
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// batchWindow returns the batch window for class.
+func (s *QoSScheduler) batchWindow(class *qosClass, window time.Duration) time.Duration {
+	if class == nil || class.policy.BatchWindowFactor <= 1 || !s.constrained() {
+		return window
+	}
+	return window * time.Duration(class.policy.BatchWindowFactor)
+}
+
+// EgressWeight returns class's share of the global egress budget, or 1
+// without a scheduler or a weight for the class.
+func (s *QoSScheduler) EgressWeight(class PriorityClass) float64 {
+	if s == nil {
+		return 1
+	}
+	if c := s.classes[class]; c != nil && c.policy.EgressWeight > 0 {
+		return c.policy.EgressWeight
+	}
+	return 1
+}
+
+// Dropped returns the number of writes refused for class.
+func (s *QoSScheduler) Dropped(class PriorityClass) uint64 {
+	if c := s.classes[class]; c != nil {
+		return atomic.LoadUint64(&c.dropped)
+	}
+	return 0
+}
+
+// ClassUsage is a point-in-time view of one class's connections.
+type ClassUsage struct {
+	Connections int
+	QueueDepth  int64 // Frames queued across the class's connections
+}
+
+// GetStats returns per-class scheduling statistics merged with usage.
+func (s *QoSScheduler) GetStats(usage map[PriorityClass]ClassUsage) map[string]interface{} {
+	stats := map[string]interface{}{
+		"constrained":   s.constrained(),
+		"default_class": string(s.defaultClass),
+	}
+	for _, class := range PriorityClasses {
+		c := s.classes[class]
+		stats[string(class)] = map[string]interface{}{
+			"connections":   usage[class].Connections,
+			"queue_depth":   usage[class].QueueDepth,
+			"dropped_total": atomic.LoadUint64(&c.dropped),
+			"queue_share":   c.policy.QueueShare,
+			"egress_weight": c.policy.EgressWeight,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUserPriorities(t *testing.T) {
+	users, err := parseUserPriorities([]string{"alice=gold", " bob = Bronze"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]PriorityClass{"alice": PriorityGold, "bob": PriorityBronze}, users)
+
+	for _, bad := range []string{"alice", "=gold", "alice=platinum"} {
+		_, err := parseUserPriorities([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestQoSSchedulerClassFor(t *testing.T) {
+	config := DefaultConfig()
+	config.UserPriorityClasses = []string{"alice=gold"}
+	sched, err := NewQoSScheduler(config, nil)
+	require.NoError(t, err)
+	assert.Equal(t, PriorityGold, sched.ClassFor("alice"))
+	assert.Equal(t, PrioritySilver, sched.ClassFor("carol"))
+
+	config.DefaultPriorityClass = PriorityBronze
+	sched, err = NewQoSScheduler(config, nil)
+	require.NoError(t, err)
+	assert.Equal(t, PriorityBronze, sched.ClassFor("carol"))
+
+	config.DefaultPriorityClass = "platinum"
+	_, err = NewQoSScheduler(config, nil)
+	assert.Error(t, err)
+}
+
+func TestQoSSchedulerPressure(t *testing.T) {
+	var pressure atomic.Bool
+	sched, err := NewQoSScheduler(DefaultConfig(), pressure.Load)
+	require.NoError(t, err)
+	gold, bronze := sched.classes[PriorityGold], sched.classes[PriorityBronze]
+
+	// Unconstrained, every class gets the full queue and window
+	assert.Equal(t, 1000, sched.queueLimit(bronze, 1000))
+	assert.Equal(t, 10*time.Millisecond, sched.batchWindow(bronze, 10*time.Millisecond))
+
+	pressure.Store(true)
+	assert.Equal(t, 1000, sched.queueLimit(gold, 1000))
+	assert.Equal(t, 250, sched.queueLimit(bronze, 1000))
+	assert.Equal(t, 1, sched.queueLimit(bronze, 2), "a class always keeps one slot")
+	assert.Equal(t, 10*time.Millisecond, sched.batchWindow(gold, 10*time.Millisecond))
+	assert.Equal(t, 40*time.Millisecond, sched.batchWindow(bronze, 10*time.Millisecond))
+}
+
+func TestWriteFrameAsyncDropsByClass(t *testing.T) {
+	var pressure atomic.Bool
+	pressure.Store(true)
+
+	config := DefaultConfig()
+	config.MaxWriteQueueSize = 8
+	config.UserPriorityClasses = []string{"alice=gold", "bob=bronze"}
+	sched, err := NewQoSScheduler(config, pressure.Load)
+	require.NoError(t, err)
+
+	// Nobody reads the client side, so queued frames stay queued
+	fill := func(user string) int {
+		serverSide, clientSide := net.Pipe()
+		t.Cleanup(func() { clientSide.Close() })
+		conn := NewConnection(serverSide, config)
+		t.Cleanup(func() { conn.Close() })
+		conn.SetPriority(sched, sched.ClassFor(user))
+
+		accepted := 0
+		for i := 0; i < config.MaxWriteQueueSize; i++ {
+			if conn.WriteFrameAsync(&protocol.Frame{Type: protocol.MessageTypeHeartbeat}) == nil {
+				accepted++
+			}
+		}
+		return accepted
+	}
+
+	assert.Equal(t, 8, fill("alice"))
+	assert.Equal(t, 2, fill("bob"))
+	assert.Equal(t, uint64(0), sched.Dropped(PriorityGold))
+	assert.Equal(t, uint64(6), sched.Dropped(PriorityBronze))
+
+	stats := sched.GetStats(map[PriorityClass]ClassUsage{PriorityBronze: {Connections: 1, QueueDepth: 2}})
+	assert.Equal(t, true, stats["constrained"])
+	bronze := stats["bronze"].(map[string]interface{})
+	assert.Equal(t, 1, bronze["connections"])
+	assert.Equal(t, uint64(6), bronze["dropped_total"])
+}
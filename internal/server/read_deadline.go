@@ -0,0 +1,51 @@
+package server
+
+import (
+	"os"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// readTimeout returns how long the next frame read may block. Until the
+// client subscribes it is ReadTimeout. Afterwards a subscriber may only send
+// heartbeats, so the deadline follows the mode's override or, by default,
+// the heartbeat timeout plus one interval of grace; the heartbeat monitor
+// remains the primary liveness check.
+func (h *ConnectionHandler) readTimeout() time.Duration {
+	sub := h.conn.GetSubscription()
+	if sub == nil {
+		return h.config.ReadTimeout
+	}
+	if d := h.config.SubscribedReadTimeouts[sub.Mode]; d > 0 {
+		return d
+	}
+	return h.config.HeartbeatTimeout + h.config.HeartbeatInterval
+}
+
+// loadReadTimeoutsFromEnv reads READ_TIMEOUT and the per-mode
+// READ_TIMEOUT_SECOND / READ_TIMEOUT_MINUTE overrides.
+func loadReadTimeoutsFromEnv(cfg *Config) {
+	if v := os.Getenv("READ_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ReadTimeout = d
+		}
+	}
+
+	modes := map[string]pb.SubscriptionMode{
+		"READ_TIMEOUT_SECOND": pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+		"READ_TIMEOUT_MINUTE": pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+	}
+	for env, mode := range modes {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			if cfg.SubscribedReadTimeouts == nil {
+				cfg.SubscribedReadTimeouts = make(map[pb.SubscriptionMode]time.Duration)
+			}
+			cfg.SubscribedReadTimeouts[mode] = d
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestReadTimeoutFollowsSubscription(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = 5 * time.Second
+	config.SubscribedReadTimeouts = map[pb.SubscriptionMode]time.Duration{
+		pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE: 3 * time.Minute,
+	}
+
+	conn := &Connection{}
+	handler := &ConnectionHandler{conn: conn, config: config}
+	assert.Equal(t, 5*time.Second, handler.readTimeout(), "awaiting SUBSCRIBE")
+
+	require.NoError(t, conn.SetSubscription(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)))
+	assert.Equal(t, config.HeartbeatTimeout+config.HeartbeatInterval, handler.readTimeout(),
+		"no override derives from the heartbeat timeout")
+
+	minute := &ConnectionHandler{conn: &Connection{subscription: NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE)}, config: config}
+	assert.Equal(t, 3*time.Minute, minute.readTimeout())
+}
+
+func TestLoadReadTimeoutsFromEnv(t *testing.T) {
+	t.Setenv("READ_TIMEOUT", "10s")
+	t.Setenv("READ_TIMEOUT_SECOND", "45s")
+	t.Setenv("READ_TIMEOUT_MINUTE", "bogus")
+
+	cfg := DefaultConfig()
+	LoadConfigFromEnv(cfg)
+	assert.Equal(t, 10*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, map[pb.SubscriptionMode]time.Duration{
+		pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND: 45 * time.Second,
+	}, cfg.SubscribedReadTimeouts)
+}
+
+func TestIdleSubscriberOutlivesReadTimeout(t *testing.T) {
+	run := func(t *testing.T, config *Config) <-chan error {
+		serverSide, clientSide := net.Pipe()
+		conn := NewConnection(serverSide, config)
+		conn.SetAuthenticated(nil)
+		require.NoError(t, conn.SetSubscription(NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)))
+		go io.Copy(io.Discard, clientSide)
+		t.Cleanup(func() {
+			conn.Close()
+			clientSide.Close()
+		})
+
+		handler := NewConnectionHandler(conn, config)
+		handler.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		done := make(chan error, 1)
+		go func() { done <- handler.Handle(context.Background()) }()
+		return done
+	}
+
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now()) // Keep the heartbeat monitor out of the way
+	config.ReadTimeout = 20 * time.Millisecond
+
+	select {
+	case err := <-run(t, config):
+		t.Fatalf("subscriber without inbound traffic was dropped: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// A per-mode override still bounds the read
+	config = DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+	config.SubscribedReadTimeouts = map[pb.SubscriptionMode]time.Duration{
+		pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND: 20 * time.Millisecond,
+	}
+	select {
+	case err := <-run(t, config):
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("per-mode read timeout did not apply")
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+var (
+	// ErrSessionExpired is returned when a client does not authenticate
+	// again within ReauthGrace of being sent REAUTH_REQUIRED.
+	ErrSessionExpired = errors.New("session expired")
+
+	// ErrReauthFailed is returned when an AUTH frame renewing a session is
+	// refused. The client has already been sent the reason.
+	ErrReauthFailed = errors.New("reauthentication failed")
+
+	// errUsernameChanged refuses a reauthentication as a different user
+	errUsernameChanged = fmt.Errorf("%w: reauthentication must keep the username", auth.ErrInvalidCredentials)
+)
+
+// sessionExpiry returns the channel that fires when the session reaches
+// MaxSessionAge, or nil when sessions live as long as their connection.
+func (h *ConnectionHandler) sessionExpiry() <-chan time.Time {
+	if h.config.MaxSessionAge <= 0 {
+		return nil
+	}
+	h.sessionTimer = h.config.clock().NewTimer(h.config.MaxSessionAge)
+	return h.sessionTimer.C()
+}
+
+// handleSessionExpiry asks the client to authenticate again when the
+// session reaches its maximum age, and ends the connection when the grace
+// period passes without it.
+func (h *ConnectionHandler) handleSessionExpiry() error {
+	if h.reauthPending {
+		h.logger.Info("session expired without reauthentication", "grace", h.config.ReauthGrace)
+		h.countReauth("expired")
+		_ = h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED, "Session expired",
+			"no AUTH received within the reauthentication grace period")
+		return ErrSessionExpired
+	}
+
+	h.reauthPending = true
+	h.sessionTimer.Reset(h.config.ReauthGrace)
+	h.countReauth("requested")
+	h.logger.Info("session reached maximum age, reauthentication required",
+		"max_session_age", h.config.MaxSessionAge,
+		"grace", h.config.ReauthGrace,
+	)
+	return h.conn.SendInfo(pb.InfoCode_INFO_CODE_REAUTH_REQUIRED, "session reached its maximum age; send AUTH",
+		map[string]string{
+			"grace_ms": strconv.FormatInt(h.config.ReauthGrace.Milliseconds(), 10),
+		})
+}
+
+// handleAuth renews the session with an AUTH frame on an authenticated
+// connection, in answer to REAUTH_REQUIRED or ahead of it. Without a maximum
+// session age AUTH is only allowed as the first frame.
+func (h *ConnectionHandler) handleAuth(ctx context.Context, frame *protocol.Frame) error {
+	if h.server == nil || h.config == nil || h.config.MaxSessionAge <= 0 {
+		return protocol.ErrInvalidSequence
+	}
+	s := h.server
+	conn := h.conn
+	noteAuthRequest(conn, frame)
+	defer conn.setCorrelationID("")
+
+	// Challenge-response takes a second AUTH frame, which arrives through
+	// the dispatcher like this one
+	if s.config.Authenticator == nil && s.authenticator.WantsChallenge(frame) {
+		challenge, err := s.authenticator.IssueChallenge(conn.authPeer(), frame)
+		if err != nil {
+			s.rejectAuth(conn, err)
+			h.countReauth("failed")
+			return fmt.Errorf("%w: %v", ErrReauthFailed, err)
+		}
+		return conn.SendMessage(protocol.MessageTypeAuthChallenge, &pb.AuthChallenge{
+			Mechanism:   challenge.Mechanism,
+			Nonce:       challenge.Nonce,
+			TimestampMs: time.Now().UnixMilli(),
+		})
+	}
+
+	previous := conn.Session()
+	s.authenticator.ExpireSession(conn.ID())
+	session, err := s.authenticate(ctx, conn, frame)
+	if err == nil && previous != nil && session.Username != previous.Username {
+		// A connection keeps its identity: subscriptions and quotas were granted to it
+		s.authenticator.RemoveSession(conn.ID())
+		err = fmt.Errorf("%w: username changed from %q to %q", errUsernameChanged, previous.Username, session.Username)
+	}
+	if err != nil {
+		h.logger.Warn("reauthentication failed", "error", err)
+		s.rejectAuth(conn, err)
+		h.countReauth("failed")
+		return fmt.Errorf("%w: %v", ErrReauthFailed, err)
+	}
+	if previous != nil && session.ClientID == "" {
+		session.ClientID = previous.ClientID
+	}
+	conn.SetAuthenticated(session)
+
+	wasPending := h.reauthPending
+	h.reauthPending = false
+	if h.sessionTimer != nil {
+		// Drain an expiry that fired before the renewal, so it does not end
+		// the renewed session
+		if !h.sessionTimer.Stop() {
+			select {
+			case <-h.sessionTimer.C():
+			default:
+			}
+		}
+		h.sessionTimer.Reset(h.config.MaxSessionAge)
+	}
+	h.countReauth("succeeded")
+	h.logger.Info("session renewed", "requested", wasPending)
+
+	metadata := s.authAckMetadata()
+	metadata["connection_id"] = conn.ID()
+	metadata["max_session_age_ms"] = strconv.FormatInt(h.config.MaxSessionAge.Milliseconds(), 10)
+	return conn.SendAuthSuccess(metadata)
+}
+
+// countReauth counts a reauthentication outcome.
+func (h *ConnectionHandler) countReauth(result string) {
+	if h.server != nil && h.server.prometheusMetrics != nil {
+		h.server.prometheusMetrics.IncrementReauth(h.server.instanceID, result)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+type reauthClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *protocol.FrameReader
+	writer *protocol.FrameWriter
+	clock  *FakeClock
+	srv    *Server
+	done   <-chan error
+}
+
+// startReauth runs Handle for a connection authenticated as alice, with
+// sessions lasting 10s and 5s to reauthenticate.
+func startReauth(t *testing.T) *reauthClient {
+	t.Helper()
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.MaxSessionAge = 10 * time.Second
+	config.ReauthGrace = 5 * time.Second
+	config.HeartbeatTimeout = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := &Server{
+		config:            config,
+		ctx:               ctx,
+		logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		authenticator:     auth.NewAuthenticator(&auth.Config{Username: "alice", Password: "secret", MaxAttempts: 10, RateLimitWindow: time.Minute}),
+		prometheusMetrics: NewPrometheusMetricsWithRegistry(prometheus.NewRegistry()),
+	}
+
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	conn.SetAuthenticated(&auth.Session{Username: "alice", ClientID: "desk-1", Authenticated: true})
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+	handler := NewConnectionHandler(conn, config, srv)
+	done := make(chan error, 1)
+	go func() { done <- handler.Handle(ctx) }()
+
+	c := &reauthClient{t: t, conn: clientSide, reader: protocol.NewFrameReader(clientSide, config.MaxMessageSize),
+		writer: protocol.NewFrameWriter(clientSide), clock: clock, srv: srv, done: done}
+	// A heartbeat round trip shows Handle has armed the session timer
+	c.send(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 1})
+	c.expect(protocol.MessageTypePong)
+	return c
+}
+
+func (c *reauthClient) send(msgType protocol.MessageType, msg proto.Message) {
+	c.t.Helper()
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	require.NoError(c.t, err)
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	require.NoError(c.t, c.writer.WriteFrame(frame))
+}
+
+func (c *reauthClient) expect(msgType protocol.MessageType) *protocol.Frame {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := c.reader.ReadFrame()
+	require.NoError(c.t, err)
+	require.Equal(c.t, msgType, frame.Type)
+	return frame
+}
+
+func (c *reauthClient) expectReauthRequired() {
+	c.t.Helper()
+	var info pb.InfoMessage
+	require.NoError(c.t, protocol.UnmarshalMessage(c.expect(protocol.MessageTypeInfo), &info))
+	assert.Equal(c.t, pb.InfoCode_INFO_CODE_REAUTH_REQUIRED, info.Code)
+	assert.Equal(c.t, "5000", info.Metadata["grace_ms"])
+}
+
+func (c *reauthClient) expectError(code pb.ErrorCode) {
+	c.t.Helper()
+	var resp pb.ErrorResponse
+	require.NoError(c.t, protocol.UnmarshalMessage(c.expect(protocol.MessageTypeError), &resp))
+	assert.Equal(c.t, code, resp.Code)
+}
+
+func (c *reauthClient) reauths(result string) float64 {
+	return counterValue(c.t, c.srv.prometheusMetrics.reauths.WithLabelValues(c.srv.instanceID, result))
+}
+
+func TestReauthRenewsSession(t *testing.T) {
+	c := startReauth(t)
+
+	c.clock.Advance(10 * time.Second)
+	c.expectReauthRequired()
+
+	c.send(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "alice", Password: "secret", RequestId: "r1"})
+	var ack pb.AckResponse
+	require.NoError(t, protocol.UnmarshalMessage(c.expect(protocol.MessageTypeACK), &ack))
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_AUTH, ack.AckType)
+	assert.Equal(t, "r1", ack.CorrelationId)
+	assert.Equal(t, "10000", ack.Metadata["max_session_age_ms"])
+
+	// The grace period no longer applies; the next renewal is due a full age later
+	c.clock.Advance(5 * time.Second)
+	c.send(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{TimestampMs: time.Now().UnixMilli(), Sequence: 2})
+	c.expect(protocol.MessageTypePong)
+	c.clock.Advance(5 * time.Second)
+	c.expectReauthRequired()
+
+	assert.Equal(t, 2.0, c.reauths("requested"))
+	assert.Equal(t, 1.0, c.reauths("succeeded"))
+}
+
+func TestReauthChallengeResponse(t *testing.T) {
+	c := startReauth(t)
+
+	c.send(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "alice", Mechanism: protocol.AuthMechanismHMACSHA256})
+	var challenge pb.AuthChallenge
+	require.NoError(t, protocol.UnmarshalMessage(c.expect(protocol.MessageTypeAuthChallenge), &challenge))
+	c.send(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "alice", Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response: auth.ChallengeResponse("secret", challenge.Nonce)})
+	c.expect(protocol.MessageTypeACK)
+	assert.Equal(t, 1.0, c.reauths("succeeded"))
+}
+
+func TestReauthExpires(t *testing.T) {
+	c := startReauth(t)
+
+	c.clock.Advance(10 * time.Second)
+	c.expectReauthRequired()
+	c.clock.Advance(5 * time.Second)
+	c.expectError(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED)
+	assert.ErrorIs(t, <-c.done, ErrSessionExpired)
+	assert.Equal(t, 1.0, c.reauths("expired"))
+}
+
+func TestReauthRefused(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *pb.AuthRequest
+	}{
+		{"wrong password", &pb.AuthRequest{Username: "alice", Password: "guess"}},
+		{"other user", &pb.AuthRequest{Username: "bob", Password: "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := startReauth(t)
+			if tt.req.Username == "bob" {
+				c.srv.authenticator.SetCredentials(auth.Credentials{Username: "bob", Password: "secret"})
+			}
+			c.send(protocol.MessageTypeAuth, tt.req)
+			c.expectError(pb.ErrorCode_ERROR_CODE_INVALID_AUTH)
+			assert.ErrorIs(t, <-c.done, ErrReauthFailed)
+			assert.Equal(t, 1.0, c.reauths("failed"))
+		})
+	}
+}
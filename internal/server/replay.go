@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// ReplayDecoder reads ticks from a recording, e.g. a Parquet reader supplied
+// by the embedding application. Tick timestamps are the recorded ones.
+type ReplayDecoder func(r io.Reader) ([]*pb.Tick, error)
+
+// ReplayConfig replays recorded ticks as live data.
+type ReplayConfig struct {
+	Path    string        // Recording to replay
+	Decoder ReplayDecoder // Reads Path; nil reads CSV
+	Speed   float64       // Playback rate: 1 is the recorded pace, 10 is ten times faster
+	Loop    bool          // Start over at the end instead of stopping
+
+	once  sync.Once
+	ticks []*pb.Tick
+	err   error
+}
+
+// Validate reports the first invalid setting. It does not read the file.
+func (c *ReplayConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("replay needs a file")
+	}
+	if c.Speed <= 0 {
+		return fmt.Errorf("replay speed must be positive")
+	}
+	if c.Decoder == nil && !strings.EqualFold(filepath.Ext(c.Path), ".csv") {
+		return fmt.Errorf("no decoder for %q: only CSV is built in", filepath.Base(c.Path))
+	}
+	return nil
+}
+
+// load reads and sorts the recording once for every subscription.
+func (c *ReplayConfig) load() ([]*pb.Tick, error) {
+	c.once.Do(func() {
+		f, err := os.Open(c.Path)
+		if err != nil {
+			c.err = err
+			return
+		}
+		defer f.Close()
+
+		decode := c.Decoder
+		if decode == nil {
+			decode = decodeCSVTicks
+		}
+		ticks, err := decode(f)
+		if err != nil {
+			c.err = fmt.Errorf("failed to read %s: %w", c.Path, err)
+			return
+		}
+		if len(ticks) == 0 {
+			c.err = fmt.Errorf("%s holds no ticks", c.Path)
+			return
+		}
+		sort.SliceStable(ticks, func(i, j int) bool { return ticks[i].TimestampMs < ticks[j].TimestampMs })
+		c.ticks = ticks
+	})
+	return c.ticks, c.err
+}
+
+// source returns the replay data source.
+func (c *ReplayConfig) source(clock Clock, logger *slog.Logger) DataSource {
+	return replaySource{config: c, clock: clock, logger: logger}
+}
+
+// replaySource streams a recording to each subscription from its start,
+// shifting recorded timestamps so the first tick is stamped when the
+// subscription begins.
+type replaySource struct {
+	config *ReplayConfig
+	clock  Clock
+	logger *slog.Logger
+}
+
+func (s replaySource) Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick)) {
+	ticks, err := s.config.load()
+	if err != nil {
+		s.logger.Error("replay unavailable", "error", err)
+		return
+	}
+	for {
+		if !s.play(ctx, ticks, subscription, emit) || !s.config.Loop {
+			return
+		}
+	}
+}
+
+// play replays ticks once, emitting ticks recorded at the same instant
+// together. It returns false when ctx ends first.
+func (s replaySource) play(ctx context.Context, ticks []*pb.Tick, subscription *Subscription, emit func([]*pb.Tick)) bool {
+	start := s.clock.Now()
+	first := ticks[0].TimestampMs
+	for i := 0; i < len(ticks); {
+		recorded := ticks[i].TimestampMs
+		offset := time.Duration(float64(time.Duration(recorded-first)*time.Millisecond) / s.config.Speed)
+		if wait := start.Add(offset).Sub(s.clock.Now()); wait > 0 {
+			timer := s.clock.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C():
+			}
+		} else if ctx.Err() != nil {
+			return false
+		}
+
+		live := start.Add(offset).UnixMilli()
+		var batch []*pb.Tick
+		for ; i < len(ticks) && ticks[i].TimestampMs == recorded; i++ {
+			tick := ticks[i]
+			if tick.Mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED && tick.Mode != subscription.Mode {
+				continue
+			}
+			if !subscription.wantsSymbol(tick.Symbol) {
+				continue
+			}
+			batch = append(batch, &pb.Tick{
+				Symbol:      tick.Symbol,
+				TimestampMs: live,
+				Price:       tick.Price,
+				Volume:      tick.Volume,
+				Bid:         tick.Bid,
+				Ask:         tick.Ask,
+				BidSize:     tick.BidSize,
+				AskSize:     tick.AskSize,
+				Mode:        subscription.Mode,
+				Metadata:    tick.Metadata,
+			})
+		}
+		if len(batch) > 0 {
+			emit(batch)
+		}
+	}
+	return true
+}
+
+// Symbols lists the recorded symbols with the modes they were recorded in.
+func (s replaySource) Symbols(context.Context) ([]*pb.SymbolInfo, error) {
+	ticks, err := s.config.load()
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := make(map[string]*pb.SymbolInfo)
+	var symbols []*pb.SymbolInfo
+	for _, tick := range ticks {
+		info, ok := bySymbol[tick.Symbol]
+		if !ok {
+			info = &pb.SymbolInfo{Symbol: tick.Symbol, Description: "Replayed instrument"}
+			bySymbol[tick.Symbol] = info
+			symbols = append(symbols, info)
+		}
+		if tick.Mode != pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED && !slices.Contains(info.Modes, tick.Mode) {
+			info.Modes = append(info.Modes, tick.Mode)
+		}
+	}
+	return symbols, nil
+}
+
+// decodeCSVTicks reads ticks from CSV with a header row naming its columns:
+// timestamp (epoch milliseconds or RFC 3339), symbol and price are required;
+// volume, bid, ask, bid_size, ask_size and mode (SECOND or MINUTE) are
+// optional. Ticks without a mode are replayed to every subscription.
+func decodeCSVTicks(r io.Reader) ([]*pb.Tick, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "timestamp_ms" || name == "time" {
+			name = "timestamp"
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"timestamp", "symbol", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var ticks []*pb.Tick
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return ticks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		tick, err := parseCSVTick(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ticks = append(ticks, tick)
+	}
+}
+
+// parseCSVTick converts one CSV record.
+func parseCSVTick(record []string, columns map[string]int) (*pb.Tick, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	float := func(name string) (float64, error) {
+		v := field(name)
+		if v == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return f, nil
+	}
+	integer := func(name string) (int64, error) {
+		v := field(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return n, nil
+	}
+
+	tick := &pb.Tick{Symbol: protocol.Symbols.Intern(field("symbol"))}
+	if tick.Symbol == "" {
+		return nil, fmt.Errorf("empty symbol")
+	}
+	ts := field("timestamp")
+	if ms, err := strconv.ParseInt(ts, 10, 64); err == nil {
+		tick.TimestampMs = ms
+	} else if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		tick.TimestampMs = t.UnixMilli()
+	} else {
+		return nil, fmt.Errorf("invalid timestamp %q", ts)
+	}
+
+	var err error
+	for name, dst := range map[string]*float64{"price": &tick.Price, "volume": &tick.Volume, "bid": &tick.Bid, "ask": &tick.Ask} {
+		if *dst, err = float(name); err != nil {
+			return nil, err
+		}
+	}
+	if tick.BidSize, err = integer("bid_size"); err != nil {
+		return nil, err
+	}
+	if tick.AskSize, err = integer("ask_size"); err != nil {
+		return nil, err
+	}
+	switch mode := strings.ToUpper(field("mode")); mode {
+	case "":
+	case "SECOND":
+		tick.Mode = pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	case "MINUTE":
+		tick.Mode = pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE
+	default:
+		return nil, fmt.Errorf("invalid mode %q", mode)
+	}
+	return tick, nil
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+const replayCSV = `time,symbol,price,volume,bid,ask,bid_size,ask_size,mode
+2024-03-01T14:30:00.000Z,EURUSD,1.0801,10,1.0800,1.0802,5,7,
+2024-03-01T14:30:00.000Z,GBPUSD,1.2650,3,,,,,SECOND
+2024-03-01T14:30:02.000Z,EURUSD,1.0803,,,,,,
+2024-03-01T14:31:00.000Z,EURUSD,1.0810,,,,,,MINUTE
+`
+
+func writeReplayFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ticks.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDecodeCSVTicks(t *testing.T) {
+	ticks, err := decodeCSVTicks(strings.NewReader(replayCSV))
+	require.NoError(t, err)
+	require.Len(t, ticks, 4)
+	assert.Equal(t, "EURUSD", ticks[0].Symbol)
+	assert.Equal(t, int64(1709303400000), ticks[0].TimestampMs)
+	assert.Equal(t, 1.0802, ticks[0].Ask)
+	assert.Equal(t, int64(7), ticks[0].AskSize)
+	assert.Equal(t, pb.SubscriptionMode_SUBSCRIPTION_MODE_UNSPECIFIED, ticks[0].Mode)
+	assert.Equal(t, pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, ticks[1].Mode)
+
+	ticks, err = decodeCSVTicks(strings.NewReader("timestamp_ms,symbol,price\n1000,X,1\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), ticks[0].TimestampMs)
+
+	_, err = decodeCSVTicks(strings.NewReader("timestamp,price\n1000,1\n"))
+	assert.ErrorContains(t, err, "missing symbol column")
+	_, err = decodeCSVTicks(strings.NewReader("timestamp,symbol,price\n1000,X,1\nyesterday,X,1\n"))
+	assert.ErrorContains(t, err, "line 3")
+}
+
+func TestReplayConfigValidate(t *testing.T) {
+	assert.NoError(t, (&ReplayConfig{Path: "ticks.CSV", Speed: 1}).Validate())
+	assert.Error(t, (&ReplayConfig{Path: "ticks.csv"}).Validate(), "speed required")
+	assert.Error(t, (&ReplayConfig{Path: "ticks.parquet", Speed: 1}).Validate(), "no built-in parquet decoder")
+	assert.NoError(t, (&ReplayConfig{Path: "ticks.parquet", Speed: 1, Decoder: decodeCSVTicks}).Validate())
+}
+
+func TestReplayStreamsAtRecordedPace(t *testing.T) {
+	start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	replay := &ReplayConfig{Path: writeReplayFile(t, replayCSV), Speed: 2}
+	source := replay.source(clock, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan []*pb.Tick, 10)
+	done := make(chan struct{})
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	go func() {
+		defer close(done)
+		source.Stream(ctx, sub, func(ticks []*pb.Tick) { batches <- ticks })
+	}()
+
+	// Ticks recorded together arrive together, stamped with the live time
+	first := <-batches
+	require.Len(t, first, 2)
+	assert.Equal(t, start.UnixMilli(), first[0].TimestampMs)
+	assert.Equal(t, pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, first[0].Mode)
+
+	// Two recorded seconds pass in one at double speed
+	waitForTimers(t, clock, 1)
+	clock.Advance(999 * time.Millisecond)
+	assert.Empty(t, batches)
+	clock.Advance(time.Millisecond)
+	second := <-batches
+	require.Len(t, second, 1)
+	assert.Equal(t, start.Add(time.Second).UnixMilli(), second[0].TimestampMs)
+
+	// The MINUTE tick is skipped and the recording ends
+	waitForTimers(t, clock, 1)
+	clock.Advance(time.Minute)
+	<-done
+	assert.Empty(t, batches)
+
+	symbols, err := source.(SymbolDirectory).Symbols(context.Background())
+	require.NoError(t, err)
+	require.Len(t, symbols, 2)
+	assert.Equal(t, []pb.SubscriptionMode{pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE}, symbols[0].Modes)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// symbolState is the symbol state replicated from the ingest leader: the
+// published symbols with the modes they tick in, and the symbols that are
+// halted or stale. It is not safe for concurrent use.
+type symbolState struct {
+	symbols  map[string]*pb.SymbolInfo
+	statuses map[string]*pb.StreamStatus // Symbols not live; live symbols have no entry
+}
+
+func newSymbolState() symbolState {
+	return symbolState{
+		symbols:  make(map[string]*pb.SymbolInfo),
+		statuses: make(map[string]*pb.StreamStatus),
+	}
+}
+
+// observe records the symbols and modes of ticks.
+func (s *symbolState) observe(ticks []*pb.Tick) {
+	for _, tick := range ticks {
+		if tick.Symbol == "" {
+			continue
+		}
+		info, ok := s.symbols[tick.Symbol]
+		if !ok {
+			info = &pb.SymbolInfo{Symbol: tick.Symbol}
+			s.symbols[tick.Symbol] = info
+		}
+		if !slices.Contains(info.Modes, tick.Mode) {
+			info.Modes = append(info.Modes, tick.Mode)
+			slices.Sort(info.Modes)
+		}
+	}
+}
+
+// merge adds the reference data of a symbol directory, keeping the modes
+// already seen ticking.
+func (s *symbolState) merge(directory []*pb.SymbolInfo) {
+	for _, info := range directory {
+		merged := proto.Clone(info).(*pb.SymbolInfo)
+		if prev, ok := s.symbols[info.Symbol]; ok {
+			for _, mode := range prev.Modes {
+				if !slices.Contains(merged.Modes, mode) {
+					merged.Modes = append(merged.Modes, mode)
+				}
+			}
+			slices.Sort(merged.Modes)
+		}
+		s.symbols[info.Symbol] = merged
+	}
+}
+
+// setStatus records a symbol's stream status.
+func (s *symbolState) setStatus(status *pb.StreamStatus) {
+	if status.State == pb.StreamState_STREAM_STATE_LIVE {
+		delete(s.statuses, status.Symbol)
+		return
+	}
+	s.statuses[status.Symbol] = status
+}
+
+// list returns a copy of the symbols.
+func (s *symbolState) list() []*pb.SymbolInfo {
+	symbols := make([]*pb.SymbolInfo, 0, len(s.symbols))
+	for _, info := range s.symbols {
+		symbols = append(symbols, proto.Clone(info).(*pb.SymbolInfo))
+	}
+	return symbols
+}
+
+// statusList returns the statuses of the symbols not live.
+func (s *symbolState) statusList() []*pb.StreamStatus {
+	statuses := make([]*pb.StreamStatus, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// snapshot returns the state as REPLICA_STATE messages that each fit in a
+// frame, the first replacing the follower's state as of sequence. The
+// messages share no mutable data with the state.
+func (s *symbolState) snapshot(epoch string, sequence uint64, timestampMs int64) []*pb.ReplicaState {
+	next := func() *pb.ReplicaState {
+		return &pb.ReplicaState{LeaderEpoch: epoch, Sequence: sequence, TimestampMs: timestampMs}
+	}
+	state := next()
+	state.Full = true
+	states := []*pb.ReplicaState{state}
+	size := symbolListOverhead
+	add := func(entry proto.Message) {
+		size += proto.Size(entry) + symbolListEntryOverhead
+		if size > protocol.DefaultMaxMessageSize {
+			state = next()
+			states = append(states, state)
+			size = symbolListOverhead + proto.Size(entry) + symbolListEntryOverhead
+		}
+	}
+	for _, info := range s.list() {
+		add(info)
+		state.Symbols = append(state.Symbols, info)
+	}
+	for _, status := range s.statuses {
+		add(status)
+		state.Statuses = append(state.Statuses, status)
+	}
+	return states
+}
+
+// apply updates the state from a REPLICA_STATE message and returns the
+// status changes to report: the statuses it carries and, for a full state,
+// the end of the halt of each symbol it omits.
+func (s *symbolState) apply(state *pb.ReplicaState, timestampMs int64) []*pb.StreamStatus {
+	var changes []*pb.StreamStatus
+	if state.Full {
+		kept := make(map[string]bool, len(state.Statuses))
+		for _, status := range state.Statuses {
+			kept[status.Symbol] = true
+		}
+		for symbol := range s.statuses {
+			if !kept[symbol] {
+				changes = append(changes, &pb.StreamStatus{
+					Symbol:      symbol,
+					State:       pb.StreamState_STREAM_STATE_LIVE,
+					TimestampMs: timestampMs,
+				})
+			}
+		}
+		*s = newSymbolState()
+	}
+	s.merge(state.Symbols)
+	for _, status := range state.Statuses {
+		s.setStatus(status)
+		changes = append(changes, status)
+	}
+	return changes
+}
@@ -0,0 +1,738 @@
+// Package server implements leader-elected ingest with replication to followers.
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// replicationModes are the subscription modes the leader pulls from the
+// upstream, each as one subscription to every symbol.
+var replicationModes = []pb.SubscriptionMode{
+	pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+	pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+}
+
+// Replication roles.
+const (
+	RoleNone     = "none" // No leader elected yet
+	RoleLeader   = "leader"
+	RoleFollower = "follower"
+)
+
+// ReplicationConfig enables leader-elected ingest: only the elected leader
+// streams from the data source, and replicates its ticks to the other
+// instances of the cluster, which serve them to their own subscribers.
+type ReplicationConfig struct {
+	Election       string        // ElectionCluster or ElectionKubernetes
+	LeaseName      string        // Kubernetes Lease; defaults to "tick-storm-ingest"
+	LeaseNamespace string        // Defaults to the pod's namespace
+	LeaseDuration  time.Duration // Defaults to 15s
+	FollowerBuffer int           // Batches queued per follower before it is dropped; defaults to 1024
+	Backlog        int           // Batches the leader keeps for followers to catch up from; defaults to 4096
+
+	once sync.Once
+	src  *ReplicatedSource
+}
+
+// Validate reports the first invalid setting.
+func (c *ReplicationConfig) Validate() error {
+	switch c.Election {
+	case ElectionCluster, ElectionKubernetes:
+	default:
+		return fmt.Errorf("unknown leader election %q: expected %q or %q", c.Election, ElectionCluster, ElectionKubernetes)
+	}
+	if c.LeaseDuration < 0 || c.FollowerBuffer < 0 || c.Backlog < 0 {
+		return fmt.Errorf("lease duration, follower buffer and backlog must not be negative")
+	}
+	return nil
+}
+
+// validateReplication checks that leader-elected ingest can run with the
+// rest of config. The replication and gossip endpoints carry the tick feed
+// and pick the leader, so they are never served without the cluster secret.
+func (c *Config) validateReplication() error {
+	if err := c.Replication.Validate(); err != nil {
+		return err
+	}
+	switch {
+	case c.ClusterAdvertiseAddr == "":
+		return fmt.Errorf("leader-elected ingest needs cluster membership (CLUSTER_ADVERTISE_ADDR)")
+	case c.ClusterSecret == "":
+		return fmt.Errorf("leader-elected ingest needs authenticated cluster endpoints (CLUSTER_SECRET)")
+	case c.DataSource == nil && c.Ingest != nil:
+		return fmt.Errorf("producers publish to the instance they connect to, so ingest cannot be leader-elected")
+	}
+	return nil
+}
+
+// source returns the replicated source shared by every subscription.
+func (c *ReplicationConfig) source() *ReplicatedSource {
+	c.once.Do(func() {
+		buffer := c.FollowerBuffer
+		if buffer == 0 {
+			buffer = 1024
+		}
+		backlog := c.Backlog
+		if backlog == 0 {
+			backlog = 4096
+		}
+		c.src = &ReplicatedSource{
+			followerBuffer: buffer,
+			backlogSize:    backlog,
+			clock:          RealClock(),
+			logger:         slog.Default(),
+			role:           RoleNone,
+			followers:      make(map[*replicaFollower]struct{}),
+			symbols:        newSymbolState(),
+		}
+	})
+	return c.src
+}
+
+// replicaFollower is a follower streaming from this instance as leader.
+type replicaFollower struct {
+	frames  chan *protocol.Frame
+	dropped chan struct{} // Closed when the follower fell behind
+	once    sync.Once
+}
+
+func (f *replicaFollower) drop() {
+	f.once.Do(func() { close(f.dropped) })
+}
+
+// ReplicationStats are the replication counters exported as metrics.
+type ReplicationStats struct {
+	Leader           bool
+	Followers        int
+	PulledTicks      uint64 // From the upstream, as leader
+	ReplicatedTicks  uint64 // Received from the leader, as follower
+	DroppedFollowers uint64
+	StreamErrors     uint64 // Failed or broken streams from the leader
+	Resumes          uint64 // Followers that caught up from the backlog, as leader
+	Resyncs          uint64 // Followers sent the full state, as leader
+}
+
+// ReplicatedSource is the data source of every instance while ingest is
+// leader-elected. The leader streams each mode from the upstream once and
+// broadcasts the ticks to its subscribers and to every follower; followers
+// stream them from the leader's /cluster/replicate and broadcast them to
+// theirs. Ticks stop while no leader is elected.
+//
+// Followers open the stream with a REPLICA_SYNC frame. The leader numbers
+// the batches it publishes within its epoch and keeps the last Backlog of
+// them, so a follower that reconnects is sent the batches it missed. A
+// follower that restarted, followed another leader or fell out of the
+// backlog is sent the leader's symbol state instead, then the backlog to
+// record in its last-value cache and tick history without broadcasting.
+type ReplicatedSource struct {
+	tickFanout
+
+	upstream       DataSource
+	record         func([]*pb.Tick) // Records catch-up ticks too old to broadcast
+	elector        LeaderElector
+	self           string
+	leaderAddr     func(id string) string // Ops address of a cluster member
+	secret         string
+	clock          Clock
+	logger         *slog.Logger
+	client         *http.Client
+	followerBuffer int
+	backlogSize    int
+
+	mu        sync.Mutex
+	role      string
+	leader    string
+	cancel    context.CancelFunc // Stops the current role's streams
+	followers map[*replicaFollower]struct{}
+	report    func(*pb.StreamStatus) error
+	symbols   symbolState
+	epoch     string             // Own epoch as leader, else the epoch of the last batch applied
+	sequence  uint64             // Last batch published as leader, else applied
+	backlog   []*pb.ReplicaBatch // Oldest first, ending at sequence; leader only
+	backfill  bool               // Catch-up batches follow a full state
+
+	pulled           atomic.Uint64
+	replicated       atomic.Uint64
+	droppedFollowers atomic.Uint64
+	streamErrors     atomic.Uint64
+	resumes          atomic.Uint64
+	resyncs          atomic.Uint64
+}
+
+// Start follows the election every interval until ctx is done, pulling
+// from upstream while this instance leads and from the leader otherwise.
+// record receives the catch-up ticks a follower is not to broadcast.
+func (r *ReplicatedSource) Start(ctx context.Context, upstream DataSource, record func([]*pb.Tick), elector LeaderElector,
+	self string, leaderAddr func(string) string, secret string, interval time.Duration, clock Clock, logger *slog.Logger) {
+	r.upstream, r.record, r.elector, r.self = upstream, record, elector, self
+	r.leaderAddr, r.secret = leaderAddr, secret
+	r.clock, r.logger = clock, logger
+	r.client = &http.Client{} // Streams are long-lived; the dialer and leader changes bound them
+
+	go elector.Run(ctx)
+	go func() {
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.follow(ctx, elector.Leader())
+			select {
+			case <-ctx.Done():
+				r.follow(ctx, "")
+				return
+			case <-ticker.C():
+			}
+		}
+	}()
+}
+
+// follow switches role when the leader changed.
+func (r *ReplicatedSource) follow(ctx context.Context, leader string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if leader == r.leader && r.cancel != nil {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	if leader != r.self {
+		// Followers reconnect to whoever leads now
+		for f := range r.followers {
+			f.drop()
+		}
+		r.backlog = nil
+	}
+	if leader != r.leader {
+		r.logger.Info("ingest leadership changed", "leader", leader, "self", leader == r.self)
+	}
+	r.leader = leader
+
+	roleCtx, cancel := context.WithCancel(ctx)
+	switch {
+	case ctx.Err() != nil || leader == "":
+		r.role = RoleNone
+		cancel()
+		return
+	case leader == r.self:
+		r.role = RoleLeader
+		r.startEpoch()
+		for _, mode := range replicationModes {
+			go r.upstream.Stream(roleCtx, NewSubscription(mode), func(ticks []*pb.Tick) { r.publish(roleCtx, ticks) })
+		}
+		if source, ok := r.upstream.(StreamStatusSource); ok {
+			go source.WatchStatus(roleCtx, func(status *pb.StreamStatus) error { return r.reportStatus(roleCtx, status) })
+		}
+	default:
+		r.role = RoleFollower
+		go r.stream(roleCtx, leader)
+	}
+	r.cancel = cancel
+}
+
+// startEpoch begins a leadership epoch, numbering batches from 1. The
+// caller holds r.mu.
+func (r *ReplicatedSource) startEpoch() {
+	r.epoch = r.self + "-" + strconv.FormatInt(r.clock.Now().UnixNano(), 36)
+	r.sequence = 0
+	r.backlog = nil
+	r.backfill = false
+}
+
+// publish broadcasts ticks pulled from the upstream locally and to followers,
+// as long as ctx, the leadership they were pulled under, lasts.
+func (r *ReplicatedSource) publish(ctx context.Context, ticks []*pb.Tick) {
+	protocol.Symbols.InternTicks(ticks)
+
+	r.mu.Lock()
+	if ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.symbols.observe(ticks)
+	now := r.clock.Now().UnixMilli()
+	for start := 0; start < len(ticks); start += protocol.MaxTicksPerBatch {
+		r.sequence++
+		batch := &pb.ReplicaBatch{
+			LeaderEpoch: r.epoch,
+			Sequence:    r.sequence,
+			Ticks:       ticks[start:min(start+protocol.MaxTicksPerBatch, len(ticks))],
+			TimestampMs: now,
+		}
+		// Appending past capacity copies the kept batches, so the dropped
+		// head is released then
+		r.backlog = append(r.backlog, batch)
+		if len(r.backlog) > r.backlogSize {
+			r.backlog = r.backlog[len(r.backlog)-r.backlogSize:]
+		}
+		r.sendFollowers(protocol.MessageTypeReplicaBatch, batch)
+	}
+	r.mu.Unlock()
+
+	r.pulled.Add(uint64(len(ticks)))
+	r.broadcast(ticks)
+}
+
+// reportStatus records a status change reported by the upstream, sends it to
+// followers and reports it to this instance's subscribers.
+func (r *ReplicatedSource) reportStatus(ctx context.Context, status *pb.StreamStatus) error {
+	if err := protocol.ValidateStreamStatus(status); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if ctx.Err() != nil {
+		r.mu.Unlock()
+		return nil
+	}
+	if status.TimestampMs == 0 {
+		status.TimestampMs = r.clock.Now().UnixMilli()
+	}
+	r.symbols.setStatus(status)
+	r.sendFollowers(protocol.MessageTypeReplicaState, &pb.ReplicaState{
+		LeaderEpoch: r.epoch,
+		Sequence:    r.sequence,
+		Statuses:    []*pb.StreamStatus{status},
+		TimestampMs: status.TimestampMs,
+	})
+	report := r.report
+	r.mu.Unlock()
+
+	if report == nil {
+		return nil
+	}
+	return report(status)
+}
+
+// sendFollowers queues msg for every follower, dropping those that are full.
+// The caller holds r.mu, which keeps each follower's frames in order.
+func (r *ReplicatedSource) sendFollowers(msgType protocol.MessageType, msg proto.Message) {
+	if len(r.followers) == 0 {
+		return
+	}
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	if err != nil {
+		r.logger.Error("failed to marshal replication frame", "type", msgType, "error", err)
+		return
+	}
+	for f := range r.followers {
+		select {
+		case f.frames <- frame:
+		default:
+			f.drop()
+		}
+	}
+}
+
+// WatchStatus passes report the status changes replicated from the leader,
+// or reported by the upstream while this instance leads, until ctx is done.
+func (r *ReplicatedSource) WatchStatus(ctx context.Context, report func(*pb.StreamStatus) error) {
+	r.mu.Lock()
+	r.report = report
+	pending := r.symbols.statusList()
+	r.mu.Unlock()
+	for _, status := range pending {
+		report(status)
+	}
+
+	<-ctx.Done()
+	r.mu.Lock()
+	r.report = nil
+	r.mu.Unlock()
+}
+
+// Symbols lists the symbols published while ingest is leader-elected: every
+// symbol seen ticking, with the reference data of the upstream's directory
+// on the leader, and of the leader's as of the last full state on followers.
+func (r *ReplicatedSource) Symbols(ctx context.Context) ([]*pb.SymbolInfo, error) {
+	if err := r.refreshDirectory(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.symbols.list(), nil
+}
+
+// refreshDirectory merges the upstream's symbol directory into the symbol
+// state while this instance leads.
+func (r *ReplicatedSource) refreshDirectory(ctx context.Context) error {
+	r.mu.Lock()
+	leading := r.role == RoleLeader
+	r.mu.Unlock()
+	dir, ok := r.upstream.(SymbolDirectory)
+	if !leading || !ok {
+		return nil
+	}
+	symbols, err := dir.Symbols(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.symbols.merge(symbols)
+	r.mu.Unlock()
+	return nil
+}
+
+// stream receives the leader's ticks until ctx is done, reconnecting after
+// failures.
+func (r *ReplicatedSource) stream(ctx context.Context, leader string) {
+	for ctx.Err() == nil {
+		err := r.streamOnce(ctx, leader)
+		if ctx.Err() != nil {
+			return
+		}
+		r.streamErrors.Add(1)
+		r.logger.Warn("replication stream from leader failed", "leader", leader, "error", err)
+
+		timer := r.clock.NewTimer(time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
+
+func (r *ReplicatedSource) streamOnce(ctx context.Context, leader string) error {
+	addr := r.leaderAddr(leader)
+	if addr == "" {
+		return fmt.Errorf("no address known for leader %s", leader)
+	}
+	body, err := r.syncFrame()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+"/cluster/replicate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentTypeHeader, "application/octet-stream")
+	if r.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+r.secret)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", addr, resp.StatusCode)
+	}
+
+	reader := protocol.NewFrameReader(resp.Body, protocol.DefaultMaxMessageSize)
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			return err
+		}
+		switch frame.Type {
+		case protocol.MessageTypeReplicaBatch:
+			var batch pb.ReplicaBatch
+			if err := protocol.UnmarshalMessage(frame, &batch); err != nil {
+				return err
+			}
+			err = r.applyBatch(ctx, &batch)
+		case protocol.MessageTypeReplicaState:
+			var state pb.ReplicaState
+			if err := protocol.UnmarshalMessage(frame, &state); err != nil {
+				return err
+			}
+			err = r.applyState(ctx, &state)
+		default:
+			err = fmt.Errorf("unexpected frame type 0x%02X on replication stream", uint8(frame.Type))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// syncFrame returns the REPLICA_SYNC frame opening a stream from the leader.
+// Batches replayed on a resumed stream were missed live, so they are
+// broadcast unless the leader starts over with a full state.
+func (r *ReplicatedSource) syncFrame() ([]byte, error) {
+	r.mu.Lock()
+	r.backfill = false
+	sync := &pb.ReplicaSync{
+		FollowerId:    r.self,
+		LeaderEpoch:   r.epoch,
+		AfterSequence: r.sequence,
+		TimestampMs:   r.clock.Now().UnixMilli(),
+	}
+	r.mu.Unlock()
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeReplicaSync, sync)
+	if err != nil {
+		return nil, err
+	}
+	return frame.Marshal()
+}
+
+// applyBatch broadcasts a batch from the leader, or records it when it
+// catches up after a full state. A batch out of sequence ends the stream,
+// so the follower reconnects to catch up.
+func (r *ReplicatedSource) applyBatch(ctx context.Context, batch *pb.ReplicaBatch) error {
+	r.mu.Lock()
+	switch {
+	case ctx.Err() != nil:
+		r.mu.Unlock()
+		return ctx.Err()
+	case batch.LeaderEpoch != r.epoch:
+		r.mu.Unlock()
+		return fmt.Errorf("batch from leader epoch %s while following %s", batch.LeaderEpoch, r.epoch)
+	case batch.Sequence <= r.sequence:
+		r.mu.Unlock()
+		return nil // Applied before reconnecting
+	case batch.Sequence != r.sequence+1:
+		expected := r.sequence + 1
+		r.mu.Unlock()
+		return fmt.Errorf("replication gap: batch %d, expected %d", batch.Sequence, expected)
+	}
+	r.sequence = batch.Sequence
+	backfill := r.backfill && batch.CatchUp
+	if !batch.CatchUp {
+		r.backfill = false
+	}
+	r.symbols.observe(batch.Ticks)
+	r.mu.Unlock()
+
+	r.replicated.Add(uint64(len(batch.Ticks)))
+	protocol.Symbols.InternTicks(batch.Ticks)
+	if backfill {
+		if r.record != nil {
+			r.record(batch.Ticks)
+		}
+		return nil
+	}
+	r.broadcast(batch.Ticks)
+	return nil
+}
+
+// applyState updates the symbol state from the leader and reports the
+// status changes to this instance's subscribers.
+func (r *ReplicatedSource) applyState(ctx context.Context, state *pb.ReplicaState) error {
+	r.mu.Lock()
+	switch {
+	case ctx.Err() != nil:
+		r.mu.Unlock()
+		return ctx.Err()
+	case state.Full:
+		r.epoch, r.sequence, r.backfill = state.LeaderEpoch, state.Sequence, true
+	case state.LeaderEpoch != r.epoch:
+		r.mu.Unlock()
+		return fmt.Errorf("state from leader epoch %s while following %s", state.LeaderEpoch, r.epoch)
+	}
+	changes := r.symbols.apply(state, r.clock.Now().UnixMilli())
+	report := r.report
+	r.mu.Unlock()
+
+	if report == nil {
+		return nil
+	}
+	for _, status := range changes {
+		if err := report(status); err != nil {
+			r.logger.Warn("invalid stream status replicated from leader", "symbol", status.Symbol, "error", err)
+		}
+	}
+	return nil
+}
+
+// catchUp returns the messages bringing a follower from sync up to date,
+// and whether it resumed from the backlog rather than starting over from
+// the full state. The caller holds r.mu.
+func (r *ReplicatedSource) catchUp(sync *pb.ReplicaSync) ([]proto.Message, bool) {
+	oldest := r.sequence - uint64(len(r.backlog)) // Sequence before the first batch kept
+	resumed := sync.LeaderEpoch == r.epoch && sync.AfterSequence >= oldest && sync.AfterSequence <= r.sequence
+
+	var msgs []proto.Message
+	from := 0
+	if resumed {
+		from = int(sync.AfterSequence - oldest)
+	} else {
+		for _, state := range r.symbols.snapshot(r.epoch, oldest, r.clock.Now().UnixMilli()) {
+			msgs = append(msgs, state)
+		}
+	}
+	for _, batch := range r.backlog[from:] {
+		msgs = append(msgs, &pb.ReplicaBatch{
+			LeaderEpoch: batch.LeaderEpoch,
+			Sequence:    batch.Sequence,
+			Ticks:       batch.Ticks,
+			CatchUp:     true,
+			TimestampMs: batch.TimestampMs,
+		})
+	}
+	return msgs, resumed
+}
+
+// ServeHTTP streams the leader's batches and state to a follower, opening
+// with the REPLICA_SYNC frame in the request body. Instances that do not
+// lead answer 409, and followers that fall FollowerBuffer frames behind are
+// disconnected to reconnect.
+func (r *ReplicatedSource) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var sync pb.ReplicaSync
+	frame, err := protocol.NewFrameReader(req.Body, protocol.DefaultMaxMessageSize).ReadFrame()
+	if err == nil && frame.Type != protocol.MessageTypeReplicaSync {
+		err = protocol.ErrInvalidMessageType
+	}
+	if err == nil {
+		err = protocol.UnmarshalMessage(frame, &sync)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("expected a REPLICA_SYNC frame: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := r.refreshDirectory(req.Context()); err != nil {
+		r.logger.Warn("symbol directory unavailable for replication", "error", err)
+	}
+
+	f := &replicaFollower{frames: make(chan *protocol.Frame, r.followerBuffer), dropped: make(chan struct{})}
+	r.mu.Lock()
+	if r.role != RoleLeader {
+		leader := r.leader
+		r.mu.Unlock()
+		http.Error(w, fmt.Sprintf("not the ingest leader (leader %q)", leader), http.StatusConflict)
+		return
+	}
+	catchUp, resumed := r.catchUp(&sync)
+	r.followers[f] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.followers, f)
+		r.mu.Unlock()
+	}()
+	if resumed {
+		r.resumes.Add(1)
+	} else {
+		r.resyncs.Add(1)
+	}
+	r.logger.Debug("replication follower synced",
+		"follower", sync.FollowerId,
+		"after_sequence", sync.AfterSequence,
+		"resumed", resumed,
+		"catch_up_frames", len(catchUp),
+	)
+
+	w.Header().Set(contentTypeHeader, "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	writer := protocol.NewFrameWriter(w)
+	write := func(frame *protocol.Frame) error {
+		if err := writer.WriteFrame(frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	for _, msg := range catchUp {
+		msgType := protocol.MessageTypeReplicaBatch
+		if _, ok := msg.(*pb.ReplicaState); ok {
+			msgType = protocol.MessageTypeReplicaState
+		}
+		frame, err := protocol.MarshalMessage(msgType, msg)
+		if err == nil {
+			err = writer.WriteFrame(frame)
+		}
+		if err != nil {
+			r.logger.Debug("replication catch-up to follower ended", "follower", sync.FollowerId, "error", err)
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-f.dropped:
+			r.droppedFollowers.Add(1)
+			return
+		case frame := <-f.frames:
+			if err := write(frame); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					r.logger.Debug("replication stream to follower ended", "follower", sync.FollowerId, "error", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// Role returns this instance's replication role and the current leader.
+func (r *ReplicatedSource) Role() (role, leader string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.role, r.leader
+}
+
+// Stats returns replication counters for metrics.
+func (r *ReplicatedSource) Stats() ReplicationStats {
+	r.mu.Lock()
+	stats := ReplicationStats{Leader: r.role == RoleLeader, Followers: len(r.followers)}
+	r.mu.Unlock()
+	stats.PulledTicks = r.pulled.Load()
+	stats.ReplicatedTicks = r.replicated.Load()
+	stats.DroppedFollowers = r.droppedFollowers.Load()
+	stats.StreamErrors = r.streamErrors.Load()
+	stats.Resumes = r.resumes.Load()
+	stats.Resyncs = r.resyncs.Load()
+	return stats
+}
+
+// GetStats returns replication statistics.
+func (r *ReplicatedSource) GetStats() map[string]interface{} {
+	r.mu.Lock()
+	epoch, sequence, backlog := r.epoch, r.sequence, len(r.backlog)
+	symbols, halted := len(r.symbols.symbols), len(r.symbols.statuses)
+	r.mu.Unlock()
+	role, leader := r.Role()
+	stats := r.Stats()
+	return map[string]interface{}{
+		"role":              role,
+		"leader":            leader,
+		"epoch":             epoch,
+		"sequence":          sequence,
+		"backlog":           backlog,
+		"symbols":           symbols,
+		"not_live_symbols":  halted,
+		"followers":         stats.Followers,
+		"subscribers":       r.subscribers(),
+		"pulled_ticks":      stats.PulledTicks,
+		"replicated_ticks":  stats.ReplicatedTicks,
+		"delivered_ticks":   r.delivered.Load(),
+		"dropped_followers": stats.DroppedFollowers,
+		"stream_errors":     stats.StreamErrors,
+		"resumes":           stats.Resumes,
+		"resyncs":           stats.Resyncs,
+	}
+}
+
+// recordTicks updates the last-value cache and tick history without
+// delivering ticks to any subscriber.
+func (s *Server) recordTicks(ticks []*pb.Tick) {
+	if s.lastValues != nil {
+		s.lastValues.Update(ticks)
+	}
+	if s.history != nil {
+		s.history.Update(ticks)
+	}
+}
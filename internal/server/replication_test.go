@@ -0,0 +1,344 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// pushSource is an upstream whose ticks the test pushes, counting the
+// streams open on it.
+type pushSource struct {
+	tickFanout
+	streams atomic.Int32
+}
+
+func (p *pushSource) Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick)) {
+	p.streams.Add(1)
+	defer p.streams.Add(-1)
+	p.tickFanout.Stream(ctx, subscription, emit)
+}
+
+// staticElector reports a leader set by the test.
+type staticElector struct {
+	mu     sync.Mutex
+	leader string
+}
+
+func (e *staticElector) Run(ctx context.Context) {}
+
+func (e *staticElector) Leader() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+func (e *staticElector) set(leader string) {
+	e.mu.Lock()
+	e.leader = leader
+	e.mu.Unlock()
+}
+
+type replicaNode struct {
+	src      *ReplicatedSource
+	upstream *pushSource
+	ticks    chan []*pb.Tick
+}
+
+// startReplicas runs a replicated source per id sharing elector, each
+// streaming to one subscription to every SECOND symbol.
+func startReplicas(t *testing.T, elector LeaderElector, ids ...string) map[string]*replicaNode {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	nodes := make(map[string]*replicaNode)
+	addrs := make(map[string]string)
+	for _, id := range ids {
+		node := &replicaNode{
+			src:      (&ReplicationConfig{Election: ElectionCluster}).source(),
+			upstream: &pushSource{},
+			ticks:    make(chan []*pb.Tick, 16),
+		}
+		ts := httptest.NewServer(node.src)
+		t.Cleanup(ts.Close)
+		addrs[id] = strings.TrimPrefix(ts.URL, "http://")
+		nodes[id] = node
+		go node.src.Stream(ctx, NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND), func(ticks []*pb.Tick) {
+			node.ticks <- ticks
+		})
+	}
+	for _, id := range ids {
+		nodes[id].src.Start(ctx, nodes[id].upstream, nil, elector, id, func(id string) string { return addrs[id] }, "",
+			10*time.Millisecond, RealClock(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	// Cleanups run last first: end the replication streams before the
+	// servers wait for them
+	t.Cleanup(cancel)
+	return nodes
+}
+
+// syncRequest returns a replication request opening with sync.
+func syncRequest(t *testing.T, sync *pb.ReplicaSync) *http.Request {
+	t.Helper()
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeReplicaSync, sync)
+	require.NoError(t, err)
+	body, err := frame.Marshal()
+	require.NoError(t, err)
+	return httptest.NewRequest(http.MethodPost, "/cluster/replicate", bytes.NewReader(body))
+}
+
+func expectTicks(t *testing.T, node *replicaNode, symbols ...string) {
+	t.Helper()
+	select {
+	case ticks := <-node.ticks:
+		var got []string
+		for _, tick := range ticks {
+			got = append(got, tick.Symbol)
+		}
+		assert.Equal(t, symbols, got)
+	case <-time.After(time.Second):
+		t.Fatalf("no ticks received, want %v", symbols)
+	}
+}
+
+func pushTicks(node *replicaNode, symbols ...string) {
+	var ticks []*pb.Tick
+	for _, symbol := range symbols {
+		ticks = append(ticks, &pb.Tick{Symbol: symbol, Price: 1, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+	}
+	node.upstream.broadcast(ticks)
+}
+
+func TestReplicationLeaderFansOutToFollowers(t *testing.T) {
+	elector := &staticElector{leader: "a"}
+	nodes := startReplicas(t, elector, "a", "b", "c")
+	a := nodes["a"]
+	require.Eventually(t, func() bool { return a.src.Stats().Followers == 2 }, time.Second, 5*time.Millisecond)
+
+	// Only the leader streams from its upstream, once per mode
+	assert.Equal(t, int32(len(replicationModes)), a.upstream.streams.Load())
+	assert.Zero(t, nodes["b"].upstream.streams.Load())
+
+	pushTicks(a, "EURUSD", "GBPUSD")
+	for _, node := range nodes {
+		expectTicks(t, node, "EURUSD", "GBPUSD")
+	}
+	assert.Equal(t, uint64(2), a.src.Stats().PulledTicks)
+	assert.Equal(t, uint64(2), nodes["c"].src.Stats().ReplicatedTicks)
+	role, leader := nodes["c"].src.Role()
+	assert.Equal(t, RoleFollower, role)
+	assert.Equal(t, "a", leader)
+
+	// A follower refuses to serve replication
+	rec := httptest.NewRecorder()
+	nodes["b"].src.ServeHTTP(rec, syncRequest(t, &pb.ReplicaSync{FollowerId: "c"}))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	nodes["b"].src.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cluster/replicate", strings.NewReader("sync")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestReplicationFollowsLeaderChange(t *testing.T) {
+	elector := &staticElector{leader: "a"}
+	nodes := startReplicas(t, elector, "a", "b")
+	a, b := nodes["a"], nodes["b"]
+	require.Eventually(t, func() bool { return a.src.Stats().Followers == 1 }, time.Second, 5*time.Millisecond)
+
+	elector.set("b")
+	require.Eventually(t, func() bool { return b.src.Stats().Followers == 1 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return a.upstream.streams.Load() == 0 }, time.Second, 5*time.Millisecond,
+		"the old leader stops pulling")
+	pushTicks(b, "USDJPY")
+	expectTicks(t, a, "USDJPY")
+	expectTicks(t, b, "USDJPY")
+
+	// Ticks stop while no leader is elected
+	elector.set("")
+	require.Eventually(t, func() bool { return b.upstream.streams.Load() == 0 }, time.Second, 5*time.Millisecond)
+	role, _ := a.src.Role()
+	assert.Equal(t, RoleNone, role)
+}
+
+func TestReplicationDropsSlowFollower(t *testing.T) {
+	src := (&ReplicationConfig{Election: ElectionCluster, FollowerBuffer: 1}).source()
+	f := &replicaFollower{frames: make(chan *protocol.Frame, src.followerBuffer), dropped: make(chan struct{})}
+	src.followers[f] = struct{}{}
+
+	src.publish(context.Background(), []*pb.Tick{{Symbol: "EURUSD"}})
+	select {
+	case <-f.dropped:
+		t.Fatal("a follower with room is kept")
+	default:
+	}
+	src.publish(context.Background(), []*pb.Tick{{Symbol: "EURUSD"}})
+	select {
+	case <-f.dropped:
+	default:
+		t.Fatal("a full follower is dropped")
+	}
+}
+
+// leaderWithBacklog returns a source leading its own epoch that published
+// one batch per symbol, keeping the last backlog batches.
+func leaderWithBacklog(backlog int, symbols ...string) *ReplicatedSource {
+	src := (&ReplicationConfig{Election: ElectionCluster, Backlog: backlog}).source()
+	src.self, src.role = "a", RoleLeader
+	src.startEpoch()
+	for _, symbol := range symbols {
+		src.publish(context.Background(), []*pb.Tick{{Symbol: symbol, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}})
+	}
+	return src
+}
+
+func catchUpSequences(msgs []proto.Message) (full bool, sequences []uint64) {
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case *pb.ReplicaState:
+			full = full || m.Full
+		case *pb.ReplicaBatch:
+			sequences = append(sequences, m.Sequence)
+		}
+	}
+	return full, sequences
+}
+
+func TestReplicationCatchUp(t *testing.T) {
+	leader := leaderWithBacklog(3, "A", "B", "C", "D", "E")
+	epoch := leader.epoch
+
+	// A follower within the backlog resumes after its last batch
+	msgs, resumed := leader.catchUp(&pb.ReplicaSync{LeaderEpoch: epoch, AfterSequence: 3})
+	full, sequences := catchUpSequences(msgs)
+	assert.True(t, resumed)
+	assert.False(t, full)
+	assert.Equal(t, []uint64{4, 5}, sequences)
+	msgs, resumed = leader.catchUp(&pb.ReplicaSync{LeaderEpoch: epoch, AfterSequence: 5})
+	assert.True(t, resumed)
+	assert.Empty(t, msgs)
+
+	// Anyone else starts over from the full state and the whole backlog
+	for _, sync := range []*pb.ReplicaSync{
+		{LeaderEpoch: epoch, AfterSequence: 1}, // Out of the backlog
+		{LeaderEpoch: "b-1", AfterSequence: 4}, // Another leader's epoch
+		{},                                     // Restarted
+	} {
+		msgs, resumed = leader.catchUp(sync)
+		full, sequences = catchUpSequences(msgs)
+		assert.False(t, resumed)
+		assert.True(t, full)
+		assert.Equal(t, []uint64{3, 4, 5}, sequences)
+		state := msgs[0].(*pb.ReplicaState)
+		assert.Equal(t, uint64(2), state.Sequence, "the state precedes the first batch replayed")
+		assert.Len(t, state.Symbols, 5)
+	}
+}
+
+func TestReplicationFollowerCatchesUp(t *testing.T) {
+	leader := leaderWithBacklog(8, "EURUSD", "GBPUSD")
+	require.NoError(t, leader.reportStatus(context.Background(),
+		&pb.StreamStatus{Symbol: "GBPUSD", State: pb.StreamState_STREAM_STATE_HALTED, TimestampMs: 1}))
+
+	follower := (&ReplicationConfig{Election: ElectionCluster}).source()
+	var recorded, broadcast []string
+	follower.record = func(ticks []*pb.Tick) {
+		for _, tick := range ticks {
+			recorded = append(recorded, tick.Symbol)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go follower.Stream(ctx, NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND), func(ticks []*pb.Tick) {
+		broadcast = append(broadcast, ticks[0].Symbol)
+	})
+	require.Eventually(t, func() bool { return follower.subscribers() == 1 }, time.Second, time.Millisecond)
+	statuses := make(chan *pb.StreamStatus, 4)
+	go follower.WatchStatus(ctx, func(status *pb.StreamStatus) error {
+		statuses <- status
+		return nil
+	})
+	require.Eventually(t, func() bool {
+		follower.mu.Lock()
+		defer follower.mu.Unlock()
+		return follower.report != nil
+	}, time.Second, time.Millisecond)
+
+	apply := func(msg proto.Message) error {
+		switch m := msg.(type) {
+		case *pb.ReplicaState:
+			return follower.applyState(ctx, m)
+		default:
+			return follower.applyBatch(ctx, m.(*pb.ReplicaBatch))
+		}
+	}
+
+	// A restarted follower records the backlog without broadcasting it
+	msgs, _ := leader.catchUp(&pb.ReplicaSync{})
+	for _, msg := range msgs {
+		require.NoError(t, apply(msg))
+	}
+	assert.Equal(t, []string{"EURUSD", "GBPUSD"}, recorded)
+	assert.Empty(t, broadcast)
+	assert.Equal(t, pb.StreamState_STREAM_STATE_HALTED, (<-statuses).State)
+	symbols, err := follower.Symbols(ctx)
+	require.NoError(t, err)
+	assert.Len(t, symbols, 2)
+
+	// Live batches are broadcast, and batches it missed reconnecting too
+	leader.publish(context.Background(), []*pb.Tick{{Symbol: "USDJPY", Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}})
+	leader.publish(context.Background(), []*pb.Tick{{Symbol: "EURUSD", Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}})
+	_, err = follower.syncFrame()
+	require.NoError(t, err)
+	msgs, resumed := leader.catchUp(&pb.ReplicaSync{LeaderEpoch: follower.epoch, AfterSequence: follower.sequence})
+	require.True(t, resumed)
+	for _, msg := range msgs {
+		require.NoError(t, apply(msg))
+	}
+	assert.Equal(t, []string{"USDJPY", "EURUSD"}, broadcast)
+	assert.Len(t, recorded, 2)
+
+	// Replays are skipped and gaps end the stream
+	require.NoError(t, apply(msgs[0]))
+	assert.Len(t, broadcast, 2)
+	err = follower.applyBatch(ctx, &pb.ReplicaBatch{LeaderEpoch: follower.epoch, Sequence: follower.sequence + 2})
+	assert.ErrorContains(t, err, "replication gap")
+
+	// A full state without the halt ends it
+	require.NoError(t, leader.reportStatus(context.Background(),
+		&pb.StreamStatus{Symbol: "GBPUSD", State: pb.StreamState_STREAM_STATE_LIVE, TimestampMs: 2}))
+	msgs, _ = leader.catchUp(&pb.ReplicaSync{})
+	require.NoError(t, apply(msgs[0]))
+	assert.Equal(t, pb.StreamState_STREAM_STATE_LIVE, (<-statuses).State)
+}
+
+func TestStartRejectsReplicationWithoutCluster(t *testing.T) {
+	config := DefaultConfig()
+	config.Replication = &ReplicationConfig{Election: ElectionCluster}
+	err := NewServer(config).Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLUSTER_ADVERTISE_ADDR")
+}
+
+func TestStartRejectsReplicationWithoutClusterSecret(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.Replication = &ReplicationConfig{Election: ElectionCluster}
+	config.ClusterAdvertiseAddr = "127.0.0.1:9090"
+	err := NewServer(config).Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLUSTER_SECRET")
+}
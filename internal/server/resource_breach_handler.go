@@ -3,12 +3,14 @@ package server
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net"
 	"runtime"
 	"sync/atomic"
 	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 )
 
 // ResourceBreachHandler manages graceful degradation when resource limits are exceeded
@@ -26,8 +28,13 @@ type ResourceBreachHandler struct {
 	enableGracefulDegradation atomic.Bool
 	rejectNewConnections     atomic.Bool
 	
+	// Load shedding while memory or FDs are breached; set before monitoring starts
+	shedBatch int
+	shed      func(n int, resource string) int
+	
 	// Metrics
 	connectionsRejected uint64
+	connectionsShed     uint64
 	degradationEvents   uint64
 }
 
@@ -44,6 +51,15 @@ func NewResourceBreachHandler(logger *slog.Logger, monitor *ResourceMonitor) *Re
 	return handler
 }
 
+// SetLoadShedder makes the handler close existing connections, not just
+// refuse new ones, while memory or file descriptors are breached. On every
+// check shed is asked to close up to n connections and returns how many it
+// closed. n <= 0 disables shedding.
+func (rbh *ResourceBreachHandler) SetLoadShedder(n int, shed func(n int, resource string) int) {
+	rbh.shedBatch = n
+	rbh.shed = shed
+}
+
 // CheckResourceLimits evaluates current resource usage and triggers breach handling
 func (rbh *ResourceBreachHandler) CheckResourceLimits() {
 	if rbh.resourceMonitor == nil {
@@ -70,12 +86,15 @@ func (rbh *ResourceBreachHandler) CheckResourceLimits() {
 		rbh.clearFDBreach()
 	}
 	
-	// Check goroutine count
-	if usage.GoroutineCount > 50000 {
+	// Check goroutine count against the monitor's limit, which scales with memory
+	rbh.resourceMonitor.mutex.RLock()
+	maxGoroutines := rbh.resourceMonitor.maxGoroutines
+	rbh.resourceMonitor.mutex.RUnlock()
+	if maxGoroutines > 0 && int64(usage.GoroutineCount) > maxGoroutines {
 		if !rbh.goroutineBreach.Load() {
 			rbh.handleGoroutineBreach(usage.GoroutineCount)
 		}
-	} else if rbh.goroutineBreach.Load() && usage.GoroutineCount < 40000 {
+	} else if rbh.goroutineBreach.Load() && float64(usage.GoroutineCount) < float64(maxGoroutines)*0.8 {
 		rbh.clearGoroutineBreach()
 	}
 	
@@ -87,6 +106,33 @@ func (rbh *ResourceBreachHandler) CheckResourceLimits() {
 	} else if rbh.connectionBreach.Load() && usage.ActiveConnections < 90000 {
 		rbh.clearConnectionBreach()
 	}
+	
+	rbh.shedLoad()
+}
+
+// shedLoad closes a batch of connections while a breach that connections
+// themselves consume is active.
+func (rbh *ResourceBreachHandler) shedLoad() {
+	if rbh.shed == nil || rbh.shedBatch <= 0 {
+		return
+	}
+	
+	var resource string
+	switch {
+	case rbh.memoryBreach.Load():
+		resource = "memory"
+	case rbh.fdBreach.Load():
+		resource = "file_descriptors"
+	default:
+		return
+	}
+	
+	if shed := rbh.shed(rbh.shedBatch, resource); shed > 0 {
+		atomic.AddUint64(&rbh.connectionsShed, uint64(shed))
+		rbh.logger.Warn("shed connections to relieve resource breach",
+			"resource", resource,
+			"connections", shed)
+	}
 }
 
 // ShouldRejectConnection determines if new connections should be rejected
@@ -111,17 +157,20 @@ func (rbh *ResourceBreachHandler) GetRejectionReason() string {
 	return "server resource limit exceeded"
 }
 
-// RejectConnection handles rejecting a new connection gracefully
-func (rbh *ResourceBreachHandler) RejectConnection(conn net.Conn) {
+// RejectConnection handles rejecting a new connection gracefully, sending a
+// SERVER_BUSY error that asks the client to wait retryAfter before
+// reconnecting.
+func (rbh *ResourceBreachHandler) RejectConnection(conn net.Conn, retryAfter time.Duration) {
 	atomic.AddUint64(&rbh.connectionsRejected, 1)
 	
-	// Send a brief error message before closing
+	// Send a brief error frame before closing
 	reason := rbh.GetRejectionReason()
-	errorMsg := fmt.Sprintf("503 Service Unavailable: %s\n", reason)
-	
-	// Set a short write timeout
-	conn.SetWriteDeadline(time.Now().Add(1 * time.Second))
-	conn.Write([]byte(errorMsg))
+	message, _ := getStandardErrorMessage(pb.ErrorCode_ERROR_CODE_SERVER_BUSY)
+	if frame, err := errorFrame(pb.ErrorCode_ERROR_CODE_SERVER_BUSY, message, reason, retryAfter); err == nil {
+		// Set a short write timeout
+		conn.SetWriteDeadline(time.Now().Add(1 * time.Second))
+		protocol.NewFrameWriter(conn).WriteFrame(frame)
+	}
 	conn.Close()
 	
 	rbh.logger.Warn("rejected connection due to resource breach",
@@ -162,6 +211,14 @@ func (rbh *ResourceBreachHandler) handleFDBreach(usage float64) {
 		"action", "rejecting_new_connections")
 }
 
+// ReportFDExhaustion enters the file descriptor breach on an EMFILE or ENFILE
+// from Accept. The next check clears it once usage is back below 80%.
+func (rbh *ResourceBreachHandler) ReportFDExhaustion() {
+	if !rbh.fdBreach.Load() {
+		rbh.handleFDBreach(100)
+	}
+}
+
 // clearFDBreach clears file descriptor breach state
 func (rbh *ResourceBreachHandler) clearFDBreach() {
 	rbh.fdBreach.Store(false)
@@ -221,6 +278,12 @@ func (rbh *ResourceBreachHandler) checkAllBreaches() {
 	}
 }
 
+// UnderPressure reports whether any resource limit is currently breached.
+func (rbh *ResourceBreachHandler) UnderPressure() bool {
+	return rbh.memoryBreach.Load() || rbh.fdBreach.Load() ||
+		rbh.goroutineBreach.Load() || rbh.connectionBreach.Load()
+}
+
 // GetBreachStats returns current breach statistics
 func (rbh *ResourceBreachHandler) GetBreachStats() map[string]interface{} {
 	return map[string]interface{}{
@@ -230,6 +293,7 @@ func (rbh *ResourceBreachHandler) GetBreachStats() map[string]interface{} {
 		"connection_breach":   rbh.connectionBreach.Load(),
 		"rejecting_connections": rbh.rejectNewConnections.Load(),
 		"connections_rejected":  atomic.LoadUint64(&rbh.connectionsRejected),
+		"connections_shed":      atomic.LoadUint64(&rbh.connectionsShed),
 		"degradation_events":   atomic.LoadUint64(&rbh.degradationEvents),
 	}
 }
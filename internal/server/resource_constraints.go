@@ -20,6 +20,7 @@ type UlimitConfig struct {
 	// File descriptor limits
 	MaxOpenFiles     uint64 // RLIMIT_NOFILE
 	MaxOpenFilesSoft uint64 // Soft limit for open files
+	MinOpenFiles     uint64 // Applying fails if the soft limit ends below this (0 disables)
 	
 	// Memory limits
 	MaxMemorySize     uint64 // RLIMIT_AS (virtual memory)
@@ -38,17 +39,23 @@ type UlimitConfig struct {
 }
 
 // NewResourceConstraints creates a new resource constraints manager
-func NewResourceConstraints() *ResourceConstraints {
+func NewResourceConstraints(logger *slog.Logger) *ResourceConstraints {
 	return &ResourceConstraints{
-		logger: slog.Default().With("component", "resource_constraints"),
+		logger: logger.With("component", "resource_constraints"),
 	}
 }
 
 // LoadConfigFromEnv loads ulimit configuration from environment variables
 func (rc *ResourceConstraints) LoadConfigFromEnv() *UlimitConfig {
+	return LoadUlimitConfigFromEnv()
+}
+
+// LoadUlimitConfigFromEnv loads ulimit configuration from environment variables
+func LoadUlimitConfigFromEnv() *UlimitConfig {
 	config := &UlimitConfig{
 		MaxOpenFiles:     65536,  // Default: 64K file descriptors
 		MaxOpenFilesSoft: 32768,  // Default: 32K soft limit
+		MinOpenFiles:     1024,   // Default: refuse to run below 1K
 		MaxMemorySize:    0,      // 0 = unlimited
 		MaxDataSize:      0,      // 0 = unlimited
 		MaxStackSize:     8388608, // Default: 8MB stack
@@ -71,6 +78,12 @@ func (rc *ResourceConstraints) LoadConfigFromEnv() *UlimitConfig {
 		}
 	}
 	
+	if val := os.Getenv("ULIMIT_MIN_OPEN_FILES"); val != "" {
+		if parsed, err := strconv.ParseUint(val, 10, 64); err == nil {
+			config.MinOpenFiles = parsed
+		}
+	}
+	
 	if val := os.Getenv("ULIMIT_MAX_MEMORY_SIZE"); val != "" {
 		if parsed, err := strconv.ParseUint(val, 10, 64); err == nil {
 			config.MaxMemorySize = parsed
@@ -124,9 +137,24 @@ func (rc *ResourceConstraints) ApplyResourceLimits(config *UlimitConfig) error {
 		"max_processes", config.MaxProcesses,
 	)
 	
-	// Set file descriptor limits
+	// Set file descriptor limits, raising only the soft limit when the
+	// process may not raise its hard limit
 	if err := rc.setRlimit(syscall.RLIMIT_NOFILE, config.MaxOpenFilesSoft, config.MaxOpenFiles); err != nil {
-		return fmt.Errorf("failed to set file descriptor limit: %w", err)
+		rc.logger.Warn("failed to set hard file descriptor limit, keeping the current one",
+			"requested", config.MaxOpenFiles, "error", err)
+		if err := rc.raiseOpenFilesSoft(config.MaxOpenFilesSoft); err != nil {
+			return fmt.Errorf("failed to set file descriptor limit: %w", err)
+		}
+	}
+	if config.MinOpenFiles > 0 {
+		var rLimit syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit); err != nil {
+			return fmt.Errorf("failed to get file descriptor limit: %w", err)
+		}
+		if rLimit.Cur < config.MinOpenFiles {
+			return fmt.Errorf("file descriptor limit (%d) is below the required minimum (%d)",
+				rLimit.Cur, config.MinOpenFiles)
+		}
 	}
 	
 	// Set virtual memory limit
@@ -190,6 +218,19 @@ func (rc *ResourceConstraints) setRlimit(resource int, soft, hard uint64) error
 	return syscall.Setrlimit(resource, &rLimit)
 }
 
+// raiseOpenFilesSoft sets the soft file descriptor limit to soft, capped at
+// the current hard limit.
+func (rc *ResourceConstraints) raiseOpenFilesSoft(soft uint64) error {
+	var rLimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit); err != nil {
+		return err
+	}
+	if soft > rLimit.Max {
+		soft = rLimit.Max
+	}
+	return rc.setRlimit(syscall.RLIMIT_NOFILE, soft, rLimit.Max)
+}
+
 // GetCurrentLimits returns current OS-level resource limits
 func (rc *ResourceConstraints) GetCurrentLimits() (map[string]syscall.Rlimit, error) {
 	limits := make(map[string]syscall.Rlimit)
@@ -264,6 +305,11 @@ func (rc *ResourceConstraints) ValidateResourceLimits(config *UlimitConfig) erro
 			config.MaxOpenFilesSoft, config.MaxOpenFiles)
 	}
 	
+	if config.MinOpenFiles > config.MaxOpenFiles {
+		return fmt.Errorf("required file descriptors (%d) cannot exceed hard limit (%d)",
+			config.MinOpenFiles, config.MaxOpenFiles)
+	}
+	
 	// Validate minimum file descriptor requirements
 	minFDs := uint64(1024)
 	if config.MaxOpenFiles < minFDs {
@@ -345,3 +391,36 @@ func (rc *ResourceConstraints) CheckResourceHealth() []string {
 	
 	return issues
 }
+
+// applyUlimits validates and applies Config.Ulimits, logging the limits
+// before and after. It fails if the file descriptor minimum is not reached.
+func (s *Server) applyUlimits() error {
+	rc := s.resourceConstraints
+	if err := rc.ValidateResourceLimits(s.config.Ulimits); err != nil {
+		return err
+	}
+	before, _ := rc.GetCurrentLimits()
+	if err := rc.ApplyResourceLimits(s.config.Ulimits); err != nil {
+		return err
+	}
+	after, _ := rc.GetCurrentLimits()
+	
+	s.logger.Info("OS resource limits applied",
+		slog.Group("before", limitAttrs(before)...),
+		slog.Group("after", limitAttrs(after)...),
+	)
+	if nofile, ok := after["RLIMIT_NOFILE"]; ok && nofile.Cur < uint64(s.config.MaxConnections) {
+		s.logger.Warn("file descriptor limit is below the connection limit",
+			"soft_limit", nofile.Cur, "max_connections", s.config.MaxConnections)
+	}
+	return nil
+}
+
+// limitAttrs formats limits as "soft/hard" log attributes.
+func limitAttrs(limits map[string]syscall.Rlimit) []interface{} {
+	attrs := make([]interface{}, 0, 2*len(limits))
+	for name, limit := range limits {
+		attrs = append(attrs, name, fmt.Sprintf("%d/%d", limit.Cur, limit.Max))
+	}
+	return attrs
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// currentOpenFiles returns the process's RLIMIT_NOFILE and restores it when
+// the test ends.
+func currentOpenFiles(t *testing.T) syscall.Rlimit {
+	t.Helper()
+	var limit syscall.Rlimit
+	require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit))
+	t.Cleanup(func() {
+		restored := limit
+		_ = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &restored)
+	})
+	return limit
+}
+
+func TestApplyUlimitsFailsBelowMinimum(t *testing.T) {
+	limit := currentOpenFiles(t)
+	if limit.Cur < 2048 {
+		t.Skipf("soft RLIMIT_NOFILE %d too low to lower safely", limit.Cur)
+	}
+
+	config := DefaultConfig()
+	config.Ulimits = &UlimitConfig{
+		MaxOpenFiles:     limit.Max,
+		MaxOpenFilesSoft: limit.Cur - 1,
+		MinOpenFiles:     limit.Cur,
+	}
+	srv := NewServer(config)
+	err := srv.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "below the required minimum")
+}
+
+func TestValidateResourceLimitsMinimum(t *testing.T) {
+	rc := NewResourceConstraints(DefaultConfig().logger())
+	err := rc.ValidateResourceLimits(&UlimitConfig{MaxOpenFiles: 4096, MaxOpenFilesSoft: 2048, MinOpenFiles: 8192})
+	assert.Error(t, err)
+	assert.NoError(t, rc.ValidateResourceLimits(&UlimitConfig{MaxOpenFiles: 4096, MaxOpenFilesSoft: 2048, MinOpenFiles: 2048}))
+}
+
+func TestLoadUlimitConfigFromEnv(t *testing.T) {
+	t.Setenv("APPLY_ULIMITS", "true")
+	t.Setenv("ULIMIT_MIN_OPEN_FILES", "4096")
+	config := DefaultConfig()
+	LoadConfigFromEnv(config)
+	require.NotNil(t, config.Ulimits)
+	assert.Equal(t, uint64(4096), config.Ulimits.MinOpenFiles)
+
+	assert.Nil(t, DefaultConfig().Ulimits, "limits are left alone unless enabled")
+}
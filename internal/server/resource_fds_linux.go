@@ -0,0 +1,19 @@
+package server
+
+import "os"
+
+// countOpenFDs returns the number of file descriptors the process holds, read
+// from /proc/self/fd.
+func countOpenFDs() (int64, bool) {
+	dir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, false
+	}
+	// Exclude the descriptor opened to read the directory itself
+	return int64(len(names)) - 1, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package server
+
+// countOpenFDs is unsupported outside Linux; callers fall back to an estimate.
+func countOpenFDs() (int64, bool) {
+	return 0, false
+}
@@ -41,6 +41,9 @@ type ResourceMonitor struct {
 	warningThreshold float64
 	criticalThreshold float64
 	
+	// Live connection count from the server; nil leaves CheckConnectionLimit to callers
+	connectionSource func() int64
+	
 	// Alert callbacks
 	alertHandlers []ResourceAlertHandler
 	logger        *slog.Logger
@@ -80,7 +83,7 @@ type ResourceLimits struct {
 }
 
 // NewResourceMonitor creates a new resource monitor
-func NewResourceMonitor(limits ResourceLimits) *ResourceMonitor {
+func NewResourceMonitor(limits ResourceLimits, logger *slog.Logger) *ResourceMonitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &ResourceMonitor{
@@ -92,7 +95,7 @@ func NewResourceMonitor(limits ResourceLimits) *ResourceMonitor {
 		maxConnections:     limits.MaxConnections,
 		warningThreshold:   limits.WarningThreshold,
 		criticalThreshold:  limits.CriticalThreshold,
-		logger:             slog.Default().With("component", "resource_monitor"),
+		logger:             logger.With("component", "resource_monitor"),
 		alertHandlers:      []ResourceAlertHandler{},
 	}
 }
@@ -129,6 +132,15 @@ func (rm *ResourceMonitor) AddAlertHandler(handler ResourceAlertHandler) {
 	rm.alertHandlers = append(rm.alertHandlers, handler)
 }
 
+// SetConnectionSource feeds the monitoring loop the server's live connection
+// count, so connection limits and the FD fallback estimate track real state.
+func (rm *ResourceMonitor) SetConnectionSource(count func() int64) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	
+	rm.connectionSource = count
+}
+
 // Start begins resource monitoring
 func (rm *ResourceMonitor) Start() {
 	rm.wg.Add(1)
@@ -186,8 +198,7 @@ func (rm *ResourceMonitor) CheckFileDescriptorLimit() bool {
 		return true
 	}
 	
-	// Estimate current FD usage (this is approximate)
-	currentFDs := rm.estimateFileDescriptorUsage()
+	currentFDs := rm.fileDescriptorUsage()
 	atomic.StoreInt64(&rm.currentFDs, currentFDs)
 	
 	maxFDs := rm.maxFileDescriptors
@@ -271,18 +282,38 @@ func (rm *ResourceMonitor) monitoringLoop() {
 		case <-rm.ctx.Done():
 			return
 		case <-ticker.C:
-			rm.CheckMemoryLimit()
-			rm.CheckFileDescriptorLimit()
-			rm.CheckGoroutineLimit()
+			rm.checkAll()
 		}
 	}
 }
 
-// estimateFileDescriptorUsage provides an estimate of current FD usage
-func (rm *ResourceMonitor) estimateFileDescriptorUsage() int64 {
-	// This is a rough estimate based on typical server usage
-	// In a real implementation, you might want to scan /proc/self/fd or use other methods
+// checkAll refreshes every tracked resource. Connections are checked first so
+// the FD estimate, where one is needed, uses the current count.
+func (rm *ResourceMonitor) checkAll() {
+	rm.mutex.RLock()
+	source := rm.connectionSource
+	rm.mutex.RUnlock()
 	
+	if source != nil {
+		rm.CheckConnectionLimit(source())
+	}
+	rm.CheckMemoryLimit()
+	rm.CheckFileDescriptorLimit()
+	rm.CheckGoroutineLimit()
+}
+
+// fileDescriptorUsage returns the number of open FDs, counted exactly where
+// the platform allows and estimated otherwise.
+func (rm *ResourceMonitor) fileDescriptorUsage() int64 {
+	if n, ok := countOpenFDs(); ok {
+		return n
+	}
+	return rm.estimateFileDescriptorUsage()
+}
+
+// estimateFileDescriptorUsage provides an estimate of current FD usage on
+// platforms where open descriptors cannot be counted
+func (rm *ResourceMonitor) estimateFileDescriptorUsage() int64 {
 	// Base FDs: stdin, stdout, stderr, listening socket
 	baseFDs := int64(4)
 	
@@ -321,7 +352,7 @@ func (rm *ResourceMonitor) GetCurrentUsage() ResourceUsage {
 	
 	// Calculate FD usage percentage
 	if rm.maxFileDescriptors > 0 {
-		currentFDs := rm.estimateFileDescriptorUsage()
+		currentFDs := atomic.LoadInt64(&rm.currentFDs)
 		usage.FDUsagePercent = float64(currentFDs) / float64(rm.maxFileDescriptors) * 100.0
 	}
 	
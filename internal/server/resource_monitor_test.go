@@ -0,0 +1,43 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceMonitorReadsConnectionSource(t *testing.T) {
+	rm := NewResourceMonitor(ResourceLimits{MaxConnections: 100, MaxFileDescriptors: 1 << 20}, slog.Default())
+
+	var live int64 = 40
+	rm.SetConnectionSource(func() int64 { return live })
+	rm.checkAll()
+	assert.Equal(t, 40, rm.GetCurrentUsage().ActiveConnections)
+	assert.InDelta(t, 0.4, rm.GetResourceUsage()["connections"], 0.001)
+	assert.Greater(t, rm.GetCurrentUsage().FDUsagePercent, 0.0)
+
+	live = 75
+	rm.checkAll()
+	assert.Equal(t, 75, rm.GetCurrentUsage().ActiveConnections)
+}
+
+func TestCountOpenFDs(t *testing.T) {
+	before, ok := countOpenFDs()
+	if runtime.GOOS != "linux" {
+		assert.False(t, ok)
+		return
+	}
+	require.True(t, ok)
+
+	f, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer f.Close()
+
+	after, ok := countOpenFDs()
+	require.True(t, ok)
+	assert.Equal(t, before+1, after)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrResumeUnavailable is returned when a resume token is unknown, expired,
+	// already used or belongs to another user.
+	ErrResumeUnavailable = errors.New("no resumable subscription for token")
+)
+
+// parkedSubscription is a subscription whose connection dropped, kept for
+// the grace window so a reconnect can continue the same stream.
+type parkedSubscription struct {
+	username string
+	sub      *Subscription
+	batchSeq uint32        // Last sequence sent on the old connection
+	history  *BatchHistory // Recent batches, replayed after last_sequence
+	parkedAt time.Time
+}
+
+// ResumeStore parks subscriptions of dropped connections keyed by a
+// single-use resume token.
+type ResumeStore struct {
+	mu      sync.Mutex
+	parked  map[string]*parkedSubscription
+	grace   time.Duration
+	release func(*Subscription) // Frees resources of subscriptions that are never resumed
+
+	// Metrics
+	parkedTotal  uint64
+	resumedTotal uint64
+	expiredTotal uint64
+	rejected     uint64
+}
+
+// NewResumeStore creates a store holding parked subscriptions for grace.
+// release, if set, is called for each subscription that expires unresumed.
+func NewResumeStore(grace time.Duration, release func(*Subscription)) *ResumeStore {
+	return &ResumeStore{
+		parked:  make(map[string]*parkedSubscription),
+		grace:   grace,
+		release: release,
+	}
+}
+
+// newResumeToken returns a random 128-bit token in hex.
+func newResumeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Park keeps p under token until it is taken or the grace window ends.
+func (rs *ResumeStore) Park(token string, p *parkedSubscription) {
+	rs.mu.Lock()
+	old := rs.parked[token]
+	rs.parked[token] = p
+	rs.mu.Unlock()
+
+	atomic.AddUint64(&rs.parkedTotal, 1)
+	if old != nil && rs.release != nil {
+		rs.release(old.sub)
+	}
+}
+
+// Take removes and returns the subscription parked under token for
+// username. Tokens are single use.
+func (rs *ResumeStore) Take(token, username string, now time.Time) (*parkedSubscription, error) {
+	rs.mu.Lock()
+	p, ok := rs.parked[token]
+	if ok && p.username == username {
+		delete(rs.parked, token)
+	}
+	rs.mu.Unlock()
+
+	if !ok || p.username != username {
+		atomic.AddUint64(&rs.rejected, 1)
+		return nil, ErrResumeUnavailable
+	}
+	if now.Sub(p.parkedAt) > rs.grace {
+		rs.expire(p)
+		atomic.AddUint64(&rs.rejected, 1)
+		return nil, ErrResumeUnavailable
+	}
+	atomic.AddUint64(&rs.resumedTotal, 1)
+	return p, nil
+}
+
+// Cleanup drops subscriptions parked for longer than the grace window.
+func (rs *ResumeStore) Cleanup(now time.Time) {
+	var expired []*parkedSubscription
+	rs.mu.Lock()
+	for token, p := range rs.parked {
+		if now.Sub(p.parkedAt) > rs.grace {
+			delete(rs.parked, token)
+			expired = append(expired, p)
+		}
+	}
+	rs.mu.Unlock()
+
+	for _, p := range expired {
+		rs.expire(p)
+	}
+}
+
+func (rs *ResumeStore) expire(p *parkedSubscription) {
+	atomic.AddUint64(&rs.expiredTotal, 1)
+	if rs.release != nil {
+		rs.release(p.sub)
+	}
+}
+
+// StartCleanupRoutine periodically expires parked subscriptions until ctx is done.
+func (rs *ResumeStore) StartCleanupRoutine(ctx context.Context) {
+	interval := rs.grace / 2
+	if interval <= 0 || interval > time.Minute {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				rs.Cleanup(now)
+			}
+		}
+	}()
+}
+
+// GetStats returns resume statistics.
+func (rs *ResumeStore) GetStats() map[string]interface{} {
+	rs.mu.Lock()
+	parked := len(rs.parked)
+	rs.mu.Unlock()
+
+	return map[string]interface{}{
+		"parked":         parked,
+		"parked_total":   atomic.LoadUint64(&rs.parkedTotal),
+		"resumed_total":  atomic.LoadUint64(&rs.resumedTotal),
+		"expired_total":  atomic.LoadUint64(&rs.expiredTotal),
+		"rejected_total": atomic.LoadUint64(&rs.rejected),
+		"grace_period":   rs.grace.String(),
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestResumeStoreTakeAndExpiry(t *testing.T) {
+	var released []*Subscription
+	store := NewResumeStore(time.Minute, func(sub *Subscription) { released = append(released, sub) })
+	now := time.Now()
+
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	store.Park("tok", &parkedSubscription{username: "alice", sub: sub, parkedAt: now})
+
+	_, err := store.Take("tok", "mallory", now)
+	assert.ErrorIs(t, err, ErrResumeUnavailable, "token is bound to its user")
+
+	p, err := store.Take("tok", "alice", now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Same(t, sub, p.sub)
+
+	_, err = store.Take("tok", "alice", now)
+	assert.ErrorIs(t, err, ErrResumeUnavailable, "tokens are single use")
+
+	// Expired entries are released, whether found by Cleanup or by a late Take
+	store.Park("a", &parkedSubscription{username: "alice", sub: sub, parkedAt: now})
+	store.Park("b", &parkedSubscription{username: "alice", sub: sub, parkedAt: now})
+	store.Cleanup(now.Add(2 * time.Minute))
+	assert.Len(t, released, 2)
+
+	store.Park("c", &parkedSubscription{username: "alice", sub: sub, parkedAt: now})
+	_, err = store.Take("c", "alice", now.Add(2*time.Minute))
+	assert.ErrorIs(t, err, ErrResumeUnavailable)
+	assert.Len(t, released, 3)
+
+	stats := store.GetStats()
+	assert.Equal(t, 0, stats["parked"])
+	assert.Equal(t, uint64(1), stats["resumed_total"])
+	assert.Equal(t, uint64(3), stats["expired_total"])
+}
+
+// resumeClient is one side of a pipe connection served by Handle.
+type resumeClient struct {
+	t      *testing.T
+	conn   *Connection
+	client net.Conn
+	reader *protocol.FrameReader
+	writer *protocol.FrameWriter
+	done   chan error
+}
+
+func dialResume(t *testing.T, srv *Server, config *Config) *resumeClient {
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	conn.SetAuthenticated(&auth.Session{Username: "alice", Authenticated: true})
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	handler := NewConnectionHandler(conn, config, srv)
+	handler.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	rc := &resumeClient{
+		t:      t,
+		conn:   conn,
+		client: clientSide,
+		reader: protocol.NewFrameReader(clientSide, config.MaxMessageSize),
+		writer: protocol.NewFrameWriter(clientSide),
+		done:   make(chan error, 1),
+	}
+	go func() { rc.done <- handler.Handle(context.Background()) }()
+	return rc
+}
+
+func (rc *resumeClient) send(msgType protocol.MessageType, msg proto.Message) {
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	require.NoError(rc.t, err)
+	rc.client.SetWriteDeadline(time.Now().Add(time.Second))
+	require.NoError(rc.t, rc.writer.WriteFrame(frame))
+}
+
+func (rc *resumeClient) read(want protocol.MessageType, msg proto.Message) {
+	rc.client.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := rc.reader.ReadFrame()
+	require.NoError(rc.t, err)
+	require.Equal(rc.t, want, frame.Type)
+	require.NoError(rc.t, proto.Unmarshal(frame.Payload, msg))
+}
+
+func (rc *resumeClient) readBatch() uint32 {
+	var batch pb.DataBatch
+	rc.read(protocol.MessageTypeDataBatch, &batch)
+	return batch.BatchSequence
+}
+
+// disconnect drops the client side and waits for Handle to park the subscription.
+func (rc *resumeClient) disconnect() {
+	rc.client.Close()
+	select {
+	case <-rc.done:
+	case <-time.After(time.Second):
+		rc.t.Fatal("Handle did not return after disconnect")
+	}
+}
+
+func TestResumeContinuesStream(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now()) // No generated ticks or heartbeat timeouts
+	config.GapFillBufferSize = 16
+	config.ResumeGracePeriod = time.Minute
+	srv := &Server{config: config, resume: NewResumeStore(config.ResumeGracePeriod, nil)}
+
+	first := dialResume(t, srv, config)
+	first.send(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+	var ack pb.AckResponse
+	first.read(protocol.MessageTypeACK, &ack)
+	token := ack.Metadata["resume_token"]
+	require.NotEmpty(t, token)
+	assert.Equal(t, "1m0s", ack.Metadata["resume_grace"])
+
+	for i := 1; i <= 3; i++ {
+		go first.conn.SendDataBatch([]*pb.Tick{{Symbol: "EURUSD"}})
+		assert.Equal(t, uint32(i), first.readBatch())
+	}
+	first.disconnect()
+	assert.Equal(t, 1, srv.resume.GetStats()["parked"])
+
+	// The client only processed batch 1 before the connection dropped
+	second := dialResume(t, srv, config)
+	second.send(protocol.MessageTypeResume, &pb.ResumeRequest{Token: token, LastSequence: 1})
+	second.read(protocol.MessageTypeACK, &ack)
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_RESUME, ack.AckType)
+	assert.Equal(t, "SUBSCRIPTION_MODE_SECOND", ack.Metadata["mode"])
+	assert.NotEqual(t, token, ack.Metadata["resume_token"], "each resume issues a new token")
+
+	assert.Equal(t, uint32(2), second.readBatch())
+	assert.Equal(t, uint32(3), second.readBatch())
+	go second.conn.SendDataBatch([]*pb.Tick{{Symbol: "EURUSD"}})
+	assert.Equal(t, uint32(4), second.readBatch(), "sequence continues without a new SUBSCRIBE")
+
+	// The old token is spent; the client may still subscribe afresh
+	third := dialResume(t, srv, config)
+	third.send(protocol.MessageTypeResume, &pb.ResumeRequest{Token: token, LastSequence: 3})
+	var errResp pb.ErrorResponse
+	third.read(protocol.MessageTypeError, &errResp)
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_RESUME_FAILED, errResp.Code)
+	third.send(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE})
+	third.read(protocol.MessageTypeACK, &ack)
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_SUBSCRIBE, ack.AckType)
+}
+
+func TestResumeExpiresOnConfiguredClock(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	config := DefaultConfig()
+	config.Clock = clock
+	config.ResumeGracePeriod = time.Minute
+	srv := &Server{config: config, resume: NewResumeStore(config.ResumeGracePeriod, nil)}
+
+	first := dialResume(t, srv, config)
+	first.send(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND})
+	var ack pb.AckResponse
+	first.read(protocol.MessageTypeACK, &ack)
+	token := ack.Metadata["resume_token"]
+	first.disconnect()
+
+	// The grace period runs on the server clock, not the wall clock
+	clock.Advance(2 * time.Minute)
+	second := dialResume(t, srv, config)
+	second.send(protocol.MessageTypeResume, &pb.ResumeRequest{Token: token})
+	var errResp pb.ErrorResponse
+	second.read(protocol.MessageTypeError, &errResp)
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_RESUME_FAILED, errResp.Code)
+}
+
+func TestResumeDisabledByDefault(t *testing.T) {
+	config := DefaultConfig()
+	assert.Zero(t, config.ResumeGracePeriod)
+
+	t.Setenv("RESUME_GRACE_PERIOD", "15s")
+	LoadConfigFromEnv(config)
+	assert.Equal(t, 15*time.Second, config.ResumeGracePeriod)
+}
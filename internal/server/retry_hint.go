@@ -0,0 +1,20 @@
+package server
+
+import (
+	"math/rand"
+	"time"
+)
+
+// retryAfterHint returns base plus up to half of base in random jitter, for
+// the retry_after_ms sent to clients that are told to back off. Clients
+// rejected at the same moment are spread out rather than all retrying
+// together, and none is asked to retry sooner than base.
+func retryAfterHint(base time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	if half := int64(base / 2); half > 0 {
+		return base + time.Duration(rand.Int63n(half))
+	}
+	return base
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/furkansarikaya/tick-storm/internal/secrets"
+)
+
+// ErrNoCertificate indicates a TLS handshake before any certificate was loaded.
+var ErrNoCertificate = errors.New("no TLS certificate loaded")
+
+// secretKeys are the secrets the server loads from Config.SecretsProvider.
+var secretKeys = []string{
+	secrets.KeyStreamUser,
+	secrets.KeyStreamPass,
+	secrets.KeyStreamPassHash,
+	secrets.KeyTLSCert,
+	secrets.KeyTLSKey,
+}
+
+// certificateHolder hands the latest certificate to each TLS handshake, so
+// rotating it affects new connections without rebuilding listeners.
+type certificateHolder struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// newCertificateHolder creates a holder serving the configured certificate
+// files, if any, until the secrets provider supplies a key pair.
+func newCertificateHolder(cfg *TLSConfig) (*certificateHolder, error) {
+	h := &certificateHolder{}
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server certificate: %w", err)
+		}
+		h.cert.Store(&cert)
+	}
+	return h, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (h *certificateHolder) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := h.cert.Load(); cert != nil {
+		return cert, nil
+	}
+	return nil, ErrNoCertificate
+}
+
+// startSecrets loads secrets from the configured provider, applies them and
+// keeps them refreshed in the background. Start fails if the first load does.
+func (s *Server) startSecrets() error {
+	if s.config.SecretsProvider == nil {
+		return nil
+	}
+
+	// Install the holder before any listener builds its TLS configuration,
+	// so rotations only swap the certificate it holds
+	if tlsCfg := s.config.TLS; tlsCfg != nil && tlsCfg.Enabled && tlsCfg.GetCertificate == nil {
+		holder, err := newCertificateHolder(tlsCfg)
+		if err != nil {
+			return err
+		}
+		s.certificates = holder
+		tlsCfg.GetCertificate = holder.GetCertificate
+	}
+
+	store := secrets.NewStore(s.config.SecretsProvider, secretKeys, s.config.SecretsRefreshInterval, s.logger)
+	if _, err := store.Refresh(s.ctx); err != nil {
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	if err := s.applySecrets(store.Values()); err != nil {
+		return err
+	}
+	if s.certificates != nil && s.certificates.cert.Load() == nil {
+		return fmt.Errorf("TLS is enabled without a certificate: set TLS_CERT_FILE and TLS_KEY_FILE or provide %s and %s as secrets",
+			secrets.KeyTLSCert, secrets.KeyTLSKey)
+	}
+	s.secrets = store
+
+	// Later rotations that fail to apply leave the previous values in use
+	store.OnChange(func(values map[string][]byte) {
+		if err := s.applySecrets(values); err != nil {
+			s.logger.Error("failed to apply rotated secrets", "error", err)
+		}
+	})
+	go store.Run(s.ctx)
+
+	s.logger.Info("secrets loaded", "provider", s.config.SecretsProvider.Name(),
+		"refresh_interval", s.config.SecretsRefreshInterval)
+	return nil
+}
+
+// applySecrets installs credentials and TLS key material from values. Keys
+// the provider does not hold keep their current setting. Rotated credentials
+// leave the previous ones valid for Config.SecretsRotationGrace.
+func (s *Server) applySecrets(values map[string][]byte) error {
+	certPEM, hasCert := values[secrets.KeyTLSCert]
+	keyPEM, hasKey := values[secrets.KeyTLSKey]
+	if hasCert != hasKey {
+		return fmt.Errorf("secrets %s and %s must be provided together", secrets.KeyTLSCert, secrets.KeyTLSKey)
+	}
+	if hasCert {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("invalid TLS key pair from secrets: %w", err)
+		}
+		// Without TLS there is no holder and the key pair goes unused
+		if s.certificates != nil {
+			s.certificates.cert.Store(&cert)
+		}
+	}
+
+	creds := s.authenticator.CurrentCredentials()
+	changed := false
+	if v, ok := values[secrets.KeyStreamUser]; ok {
+		creds.Username, changed = string(v), true
+	}
+	if v, ok := values[secrets.KeyStreamPass]; ok {
+		creds.Password, changed = string(v), true
+	}
+	if v, ok := values[secrets.KeyStreamPassHash]; ok {
+		creds.PasswordHash, changed = string(v), true
+	}
+	if !changed || creds == s.authenticator.CurrentCredentials() {
+		return nil
+	}
+	if s.secrets == nil {
+		// The first load replaces whatever the environment configured
+		s.authenticator.SetCredentials(creds)
+		return nil
+	}
+	// Clients still holding the old secret keep authenticating for the grace period
+	s.authenticator.RotateCredentials(creds, s.config.SecretsRotationGrace)
+	s.logger.Info("credentials rotated", "grace", s.config.SecretsRotationGrace)
+	return nil
+}
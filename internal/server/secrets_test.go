@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// writeSecret places a secret where a FileProvider rooted at dir finds it.
+func writeSecret(t *testing.T, dir, key string, value []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, key), value, 0o600))
+}
+
+// copySecret writes the contents of file as a secret.
+func copySecret(t *testing.T, dir, key, file string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	writeSecret(t, dir, key, data)
+}
+
+func TestServerRotatesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	certA, keyA := generateTestCertificate(t)
+	copySecret(t, dir, secrets.KeyTLSCert, certA)
+	copySecret(t, dir, secrets.KeyTLSKey, keyA)
+	writeSecret(t, dir, secrets.KeyStreamUser, []byte("svc"))
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("first\n"))
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = &TLSConfig{Enabled: true, MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS13}
+	config.SecretsProvider = &secrets.FileProvider{Dir: dir}
+	config.SecretsRefreshInterval = 0 // Rotations are driven by the test
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	creds := server.authenticator.CurrentCredentials()
+	assert.Equal(t, "svc", creds.Username)
+	assert.Equal(t, "first", creds.Password)
+
+	peerCert := func() []byte {
+		conn, err := tls.Dial("tcp", server.ListenAddr(), &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+	before := peerCert()
+
+	// Rotate both the password and the key pair in place
+	certB, keyB := generateTestCertificate(t)
+	copySecret(t, dir, secrets.KeyTLSCert, certB)
+	copySecret(t, dir, secrets.KeyTLSKey, keyB)
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("second"))
+	changed, err := server.secrets.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "second", server.authenticator.CurrentCredentials().Password)
+
+	// Clients still on the old password are accepted during the grace period
+	login := func(conn, password string) (*auth.Session, error) {
+		payload, _ := proto.Marshal(&pb.AuthRequest{Username: "svc", Password: password})
+		return server.authenticator.Authenticate(context.Background(), auth.Peer{ConnID: conn, Addr: "127.0.0.1:1"},
+			&protocol.Frame{Type: protocol.MessageTypeAuth, Payload: payload})
+	}
+	session, err := login("old", "first")
+	require.NoError(t, err)
+	assert.True(t, session.PreviousCredentials)
+	session, err = login("new", "second")
+	require.NoError(t, err)
+	assert.False(t, session.PreviousCredentials)
+	assert.WithinDuration(t, time.Now().Add(config.SecretsRotationGrace), server.authenticator.RotationGraceUntil(), time.Minute)
+
+	// Spaced out to stay under the DDoS burst limit
+	time.Sleep(150 * time.Millisecond)
+	after := peerCert()
+	assert.NotEqual(t, before, after, "new handshakes use the rotated certificate")
+
+	// A broken rotation keeps the previous key pair
+	writeSecret(t, dir, secrets.KeyTLSKey, []byte("not a key"))
+	_, err = server.secrets.Refresh(context.Background())
+	require.NoError(t, err)
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, after, peerCert())
+
+	stats := server.GetStats()["secrets"].(map[string]interface{})
+	assert.Equal(t, "file", stats["provider"])
+	assert.Contains(t, stats, "rotation_grace_until")
+}
+
+func TestServerStartFailsWithoutSecrets(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, secrets.KeyTLSCert, []byte("cert without key"))
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.SecretsProvider = &secrets.FileProvider{Dir: dir}
+
+	server := NewServer(config)
+	err := server.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must be provided together")
+}
+
+func TestServerRotationWithoutGrace(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, secrets.KeyStreamUser, []byte("svc"))
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("first"))
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = nil
+	config.SecretsProvider = &secrets.FileProvider{Dir: dir}
+	config.SecretsRefreshInterval = 0
+	config.SecretsRotationGrace = 0
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("second"))
+	_, err := server.secrets.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.True(t, server.authenticator.RotationGraceUntil().IsZero(), "old password is dropped at once")
+}
+
+func TestServerServesCertificateFirstSeenOnRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, secrets.KeyStreamUser, []byte("svc"))
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("first"))
+	certFile, keyFile := generateTestCertificate(t)
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = &TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS13}
+	config.SecretsProvider = &secrets.FileProvider{Dir: dir}
+	config.SecretsRefreshInterval = 0
+
+	server := NewServer(config)
+	require.NoError(t, server.Start())
+	defer server.Stop(context.Background())
+
+	peerCert := func() []byte {
+		conn, err := tls.Dial("tcp", server.ListenAddr(), &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+	before := peerCert()
+
+	// The configured files are served until the provider has a key pair
+	certB, keyB := generateTestCertificate(t)
+	copySecret(t, dir, secrets.KeyTLSCert, certB)
+	copySecret(t, dir, secrets.KeyTLSKey, keyB)
+	_, err := server.secrets.Refresh(context.Background())
+	require.NoError(t, err)
+
+	time.Sleep(150 * time.Millisecond)
+	assert.NotEqual(t, before, peerCert(), "the certificate from secrets is served")
+}
+
+func TestServerStartFailsWithoutCertificate(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, secrets.KeyStreamUser, []byte("svc"))
+	writeSecret(t, dir, secrets.KeyStreamPass, []byte("first"))
+
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.TLS = &TLSConfig{Enabled: true, MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS13}
+	config.SecretsProvider = &secrets.FileProvider{Dir: dir}
+
+	err := NewServer(config).Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without a certificate")
+}
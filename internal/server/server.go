@@ -4,6 +4,7 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -17,7 +18,11 @@ import (
 	"sync/atomic"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
 	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/secrets"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
 	"github.com/furkansarikaya/tick-storm/internal/protocol"
 	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
 )
@@ -35,36 +40,308 @@ type Config struct {
 	// Network settings
 	ListenAddr      string
 	MaxConnections  int
-	ReadTimeout     time.Duration
-	WriteTimeout    time.Duration
+	ReadTimeout     time.Duration // Read deadline until the client subscribes
 	KeepAlive       time.Duration
 	
 	// Network security
 	AllowCIDRs      []string
 	BlockCIDRs      []string
 	
+	// Per-IP concurrent connection ceiling (0 disables), applied below MaxConnections
+	MaxConnsPerIP          int
+	PerIPLimitPolicy       PerIPLimitPolicy
+	MaxConnsPerIPOverrides []string // "CIDR=limit" entries for trusted NAT gateways
+	
+	// Global accept rate across all listeners in connections/sec (0 disables),
+	// with bursts of up to AcceptBurst
+	AcceptRate  float64
+	AcceptBurst int
+	
+	// Runtime IP bans
+	IPBanFile           string        // Optional JSON file persisting bans across restarts
+	AutoBanAuthFailures int           // Auth failures within AutoBanWindow that trigger a ban (0 disables)
+	AutoBanWindow       time.Duration
+	AutoBanDuration     time.Duration
+	
+	// What happens when a connection authenticates with the client_id of a
+	// connected session: ClientPolicyAllow, ClientPolicyReject or
+	// ClientPolicyTakeover
+	DuplicateClientPolicy string
+	
+	// Bearer token for /admin endpoints on the health server (empty disables them)
+	AdminToken          string
+	
+	// Priority classes for QoS under resource pressure
+	UserPriorityClasses  []string                    // "username=class" entries
+	DefaultPriorityClass PriorityClass               // Class for unlisted users (silver if empty)
+	QoSPolicies          map[PriorityClass]QoSPolicy // Overrides DefaultQoSPolicies
+	
+	// Tenants sharing the deployment, resolved from username or SNI after auth
+	Tenants              []TenantConfig
+	
+	// Egress shaping per authenticated connection in bytes per second (0
+	// disables), with bursts of EgressBurst bytes (0 is one second's worth);
+	// UserEgressRates overrides the rate per user. Tenants may also cap the
+	// bandwidth their connections share.
+	EgressRate           float64
+	EgressBurst          int
+	UserEgressRates      []string // "username=bytes_per_second" entries
+	
+	// Server-wide egress budget in bytes per second (0 disables), shared by
+	// weighted fair queuing: under saturation each connection gets bandwidth
+	// in proportion to its priority class's EgressWeight
+	GlobalEgressRate     float64
+	GlobalEgressBurst    int
+	
+	// Trading hours per symbol group (empty streams around the clock) and
+	// what happens to ticks outside them: SessionPolicyPause or SessionPolicyFlag
+	TradingSessions      []TradingSession
+	SessionPolicy        string
+	
+	// Additional listeners served alongside ListenAddr
+	Listeners        []ListenerConfig
+	
+	// Listener socket tuning (Linux only) and an optional custom factory
+	ReusePort        bool
+	TCPFastOpenQueue int
+	ListenBacklog    int
+	TCPUserTimeout   time.Duration
+	ListenerFactory  ListenerFactory
+	
+	// PROXY protocol v1/v2 from load balancers
+	ProxyProtocol            bool
+	ProxyProtocolTrustedCIDRs []string     // Peers expected to send headers; required with ProxyProtocol
+	ProxyHeaderTimeout       time.Duration
+	
+	// GeoIP tagging and per-country policy
+	GeoIPDatabase       string        // CSV of network,country_code records (optional)
+	GeoIPAllowCountries []string      // ISO country codes; empty allows all not denied
+	GeoIPDenyCountries  []string
+	GeoIP               GeoIPResolver // Overrides GeoIPDatabase when set
+	
 	// TLS settings
 	TLS             *TLSConfig
 	
 	// TCP Performance settings
 	TCPReadBufferSize  int
 	TCPWriteBufferSize int
-	WriteDeadlineMS    int
+	WriteDeadlineMS    int // Deadline for each frame to be written, queueing included
 	MaxWriteQueueSize  int
 	
+	// Approximate memory a connection may hold in its write queue, pending
+	// batch and gap-fill history before it is dropped as a slow client (0 disables)
+	MaxConnMemoryBytes int64
+	
+	// Disk spill for slow consumers: connections in SpillClasses overflow a
+	// full write queue to a file in SpillDir of up to SpillMaxBytes instead of
+	// dropping frames (empty SpillDir disables)
+	SpillDir      string
+	SpillMaxBytes int64
+	SpillClasses  []PriorityClass
+	
+	// Lower GOGC and GOMEMLIMIT as memory approaches the resource monitor's
+	// thresholds, trading CPU for headroom
+	AdaptiveGC bool
+	
+	// Widen the default batch window towards AdaptiveBatchMaxWindow while
+	// clients are sent more than AdaptiveBatchTargetRate batches per second,
+	// and narrow it towards AdaptiveBatchMinWindow when load falls away
+	AdaptiveBatching        bool
+	AdaptiveBatchMinWindow  time.Duration
+	AdaptiveBatchMaxWindow  time.Duration
+	AdaptiveBatchTargetRate int
+	
+	// How long Shutdown reports not-ready on /ready before closing listeners,
+	// giving load balancers time to stop routing new connections
+	DrainReadinessDelay time.Duration
+	
+	// Connections closed with SERVER_BUSY on each breach check while memory
+	// or file descriptors are critically breached (0 only rejects new ones)
+	BreachShedConnections int
+	
+	// Minimum retry_after_ms hint sent with SERVER_BUSY, before jitter
+	BusyRetryAfter time.Duration
+	
 	// Protocol settings
 	MaxMessageSize  uint32
 	
+	// Symbol count, batch size, price, volume and timestamp bounds applied
+	// when validating messages, process-wide (nil uses the built-in limits)
+	ValidationLimits *protocol.Limits
+	
+	// Inbound frames per second per connection (0 disables), with bursts of up
+	// to FrameBurst; excess frames are answered with RATE_LIMITED and dropped
+	MaxFramesPerSecond float64
+	FrameBurst         int
+	
+	// Recoverable frame errors (unknown message type, undecodable or invalid
+	// payload) answered with an ERROR frame while the connection stays open;
+	// the next one closes it (0 closes on the first)
+	MaxFrameStrikes    int
+	
 	// Authentication
 	AuthTimeout     time.Duration
 	
+	// How long an authenticated client may take to SUBSCRIBE or RESUME (0 disables)
+	SubscribeTimeout time.Duration
+	
+	// Sessions older than MaxSessionAge are sent REAUTH_REQUIRED and must
+	// send a fresh AUTH within ReauthGrace or be disconnected (0 disables)
+	MaxSessionAge time.Duration
+	ReauthGrace   time.Duration
+	
+	// Secrets backend for credentials and TLS key material (nil uses env/files directly)
+	SecretsProvider        secrets.Provider
+	SecretsRefreshInterval time.Duration // How often secrets are re-read for rotation
+	SecretsRotationGrace   time.Duration // How long rotated-out credentials stay valid (0 replaces them at once)
+	
 	// Heartbeat settings
 	HeartbeatInterval time.Duration
 	HeartbeatTimeout  time.Duration
 	
+	// Read deadline per subscription mode once subscribed (unset derives from the heartbeat timeout)
+	SubscribedReadTimeouts map[pb.SubscriptionMode]time.Duration
+	
+	// Send clients an INFO notice when their clock skew exceeds this (0 disables)
+	ClockSkewWarnThreshold time.Duration
+	
+	// Send a KEEPALIVE frame to authenticated connections nothing has been
+	// written to for this long, for middleboxes that drop idle TCP despite
+	// TCP keepalive (0 disables)
+	FrameKeepaliveInterval time.Duration
+	
+	// Resolution of a timer wheel shared by heartbeat and connection stage
+	// timeouts, which then fire up to one tick late (0 gives each connection
+	// its own runtime timers)
+	TimerWheelTick time.Duration
+	
 	// Data delivery settings
 	BatchWindow    time.Duration
 	MaxBatchSize   int
+	
+	// Emit SECOND and MINUTE ticks from the synthetic and simulated sources
+	// on wall-clock boundaries, shifted by ModeAlignmentOffset, rather than
+	// relative to when each subscription started
+	AlignModeBoundaries bool
+	ModeAlignmentOffset time.Duration
+	
+	// Bounds for per-subscription overrides via SUBSCRIBE metadata
+	SubscriptionMaxBatchSize   int
+	SubscriptionMinBatchWindow time.Duration
+	SubscriptionMaxBatchWindow time.Duration
+	
+	// At-least-once delivery settings
+	MaxUnackedBatches    int
+	DeliveryRetentionTTL time.Duration
+	
+	// Batches kept per connection to answer gap-fill requests (0 disables)
+	GapFillBufferSize    int
+	
+	// Symbols whose latest tick is kept to send new subscribers a snapshot (0 disables)
+	SnapshotMaxSymbols   int
+	
+	// Recent ticks kept per symbol to answer HISTORY requests: how far back
+	// (0 disables), how many ticks per symbol and mode, and how many symbols
+	HistoryWindow        time.Duration
+	HistoryMaxTicks      int
+	HistoryMaxSymbols    int
+	
+	// How long a dropped connection's subscription waits for RESUME (0 disables)
+	ResumeGracePeriod    time.Duration
+	
+	// Symbols with their own publish metric series; empty admits the first
+	// MetricsMaxSymbols seen, the rest are reported as "other"
+	MetricsSymbols    []string
+	MetricsMaxSymbols int
+	
+	// Push metrics to statsd or an OTLP collector alongside the Prometheus endpoint (nil disables)
+	MetricsExporter       MetricsExporter
+	MetricsExportInterval time.Duration
+	
+	// Usage accounting for billing: per-user records closed every
+	// UsageInterval (0 disables), kept for UsageRetention and passed to
+	// UsageExporter when set
+	UsageInterval  time.Duration
+	UsageRetention time.Duration
+	UsageExporter  UsageExporter
+	
+	// Cumulative business counters and closed usage records saved to
+	// MetricsStateFile every MetricsStateInterval and on shutdown, and
+	// restored on startup (empty disables)
+	MetricsStateFile     string
+	MetricsStateInterval time.Duration
+	
+	// Final GetStats written as JSON to StatsFile on shutdown for
+	// post-mortem analysis (empty disables)
+	StatsFile string
+	
+	// Delivery SLO: SLOTarget of batches handed to connections within
+	// SLOLatencyThreshold over a rolling SLOWindow (zero SLOWindow disables)
+	SLOTarget           float64
+	SLOLatencyThreshold time.Duration
+	SLOWindow           time.Duration
+	
+	// Check outbound batches against the protocol and drop corrupt ones:
+	// OutboundValidationOff, OutboundValidationSample (checks a
+	// OutboundValidationSampleRate fraction of batches) or OutboundValidationFull
+	OutboundValidation           string
+	OutboundValidationSampleRate float64
+	
+	// Ops HTTP server for probes, metrics, autoscaling and admin endpoints
+	// (empty OpsListenAddr disables it)
+	OpsListenAddr string
+	HealthPath    string
+	ReadyPath     string
+	MetricsPath   string
+	
+	// Gossip membership between instances over their ops servers (empty
+	// ClusterAdvertiseAddr disables it). Seeds are peers' ops addresses;
+	// ClusterSecret, when set, must match on every instance.
+	ClusterAdvertiseAddr    string
+	ClusterSeeds            []string
+	ClusterSecret           string
+	ClusterGossipInterval   time.Duration
+	ClusterPeerTimeout      time.Duration
+	
+	// Fault injection (staging/testing only)
+	Chaos          *ChaosConfig
+	
+	// OS resource limits applied by Start (nil leaves the process limits alone)
+	Ulimits        *UlimitConfig
+	
+	// Time source for tick generation, batching, and heartbeats (nil uses the wall clock)
+	Clock          Clock
+	
+	// Ticks streamed to subscribers (nil generates synthetic ticks)
+	DataSource     DataSource
+	
+	// Ticks pushed by producers over PUBLISH frames or POST /ingest,
+	// broadcast when DataSource is nil (nil disables)
+	Ingest         *IngestConfig
+	
+	// Recorded ticks replayed when DataSource and Ingest are nil (nil disables)
+	Replay         *ReplayConfig
+	
+	// Market simulator used when DataSource and Replay are nil (nil disables)
+	Simulation     *SimulationConfig
+	
+	// Leader-elected ingest: only the elected instance streams from the
+	// source above and replicates to the rest of the cluster (nil disables)
+	Replication    *ReplicationConfig
+	
+	// Built-in username/password authentication (nil reads STREAM_USER/STREAM_PASS)
+	Auth           *auth.Config
+	
+	// Custom authentication backend checking AUTH requests instead of the
+	// built-in credentials (nil uses the built-in)
+	Authenticator  authn.Authenticator
+	
+	// Pre-opened socket for the default listener, e.g. from systemd or an
+	// embedding application (nil listens on ListenAddr)
+	Listener       net.Listener
+	
+	// Logger for the server and its subsystems (nil uses slog.Default)
+	Logger         *slog.Logger
 }
 
 // DefaultConfig returns default server configuration.
@@ -72,21 +349,83 @@ func DefaultConfig() *Config {
 	return &Config{
 		ListenAddr:         ":8080",
 		MaxConnections:     100000,
+		PerIPLimitPolicy:   PerIPPolicyReject,
+		AutoBanWindow:      5 * time.Minute,
+		AutoBanDuration:    10 * time.Minute,
+		ProxyHeaderTimeout: 5 * time.Second,
 		ReadTimeout:        30 * time.Second,
-		WriteTimeout:       5 * time.Second,
 		KeepAlive:          30 * time.Second,
 		TLS:                DefaultTLSConfig(),
 		TCPReadBufferSize:  65536,  // 64KB
 		TCPWriteBufferSize: 65536,  // 64KB
 		WriteDeadlineMS:    5000,   // 5s default
 		MaxWriteQueueSize:  1000,   // Max queued writes per connection
+		MaxConnMemoryBytes: 16 << 20, // 16MB per connection
+		SpillMaxBytes:      256 << 20, // 256MB per connection
+		SpillClasses:       []PriorityClass{PriorityGold},
+		BreachShedConnections: 100,
+		BusyRetryAfter:     5 * time.Second,
 		MaxMessageSize:     protocol.DefaultMaxMessageSize,
 		AuthTimeout:        10 * time.Second,
+		SubscribeTimeout:   30 * time.Second,
+		ReauthGrace:        30 * time.Second,
+		SecretsRefreshInterval: 5 * time.Minute,
+		SecretsRotationGrace:   15 * time.Minute,
 		HeartbeatInterval:  15 * time.Second,
 		HeartbeatTimeout:   20 * time.Second,
 		BatchWindow:        5 * time.Millisecond,
 		MaxBatchSize:       100,
+		SubscriptionMaxBatchSize:   1000,
+		SubscriptionMinBatchWindow: 1 * time.Millisecond,
+		SubscriptionMaxBatchWindow: 1 * time.Second,
+		AdaptiveBatchMinWindow:     1 * time.Millisecond,
+		AdaptiveBatchMaxWindow:     50 * time.Millisecond,
+		AdaptiveBatchTargetRate:    20000,
+		MaxUnackedBatches:  1000,
+		DeliveryRetentionTTL: 5 * time.Minute,
+		GapFillBufferSize:  256,
+		MetricsMaxSymbols:  50,
+		SnapshotMaxSymbols: 10000,
+		HistoryMaxTicks:    3600,
+		HistoryMaxSymbols:  1000,
+		SessionPolicy:      SessionPolicyPause,
+		DuplicateClientPolicy: ClientPolicyAllow,
+		MetricsExportInterval: 10 * time.Second,
+		UsageRetention:     24 * time.Hour,
+		MetricsStateInterval: time.Minute,
+		SLOTarget:          0.999,
+		SLOLatencyThreshold: 50 * time.Millisecond,
+		SLOWindow:          time.Hour,
+		OutboundValidation: OutboundValidationOff,
+		OutboundValidationSampleRate: 0.01,
+		OpsListenAddr:      ":9090",
+		HealthPath:         "/healthz",
+		ReadyPath:          "/ready",
+		MetricsPath:        "/metrics",
+		ClusterGossipInterval: time.Second,
+		ClusterPeerTimeout:    10 * time.Second,
+		Chaos:              DefaultChaosConfig(),
+	}
+}
+
+// logger returns the configured logger, defaulting to slog.Default.
+func (c *Config) logger() *slog.Logger {
+	if c == nil || c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// defaultWriteDeadline applies when WriteDeadlineMS is not positive.
+const defaultWriteDeadline = 5 * time.Second
+
+// writeDeadline returns how long a frame may take to be written, from being
+// queued to reaching the socket.
+func (c *Config) writeDeadline() time.Duration {
+	if c.WriteDeadlineMS <= 0 {
+		return defaultWriteDeadline
 	}
+	return time.Duration(c.WriteDeadlineMS) * time.Millisecond
 }
 
 // LoadConfigFromEnv loads configuration from environment variables.
@@ -118,6 +457,18 @@ func LoadConfigFromEnv(cfg *Config) {
 		LoadTLSConfigFromEnv(cfg.TLS)
 	}
 	
+	// Load chaos configuration from environment
+	if cfg.Chaos != nil {
+		LoadChaosConfigFromEnv(cfg.Chaos)
+	}
+	
+	// OS resource limits, from the ULIMIT_* variables
+	if v := os.Getenv("APPLY_ULIMITS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil && b {
+			cfg.Ulimits = LoadUlimitConfigFromEnv()
+		}
+	}
+	
 	if interval := os.Getenv("HEARTBEAT_INTERVAL"); interval != "" {
 		if d, err := time.ParseDuration(interval); err == nil {
 			cfg.HeartbeatInterval = d
@@ -144,6 +495,51 @@ func LoadConfigFromEnv(cfg *Config) {
 		}
 	}
 	
+	loadReadTimeoutsFromEnv(cfg)
+	
+	if v := os.Getenv("AUTH_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.AuthTimeout = d
+		} else {
+			slog.Warn("ignoring invalid AUTH_TIMEOUT", "value", v)
+		}
+	}
+	if v := os.Getenv("SUBSCRIBE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SubscribeTimeout = d
+		} else {
+			slog.Warn("ignoring invalid SUBSCRIBE_TIMEOUT", "value", v)
+		}
+	}
+	if v := os.Getenv("MAX_SESSION_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.MaxSessionAge = d
+		} else {
+			slog.Warn("ignoring invalid MAX_SESSION_AGE", "value", v)
+		}
+	}
+	if v := os.Getenv("REAUTH_GRACE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ReauthGrace = d
+		} else {
+			slog.Warn("ignoring invalid REAUTH_GRACE", "value", v)
+		}
+	}
+	
+	if threshold := os.Getenv("CLOCK_SKEW_WARN_THRESHOLD"); threshold != "" {
+		if d, err := time.ParseDuration(threshold); err == nil && d >= 0 {
+			cfg.ClockSkewWarnThreshold = d
+		}
+	}
+	
+	if v := os.Getenv("FRAME_KEEPALIVE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.FrameKeepaliveInterval = d
+		} else {
+			slog.Warn("ignoring invalid FRAME_KEEPALIVE_INTERVAL", "value", v)
+		}
+	}
+	
 	if batchWindow := os.Getenv("BATCH_WINDOW"); batchWindow != "" {
 		if d, err := time.ParseDuration(batchWindow); err == nil {
 			cfg.BatchWindow = d
@@ -171,8 +567,10 @@ func LoadConfigFromEnv(cfg *Config) {
 	}
 	
 	if writeDeadline := os.Getenv("WRITE_DEADLINE_MS"); writeDeadline != "" {
-		if ms, err := strconv.Atoi(writeDeadline); err == nil {
+		if ms, err := strconv.Atoi(writeDeadline); err == nil && ms > 0 {
 			cfg.WriteDeadlineMS = ms
+		} else {
+			slog.Warn("ignoring invalid WRITE_DEADLINE_MS", "value", writeDeadline)
 		}
 	}
 	
@@ -182,15 +580,300 @@ func LoadConfigFromEnv(cfg *Config) {
 		}
 	}
 
+	if v := os.Getenv("MAX_CONN_MEMORY_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.MaxConnMemoryBytes = n
+		}
+	}
+	if v := os.Getenv("SPILL_DIR"); v != "" {
+		cfg.SpillDir = v
+	}
+	if v := os.Getenv("SPILL_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			cfg.SpillMaxBytes = n
+		} else {
+			slog.Warn("ignoring invalid SPILL_MAX_BYTES", "value", v)
+		}
+	}
+	if v := os.Getenv("SPILL_CLASSES"); v != "" {
+		var classes []PriorityClass
+		for _, name := range splitAndTrimCSV(v) {
+			class, err := ParsePriorityClass(name)
+			if err != nil {
+				slog.Warn("ignoring invalid SPILL_CLASSES", "value", v, "error", err)
+				classes = nil
+				break
+			}
+			classes = append(classes, class)
+		}
+		if classes != nil {
+			cfg.SpillClasses = classes
+		}
+	}
+	if v := os.Getenv("DRAIN_READINESS_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.DrainReadinessDelay = d
+		}
+	}
+	if v := os.Getenv("BREACH_SHED_CONNECTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.BreachShedConnections = n
+		}
+	}
+	if v := os.Getenv("BUSY_RETRY_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.BusyRetryAfter = d
+		}
+	}
+	if v := os.Getenv("SLO_TARGET"); v != "" {
+		if target, err := strconv.ParseFloat(v, 64); err == nil && target > 0 && target < 1 {
+			cfg.SLOTarget = target
+		} else {
+			slog.Warn("ignoring invalid SLO_TARGET", "value", v)
+		}
+	}
+	if v := os.Getenv("OUTBOUND_VALIDATION"); v != "" {
+		switch mode := strings.ToLower(v); mode {
+		case OutboundValidationOff, OutboundValidationSample, OutboundValidationFull:
+			cfg.OutboundValidation = mode
+		default:
+			slog.Warn("ignoring invalid OUTBOUND_VALIDATION", "value", v)
+		}
+	}
+	if v := os.Getenv("OUTBOUND_VALIDATION_SAMPLE_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate > 0 && rate <= 1 {
+			cfg.OutboundValidationSampleRate = rate
+		} else {
+			slog.Warn("ignoring invalid OUTBOUND_VALIDATION_SAMPLE_RATE", "value", v)
+		}
+	}
+	if v := os.Getenv("SLO_LATENCY_THRESHOLD"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.SLOLatencyThreshold = d
+		}
+	}
+	if v := os.Getenv("SLO_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SLOWindow = d
+		}
+	}
+	if v := os.Getenv("ADAPTIVE_GC"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.AdaptiveGC = enabled
+		}
+	}
+	if v := os.Getenv("ADAPTIVE_BATCHING"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.AdaptiveBatching = enabled
+		}
+	}
+	if v := os.Getenv("ADAPTIVE_BATCH_MIN_WINDOW_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			cfg.AdaptiveBatchMinWindow = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("ADAPTIVE_BATCH_MAX_WINDOW_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			cfg.AdaptiveBatchMaxWindow = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("ADAPTIVE_BATCH_TARGET_RATE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.AdaptiveBatchTargetRate = n
+		}
+	}
+
+	if v := os.Getenv("TIMER_WHEEL_TICK_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms >= 0 {
+			cfg.TimerWheelTick = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v := os.Getenv("ALIGN_MODE_BOUNDARIES"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.AlignModeBoundaries = enabled
+		}
+	}
+	if v := os.Getenv("MODE_ALIGNMENT_OFFSET_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms >= 0 {
+			cfg.ModeAlignmentOffset = time.Duration(ms) * time.Millisecond
+		}
+	}
+
 	if maxBatchSize := os.Getenv("MAX_BATCH_SIZE"); maxBatchSize != "" {
 		if size, err := strconv.Atoi(maxBatchSize); err == nil && size > 0 {
 			cfg.MaxBatchSize = size
 		}
 	}
 	
-	if deadline := os.Getenv("WRITE_DEADLINE_MS"); deadline != "" {
-		if d, err := time.ParseDuration(deadline + "ms"); err == nil {
-			cfg.WriteTimeout = d
+	if maxSize := os.Getenv("SUBSCRIPTION_MAX_BATCH_SIZE"); maxSize != "" {
+		if size, err := strconv.Atoi(maxSize); err == nil && size > 0 {
+			cfg.SubscriptionMaxBatchSize = size
+		}
+	}
+
+	if minWindow := os.Getenv("SUBSCRIPTION_MIN_BATCH_WINDOW_MS"); minWindow != "" {
+		if ms, err := strconv.Atoi(minWindow); err == nil && ms > 0 {
+			cfg.SubscriptionMinBatchWindow = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	if maxWindow := os.Getenv("SUBSCRIPTION_MAX_BATCH_WINDOW_MS"); maxWindow != "" {
+		if ms, err := strconv.Atoi(maxWindow); err == nil && ms > 0 {
+			cfg.SubscriptionMaxBatchWindow = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	if maxUnacked := os.Getenv("DELIVERY_MAX_UNACKED_BATCHES"); maxUnacked != "" {
+		if n, err := strconv.Atoi(maxUnacked); err == nil && n > 0 {
+			cfg.MaxUnackedBatches = n
+		}
+	}
+
+	if ttl := os.Getenv("DELIVERY_RETENTION_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil && d > 0 {
+			cfg.DeliveryRetentionTTL = d
+		}
+	}
+
+	if gapFill := os.Getenv("GAP_FILL_BUFFER_SIZE"); gapFill != "" {
+		if size, err := strconv.Atoi(gapFill); err == nil && size >= 0 {
+			cfg.GapFillBufferSize = size
+		}
+	}
+
+	if grace := os.Getenv("RESUME_GRACE_PERIOD"); grace != "" {
+		if d, err := time.ParseDuration(grace); err == nil && d >= 0 {
+			cfg.ResumeGracePeriod = d
+		}
+	}
+
+	if v := os.Getenv("SNAPSHOT_MAX_SYMBOLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.SnapshotMaxSymbols = n
+		} else {
+			slog.Warn("ignoring invalid SNAPSHOT_MAX_SYMBOLS", "value", v)
+		}
+	}
+	
+	if v := os.Getenv("HISTORY_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.HistoryWindow = d
+		} else {
+			slog.Warn("ignoring invalid HISTORY_WINDOW", "value", v)
+		}
+	}
+	if v := os.Getenv("HISTORY_MAX_TICKS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.HistoryMaxTicks = n
+		} else {
+			slog.Warn("ignoring invalid HISTORY_MAX_TICKS", "value", v)
+		}
+	}
+	if v := os.Getenv("HISTORY_MAX_SYMBOLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.HistoryMaxSymbols = n
+		} else {
+			slog.Warn("ignoring invalid HISTORY_MAX_SYMBOLS", "value", v)
+		}
+	}
+
+	if v := os.Getenv("METRICS_SYMBOLS"); v != "" {
+		cfg.MetricsSymbols = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("METRICS_MAX_SYMBOLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MetricsMaxSymbols = n
+		}
+	}
+
+	// Push exporter for deployments without a Prometheus scraper
+	if v := os.Getenv("METRICS_EXPORTER"); v != "" {
+		if exporter, err := NewMetricsExporterFromEnv(strings.ToLower(v)); err == nil {
+			cfg.MetricsExporter = exporter
+		} else {
+			slog.Warn("ignoring invalid METRICS_EXPORTER", "error", err)
+		}
+	}
+	if v := os.Getenv("METRICS_EXPORT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.MetricsExportInterval = d
+		}
+	}
+
+	// Usage accounting
+	if v := os.Getenv("USAGE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.UsageInterval = d
+		} else {
+			slog.Warn("ignoring invalid USAGE_INTERVAL", "value", v)
+		}
+	}
+	if v := os.Getenv("USAGE_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.UsageRetention = d
+		} else {
+			slog.Warn("ignoring invalid USAGE_RETENTION", "value", v)
+		}
+	}
+	if v := os.Getenv("USAGE_FILE"); v != "" {
+		cfg.UsageExporter = &UsageFileExporter{Path: v}
+	}
+	
+	// Metrics persisted across restarts
+	if v := os.Getenv("METRICS_STATE_FILE"); v != "" {
+		cfg.MetricsStateFile = v
+	}
+	if v := os.Getenv("METRICS_STATE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.MetricsStateInterval = d
+		} else {
+			slog.Warn("ignoring invalid METRICS_STATE_INTERVAL", "value", v)
+		}
+	}
+	if v := os.Getenv("STATS_FILE"); v != "" {
+		cfg.StatsFile = v
+	}
+	
+	// Ops HTTP server
+	if v, ok := os.LookupEnv("OPS_LISTEN_ADDR"); ok {
+		cfg.OpsListenAddr = v
+	}
+	for env, path := range map[string]*string{
+		"OPS_HEALTH_PATH":  &cfg.HealthPath,
+		"OPS_READY_PATH":   &cfg.ReadyPath,
+		"OPS_METRICS_PATH": &cfg.MetricsPath,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if strings.HasPrefix(v, "/") {
+				*path = v
+			} else {
+				slog.Warn("ignoring invalid "+env, "value", v, "error", "path must start with /")
+			}
+		}
+	}
+	
+	// Cluster membership
+	if v := os.Getenv("CLUSTER_ADVERTISE_ADDR"); v != "" {
+		cfg.ClusterAdvertiseAddr = v
+	}
+	if v := os.Getenv("CLUSTER_SEEDS"); v != "" {
+		cfg.ClusterSeeds = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("CLUSTER_SECRET"); v != "" {
+		cfg.ClusterSecret = v
+	}
+	if v := os.Getenv("CLUSTER_GOSSIP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ClusterGossipInterval = d
+		} else {
+			slog.Warn("ignoring invalid CLUSTER_GOSSIP_INTERVAL", "value", v)
+		}
+	}
+	if v := os.Getenv("CLUSTER_PEER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ClusterPeerTimeout = d
+		} else {
+			slog.Warn("ignoring invalid CLUSTER_PEER_TIMEOUT", "value", v)
 		}
 	}
 
@@ -201,17 +884,333 @@ func LoadConfigFromEnv(cfg *Config) {
 	if v := os.Getenv("IP_BLOCKLIST"); v != "" {
 		cfg.BlockCIDRs = splitAndTrimCSV(v)
 	}
+
+	// Per-IP connection ceiling
+	if v := os.Getenv("MAX_CONNS_PER_IP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxConnsPerIP = n
+		}
+	}
+	if v := os.Getenv("PER_IP_LIMIT_POLICY"); v != "" {
+		cfg.PerIPLimitPolicy = PerIPLimitPolicy(strings.ToLower(strings.TrimSpace(v)))
+	}
+	if v := os.Getenv("MAX_CONNS_PER_IP_OVERRIDES"); v != "" {
+		cfg.MaxConnsPerIPOverrides = splitAndTrimCSV(v)
+	}
+
+	// Per-connection inbound frame rate
+	if v := os.Getenv("MAX_FRAMES_PER_SECOND"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			cfg.MaxFramesPerSecond = rate
+		} else {
+			slog.Warn("ignoring invalid MAX_FRAMES_PER_SECOND", "value", v)
+		}
+	}
+	if v := os.Getenv("FRAME_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.FrameBurst = n
+		} else {
+			slog.Warn("ignoring invalid FRAME_BURST", "value", v)
+		}
+	}
+	if v := os.Getenv("MAX_FRAME_STRIKES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxFrameStrikes = n
+		} else {
+			slog.Warn("ignoring invalid MAX_FRAME_STRIKES", "value", v)
+		}
+	}
+
+	// Global accept rate
+	if v := os.Getenv("ACCEPT_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			cfg.AcceptRate = rate
+		} else {
+			slog.Warn("ignoring invalid ACCEPT_RATE", "value", v)
+		}
+	}
+	if v := os.Getenv("ACCEPT_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.AcceptBurst = n
+		} else {
+			slog.Warn("ignoring invalid ACCEPT_BURST", "value", v)
+		}
+	}
+
+	// Runtime IP bans
+	if v := os.Getenv("IP_BAN_FILE"); v != "" {
+		cfg.IPBanFile = v
+	}
+	if v := os.Getenv("AUTO_BAN_AUTH_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AutoBanAuthFailures = n
+		}
+	}
+	if v := os.Getenv("AUTO_BAN_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.AutoBanWindow = d
+		}
+	}
+	if v := os.Getenv("AUTO_BAN_DURATION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.AutoBanDuration = d
+		}
+	}
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.AdminToken = v
+	}
+
+	// Duplicate client IDs
+	if v := os.Getenv("DUPLICATE_CLIENT_POLICY"); v != "" {
+		switch policy := strings.ToLower(v); policy {
+		case ClientPolicyAllow, ClientPolicyReject, ClientPolicyTakeover:
+			cfg.DuplicateClientPolicy = policy
+		default:
+			slog.Warn("ignoring invalid DUPLICATE_CLIENT_POLICY", "value", v)
+		}
+	}
+
+	// Priority classes
+	if v := os.Getenv("USER_PRIORITY_CLASSES"); v != "" {
+		cfg.UserPriorityClasses = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("DEFAULT_PRIORITY_CLASS"); v != "" {
+		cfg.DefaultPriorityClass = PriorityClass(strings.ToLower(strings.TrimSpace(v)))
+	}
+
+	// Secrets backend
+	if v := os.Getenv("SECRETS_PROVIDER"); v != "" {
+		if provider, err := secrets.NewProviderFromEnv(strings.ToLower(v)); err == nil {
+			cfg.SecretsProvider = provider
+		} else {
+			slog.Warn("ignoring invalid SECRETS_PROVIDER", "error", err)
+		}
+	}
+	if v := os.Getenv("SECRETS_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SecretsRefreshInterval = d
+		}
+	}
+	if v := os.Getenv("SECRETS_ROTATION_GRACE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SecretsRotationGrace = d
+		}
+	}
+
+	// Tenants
+	if v := os.Getenv("TENANTS"); v != "" {
+		if tenants, err := parseTenantSpecs(v); err == nil {
+			cfg.Tenants = tenants
+		} else {
+			slog.Warn("ignoring invalid TENANTS", "error", err)
+		}
+	}
+	
+	// Egress shaping
+	if v := os.Getenv("EGRESS_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			cfg.EgressRate = rate
+		} else {
+			slog.Warn("ignoring invalid EGRESS_RATE", "value", v)
+		}
+	}
+	if v := os.Getenv("EGRESS_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.EgressBurst = n
+		} else {
+			slog.Warn("ignoring invalid EGRESS_BURST", "value", v)
+		}
+	}
+	if v := os.Getenv("USER_EGRESS_RATES"); v != "" {
+		cfg.UserEgressRates = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("GLOBAL_EGRESS_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 {
+			cfg.GlobalEgressRate = rate
+		} else {
+			slog.Warn("ignoring invalid GLOBAL_EGRESS_RATE", "value", v)
+		}
+	}
+	if v := os.Getenv("GLOBAL_EGRESS_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.GlobalEgressBurst = n
+		} else {
+			slog.Warn("ignoring invalid GLOBAL_EGRESS_BURST", "value", v)
+		}
+	}
+
+	// Ingestion API
+	if v := os.Getenv("INGEST_PRODUCERS"); v != "" {
+		if producers, err := parseProducerSpecs(v); err == nil {
+			ingest := &IngestConfig{Producers: producers}
+			if rate := os.Getenv("INGEST_RATE"); rate != "" {
+				if f, err := strconv.ParseFloat(rate, 64); err == nil && f >= 0 {
+					ingest.Rate = f
+				}
+			}
+			if burst := os.Getenv("INGEST_BURST"); burst != "" {
+				if n, err := strconv.Atoi(burst); err == nil && n >= 0 {
+					ingest.Burst = n
+				}
+			}
+			cfg.Ingest = ingest
+		} else {
+			slog.Warn("ignoring invalid INGEST_PRODUCERS", "error", err)
+		}
+	}
+	
+	// Leader-elected ingest
+	if v := os.Getenv("INGEST_LEADER_ELECTION"); v != "" {
+		replication := &ReplicationConfig{
+			Election:       strings.ToLower(v),
+			LeaseName:      os.Getenv("INGEST_LEASE_NAME"),
+			LeaseNamespace: os.Getenv("INGEST_LEASE_NAMESPACE"),
+		}
+		if d := os.Getenv("INGEST_LEASE_DURATION"); d != "" {
+			if dur, err := time.ParseDuration(d); err == nil && dur > 0 {
+				replication.LeaseDuration = dur
+			} else {
+				slog.Warn("ignoring invalid INGEST_LEASE_DURATION", "value", d)
+			}
+		}
+		if b := os.Getenv("INGEST_FOLLOWER_BUFFER"); b != "" {
+			if n, err := strconv.Atoi(b); err == nil && n > 0 {
+				replication.FollowerBuffer = n
+			} else {
+				slog.Warn("ignoring invalid INGEST_FOLLOWER_BUFFER", "value", b)
+			}
+		}
+		if b := os.Getenv("INGEST_REPLICATION_BACKLOG"); b != "" {
+			if n, err := strconv.Atoi(b); err == nil && n > 0 {
+				replication.Backlog = n
+			} else {
+				slog.Warn("ignoring invalid INGEST_REPLICATION_BACKLOG", "value", b)
+			}
+		}
+		if err := replication.Validate(); err == nil {
+			cfg.Replication = replication
+		} else {
+			slog.Warn("ignoring invalid INGEST_LEADER_ELECTION", "error", err)
+		}
+	}
+
+	// File replay
+	if v := os.Getenv("REPLAY_FILE"); v != "" {
+		replay := &ReplayConfig{Path: v, Speed: 1}
+		if speed := os.Getenv("REPLAY_SPEED"); speed != "" {
+			if f, err := strconv.ParseFloat(speed, 64); err == nil {
+				replay.Speed = f
+			}
+		}
+		if loop := os.Getenv("REPLAY_LOOP"); loop != "" {
+			replay.Loop, _ = strconv.ParseBool(loop)
+		}
+		if err := replay.Validate(); err == nil {
+			cfg.Replay = replay
+		} else {
+			slog.Warn("ignoring invalid REPLAY_FILE settings", "error", err)
+		}
+	}
+
+	// Validation limits
+	if limits, err := loadValidationLimitsFromEnv(cfg.ValidationLimits); err != nil {
+		slog.Warn("ignoring invalid validation limits", "error", err)
+	} else if limits != nil {
+		cfg.ValidationLimits = limits
+	}
+
+	// Market simulator
+	if sim, err := loadSimulationFromEnv(); err != nil {
+		slog.Warn("ignoring invalid simulation settings", "error", err)
+	} else if sim != nil {
+		cfg.Simulation = sim
+	}
+
+	// Trading sessions
+	if v := os.Getenv("TRADING_SESSIONS"); v != "" {
+		if sessions, err := parseSessionSpecs(v); err == nil {
+			cfg.TradingSessions = sessions
+		} else {
+			slog.Warn("ignoring invalid TRADING_SESSIONS", "error", err)
+		}
+	}
+	if v := os.Getenv("TRADING_SESSION_POLICY"); v != "" {
+		switch policy := strings.ToLower(v); policy {
+		case SessionPolicyPause, SessionPolicyFlag:
+			cfg.SessionPolicy = policy
+		default:
+			slog.Warn("ignoring invalid TRADING_SESSION_POLICY", "value", v)
+		}
+	}
+
+	// Additional listeners
+	if v := os.Getenv("LISTENERS"); v != "" {
+		if listeners, err := parseListenerSpecs(v); err == nil {
+			cfg.Listeners = listeners
+		} else {
+			slog.Warn("ignoring invalid LISTENERS", "error", err)
+		}
+	}
+
+	// Listener socket tuning
+	if v := os.Getenv("SO_REUSEPORT"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.ReusePort = enabled
+		}
+	}
+	if v := os.Getenv("TCP_FASTOPEN_QUEUE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.TCPFastOpenQueue = n
+		}
+	}
+	if v := os.Getenv("LISTEN_BACKLOG"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ListenBacklog = n
+		}
+	}
+	if v := os.Getenv("TCP_USER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.TCPUserTimeout = d
+		}
+	}
+
+	// PROXY protocol
+	if v := os.Getenv("PROXY_PROTOCOL"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			cfg.ProxyProtocol = enabled
+		}
+	}
+	if v := os.Getenv("PROXY_PROTOCOL_TRUSTED_CIDRS"); v != "" {
+		cfg.ProxyProtocolTrustedCIDRs = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("PROXY_HEADER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ProxyHeaderTimeout = d
+		}
+	}
+
+	// GeoIP policy
+	if v := os.Getenv("GEOIP_DATABASE"); v != "" {
+		cfg.GeoIPDatabase = v
+	}
+	if v := os.Getenv("GEOIP_ALLOW_COUNTRIES"); v != "" {
+		cfg.GeoIPAllowCountries = splitAndTrimCSV(v)
+	}
+	if v := os.Getenv("GEOIP_DENY_COUNTRIES"); v != "" {
+		cfg.GeoIPDenyCountries = splitAndTrimCSV(v)
+	}
 }
 
 // Server represents the TCP server.
 type Server struct {
 	config         *Config
-	listener       net.Listener
+	listener       net.Listener      // Default listener on ListenAddr
+	listeners      []*serverListener // Default listener first, then Config.Listeners
 	authenticator  *auth.Authenticator
 	
 	// Connection management
-	mu             sync.RWMutex
-	connections    map[string]*Connection
+	mu             sync.RWMutex // Guards the ops server fields
+	connections    connRegistry
 	activeConns    int32
 	
 	// Lifecycle management
@@ -219,22 +1218,47 @@ type Server struct {
 	cancel         context.CancelFunc
 	wg             sync.WaitGroup
 	closed         atomic.Bool
+	draining       atomic.Bool // Shutdown has begun; readiness fails before listeners close
+	opsServer      *http.Server
+	opsAddr        string
 	
 	// Metrics
 	totalConns     uint64
 	authSuccess    uint64
 	authFailures   uint64
 	authRateLimited uint64
+	connPanics     uint64
+	memoryBudgetExceeded uint64
+	spillCounters  spillCounters
+	writeErrors    writeErrorCounters
 	tlsMetrics     *TLSMetrics
+	tlsHandshaker  *tlsHandshaker
+	stages         *StageTracker // Per-stage connection deadlines and counts
+	dispatcher     *Dispatcher   // Routes frames to per-message-type handlers
+	hooks          *Hooks        // Connection lifecycle callbacks
+	sniHosts       map[string]bool // Hosts with their own certificate, for SNI metrics
 
 	// Security
 	ipFilter       *IPFilter
+	ipConnLimiter  *IPConnLimiter
+	acceptLimiter  *AcceptLimiter // nil when disabled
+	authBanner     *AuthFailureBanner
+	secrets        *secrets.Store
+	certificates   *certificateHolder // TLS certificate from the secrets provider
+	geoPolicy      *GeoPolicy
 	ddosProtection *DDoSProtection
 	
 	// Resource management
 	resourceMonitor     *ResourceMonitor
 	resourceConstraints *ResourceConstraints
 	breachHandler       *ResourceBreachHandler
+	gcTuner             *GCTuner
+	batchTuner          *BatchTuner
+	timerWheel          *TimerWheel
+	slo                 *SLOTracker // nil when disabled
+	outbound            *outboundValidator // nil when outbound validation is off
+	usage               *UsageMeter // nil when disabled
+	metricsStateLoaded  atomic.Bool // Saving before the restore would lose the last run's counters
 	
 	// Health checking
 	healthChecker       *HealthChecker
@@ -247,6 +1271,45 @@ type Server struct {
 	
 	// Goroutine pool for connection handling
 	goroutinePool       *GoroutinePool
+	
+	// Fault injection for resilience testing
+	chaos               *ChaosInjector
+	
+	// Unacknowledged batches for at-least-once subscribers
+	retention           *RetentionStore
+	
+	// Latest tick per symbol for subscribe snapshots; nil when disabled
+	lastValues          *LastValueCache
+	
+	// Recent ticks per symbol for HISTORY requests; nil when disabled
+	history             *TickHistory
+	
+	// Gossip membership with other instances; nil when disabled
+	cluster             *Cluster
+	
+	// Decides which instance pulls from the data source; nil unless ingest
+	// is leader-elected
+	elector             LeaderElector
+	
+	// Subscriptions of dropped connections awaiting RESUME; nil when disabled
+	resume              *ResumeStore
+	
+	// Priority class scheduling under resource pressure
+	qos                 *QoSScheduler
+	
+	// Per-tenant quotas and namespaces
+	tenants             *TenantRegistry
+	calendar            *TradingCalendar // nil without trading sessions
+	
+	// Egress shaping per connection, user and tenant
+	egress              *EgressLimits
+	
+	// Authenticated connections by username/client_id
+	clients             clientRegistry
+	
+	// Admin-initiated cohort drain, kept after it ends for status
+	drainMu             sync.Mutex
+	drain               *cohortDrain
 }
 
 // NewServer creates a new TCP server.
@@ -259,40 +1322,76 @@ func NewServer(config *Config) *Server {
 	
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	logger := slog.Default()
+	logger := config.logger()
 	instanceID := generateInstanceID()
 	
 	s := &Server{
 		config:         config,
-		authenticator:  auth.NewAuthenticator(auth.DefaultConfig()),
-		connections:    make(map[string]*Connection),
+		authenticator:  auth.NewAuthenticator(config.Auth),
 		ctx:            ctx,
 		cancel:         cancel,
 		tlsMetrics:     NewTLSMetrics(),
+		tlsHandshaker:  newTLSHandshaker(config.TLS),
+		stages:         NewStageTracker(config),
 		ddosProtection: NewDDoSProtection(),
 		instanceID:     instanceID,
 		logger:         logger,
 		startTime:      time.Now(),
 	}
 	
+	if config.TimerWheelTick > 0 {
+		s.timerWheel = NewTimerWheel(config.clock(), config.TimerWheelTick)
+		s.stages.clock = s.timers()
+	}
+	
+	if config.TLS != nil {
+		s.sniHosts = config.TLS.sniHosts()
+	}
+	
 	// Initialize resource management components
-	limits := ResourceLimits{
-		MaxMemoryMB:       1024,  // 1GB default
-		MaxFileDescriptors: 65536, // 64K file descriptors
-		MaxGoroutines:     50000,  // 50K goroutines
-		MaxConnections:    100000, // 100K connections
-		WarningThreshold:  0.8,    // 80% warning
-		CriticalThreshold: 0.9,    // 90% critical
-	}
-	s.resourceMonitor = NewResourceMonitor(limits)
-	s.resourceConstraints = NewResourceConstraints()
+	container := DetectContainerLimits()
+	limits := resourceLimitsFor(container, config.MaxConnections)
+	logger.Info("resource limits derived from container",
+		"cgroup_version", container.CgroupVersion,
+		"container_memory_bytes", container.MemoryBytes,
+		"container_cpus", container.CPUs,
+		"max_memory_mb", limits.MaxMemoryMB,
+		"max_goroutines", limits.MaxGoroutines,
+	)
+	applyContainerCPULimit(container, logger)
+	s.resourceMonitor = NewResourceMonitor(limits, logger)
+	s.resourceMonitor.SetConnectionSource(func() int64 {
+		return int64(atomic.LoadInt32(&s.activeConns))
+	})
+	s.resourceConstraints = NewResourceConstraints(logger)
 	s.breachHandler = NewResourceBreachHandler(logger, s.resourceMonitor)
+	s.breachHandler.SetLoadShedder(config.BreachShedConnections, s.shedConnections)
+	if config.AdaptiveGC {
+		s.gcTuner = NewGCTuner(s.resourceMonitor, logger)
+	}
+	if config.AdaptiveBatching {
+		s.batchTuner = NewBatchTuner(config.BatchWindow, config.AdaptiveBatchMinWindow,
+			config.AdaptiveBatchMaxWindow, config.AdaptiveBatchTargetRate, config.clock(), logger)
+	}
+	if config.SLOWindow > 0 {
+		s.slo = NewSLOTracker(config.SLOTarget, config.SLOLatencyThreshold, config.SLOWindow, config.clock())
+	}
+	if config.UsageInterval > 0 {
+		s.usage = NewUsageMeter(config.clock(), config.UsageRetention)
+	}
 	
 	// Initialize health checker
 	s.healthChecker = NewHealthChecker(s)
 	
 	// Initialize Prometheus metrics
 	s.prometheusMetrics = NewPrometheusMetrics()
+	s.prometheusMetrics.SetSymbolLabels(config.MetricsSymbols, config.MetricsMaxSymbols)
+	s.prometheusMetrics.RegisterWriteErrorMetrics(s.instanceID, s.writeErrors.Stats)
+	
+	// Frames pass through tracing, metrics and rate limiting before their handler
+	s.dispatcher = NewDispatcher()
+	s.dispatcher.Use(traceFrames, frameMetrics(s.prometheusMetrics), limitFrameRate)
+	s.hooks = &Hooks{}
 	
 	// Initialize goroutine pool for optimized connection handling
 	s.goroutinePool = NewGoroutinePool(runtime.NumCPU(), runtime.NumCPU()*4)
@@ -300,6 +1399,40 @@ func NewServer(config *Config) *Server {
 	// Initialize auto-scaling support
 	s.initAutoScaling()
 	
+	// Initialize retention for at-least-once subscriptions
+	s.retention = NewRetentionStore(config.MaxUnackedBatches, config.DeliveryRetentionTTL)
+	
+	// Cache the latest tick per symbol for subscribe snapshots
+	if config.SnapshotMaxSymbols > 0 {
+		s.lastValues = NewLastValueCache(config.SnapshotMaxSymbols)
+	}
+	
+	// Keep recent ticks per symbol for HISTORY requests
+	if config.HistoryWindow > 0 {
+		s.history = NewTickHistory(config.HistoryWindow, config.HistoryMaxTicks, config.HistoryMaxSymbols)
+	}
+	
+	// Park subscriptions of dropped connections for RESUME
+	if config.ResumeGracePeriod > 0 {
+		s.resume = NewResumeStore(config.ResumeGracePeriod, func(sub *Subscription) {
+			if sub.Retention != nil {
+				s.retention.Release(sub.Retention)
+			}
+		})
+	}
+	
+	// Initialize fault injection if explicitly enabled
+	if config.Chaos != nil && config.Chaos.Enabled {
+		s.chaos = NewChaosInjector(config.Chaos)
+		logger.Warn("CHAOS MODE ENABLED - connections will experience injected faults",
+			"connection_percent", config.Chaos.ConnectionPercent,
+			"write_delay_probability", config.Chaos.WriteDelayProbability,
+			"drop_probability", config.Chaos.DropProbability,
+			"corrupt_probability", config.Chaos.CorruptProbability,
+			"read_stall_probability", config.Chaos.ReadStallProbability,
+		)
+	}
+	
 	return s
 }
 
@@ -309,6 +1442,22 @@ func (s *Server) Start() error {
 		return ErrServerClosed
 	}
 	
+	// Apply OS limits before opening any sockets or files
+	if s.config.Ulimits != nil {
+		if err := s.applyUlimits(); err != nil {
+			return fmt.Errorf("failed to apply resource limits: %w", err)
+		}
+	}
+	
+	// Load credentials and key material before anything depends on them
+	if err := s.startSecrets(); err != nil {
+		return err
+	}
+	
+	if err := s.applyValidationLimits(); err != nil {
+		return fmt.Errorf("invalid validation limits: %w", err)
+	}
+	
 	// Validate TLS configuration if enabled
 	if s.config.TLS != nil {
 		if err := s.config.TLS.ValidateTLSConfig(); err != nil {
@@ -323,66 +1472,340 @@ func (s *Server) Start() error {
 		s.ipFilter = ipf
 	}
 	
-	// Create listener with TLS support if enabled
-	listener, err := s.createListener()
+	// Restore persisted bans and enable automatic bans on repeated auth failures
+	if s.config.IPBanFile != "" {
+		if err := s.ipFilter.SetBanFile(s.config.IPBanFile); err != nil {
+			return fmt.Errorf("invalid IP ban file: %w", err)
+		}
+	}
+	
+	// Carry cumulative counters and usage records over from the last run
+	if s.config.MetricsStateFile != "" {
+		if err := s.loadMetricsState(); err != nil {
+			return fmt.Errorf("invalid metrics state file: %w", err)
+		}
+		s.metricsStateLoaded.Store(true)
+	}
+	if s.config.AutoBanAuthFailures > 0 {
+		s.authBanner = NewAuthFailureBanner(s.ipFilter, s.config.AutoBanAuthFailures,
+			s.config.AutoBanWindow, s.config.AutoBanDuration)
+		go func() {
+			ticker := time.NewTicker(s.config.AutoBanWindow)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-s.ctx.Done():
+					return
+				case <-ticker.C:
+					s.authBanner.Cleanup()
+				}
+			}
+		}()
+	}
+	
+	// Build GeoIP policy when a resolver or country rules are configured
+	if s.config.GeoIP != nil || s.config.GeoIPDatabase != "" ||
+		len(s.config.GeoIPAllowCountries) > 0 || len(s.config.GeoIPDenyCountries) > 0 {
+		resolver := s.config.GeoIP
+		if resolver == nil && s.config.GeoIPDatabase != "" {
+			db, err := LoadGeoIPDatabase(s.config.GeoIPDatabase)
+			if err != nil {
+				return err
+			}
+			s.logger.Info("GeoIP database loaded", "path", s.config.GeoIPDatabase, "networks", db.Len())
+			resolver = db
+		}
+		s.geoPolicy = NewGeoPolicy(resolver, s.config.GeoIPAllowCountries, s.config.GeoIPDenyCountries)
+	}
+	
+	// Build the QoS scheduler; lower classes yield while a resource limit is breached
+	qos, err := NewQoSScheduler(s.config, s.breachHandler.UnderPressure)
+	if err != nil {
+		return fmt.Errorf("invalid priority class configuration: %w", err)
+	}
+	s.qos = qos
+	
+	tenants, err := NewTenantRegistry(s.config.Tenants, s.config.clock())
+	if err != nil {
+		return fmt.Errorf("invalid tenant configuration: %w", err)
+	}
+	s.tenants = tenants
+	
+	if s.config.ProxyProtocol {
+		if _, err := parseProxyTrustedNetworks(s.config.ProxyProtocolTrustedCIDRs); err != nil {
+			return fmt.Errorf("invalid PROXY protocol configuration: %w", err)
+		}
+	}
+	
+	egress, err := NewEgressLimits(s.config)
+	if err != nil {
+		return fmt.Errorf("invalid egress configuration: %w", err)
+	}
+	s.egress = egress
+	s.prometheusMetrics.RegisterEgressMetrics(s.instanceID, egress.Stats)
+	
+	// Check the configured tick source before accepting subscribers
+	switch {
+	case s.config.DataSource != nil:
+	case s.config.Ingest != nil:
+		if err := s.config.Ingest.Validate(); err != nil {
+			return fmt.Errorf("invalid ingest configuration: %w", err)
+		}
+	case s.config.Replay != nil:
+		if err := s.config.Replay.Validate(); err != nil {
+			return fmt.Errorf("invalid replay configuration: %w", err)
+		}
+		// Surface unreadable recordings at startup rather than per subscription
+		if _, err := s.config.Replay.load(); err != nil {
+			return fmt.Errorf("invalid replay configuration: %w", err)
+		}
+	case s.config.Simulation != nil:
+		if err := s.config.Simulation.Validate(); err != nil {
+			return fmt.Errorf("invalid simulation configuration: %w", err)
+		}
+	}
+	if s.config.Replication != nil {
+		if err := s.config.validateReplication(); err != nil {
+			return fmt.Errorf("invalid replication configuration: %w", err)
+		}
+	}
+	
+	outbound, err := newOutboundValidator(s.config)
+	if err != nil {
+		return fmt.Errorf("invalid outbound validation configuration: %w", err)
+	}
+	s.outbound = outbound
+	
+	if len(s.config.TradingSessions) > 0 {
+		calendar, err := NewTradingCalendar(s.config.TradingSessions, s.config.SessionPolicy, s.config.clock().Now())
+		if err != nil {
+			return fmt.Errorf("invalid trading session configuration: %w", err)
+		}
+		s.calendar = calendar
+		s.calendar.logSessions(s.logger)
+	}
+	s.prometheusMetrics.RegisterQoSMetrics(s.instanceID, s.qos, s.qosUsage)
+	s.prometheusMetrics.RegisterConnMemoryMetrics(s.instanceID, s.connMemoryStats)
+	if s.batchTuner != nil {
+		s.prometheusMetrics.RegisterBatchTunerMetrics(s.instanceID, s.batchTuner.Window)
+	}
+	s.prometheusMetrics.RegisterTLSHandshakeMetrics(s.instanceID, s.tlsHandshaker.InProgress)
+	s.prometheusMetrics.RegisterAuthLockoutMetrics(s.instanceID, s.authenticator.LockedUsers)
+	s.authenticator.OnLockout(s.noteLockout)
+	s.prometheusMetrics.RegisterConnectionStageMetrics(s.instanceID, s.stages)
+	if s.slo != nil {
+		s.prometheusMetrics.RegisterSLOMetrics(s.instanceID, s.slo.Status)
+	}
+	
+	// Build per-IP connection limiter when a ceiling or overrides are configured
+	if s.config.MaxConnsPerIP > 0 || len(s.config.MaxConnsPerIPOverrides) > 0 {
+		limiter, err := NewIPConnLimiter(s.config)
+		if err != nil {
+			return fmt.Errorf("invalid per-IP connection limit configuration: %w", err)
+		}
+		s.ipConnLimiter = limiter
+	}
+	
+	// Build the global accept-rate limiter shared by all listeners
+	if s.config.AcceptRate > 0 {
+		s.acceptLimiter = NewAcceptLimiter(s.config.AcceptRate, s.config.AcceptBurst, s.config.clock())
+	}
+	
+	// Create the default listener, with TLS support if enabled, and any extras
+	useTLS := s.config.TLS != nil && s.config.TLS.Enabled
+	var listener net.Listener
+	if s.config.Listener != nil {
+		listener, err = s.wrapListener(s.config.Listener, s.config.ListenAddr, useTLS)
+	} else {
+		listener, err = s.createListener(s.config.ListenAddr, useTLS)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create listener: %w", err)
 	}
 	
 	s.listener = listener
+	primary, _ := newServerListener(listener, ListenerConfig{Name: DefaultListenerName, TLS: useTLS})
+	s.listeners = []*serverListener{primary}
+	
+	for _, lc := range s.config.Listeners {
+		l, err := s.createListener(lc.Addr, lc.TLS)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("failed to create listener %q: %w", lc.Name, err)
+		}
+		sl, err := newServerListener(l, lc)
+		if err != nil {
+			l.Close()
+			s.closeListeners()
+			return err
+		}
+		s.listeners = append(s.listeners, sl)
+		s.logger.Info("additional listener started", "name", lc.Name, "addr", l.Addr().String(), "tls", lc.TLS)
+	}
 	
 	// Start DDoS protection cleanup routine
 	s.ddosProtection.StartCleanupRoutine()
 	
+	// Expire retention buffers of clients that did not reconnect
+	s.retention.StartCleanupRoutine(s.ctx)
+	if s.resume != nil {
+		s.resume.StartCleanupRoutine(s.ctx)
+	}
+	
 	// Start resource monitoring services
 	if s.resourceMonitor != nil {
 		s.resourceMonitor.Start()
 	}
-	if s.breachHandler != nil {
-		go s.breachHandler.StartMonitoring(s.ctx)
+	if s.breachHandler != nil {
+		go s.breachHandler.StartMonitoring(s.ctx)
+	}
+	if s.gcTuner != nil {
+		s.gcTuner.Start(s.ctx, 5*time.Second)
+	}
+	if s.batchTuner != nil {
+		s.batchTuner.Start(s.ctx, time.Second)
+	}
+	if s.timerWheel != nil {
+		s.timerWheel.Start(s.ctx)
+	}
+	
+	// Discover other instances by gossip through the ops server
+	if s.config.ClusterAdvertiseAddr != "" {
+		s.cluster = NewCluster(s.config.ClusterAdvertiseAddr, s.config.ClusterSeeds, s.config.ClusterSecret,
+			s.config.ClusterPeerTimeout, s.config.clock(), s.clusterMember)
+		s.prometheusMetrics.RegisterClusterMetrics(s.instanceID, s.cluster.Stats)
+		if s.config.ClusterSecret == "" {
+			s.logger.Warn("cluster gossip is unauthenticated; set CLUSTER_SECRET")
+		}
+		if s.config.Replication != nil {
+			elector, err := s.newLeaderElector()
+			if err != nil {
+				return fmt.Errorf("invalid replication configuration: %w", err)
+			}
+			s.elector = elector
+			s.prometheusMetrics.RegisterReplicationMetrics(s.instanceID, s.config.Replication.source().Stats)
+		}
+	}
+	
+	// Health, readiness, metrics and admin endpoints share one HTTP server
+	if err := s.startOpsServer(); err != nil {
+		s.logger.Error("failed to start ops server", "addr", s.config.OpsListenAddr, "error", err)
+	}
+	
+	// Push metrics for deployments that do not scrape the endpoint above
+	if s.config.MetricsExporter != nil {
+		s.prometheusMetrics.StartExporter(s.ctx, s.config.MetricsExporter, s.config.MetricsExportInterval, s.logger)
+		s.logger.Info("metrics exporter started",
+			"exporter", s.config.MetricsExporter.Name(),
+			"interval", s.config.MetricsExportInterval,
+		)
+	}
+	
+	// Tell subscribers when their trading sessions open and close
+	if s.calendar != nil {
+		s.calendar.Start(s.ctx, s.config.clock(), s.notifySessionChange)
+	}
+	
+	// Forward halts, resumes and stale data reported by the data source
+	if source, ok := s.config.dataSource().(StreamStatusSource); ok {
+		go source.WatchStatus(s.ctx, s.ReportStreamStatus)
+	}
+	
+	// Close usage periods and ship them to the billing pipeline
+	if s.usage != nil {
+		s.usage.Start(s.ctx, s.config.UsageInterval, s.config.UsageExporter, s.logger)
+	}
+	
+	if s.config.MetricsStateFile != "" {
+		go s.persistMetricsState(s.ctx, s.config.MetricsStateInterval)
 	}
 	
-	// Start health check server on port 8081
-	if err := s.StartHealthCheckServer(8081); err != nil {
-		s.logger.Error("failed to start health check server", "error", err)
+	if s.cluster != nil {
+		s.cluster.Start(s.ctx, s.config.ClusterGossipInterval, s.logger)
+		s.logger.Info("cluster membership started",
+			"advertise_addr", s.config.ClusterAdvertiseAddr,
+			"seeds", s.config.ClusterSeeds,
+		)
 	}
 	
-	// Start Prometheus metrics server on port 9090
-	go func() {
-		if err := s.prometheusMetrics.StartMetricsServer(9090); err != nil && err != http.ErrServerClosed {
-			s.logger.Error("failed to start Prometheus metrics server", "error", err)
-		}
-	}()
+	// Pull from the data source only while elected, replicating to followers
+	if s.elector != nil {
+		s.config.Replication.source().Start(s.ctx, s.config.upstreamSource(), s.recordTicks, s.elector, s.instanceID,
+			s.cluster.Addr, s.config.ClusterSecret, s.config.ClusterGossipInterval, s.config.clock(), s.logger)
+		s.logger.Info("leader-elected ingest started", "election", s.config.Replication.Election)
+	}
 	
 	// Start accepting connections
-	s.wg.Add(1)
-	go s.acceptLoop()
+	for _, l := range s.listeners {
+		s.wg.Add(1)
+		go s.acceptLoop(l)
+	}
 	
 	return nil
 }
 
-// createListener creates a network listener with optional TLS support
-func (s *Server) createListener() (net.Listener, error) {
+// createListener creates a network listener on addr with optional TLS support
+func (s *Server) createListener(addr string, useTLS bool) (net.Listener, error) {
 	// Create base TCP listener
-	listener, err := net.Listen("tcp", s.config.ListenAddr)
+	listener, err := s.config.listenerFactory()("tcp", addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.wrapListener(listener, addr, useTLS)
+}
+
+// wrapListener adds PROXY protocol parsing and TLS to an open listener for
+// addr, closing it on failure.
+func (s *Server) wrapListener(listener net.Listener, addr string, useTLS bool) (net.Listener, error) {
+	// PROXY headers precede the TLS handshake, so parse them beneath TLS
+	if s.config.ProxyProtocol {
+		proxied, err := newProxyListener(listener, s.config.ProxyProtocolTrustedCIDRs, s.config.ProxyHeaderTimeout)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		listener = proxied
 	}
 	
 	// Wrap with TLS if enabled
-	if s.config.TLS != nil && s.config.TLS.Enabled {
-		tlsConfig, err := s.config.TLS.BuildTLSConfig()
+	if useTLS {
+		if s.config.TLS == nil {
+			listener.Close()
+			return nil, fmt.Errorf("TLS requested for %s but no TLS configuration is set", addr)
+		}
+		// Extra TLS listeners share the certificate settings even when the
+		// default listener is plaintext
+		tlsSettings := *s.config.TLS
+		tlsSettings.Enabled = true
+		if err := tlsSettings.ValidateTLSConfig(); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("TLS configuration validation failed: %w", err)
+		}
+		tlsConfig, err := tlsSettings.BuildTLSConfig()
 		if err != nil {
 			listener.Close()
 			return nil, fmt.Errorf("failed to build TLS config: %w", err)
 		}
 		
+		if tlsSettings.Fingerprint {
+			return newFingerprintListener(listener, tlsConfig), nil
+		}
 		return tls.NewListener(listener, tlsConfig), nil
 	}
 	
 	return listener, nil
 }
 
+// closeListeners stops every listener from accepting new connections.
+func (s *Server) closeListeners() {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	if len(s.listeners) == 0 && s.listener != nil {
+		s.listener.Close()
+	}
+}
+
 // Shutdown gracefully shuts down the server without losing connections.
 func (s *Server) Shutdown(ctx context.Context) error {
 	if !s.closed.CompareAndSwap(false, true) {
@@ -391,9 +1814,27 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	
 	s.logger.Info("starting graceful shutdown")
 	
-	// Stop accepting new connections first
+	// Save counters and final stats once connections are done adding to them
+	defer s.storeMetricsState()
+	defer s.storeStatsFile()
+	
+	// Fail readiness first so load balancers stop routing to this instance
+	s.draining.Store(true)
+	defer s.draining.Store(false)
+	
+	// Probes keep being served until the drain completes
+	defer s.stopOpsServer(ctx)
+	if delay := s.config.DrainReadinessDelay; delay > 0 {
+		s.logger.Info("waiting for load balancers to observe readiness", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+	
+	// Stop accepting new connections
 	if s.listener != nil {
-		s.listener.Close()
+		s.closeListeners()
 		s.logger.Info("stopped accepting new connections")
 	}
 	
@@ -456,11 +1897,11 @@ func (s *Server) Stop(ctx context.Context) error {
 	if !s.closed.CompareAndSwap(false, true) {
 		return nil // Already closed
 	}
+	defer s.storeMetricsState()
+	defer s.storeStatsFile()
 	
 	// Stop accepting new connections
-	if s.listener != nil {
-		s.listener.Close()
-	}
+	s.closeListeners()
 	
 	// Cancel server context
 	s.cancel()
@@ -473,6 +1914,8 @@ func (s *Server) Stop(ctx context.Context) error {
 	// Close all active connections
 	s.closeAllConnections()
 	
+	s.stopOpsServer(ctx)
+	
 	// Wait for all goroutines to finish or context to expire
 	done := make(chan struct{})
 	go func() {
@@ -488,95 +1931,228 @@ func (s *Server) Stop(ctx context.Context) error {
 	}
 }
 
-// acceptLoop accepts incoming connections.
-func (s *Server) acceptLoop() {
+// acceptLoop accepts incoming connections on l. Accept errors are retried
+// with exponential backoff until the listener is closed.
+func (s *Server) acceptLoop(l *serverListener) {
 	defer s.wg.Done()
 	
+	var backoff time.Duration
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := l.Accept()
 		if err != nil {
 			if s.closed.Load() {
+				s.acceptLoopExited(l, "shutdown", nil)
 				return
 			}
-			
-			// Check if it's a temporary error
-			if ne, ok := err.(net.Error); ok && ne.Temporary() {
-				time.Sleep(10 * time.Millisecond)
-				continue
+			if errors.Is(err, net.ErrClosed) {
+				s.acceptLoopExited(l, "listener_closed", err)
+				return
 			}
 			
-			return
-		}
-		
-		// Enforce IP filtering if configured
-		if s.ipFilter != nil {
-			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
-			if ip := net.ParseIP(host); !s.ipFilter.Allow(ip) {
-				GlobalMetrics.IncrementIPRejectedConnections()
-				conn.Close()
-				continue
+			backoff = nextAcceptBackoff(backoff)
+			s.acceptFailed(l, err, backoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-s.ctx.Done():
+				timer.Stop()
 			}
+			continue
 		}
+		backoff = 0
 		
-		// Check DDoS protection
-		if !s.ddosProtection.CheckConnectionAllowed(conn.RemoteAddr()) {
+		// Refuse connections beyond the global accept rate before any PROXY,
+		// TLS or authentication work is spent on them
+		if !s.acceptLimiter.Allow() {
+			s.prometheusMetrics.IncrementAcceptRejected(s.instanceID, l.name)
 			conn.Close()
 			continue
 		}
 		
-		// Check resource breach handler
-		if s.breachHandler != nil && s.breachHandler.ShouldRejectConnection() {
-			s.breachHandler.RejectConnection(conn)
+		// The client address behind a PROXY header is only known once the
+		// header arrives, so admit those connections off the accept goroutine
+		if s.config.ProxyProtocol {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.admitConnection(conn, l)
+			}()
 			continue
 		}
+		
+		s.admitConnection(conn, l)
+	}
+}
 
-		// Check connection limit
-		if atomic.LoadInt32(&s.activeConns) >= int32(s.config.MaxConnections) {
+// admitConnection applies admission policy to a connection accepted on l and
+// hands it to a connection handler.
+func (s *Server) admitConnection(conn net.Conn, l *serverListener) {
+	if err := checkProxyHeader(conn); err != nil {
+		s.logger.Warn("rejecting connection with invalid PROXY header",
+			"peer_addr", conn.RemoteAddr().String(),
+			"error", err,
+		)
+		s.prometheusMetrics.IncrementConnectionErrors(s.instanceID, "proxy_header")
+		conn.Close()
+		return
+	}
+	
+	// Enforce IP filtering if configured
+	if s.ipFilter != nil {
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		if ip := net.ParseIP(host); !s.ipFilter.Allow(ip) {
+			GlobalMetrics.IncrementIPRejectedConnections()
 			conn.Close()
-			continue
+			return
 		}
-		
-		// Handle connection using goroutine pool if available, otherwise direct goroutine
-		if s.goroutinePool != nil {
-			// Use goroutine pool for better resource management
-			if !s.goroutinePool.Submit(func() {
-				s.handleConnection(conn)
-			}) {
-				// Pool is full, fall back to direct goroutine
-				s.wg.Add(1)
-				go s.handleConnection(conn)
-			}
-		} else {
-			// Direct goroutine-per-connection model
+	}
+	
+	// Tag the client's region and enforce per-country policy
+	region := GeoRegionUnknown
+	if s.geoPolicy != nil {
+		region = s.geoPolicy.Region(remoteIP(conn))
+		if !s.geoPolicy.Allow(region) {
+			s.logger.Warn("connection rejected by GeoIP policy",
+				"remote_addr", conn.RemoteAddr().String(),
+				"region", region,
+			)
+			s.prometheusMetrics.IncrementConnectionErrors(s.instanceID, "geo_policy")
+			conn.Close()
+			return
+		}
+	}
+	
+	// Check DDoS protection
+	if !s.ddosProtection.CheckConnectionAllowed(conn.RemoteAddr()) {
+		conn.Close()
+		return
+	}
+	
+	// Check resource breach handler
+	if s.breachHandler != nil && s.breachHandler.ShouldRejectConnection() {
+		s.breachHandler.RejectConnection(conn, retryAfterHint(s.config.BusyRetryAfter))
+		return
+	}
+
+	// Check connection limit
+	if atomic.LoadInt32(&s.activeConns) >= int32(s.config.MaxConnections) {
+		conn.Close()
+		return
+	}
+	
+	// Apply the listener's own IP lists and connection ceiling
+	if !l.admit(conn) {
+		atomic.AddUint64(&l.rejected, 1)
+		s.prometheusMetrics.IncrementListenerConnections(s.instanceID, l.name, "rejected")
+		conn.Close()
+		return
+	}
+	
+	// Check per-IP concurrent connection ceiling
+	if !s.ipConnLimiter.Acquire(conn) {
+		s.logger.Warn("per-IP connection limit reached",
+			"remote_addr", conn.RemoteAddr().String(),
+			"limit", s.config.MaxConnsPerIP,
+		)
+		s.prometheusMetrics.IncrementConnectionErrors(s.instanceID, "per_ip_limit")
+		conn.Close()
+		return
+	}
+	
+	// Handle connection using goroutine pool if available, otherwise direct goroutine
+	if s.goroutinePool != nil {
+		// Use goroutine pool for better resource management
+		if !s.goroutinePool.Submit(func() {
+			s.handleConnection(conn, region, l)
+		}) {
+			// Pool is full, fall back to direct goroutine
 			s.wg.Add(1)
-			go s.handleConnection(conn)
+			go s.handleConnection(conn, region, l)
 		}
+	} else {
+		// Direct goroutine-per-connection model
+		s.wg.Add(1)
+		go s.handleConnection(conn, region, l)
 	}
 }
 
-// handleConnection handles a single client connection.
-func (s *Server) handleConnection(netConn net.Conn) {
+// handleConnection handles a single client connection from region accepted on l.
+func (s *Server) handleConnection(netConn net.Conn, region string, l *serverListener) {
 	// Only call Done if we're using direct goroutines (not pool)
 	if s.goroutinePool == nil {
 		defer s.wg.Done()
 	}
 	
+	// Free the per-IP slot acquired in the accept loop
+	defer s.ipConnLimiter.Release(netConn)
+	
+	// Every log line about the connection carries its ID, from the handshake on
+	connID := newConnectionID()
+	logger := s.logger.With("connection_id", connID, "remote_addr", netConn.RemoteAddr().String())
+	
+	// Track the connection through its lifecycle stages
+	stages := s.stages.Begin()
+	var endErr error
+	defer func() {
+		stage, reason := stages.End(endErr)
+		if reason != StageEndClosed {
+			logger.Debug("connection ended",
+				"stage", stage.String(),
+				"reason", reason,
+				"error", endErr,
+			)
+		}
+	}()
+	
+	// A panic ends only this connection; the deferred cleanup above still runs
+	defer s.recoverConnection(netConn, logger, &endErr)
+	
 	// Record TLS connection metrics if applicable
+	var fingerprint TLSFingerprint
+	var hasFingerprint bool
+	var serverName string
+	var peerCertificates []*x509.Certificate
 	if tlsConn, ok := netConn.(*tls.Conn); ok {
 		s.tlsMetrics.RecordTLSConnection()
+		s.enterStage(stages, StageTLS)
 		
 		// Perform handshake and record metrics
 		start := time.Now()
-		err := tlsConn.Handshake()
+		err := s.tlsHandshaker.Handshake(s.ctx, tlsConn)
 		handshakeDuration := time.Since(start)
 		
 		s.tlsMetrics.RecordTLSHandshake(handshakeDuration, err)
 		
-		if err == nil {
-			// Record TLS version and cipher suite
-			state := tlsConn.ConnectionState()
-			s.tlsMetrics.RecordTLSVersion(state.Version)
-			s.tlsMetrics.RecordCipherSuite(state.CipherSuite)
+		if err != nil {
+			reason := "error"
+			switch {
+			case errors.Is(err, ErrHandshakeTimeout):
+				reason = "timeout"
+			case errors.Is(err, ErrHandshakeCapacity):
+				reason = "capacity"
+			}
+			logger.Debug("TLS handshake failed",
+				"reason", reason,
+				"error", err,
+			)
+			s.prometheusMetrics.IncrementTLSHandshakeFailures(s.instanceID, reason)
+			netConn.Close()
+			endErr = err
+			return
+		}
+		
+		// Record TLS version and cipher suite
+		state := tlsConn.ConnectionState()
+		s.tlsMetrics.RecordTLSVersion(state.Version)
+		s.tlsMetrics.RecordCipherSuite(state.CipherSuite)
+		s.prometheusMetrics.IncrementTLSHandshakesBySNI(s.instanceID, matchSNIHost(s.sniHosts, state.ServerName))
+		serverName = state.ServerName
+		peerCertificates = state.PeerCertificates
+		
+		if fingerprint, hasFingerprint = tlsFingerprint(tlsConn); hasFingerprint {
+			logger.Debug("TLS client fingerprinted",
+				"ja3_hash", fingerprint.JA3Hash,
+			)
 		}
 	}
 	
@@ -586,20 +2162,42 @@ func (s *Server) handleConnection(netConn net.Conn) {
 	
 	// Update Prometheus metrics
 	s.prometheusMetrics.IncrementActiveConnections(s.instanceID)
+	s.prometheusMetrics.IncrementClientsByRegion(s.instanceID, region)
+	atomic.AddInt32(&l.active, 1)
+	atomic.AddUint64(&l.accepted, 1)
+	s.prometheusMetrics.IncrementListenerConnections(s.instanceID, l.name, "accepted")
+	s.prometheusMetrics.IncrementListenerActiveConnections(s.instanceID, l.name)
 	defer func() {
 		atomic.AddInt32(&s.activeConns, -1)
 		s.prometheusMetrics.DecrementActiveConnections(s.instanceID)
+		s.prometheusMetrics.DecrementClientsByRegion(s.instanceID, region)
+		atomic.AddInt32(&l.active, -1)
+		s.prometheusMetrics.DecrementListenerActiveConnections(s.instanceID, l.name)
 	}()
 	
 	// Configure TCP connection
-	if tcpConn, ok := netConn.(*net.TCPConn); ok {
+	if tcpConn, ok := unwrapProxyConn(netConn).(*net.TCPConn); ok {
 		tcpConn.SetKeepAlive(true)
 		tcpConn.SetKeepAlivePeriod(s.config.KeepAlive)
 		tcpConn.SetNoDelay(true) // Disable Nagle's algorithm for low latency
 	}
 	
+	// Inject faults on a sample of connections when chaos mode is enabled
+	if s.chaos != nil {
+		netConn = s.chaos.Wrap(netConn)
+	}
+	
 	// Create connection wrapper
-	conn := NewConnection(netConn, s.config)
+	conn := newConnection(netConn, s.config, connID)
+	conn.logger = logger
+	if hasFingerprint {
+		conn.tlsFingerprint = &fingerprint
+	}
+	conn.serverName = serverName
+	conn.peerCertificates = peerCertificates
+	conn.stages = stages
+	conn.writeErrors = &s.writeErrors
+	stages.OnTimeout(func(stage ConnStage) { s.stageTimedOut(conn, stage) })
 	
 	// Register connection
 	s.registerConnection(conn)
@@ -610,13 +2208,41 @@ func (s *Server) handleConnection(netConn net.Conn) {
 		s.ddosProtection.RecordPortAccess(netConn.RemoteAddr(), 8080) // Use actual port from config
 	}
 	
+	// Let lifecycle hooks veto the connection
+	if err := s.hooks.runConnect(s.ctx, conn); err != nil {
+		conn.Logger().Info("connection vetoed", "error", err)
+		s.prometheusMetrics.IncrementConnectionErrors(s.instanceID, "hook_veto")
+		conn.Close()
+		endErr = err
+		return
+	}
+	
 	// Handle the connection
-	if err := s.processConnection(conn); err != nil {
-		// Log error (in production, use proper logging)
-		if !errors.Is(err, context.Canceled) && !errors.Is(err, net.ErrClosed) {
-			// Log the error
-		}
+	endErr = s.processConnection(conn)
+	s.hooks.runDisconnect(conn, endErr)
+}
+
+// enterStage moves a connection to stage, logging out-of-order transitions.
+func (s *Server) enterStage(stages *connStages, stage ConnStage) {
+	if err := stages.Enter(stage); err != nil {
+		s.logger.Warn("connection stage violation", "error", err)
+	}
+}
+
+// stageTimedOut tells the client which deadline it missed and closes the
+// connection. It runs on the stage timer.
+func (s *Server) stageTimedOut(conn *Connection, stage ConnStage) {
+	conn.Logger().Info("connection stage deadline exceeded", "stage", stage.String())
+	
+	code, message := pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED, "authentication timeout"
+	if stage == StageSubscribe {
+		code, message = pb.ErrorCode_ERROR_CODE_NOT_SUBSCRIBED, "subscribe timeout"
+	}
+	// Written synchronously: Close discards frames still in the write queue
+	if frame, err := errorFrame(code, message, "", 0); err == nil {
+		_ = conn.WriteFrameSync(frame)
 	}
+	conn.Close()
 }
 
 // processConnection processes a client connection.
@@ -624,102 +2250,344 @@ func (s *Server) processConnection(conn *Connection) error {
 	ctx, cancel := context.WithCancel(s.ctx)
 	defer cancel()
 	
-	// Set authentication timeout
-	authTimer := time.NewTimer(s.config.AuthTimeout)
-	defer authTimer.Stop()
-	
-	// Read first frame (must be AUTH)
-	select {
-	case <-authTimer.C:
-		return conn.SendError(pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT, "authentication timeout")
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-	}
-	
-	// Set read deadline for auth
-	conn.SetReadDeadline(time.Now().Add(s.config.AuthTimeout))
-	
+	// Read first frame (must be AUTH) within the auth stage deadline
+	s.enterStage(conn.stages, StageAuth)
 	frame, err := conn.ReadFrame()
 	if err != nil {
 		return err
 	}
+	noteAuthRequest(conn, frame)
 	
 	// Validate first frame is AUTH
 	if err := s.authenticator.ValidateFirstFrame(frame); err != nil {
 		// First message must be AUTH
 		_ = conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED)
 		atomic.AddUint64(&s.authFailures, 1)
+		s.recordAuthFailure(conn)
 		return err
 	}
 	
+	// Challenge-response: answer with a nonce and read the signed AUTH frame.
+	// Custom authenticators only take plaintext credentials
+	if s.config.Authenticator == nil && s.authenticator.WantsChallenge(frame) {
+		if frame, err = s.issueAuthChallenge(conn, frame); err != nil {
+			return err
+		}
+		noteAuthRequest(conn, frame)
+	}
+	
 	// Authenticate
-	session, err := s.authenticator.Authenticate(ctx, conn.RemoteAddr(), frame)
+	session, err := s.authenticate(ctx, conn, frame)
 	if err != nil {
-		// Send specific error codes for better observability
-		switch {
-		case errors.Is(err, auth.ErrRateLimited):
-			_ = conn.SendAuthError()
-			atomic.AddUint64(&s.authRateLimited, 1)
-			s.prometheusMetrics.IncrementAuthRateLimited(s.instanceID)
-		case errors.Is(err, auth.ErrInvalidCredentials):
-			_ = conn.SendAuthError()
-			atomic.AddUint64(&s.authFailures, 1)
-			s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "invalid_credentials")
-		default:
-			_ = conn.SendAuthError()
-			atomic.AddUint64(&s.authFailures, 1)
-			s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "unknown")
-		}
+		s.rejectAuth(conn, err)
+		return err
+	}
+	
+	// Let lifecycle hooks veto the session
+	conn.SetAuthenticated(session)
+	if err := s.hooks.runAuthenticated(ctx, conn); err != nil {
+		_ = conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_AUTH, "Authentication failed", vetoReason(err))
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "hook_veto")
+		return err
+	}
+	
+	// One connection per client_id, unless duplicates are allowed
+	releaseClient, err := s.claimClientID(conn)
+	if err != nil {
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "duplicate_session")
 		return err
 	}
+	defer releaseClient()
 	
 	// Authentication successful
+	if session.PreviousCredentials {
+		conn.Logger().Info("client authenticated with rotated-out credentials",
+			"username", session.Username,
+			"grace_until", s.authenticator.RotationGraceUntil(),
+		)
+	}
+	s.authBanner.RecordSuccess(hostIP(conn.RemoteAddr()))
 	atomic.AddUint64(&s.authSuccess, 1)
 	s.prometheusMetrics.IncrementAuthSuccess(s.instanceID)
-	conn.SetAuthenticated(session)
+	conn.SetPriority(s.qos, s.qos.ClassFor(session.Username))
+	if s.config.spillEnabledFor(conn.Priority()) {
+		conn.enableSpill(s.config.SpillDir, s.config.SpillMaxBytes, &s.spillCounters)
+	}
+	
+	// Admit the session against its tenant's quotas
+	metadata := s.authAckMetadata()
+	metadata["connection_id"] = conn.ID()
+	if s.config.MaxSessionAge > 0 {
+		metadata["max_session_age_ms"] = strconv.FormatInt(s.config.MaxSessionAge.Milliseconds(), 10)
+	}
+	if s.tenants != nil {
+		tenant := s.tenants.Resolve(session.Username, conn.ServerName())
+		if err := s.admitTenant(conn, tenant); err != nil {
+			return err
+		}
+		defer s.releaseTenant(tenant)
+		metadata["tenant"] = tenant.Name()
+	}
+	if s.egress != nil {
+		conn.setEgress(s.egress.forSession(session.Username, conn.Tenant(), s.qos.EgressWeight(conn.Priority())))
+	}
+	if s.usage != nil {
+		s.usage.Track(conn)
+		defer s.usage.Untrack(conn)
+	}
 	
 	// Send AUTH ACK
-	if err := conn.SendAuthSuccess(); err != nil {
+	if err := conn.SendAuthSuccess(metadata); err != nil {
 		return err
 	}
-	conn.SetReadDeadline(time.Time{})
+	conn.setCorrelationID("")
+	s.enterStage(conn.stages, StageSubscribe)
 	
 	// Start connection handler
 	handler := NewConnectionHandler(conn, s.config, s)
 	return handler.Handle(ctx)
 }
 
+// rejectAuth tells conn why authentication failed, with specific error codes
+// for better observability, and records the failure.
+func (s *Server) rejectAuth(conn *Connection, err error) {
+	var lockout *auth.LockoutError
+	switch {
+	case errors.As(err, &lockout):
+		_ = conn.SendRetryableError(pb.ErrorCode_ERROR_CODE_RATE_LIMITED, retryAfterHint(lockout.RetryAfter))
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "locked_out")
+	case errors.Is(err, auth.ErrRateLimited):
+		retryAfter := s.authenticator.RetryAfter(conn.RemoteAddr())
+		if retryAfter == 0 {
+			// Limited by a custom authenticator
+			retryAfter = s.config.BusyRetryAfter
+		}
+		_ = conn.SendRetryableError(pb.ErrorCode_ERROR_CODE_RATE_LIMITED, retryAfterHint(retryAfter))
+		atomic.AddUint64(&s.authRateLimited, 1)
+		s.prometheusMetrics.IncrementAuthRateLimited(s.instanceID)
+	case errors.Is(err, auth.ErrUnavailable):
+		// The backend failed, not the client: ask it to retry and do not count a failure
+		_ = conn.SendRetryableError(pb.ErrorCode_ERROR_CODE_SERVER_BUSY, retryAfterHint(s.config.BusyRetryAfter))
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "backend_unavailable")
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrNoChallenge):
+		_ = conn.SendAuthError()
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "invalid_credentials")
+	case errors.Is(err, auth.ErrChallengeRequired):
+		_ = conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_AUTH, "Authentication failed",
+			"challenge-response authentication required")
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "challenge_required")
+	default:
+		_ = conn.SendAuthError()
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "unknown")
+	}
+	s.recordAuthFailure(conn)
+}
+
+// noteAuthRequest echoes the request_id of an AUTH frame in the answer and
+// joins the trace in its metadata. Other frames and malformed requests,
+// which authentication rejects anyway, clear the correlation ID.
+func noteAuthRequest(conn *Connection, frame *protocol.Frame) {
+	var req pb.AuthRequest
+	if frame.Type != protocol.MessageTypeAuth || proto.Unmarshal(frame.Payload, &req) != nil ||
+		len(req.RequestId) > protocol.MaxRequestIDLength {
+		conn.setCorrelationID("")
+		return
+	}
+	conn.setCorrelationID(req.RequestId)
+	conn.joinTrace(req.Metadata)
+}
+
+// authenticate checks an AUTH frame with the custom authenticator when one
+// is configured, else against the built-in credentials. Both share the
+// built-in per-IP rate limiting and session tracking.
+func (s *Server) authenticate(ctx context.Context, conn *Connection, frame *protocol.Frame) (*auth.Session, error) {
+	backend := s.config.Authenticator
+	if backend == nil {
+		return s.authenticator.Authenticate(ctx, conn.authPeer(), frame)
+	}
+	
+	// The backend may block on the network: bound it by the auth deadline
+	if s.config.AuthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.AuthTimeout)
+		defer cancel()
+	}
+	meta := conn.authMeta()
+	return s.authenticator.AuthenticateWith(ctx, conn.authPeer(), frame, func(ctx context.Context, req *pb.AuthRequest) (*auth.Session, error) {
+		if req.Mechanism != "" {
+			return nil, auth.ErrUnsupportedMechanism
+		}
+		granted, err := backend.Authenticate(ctx, meta, authn.Request{
+			Username: req.Username,
+			Password: req.Password,
+			ClientID: req.ClientId,
+			Version:  req.Version,
+		})
+		if err != nil {
+			if !errors.Is(err, authn.ErrInvalidCredentials) && !errors.Is(err, authn.ErrRateLimited) &&
+				!errors.Is(err, authn.ErrUnavailable) {
+				conn.Logger().Warn("custom authenticator failed", "error", err)
+				err = fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+			}
+			return nil, err
+		}
+		
+		session := &auth.Session{
+			ClientID:   granted.ClientID,
+			Username:   granted.Username,
+			Attributes: granted.Attributes,
+		}
+		if session.ClientID == "" {
+			session.ClientID = req.ClientId
+		}
+		if session.Username == "" {
+			session.Username = req.Username
+		}
+		return session, nil
+	})
+}
+
+// admitTenant binds conn to tenant, refusing it with RATE_LIMITED when the
+// tenant is at its connection quota or connect rate.
+func (s *Server) admitTenant(conn *Connection, tenant *Tenant) error {
+	if err := tenant.Acquire(); err != nil {
+		reason := "quota"
+		if errors.Is(err, ErrTenantRateLimited) {
+			reason = "rate"
+		}
+		conn.Logger().Warn("tenant refused connection",
+			"tenant", tenant.Name(),
+			"reason", reason,
+		)
+		s.prometheusMetrics.IncrementTenantRejected(s.instanceID, tenant.Name(), reason)
+		code := pb.ErrorCode_ERROR_CODE_QUOTA_EXCEEDED
+		if reason == "rate" {
+			code = pb.ErrorCode_ERROR_CODE_RATE_LIMITED
+		}
+		_ = conn.SendErrorCodeSync(code, retryAfterHint(s.config.BusyRetryAfter))
+		return err
+	}
+	conn.SetTenant(tenant)
+	s.prometheusMetrics.IncrementTenantConnections(s.instanceID, tenant.Name())
+	return nil
+}
+
+// releaseTenant frees a session admitted by admitTenant.
+func (s *Server) releaseTenant(tenant *Tenant) {
+	tenant.Release()
+	s.prometheusMetrics.DecrementTenantConnections(s.instanceID, tenant.Name())
+}
+
+// issueAuthChallenge sends an AUTH_CHALLENGE for req and returns the client's
+// signed AUTH frame.
+func (s *Server) issueAuthChallenge(conn *Connection, req *protocol.Frame) (*protocol.Frame, error) {
+	challenge, err := s.authenticator.IssueChallenge(conn.authPeer(), req)
+	if err != nil {
+		_ = conn.SendAuthError()
+		atomic.AddUint64(&s.authFailures, 1)
+		s.prometheusMetrics.IncrementAuthFailure(s.instanceID, "unsupported_mechanism")
+		s.recordAuthFailure(conn)
+		return nil, err
+	}
+	
+	if err := conn.SendMessage(protocol.MessageTypeAuthChallenge, &pb.AuthChallenge{
+		Mechanism:   challenge.Mechanism,
+		Nonce:       challenge.Nonce,
+		TimestampMs: time.Now().UnixMilli(),
+	}); err != nil {
+		return nil, err
+	}
+	
+	// The response must arrive within the auth stage deadline
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authenticator.ValidateFirstFrame(frame); err != nil {
+		_ = conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_AUTH_REQUIRED)
+		atomic.AddUint64(&s.authFailures, 1)
+		s.recordAuthFailure(conn)
+		return nil, err
+	}
+	return frame, nil
+}
+
+// noteLockout logs and counts a username locked out after repeated failures.
+func (s *Server) noteLockout(username string, failures int, duration time.Duration) {
+	s.logger.Warn("username locked out after repeated authentication failures",
+		"username", username,
+		"failures", failures,
+		"duration", duration,
+	)
+	s.prometheusMetrics.IncrementAuthLockouts(s.instanceID)
+}
+
+// recordAuthFailure feeds the auto-ban tracker and logs any resulting ban.
+func (s *Server) recordAuthFailure(conn *Connection) {
+	ban, err := s.authBanner.RecordFailure(hostIP(conn.RemoteAddr()))
+	if err != nil {
+		s.logger.Error("failed to persist ban list", "error", err)
+	}
+	if ban != nil {
+		conn.Logger().Warn("IP auto-banned after repeated authentication failures",
+			"ip", ban.IP,
+			"duration", s.config.AutoBanDuration,
+		)
+	}
+}
+
 // registerConnection registers a connection.
 func (s *Server) registerConnection(conn *Connection) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	s.connections[conn.ID()] = conn
+	s.connections.add(conn)
 }
 
 // unregisterConnection unregisters a connection.
 func (s *Server) unregisterConnection(conn *Connection) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	delete(s.connections, conn.ID())
+	s.connections.remove(conn)
+	if s.prometheusMetrics != nil {
+		s.prometheusMetrics.ObserveWriteQueueHighWater(s.instanceID, conn.WriteQueueStats().HighWater)
+	}
 	
 	// Clean up authentication session
-	s.authenticator.RemoveSession(conn.RemoteAddr())
+	s.authenticator.RemoveSession(conn.ID())
+}
+
+// timers returns the clock for per-connection timeouts, backed by the timer
+// wheel when one is configured.
+func (s *Server) timers() Clock {
+	if s.timerWheel != nil {
+		return s.timerWheel.Clock(s.config.clock())
+	}
+	return s.config.clock()
+}
+
+// qosUsage returns connection counts and queued frames per priority class.
+func (s *Server) qosUsage() map[PriorityClass]ClassUsage {
+	usage := make(map[PriorityClass]ClassUsage, len(PriorityClasses))
+	s.connections.each(func(conn *Connection) {
+		class := conn.Priority()
+		if class == "" {
+			return
+		}
+		u := usage[class]
+		u.Connections++
+		u.QueueDepth += int64(conn.QueueLen())
+		usage[class] = u
+	})
+	return usage
 }
 
 // closeAllConnections closes all active connections.
 func (s *Server) closeAllConnections() {
-	s.mu.Lock()
-	connections := make([]*Connection, 0, len(s.connections))
-	for _, conn := range s.connections {
-		connections = append(connections, conn)
-	}
-	s.mu.Unlock()
-	
-	// Close connections outside of lock
-	for _, conn := range connections {
+	// Close connections outside of the registry's locks
+	for _, conn := range s.connections.snapshot() {
 		conn.Close()
 	}
 }
@@ -732,6 +2600,7 @@ func (s *Server) GetStats() map[string]interface{} {
 		"auth_success":        atomic.LoadUint64(&s.authSuccess),
 		"auth_failures":       atomic.LoadUint64(&s.authFailures),
 		"auth_rate_limited":   atomic.LoadUint64(&s.authRateLimited),
+		"connection_panics":   atomic.LoadUint64(&s.connPanics),
 		"max_connections":     s.config.MaxConnections,
 		"listen_addr":         s.config.ListenAddr,
 	}
@@ -752,10 +2621,126 @@ func (s *Server) GetStats() map[string]interface{} {
 		}
 	}
 	
+	if s.gcTuner != nil {
+		stats["gc_tuner"] = s.gcTuner.GetStats()
+	}
+	if s.batchTuner != nil {
+		stats["batch_tuner"] = s.batchTuner.GetStats()
+	}
+	if s.timerWheel != nil {
+		stats["timer_wheel"] = s.timerWheel.GetStats()
+	}
+	
+	if s.slo != nil {
+		stats["slo"] = s.slo.Status().GetStats()
+	}
+	
+	// Add runtime ban metrics
+	if s.ipFilter != nil {
+		stats["ip_bans_active"] = len(s.ipFilter.Bans())
+	}
+	stats["ip_auto_bans_total"] = s.authBanner.AutoBans()
+	if s.secrets != nil {
+		secretStats := s.secrets.GetStats()
+		if until := s.authenticator.RotationGraceUntil(); !until.IsZero() {
+			secretStats["rotation_grace_until"] = until
+		}
+		stats["secrets"] = secretStats
+	}
+	
+	// Add per-IP connection limit metrics
+	if s.acceptLimiter != nil {
+		stats["accept_limiter"] = s.acceptLimiter.GetStats()
+	}
+	if s.calendar != nil {
+		stats["calendar"] = s.calendar.GetStats()
+	}
+	if s.outbound != nil {
+		stats["outbound_validation"] = s.outbound.GetStats()
+	}
+	if s.config.Ingest != nil {
+		stats["ingest"] = s.config.Ingest.source(s.config.clock()).GetStats()
+	}
+	if s.tenants != nil {
+		stats["tenants"] = s.tenants.GetStats()
+	}
+	if s.egress != nil {
+		stats["egress"] = s.egress.GetStats()
+	}
+	if s.stages != nil {
+		stats["stages"] = s.stages.GetStats()
+	}
+	if s.usage != nil {
+		stats["usage"] = s.usage.GetStats()
+	}
+	if s.ipConnLimiter != nil {
+		for k, v := range s.ipConnLimiter.GetStats() {
+			stats["per_ip_"+k] = v
+		}
+	}
+	
+	// Add at-least-once delivery metrics
+	if s.retention != nil {
+		for k, v := range s.retention.GetStats() {
+			stats["delivery_"+k] = v
+		}
+	}
+	
+	// Add cluster membership metrics
+	if s.cluster != nil {
+		stats["cluster"] = s.cluster.GetStats()
+	}
+	if s.elector != nil {
+		stats["replication"] = s.config.Replication.source().GetStats()
+	}
+	
+	// Add tick history metrics
+	if s.history != nil {
+		stats["history"] = s.history.GetStats()
+	}
+	
+	// Add last-value cache metrics
+	if s.lastValues != nil {
+		stats["snapshot"] = s.lastValues.GetStats()
+	}
+	
+	// Add subscription resume metrics
+	if s.resume != nil {
+		stats["resume"] = s.resume.GetStats()
+	}
+	
+	// Add QoS class metrics
+	if s.qos != nil {
+		stats["qos"] = s.qos.GetStats(s.qosUsage())
+	}
+	
+	// Add per-connection memory accounting
+	stats["connection_memory"] = s.connMemoryStats().GetStats()
+	stats["write_queue"] = s.writeQueueStats().GetStats()
+	stats["write_errors"] = s.writeErrors.Stats().GetStats()
+	if s.config.SpillDir != "" {
+		stats["spill"] = s.spillStats()
+	}
+	
+	// Add per-listener metrics
+	listenerStats := make(map[string]interface{}, len(s.listeners))
+	for _, l := range s.listeners {
+		listenerStats[l.name] = l.GetStats()
+	}
+	stats["listeners"] = listenerStats
+	
+	// Add chaos injection metrics if enabled
+	if s.chaos != nil {
+		for k, v := range s.chaos.GetStats() {
+			stats["chaos_"+k] = v
+		}
+	}
+	
 	// Add TLS metrics if TLS is enabled
 	if s.config.TLS != nil && s.config.TLS.Enabled {
 		stats["tls"] = s.tlsMetrics.GetTLSMetrics()
 		stats["tls_health"] = s.tlsMetrics.GetTLSHealthStatus()
+		stats["tls_handshakes"] = s.tlsHandshaker.GetStats()
 		stats["tls_config"] = s.config.TLS.GetTLSInfo()
 	}
 	
@@ -769,3 +2754,28 @@ func (s *Server) ListenAddr() string {
 	}
 	return s.config.ListenAddr
 }
+
+// Dispatcher returns the frame dispatcher. Embedders may register handlers
+// for custom message types or add middleware before calling Start.
+func (s *Server) Dispatcher() *Dispatcher {
+	return s.dispatcher
+}
+
+// Hooks returns the connection lifecycle hooks. Embedders register callbacks
+// on it before calling Start.
+func (s *Server) Hooks() *Hooks {
+	return s.hooks
+}
+
+// Usage returns the usage meter, or nil when accounting is disabled.
+func (s *Server) Usage() *UsageMeter {
+	return s.usage
+}
+
+// GoroutinePoolStats returns statistics for the connection-handling goroutine pool.
+func (s *Server) GoroutinePoolStats() PoolStats {
+	if s.goroutinePool == nil {
+		return PoolStats{}
+	}
+	return s.goroutinePool.Stats()
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Price models for the simulator.
+const (
+	SimModelGBM           = "gbm"            // Geometric Brownian motion
+	SimModelMeanReversion = "mean_reversion" // Ornstein-Uhlenbeck on log price around the start price
+)
+
+// Seconds per year, the time unit of volatility, drift and reversion speed.
+const simYear = 365 * 24 * time.Hour
+
+// SimSymbol is one instrument in the simulated universe.
+type SimSymbol struct {
+	Symbol string
+	Price  float64 // Starting price, and the mean for mean reversion
+}
+
+// SimulationConfig configures the market simulator. Volatility, drift and
+// mean reversion are annualized.
+type SimulationConfig struct {
+	Symbols        []SimSymbol
+	TicksPerSecond float64 // Ticks per symbol per second in SECOND mode
+	Model          string
+	Volatility     float64
+	Drift          float64
+	MeanReversion  float64 // Reversion speed for SimModelMeanReversion
+	Spread         float64 // Bid/ask spread as a fraction of price
+
+	// Volatility regimes: every RegimeInterval the market turns volatile with
+	// HighVolProbability, scaling volatility by HighVolFactor (0 interval disables)
+	RegimeInterval     time.Duration
+	HighVolProbability float64
+	HighVolFactor      float64
+
+	// Gap events: each price step jumps by about GapSize (a fraction of price)
+	// in a random direction with GapProbability
+	GapProbability float64
+	GapSize        float64
+
+	Seed int64 // Random seed for reproducible runs (0 seeds from the clock)
+
+	once   sync.Once
+	market *simMarket
+}
+
+// simDefaultUniverse is used when a scenario is chosen without symbols.
+var simDefaultUniverse = []SimSymbol{
+	{"EURUSD", 1.08}, {"GBPUSD", 1.27}, {"USDJPY", 150}, {"BTCUSD", 65000},
+	{"ETHUSD", 3200}, {"AAPL", 190}, {"MSFT", 420}, {"SPY", 520},
+}
+
+// simScenarios are the presets SIM_SCENARIO selects.
+var simScenarios = map[string]*SimulationConfig{
+	"calm": {
+		TicksPerSecond: 1, Model: SimModelGBM, Volatility: 0.1, Spread: 0.0001,
+	},
+	"volatile": {
+		TicksPerSecond: 10, Model: SimModelGBM, Volatility: 0.8, Spread: 0.0005,
+		RegimeInterval: time.Minute, HighVolProbability: 0.3, HighVolFactor: 3,
+	},
+	"mean-reverting": {
+		TicksPerSecond: 5, Model: SimModelMeanReversion, Volatility: 0.4, MeanReversion: 50000, Spread: 0.0002,
+	},
+	"gappy": {
+		TicksPerSecond: 5, Model: SimModelGBM, Volatility: 0.3, Spread: 0.0003,
+		GapProbability: 0.001, GapSize: 0.05,
+	},
+}
+
+// NewSimulationConfig returns a copy of the named scenario using the default
+// symbol universe.
+func NewSimulationConfig(scenario string) (*SimulationConfig, error) {
+	preset, ok := simScenarios[scenario]
+	if !ok {
+		return nil, fmt.Errorf("unknown simulation scenario %q", scenario)
+	}
+	return &SimulationConfig{
+		Symbols:            append([]SimSymbol(nil), simDefaultUniverse...),
+		TicksPerSecond:     preset.TicksPerSecond,
+		Model:              preset.Model,
+		Volatility:         preset.Volatility,
+		Drift:              preset.Drift,
+		MeanReversion:      preset.MeanReversion,
+		Spread:             preset.Spread,
+		RegimeInterval:     preset.RegimeInterval,
+		HighVolProbability: preset.HighVolProbability,
+		HighVolFactor:      preset.HighVolFactor,
+		GapProbability:     preset.GapProbability,
+		GapSize:            preset.GapSize,
+	}, nil
+}
+
+// Validate reports the first invalid setting.
+func (c *SimulationConfig) Validate() error {
+	if len(c.Symbols) == 0 {
+		return fmt.Errorf("simulation needs at least one symbol")
+	}
+	seen := make(map[string]bool, len(c.Symbols))
+	for _, s := range c.Symbols {
+		if s.Symbol == "" || s.Price <= 0 {
+			return fmt.Errorf("invalid simulated symbol %q: needs a name and a positive price", s.Symbol)
+		}
+		if seen[s.Symbol] {
+			return fmt.Errorf("duplicate simulated symbol %q", s.Symbol)
+		}
+		seen[s.Symbol] = true
+	}
+	switch c.Model {
+	case SimModelGBM, SimModelMeanReversion:
+	default:
+		return fmt.Errorf("unknown simulation model %q", c.Model)
+	}
+	if c.TicksPerSecond <= 0 || c.TicksPerSecond > 1000 {
+		return fmt.Errorf("ticks per second must be in (0, 1000]")
+	}
+	if c.Volatility < 0 || c.MeanReversion < 0 || c.Spread < 0 || c.GapSize < 0 || c.HighVolFactor < 0 {
+		return fmt.Errorf("volatility, mean reversion, spread, gap size and volatility factor must not be negative")
+	}
+	if c.GapProbability < 0 || c.GapProbability > 1 || c.HighVolProbability < 0 || c.HighVolProbability > 1 {
+		return fmt.Errorf("probabilities must be between 0 and 1")
+	}
+	return nil
+}
+
+// source returns the simulator shared by every subscription, so all clients
+// see the same market.
+func (c *SimulationConfig) source(clock Clock, schedule modeSchedule) DataSource {
+	c.once.Do(func() {
+		c.market = newSimMarket(c, clock)
+		c.market.schedule = schedule
+	})
+	return c.market
+}
+
+// loadSimulationFromEnv builds a simulation from SIM_* variables, or returns
+// nil when none is set. SIM_SCENARIO picks the preset (default calm) the
+// other variables override.
+func loadSimulationFromEnv() (*SimulationConfig, error) {
+	scenario, enabled := os.LookupEnv("SIM_SCENARIO")
+	for _, env := range []string{"SIM_SYMBOLS", "SIM_SYMBOL_COUNT", "SIM_MODEL", "SIM_TICKS_PER_SECOND"} {
+		if _, ok := os.LookupEnv(env); ok {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return nil, nil
+	}
+	if scenario == "" {
+		scenario = "calm"
+	}
+	sim, err := NewSimulationConfig(strings.ToLower(scenario))
+	if err != nil {
+		return nil, err
+	}
+
+	if v := os.Getenv("SIM_SYMBOLS"); v != "" {
+		if sim.Symbols, err = parseSimSymbols(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := os.Getenv("SIM_SYMBOL_COUNT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid SIM_SYMBOL_COUNT %q", v)
+		}
+		// Uniform universe for load tests
+		sim.Symbols = make([]SimSymbol, n)
+		for i := range sim.Symbols {
+			sim.Symbols[i] = SimSymbol{Symbol: fmt.Sprintf("SIM%05d", i), Price: 100}
+		}
+	}
+	if v := os.Getenv("SIM_MODEL"); v != "" {
+		sim.Model = strings.ToLower(v)
+	}
+	if v := os.Getenv("SIM_REGIME_INTERVAL"); v != "" {
+		if sim.RegimeInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid SIM_REGIME_INTERVAL %q", v)
+		}
+	}
+	if v := os.Getenv("SIM_SEED"); v != "" {
+		if sim.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid SIM_SEED %q", v)
+		}
+	}
+	for env, field := range map[string]*float64{
+		"SIM_TICKS_PER_SECOND":     &sim.TicksPerSecond,
+		"SIM_VOLATILITY":           &sim.Volatility,
+		"SIM_DRIFT":                &sim.Drift,
+		"SIM_MEAN_REVERSION":       &sim.MeanReversion,
+		"SIM_SPREAD":               &sim.Spread,
+		"SIM_HIGH_VOL_PROBABILITY": &sim.HighVolProbability,
+		"SIM_HIGH_VOL_FACTOR":      &sim.HighVolFactor,
+		"SIM_GAP_PROBABILITY":      &sim.GapProbability,
+		"SIM_GAP_SIZE":             &sim.GapSize,
+	} {
+		if v := os.Getenv(env); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", env, v)
+			}
+			*field = f
+		}
+	}
+	if err := sim.Validate(); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// parseSimSymbols parses comma-separated SYMBOL:PRICE pairs.
+func parseSimSymbols(spec string) ([]SimSymbol, error) {
+	var symbols []SimSymbol
+	for _, entry := range splitAndTrimCSV(spec) {
+		name, price, ok := strings.Cut(entry, ":")
+		p, err := strconv.ParseFloat(price, 64)
+		if !ok || err != nil || name == "" {
+			return nil, fmt.Errorf("invalid simulated symbol %q: expected SYMBOL:PRICE", entry)
+		}
+		symbols = append(symbols, SimSymbol{Symbol: name, Price: p})
+	}
+	return symbols, nil
+}
+
+// simPrice is one symbol's simulated state.
+type simPrice struct {
+	mean    float64
+	price   float64
+	updated time.Time
+}
+
+// simMarket advances prices by the time elapsed since each symbol was last
+// sampled, so concurrent subscriptions share one consistent price path.
+type simMarket struct {
+	config   *SimulationConfig
+	clock    Clock
+	schedule modeSchedule
+	symbols  []string // Sorted universe
+
+	mu          sync.Mutex
+	rng         *rand.Rand
+	prices      map[string]*simPrice
+	highVol     bool
+	regimeUntil time.Time
+}
+
+func newSimMarket(config *SimulationConfig, clock Clock) *simMarket {
+	seed := config.Seed
+	if seed == 0 {
+		seed = clock.Now().UnixNano()
+	}
+	m := &simMarket{
+		config: config,
+		clock:  clock,
+		rng:    rand.New(rand.NewSource(seed)),
+		prices: make(map[string]*simPrice, len(config.Symbols)),
+	}
+	now := clock.Now()
+	for _, s := range config.Symbols {
+		m.symbols = append(m.symbols, s.Symbol)
+		m.prices[s.Symbol] = &simPrice{mean: s.Price, price: s.Price, updated: now}
+	}
+	sort.Strings(m.symbols)
+	return m
+}
+
+// Stream emits a tick for each subscribed symbol every 1/TicksPerSecond in
+// SECOND mode and every minute in MINUTE mode.
+func (m *simMarket) Stream(ctx context.Context, subscription *Subscription, emit func([]*pb.Tick)) {
+	var interval time.Duration
+	switch subscription.Mode {
+	case pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND:
+		interval = time.Duration(float64(time.Second) / m.config.TicksPerSecond)
+	case pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE:
+		interval = time.Minute
+	default:
+		return
+	}
+	ticker := m.schedule.ticker(m.clock, interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if ticks := m.sampleAt(subscription, ticker.Fired()); len(ticks) > 0 {
+				emit(ticks)
+			}
+		}
+	}
+}
+
+// sample advances and returns the current tick of each symbol subscription
+// wants.
+func (m *simMarket) sample(subscription *Subscription) []*pb.Tick {
+	return m.sampleAt(subscription, m.clock.Now())
+}
+
+// sampleAt is sample with the ticks stamped at, the boundary they were
+// scheduled for. Prices still advance to the current time.
+func (m *simMarket) sampleAt(subscription *Subscription, at time.Time) []*pb.Tick {
+	now := m.clock.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updateRegime(now)
+
+	var ticks []*pb.Tick
+	for _, symbol := range m.symbols {
+		if !subscription.wantsSymbol(symbol) {
+			continue
+		}
+		state := m.prices[symbol]
+		m.step(state, now)
+		half := state.price * m.config.Spread / 2
+		ticks = append(ticks, &pb.Tick{
+			Symbol:      symbol,
+			TimestampMs: at.UnixMilli(),
+			Price:       state.price,
+			Volume:      math.Round(m.rng.ExpFloat64() * 100),
+			Bid:         state.price - half,
+			Ask:         state.price + half,
+			BidSize:     1 + m.rng.Int63n(1000),
+			AskSize:     1 + m.rng.Int63n(1000),
+			Mode:        subscription.Mode,
+		})
+	}
+	return ticks
+}
+
+// updateRegime rolls the volatility regime when the current one has run
+// RegimeInterval.
+func (m *simMarket) updateRegime(now time.Time) {
+	if m.config.RegimeInterval <= 0 || now.Before(m.regimeUntil) {
+		return
+	}
+	m.highVol = m.rng.Float64() < m.config.HighVolProbability
+	m.regimeUntil = now.Add(m.config.RegimeInterval)
+}
+
+// step moves state's price from its last update to now.
+func (m *simMarket) step(state *simPrice, now time.Time) {
+	dt := now.Sub(state.updated).Seconds() / simYear.Seconds()
+	state.updated = now
+	if dt <= 0 {
+		return
+	}
+
+	sigma := m.config.Volatility
+	if m.highVol {
+		sigma *= m.config.HighVolFactor
+	}
+	shock := sigma * math.Sqrt(dt) * m.rng.NormFloat64()
+	logPrice := math.Log(state.price)
+	switch m.config.Model {
+	case SimModelMeanReversion:
+		// Exact decay toward the mean, stable for any reversion speed and step
+		decay := math.Exp(-m.config.MeanReversion * dt)
+		logPrice = math.Log(state.mean) + (logPrice-math.Log(state.mean))*decay + shock
+	default:
+		logPrice += (m.config.Drift-sigma*sigma/2)*dt + shock
+	}
+	if m.config.GapProbability > 0 && m.rng.Float64() < m.config.GapProbability {
+		gap := m.config.GapSize * (0.5 + m.rng.Float64())
+		if m.rng.Intn(2) == 0 {
+			gap = -gap
+		}
+		logPrice += math.Log1p(math.Max(gap, -0.99))
+	}
+	state.price = math.Exp(logPrice)
+}
+
+// Symbols describes the simulated universe.
+func (m *simMarket) Symbols(context.Context) ([]*pb.SymbolInfo, error) {
+	infos := make([]*pb.SymbolInfo, 0, len(m.symbols))
+	for _, symbol := range m.symbols {
+		infos = append(infos, &pb.SymbolInfo{
+			Symbol:      symbol,
+			Description: "Simulated instrument",
+			Modes: []pb.SubscriptionMode{
+				pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+				pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+			},
+		})
+	}
+	return infos, nil
+}
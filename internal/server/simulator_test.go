@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestSimulationScenarios(t *testing.T) {
+	for name := range simScenarios {
+		sim, err := NewSimulationConfig(name)
+		require.NoError(t, err, name)
+		assert.NoError(t, sim.Validate(), name)
+	}
+	_, err := NewSimulationConfig("apocalypse")
+	assert.Error(t, err)
+
+	sim, _ := NewSimulationConfig("calm")
+	sim.Symbols = append(sim.Symbols, SimSymbol{Symbol: "EURUSD", Price: 1})
+	assert.Error(t, sim.Validate(), "duplicate symbol")
+	sim, _ = NewSimulationConfig("calm")
+	sim.Model = "random"
+	assert.Error(t, sim.Validate())
+}
+
+func TestLoadSimulationFromEnv(t *testing.T) {
+	sim, err := loadSimulationFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, sim, "disabled without SIM_ variables")
+
+	t.Setenv("SIM_SCENARIO", "gappy")
+	t.Setenv("SIM_SYMBOLS", "EURUSD:1.08, BTCUSD:65000")
+	t.Setenv("SIM_TICKS_PER_SECOND", "20")
+	t.Setenv("SIM_SEED", "42")
+	sim, err = loadSimulationFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, []SimSymbol{{"EURUSD", 1.08}, {"BTCUSD", 65000}}, sim.Symbols)
+	assert.Equal(t, 20.0, sim.TicksPerSecond)
+	assert.Equal(t, int64(42), sim.Seed)
+	assert.Equal(t, 0.05, sim.GapSize, "scenario defaults are kept")
+
+	t.Setenv("SIM_SYMBOL_COUNT", "3")
+	sim, err = loadSimulationFromEnv()
+	require.NoError(t, err)
+	require.Len(t, sim.Symbols, 3)
+	assert.Equal(t, "SIM00002", sim.Symbols[2].Symbol)
+
+	t.Setenv("SIM_TICKS_PER_SECOND", "0")
+	_, err = loadSimulationFromEnv()
+	assert.Error(t, err)
+}
+
+func TestSimMarketSharesPricePath(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+	sim, _ := NewSimulationConfig("volatile")
+	sim.Seed = 1
+	config := DefaultConfig()
+	config.Clock = clock
+	config.Simulation = sim
+	market := config.dataSource().(*simMarket)
+	require.Same(t, market, config.dataSource(), "subscriptions share one market")
+
+	all := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	one := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	one.setSymbols([]string{"BTCUSD"})
+
+	clock.Advance(time.Minute)
+	ticks := market.sample(all)
+	require.Len(t, ticks, len(simDefaultUniverse))
+	for _, tick := range ticks {
+		assert.Greater(t, tick.Price, 0.0)
+		assert.Less(t, tick.Bid, tick.Ask)
+	}
+	var btc *pb.Tick
+	for _, tick := range ticks {
+		if tick.Symbol == "BTCUSD" {
+			btc = tick
+		}
+	}
+	require.NotNil(t, btc)
+	assert.NotEqual(t, 65000.0, btc.Price, "price moved")
+
+	// No time has passed, so the second subscription sees the same price
+	same := market.sample(one)
+	require.Len(t, same, 1)
+	assert.Equal(t, btc.Price, same[0].Price)
+
+	infos, err := market.Symbols(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, infos, len(simDefaultUniverse))
+}
+
+func TestSimMarketModels(t *testing.T) {
+	start := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	run := func(sim *SimulationConfig, steps int, every time.Duration) float64 {
+		clock := NewFakeClock(start)
+		sim.Symbols = []SimSymbol{{"X", 100}}
+		sim.Seed = 7
+		market := newSimMarket(sim, clock)
+		sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+		var price float64
+		for i := 0; i < steps; i++ {
+			clock.Advance(every)
+			price = market.sample(sub)[0].Price
+		}
+		return price
+	}
+
+	// Strong reversion keeps the price near its mean despite high volatility
+	reverting := &SimulationConfig{Model: SimModelMeanReversion, Volatility: 2, MeanReversion: 1e6, TicksPerSecond: 1}
+	assert.InDelta(t, 100, run(reverting, 1000, time.Hour), 5)
+
+	// Every step gaps by at least half of GapSize
+	gappy := &SimulationConfig{Model: SimModelGBM, GapProbability: 1, GapSize: 0.1, TicksPerSecond: 1}
+	moved := run(gappy, 1, time.Second)
+	assert.GreaterOrEqual(t, math.Abs(moved-100), 4.9)
+}
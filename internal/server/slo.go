@@ -0,0 +1,151 @@
+// Package server implements error budget and SLO tracking for batch delivery.
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// sloBuckets is how many buckets the rolling window is divided into.
+const sloBuckets = 60
+
+// sloMinEvents is how many batches a window must hold before the budget can
+// be declared exhausted, so a handful of early failures do not flip health.
+const sloMinEvents = 100
+
+// sloFastWindow is the short window used for the fast burn rate.
+const sloFastWindow = 5 * time.Minute
+
+type sloBucket struct {
+	start time.Time
+	total uint64
+	good  uint64
+}
+
+// SLOTracker measures batch delivery against an objective: a batch is good
+// when it is handed to the connection within the latency threshold. Counts
+// are kept in a rolling window of fixed-width buckets.
+type SLOTracker struct {
+	target    float64
+	threshold time.Duration
+	window    time.Duration
+	width     time.Duration
+	clock     Clock
+
+	mu      sync.Mutex
+	buckets [sloBuckets]sloBucket
+}
+
+// NewSLOTracker creates a tracker for target (e.g. 0.999, strictly between 0
+// and 1) of batches delivered within threshold over window.
+func NewSLOTracker(target float64, threshold, window time.Duration, clock Clock) *SLOTracker {
+	width := window / sloBuckets
+	if width <= 0 {
+		width = time.Second
+	}
+	return &SLOTracker{
+		target:    target,
+		threshold: threshold,
+		window:    window,
+		width:     width,
+		clock:     clock,
+	}
+}
+
+// Record counts one delivery attempt. delivered is false when the batch
+// could not be sent at all.
+func (t *SLOTracker) Record(delivered bool, latency time.Duration) {
+	now := t.clock.Now()
+	start := now.Truncate(t.width)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[(start.UnixNano()/int64(t.width))%sloBuckets]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	b.total++
+	if delivered && latency <= t.threshold {
+		b.good++
+	}
+}
+
+// counts returns the totals for buckets that started within span of now.
+func (t *SLOTracker) counts(now time.Time, span time.Duration) (total, good uint64) {
+	oldest := now.Add(-span)
+	for _, b := range t.buckets {
+		if b.total > 0 && b.start.After(oldest) {
+			total += b.total
+			good += b.good
+		}
+	}
+	return total, good
+}
+
+// SLOStatus is a snapshot of SLO compliance over the rolling window.
+type SLOStatus struct {
+	Target               float64       `json:"target"`
+	LatencyThreshold     time.Duration `json:"latency_threshold"`
+	Window               time.Duration `json:"window"`
+	Total                uint64        `json:"total"`
+	Good                 uint64        `json:"good"`
+	SuccessRatio         float64       `json:"success_ratio"`
+	ErrorBudgetRemaining float64       `json:"error_budget_remaining"` // Fraction of the window's budget left, negative once overspent
+	BurnRate             float64       `json:"burn_rate"`              // Over the whole window; 1 spends the budget exactly
+	FastBurnRate         float64       `json:"fast_burn_rate"`         // Over the last five minutes
+	BudgetExhausted      bool          `json:"budget_exhausted"`
+}
+
+// Status returns the current compliance.
+func (t *SLOTracker) Status() SLOStatus {
+	now := t.clock.Now()
+
+	t.mu.Lock()
+	total, good := t.counts(now, t.window)
+	fastTotal, fastGood := t.counts(now, min(sloFastWindow, t.window))
+	t.mu.Unlock()
+
+	st := SLOStatus{
+		Target:               t.target,
+		LatencyThreshold:     t.threshold,
+		Window:               t.window,
+		Total:                total,
+		Good:                 good,
+		SuccessRatio:         1,
+		ErrorBudgetRemaining: 1,
+	}
+	if total > 0 {
+		st.SuccessRatio = float64(good) / float64(total)
+		st.BurnRate = t.burnRate(total, good)
+		st.ErrorBudgetRemaining = 1 - st.BurnRate
+	}
+	st.FastBurnRate = t.burnRate(fastTotal, fastGood)
+	st.BudgetExhausted = total >= sloMinEvents && st.ErrorBudgetRemaining <= 0
+	return st
+}
+
+// burnRate is the observed error rate as a multiple of the allowed rate.
+func (t *SLOTracker) burnRate(total, good uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	errorRate := float64(total-good) / float64(total)
+	return errorRate / (1 - t.target)
+}
+
+// GetStats returns the status as a map for Server.GetStats.
+func (st SLOStatus) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"target":                 st.Target,
+		"latency_threshold":      st.LatencyThreshold.String(),
+		"window":                 st.Window.String(),
+		"total":                  st.Total,
+		"good":                   st.Good,
+		"success_ratio":          st.SuccessRatio,
+		"error_budget_remaining": st.ErrorBudgetRemaining,
+		"burn_rate":              st.BurnRate,
+		"fast_burn_rate":         st.FastBurnRate,
+		"budget_exhausted":       st.BudgetExhausted,
+	}
+}
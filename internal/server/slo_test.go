@@ -0,0 +1,73 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOTrackerBurnRate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1_700_000_000, 0))
+	slo := NewSLOTracker(0.99, 10*time.Millisecond, time.Hour, clock)
+
+	st := slo.Status()
+	assert.Equal(t, 1.0, st.SuccessRatio, "an empty window is compliant")
+	assert.False(t, st.BudgetExhausted)
+
+	for i := 0; i < 197; i++ {
+		slo.Record(true, time.Millisecond)
+	}
+	slo.Record(true, 20*time.Millisecond) // Too slow
+	slo.Record(false, 0)                  // Not delivered
+	slo.Record(false, 0)
+
+	st = slo.Status()
+	assert.Equal(t, uint64(200), st.Total)
+	assert.Equal(t, uint64(197), st.Good)
+	assert.InDelta(t, 0.985, st.SuccessRatio, 1e-9)
+	assert.InDelta(t, 1.5, st.BurnRate, 1e-9, "a 1.5% error rate against a 1% budget")
+	assert.InDelta(t, -0.5, st.ErrorBudgetRemaining, 1e-9)
+	assert.Equal(t, st.BurnRate, st.FastBurnRate)
+	assert.True(t, st.BudgetExhausted)
+
+	// The failures age out of the fast window first, then the whole window
+	clock.Advance(10 * time.Minute)
+	for i := 0; i < 200; i++ {
+		slo.Record(true, time.Millisecond)
+	}
+	st = slo.Status()
+	assert.Equal(t, 0.0, st.FastBurnRate)
+	assert.InDelta(t, 0.25, st.ErrorBudgetRemaining, 1e-9)
+	assert.False(t, st.BudgetExhausted)
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, uint64(0), slo.Status().Total)
+}
+
+func TestSLOTrackerNeedsMinimumEvents(t *testing.T) {
+	slo := NewSLOTracker(0.999, time.Millisecond, time.Hour, NewFakeClock(time.Unix(0, 0)))
+	for i := 0; i < sloMinEvents-1; i++ {
+		slo.Record(false, 0)
+	}
+	assert.False(t, slo.Status().BudgetExhausted)
+	slo.Record(false, 0)
+	assert.True(t, slo.Status().BudgetExhausted)
+}
+
+func TestHealthDegradedWhenSLOBudgetExhausted(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Unix(1_700_000_000, 0))
+	srv := NewServer(config)
+	require.NotNil(t, srv.slo)
+
+	assert.Equal(t, HealthStatusHealthy, srv.healthChecker.determineOverallStatus())
+	for i := 0; i < sloMinEvents; i++ {
+		srv.slo.Record(false, 0)
+	}
+	assert.Equal(t, HealthStatusDegraded, srv.healthChecker.determineOverallStatus())
+	health := srv.healthChecker.GetHealth()
+	assert.Equal(t, HealthStatusDegraded, health.Checks["slo"].Status)
+	assert.Equal(t, true, srv.GetStats()["slo"].(map[string]interface{})["budget_exhausted"])
+}
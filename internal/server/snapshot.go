@@ -0,0 +1,183 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+type lastValueKey struct {
+	mode   pb.SubscriptionMode
+	symbol string
+}
+
+// LastValueCache keeps the latest tick per symbol and subscription mode so
+// new subscribers can be sent a snapshot before live updates begin, along
+// with the stream status of symbols that are halted or stale.
+type LastValueCache struct {
+	maxSymbols int
+
+	mu       sync.RWMutex
+	ticks    map[lastValueKey]*pb.Tick
+	statuses map[string]*pb.StreamStatus // Symbols not live; live symbols have no entry
+
+	dropped atomic.Uint64 // Ticks for new symbols refused once the cache is full
+	served  atomic.Uint64 // Snapshots sent
+}
+
+// NewLastValueCache creates a cache holding up to maxSymbols entries
+// (0 means unbounded).
+func NewLastValueCache(maxSymbols int) *LastValueCache {
+	return &LastValueCache{
+		maxSymbols: maxSymbols,
+		ticks:      make(map[lastValueKey]*pb.Tick),
+		statuses:   make(map[string]*pb.StreamStatus),
+	}
+}
+
+// Update records ticks, keeping the newest tick for each symbol.
+func (c *LastValueCache) Update(ticks []*pb.Tick) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tick := range ticks {
+		if tick.Symbol == "" {
+			continue
+		}
+		key := lastValueKey{mode: tick.Mode, symbol: tick.Symbol}
+		prev, ok := c.ticks[key]
+		if !ok && c.maxSymbols > 0 && len(c.ticks) >= c.maxSymbols {
+			c.dropped.Add(1)
+			continue
+		}
+		if ok && prev.TimestampMs > tick.TimestampMs {
+			continue
+		}
+		c.ticks[key] = tick
+	}
+}
+
+// Snapshot returns the latest tick in mode for each of symbols, or for every
+// cached symbol when symbols is empty, sorted by symbol. allow, when set,
+// filters the symbols the caller may see.
+func (c *LastValueCache) Snapshot(mode pb.SubscriptionMode, symbols []string, allow func(string) bool) []*pb.Tick {
+	c.mu.RLock()
+	var ticks []*pb.Tick
+	if len(symbols) > 0 {
+		for _, symbol := range symbols {
+			if tick, ok := c.ticks[lastValueKey{mode: mode, symbol: symbol}]; ok {
+				ticks = append(ticks, tick)
+			}
+		}
+	} else {
+		for key, tick := range c.ticks {
+			if key.mode == mode {
+				ticks = append(ticks, tick)
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	filtered := ticks[:0]
+	for _, tick := range ticks {
+		if allow == nil || allow(tick.Symbol) {
+			filtered = append(filtered, tick)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Symbol < filtered[j].Symbol })
+	return filtered
+}
+
+// SetStatus records the stream status of a symbol and reports whether its
+// state changed. Live symbols are forgotten, so the statuses kept are
+// bounded by the symbols currently halted or stale.
+func (c *LastValueCache) SetStatus(status *pb.StreamStatus) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.statuses[status.Symbol]
+	if status.State == pb.StreamState_STREAM_STATE_LIVE {
+		delete(c.statuses, status.Symbol)
+		return ok
+	}
+	c.statuses[status.Symbol] = status
+	return !ok || prev.State != status.State
+}
+
+// Statuses returns the status of each of symbols, or of every symbol when
+// empty, that is halted or stale, sorted by symbol. allow, when set,
+// filters the symbols the caller may see.
+func (c *LastValueCache) Statuses(symbols []string, allow func(string) bool) []*pb.StreamStatus {
+	c.mu.RLock()
+	var statuses []*pb.StreamStatus
+	if len(symbols) > 0 {
+		for _, symbol := range symbols {
+			if status, ok := c.statuses[symbol]; ok {
+				statuses = append(statuses, status)
+			}
+		}
+	} else {
+		for _, status := range c.statuses {
+			statuses = append(statuses, status)
+		}
+	}
+	c.mu.RUnlock()
+
+	filtered := statuses[:0]
+	for _, status := range statuses {
+		if allow == nil || allow(status.Symbol) {
+			filtered = append(filtered, status)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Symbol < filtered[j].Symbol })
+	return filtered
+}
+
+// Len returns the number of cached symbols across modes.
+func (c *LastValueCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.ticks)
+}
+
+// GetStats returns last-value cache statistics.
+func (c *LastValueCache) GetStats() map[string]interface{} {
+	c.mu.RLock()
+	notLive := len(c.statuses)
+	c.mu.RUnlock()
+	return map[string]interface{}{
+		"symbols":         c.Len(),
+		"not_live":        notLive,
+		"max_symbols":     c.maxSymbols,
+		"dropped_total":   c.dropped.Load(),
+		"snapshots_total": c.served.Load(),
+	}
+}
+
+// sendSnapshot sends the latest cached tick of each of symbols, or of every
+// symbol when empty, as a single snapshot batch, followed by the status of
+// those that are halted or stale. Nothing is sent when the cache holds none
+// of them.
+func (h *ConnectionHandler) sendSnapshot(mode pb.SubscriptionMode, symbols []string) error {
+	if h.server == nil || h.server.lastValues == nil {
+		return nil
+	}
+	var allow func(string) bool
+	if tenant := h.conn.Tenant(); tenant != nil {
+		allow = tenant.AllowsSymbol
+	}
+	ticks := h.server.lastValues.Snapshot(mode, symbols, allow)
+	if len(ticks) > 0 {
+		if err := h.conn.SendSnapshot(ticks); err != nil {
+			return err
+		}
+		h.server.lastValues.served.Add(1)
+		h.logger.Debug("snapshot sent", "symbols", len(ticks))
+	}
+	for _, status := range h.server.lastValues.Statuses(symbols, allow) {
+		if err := h.conn.SendStreamStatus(status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
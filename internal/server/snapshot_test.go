@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestLastValueCacheKeepsLatestPerSymbol(t *testing.T) {
+	second := pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	minute := pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE
+	cache := NewLastValueCache(3)
+	cache.Update([]*pb.Tick{
+		{Symbol: "EURUSD", Price: 1.0, TimestampMs: 100, Mode: second},
+		{Symbol: "BTCUSD", Price: 50, TimestampMs: 100, Mode: second},
+		{Symbol: "EURUSD", Price: 1.1, TimestampMs: 200, Mode: second},
+		{Symbol: "EURUSD", Price: 0.9, TimestampMs: 150, Mode: second}, // Out of order, ignored
+		{Symbol: "EURUSD", Price: 2.0, TimestampMs: 100, Mode: minute},
+		{Symbol: "XAUUSD", Price: 2000, TimestampMs: 100, Mode: second}, // Cache full
+	})
+
+	all := cache.Snapshot(second, nil, nil)
+	require.Len(t, all, 2)
+	assert.Equal(t, "BTCUSD", all[0].Symbol)
+	assert.Equal(t, "EURUSD", all[1].Symbol)
+	assert.Equal(t, 1.1, all[1].Price)
+
+	picked := cache.Snapshot(second, []string{"EURUSD", "XAUUSD"}, nil)
+	require.Len(t, picked, 1)
+	assert.Equal(t, "EURUSD", picked[0].Symbol)
+
+	allowed := cache.Snapshot(second, nil, func(symbol string) bool { return symbol == "BTCUSD" })
+	require.Len(t, allowed, 1)
+	assert.Equal(t, "BTCUSD", allowed[0].Symbol)
+
+	assert.Equal(t, 2.0, cache.Snapshot(minute, nil, nil)[0].Price)
+	assert.Equal(t, uint64(1), cache.dropped.Load())
+}
+
+func TestSubscribeSendsSnapshotAfterConfirmation(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	config := DefaultConfig()
+	conn := NewConnection(serverSide, config)
+	srv := &Server{config: config, lastValues: NewLastValueCache(10)}
+	srv.lastValues.Update([]*pb.Tick{
+		{Symbol: "EURUSD", Price: 1.1, TimestampMs: 100, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE},
+		{Symbol: "BTCUSD", Price: 50, TimestampMs: 100, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+		dataChan:      make(chan []*pb.Tick, 10),
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		server:        srv,
+	}
+	t.Cleanup(func() {
+		cancel()
+		conn.Close()
+		clientSide.Close()
+	})
+
+	payload, err := proto.Marshal(&pb.SubscribeRequest{
+		Mode:    pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+		Symbols: []string{"EURUSD"},
+	})
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- handler.processFrame(ctx, &protocol.Frame{Type: protocol.MessageTypeSubscribe, Payload: payload})
+	}()
+
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeACK, frame.Type)
+
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeDataBatch, frame.Type)
+	require.NoError(t, <-errCh)
+
+	var batch pb.DataBatch
+	require.NoError(t, proto.Unmarshal(frame.Payload, &batch))
+	assert.True(t, batch.IsSnapshot)
+	assert.Zero(t, batch.BatchSequence, "snapshots are outside the batch sequence")
+	require.Len(t, batch.Ticks, 1)
+	assert.Equal(t, "EURUSD", batch.Ticks[0].Symbol)
+	assert.Equal(t, uint64(1), srv.lastValues.served.Load())
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// ErrSpillFull is returned when a frame would take a connection's disk
+// spill past Config.SpillMaxBytes.
+var ErrSpillFull = errors.New("write spill full - slow client detected")
+
+// spillCounters aggregates spill activity across connections.
+type spillCounters struct {
+	spilled   atomic.Uint64 // Frames written to disk
+	replayed  atomic.Uint64 // Frames written from disk to the socket
+	overflows atomic.Uint64 // Frames refused because the spill was full
+}
+
+// frameSpill is a bounded on-disk FIFO holding frames a connection could not
+// queue in memory. While it holds frames every new frame is appended to it,
+// so the client still sees them in order once the write loop replays them.
+type frameSpill struct {
+	dir      string
+	maxBytes int64
+	counters *spillCounters // May be nil
+
+	mu       sync.Mutex
+	file     *os.File // Created on first use, truncated whenever it empties
+	readOff  int64
+	writeOff int64
+	frames   int
+
+	ready chan struct{} // Wakes an idle write loop after a push
+}
+
+func newFrameSpill(dir string, maxBytes int64, counters *spillCounters) *frameSpill {
+	return &frameSpill{
+		dir:      dir,
+		maxBytes: maxBytes,
+		counters: counters,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// offer appends frame to the spill when it already holds frames or when
+// full reports that the in-memory queue cannot take it, and reports whether
+// the frame was taken.
+func (s *frameSpill) offer(frame *protocol.Frame, full bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.frames == 0 && !full {
+		return false, nil
+	}
+	if err := s.push(frame); err != nil {
+		if errors.Is(err, ErrSpillFull) && s.counters != nil {
+			s.counters.overflows.Add(1)
+		}
+		return true, err
+	}
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// push appends frame to the file. Callers hold s.mu.
+func (s *frameSpill) push(frame *protocol.Frame) error {
+	data, err := frame.Marshal()
+	if err != nil {
+		return err
+	}
+	if s.maxBytes > 0 && s.writeOff+int64(len(data)) > s.maxBytes {
+		return ErrSpillFull
+	}
+	if s.file == nil {
+		f, err := os.CreateTemp(s.dir, "tick-storm-spill-*")
+		if err != nil {
+			return fmt.Errorf("create spill file: %w", err)
+		}
+		s.file = f
+	}
+	if _, err := s.file.WriteAt(data, s.writeOff); err != nil {
+		return fmt.Errorf("write spill file: %w", err)
+	}
+	s.writeOff += int64(len(data))
+	s.frames++
+	if s.counters != nil {
+		s.counters.spilled.Add(1)
+	}
+	return nil
+}
+
+// pop removes and returns the oldest frame, or nil when the spill is empty.
+func (s *frameSpill) pop() (*protocol.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.frames == 0 {
+		return nil, nil
+	}
+	r := io.NewSectionReader(s.file, s.readOff, s.writeOff-s.readOff)
+	frame, err := protocol.NewFrameReader(r, protocol.DefaultMaxMessageSize).ReadFrame()
+	if err != nil {
+		return nil, fmt.Errorf("read spill file: %w", err)
+	}
+	s.readOff += frameWireSize(frame)
+	s.frames--
+	if s.frames == 0 {
+		// Reclaim the disk once the client has caught up
+		s.readOff, s.writeOff = 0, 0
+		if err := s.file.Truncate(0); err != nil {
+			return nil, fmt.Errorf("truncate spill file: %w", err)
+		}
+	}
+	if s.counters != nil {
+		s.counters.replayed.Add(1)
+	}
+	return frame, nil
+}
+
+// pending returns the frames and bytes waiting on disk.
+func (s *frameSpill) pending() (frames int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames, s.writeOff - s.readOff
+}
+
+// close discards any frames left and removes the file.
+func (s *frameSpill) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	s.file.Close()
+	s.file = nil
+	s.frames, s.readOff, s.writeOff = 0, 0, 0
+	return os.Remove(name)
+}
+
+// spillEnabledFor reports whether connections in class may spill to disk.
+func (c *Config) spillEnabledFor(class PriorityClass) bool {
+	if c.SpillDir == "" {
+		return false
+	}
+	for _, sc := range c.SpillClasses {
+		if sc == class {
+			return true
+		}
+	}
+	return false
+}
+
+// spillStats returns spill activity and the frames currently on disk.
+func (s *Server) spillStats() map[string]interface{} {
+	var connections, frames int
+	var bytes int64
+	s.connections.each(func(conn *Connection) {
+		if n, b := conn.SpillPending(); n > 0 {
+			connections++
+			frames += n
+			bytes += b
+		}
+	})
+	return map[string]interface{}{
+		"spilling_connections": connections,
+		"pending_frames":       frames,
+		"pending_bytes":        bytes,
+		"spilled_total":        s.spillCounters.spilled.Load(),
+		"replayed_total":       s.spillCounters.replayed.Load(),
+		"overflows_total":      s.spillCounters.overflows.Load(),
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func newSpillConn(t *testing.T, maxBytes int64) (*Connection, net.Conn, string) {
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	config := DefaultConfig()
+	config.MaxWriteQueueSize = 2
+	conn := NewConnection(server, config)
+	t.Cleanup(func() { conn.Close() })
+	dir := t.TempDir()
+	conn.enableSpill(dir, maxBytes, &spillCounters{})
+	return conn, client, dir
+}
+
+func spillTestFrame(i int) *protocol.Frame {
+	return &protocol.Frame{Version: protocol.ProtocolVersion, Type: protocol.MessageTypeInfo, Payload: []byte{byte(i)}}
+}
+
+func TestSpillReplaysOverflowInOrder(t *testing.T) {
+	conn, client, dir := newSpillConn(t, 1<<20)
+
+	// The client is not reading, so everything past the queue goes to disk
+	const total = 50
+	for i := 0; i < total; i++ {
+		require.NoError(t, conn.WriteFrameAsync(spillTestFrame(i)))
+	}
+	frames, bytes := conn.SpillPending()
+	assert.Greater(t, frames, 0)
+	assert.Greater(t, bytes, int64(0))
+
+	reader := protocol.NewFrameReader(client, 0)
+	for i := 0; i < total; i++ {
+		frame, err := reader.ReadFrame()
+		require.NoError(t, err)
+		require.Equal(t, []byte{byte(i)}, frame.Payload, "frame %d out of order", i)
+	}
+	frames, _ = conn.SpillPending()
+	assert.Zero(t, frames)
+
+	require.NoError(t, conn.Close())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "spill file should be removed on close")
+}
+
+func TestSpillRefusesFramesPastLimit(t *testing.T) {
+	frameSize := frameWireSize(spillTestFrame(0))
+	conn, _, _ := newSpillConn(t, 3*frameSize)
+	counters := conn.spill.Load().counters
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = conn.WriteFrameAsync(spillTestFrame(i))
+	}
+	assert.ErrorIs(t, err, ErrSpillFull)
+	assert.Equal(t, uint64(3), counters.spilled.Load())
+	assert.Equal(t, uint64(1), counters.overflows.Load())
+}
+
+func TestSpillEnabledForClasses(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.spillEnabledFor(PriorityGold), "disabled without a directory")
+
+	config.SpillDir = t.TempDir()
+	assert.True(t, config.spillEnabledFor(PriorityGold))
+	assert.False(t, config.spillEnabledFor(PrioritySilver))
+}
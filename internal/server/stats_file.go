@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// saveStatsFile writes GetStats to StatsFile atomically, if one is
+// configured.
+func (s *Server) saveStatsFile() error {
+	path := s.config.StatsFile
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.GetStats(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".stats-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return nil
+}
+
+// storeStatsFile saves the final stats on shutdown, logging failures.
+func (s *Server) storeStatsFile() {
+	if err := s.saveStatsFile(); err != nil {
+		s.logger.Warn("stats file not written", "file", s.config.StatsFile, "error", err)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownWritesStatsFile(t *testing.T) {
+	config := DefaultConfig()
+	config.ListenAddr = "127.0.0.1:0"
+	config.OpsListenAddr = ""
+	config.StatsFile = filepath.Join(t.TempDir(), "stats.json")
+	srv := NewServer(config)
+	require.NoError(t, srv.Start())
+	_, err := os.Stat(config.StatsFile)
+	require.True(t, os.IsNotExist(err), "stats are only written on shutdown")
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(config.StatsFile)
+	require.NoError(t, err)
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &stats))
+	assert.Equal(t, float64(0), stats["active_connections"])
+	assert.Equal(t, config.ListenAddr, stats["listen_addr"])
+}
+
+func TestSaveStatsFileReportsUnwritablePath(t *testing.T) {
+	config := DefaultConfig()
+	config.StatsFile = filepath.Join(t.TempDir(), "missing", "stats.json")
+	err := NewServer(config).saveStatsFile()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to write stats file")
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// ReportStreamStatus records that a symbol was halted, resumed or went stale
+// and sends a STREAM_STATUS frame to every subscriber receiving it. Data
+// sources implementing StreamStatusSource report through it; it is exported
+// for sources that learn of halts elsewhere. With the last-value cache
+// enabled, repeated reports of the same state are dropped, and new
+// subscribers are told which of their symbols are not live after their
+// snapshot.
+func (s *Server) ReportStreamStatus(status *pb.StreamStatus) error {
+	if err := protocol.ValidateStreamStatus(status); err != nil {
+		return err
+	}
+	if status.TimestampMs == 0 {
+		status.TimestampMs = s.config.clock().Now().UnixMilli()
+	}
+	if s.lastValues != nil && !s.lastValues.SetStatus(status) {
+		return nil
+	}
+
+	state := strings.ToLower(strings.TrimPrefix(status.State.String(), "STREAM_STATE_"))
+	if s.prometheusMetrics != nil {
+		s.prometheusMetrics.IncrementStreamStatusChanges(s.instanceID, state)
+	}
+	s.logger.Info("symbol stream status changed",
+		"symbol", status.Symbol,
+		"state", state,
+		"reason", status.Reason,
+	)
+
+	conns := s.connections.filter(func(conn *Connection) bool {
+		sub := conn.GetSubscription()
+		if sub == nil || !sub.wantsSymbol(status.Symbol) {
+			return false
+		}
+		tenant := conn.Tenant()
+		return tenant == nil || tenant.AllowsSymbol(status.Symbol)
+	})
+	for _, conn := range conns {
+		if err := conn.SendStreamStatus(status); err != nil {
+			s.logger.Debug("failed to send stream status", "connection_id", conn.ID(), "symbol", status.Symbol, "error", err)
+		}
+	}
+	return nil
+}
+
+// SendStreamStatus tells the client a subscribed symbol's stream condition
+// changed.
+func (c *Connection) SendStreamStatus(status *pb.StreamStatus) error {
+	return c.SendMessage(protocol.MessageTypeStreamStatus, status)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func streamStatus(symbol string, state pb.StreamState) *pb.StreamStatus {
+	return &pb.StreamStatus{Symbol: symbol, State: state, TimestampMs: 100}
+}
+
+func TestLastValueCacheStatuses(t *testing.T) {
+	cache := NewLastValueCache(10)
+	assert.True(t, cache.SetStatus(streamStatus("EURUSD", pb.StreamState_STREAM_STATE_HALTED)))
+	assert.False(t, cache.SetStatus(streamStatus("EURUSD", pb.StreamState_STREAM_STATE_HALTED)), "same state again")
+	assert.True(t, cache.SetStatus(streamStatus("EURUSD", pb.StreamState_STREAM_STATE_STALE)))
+	assert.True(t, cache.SetStatus(streamStatus("BTCUSD", pb.StreamState_STREAM_STATE_HALTED)))
+	assert.False(t, cache.SetStatus(streamStatus("XAUUSD", pb.StreamState_STREAM_STATE_LIVE)), "already live")
+
+	all := cache.Statuses(nil, nil)
+	require.Len(t, all, 2)
+	assert.Equal(t, "BTCUSD", all[0].Symbol)
+	assert.Equal(t, pb.StreamState_STREAM_STATE_STALE, all[1].State)
+	assert.Len(t, cache.Statuses([]string{"EURUSD", "XAUUSD"}, nil), 1)
+	assert.Empty(t, cache.Statuses(nil, func(symbol string) bool { return symbol == "XAUUSD" }))
+
+	assert.True(t, cache.SetStatus(streamStatus("EURUSD", pb.StreamState_STREAM_STATE_LIVE)))
+	assert.Len(t, cache.Statuses(nil, nil), 1, "live symbols are forgotten")
+}
+
+// statusClient subscribes a pipe connection on srv to symbols and returns a
+// function reading the next STREAM_STATUS frame, or nil when none arrives.
+func statusClient(t *testing.T, srv *Server, id string, symbols ...string) func() *pb.StreamStatus {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, srv.config)
+	conn.id = id
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	sub.setSymbols(symbols)
+	require.NoError(t, conn.SetSubscription(sub))
+	srv.connections.add(conn)
+	t.Cleanup(func() {
+		clientSide.Close()
+		conn.Close()
+	})
+
+	reader := protocol.NewFrameReader(clientSide, srv.config.MaxMessageSize)
+	return func() *pb.StreamStatus {
+		clientSide.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			return nil
+		}
+		require.Equal(t, protocol.MessageTypeStreamStatus, frame.Type)
+		var status pb.StreamStatus
+		require.NoError(t, protocol.UnmarshalMessage(frame, &status))
+		return &status
+	}
+}
+
+func TestReportStreamStatusForwardsChanges(t *testing.T) {
+	srv := &Server{
+		config:     DefaultConfig(),
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		lastValues: NewLastValueCache(10),
+	}
+	eurusd := statusClient(t, srv, "a", "EURUSD")
+	gbpusd := statusClient(t, srv, "b", "GBPUSD")
+	everything := statusClient(t, srv, "c")
+
+	halt := &pb.StreamStatus{Symbol: "EURUSD", State: pb.StreamState_STREAM_STATE_HALTED, Reason: "volatility halt"}
+	go func() { assert.NoError(t, srv.ReportStreamStatus(halt)) }()
+	for _, next := range []func() *pb.StreamStatus{eurusd, everything} {
+		status := next()
+		require.NotNil(t, status)
+		assert.Equal(t, "EURUSD", status.Symbol)
+		assert.Equal(t, pb.StreamState_STREAM_STATE_HALTED, status.State)
+		assert.Equal(t, "volatility halt", status.Reason)
+		assert.Positive(t, status.TimestampMs, "unset timestamps are stamped")
+	}
+	assert.Nil(t, gbpusd(), "other symbols' subscribers are not told")
+
+	// A repeated report changes nothing and is not forwarded
+	require.NoError(t, srv.ReportStreamStatus(&pb.StreamStatus{Symbol: "EURUSD", State: pb.StreamState_STREAM_STATE_HALTED}))
+	assert.Nil(t, eurusd())
+
+	assert.ErrorIs(t, srv.ReportStreamStatus(&pb.StreamStatus{Symbol: "EURUSD"}), protocol.ErrInvalidEnum)
+}
+
+func TestSnapshotIncludesStreamStatus(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	config := DefaultConfig()
+	conn := NewConnection(serverSide, config)
+	srv := &Server{config: config, lastValues: NewLastValueCache(10)}
+	srv.lastValues.Update([]*pb.Tick{{Symbol: "EURUSD", Price: 1.1, TimestampMs: 100, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND}})
+	srv.lastValues.SetStatus(streamStatus("EURUSD", pb.StreamState_STREAM_STATE_STALE))
+	handler := &ConnectionHandler{conn: conn, config: config, server: srv,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- handler.sendSnapshot(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, []string{"EURUSD"})
+	}()
+
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	reader := protocol.NewFrameReader(clientSide, config.MaxMessageSize)
+	frame, err := reader.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, protocol.MessageTypeDataBatch, frame.Type)
+	frame, err = reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeStreamStatus, frame.Type)
+	var status pb.StreamStatus
+	require.NoError(t, protocol.UnmarshalMessage(frame, &status))
+	assert.Equal(t, pb.StreamState_STREAM_STATE_STALE, status.State)
+	require.NoError(t, <-errCh)
+}
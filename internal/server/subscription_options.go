@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Subscription metadata keys that override server delivery defaults.
+const (
+	MetadataMaxBatchSize  = "max_batch_size"
+	MetadataBatchWindowMS = "batch_window_ms"
+	MetadataConflate      = "conflate"
+)
+
+var (
+	// ErrInvalidSubscriptionOption is returned when a delivery option in subscription metadata cannot be parsed.
+	ErrInvalidSubscriptionOption = errors.New("invalid subscription option")
+)
+
+// DeliveryOptions are the effective per-subscription batching settings.
+type DeliveryOptions struct {
+	MaxBatchSize int
+	BatchWindow  time.Duration
+	Conflate     bool // Keep only the latest tick per symbol in each batch
+}
+
+// ResolveDeliveryOptions applies recognised metadata keys on top of the server
+// defaults. Values are clamped to the configured bounds; unknown keys are ignored.
+func ResolveDeliveryOptions(metadata map[string]string, cfg *Config) (DeliveryOptions, error) {
+	opts := DeliveryOptions{
+		MaxBatchSize: cfg.MaxBatchSize,
+		BatchWindow:  cfg.BatchWindow,
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = 100
+	}
+	if opts.BatchWindow <= 0 {
+		opts.BatchWindow = 5 * time.Millisecond
+	}
+
+	if v, ok := metadata[MetadataMaxBatchSize]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("%w: %s must be a positive integer, got %q", ErrInvalidSubscriptionOption, MetadataMaxBatchSize, v)
+		}
+		if cfg.SubscriptionMaxBatchSize > 0 && n > cfg.SubscriptionMaxBatchSize {
+			n = cfg.SubscriptionMaxBatchSize
+		}
+		opts.MaxBatchSize = n
+	}
+
+	if v, ok := metadata[MetadataBatchWindowMS]; ok {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return opts, fmt.Errorf("%w: %s must be a positive integer, got %q", ErrInvalidSubscriptionOption, MetadataBatchWindowMS, v)
+		}
+		window := time.Duration(ms) * time.Millisecond
+		if cfg.SubscriptionMinBatchWindow > 0 && window < cfg.SubscriptionMinBatchWindow {
+			window = cfg.SubscriptionMinBatchWindow
+		}
+		if cfg.SubscriptionMaxBatchWindow > 0 && window > cfg.SubscriptionMaxBatchWindow {
+			window = cfg.SubscriptionMaxBatchWindow
+		}
+		opts.BatchWindow = window
+	}
+
+	if v, ok := metadata[MetadataConflate]; ok {
+		conflate, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %s must be a boolean, got %q", ErrInvalidSubscriptionOption, MetadataConflate, v)
+		}
+		opts.Conflate = conflate
+	}
+
+	return opts, nil
+}
+
+// Metadata renders the effective options for the subscription ACK.
+func (o DeliveryOptions) Metadata() map[string]string {
+	return map[string]string{
+		MetadataMaxBatchSize:  strconv.Itoa(o.MaxBatchSize),
+		MetadataBatchWindowMS: strconv.FormatInt(o.BatchWindow.Milliseconds(), 10),
+		MetadataConflate:      strconv.FormatBool(o.Conflate),
+	}
+}
+
+// conflateTicks keeps the latest tick for each symbol, ordered by each
+// symbol's first appearance in the batch.
+func conflateTicks(ticks []*pb.Tick) []*pb.Tick {
+	index := make(map[string]int, len(ticks))
+	out := make([]*pb.Tick, 0, len(ticks))
+	for _, tick := range ticks {
+		if i, ok := index[tick.Symbol]; ok {
+			out[i] = tick
+			continue
+		}
+		index[tick.Symbol] = len(out)
+		out = append(out, tick)
+	}
+	return out
+}
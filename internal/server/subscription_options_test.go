@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestResolveDeliveryOptions(t *testing.T) {
+	config := DefaultConfig()
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     DeliveryOptions
+		wantErr  bool
+	}{
+		{
+			name:     "server defaults",
+			metadata: nil,
+			want:     DeliveryOptions{MaxBatchSize: 100, BatchWindow: 5 * time.Millisecond},
+		},
+		{
+			name: "overrides within bounds",
+			metadata: map[string]string{
+				MetadataMaxBatchSize:  "10",
+				MetadataBatchWindowMS: "50",
+				MetadataConflate:      "true",
+				"client_tag":          "ignored",
+			},
+			want: DeliveryOptions{MaxBatchSize: 10, BatchWindow: 50 * time.Millisecond, Conflate: true},
+		},
+		{
+			name: "clamped to configured bounds",
+			metadata: map[string]string{
+				MetadataMaxBatchSize:  "1000000",
+				MetadataBatchWindowMS: "60000",
+			},
+			want: DeliveryOptions{MaxBatchSize: 1000, BatchWindow: time.Second},
+		},
+		{
+			name:     "non-numeric batch size",
+			metadata: map[string]string{MetadataMaxBatchSize: "lots"},
+			wantErr:  true,
+		},
+		{
+			name:     "zero batch window",
+			metadata: map[string]string{MetadataBatchWindowMS: "0"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid conflate flag",
+			metadata: map[string]string{MetadataConflate: "sometimes"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveDeliveryOptions(tt.metadata, config)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSubscriptionOption)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConflateTicksKeepsLatestPerSymbol(t *testing.T) {
+	ticks := []*pb.Tick{
+		{Symbol: "EURUSD", Price: 1.1},
+		{Symbol: "GBPUSD", Price: 1.2},
+		{Symbol: "EURUSD", Price: 1.3},
+	}
+
+	got := conflateTicks(ticks)
+	require.Len(t, got, 2)
+	assert.Equal(t, "EURUSD", got[0].Symbol)
+	assert.Equal(t, 1.3, got[0].Price)
+	assert.Equal(t, "GBPUSD", got[1].Symbol)
+}
+
+func TestSubscribeEchoesEffectiveOptions(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	config := DefaultConfig()
+	conn := NewConnection(serverSide, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		ctx:           ctx,
+		cancel:        cancel,
+		dataChan:      make(chan []*pb.Tick, 10),
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	t.Cleanup(func() {
+		cancel()
+		conn.Close()
+		clientSide.Close()
+	})
+
+	payload, err := proto.Marshal(&pb.SubscribeRequest{
+		Mode:     pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE,
+		Metadata: map[string]string{MetadataMaxBatchSize: "5000", MetadataConflate: "1"},
+	})
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- handler.processFrame(ctx, &protocol.Frame{Type: protocol.MessageTypeSubscribe, Payload: payload})
+	}()
+
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := protocol.NewFrameReader(clientSide, config.MaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeACK, frame.Type)
+	require.NoError(t, <-errCh)
+
+	var ack pb.AckResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &ack))
+	assert.Equal(t, "1000", ack.Metadata[MetadataMaxBatchSize], "clamped to SubscriptionMaxBatchSize")
+	assert.Equal(t, "5", ack.Metadata[MetadataBatchWindowMS], "server default echoed")
+	assert.Equal(t, "true", ack.Metadata[MetadataConflate])
+	assert.Equal(t, pb.DeliveryMode_DELIVERY_MODE_UNSPECIFIED.String(), ack.Metadata["delivery_mode"])
+
+	window, size := handler.batchSettings(config.BatchWindow, config.MaxBatchSize)
+	assert.Equal(t, 5*time.Millisecond, window)
+	assert.Equal(t, 1000, size)
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// setSymbols replaces the subscribed symbols, interning them in place.
+func (s *Subscription) setSymbols(symbols []string) {
+	index := make(map[string]struct{}, len(symbols))
+	for i, symbol := range symbols {
+		symbols[i] = protocol.Symbols.Intern(symbol)
+		index[symbols[i]] = struct{}{}
+	}
+	s.symbolsMu.Lock()
+	defer s.symbolsMu.Unlock()
+	s.Symbols = symbols
+	s.symbolIndex = index
+}
+
+// CurrentSymbols returns the subscribed symbols; empty means all. The slice
+// must not be modified.
+func (s *Subscription) CurrentSymbols() []string {
+	s.symbolsMu.RLock()
+	defer s.symbolsMu.RUnlock()
+	return s.Symbols
+}
+
+// wantsSymbol reports whether ticks for symbol belong to the subscription.
+func (s *Subscription) wantsSymbol(symbol string) bool {
+	s.symbolsMu.RLock()
+	defer s.symbolsMu.RUnlock()
+	if len(s.Symbols) == 0 {
+		return true
+	}
+	if s.symbolIndex != nil {
+		_, ok := s.symbolIndex[symbol]
+		return ok
+	}
+	for _, want := range s.Symbols {
+		if want == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateSymbols adds and then removes symbols, returning the resulting set
+// and the symbols newly added to it. A subscription to all symbols can only
+// be narrowed by adding symbols, and an update may not leave it empty.
+func (s *Subscription) UpdateSymbols(add, remove []string) (symbols, added []string, err error) {
+	s.symbolsMu.Lock()
+	defer s.symbolsMu.Unlock()
+
+	all := len(s.Symbols) == 0
+	if all && len(add) == 0 {
+		return nil, nil, fmt.Errorf("%w: subscription covers all symbols; add symbols to narrow it", protocol.ErrInvalidSubscription)
+	}
+
+	removed := make(map[string]bool, len(remove))
+	for _, symbol := range remove {
+		removed[symbol] = true
+	}
+	seen := make(map[string]struct{}, len(s.Symbols)+len(add))
+	for _, symbol := range s.Symbols {
+		if !removed[symbol] {
+			symbols = append(symbols, symbol)
+			seen[symbol] = struct{}{}
+		}
+	}
+	for _, symbol := range add {
+		if _, ok := seen[symbol]; ok || removed[symbol] {
+			continue
+		}
+		if !all {
+			added = append(added, symbol)
+		}
+		symbols = append(symbols, symbol)
+		seen[symbol] = struct{}{}
+	}
+
+	if len(symbols) == 0 {
+		return nil, nil, fmt.Errorf("%w: update would leave no symbols", protocol.ErrInvalidSubscription)
+	}
+	if maxSymbols := protocol.CurrentLimits().MaxSymbolsCount; len(symbols) > maxSymbols {
+		return nil, nil, fmt.Errorf("%w: subscription would exceed %d symbols", protocol.ErrInvalidSubscription, maxSymbols)
+	}
+	s.Symbols = symbols
+	s.symbolIndex = seen
+	return symbols, added, nil
+}
+
+// SendSubscriptionUpdated acknowledges a SUBSCRIPTION_UPDATE with the
+// resulting symbol set.
+func (c *Connection) SendSubscriptionUpdated(symbols []string) error {
+	ack := &pb.AckResponse{
+		AckType:     pb.MessageType_MESSAGE_TYPE_SUBSCRIPTION_UPDATE,
+		Success:     true,
+		Message:     "Subscription updated",
+		TimestampMs: time.Now().UnixMilli(),
+		Metadata:    map[string]string{"symbols": strings.Join(symbols, ",")},
+	}
+	return c.SendMessage(protocol.MessageTypeACK, ack)
+}
+
+// handleSubscriptionUpdate adds or removes symbols on the active
+// subscription without restarting it. A refused update leaves the
+// subscription and the connection as they were.
+func (h *ConnectionHandler) handleSubscriptionUpdate(frame *protocol.Frame) error {
+	var req pb.SubscriptionUpdateRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal subscription update: %w", err)
+	}
+
+	if err := protocol.ValidateSubscriptionUpdate(&req); err != nil {
+		if sendErr := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
+			"Invalid subscription update", fmt.Sprintf("Validation failed: %v", err)); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return nil
+	}
+
+	sub := h.conn.GetSubscription()
+	if sub == nil {
+		if err := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_NOT_SUBSCRIBED); err != nil {
+			h.logger.Error(errorSendFailedMsg, "error", err)
+		}
+		return nil
+	}
+
+	if err := h.checkTenantSymbols(req.AddSymbols); err != nil {
+		// The client was told which symbol was refused
+		return nil
+	}
+
+	symbols, added, err := sub.UpdateSymbols(req.AddSymbols, req.RemoveSymbols)
+	if err != nil {
+		h.logger.Info("subscription update refused", "error", err)
+		if sendErr := h.conn.SendErrorWithDetails(pb.ErrorCode_ERROR_CODE_INVALID_SUBSCRIPTION,
+			"Subscription update refused", err.Error()); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return nil
+	}
+
+	if err := h.conn.SendSubscriptionUpdated(symbols); err != nil {
+		return err
+	}
+	h.logger.Info("subscription updated",
+		"added", req.AddSymbols,
+		"removed", req.RemoveSymbols,
+		"symbols", len(symbols),
+	)
+
+	// Newly added symbols get their last value before live updates
+	if len(added) > 0 {
+		return h.sendSnapshot(sub.Mode, added)
+	}
+	return nil
+}
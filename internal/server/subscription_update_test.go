@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestSubscriptionUpdateSymbols(t *testing.T) {
+	sub := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	sub.setSymbols([]string{"EURUSD", "GBPUSD"})
+
+	symbols, added, err := sub.UpdateSymbols([]string{"USDJPY", "EURUSD"}, []string{"GBPUSD"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EURUSD", "USDJPY"}, symbols)
+	assert.Equal(t, []string{"USDJPY"}, added)
+	assert.True(t, sub.wantsSymbol("USDJPY"))
+	assert.False(t, sub.wantsSymbol("GBPUSD"))
+
+	_, _, err = sub.UpdateSymbols(nil, []string{"EURUSD", "USDJPY"})
+	assert.ErrorIs(t, err, protocol.ErrInvalidSubscription, "may not leave the subscription empty")
+	assert.Equal(t, []string{"EURUSD", "USDJPY"}, sub.CurrentSymbols())
+
+	// A subscription to everything can be narrowed but not reduced
+	all := NewSubscription(pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND)
+	assert.True(t, all.wantsSymbol("ANY"))
+	_, _, err = all.UpdateSymbols(nil, []string{"EURUSD"})
+	assert.ErrorIs(t, err, protocol.ErrInvalidSubscription)
+	symbols, added, err = all.UpdateSymbols([]string{"EURUSD"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EURUSD"}, symbols)
+	assert.Empty(t, added, "symbols were already streaming")
+	assert.False(t, all.wantsSymbol("ANY"))
+}
+
+func TestHandleSubscriptionUpdate(t *testing.T) {
+	config := DefaultConfig()
+	config.Clock = NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler, client, done := startHandle(t, ctx, config)
+	reader := protocol.NewFrameReader(client, config.MaxMessageSize)
+	client.SetDeadline(time.Now().Add(time.Second))
+
+	send := func(msgType protocol.MessageType, msg proto.Message) *protocol.Frame {
+		frame, err := protocol.MarshalMessage(msgType, msg)
+		require.NoError(t, err)
+		require.NoError(t, protocol.NewFrameWriter(client).WriteFrame(frame))
+		resp, err := reader.ReadFrame()
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Updates need a subscription
+	resp := send(protocol.MessageTypeSubscriptionUpdate, &pb.SubscriptionUpdateRequest{AddSymbols: []string{"EURUSD"}})
+	require.Equal(t, protocol.MessageTypeError, resp.Type)
+
+	resp = send(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{
+		Mode:    pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+		Symbols: []string{"EURUSD", "GBPUSD"},
+	})
+	require.Equal(t, protocol.MessageTypeACK, resp.Type)
+
+	resp = send(protocol.MessageTypeSubscriptionUpdate, &pb.SubscriptionUpdateRequest{
+		AddSymbols:    []string{"USDJPY"},
+		RemoveSymbols: []string{"EURUSD"},
+	})
+	require.Equal(t, protocol.MessageTypeACK, resp.Type)
+	var ack pb.AckResponse
+	require.NoError(t, proto.Unmarshal(resp.Payload, &ack))
+	assert.Equal(t, pb.MessageType_MESSAGE_TYPE_SUBSCRIPTION_UPDATE, ack.AckType)
+	assert.Equal(t, "GBPUSD,USDJPY", ack.Metadata["symbols"])
+
+	// Refused updates keep the connection and the subscription
+	resp = send(protocol.MessageTypeSubscriptionUpdate, &pb.SubscriptionUpdateRequest{RemoveSymbols: []string{"GBPUSD", "USDJPY"}})
+	require.Equal(t, protocol.MessageTypeError, resp.Type)
+	assert.Equal(t, []string{"GBPUSD", "USDJPY"}, handler.conn.GetSubscription().CurrentSymbols())
+
+	filtered := handler.filterTicksBySubscription([]*pb.Tick{
+		{Symbol: "EURUSD", Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND},
+		{Symbol: "USDJPY", Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND},
+	})
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "USDJPY", filtered[0].Symbol)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Room left in a SYMBOL_LIST payload for the fields besides the symbols,
+// and for each symbol's tag and length prefix.
+const (
+	symbolListOverhead      = 64
+	symbolListEntryOverhead = 8
+)
+
+// Symbols returns the cached symbols with the modes each has ticked in.
+func (c *LastValueCache) Symbols() []*pb.SymbolInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bySymbol := make(map[string]*pb.SymbolInfo)
+	for key := range c.ticks {
+		info, ok := bySymbol[key.symbol]
+		if !ok {
+			info = &pb.SymbolInfo{Symbol: key.symbol}
+			bySymbol[key.symbol] = info
+		}
+		info.Modes = append(info.Modes, key.mode)
+	}
+	symbols := make([]*pb.SymbolInfo, 0, len(bySymbol))
+	for _, info := range bySymbol {
+		sort.Slice(info.Modes, func(i, j int) bool { return info.Modes[i] < info.Modes[j] })
+		symbols = append(symbols, info)
+	}
+	return symbols
+}
+
+// symbolDirectory lists the symbols the server publishes, from the data
+// source when it is a SymbolDirectory and from the last-value cache otherwise.
+func (h *ConnectionHandler) symbolDirectory(ctx context.Context) ([]*pb.SymbolInfo, error) {
+	if dir, ok := h.config.dataSource().(SymbolDirectory); ok {
+		return dir.Symbols(ctx)
+	}
+	if h.server != nil && h.server.lastValues != nil {
+		return h.server.lastValues.Symbols(), nil
+	}
+	return nil, nil
+}
+
+// handleSymbolList answers a SYMBOL_LIST request with the matching symbols
+// the client's tenant may subscribe to, sorted by symbol.
+func (h *ConnectionHandler) handleSymbolList(ctx context.Context, frame *protocol.Frame) error {
+	var req pb.SymbolListRequest
+	if err := proto.Unmarshal(frame.Payload, &req); err != nil {
+		return fmt.Errorf("failed to unmarshal symbol list request: %w", err)
+	}
+
+	if err := protocol.ValidateSymbolListRequest(&req); err != nil {
+		return fmt.Errorf("symbol list validation failed: %w", err)
+	}
+
+	symbols, err := h.symbolDirectory(ctx)
+	if err != nil {
+		h.logger.Error("symbol directory unavailable", "error", err)
+		if sendErr := h.conn.SendErrorCode(pb.ErrorCode_ERROR_CODE_INTERNAL_ERROR); sendErr != nil {
+			h.logger.Error(errorSendFailedMsg, "error", sendErr)
+		}
+		return nil
+	}
+
+	tenant := h.conn.Tenant()
+	matched := make([]*pb.SymbolInfo, 0, len(symbols))
+	for _, info := range symbols {
+		if !strings.HasPrefix(info.Symbol, req.Prefix) || !symbolInModes(info, req.Modes) {
+			continue
+		}
+		if tenant != nil && !tenant.AllowsSymbol(info.Symbol) {
+			continue
+		}
+		matched = append(matched, info)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Symbol < matched[j].Symbol })
+
+	// Keep the response within one frame
+	resp := &pb.SymbolListResponse{TimestampMs: h.conn.now().UnixMilli()}
+	size := symbolListOverhead
+	for _, info := range matched {
+		size += proto.Size(info) + symbolListEntryOverhead
+		if size > protocol.DefaultMaxMessageSize {
+			resp.Truncated = true
+			break
+		}
+		resp.Symbols = append(resp.Symbols, info)
+	}
+
+	h.logger.Debug("symbol list request",
+		"prefix", req.Prefix,
+		"matched", len(matched),
+		"truncated", resp.Truncated,
+	)
+	return h.conn.SendMessage(protocol.MessageTypeSymbolList, resp)
+}
+
+// symbolInModes reports whether info is published in one of modes. An empty
+// filter matches every symbol, and a symbol without modes matches every filter.
+func symbolInModes(info *pb.SymbolInfo, modes []pb.SubscriptionMode) bool {
+	if len(modes) == 0 || len(info.Modes) == 0 {
+		return true
+	}
+	for _, want := range modes {
+		for _, mode := range info.Modes {
+			if mode == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	"github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// directorySource is a data source that describes its symbols.
+type directorySource struct {
+	syntheticSource
+	symbols []*pb.SymbolInfo
+}
+
+func (s directorySource) Symbols(context.Context) ([]*pb.SymbolInfo, error) {
+	return s.symbols, nil
+}
+
+// requestSymbolList sends req through handler and returns the response.
+func requestSymbolList(t *testing.T, config *Config, srv *Server, req *pb.SymbolListRequest) *pb.SymbolListResponse {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	handler := &ConnectionHandler{
+		conn:          conn,
+		config:        config,
+		authenticated: true,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		server:        srv,
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		clientSide.Close()
+	})
+
+	payload, err := proto.Marshal(req)
+	require.NoError(t, err)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewDispatcher().Dispatch(context.Background(), handler, &protocol.Frame{Type: protocol.MessageTypeSymbolList, Payload: payload})
+	}()
+
+	clientSide.SetReadDeadline(time.Now().Add(time.Second))
+	frame, err := protocol.NewFrameReader(clientSide, config.MaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeSymbolList, frame.Type)
+	require.NoError(t, <-errCh)
+
+	var resp pb.SymbolListResponse
+	require.NoError(t, proto.Unmarshal(frame.Payload, &resp))
+	return &resp
+}
+
+func TestSymbolListFromDataSource(t *testing.T) {
+	second := pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	minute := pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE
+	config := DefaultConfig()
+	config.DataSource = directorySource{symbols: []*pb.SymbolInfo{
+		{Symbol: "FX.GBPUSD", Description: "Pound / Dollar", TickSize: 0.0001, Modes: []pb.SubscriptionMode{minute}},
+		{Symbol: "FX.EURUSD", Description: "Euro / Dollar", TickSize: 0.0001, Modes: []pb.SubscriptionMode{second, minute}},
+		{Symbol: "EQ.ACME", Description: "Acme Corp", TickSize: 0.01, Modes: []pb.SubscriptionMode{second}},
+	}}
+
+	resp := requestSymbolList(t, config, nil, &pb.SymbolListRequest{})
+	require.Len(t, resp.Symbols, 3)
+	assert.Equal(t, "EQ.ACME", resp.Symbols[0].Symbol)
+	assert.Equal(t, "Euro / Dollar", resp.Symbols[1].Description)
+	assert.Equal(t, 0.0001, resp.Symbols[1].TickSize)
+	assert.False(t, resp.Truncated)
+
+	resp = requestSymbolList(t, config, nil, &pb.SymbolListRequest{Prefix: "FX.", Modes: []pb.SubscriptionMode{second}})
+	require.Len(t, resp.Symbols, 1)
+	assert.Equal(t, "FX.EURUSD", resp.Symbols[0].Symbol)
+}
+
+func TestSymbolListFallsBackToLastValues(t *testing.T) {
+	config := DefaultConfig()
+	srv := &Server{config: config, lastValues: NewLastValueCache(10)}
+	srv.lastValues.Update([]*pb.Tick{
+		{Symbol: "BTCUSD", TimestampMs: 1, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND},
+		{Symbol: "BTCUSD", TimestampMs: 1, Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE},
+	})
+
+	resp := requestSymbolList(t, config, srv, &pb.SymbolListRequest{})
+	require.Len(t, resp.Symbols, 1)
+	assert.Equal(t, "BTCUSD", resp.Symbols[0].Symbol)
+	assert.Equal(t, []pb.SubscriptionMode{pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND, pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE}, resp.Symbols[0].Modes)
+}
+
+func TestSymbolListTruncatesToOneFrame(t *testing.T) {
+	symbols := make([]*pb.SymbolInfo, 5000)
+	for i := range symbols {
+		symbols[i] = &pb.SymbolInfo{Symbol: fmt.Sprintf("SYM%05d", i), Description: "A symbol with a fairly long description"}
+	}
+	config := DefaultConfig()
+	config.DataSource = directorySource{symbols: symbols}
+
+	resp := requestSymbolList(t, config, nil, &pb.SymbolListRequest{})
+	assert.True(t, resp.Truncated)
+	assert.NotEmpty(t, resp.Symbols)
+	assert.LessOrEqual(t, proto.Size(resp), protocol.DefaultMaxMessageSize)
+}
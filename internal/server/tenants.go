@@ -0,0 +1,260 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultTenantName is the tenant for connections no tenant claims. Listing
+// a tenant with this name in TENANTS applies its limits to them.
+const DefaultTenantName = "default"
+
+var (
+	// ErrTenantQuota is returned when a tenant already has its maximum
+	// number of connections.
+	ErrTenantQuota = errors.New("tenant connection quota reached")
+
+	// ErrTenantRateLimited is returned when a tenant opens sessions faster
+	// than its connect rate allows.
+	ErrTenantRateLimited = errors.New("tenant connect rate exceeded")
+)
+
+// TenantConfig describes one customer sharing the deployment.
+type TenantConfig struct {
+	Name           string
+	Users          []string // Usernames belonging to the tenant
+	SNIHosts       []string // TLS server names belonging to the tenant; *.domain wildcards allowed
+	MaxConnections int      // Concurrent authenticated connections (0 disables)
+	SymbolPrefixes []string // Symbol namespaces the tenant may subscribe to; empty allows all
+	ConnectRate    float64  // New sessions per second (0 disables)
+	ConnectBurst   int
+	EgressRate     float64  // Bytes per second shared by the tenant's connections (0 disables)
+	EgressBurst    int
+}
+
+// parseTenantSpecs parses TENANTS entries separated by ';', each of the form
+// "name[,users=a|b][,sni=host|*.domain][,max_conns=N][,symbols=P|Q]
+// [,connect_rate=R][,connect_burst=N][,egress_rate=BYTES][,egress_burst=BYTES]".
+func parseTenantSpecs(spec string) ([]TenantConfig, error) {
+	var tenants []TenantConfig
+	for _, raw := range strings.Split(spec, ";") {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ",")
+		tc := TenantConfig{Name: strings.TrimSpace(parts[0])}
+		if tc.Name == "" || strings.Contains(tc.Name, "=") {
+			return nil, fmt.Errorf("invalid tenant %q: expected a name first", entry)
+		}
+
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+			switch key {
+			case "users":
+				tc.Users = strings.Split(value, "|")
+			case "sni":
+				for _, host := range strings.Split(value, "|") {
+					tc.SNIHosts = append(tc.SNIHosts, strings.ToLower(host))
+				}
+			case "symbols":
+				tc.SymbolPrefixes = strings.Split(value, "|")
+			case "max_conns":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid max_conns in tenant %q", tc.Name)
+				}
+				tc.MaxConnections = n
+			case "connect_rate":
+				rate, err := strconv.ParseFloat(value, 64)
+				if err != nil || rate < 0 {
+					return nil, fmt.Errorf("invalid connect_rate in tenant %q", tc.Name)
+				}
+				tc.ConnectRate = rate
+			case "connect_burst":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid connect_burst in tenant %q", tc.Name)
+				}
+				tc.ConnectBurst = n
+			case "egress_rate":
+				rate, err := strconv.ParseFloat(value, 64)
+				if err != nil || rate < 0 {
+					return nil, fmt.Errorf("invalid egress_rate in tenant %q", tc.Name)
+				}
+				tc.EgressRate = rate
+			case "egress_burst":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid egress_burst in tenant %q", tc.Name)
+				}
+				tc.EgressBurst = n
+			default:
+				return nil, fmt.Errorf("unknown option %q in tenant %q", key, tc.Name)
+			}
+		}
+		tenants = append(tenants, tc)
+	}
+	return tenants, nil
+}
+
+// Tenant enforces one tenant's quotas and tracks its usage.
+type Tenant struct {
+	name     string
+	maxConns int
+	symbols  []string
+	connects *AcceptLimiter // nil when the connect rate is unlimited
+	egress   *egressShaper  // Shared by the tenant's connections; nil when unlimited
+
+	active      int32
+	quotaDenied uint64
+	rateDenied  uint64
+}
+
+// Name returns the tenant's name, used as its metrics label.
+func (t *Tenant) Name() string {
+	return t.name
+}
+
+// Acquire admits a new session against the tenant's connect rate and
+// connection quota. Each successful Acquire must be paired with Release.
+func (t *Tenant) Acquire() error {
+	for {
+		active := atomic.LoadInt32(&t.active)
+		if t.maxConns > 0 && int(active) >= t.maxConns {
+			atomic.AddUint64(&t.quotaDenied, 1)
+			return ErrTenantQuota
+		}
+		if atomic.CompareAndSwapInt32(&t.active, active, active+1) {
+			break
+		}
+	}
+	if !t.connects.Allow() {
+		atomic.AddInt32(&t.active, -1)
+		atomic.AddUint64(&t.rateDenied, 1)
+		return ErrTenantRateLimited
+	}
+	return nil
+}
+
+// Release frees a session admitted by Acquire.
+func (t *Tenant) Release() {
+	atomic.AddInt32(&t.active, -1)
+}
+
+// AllowsSymbol reports whether symbol is inside one of the tenant's namespaces.
+func (t *Tenant) AllowsSymbol(symbol string) bool {
+	if len(t.symbols) == 0 {
+		return true
+	}
+	for _, prefix := range t.symbols {
+		if strings.HasPrefix(symbol, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// egressRate returns the tenant's egress rate in bytes per second, or 0.
+func (t *Tenant) egressRate() float64 {
+	if t.egress == nil {
+		return 0
+	}
+	return t.egress.rate
+}
+
+// GetStats returns the tenant's usage.
+func (t *Tenant) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"active_connections": atomic.LoadInt32(&t.active),
+		"max_connections":    t.maxConns,
+		"symbol_prefixes":    t.symbols,
+		"egress_rate_bytes":  t.egressRate(),
+		"quota_denied_total": atomic.LoadUint64(&t.quotaDenied),
+		"rate_denied_total":  atomic.LoadUint64(&t.rateDenied),
+	}
+}
+
+// TenantRegistry maps authenticated connections to tenants.
+type TenantRegistry struct {
+	tenants map[string]*Tenant
+	byUser  map[string]*Tenant
+	bySNI   map[string]*Tenant
+	hosts   map[string]bool // Keys of bySNI, for matchSNIHost
+}
+
+// NewTenantRegistry builds tenants from configs. A default tenant without
+// limits is added unless one is configured.
+func NewTenantRegistry(configs []TenantConfig, clock Clock) (*TenantRegistry, error) {
+	r := &TenantRegistry{
+		tenants: make(map[string]*Tenant, len(configs)+1),
+		byUser:  make(map[string]*Tenant),
+		bySNI:   make(map[string]*Tenant),
+		hosts:   make(map[string]bool),
+	}
+	for _, tc := range configs {
+		if _, dup := r.tenants[tc.Name]; dup {
+			return nil, fmt.Errorf("duplicate tenant %q", tc.Name)
+		}
+		t := &Tenant{name: tc.Name, maxConns: tc.MaxConnections, symbols: tc.SymbolPrefixes}
+		if tc.ConnectRate > 0 {
+			t.connects = NewAcceptLimiter(tc.ConnectRate, tc.ConnectBurst, clock)
+		}
+		t.egress = newEgressShaper(tc.EgressRate, tc.EgressBurst, clock)
+		r.tenants[tc.Name] = t
+
+		for _, user := range tc.Users {
+			if other, dup := r.byUser[user]; dup {
+				return nil, fmt.Errorf("user %q belongs to tenants %q and %q", user, other.name, tc.Name)
+			}
+			r.byUser[user] = t
+		}
+		for _, host := range tc.SNIHosts {
+			if other, dup := r.bySNI[host]; dup {
+				return nil, fmt.Errorf("SNI host %q belongs to tenants %q and %q", host, other.name, tc.Name)
+			}
+			r.bySNI[host] = t
+			r.hosts[host] = true
+		}
+	}
+	if _, ok := r.tenants[DefaultTenantName]; !ok {
+		r.tenants[DefaultTenantName] = &Tenant{name: DefaultTenantName}
+	}
+	return r, nil
+}
+
+// Resolve returns the tenant for a session: by username first, then by the
+// TLS server name the client connected to, else the default tenant.
+func (r *TenantRegistry) Resolve(username, serverName string) *Tenant {
+	if t, ok := r.byUser[username]; ok {
+		return t
+	}
+	if t, ok := r.bySNI[matchSNIHost(r.hosts, serverName)]; ok {
+		return t
+	}
+	return r.tenants[DefaultTenantName]
+}
+
+// Tenants returns every tenant sorted by name.
+func (r *TenantRegistry) Tenants() []*Tenant {
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].name < tenants[j].name })
+	return tenants
+}
+
+// GetStats returns per-tenant usage for Server.GetStats.
+func (r *TenantRegistry) GetStats() map[string]interface{} {
+	stats := make(map[string]interface{}, len(r.tenants))
+	for name, t := range r.tenants {
+		stats[name] = t.GetStats()
+	}
+	return stats
+}
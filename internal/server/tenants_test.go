@@ -0,0 +1,118 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+func TestParseTenantSpecs(t *testing.T) {
+	tenants, err := parseTenantSpecs(
+		"acme,users=alice|bob,sni=acme.example.com|*.ACME.io,max_conns=100,symbols=ACME.|FX.; globex,connect_rate=2.5,connect_burst=5; initech,egress_rate=1e6,egress_burst=65536")
+	require.NoError(t, err)
+	require.Len(t, tenants, 3)
+
+	assert.Equal(t, TenantConfig{
+		Name:           "acme",
+		Users:          []string{"alice", "bob"},
+		SNIHosts:       []string{"acme.example.com", "*.acme.io"},
+		MaxConnections: 100,
+		SymbolPrefixes: []string{"ACME.", "FX."},
+	}, tenants[0])
+	assert.Equal(t, TenantConfig{Name: "globex", ConnectRate: 2.5, ConnectBurst: 5}, tenants[1])
+	assert.Equal(t, TenantConfig{Name: "initech", EgressRate: 1e6, EgressBurst: 65536}, tenants[2])
+
+	for _, bad := range []string{"users=alice", "x,max_conns=-1", "x,connect_rate=fast", "x,quota=1", "x,egress_rate=-1"} {
+		_, err := parseTenantSpecs(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestTenantRegistryResolve(t *testing.T) {
+	r, err := NewTenantRegistry([]TenantConfig{
+		{Name: "acme", Users: []string{"alice"}, SNIHosts: []string{"*.acme.io"}},
+		{Name: "globex", SNIHosts: []string{"globex.example.com"}},
+	}, NewFakeClock(time.Unix(0, 0)))
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", r.Resolve("alice", "globex.example.com").Name(), "username wins over SNI")
+	assert.Equal(t, "acme", r.Resolve("carol", "eu.acme.io").Name())
+	assert.Equal(t, "globex", r.Resolve("carol", "globex.example.com").Name())
+	assert.Equal(t, DefaultTenantName, r.Resolve("carol", "").Name())
+	assert.Len(t, r.Tenants(), 3)
+
+	_, err = NewTenantRegistry([]TenantConfig{{Name: "a", Users: []string{"x"}}, {Name: "b", Users: []string{"x"}}}, RealClock())
+	assert.Error(t, err, "a user belongs to one tenant")
+	_, err = NewTenantRegistry([]TenantConfig{{Name: "a"}, {Name: "a"}}, RealClock())
+	assert.Error(t, err)
+}
+
+func TestTenantQuotaAndRate(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	r, err := NewTenantRegistry([]TenantConfig{
+		{Name: "small", Users: []string{"s"}, MaxConnections: 2},
+		{Name: "slow", Users: []string{"r"}, ConnectRate: 1, ConnectBurst: 1},
+	}, clock)
+	require.NoError(t, err)
+
+	small := r.Resolve("s", "")
+	require.NoError(t, small.Acquire())
+	require.NoError(t, small.Acquire())
+	assert.ErrorIs(t, small.Acquire(), ErrTenantQuota)
+	small.Release()
+	assert.NoError(t, small.Acquire(), "released slots are reusable")
+
+	slow := r.Resolve("r", "")
+	require.NoError(t, slow.Acquire())
+	assert.ErrorIs(t, slow.Acquire(), ErrTenantRateLimited)
+	clock.Advance(time.Second)
+	assert.NoError(t, slow.Acquire())
+
+	stats := r.GetStats()
+	assert.Equal(t, uint64(1), stats["small"].(map[string]interface{})["quota_denied_total"])
+	assert.Equal(t, int32(2), stats["slow"].(map[string]interface{})["active_connections"], "rate-limited attempts hold no slot")
+}
+
+func TestTenantAllowsSymbol(t *testing.T) {
+	tenant := &Tenant{name: "acme", symbols: []string{"ACME.", "FX."}}
+	assert.True(t, tenant.AllowsSymbol("ACME.WIDGET"))
+	assert.True(t, tenant.AllowsSymbol("FX.EURUSD"))
+	assert.False(t, tenant.AllowsSymbol("GLOBEX.GIZMO"))
+	assert.True(t, (&Tenant{name: DefaultTenantName}).AllowsSymbol("ANY"))
+}
+
+func TestAdmitTenantRefusesOverQuota(t *testing.T) {
+	config := DefaultConfig()
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+	conn := NewConnection(serverSide, config)
+	defer conn.Close()
+
+	srv := &Server{
+		config:            config,
+		logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+		prometheusMetrics: NewPrometheusMetricsWithRegistry(prometheus.NewRegistry()),
+	}
+	tenant := &Tenant{name: "full", maxConns: 1, active: 1}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.admitTenant(conn, tenant) }()
+	frame, err := protocol.NewFrameReader(clientSide, protocol.DefaultMaxMessageSize).ReadFrame()
+	require.NoError(t, err)
+	var resp pb.ErrorResponse
+	require.NoError(t, protocol.UnmarshalMessage(frame, &resp))
+	assert.Equal(t, pb.ErrorCode_ERROR_CODE_QUOTA_EXCEEDED, resp.Code)
+	assert.Positive(t, resp.RetryAfterMs)
+
+	assert.ErrorIs(t, <-done, ErrTenantQuota)
+	assert.Nil(t, conn.Tenant())
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// timerWheelSlots is the number of buckets in a TimerWheel. Timers further
+// out than one revolution wait in their bucket for the extra rounds.
+const timerWheelSlots = 512
+
+// TimerWheel is a hashed timer wheel for the per-connection timeouts that are
+// almost always reset or stopped before they fire: heartbeat and connection
+// stage deadlines. One clock ticker drives every timer, so arming, resetting
+// and stopping them is a map operation under one lock instead of a runtime
+// timer each. Timers fire up to one tick late and never early.
+type TimerWheel struct {
+	clock Clock
+	tick  time.Duration
+
+	mu     sync.Mutex
+	slots  []map[*wheelTimer]struct{}
+	cursor int       // Bucket of the last processed tick
+	now    time.Time // End of the last processed tick
+	armed  int
+	fired  uint64
+}
+
+// NewTimerWheel creates a wheel with tick resolution. Start drives it.
+func NewTimerWheel(clock Clock, tick time.Duration) *TimerWheel {
+	w := &TimerWheel{
+		clock: clock,
+		tick:  tick,
+		slots: make([]map[*wheelTimer]struct{}, timerWheelSlots),
+		now:   clock.Now(),
+	}
+	for i := range w.slots {
+		w.slots[i] = make(map[*wheelTimer]struct{})
+	}
+	return w
+}
+
+// Start advances the wheel every tick until ctx is cancelled. Ticks the
+// goroutine misses are caught up on the next one.
+func (w *TimerWheel) Start(ctx context.Context) {
+	go func() {
+		ticker := w.clock.NewTicker(w.tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				w.advance(w.clock.Now())
+			}
+		}
+	}()
+}
+
+// AfterFunc runs f on its own goroutine once d has elapsed.
+func (w *TimerWheel) AfterFunc(d time.Duration, f func()) Timer {
+	t := &wheelTimer{wheel: w, fn: f}
+	w.mu.Lock()
+	w.schedule(t, d)
+	w.mu.Unlock()
+	return t
+}
+
+// Clock returns clock with AfterFunc served by the wheel.
+func (w *TimerWheel) Clock(clock Clock) Clock {
+	return wheelClock{Clock: clock, wheel: w}
+}
+
+// schedule places t in the bucket of the first tick ending at least d from
+// now; the caller holds w.mu.
+func (w *TimerWheel) schedule(t *wheelTimer, d time.Duration) {
+	when := w.clock.Now().Add(d)
+	ticks := int((when.Sub(w.now) + w.tick - 1) / w.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+	t.slot = (w.cursor + ticks) % len(w.slots)
+	t.rounds = (ticks - 1) / len(w.slots)
+	t.active = true
+	w.slots[t.slot][t] = struct{}{}
+	w.armed++
+}
+
+// unschedule removes t; the caller holds w.mu.
+func (w *TimerWheel) unschedule(t *wheelTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	delete(w.slots[t.slot], t)
+	w.armed--
+	return true
+}
+
+// advance processes every tick that has ended by now and fires due timers.
+func (w *TimerWheel) advance(now time.Time) {
+	var due []func()
+	w.mu.Lock()
+	for !w.now.Add(w.tick).After(now) {
+		w.cursor = (w.cursor + 1) % len(w.slots)
+		w.now = w.now.Add(w.tick)
+		for t := range w.slots[w.cursor] {
+			if t.rounds > 0 {
+				t.rounds--
+				continue
+			}
+			w.unschedule(t)
+			due = append(due, t.fn)
+		}
+	}
+	w.fired += uint64(len(due))
+	w.mu.Unlock()
+
+	for _, f := range due {
+		go f()
+	}
+}
+
+// GetStats returns the wheel's state for Server.GetStats.
+func (w *TimerWheel) GetStats() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return map[string]interface{}{
+		"tick_ms": w.tick.Milliseconds(),
+		"slots":   len(w.slots),
+		"armed":   w.armed,
+		"fired":   w.fired,
+	}
+}
+
+// wheelTimer is an AfterFunc timer on a TimerWheel.
+type wheelTimer struct {
+	wheel  *TimerWheel
+	fn     func()
+	slot   int
+	rounds int
+	active bool
+}
+
+func (t *wheelTimer) C() <-chan time.Time { return nil }
+
+func (t *wheelTimer) Stop() bool {
+	t.wheel.mu.Lock()
+	defer t.wheel.mu.Unlock()
+	return t.wheel.unschedule(t)
+}
+
+func (t *wheelTimer) Reset(d time.Duration) bool {
+	t.wheel.mu.Lock()
+	defer t.wheel.mu.Unlock()
+	wasActive := t.wheel.unschedule(t)
+	t.wheel.schedule(t, d)
+	return wasActive
+}
+
+// wheelClock is a Clock whose AfterFunc timers live on a TimerWheel.
+type wheelClock struct {
+	Clock
+	wheel *TimerWheel
+}
+
+func (c wheelClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.wheel.AfterFunc(d, f)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimerWheelFiresAfterDelay(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	wheel := NewTimerWheel(clock, 100*time.Millisecond)
+
+	var fired atomic.Int32
+	wheel.AfterFunc(250*time.Millisecond, func() { fired.Add(1) })
+
+	wheel.advance(clock.Now().Add(200 * time.Millisecond))
+	assert.Zero(t, fired.Load(), "never early")
+
+	wheel.advance(clock.Now().Add(300 * time.Millisecond))
+	require.Eventually(t, func() bool { return fired.Load() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, wheel.GetStats()["armed"])
+}
+
+func TestTimerWheelSpansRevolutions(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	wheel := NewTimerWheel(clock, time.Millisecond)
+
+	var fired atomic.Int32
+	d := 3*timerWheelSlots*time.Millisecond + 5*time.Millisecond
+	wheel.AfterFunc(d, func() { fired.Add(1) })
+
+	wheel.advance(clock.Now().Add(d - time.Millisecond))
+	assert.Zero(t, fired.Load())
+	wheel.advance(clock.Now().Add(d))
+	require.Eventually(t, func() bool { return fired.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestTimerWheelStopAndReset(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	wheel := NewTimerWheel(clock, 100*time.Millisecond)
+
+	var fired atomic.Int32
+	stopped := wheel.AfterFunc(time.Second, func() { fired.Add(1) })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	reset := wheel.AfterFunc(time.Second, func() { fired.Add(1) })
+	clock.Advance(900 * time.Millisecond)
+	wheel.advance(clock.Now())
+	assert.True(t, reset.Reset(time.Second))
+
+	clock.Advance(900 * time.Millisecond)
+	wheel.advance(clock.Now())
+	assert.Zero(t, fired.Load(), "the reset pushed the deadline back")
+
+	clock.Advance(100 * time.Millisecond)
+	wheel.advance(clock.Now())
+	require.Eventually(t, func() bool { return fired.Load() == 1 }, time.Second, time.Millisecond)
+	assert.False(t, reset.Stop())
+}
+
+func TestHeartbeatMonitorOnTimerWheel(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	wheel := NewTimerWheel(clock, 100*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wheel.Start(ctx)
+	waitForTimers(t, clock, 1)
+
+	monitor := NewHeartbeatMonitor(wheel.Clock(clock), time.Second, 2*time.Second, nil)
+	monitor.Start()
+	defer monitor.Stop()
+	clock.mu.Lock()
+	assert.Len(t, clock.waiters, 1, "the monitor adds no runtime timer")
+	clock.mu.Unlock()
+
+	clock.Advance(1500 * time.Millisecond)
+	monitor.Beat()
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case <-monitor.Expired():
+		t.Fatal("expired despite the heartbeat")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case <-monitor.Expired():
+	case <-time.After(time.Second):
+		t.Fatal("did not expire")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,6 +20,9 @@ type TLSConfig struct {
 	KeyFile         string
 	CAFile          string
 	
+	// Additional certificates selected by the client's SNI server name
+	SNICertificates []SNICertificate
+	
 	// mTLS settings
 	ClientAuth      tls.ClientAuthType
 	ClientCAFile    string
@@ -25,6 +30,7 @@ type TLSConfig struct {
 	// Security settings
 	MinVersion      uint16
 	MaxVersion      uint16
+	AllowTLS12      bool // Permit MinVersion TLS 1.2 for legacy clients, with TLS12CipherSuites only
 	CipherSuites    []uint16
 	CurvePreferences []tls.CurveID
 	
@@ -35,6 +41,34 @@ type TLSConfig struct {
 	// Certificate rotation
 	CertWatchEnabled bool
 	CertCheckInterval time.Duration
+	
+	// Handshake limits: clients that do not finish within HandshakeTimeout are
+	// dropped, and handshakes beyond MaxConcurrentHandshakes are refused (0 disables)
+	HandshakeTimeout        time.Duration
+	MaxConcurrentHandshakes int
+	
+	// KeyLogFile, when set, receives session secrets in NSS key log format so
+	// captured traffic can be decrypted. For authorized debugging only.
+	KeyLogFile string
+	
+	// Fingerprint records each client's ClientHello and computes its JA3
+	// fingerprint, reported in connection stats
+	Fingerprint bool
+	
+	// GetCertificate, when set, supplies the server certificate per handshake
+	// instead of CertFile/KeyFile, so it can be rotated in place
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// TLS12CipherSuites are the only suites offered to TLS 1.2 clients when
+// AllowTLS12 is set: ECDHE key exchange with AEAD ciphers.
+var TLS12CipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
 }
 
 // DefaultTLSConfig returns secure default TLS configuration
@@ -63,6 +97,8 @@ func DefaultTLSConfig() *TLSConfig {
 		InsecureSkipVerify: false,
 		CertWatchEnabled:  false,
 		CertCheckInterval: 5 * time.Minute,
+		HandshakeTimeout:  10 * time.Second,
+		MaxConcurrentHandshakes: 256,
 	}
 	
 	return cfg
@@ -82,6 +118,14 @@ func LoadTLSConfigFromEnv(cfg *TLSConfig) {
 		cfg.KeyFile = keyFile
 	}
 	
+	if sniCerts := os.Getenv("TLS_SNI_CERTS"); sniCerts != "" {
+		if certs, err := parseSNICertificates(strings.Split(sniCerts, ",")); err == nil {
+			cfg.SNICertificates = certs
+		} else {
+			slog.Warn("ignoring invalid TLS_SNI_CERTS", "error", err)
+		}
+	}
+	
 	if caFile := os.Getenv("TLS_CA_FILE"); caFile != "" {
 		cfg.CAFile = caFile
 	}
@@ -106,6 +150,19 @@ func LoadTLSConfigFromEnv(cfg *TLSConfig) {
 		}
 	}
 	
+	if minVersion := os.Getenv("TLS_MIN_VERSION"); minVersion != "" {
+		switch minVersion {
+		case "1.3":
+			cfg.MinVersion = tls.VersionTLS13
+			cfg.AllowTLS12 = false
+		case "1.2":
+			cfg.MinVersion = tls.VersionTLS12
+			cfg.AllowTLS12 = true
+		default:
+			slog.Warn("ignoring invalid TLS_MIN_VERSION", "value", minVersion)
+		}
+	}
+	
 	if ocsp := os.Getenv("TLS_OCSP_ENABLED"); ocsp != "" {
 		cfg.OCSPEnabled = strings.ToLower(ocsp) == "true"
 	}
@@ -123,6 +180,26 @@ func LoadTLSConfigFromEnv(cfg *TLSConfig) {
 			cfg.CertCheckInterval = d
 		}
 	}
+	
+	if timeout := os.Getenv("TLS_HANDSHAKE_TIMEOUT"); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err == nil && d >= 0 {
+			cfg.HandshakeTimeout = d
+		}
+	}
+	
+	if max := os.Getenv("TLS_MAX_CONCURRENT_HANDSHAKES"); max != "" {
+		if n, err := strconv.Atoi(max); err == nil && n >= 0 {
+			cfg.MaxConcurrentHandshakes = n
+		}
+	}
+	
+	if keyLog := os.Getenv("TLS_KEY_LOG_FILE"); keyLog != "" {
+		cfg.KeyLogFile = keyLog
+	}
+	
+	if fingerprint := os.Getenv("TLS_FINGERPRINT"); fingerprint != "" {
+		cfg.Fingerprint = strings.ToLower(fingerprint) == "true"
+	}
 }
 
 // BuildTLSConfig creates a *tls.Config from TLSConfig
@@ -131,19 +208,7 @@ func (cfg *TLSConfig) BuildTLSConfig() (*tls.Config, error) {
 		return nil, nil
 	}
 	
-	// Validate required files
-	if cfg.CertFile == "" || cfg.KeyFile == "" {
-		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be specified when TLS is enabled")
-	}
-	
-	// Load server certificate
-	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load server certificate: %w", err)
-	}
-	
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
 		MinVersion:   cfg.MinVersion,
 		MaxVersion:   cfg.MaxVersion,
 		CipherSuites: cfg.CipherSuites,
@@ -152,6 +217,41 @@ func (cfg *TLSConfig) BuildTLSConfig() (*tls.Config, error) {
 		InsecureSkipVerify: cfg.InsecureSkipVerify,
 	}
 	
+	// TLS 1.2 clients only get the ECDHE+AEAD whitelist; TLS 1.3 suites are not configurable
+	if cfg.MinVersion < tls.VersionTLS13 {
+		tlsConfig.CipherSuites = TLS12CipherSuites
+		slog.Warn("TLS 1.2 compatibility mode enabled; clients may negotiate TLS 1.2",
+			"min_version", cfg.getTLSVersionString(cfg.MinVersion),
+			"cipher_suites", len(TLS12CipherSuites),
+		)
+	}
+	
+	if cfg.GetCertificate != nil {
+		tlsConfig.GetCertificate = cfg.GetCertificate
+	} else {
+		// Validate required files
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be specified when TLS is enabled")
+		}
+		
+		// Load server certificate
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	
+	// Serve tenant certificates by SNI ahead of the default one
+	if len(cfg.SNICertificates) > 0 {
+		sni, err := loadSNICertificates(cfg.SNICertificates)
+		if err != nil {
+			return nil, err
+		}
+		sni.fallback = tlsConfig.GetCertificate
+		tlsConfig.GetCertificate = sni.GetCertificate
+	}
+	
 	// Configure client certificate validation for mTLS
 	if cfg.ClientAuth != tls.NoClientCert {
 		if err := cfg.setupClientCertValidation(tlsConfig); err != nil {
@@ -164,6 +264,17 @@ func (cfg *TLSConfig) BuildTLSConfig() (*tls.Config, error) {
 		tlsConfig.VerifyConnection = cfg.verifyConnectionWithOCSP
 	}
 	
+	// Write session secrets for decrypting packet captures
+	if cfg.KeyLogFile != "" {
+		keyLog, err := os.OpenFile(cfg.KeyLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open TLS key log file: %w", err)
+		}
+		tlsConfig.KeyLogWriter = keyLog
+		slog.Warn("TLS key logging enabled; captured traffic can be decrypted with this file",
+			"file", cfg.KeyLogFile)
+	}
+	
 	return tlsConfig, nil
 }
 
@@ -258,14 +369,22 @@ func (cfg *TLSConfig) ValidateTLSConfig() error {
 		}
 	}
 	
+	for _, c := range cfg.SNICertificates {
+		for _, file := range []string{c.CertFile, c.KeyFile} {
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				return fmt.Errorf("TLS SNI file for %s does not exist: %s", c.Host, file)
+			}
+		}
+	}
+	
 	// Validate TLS version settings
 	if cfg.MinVersion > cfg.MaxVersion {
 		return fmt.Errorf("TLS min version cannot be greater than max version")
 	}
 	
-	// Ensure TLS 1.3 is used for security
-	if cfg.MinVersion < tls.VersionTLS13 {
-		return fmt.Errorf("minimum TLS version must be 1.3 for security compliance")
+	// Ensure TLS 1.3 is used for security unless TLS 1.2 was explicitly allowed
+	if cfg.MinVersion < tls.VersionTLS13 && !(cfg.AllowTLS12 && cfg.MinVersion == tls.VersionTLS12) {
+		return fmt.Errorf("minimum TLS version must be 1.3 for security compliance (set TLS_MIN_VERSION=1.2 to allow TLS 1.2)")
 	}
 	
 	return nil
@@ -280,6 +399,8 @@ func (cfg *TLSConfig) GetTLSInfo() map[string]interface{} {
 		"client_auth": cfg.getClientAuthString(cfg.ClientAuth),
 		"ocsp_enabled": cfg.OCSPEnabled,
 		"cert_watch_enabled": cfg.CertWatchEnabled,
+		"key_log_enabled": cfg.KeyLogFile != "",
+		"fingerprint_enabled": cfg.Fingerprint,
 	}
 	
 	if cfg.Enabled {
@@ -288,6 +409,13 @@ func (cfg *TLSConfig) GetTLSInfo() map[string]interface{} {
 		if cfg.ClientCAFile != "" {
 			info["client_ca_file"] = cfg.ClientCAFile
 		}
+		if len(cfg.SNICertificates) > 0 {
+			hosts := make([]string, 0, len(cfg.SNICertificates))
+			for _, c := range cfg.SNICertificates {
+				hosts = append(hosts, c.Host)
+			}
+			info["sni_hosts"] = hosts
+		}
 	}
 	
 	return info
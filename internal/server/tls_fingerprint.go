@@ -0,0 +1,207 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// maxClientHelloBytes bounds how much of a connection is recorded while
+// waiting for its ClientHello.
+const maxClientHelloBytes = 64 << 10
+
+// TLS record, handshake and extension codes needed to parse a ClientHello.
+const (
+	recordTypeHandshake      = 22
+	handshakeTypeClientHello = 1
+	extensionSupportedGroups = 10
+	extensionECPointFormats  = 11
+)
+
+// ErrInvalidClientHello is returned when the recorded bytes do not hold a
+// complete ClientHello.
+var ErrInvalidClientHello = errors.New("invalid or incomplete TLS ClientHello")
+
+// TLSFingerprint identifies a client's TLS stack from its ClientHello.
+type TLSFingerprint struct {
+	JA3     string // Version,Ciphers,Extensions,Groups,PointFormats
+	JA3Hash string // MD5 of JA3, as reported by most tooling
+}
+
+// fingerprintListener wraps accepted connections in TLS like tls.NewListener,
+// recording each ClientHello so it can be fingerprinted after the handshake.
+type fingerprintListener struct {
+	net.Listener
+	config *tls.Config
+}
+
+func newFingerprintListener(inner net.Listener, config *tls.Config) net.Listener {
+	return &fingerprintListener{Listener: inner, config: config}
+}
+
+func (l *fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Server(&helloRecorder{Conn: conn}, l.config), nil
+}
+
+// helloRecorder keeps a copy of the bytes read from the client until the
+// handshake finishes.
+type helloRecorder struct {
+	net.Conn
+	buf  []byte
+	done bool
+}
+
+func (r *helloRecorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	if !r.done && n > 0 && len(r.buf) < maxClientHelloBytes {
+		r.buf = append(r.buf, p[:min(n, maxClientHelloBytes-len(r.buf))]...)
+	}
+	return n, err
+}
+
+// Fingerprint stops recording and fingerprints the recorded ClientHello. It
+// must be called from the goroutine that ran the handshake.
+func (r *helloRecorder) Fingerprint() (TLSFingerprint, error) {
+	data := r.buf
+	r.buf, r.done = nil, true
+	return fingerprintClientHello(data)
+}
+
+// tlsFingerprint returns the fingerprint of a handshaken connection accepted
+// through a fingerprintListener.
+func tlsFingerprint(conn *tls.Conn) (TLSFingerprint, bool) {
+	rec, ok := conn.NetConn().(*helloRecorder)
+	if !ok {
+		return TLSFingerprint{}, false
+	}
+	fp, err := rec.Fingerprint()
+	return fp, err == nil
+}
+
+// fingerprintClientHello computes the JA3 fingerprint of the ClientHello
+// carried by the TLS records in data. GREASE values are ignored.
+func fingerprintClientHello(data []byte) (TLSFingerprint, error) {
+	msg, err := clientHelloMessage(data)
+	if err != nil {
+		return TLSFingerprint{}, err
+	}
+
+	var (
+		version                               uint16
+		sessionID, ciphers, compression, exts cryptobyte.String
+	)
+	s := cryptobyte.String(msg)
+	if !s.ReadUint16(&version) ||
+		!s.Skip(32) || // Random
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!s.ReadUint16LengthPrefixed(&ciphers) ||
+		!s.ReadUint8LengthPrefixed(&compression) {
+		return TLSFingerprint{}, ErrInvalidClientHello
+	}
+	if !s.Empty() && !s.ReadUint16LengthPrefixed(&exts) {
+		return TLSFingerprint{}, ErrInvalidClientHello
+	}
+
+	var cipherIDs, extIDs, groups, points []string
+	for !ciphers.Empty() {
+		var id uint16
+		if !ciphers.ReadUint16(&id) {
+			return TLSFingerprint{}, ErrInvalidClientHello
+		}
+		if !isGREASE(id) {
+			cipherIDs = append(cipherIDs, strconv.Itoa(int(id)))
+		}
+	}
+	for !exts.Empty() {
+		var id uint16
+		var body cryptobyte.String
+		if !exts.ReadUint16(&id) || !exts.ReadUint16LengthPrefixed(&body) {
+			return TLSFingerprint{}, ErrInvalidClientHello
+		}
+		if isGREASE(id) {
+			continue
+		}
+		extIDs = append(extIDs, strconv.Itoa(int(id)))
+
+		switch id {
+		case extensionSupportedGroups:
+			var list cryptobyte.String
+			if !body.ReadUint16LengthPrefixed(&list) {
+				return TLSFingerprint{}, ErrInvalidClientHello
+			}
+			for !list.Empty() {
+				var group uint16
+				if !list.ReadUint16(&group) {
+					return TLSFingerprint{}, ErrInvalidClientHello
+				}
+				if !isGREASE(group) {
+					groups = append(groups, strconv.Itoa(int(group)))
+				}
+			}
+		case extensionECPointFormats:
+			var list cryptobyte.String
+			if !body.ReadUint8LengthPrefixed(&list) {
+				return TLSFingerprint{}, ErrInvalidClientHello
+			}
+			for _, format := range list {
+				points = append(points, strconv.Itoa(int(format)))
+			}
+		}
+	}
+
+	ja3 := strings.Join([]string{
+		strconv.Itoa(int(version)),
+		strings.Join(cipherIDs, "-"),
+		strings.Join(extIDs, "-"),
+		strings.Join(groups, "-"),
+		strings.Join(points, "-"),
+	}, ",")
+	sum := md5.Sum([]byte(ja3))
+	return TLSFingerprint{JA3: ja3, JA3Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+// clientHelloMessage reassembles the ClientHello body, which may span
+// several handshake records, from the start of a TLS stream.
+func clientHelloMessage(data []byte) ([]byte, error) {
+	var msg []byte
+	s := cryptobyte.String(data)
+	for !s.Empty() {
+		var recordType uint8
+		var legacyVersion uint16
+		var fragment cryptobyte.String
+		if !s.ReadUint8(&recordType) || !s.ReadUint16(&legacyVersion) || !s.ReadUint16LengthPrefixed(&fragment) {
+			break
+		}
+		if recordType != recordTypeHandshake {
+			return nil, ErrInvalidClientHello
+		}
+		msg = append(msg, fragment...)
+
+		if len(msg) >= 4 {
+			if msg[0] != handshakeTypeClientHello {
+				return nil, ErrInvalidClientHello
+			}
+			length := 4 + (int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3]))
+			if len(msg) >= length {
+				return msg[4:length], nil
+			}
+		}
+	}
+	return nil, ErrInvalidClientHello
+}
+
+// isGREASE reports whether v is a GREASE value (RFC 8701), which clients
+// randomize and fingerprints must skip.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// clientHelloRecords builds a ClientHello split across two handshake records.
+func clientHelloRecords(t *testing.T) []byte {
+	t.Helper()
+	var body cryptobyte.Builder
+	body.AddUint16(0x0303)
+	body.AddBytes(make([]byte, 32)) // Random
+	body.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {})
+	body.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(0x0a0a) // GREASE
+		b.AddUint16(0x1301)
+		b.AddUint16(0x1302)
+	})
+	body.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+	body.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(0x1a1a) // GREASE extension
+		b.AddUint16(0)
+		b.AddUint16(extensionSupportedGroups)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16(0x2a2a)
+				b.AddUint16(29)
+				b.AddUint16(23)
+			})
+		})
+		b.AddUint16(extensionECPointFormats)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+		})
+		b.AddUint16(43)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint16(0x0304) })
+		})
+	})
+
+	var msg cryptobyte.Builder
+	msg.AddUint8(handshakeTypeClientHello)
+	msg.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(body.BytesOrPanic()) })
+	hello := msg.BytesOrPanic()
+
+	var records cryptobyte.Builder
+	for _, fragment := range [][]byte{hello[:10], hello[10:]} {
+		records.AddUint8(recordTypeHandshake)
+		records.AddUint16(0x0301)
+		records.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(fragment) })
+	}
+	return records.BytesOrPanic()
+}
+
+func TestFingerprintClientHello(t *testing.T) {
+	fp, err := fingerprintClientHello(clientHelloRecords(t))
+	require.NoError(t, err)
+	assert.Equal(t, "771,4865-4866,10-11-43,29-23,0", fp.JA3)
+	sum := md5.Sum([]byte(fp.JA3))
+	assert.Equal(t, hex.EncodeToString(sum[:]), fp.JA3Hash)
+
+	data := clientHelloRecords(t)
+	_, err = fingerprintClientHello(data[:len(data)-1])
+	assert.ErrorIs(t, err, ErrInvalidClientHello, "truncated hello")
+	_, err = fingerprintClientHello([]byte("GET / HTTP/1.1\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidClientHello)
+}
+
+func TestFingerprintListenerAndKeyLog(t *testing.T) {
+	certFile, keyFile := generateTestCertificate(t)
+	keyLogFile := filepath.Join(t.TempDir(), "keys.log")
+	cfg := DefaultTLSConfig()
+	cfg.Enabled = true
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.KeyLogFile = keyLogFile
+	tlsConfig, err := cfg.BuildTLSConfig()
+	require.NoError(t, err)
+
+	base, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := newFingerprintListener(base, tlsConfig)
+	defer listener.Close()
+
+	go func() {
+		conn, err := tls.Dial("tcp", base.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			conn.Read(make([]byte, 1))
+			conn.Close()
+		}
+	}()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	tlsConn := conn.(*tls.Conn)
+	require.NoError(t, tlsConn.Handshake())
+
+	fp, ok := tlsFingerprint(tlsConn)
+	require.True(t, ok)
+	assert.Regexp(t, `^771,[0-9-]+,[0-9-]+,[0-9-]*,[0-9-]*$`, fp.JA3)
+	assert.Len(t, fp.JA3Hash, 32)
+
+	keys, err := os.ReadFile(keyLogFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(keys), "SERVER_TRAFFIC_SECRET_0")
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrHandshakeTimeout is returned when a client does not complete the TLS
+	// handshake within TLSConfig.HandshakeTimeout.
+	ErrHandshakeTimeout = errors.New("TLS handshake timed out")
+
+	// ErrHandshakeCapacity is returned when TLSConfig.MaxConcurrentHandshakes
+	// handshakes are already in progress.
+	ErrHandshakeCapacity = errors.New("too many concurrent TLS handshakes")
+)
+
+// tlsHandshaker runs server-side TLS handshakes under a deadline and bounds
+// how many run at once, so clients that open a socket and stall cannot pin
+// goroutines or queue up handshake CPU without limit.
+type tlsHandshaker struct {
+	timeout time.Duration
+	slots   chan struct{} // nil when concurrency is unbounded
+
+	inProgress int64
+	timedOut   uint64
+	rejected   uint64
+}
+
+// newTLSHandshaker creates a handshaker from cfg; a nil cfg uses the defaults.
+func newTLSHandshaker(cfg *TLSConfig) *tlsHandshaker {
+	if cfg == nil {
+		cfg = DefaultTLSConfig()
+	}
+	h := &tlsHandshaker{timeout: cfg.HandshakeTimeout}
+	if cfg.MaxConcurrentHandshakes > 0 {
+		h.slots = make(chan struct{}, cfg.MaxConcurrentHandshakes)
+	}
+	return h
+}
+
+// Handshake completes conn's handshake, failing immediately with
+// ErrHandshakeCapacity when no slot is free and with ErrHandshakeTimeout when
+// the client is too slow. Cancelling ctx aborts the handshake.
+func (h *tlsHandshaker) Handshake(ctx context.Context, conn *tls.Conn) error {
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			defer func() { <-h.slots }()
+		default:
+			atomic.AddUint64(&h.rejected, 1)
+			return ErrHandshakeCapacity
+		}
+	}
+	atomic.AddInt64(&h.inProgress, 1)
+	defer atomic.AddInt64(&h.inProgress, -1)
+
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	err := conn.HandshakeContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		atomic.AddUint64(&h.timedOut, 1)
+		return ErrHandshakeTimeout
+	}
+	return err
+}
+
+// InProgress returns the number of handshakes currently running.
+func (h *tlsHandshaker) InProgress() int64 {
+	return atomic.LoadInt64(&h.inProgress)
+}
+
+// GetStats returns handshake limiter statistics for Server.GetStats.
+func (h *tlsHandshaker) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"timeout_ms":     h.timeout.Milliseconds(),
+		"max_concurrent": cap(h.slots),
+		"in_progress":    h.InProgress(),
+		"timed_out":      atomic.LoadUint64(&h.timedOut),
+		"rejected":       atomic.LoadUint64(&h.rejected),
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stalledTLSConn returns the server end of a TLS connection whose client
+// never sends a ClientHello.
+func stalledTLSConn(t *testing.T, config *tls.Config) *tls.Conn {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	t.Cleanup(func() {
+		clientSide.Close()
+		serverSide.Close()
+	})
+	return tls.Server(serverSide, config)
+}
+
+func testServerTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	certFile, keyFile := generateTestCertificate(t)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
+}
+
+func TestTLSHandshakerTimesOutStalledClients(t *testing.T) {
+	cfg := DefaultTLSConfig()
+	cfg.HandshakeTimeout = 50 * time.Millisecond
+	h := newTLSHandshaker(cfg)
+
+	start := time.Now()
+	err := h.Handshake(context.Background(), stalledTLSConn(t, testServerTLSConfig(t)))
+	assert.ErrorIs(t, err, ErrHandshakeTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, uint64(1), h.GetStats()["timed_out"])
+	assert.Zero(t, h.InProgress())
+}
+
+func TestTLSHandshakerCapsConcurrentHandshakes(t *testing.T) {
+	cfg := DefaultTLSConfig()
+	cfg.HandshakeTimeout = time.Minute
+	cfg.MaxConcurrentHandshakes = 1
+	h := newTLSHandshaker(cfg)
+	tlsConfig := testServerTLSConfig(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- h.Handshake(ctx, stalledTLSConn(t, tlsConfig)) }()
+	require.Eventually(t, func() bool { return h.InProgress() == 1 }, time.Second, time.Millisecond)
+
+	err := h.Handshake(context.Background(), stalledTLSConn(t, tlsConfig))
+	assert.ErrorIs(t, err, ErrHandshakeCapacity)
+	assert.Equal(t, uint64(1), h.GetStats()["rejected"])
+
+	// Cancelling frees the slot without counting a timeout
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Zero(t, h.InProgress())
+	assert.Equal(t, uint64(0), h.GetStats()["timed_out"])
+}
@@ -240,3 +240,41 @@ func TestTLSMetricsIntegration(t *testing.T) {
 		assert.True(t, health["avg_handshake_duration_ms"].(float64) > 0)
 	})
 }
+
+func TestTLS12CompatibilityMode(t *testing.T) {
+	certFile, keyFile := generateTestCertificate(t)
+	cfg := DefaultTLSConfig()
+	cfg.Enabled = true
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.AllowTLS12 = true
+	require.NoError(t, cfg.ValidateTLSConfig())
+	serverConfig, err := cfg.BuildTLSConfig()
+	require.NoError(t, err)
+
+	handshake := func(client *tls.Config) (tls.ConnectionState, error) {
+		serverSide, clientSide := net.Pipe()
+		defer serverSide.Close()
+		defer clientSide.Close()
+		go tls.Server(serverSide, serverConfig).Handshake()
+		conn := tls.Client(clientSide, client)
+		err := conn.Handshake()
+		return conn.ConnectionState(), err
+	}
+
+	state, err := handshake(&tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), state.Version)
+	assert.Contains(t, TLS12CipherSuites, state.CipherSuite)
+
+	_, err = handshake(&tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+		CipherSuites:       []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256},
+	})
+	assert.Error(t, err, "suites outside the whitelist are refused")
+
+	state, err = handshake(&tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), state.Version, "modern clients still get TLS 1.3")
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// SNIDefault labels handshakes served by the default certificate because
+// the client sent no server name or one that matched no SNI certificate.
+const SNIDefault = "default"
+
+// SNICertificate is a certificate served to clients asking for Host, which
+// may be an exact hostname or a single-label wildcard such as *.example.com.
+type SNICertificate struct {
+	Host     string
+	CertFile string
+	KeyFile  string
+}
+
+// parseSNICertificates parses entries of the form "host=certfile:keyfile".
+func parseSNICertificates(items []string) ([]SNICertificate, error) {
+	var certs []SNICertificate
+	for _, raw := range items {
+		entry := strings.TrimSpace(raw)
+		if entry == "" {
+			continue
+		}
+		host, files, ok := strings.Cut(entry, "=")
+		certFile, keyFile, hasKey := strings.Cut(files, ":")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || !hasKey || host == "" || certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("invalid SNI certificate %q, expected host=certfile:keyfile", entry)
+		}
+		certs = append(certs, SNICertificate{Host: host, CertFile: certFile, KeyFile: keyFile})
+	}
+	return certs, nil
+}
+
+// matchSNIHost returns the configured host that serverName selects: an exact
+// match first, then a wildcard for its parent domain. It returns SNIDefault
+// when none applies.
+func matchSNIHost(hosts map[string]bool, serverName string) string {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if name == "" {
+		return SNIDefault
+	}
+	if hosts[name] {
+		return name
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok && hosts["*."+parent] {
+		return "*." + parent
+	}
+	return SNIDefault
+}
+
+// sniCertificates selects a certificate by the client's server name, falling
+// back to the default certificate.
+type sniCertificates struct {
+	certs    map[string]*tls.Certificate
+	hosts    map[string]bool
+	fallback func(*tls.ClientHelloInfo) (*tls.Certificate, error) // nil uses tls.Config.Certificates
+}
+
+// loadSNICertificates loads every configured key pair.
+func loadSNICertificates(entries []SNICertificate) (*sniCertificates, error) {
+	s := &sniCertificates{
+		certs: make(map[string]*tls.Certificate, len(entries)),
+		hosts: make(map[string]bool, len(entries)),
+	}
+	for _, e := range entries {
+		cert, err := tls.LoadX509KeyPair(e.CertFile, e.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate for %s: %w", e.Host, err)
+		}
+		s.certs[e.Host] = &cert
+		s.hosts[e.Host] = true
+	}
+	return s, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (s *sniCertificates) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert, ok := s.certs[matchSNIHost(s.hosts, hello.ServerName)]; ok {
+		return cert, nil
+	}
+	if s.fallback != nil {
+		return s.fallback(hello)
+	}
+	return nil, nil // tls falls back to Config.Certificates
+}
+
+// sniHosts returns the set of hosts with their own certificate, for labelling
+// handshakes by the certificate they were served.
+func (cfg *TLSConfig) sniHosts() map[string]bool {
+	hosts := make(map[string]bool, len(cfg.SNICertificates))
+	for _, c := range cfg.SNICertificates {
+		hosts[c.Host] = true
+	}
+	return hosts
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSNICertificates(t *testing.T) {
+	certs, err := parseSNICertificates([]string{"A.Example.com=/c/a.pem:/c/a.key", " *.b.example.com=/c/b.pem:/c/b.key", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []SNICertificate{
+		{Host: "a.example.com", CertFile: "/c/a.pem", KeyFile: "/c/a.key"},
+		{Host: "*.b.example.com", CertFile: "/c/b.pem", KeyFile: "/c/b.key"},
+	}, certs)
+
+	for _, bad := range []string{"a.example.com", "a.example.com=/c/a.pem", "=/c/a.pem:/c/a.key"} {
+		_, err := parseSNICertificates([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMatchSNIHost(t *testing.T) {
+	hosts := map[string]bool{"a.example.com": true, "*.b.example.com": true}
+
+	assert.Equal(t, "a.example.com", matchSNIHost(hosts, "A.Example.com."))
+	assert.Equal(t, "*.b.example.com", matchSNIHost(hosts, "x.b.example.com"))
+	assert.Equal(t, SNIDefault, matchSNIHost(hosts, "x.y.b.example.com"), "wildcards cover one label")
+	assert.Equal(t, SNIDefault, matchSNIHost(hosts, "b.example.com"))
+	assert.Equal(t, SNIDefault, matchSNIHost(hosts, ""))
+	assert.Equal(t, SNIDefault, matchSNIHost(nil, "a.example.com"))
+}
+
+func TestBuildTLSConfigSelectsCertificateBySNI(t *testing.T) {
+	defaultCert, defaultKey := generateTestCertificate(t)
+	tenantCert, tenantKey := generateTestCertificate(t)
+	wildcardCert, wildcardKey := generateTestCertificate(t)
+
+	cfg := DefaultTLSConfig()
+	cfg.Enabled = true
+	cfg.CertFile, cfg.KeyFile = defaultCert, defaultKey
+	cfg.SNICertificates = []SNICertificate{
+		{Host: "tenant.example.com", CertFile: tenantCert, KeyFile: tenantKey},
+		{Host: "*.wild.example.com", CertFile: wildcardCert, KeyFile: wildcardKey},
+	}
+	require.NoError(t, cfg.ValidateTLSConfig())
+	serverConfig, err := cfg.BuildTLSConfig()
+	require.NoError(t, err)
+
+	served := func(serverName string) []byte {
+		serverSide, clientSide := net.Pipe()
+		defer serverSide.Close()
+		defer clientSide.Close()
+		go tls.Server(serverSide, serverConfig).Handshake()
+		conn := tls.Client(clientSide, &tls.Config{InsecureSkipVerify: true, ServerName: serverName})
+		require.NoError(t, conn.Handshake())
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+	leaf := func(certFile, keyFile string) []byte {
+		pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		require.NoError(t, err)
+		return pair.Certificate[0]
+	}
+
+	assert.True(t, bytes.Equal(leaf(tenantCert, tenantKey), served("tenant.example.com")))
+	assert.True(t, bytes.Equal(leaf(wildcardCert, wildcardKey), served("eu.wild.example.com")))
+	assert.True(t, bytes.Equal(leaf(defaultCert, defaultKey), served("other.example.com")))
+
+	cfg.SNICertificates = append(cfg.SNICertificates, SNICertificate{Host: "x", CertFile: "/missing.pem", KeyFile: "/missing.key"})
+	assert.Error(t, cfg.ValidateTLSConfig())
+}
@@ -54,6 +54,7 @@ func TestLoadTLSConfigFromEnv(t *testing.T) {
 		"TLS_INSECURE_SKIP_VERIFY": os.Getenv("TLS_INSECURE_SKIP_VERIFY"),
 		"TLS_CERT_WATCH_ENABLED":   os.Getenv("TLS_CERT_WATCH_ENABLED"),
 		"TLS_CERT_CHECK_INTERVAL":  os.Getenv("TLS_CERT_CHECK_INTERVAL"),
+		"TLS_MIN_VERSION":          os.Getenv("TLS_MIN_VERSION"),
 	}
 	
 	// Clean up after test
@@ -125,6 +126,20 @@ func TestLoadTLSConfigFromEnv(t *testing.T) {
 		
 		assert.Equal(t, 10*time.Minute, cfg.CertCheckInterval)
 	})
+	
+	t.Run("minimum version", func(t *testing.T) {
+		os.Setenv("TLS_MIN_VERSION", "1.2")
+		cfg := DefaultTLSConfig()
+		LoadTLSConfigFromEnv(cfg)
+		assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+		assert.True(t, cfg.AllowTLS12)
+		
+		os.Setenv("TLS_MIN_VERSION", "1.0")
+		cfg = DefaultTLSConfig()
+		LoadTLSConfigFromEnv(cfg)
+		assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion, "only 1.2 and 1.3 are accepted")
+		assert.False(t, cfg.AllowTLS12)
+	})
 }
 
 func TestTLSConfig_ValidateTLSConfig(t *testing.T) {
@@ -170,6 +185,19 @@ func TestTLSConfig_ValidateTLSConfig(t *testing.T) {
 		assert.Contains(t, err.Error(), "minimum TLS version must be 1.3")
 	})
 	
+	t.Run("TLS 1.2 explicitly allowed", func(t *testing.T) {
+		cfg := &TLSConfig{
+			Enabled:    true,
+			MinVersion: tls.VersionTLS12,
+			MaxVersion: tls.VersionTLS13,
+			AllowTLS12: true,
+		}
+		assert.NoError(t, cfg.ValidateTLSConfig())
+		
+		cfg.MinVersion = tls.VersionTLS11
+		assert.Error(t, cfg.ValidateTLSConfig(), "older versions are never allowed")
+	})
+	
 	t.Run("valid configuration", func(t *testing.T) {
 		cfg := &TLSConfig{
 			Enabled:    true,
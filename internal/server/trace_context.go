@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// joinTrace adopts the W3C traceparent in AUTH or SUBSCRIBE metadata as the
+// connection's trace, so later publish exemplars and log lines carry the
+// client's trace ID and span. A missing or malformed traceparent leaves the
+// current trace in place, as the W3C spec asks of receivers.
+func (c *Connection) joinTrace(metadata map[string]string) (protocol.TraceContext, bool) {
+	value, ok := metadata[protocol.MetadataTraceparent]
+	if !ok {
+		return protocol.TraceContext{}, false
+	}
+	tc, err := protocol.ParseTraceparent(value)
+	if err != nil {
+		c.Logger().Debug("ignoring invalid traceparent", "traceparent", value)
+		return protocol.TraceContext{}, false
+	}
+	c.trace.Store(&tc)
+	return tc, true
+}
+
+// traceAttrs returns the log attributes for the connection's trace.
+func (c *Connection) traceAttrs() []interface{} {
+	tc := c.trace.Load()
+	if tc == nil {
+		return nil
+	}
+	if tc.ParentID == "" {
+		return []interface{}{"trace_id", tc.TraceID}
+	}
+	return []interface{}{"trace_id", tc.TraceID, "parent_span_id", tc.ParentID}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func TestJoinTrace(t *testing.T) {
+	conn := &Connection{}
+	conn.trace.Store(&protocol.TraceContext{TraceID: newTraceID()})
+	own := conn.TraceID()
+
+	_, ok := conn.joinTrace(map[string]string{protocol.MetadataTraceparent: "not-a-traceparent"})
+	assert.False(t, ok)
+	assert.Equal(t, own, conn.TraceID(), "an invalid traceparent keeps the connection's trace")
+	assert.Equal(t, []interface{}{"trace_id", own}, conn.traceAttrs())
+
+	tc, ok := conn.joinTrace(map[string]string{protocol.MetadataTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"})
+	assert.True(t, ok)
+	assert.Equal(t, tc.TraceID, conn.TraceID())
+	assert.Equal(t, []interface{}{"trace_id", "4bf92f3577b34da6a3ce929d0e0e4736", "parent_span_id", "00f067aa0ba902b7"}, conn.traceAttrs())
+}
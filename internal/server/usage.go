@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UsageRecord is one user's usage over an accounting period.
+type UsageRecord struct {
+	Username         string    `json:"username"`
+	Tenant           string    `json:"tenant,omitempty"`
+	PeriodStart      time.Time `json:"period_start"`
+	PeriodEnd        time.Time `json:"period_end"`
+	ConnectedSeconds float64   `json:"connected_seconds"`
+	Connections      int       `json:"connections"` // Sessions started in the period
+	MessagesSent     uint64    `json:"messages_sent"`
+	MessagesReceived uint64    `json:"messages_received"`
+	BytesSent        uint64    `json:"bytes_sent"`
+	BytesReceived    uint64    `json:"bytes_received"`
+}
+
+// UsageExporter ships closed usage records to a billing pipeline.
+type UsageExporter interface {
+	ExportUsage(ctx context.Context, records []UsageRecord) error
+}
+
+// UsageFileExporter appends usage records to a file as JSON lines.
+type UsageFileExporter struct {
+	Path string
+}
+
+// ExportUsage appends records to the file, one JSON object per line.
+func (e *UsageFileExporter) ExportUsage(_ context.Context, records []UsageRecord) error {
+	f, err := os.OpenFile(e.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open usage file: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			f.Close()
+			return fmt.Errorf("write usage file: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+type usageKey struct {
+	username string
+	tenant   string
+}
+
+// usageConn is a tracked connection with its counters at the last sample.
+type usageConn struct {
+	key       usageKey
+	sampledAt time.Time
+	msgsSent  uint64
+	msgsRecv  uint64
+	bytesSent uint64
+	bytesRecv uint64
+}
+
+// UsageMeter accounts per-user connected time, messages and bytes of
+// authenticated connections. Flush closes the current period into one
+// record per user.
+type UsageMeter struct {
+	clock     Clock
+	retention time.Duration
+
+	mu          sync.Mutex
+	conns       map[*Connection]*usageConn
+	period      map[usageKey]*UsageRecord
+	periodStart time.Time
+	records     []UsageRecord // Closed periods within retention, oldest first
+
+	flushes      atomic.Uint64
+	exportErrors atomic.Uint64
+}
+
+// NewUsageMeter creates a meter keeping closed records for retention.
+func NewUsageMeter(clock Clock, retention time.Duration) *UsageMeter {
+	return &UsageMeter{
+		clock:       clock,
+		retention:   retention,
+		conns:       make(map[*Connection]*usageConn),
+		period:      make(map[usageKey]*UsageRecord),
+		periodStart: clock.Now(),
+	}
+}
+
+// Track starts accounting conn to its session's user. Traffic before Track,
+// such as the AUTH exchange, is not billed.
+func (m *UsageMeter) Track(conn *Connection) {
+	session := conn.Session()
+	if session == nil {
+		return
+	}
+	key := usageKey{username: session.Username}
+	if tenant := conn.Tenant(); tenant != nil {
+		key.tenant = tenant.Name()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	uc := &usageConn{key: key, sampledAt: m.clock.Now()}
+	uc.msgsSent, uc.msgsRecv, uc.bytesSent, uc.bytesRecv = conn.trafficCounters()
+	m.conns[conn] = uc
+	m.recordFor(key).Connections++
+}
+
+// Untrack accounts conn's usage since the last sample and stops tracking it.
+func (m *UsageMeter) Untrack(conn *Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if uc, ok := m.conns[conn]; ok {
+		m.sample(conn, uc, m.clock.Now())
+		delete(m.conns, conn)
+	}
+}
+
+// Flush closes the current period and returns its records, sorted by user.
+// Only users connected during the period get a record.
+func (m *UsageMeter) Flush() []UsageRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for conn, uc := range m.conns {
+		m.sample(conn, uc, now)
+	}
+
+	closed := make([]UsageRecord, 0, len(m.period))
+	for _, record := range m.period {
+		if *record == (UsageRecord{Username: record.Username, Tenant: record.Tenant}) {
+			continue
+		}
+		record.PeriodStart, record.PeriodEnd = m.periodStart, now
+		closed = append(closed, *record)
+	}
+	sort.Slice(closed, func(i, j int) bool {
+		if closed[i].Username != closed[j].Username {
+			return closed[i].Username < closed[j].Username
+		}
+		return closed[i].Tenant < closed[j].Tenant
+	})
+	m.period = make(map[usageKey]*UsageRecord)
+	m.periodStart = now
+
+	// Drop records older than the retention window
+	m.records = append(m.records, closed...)
+	cutoff := now.Add(-m.retention)
+	drop := 0
+	for drop < len(m.records) && m.records[drop].PeriodEnd.Before(cutoff) {
+		drop++
+	}
+	m.records = append([]UsageRecord(nil), m.records[drop:]...)
+
+	m.flushes.Add(1)
+	return closed
+}
+
+// Records returns retained records whose period ended after since, for
+// username, or for every user when username is empty.
+func (m *UsageMeter) Records(since time.Time, username string) []UsageRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records := make([]UsageRecord, 0, len(m.records))
+	for _, record := range m.records {
+		if record.PeriodEnd.After(since) && (username == "" || record.Username == username) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Start flushes every interval until ctx is done, passing each period's
+// records to exp when set, with a final flush on the way out.
+func (m *UsageMeter) Start(ctx context.Context, interval time.Duration, exp UsageExporter, logger *slog.Logger) {
+	flush := func(ctx context.Context) {
+		records := m.Flush()
+		if exp == nil || len(records) == 0 {
+			return
+		}
+		if err := exp.ExportUsage(ctx, records); err != nil {
+			m.exportErrors.Add(1)
+			logger.Warn("usage export failed", "records", len(records), "error", err)
+		}
+	}
+
+	go func() {
+		ticker := m.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), interval)
+				flush(flushCtx)
+				cancel()
+				return
+			case <-ticker.C():
+				flush(ctx)
+			}
+		}
+	}()
+}
+
+// GetStats returns usage accounting statistics.
+func (m *UsageMeter) GetStats() map[string]interface{} {
+	m.mu.Lock()
+	tracked, users, retained := len(m.conns), len(m.period), len(m.records)
+	m.mu.Unlock()
+	return map[string]interface{}{
+		"tracked_connections": tracked,
+		"period_users":        users,
+		"retained_records":    retained,
+		"flushes":             m.flushes.Load(),
+		"export_errors":       m.exportErrors.Load(),
+	}
+}
+
+// sample adds conn's usage since its last sample to the current period.
+// Callers hold m.mu.
+func (m *UsageMeter) sample(conn *Connection, uc *usageConn, now time.Time) {
+	msgsSent, msgsRecv, bytesSent, bytesRecv := conn.trafficCounters()
+	record := m.recordFor(uc.key)
+	if now.After(uc.sampledAt) {
+		record.ConnectedSeconds += now.Sub(uc.sampledAt).Seconds()
+	}
+	record.MessagesSent += msgsSent - uc.msgsSent
+	record.MessagesReceived += msgsRecv - uc.msgsRecv
+	record.BytesSent += bytesSent - uc.bytesSent
+	record.BytesReceived += bytesRecv - uc.bytesRecv
+	uc.sampledAt = now
+	uc.msgsSent, uc.msgsRecv, uc.bytesSent, uc.bytesRecv = msgsSent, msgsRecv, bytesSent, bytesRecv
+}
+
+// recordFor returns key's record for the current period. Callers hold m.mu.
+func (m *UsageMeter) recordFor(key usageKey) *UsageRecord {
+	record, ok := m.period[key]
+	if !ok {
+		record = &UsageRecord{Username: key.username, Tenant: key.tenant}
+		m.period[key] = record
+	}
+	return record
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+)
+
+func newUsageConn(t *testing.T, username string) *Connection {
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close(); server.Close() })
+	conn := NewConnection(server, DefaultConfig())
+	conn.SetAuthenticated(&auth.Session{Username: username})
+	return conn
+}
+
+func TestUsageMeterAggregatesPerUser(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	meter := NewUsageMeter(clock, time.Hour)
+
+	a1, a2, b := newUsageConn(t, "alice"), newUsageConn(t, "alice"), newUsageConn(t, "bob")
+	atomic.AddUint64(&a1.bytesRecv, 50) // AUTH exchange, before tracking
+	meter.Track(a1)
+	meter.Track(a2)
+	meter.Track(b)
+
+	clock.Advance(30 * time.Second)
+	atomic.AddUint64(&a1.messagesSent, 3)
+	atomic.AddUint64(&a1.bytesSent, 300)
+	atomic.AddUint64(&a2.messagesRecv, 1)
+	atomic.AddUint64(&a2.bytesRecv, 20)
+	meter.Untrack(a2)
+
+	clock.Advance(30 * time.Second)
+	records := meter.Flush()
+	require.Len(t, records, 2)
+	alice := records[0]
+	assert.Equal(t, "alice", alice.Username)
+	assert.Equal(t, 2, alice.Connections)
+	assert.InDelta(t, 90, alice.ConnectedSeconds, 0.001, "60s for a1 and 30s for a2")
+	assert.Equal(t, uint64(3), alice.MessagesSent)
+	assert.Equal(t, uint64(1), alice.MessagesReceived)
+	assert.Equal(t, uint64(300), alice.BytesSent)
+	assert.Equal(t, uint64(20), alice.BytesReceived)
+	assert.Equal(t, time.Unix(0, 0), alice.PeriodStart)
+	assert.Equal(t, time.Unix(60, 0), alice.PeriodEnd)
+	assert.Equal(t, "bob", records[1].Username)
+	assert.InDelta(t, 60, records[1].ConnectedSeconds, 0.001)
+
+	// The next period only counts new usage
+	clock.Advance(10 * time.Second)
+	atomic.AddUint64(&a1.bytesSent, 5)
+	records = meter.Flush()
+	require.Len(t, records, 2)
+	assert.Equal(t, 0, records[0].Connections)
+	assert.Equal(t, uint64(5), records[0].BytesSent)
+	assert.InDelta(t, 10, records[0].ConnectedSeconds, 0.001)
+
+	assert.Len(t, meter.Records(time.Time{}, ""), 4)
+	assert.Len(t, meter.Records(time.Unix(60, 0), "bob"), 1)
+
+	// Records age out after the retention window
+	meter.Untrack(a1)
+	meter.Untrack(b)
+	clock.Advance(2 * time.Hour)
+	assert.Empty(t, meter.Flush())
+	assert.Empty(t, meter.Records(time.Time{}, ""))
+}
+
+func TestUsageFileExporterAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	exp := &UsageFileExporter{Path: path}
+	require.NoError(t, exp.ExportUsage(context.Background(), []UsageRecord{{Username: "alice", BytesSent: 1}}))
+	require.NoError(t, exp.ExportUsage(context.Background(), []UsageRecord{{Username: "bob", BytesSent: 2}}))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var users []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record UsageRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		users = append(users, record.Username)
+	}
+	assert.Equal(t, []string{"alice", "bob"}, users)
+}
+
+func TestAdminUsageAPI(t *testing.T) {
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	clock := NewFakeClock(time.Unix(0, 0))
+	s := &Server{config: config, usage: NewUsageMeter(clock, time.Hour)}
+	s.usage.Track(newUsageConn(t, "alice"))
+	clock.Advance(time.Minute)
+	s.usage.Flush()
+
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(query string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/usage"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("?user=alice")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var records []UsageRecord
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&records))
+	require.Len(t, records, 1)
+	assert.InDelta(t, 60, records[0].ConnectedSeconds, 0.001)
+
+	assert.Equal(t, http.StatusBadRequest, get("?since=yesterday").StatusCode)
+}
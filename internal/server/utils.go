@@ -1,6 +1,9 @@
 package server
 
-import "strings"
+import (
+	"net"
+	"strings"
+)
 
 // splitAndTrimCSV splits a comma-separated string and trims whitespace from each element.
 // Empty results are omitted.
@@ -15,3 +18,16 @@ func splitAndTrimCSV(s string) []string {
 	}
 	return out
 }
+
+// hostIP parses the IP from a "host:port" address string, returning nil if it has none.
+func hostIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	return normalizeIP(ip)
+}
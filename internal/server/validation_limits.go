@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+// loadValidationLimitsFromEnv applies the VALIDATION_* variables to base, or
+// to the built-in limits when base is nil. It returns nil when none are set.
+func loadValidationLimitsFromEnv(base *protocol.Limits) (*protocol.Limits, error) {
+	limits := protocol.DefaultLimits()
+	if base != nil {
+		limits = *base
+	}
+	set := false
+	for _, v := range []struct {
+		env string
+		dst interface{}
+	}{
+		{"VALIDATION_MAX_SYMBOLS", &limits.MaxSymbolsCount},
+		{"VALIDATION_MAX_TICKS_PER_BATCH", &limits.MaxTicksPerBatch},
+		{"VALIDATION_MIN_PRICE", &limits.MinPrice},
+		{"VALIDATION_MAX_PRICE", &limits.MaxPrice},
+		{"VALIDATION_MIN_VOLUME", &limits.MinVolume},
+		{"VALIDATION_MAX_VOLUME", &limits.MaxVolume},
+		{"VALIDATION_MAX_TIMESTAMP_AGE", &limits.MaxTimestampAge},
+		{"VALIDATION_MAX_TIMESTAMP_SKEW", &limits.MaxTimestampSkew},
+	} {
+		raw := os.Getenv(v.env)
+		if raw == "" {
+			continue
+		}
+		set = true
+		var err error
+		switch dst := v.dst.(type) {
+		case *int:
+			*dst, err = strconv.Atoi(raw)
+		case *float64:
+			*dst, err = strconv.ParseFloat(raw, 64)
+		case *time.Duration:
+			*dst, err = time.ParseDuration(raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", v.env, raw)
+		}
+	}
+	if !set {
+		return nil, nil
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+// validationLimits returns the configured limits, checking that outbound
+// batches fit within them.
+func (c *Config) validationLimits() (protocol.Limits, error) {
+	limits := protocol.DefaultLimits()
+	if c.ValidationLimits != nil {
+		limits = *c.ValidationLimits
+	}
+	if err := limits.Validate(); err != nil {
+		return limits, err
+	}
+	if c.MaxBatchSize > limits.MaxTicksPerBatch || c.SubscriptionMaxBatchSize > limits.MaxTicksPerBatch {
+		return limits, fmt.Errorf("batch sizes must not exceed %d ticks per batch", limits.MaxTicksPerBatch)
+	}
+	return limits, nil
+}
+
+// applyValidationLimits installs the configured limits.
+func (s *Server) applyValidationLimits() error {
+	limits, err := s.config.validationLimits()
+	if err != nil {
+		return err
+	}
+	return protocol.SetLimits(limits)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func TestLoadValidationLimitsFromEnv(t *testing.T) {
+	limits, err := loadValidationLimitsFromEnv(nil)
+	require.NoError(t, err)
+	assert.Nil(t, limits, "built-in limits without VALIDATION_ variables")
+
+	t.Setenv("VALIDATION_MAX_PRICE", "100000000")
+	t.Setenv("VALIDATION_MIN_PRICE", "0.00000001")
+	t.Setenv("VALIDATION_MAX_TIMESTAMP_AGE", "1h")
+	limits, err = loadValidationLimitsFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1e8, limits.MaxPrice)
+	assert.Equal(t, 1e-8, limits.MinPrice)
+	assert.Equal(t, time.Hour, limits.MaxTimestampAge)
+	assert.Equal(t, protocol.MaxTicksPerBatch, limits.MaxTicksPerBatch, "unset limits keep their defaults")
+
+	t.Setenv("VALIDATION_MAX_SYMBOLS", "many")
+	_, err = loadValidationLimitsFromEnv(nil)
+	assert.ErrorContains(t, err, "VALIDATION_MAX_SYMBOLS")
+	t.Setenv("VALIDATION_MAX_SYMBOLS", "0")
+	_, err = loadValidationLimitsFromEnv(nil)
+	assert.Error(t, err, "out of bounds")
+}
+
+func TestApplyValidationLimits(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, protocol.SetLimits(protocol.DefaultLimits())) })
+
+	limits := protocol.DefaultLimits()
+	limits.MaxTicksPerBatch = 500
+	config := DefaultConfig()
+	config.ValidationLimits = &limits
+	srv := &Server{config: config}
+	assert.ErrorContains(t, srv.applyValidationLimits(), "500 ticks per batch", "subscriptions may ask for 1000-tick batches")
+
+	config.SubscriptionMaxBatchSize = 500
+	require.NoError(t, srv.applyValidationLimits())
+	assert.Equal(t, 500, protocol.CurrentLimits().MaxTicksPerBatch)
+}
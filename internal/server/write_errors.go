@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+// ErrWriteDeadlineExceeded is returned for a frame whose write deadline
+// passed before it could be written.
+var ErrWriteDeadlineExceeded = errors.New("write deadline exceeded")
+
+// Classes of failed writes, as reported by classifyWriteError.
+const (
+	writeErrorDeadline  = "deadline_exceeded"
+	writeErrorQueueFull = "queue_full"
+	writeErrorReset     = "connection_reset"
+	writeErrorOther     = "other"
+)
+
+// classifyWriteError reports why a write failed: its deadline passed, in the
+// write queue or on the socket; the write queue was full; or the peer reset
+// or closed the connection.
+func classifyWriteError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrWriteQueueFull):
+		return writeErrorQueueFull
+	case errors.Is(err, ErrWriteDeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return writeErrorDeadline
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, net.ErrClosed), errors.Is(err, io.ErrClosedPipe), errors.Is(err, io.EOF):
+		return writeErrorReset
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return writeErrorDeadline
+	}
+	return writeErrorOther
+}
+
+// writeErrorCounters aggregates failed writes across connections.
+type writeErrorCounters struct {
+	deadlineExceeded atomic.Uint64 // Frames whose deadline passed in the write queue
+	timeouts         atomic.Uint64 // Socket writes that ran past the deadline
+	queueFull        atomic.Uint64 // Frames refused because the write queue was full
+	resets           atomic.Uint64 // Socket writes failed by a reset or closed connection
+	other            atomic.Uint64 // Socket writes failed for any other reason
+}
+
+// record counts a failed socket write by its class. Counters may be nil.
+func (c *writeErrorCounters) record(err error) {
+	if c == nil || err == nil {
+		return
+	}
+	switch classifyWriteError(err) {
+	case writeErrorDeadline:
+		c.timeouts.Add(1)
+	case writeErrorQueueFull:
+		c.queueFull.Add(1)
+	case writeErrorReset:
+		c.resets.Add(1)
+	default:
+		c.other.Add(1)
+	}
+}
+
+// expired counts a frame dropped because its deadline passed in the write
+// queue. Counters may be nil.
+func (c *writeErrorCounters) expired() {
+	if c != nil {
+		c.deadlineExceeded.Add(1)
+	}
+}
+
+// WriteErrorStats counts failed writes by class.
+type WriteErrorStats struct {
+	DeadlineExceeded uint64
+	Timeouts         uint64
+	QueueFull        uint64
+	ConnectionResets uint64
+	Other            uint64
+}
+
+// Stats returns the counts so far.
+func (c *writeErrorCounters) Stats() WriteErrorStats {
+	return WriteErrorStats{
+		DeadlineExceeded: c.deadlineExceeded.Load(),
+		Timeouts:         c.timeouts.Load(),
+		QueueFull:        c.queueFull.Load(),
+		ConnectionResets: c.resets.Load(),
+		Other:            c.other.Load(),
+	}
+}
+
+// GetStats returns the counts for server stats.
+func (st WriteErrorStats) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"deadline_exceeded": st.DeadlineExceeded,
+		"timeouts":          st.Timeouts,
+		"queue_full":        st.QueueFull,
+		"connection_resets": st.ConnectionResets,
+		"other":             st.Other,
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+)
+
+func TestClassifyWriteError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w - slow client detected", ErrWriteQueueFull), writeErrorQueueFull},
+		{ErrWriteDeadlineExceeded, writeErrorDeadline},
+		{&net.OpError{Op: "write", Err: os.ErrDeadlineExceeded}, writeErrorDeadline},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.ECONNRESET)}, writeErrorReset},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, writeErrorReset},
+		{io.ErrClosedPipe, writeErrorReset},
+		{errors.New("disk on fire"), writeErrorOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, classifyWriteError(tt.err), tt.err.Error())
+	}
+}
+
+// writeErrorConn returns a connection with a short write deadline counting
+// its failed writes into counters, and the client side of its pipe.
+func writeErrorConn(t *testing.T, counters *writeErrorCounters) (*Connection, net.Conn) {
+	config := DefaultConfig()
+	config.WriteDeadlineMS = 20
+	serverSide, clientSide := net.Pipe()
+	conn := NewConnection(serverSide, config)
+	conn.writeErrors = counters
+	t.Cleanup(func() {
+		clientSide.Close()
+		conn.Close()
+	})
+	return conn, clientSide
+}
+
+func TestConnectionCountsWriteErrorsByClass(t *testing.T) {
+	frame := &protocol.Frame{Type: protocol.MessageTypeHeartbeat}
+	var counters writeErrorCounters
+
+	// Nobody reads the pipe, so the socket write runs past its deadline
+	conn, _ := writeErrorConn(t, &counters)
+	err := conn.WriteFrameSync(frame)
+	assert.Equal(t, writeErrorDeadline, classifyWriteError(err))
+
+	// The peer has gone away
+	conn, client := writeErrorConn(t, &counters)
+	client.Close()
+	err = conn.WriteFrameSync(frame)
+	assert.Equal(t, writeErrorReset, classifyWriteError(err))
+
+	// A frame that waited out its deadline in the queue is dropped unwritten
+	conn, _ = writeErrorConn(t, &counters)
+	done := make(chan error, 1)
+	require.NoError(t, conn.writeItem(&WriteQueueItem{frame: frame, deadline: time.Now().Add(-time.Millisecond), done: done}))
+	assert.ErrorIs(t, <-done, ErrWriteDeadlineExceeded)
+
+	assert.Equal(t, WriteErrorStats{DeadlineExceeded: 1, Timeouts: 1, ConnectionResets: 1}, counters.Stats())
+}
+
+func TestWriteDeadlineDefaultsWhenUnset(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, 5*time.Second, config.writeDeadline())
+	config.WriteDeadlineMS = 250
+	assert.Equal(t, 250*time.Millisecond, config.writeDeadline())
+	config.WriteDeadlineMS = 0
+	assert.Equal(t, defaultWriteDeadline, config.writeDeadline())
+
+	t.Setenv("WRITE_DEADLINE_MS", "-5")
+	LoadConfigFromEnv(config)
+	assert.Equal(t, 0, config.WriteDeadlineMS, "invalid values are ignored")
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// slowestWriteQueues is how many connections WriteQueueAggregate lists by
+// estimated drain time.
+const slowestWriteQueues = 5
+
+// WriteQueueStats describes one connection's write queue.
+type WriteQueueStats struct {
+	Depth          int           // Frames waiting to be written
+	HighWater      int           // Deepest the queue has been
+	QueuedBytes    int64         // Encoded bytes waiting to be written
+	AvgWrite       time.Duration // Moving average of one socket write
+	EstimatedDrain time.Duration // Depth * AvgWrite
+}
+
+// WriteQueueStats returns the connection's current write queue occupancy.
+func (c *Connection) WriteQueueStats() WriteQueueStats {
+	st := WriteQueueStats{
+		Depth:       int(atomic.LoadInt32(&c.writeQueueLen)),
+		HighWater:   int(atomic.LoadInt32(&c.queueHighWater)),
+		QueuedBytes: atomic.LoadInt64(&c.queuedBytes),
+		AvgWrite:    time.Duration(atomic.LoadInt64(&c.avgWriteNanos)),
+	}
+	st.EstimatedDrain = time.Duration(st.Depth) * st.AvgWrite
+	return st
+}
+
+// raiseHighWater records depth if it is the deepest the queue has been.
+func (c *Connection) raiseHighWater(depth int32) {
+	for {
+		hw := atomic.LoadInt32(&c.queueHighWater)
+		if depth <= hw || atomic.CompareAndSwapInt32(&c.queueHighWater, hw, depth) {
+			return
+		}
+	}
+}
+
+// recordWrite folds one socket write into the moving average, weighting the
+// newest write 1/8. Only the write loop calls it.
+func (c *Connection) recordWrite(d time.Duration) {
+	avg := atomic.LoadInt64(&c.avgWriteNanos)
+	if avg == 0 {
+		avg = int64(d)
+	} else {
+		avg += (int64(d) - avg) / 8
+	}
+	atomic.StoreInt64(&c.avgWriteNanos, avg)
+}
+
+// ConnWriteQueue is one connection's entry in WriteQueueAggregate.Slowest.
+type ConnWriteQueue struct {
+	ConnectionID string
+	WriteQueueStats
+}
+
+// WriteQueueAggregate summarises write queues across connections.
+type WriteQueueAggregate struct {
+	Connections  int
+	TotalDepth   int
+	MaxDepth     int
+	MaxHighWater int
+	P99Drain     time.Duration
+	MaxDrain     time.Duration
+	Slowest      []ConnWriteQueue // Longest estimated drain first
+}
+
+// writeQueueStats returns write queue occupancy across all registered
+// connections.
+func (s *Server) writeQueueStats() WriteQueueAggregate {
+	var queues []ConnWriteQueue
+	s.connections.each(func(conn *Connection) {
+		queues = append(queues, ConnWriteQueue{ConnectionID: conn.ID(), WriteQueueStats: conn.WriteQueueStats()})
+	})
+
+	agg := WriteQueueAggregate{Connections: len(queues)}
+	if len(queues) == 0 {
+		return agg
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if queues[i].EstimatedDrain != queues[j].EstimatedDrain {
+			return queues[i].EstimatedDrain > queues[j].EstimatedDrain
+		}
+		return queues[i].ConnectionID < queues[j].ConnectionID
+	})
+	for _, q := range queues {
+		agg.TotalDepth += q.Depth
+		if q.Depth > agg.MaxDepth {
+			agg.MaxDepth = q.Depth
+		}
+		if q.HighWater > agg.MaxHighWater {
+			agg.MaxHighWater = q.HighWater
+		}
+	}
+	agg.MaxDrain = queues[0].EstimatedDrain
+	agg.P99Drain = queues[len(queues)-1-(len(queues)*99-1)/100].EstimatedDrain
+	n := len(queues)
+	if n > slowestWriteQueues {
+		n = slowestWriteQueues
+	}
+	agg.Slowest = queues[:n:n]
+	return agg
+}
+
+// GetStats returns the aggregate as a map for Server.GetStats.
+func (agg WriteQueueAggregate) GetStats() map[string]interface{} {
+	slowest := make([]map[string]interface{}, 0, len(agg.Slowest))
+	for _, q := range agg.Slowest {
+		slowest = append(slowest, map[string]interface{}{
+			"connection_id":      q.ConnectionID,
+			"depth":              q.Depth,
+			"high_water":         q.HighWater,
+			"queued_bytes":       q.QueuedBytes,
+			"avg_write_ms":       float64(q.AvgWrite) / float64(time.Millisecond),
+			"estimated_drain_ms": float64(q.EstimatedDrain) / float64(time.Millisecond),
+		})
+	}
+	return map[string]interface{}{
+		"connections":    agg.Connections,
+		"total_depth":    agg.TotalDepth,
+		"max_depth":      agg.MaxDepth,
+		"max_high_water": agg.MaxHighWater,
+		"p99_drain_ms":   float64(agg.P99Drain) / float64(time.Millisecond),
+		"max_drain_ms":   float64(agg.MaxDrain) / float64(time.Millisecond),
+		"slowest":        slowest,
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteQueueStatsTracksHighWater(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConnMemoryBytes = 0
+	conn := stalledConnection(t, config)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, conn.WriteFrameAsync(testFrame(100)))
+	}
+	// The write loop may have taken the first frame off the queue
+	st := conn.WriteQueueStats()
+	assert.GreaterOrEqual(t, st.Depth, 2)
+	assert.Equal(t, 3, st.HighWater)
+
+	conn.raiseHighWater(1)
+	assert.Equal(t, 3, conn.WriteQueueStats().HighWater, "the high-water mark never falls")
+}
+
+func TestWriteQueueStatsEstimatesDrain(t *testing.T) {
+	conn := &Connection{writeQueueLen: 4}
+	conn.recordWrite(8 * time.Millisecond)
+	assert.Equal(t, 8*time.Millisecond, conn.WriteQueueStats().AvgWrite)
+
+	conn.recordWrite(16 * time.Millisecond)
+	st := conn.WriteQueueStats()
+	assert.Equal(t, 9*time.Millisecond, st.AvgWrite)
+	assert.Equal(t, 36*time.Millisecond, st.EstimatedDrain)
+}
+
+func TestWriteQueueAggregate(t *testing.T) {
+	srv := &Server{}
+	for i := 1; i <= 100; i++ {
+		conn := &Connection{id: fmt.Sprint(i), writeQueueLen: int32(i), queueHighWater: int32(2 * i)}
+		conn.recordWrite(time.Millisecond)
+		srv.connections.add(conn)
+	}
+
+	agg := srv.writeQueueStats()
+	assert.Equal(t, 100, agg.Connections)
+	assert.Equal(t, 5050, agg.TotalDepth)
+	assert.Equal(t, 100, agg.MaxDepth)
+	assert.Equal(t, 200, agg.MaxHighWater)
+	assert.Equal(t, 99*time.Millisecond, agg.P99Drain)
+	assert.Equal(t, 100*time.Millisecond, agg.MaxDrain)
+	require.Len(t, agg.Slowest, slowestWriteQueues)
+	assert.Equal(t, "100", agg.Slowest[0].ConnectionID)
+
+	stats := agg.GetStats()
+	assert.Equal(t, 100.0, stats["max_drain_ms"])
+	assert.Len(t, stats["slowest"], slowestWriteQueues)
+}
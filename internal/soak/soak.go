@@ -0,0 +1,418 @@
+// Package soak runs the server together with synthetic clients in-process for
+// long periods and checks that goroutines, memory, and pools stay bounded.
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+var (
+	// ErrGoroutineLeak indicates goroutine count grew beyond the allowed bound.
+	ErrGoroutineLeak = errors.New("goroutine leak detected")
+
+	// ErrMemoryGrowth indicates RSS grew beyond the allowed bound.
+	ErrMemoryGrowth = errors.New("memory growth exceeded bound")
+
+	// ErrPoolBacklog indicates the connection goroutine pool queue kept growing.
+	ErrPoolBacklog = errors.New("goroutine pool backlog exceeded bound")
+)
+
+// Config holds soak test configuration.
+type Config struct {
+	Duration       time.Duration
+	Warmup         time.Duration
+	SampleInterval time.Duration
+
+	// Synthetic client settings
+	Clients        int
+	ClientLifetime time.Duration // Clients reconnect after roughly this long (0 keeps them connected)
+	Username       string
+	Password       string
+
+	// Bounds checked on every sample, relative to the post-warmup baseline
+	MaxGoroutineGrowth int
+	MaxRSSGrowthMB     int64
+	MaxQueuedTasks     int32
+
+	// Server configuration (ListenAddr is forced to an ephemeral local port)
+	ServerConfig *server.Config
+}
+
+// DefaultConfig returns default soak configuration.
+func DefaultConfig() *Config {
+	return &Config{
+		Duration:           1 * time.Hour,
+		Warmup:             30 * time.Second,
+		SampleInterval:     10 * time.Second,
+		Clients:            200,
+		ClientLifetime:     2 * time.Minute,
+		Username:           "soak",
+		Password:           "soak",
+		MaxGoroutineGrowth: 100,
+		MaxRSSGrowthMB:     256,
+		MaxQueuedTasks:     1000,
+	}
+}
+
+// LoadConfigFromEnv loads soak configuration from environment variables.
+func LoadConfigFromEnv(cfg *Config) {
+	if v := os.Getenv("SOAK_DURATION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Duration = d
+		}
+	}
+	if v := os.Getenv("SOAK_WARMUP"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.Warmup = d
+		}
+	}
+	if v := os.Getenv("SOAK_SAMPLE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.SampleInterval = d
+		}
+	}
+	if v := os.Getenv("SOAK_CLIENTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Clients = n
+		}
+	}
+	if v := os.Getenv("SOAK_CLIENT_LIFETIME"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.ClientLifetime = d
+		}
+	}
+	if v := os.Getenv("SOAK_MAX_GOROUTINE_GROWTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxGoroutineGrowth = n
+		}
+	}
+	if v := os.Getenv("SOAK_MAX_RSS_GROWTH_MB"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.MaxRSSGrowthMB = n
+		}
+	}
+}
+
+// Sample is a single point-in-time measurement.
+type Sample struct {
+	Time              time.Time
+	Goroutines        int
+	RSSBytes          int64
+	HeapInuseBytes    uint64
+	ActiveConnections int32
+	Pool              server.PoolStats
+}
+
+// Report summarizes a soak run.
+type Report struct {
+	Baseline     Sample
+	Samples      []Sample
+	Reconnects   uint64
+	ClientErrors uint64
+	Batches      uint64
+	LeakDiff     string // Goroutine stacks that grew since baseline, set on leak failures
+}
+
+// Runner runs a soak test.
+type Runner struct {
+	config *Config
+	logger *slog.Logger
+
+	reconnects   uint64
+	clientErrors uint64
+	batches      uint64
+}
+
+// NewRunner creates a new soak runner.
+func NewRunner(config *Config, logger *slog.Logger) *Runner {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Runner{config: config, logger: logger}
+}
+
+// Run starts the server and clients and samples resource usage until the
+// configured duration elapses, ctx is cancelled, or a bound is violated.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	cfg := r.config
+
+	// The server reads credentials from the environment
+	os.Setenv("STREAM_USER", cfg.Username)
+	os.Setenv("STREAM_PASS", cfg.Password)
+
+	serverCfg := cfg.ServerConfig
+	if serverCfg == nil {
+		serverCfg = server.DefaultConfig()
+	}
+	serverCfg.ListenAddr = "127.0.0.1:0"
+	if serverCfg.TLS != nil {
+		serverCfg.TLS.Enabled = false
+	}
+
+	srv := server.NewServer(serverCfg)
+	if err := srv.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+	defer func() {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer stopCancel()
+		srv.Stop(stopCtx)
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Clients; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			r.runClient(runCtx, srv.ListenAddr(), id, serverCfg.HeartbeatInterval)
+		}(i)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	report := &Report{}
+	defer func() {
+		report.Reconnects = atomic.LoadUint64(&r.reconnects)
+		report.ClientErrors = atomic.LoadUint64(&r.clientErrors)
+		report.Batches = atomic.LoadUint64(&r.batches)
+	}()
+
+	// Let connections settle before taking the baseline
+	select {
+	case <-time.After(cfg.Warmup):
+	case <-runCtx.Done():
+		return report, nil
+	}
+	runtime.GC()
+	baseStacks := CaptureStacks()
+	report.Baseline = r.sample(srv)
+	r.logger.Info("soak baseline captured",
+		"goroutines", report.Baseline.Goroutines,
+		"rss_bytes", report.Baseline.RSSBytes,
+		"active_connections", report.Baseline.ActiveConnections,
+	)
+
+	ticker := time.NewTicker(cfg.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-runCtx.Done():
+			return report, nil
+		case <-ticker.C:
+		}
+
+		runtime.GC()
+		s := r.sample(srv)
+		report.Samples = append(report.Samples, s)
+		r.logger.Info("soak sample",
+			"goroutines", s.Goroutines,
+			"rss_bytes", s.RSSBytes,
+			"heap_inuse_bytes", s.HeapInuseBytes,
+			"active_connections", s.ActiveConnections,
+			"pool_queued", s.Pool.QueuedTasks,
+		)
+
+		if growth := s.Goroutines - report.Baseline.Goroutines; growth > cfg.MaxGoroutineGrowth {
+			report.LeakDiff = CaptureStacks().Diff(baseStacks)
+			return report, fmt.Errorf("%w: %d goroutines above baseline (limit %d)",
+				ErrGoroutineLeak, growth, cfg.MaxGoroutineGrowth)
+		}
+		if growth := s.RSSBytes - report.Baseline.RSSBytes; growth > cfg.MaxRSSGrowthMB*1024*1024 {
+			return report, fmt.Errorf("%w: RSS grew by %d MB (limit %d MB)",
+				ErrMemoryGrowth, growth/(1024*1024), cfg.MaxRSSGrowthMB)
+		}
+		if s.Pool.QueuedTasks > cfg.MaxQueuedTasks {
+			return report, fmt.Errorf("%w: %d queued tasks (limit %d)",
+				ErrPoolBacklog, s.Pool.QueuedTasks, cfg.MaxQueuedTasks)
+		}
+	}
+}
+
+// sample measures current resource usage.
+func (r *Runner) sample(srv *server.Server) Sample {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	active, _ := srv.GetStats()["active_connections"].(int32)
+	return Sample{
+		Time:              time.Now(),
+		Goroutines:        runtime.NumGoroutine(),
+		RSSBytes:          readRSS(&ms),
+		HeapInuseBytes:    ms.HeapInuse,
+		ActiveConnections: active,
+		Pool:              srv.GoroutinePoolStats(),
+	}
+}
+
+// readRSS returns resident set size from /proc on Linux, falling back to
+// memory obtained from the OS by the Go runtime elsewhere.
+func readRSS(ms *runtime.MemStats) int64 {
+	if data, err := os.ReadFile("/proc/self/statm"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) >= 2 {
+			if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return pages * int64(os.Getpagesize())
+			}
+		}
+	}
+	return int64(ms.Sys)
+}
+
+// runClient keeps a synthetic subscriber connected until ctx is done,
+// reconnecting after each lifetime expires or on error.
+func (r *Runner) runClient(ctx context.Context, addr string, id int, heartbeatInterval time.Duration) {
+	rng := rand.New(rand.NewSource(int64(id) + time.Now().UnixNano()))
+	for ctx.Err() == nil {
+		lifetime := r.config.ClientLifetime
+		if lifetime > 0 {
+			// Jitter lifetimes so reconnects are spread out
+			lifetime = lifetime/2 + time.Duration(rng.Int63n(int64(lifetime)))
+		}
+
+		if err := r.clientSession(ctx, addr, id, heartbeatInterval, lifetime); err != nil && ctx.Err() == nil {
+			atomic.AddUint64(&r.clientErrors, 1)
+			r.logger.Debug("soak client error", "client", id, "error", err)
+			time.Sleep(100 * time.Millisecond)
+		}
+		atomic.AddUint64(&r.reconnects, 1)
+	}
+}
+
+// clientSession runs one AUTH → SUBSCRIBE → heartbeat/read session.
+func (r *Runner) clientSession(ctx context.Context, addr string, id int, heartbeatInterval, lifetime time.Duration) error {
+	conn, err := dialFrom(addr, id)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sessCtx := ctx
+	if lifetime > 0 {
+		var cancel context.CancelFunc
+		sessCtx, cancel = context.WithTimeout(ctx, lifetime)
+		defer cancel()
+	}
+	go func() {
+		<-sessCtx.Done()
+		conn.Close()
+	}()
+
+	reader := protocol.NewFrameReader(conn, 0)
+	writer := protocol.NewFrameWriter(conn)
+	var writeMu sync.Mutex
+	write := func(frame *protocol.Frame, err error) error {
+		if err != nil {
+			return err
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return writer.WriteFrame(frame)
+	}
+
+	if err := write(protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: r.config.Username,
+		Password: r.config.Password,
+		ClientId: fmt.Sprintf("soak-%d", id),
+		Version:  "1.0.0",
+	})); err != nil {
+		return err
+	}
+	if err := expectACK(reader); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	if err := write(protocol.MarshalMessage(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{
+		Mode: pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND,
+	})); err != nil {
+		return err
+	}
+	if err := expectACK(reader); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	// Heartbeat sender
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		var seq uint64
+		for {
+			select {
+			case <-sessCtx.Done():
+				return
+			case <-ticker.C:
+				seq++
+				if err := write(protocol.MarshalMessage(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{
+					TimestampMs: time.Now().UnixMilli(),
+					Sequence:    seq,
+				})); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			if sessCtx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if frame.Type == protocol.MessageTypeDataBatch {
+			atomic.AddUint64(&r.batches, 1)
+		}
+	}
+}
+
+// dialFrom connects using a per-client loopback source address so that the
+// server's per-IP DDoS and rate limits treat synthetic clients as distinct
+// hosts. Platforms that only route 127.0.0.1 fall back to the default address.
+func dialFrom(addr string, id int) (net.Conn, error) {
+	d := net.Dialer{
+		Timeout:   5 * time.Second,
+		LocalAddr: &net.TCPAddr{IP: net.IPv4(127, byte(1+id/250), byte(id%250), 2)},
+	}
+	conn, err := d.Dial("tcp", addr)
+	if err == nil {
+		return conn, nil
+	}
+	return net.DialTimeout("tcp", addr, 5*time.Second)
+}
+
+// expectACK reads the next frame and returns an error unless it is an ACK.
+func expectACK(reader *protocol.FrameReader) error {
+	frame, err := reader.ReadFrame()
+	if err != nil {
+		return err
+	}
+	if frame.Type != protocol.MessageTypeACK {
+		return fmt.Errorf("unexpected frame type %d", frame.Type)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package soak
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDump = `goroutine 1 [running]:
+main.main()
+	/src/main.go:12 +0x1d
+
+goroutine 17 [chan receive, 3 minutes]:
+github.com/x/server.(*Handler).loop(0xc000123456, {0x10, 0x20})
+	/src/handler.go:40 +0x55
+created by github.com/x/server.Start in goroutine 1
+	/src/server.go:80 +0x99
+
+goroutine 18 [chan receive]:
+github.com/x/server.(*Handler).loop(0xc000999999, {0x30, 0x40})
+	/src/handler.go:40 +0x55
+created by github.com/x/server.Start in goroutine 1
+	/src/server.go:80 +0x99`
+
+func TestParseStacksGroupsBySignature(t *testing.T) {
+	profile := ParseStacks(sampleDump)
+
+	assert.Equal(t, 3, profile.Total())
+	assert.Len(t, profile, 2, "goroutines differing only by ID, args, and wait time share a signature")
+
+	for sig, n := range profile {
+		if strings.Contains(sig, "Handler") {
+			assert.Equal(t, 2, n)
+			assert.NotContains(t, sig, "0xc000")
+			assert.NotContains(t, sig, "in goroutine")
+		}
+	}
+}
+
+func TestStackProfileDiff(t *testing.T) {
+	base := ParseStacks(sampleDump)
+	grown := ParseStacks(sampleDump + "\n\ngoroutine 99 [chan receive]:\ngithub.com/x/server.(*Handler).loop(0x1)\n\t/src/handler.go:40 +0x55\ncreated by github.com/x/server.Start in goroutine 1\n\t/src/server.go:80 +0x99")
+
+	diff := grown.Diff(base)
+	assert.Contains(t, diff, "+1 goroutines (2 -> 3)")
+	assert.Contains(t, diff, "(*Handler).loop")
+	assert.Empty(t, base.Diff(grown))
+}
+
+// TestSoak runs a full soak only when SOAK_DURATION is set, e.g.
+// SOAK_DURATION=2h go test -run TestSoak -timeout 0 ./internal/soak
+func TestSoak(t *testing.T) {
+	if os.Getenv("SOAK_DURATION") == "" {
+		t.Skip("set SOAK_DURATION to run the soak test")
+	}
+
+	cfg := DefaultConfig()
+	LoadConfigFromEnv(cfg)
+
+	report, err := NewRunner(cfg, nil).Run(context.Background())
+	if report != nil && report.LeakDiff != "" {
+		t.Logf("leaked goroutine stacks:\n%s", report.LeakDiff)
+	}
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	t.Logf("soak completed: %d samples, %d reconnects, %d batches, %d client errors",
+		len(report.Samples), report.Reconnects, report.Batches, report.ClientErrors)
+	assert.NotZero(t, report.Batches, "clients should have received data")
+}
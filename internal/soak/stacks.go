@@ -0,0 +1,100 @@
+package soak
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// goroutineHeader matches "goroutine 42 [chan receive, 5 minutes]:"
+var goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[([^,\]]+)`)
+
+// StackProfile counts live goroutines grouped by normalized stack signature.
+type StackProfile map[string]int
+
+// CaptureStacks returns the current goroutine profile.
+func CaptureStacks() StackProfile {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	return ParseStacks(string(buf))
+}
+
+// ParseStacks groups a runtime.Stack dump by state and function frames,
+// dropping goroutine IDs, argument values, and file offsets.
+func ParseStacks(dump string) StackProfile {
+	profile := make(StackProfile)
+	for _, block := range strings.Split(strings.TrimSpace(dump), "\n\n") {
+		lines := strings.Split(block, "\n")
+		if len(lines) == 0 {
+			continue
+		}
+
+		state := "unknown"
+		if m := goroutineHeader.FindStringSubmatch(lines[0]); m != nil {
+			state = m[1]
+		}
+
+		var frames []string
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "\t") {
+				continue
+			}
+			if i := strings.Index(line, " in goroutine "); i >= 0 {
+				line = line[:i]
+			}
+			// Drop the argument list, which contains pointer values
+			if strings.HasSuffix(line, ")") {
+				if i := strings.LastIndex(line, "("); i > 0 {
+					line = line[:i]
+				}
+			}
+			frames = append(frames, line)
+		}
+		profile["["+state+"]\n"+strings.Join(frames, "\n")]++
+	}
+	return profile
+}
+
+// Total returns the number of goroutines in the profile.
+func (p StackProfile) Total() int {
+	total := 0
+	for _, n := range p {
+		total += n
+	}
+	return total
+}
+
+// Diff returns a human-readable list of stack signatures whose count grew
+// from base to p, largest growth first.
+func (p StackProfile) Diff(base StackProfile) string {
+	type growth struct {
+		signature string
+		before    int
+		after     int
+	}
+
+	var grown []growth
+	for sig, after := range p {
+		if before := base[sig]; after > before {
+			grown = append(grown, growth{sig, before, after})
+		}
+	}
+	sort.Slice(grown, func(i, j int) bool {
+		return grown[i].after-grown[i].before > grown[j].after-grown[j].before
+	})
+
+	var b strings.Builder
+	for _, g := range grown {
+		fmt.Fprintf(&b, "+%d goroutines (%d -> %d)\n%s\n\n", g.after-g.before, g.before, g.after, g.signature)
+	}
+	return b.String()
+}
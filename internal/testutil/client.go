@@ -0,0 +1,217 @@
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// Client is a scripted protocol client. Its Expect methods fail the test on
+// any other frame, so a test reads as the exchange it expects.
+type Client struct {
+	conn   net.Conn
+	reader *protocol.FrameReader
+	writer *protocol.FrameWriter
+	h      *Harness
+	t      testing.TB
+
+	// Frames are read on their own goroutine, standing in for the socket
+	// buffer, so server writes made while the clock advances never block
+	frames chan readResult
+	err    error // Read error that ended the stream
+
+	heartbeats uint64 // Sequence of the last heartbeat sent
+}
+
+// frameBuffer is how many unread frames a client holds.
+const frameBuffer = 1024
+
+type readResult struct {
+	frame *protocol.Frame
+	err   error
+}
+
+func newClient(h *Harness, conn net.Conn) *Client {
+	c := &Client{
+		conn:   conn,
+		reader: protocol.NewFrameReader(conn, h.Config.MaxMessageSize),
+		writer: protocol.NewFrameWriter(conn),
+		h:      h,
+		t:      h.t,
+		frames: make(chan readResult, frameBuffer),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *Client) readLoop() {
+	for {
+		frame, err := c.reader.ReadFrame()
+		c.frames <- readResult{frame: frame, err: err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Conn returns the client's end of the pipe.
+func (c *Client) Conn() net.Conn {
+	return c.conn
+}
+
+// Close closes the connection.
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// Send writes msg as a frame of msgType.
+func (c *Client) Send(msgType protocol.MessageType, msg proto.Message) {
+	c.t.Helper()
+	frame, err := protocol.MarshalMessage(msgType, msg)
+	require.NoError(c.t, err)
+	c.SendFrame(frame)
+}
+
+// SendFrame writes frame as is, for tests of malformed input.
+func (c *Client) SendFrame(frame *protocol.Frame) {
+	c.t.Helper()
+	c.conn.SetWriteDeadline(time.Now().Add(WaitTimeout))
+	require.NoError(c.t, c.writer.WriteFrame(frame))
+}
+
+// Next reads the next frame.
+func (c *Client) Next() *protocol.Frame {
+	c.t.Helper()
+	frame, err := c.read()
+	require.NoError(c.t, err)
+	return frame
+}
+
+func (c *Client) read() (*protocol.Frame, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	select {
+	case res := <-c.frames:
+		c.err = res.err
+		return res.frame, res.err
+	case <-time.After(WaitTimeout):
+		return nil, fmt.Errorf("no frame within %s", WaitTimeout)
+	}
+}
+
+// Expect reads the next frame, requires it to be of msgType and decodes it
+// into msg.
+func (c *Client) Expect(msgType protocol.MessageType, msg proto.Message) {
+	c.t.Helper()
+	frame := c.Next()
+	c.requireType(frame, msgType)
+	require.NoError(c.t, proto.Unmarshal(frame.Payload, msg))
+}
+
+// requireType fails unless frame is of msgType, quoting unexpected errors.
+func (c *Client) requireType(frame *protocol.Frame, msgType protocol.MessageType) {
+	c.t.Helper()
+	if frame.Type == msgType {
+		return
+	}
+	if frame.Type == protocol.MessageTypeError {
+		var resp pb.ErrorResponse
+		if proto.Unmarshal(frame.Payload, &resp) == nil {
+			c.t.Fatalf("expected frame type %v, got error %v: %s (%s)", msgType, resp.Code, resp.Message, resp.Details)
+		}
+	}
+	c.t.Fatalf("expected frame type %v, got %v", msgType, frame.Type)
+}
+
+// ExpectAck reads a successful ACK.
+func (c *Client) ExpectAck() *pb.AckResponse {
+	c.t.Helper()
+	var ack pb.AckResponse
+	c.Expect(protocol.MessageTypeACK, &ack)
+	require.True(c.t, ack.Success, "ACK reports failure: %s", ack.Message)
+	return &ack
+}
+
+// ExpectError reads an ERROR frame with code.
+func (c *Client) ExpectError(code pb.ErrorCode) *pb.ErrorResponse {
+	c.t.Helper()
+	var resp pb.ErrorResponse
+	c.Expect(protocol.MessageTypeError, &resp)
+	require.Equal(c.t, code, resp.Code, "unexpected error: %s", resp.Message)
+	return &resp
+}
+
+// ExpectClosed requires the server to have closed the connection, skipping
+// no frames on the way.
+func (c *Client) ExpectClosed() {
+	c.t.Helper()
+	frame, err := c.read()
+	if err == nil {
+		c.t.Fatalf("expected the connection to be closed, got frame type %v", frame.Type)
+	}
+	require.True(c.t, errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe), "expected close, got %v", err)
+}
+
+// Auth authenticates and requires the server to accept.
+func (c *Client) Auth(username, password string) *pb.AckResponse {
+	c.t.Helper()
+	c.Send(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username: username,
+		Password: password,
+		ClientId: "testutil",
+	})
+	return c.ExpectAck()
+}
+
+// Subscribe subscribes to symbols in mode and requires the server to
+// confirm.
+func (c *Client) Subscribe(mode pb.SubscriptionMode, symbols ...string) *pb.AckResponse {
+	c.t.Helper()
+	c.Send(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: mode, Symbols: symbols})
+	return c.ExpectAck()
+}
+
+// Heartbeat sends a heartbeat stamped with the fake clock and reads the
+// PONG.
+func (c *Client) Heartbeat() *pb.HeartbeatResponse {
+	c.t.Helper()
+	c.heartbeats++
+	c.Send(protocol.MessageTypeHeartbeat, &pb.HeartbeatRequest{
+		TimestampMs: c.h.Clock.Now().UnixMilli(),
+		Sequence:    c.heartbeats,
+	})
+	var pong pb.HeartbeatResponse
+	c.Expect(protocol.MessageTypePong, &pong)
+	require.Equal(c.t, c.heartbeats, pong.Sequence)
+	return &pong
+}
+
+// NextBatch advances the clock until the next data batch arrives and
+// returns it.
+func (c *Client) NextBatch() *pb.DataBatch {
+	c.t.Helper()
+	var frame *protocol.Frame
+	var err error
+	arrived := make(chan struct{})
+	go func() {
+		frame, err = c.read()
+		close(arrived)
+	}()
+	c.h.AdvanceUntil(arrived)
+
+	require.NoError(c.t, err)
+	c.requireType(frame, protocol.MessageTypeDataBatch)
+	var batch pb.DataBatch
+	require.NoError(c.t, proto.Unmarshal(frame.Payload, &batch))
+	return &batch
+}
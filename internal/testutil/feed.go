@@ -0,0 +1,81 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+// Feed is a data source that emits exactly the ticks a test publishes, to
+// every subscription streaming from it.
+type Feed struct {
+	mu      sync.Mutex
+	streams map[*server.Subscription]func([]*pb.Tick)
+	changed chan struct{} // Closed and replaced when a stream starts or ends
+}
+
+// NewFeed creates a feed with no streams.
+func NewFeed() *Feed {
+	return &Feed{
+		streams: make(map[*server.Subscription]func([]*pb.Tick)),
+		changed: make(chan struct{}),
+	}
+}
+
+// Stream implements server.DataSource.
+func (f *Feed) Stream(ctx context.Context, subscription *server.Subscription, emit func([]*pb.Tick)) {
+	f.update(func() { f.streams[subscription] = emit })
+	<-ctx.Done()
+	f.update(func() { delete(f.streams, subscription) })
+}
+
+func (f *Feed) update(fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn()
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Publish emits ticks to every stream as one batch and returns how many
+// streams it reached.
+func (f *Feed) Publish(ticks ...*pb.Tick) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, emit := range f.streams {
+		emit(ticks)
+	}
+	return len(f.streams)
+}
+
+// Streams returns the number of subscriptions streaming from the feed.
+func (f *Feed) Streams() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.streams)
+}
+
+// WaitForStreams waits until n subscriptions are streaming. Streams start on
+// their own goroutine after the subscription is confirmed, so tests call it
+// before publishing.
+func (f *Feed) WaitForStreams(t testing.TB, n int) {
+	t.Helper()
+	deadline := time.After(WaitTimeout)
+	for {
+		f.mu.Lock()
+		count, changed := len(f.streams), f.changed
+		f.mu.Unlock()
+		if count == n {
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("%d feed streams after %s, want %d", count, WaitTimeout, n)
+		}
+	}
+}
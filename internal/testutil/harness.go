@@ -0,0 +1,135 @@
+package testutil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+// Credentials accepted by harness servers.
+const (
+	Username = "tester"
+	Password = "secret"
+)
+
+// WaitTimeout bounds every wait on the server in wall-clock time. It only
+// catches hung tests; nothing sleeps for it.
+const WaitTimeout = 5 * time.Second
+
+// AdvanceUntil moves the clock by advanceStep, the default batch window,
+// every advancePace of wall-clock time.
+const (
+	advanceStep = 5 * time.Millisecond
+	advancePace = 100 * time.Microsecond
+)
+
+// Harness is a running server with its in-memory listener, fake clock and
+// tick feed.
+type Harness struct {
+	Server   *server.Server
+	Config   *server.Config
+	Clock    *server.FakeClock
+	Listener *PipeListener
+	Feed     *Feed
+
+	t testing.TB
+}
+
+// Start runs a server for the duration of the test. The default
+// configuration authenticates Username and Password, streams ticks from Feed
+// and disables TLS and the ops server; configure adjusts it before the
+// server starts.
+func Start(t testing.TB, configure ...func(*server.Config)) *Harness {
+	t.Helper()
+	h := &Harness{
+		Clock:    server.NewFakeClock(time.Now()),
+		Listener: NewPipeListener(),
+		Feed:     NewFeed(),
+		t:        t,
+	}
+
+	config := server.DefaultConfig()
+	config.Clock = h.Clock
+	config.Listener = h.Listener
+	config.DataSource = h.Feed
+	config.TLS = nil
+	config.OpsListenAddr = ""
+	config.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	config.Auth = &auth.Config{
+		Username:        Username,
+		Password:        Password,
+		Timeout:         config.AuthTimeout,
+		MaxAttempts:     3,
+		RateLimitWindow: time.Minute,
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+	h.Config = config
+
+	h.Server = server.NewServer(config)
+	require.NoError(t, h.Server.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), WaitTimeout)
+		defer cancel()
+		h.Server.Stop(ctx)
+	})
+	return h
+}
+
+// Dial connects a new client. It is closed when the test ends.
+func (h *Harness) Dial() *Client {
+	h.t.Helper()
+	conn, err := h.Listener.Dial()
+	require.NoError(h.t, err)
+	c := newClient(h, conn)
+	h.t.Cleanup(c.Close)
+	return c
+}
+
+// Connect dials a client and authenticates it with the harness credentials.
+func (h *Harness) Connect() *Client {
+	h.t.Helper()
+	c := h.Dial()
+	c.Auth(Username, Password)
+	return c
+}
+
+// Advance moves the fake clock forward by d, firing due timers.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// AdvanceUntil moves the clock forward in small steps until done is closed.
+// It is for work the server schedules asynchronously, such as batch flushes.
+// Each step waits briefly for done in wall-clock time, so server goroutines
+// that poll on real time keep up with the fake clock.
+func (h *Harness) AdvanceUntil(done <-chan struct{}) {
+	for {
+		h.Clock.Advance(advanceStep)
+		select {
+		case <-done:
+			return
+		case <-time.After(advancePace):
+		}
+	}
+}
+
+// Tick returns a tick for symbol in mode, stamped with the fake clock.
+func (h *Harness) Tick(symbol string, mode pb.SubscriptionMode, price float64) *pb.Tick {
+	return &pb.Tick{
+		Symbol:      symbol,
+		Price:       price,
+		Volume:      1,
+		TimestampMs: h.Clock.Now().UnixMilli(),
+		Mode:        mode,
+	}
+}
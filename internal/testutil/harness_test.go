@@ -0,0 +1,66 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/server"
+)
+
+const second = pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+
+func TestHarnessAuthRejectsWrongPassword(t *testing.T) {
+	h := Start(t)
+	c := h.Dial()
+	c.Send(protocol.MessageTypeAuth, &pb.AuthRequest{Username: Username, Password: "wrong"})
+	c.ExpectError(pb.ErrorCode_ERROR_CODE_INVALID_AUTH)
+
+	h.Connect().Heartbeat()
+}
+
+func TestHarnessStreamsPublishedTicksInBatches(t *testing.T) {
+	h := Start(t, func(c *server.Config) { c.MaxBatchSize = 3 })
+	c := h.Connect()
+	c.Subscribe(second, "EURUSD", "GBPUSD")
+	h.Feed.WaitForStreams(t, 1)
+
+	// A full batch is flushed at once; the rest waits for the batch window
+	h.Feed.Publish(h.Tick("EURUSD", second, 1.1), h.Tick("USDJPY", second, 150), h.Tick("GBPUSD", second, 1.3), h.Tick("EURUSD", second, 1.2))
+	h.Feed.Publish(h.Tick("GBPUSD", second, 1.4))
+	batch := c.NextBatch()
+	require.Len(t, batch.Ticks, 3, "USDJPY is filtered out")
+	assert.Equal(t, []string{"EURUSD", "GBPUSD", "EURUSD"}, []string{batch.Ticks[0].Symbol, batch.Ticks[1].Symbol, batch.Ticks[2].Symbol})
+
+	batch = c.NextBatch()
+	require.Len(t, batch.Ticks, 1)
+	assert.Equal(t, 1.4, batch.Ticks[0].Price)
+}
+
+func TestHarnessHeartbeatTimeout(t *testing.T) {
+	h := Start(t)
+	c := h.Connect()
+	c.Subscribe(second, "EURUSD")
+
+	h.Advance(h.Config.HeartbeatTimeout - 1)
+	c.Heartbeat()
+	h.Advance(h.Config.HeartbeatTimeout - 1)
+	c.Heartbeat()
+
+	h.Advance(h.Config.HeartbeatTimeout)
+	c.ExpectError(pb.ErrorCode_ERROR_CODE_HEARTBEAT_TIMEOUT)
+	c.ExpectClosed()
+}
+
+func TestHarnessSeparatesClients(t *testing.T) {
+	h := Start(t, func(c *server.Config) { c.MaxConnsPerIP = 1 })
+	first := h.Connect()
+	first.Subscribe(second, "EURUSD")
+
+	second := h.Connect()
+	second.Heartbeat()
+	assert.NotEqual(t, first.Conn().LocalAddr().String(), second.Conn().LocalAddr().String())
+}
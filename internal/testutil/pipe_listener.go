@@ -0,0 +1,149 @@
+// Package testutil runs a complete server in memory for end-to-end tests:
+// clients connect over net.Pipe instead of sockets, and a fake clock drives
+// heartbeats, batching and tick generation, so tests need no sleeps.
+package testutil
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// firstClientPort is the source port given to the first pipe client.
+const firstClientPort = 40000
+
+// PipeListener is a net.Listener whose connections are in-memory pipes
+// created by Dial.
+type PipeListener struct {
+	conns   chan net.Conn
+	closed  chan struct{}
+	once    sync.Once
+	clients atomic.Uint32
+	addr    *net.TCPAddr
+}
+
+// NewPipeListener creates a listener that appears to listen on 127.0.0.1.
+func NewPipeListener() *PipeListener {
+	return &PipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+		addr:   &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080},
+	}
+}
+
+// Accept waits for the next Dial.
+func (l *PipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops Accept and Dial.
+func (l *PipeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr returns the address the listener appears to listen on.
+func (l *PipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+// Dial connects a new client, returning its end once the server has
+// accepted the other. Each client gets its own address in 10.0.0.0/8, so
+// per-IP limits see them as different hosts.
+func (l *PipeListener) Dial() (net.Conn, error) {
+	n := l.clients.Add(1)
+	remote := &net.TCPAddr{IP: net.IPv4(10, byte(n>>16), byte(n>>8), byte(n)), Port: firstClientPort}
+	serverSide, clientSide := net.Pipe()
+	select {
+	case l.conns <- &pipeConn{Conn: serverSide, local: l.addr, remote: remote}:
+		return newBufferedConn(&pipeConn{Conn: clientSide, local: remote, remote: l.addr}), nil
+	case <-l.closed:
+		serverSide.Close()
+		clientSide.Close()
+		return nil, net.ErrClosed
+	}
+}
+
+// pipeConn gives a pipe end TCP addresses, which the server needs for IP
+// filtering and per-IP limits.
+type pipeConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+// bufferedConn gives the client end a send buffer. Like a socket, it can
+// write bytes the server has not read yet, such as the rest of a frame the
+// server rejected from its header.
+type bufferedConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	ready   *sync.Cond
+	pending [][]byte
+	err     error // Set when the pipe fails or the conn is closed
+}
+
+func newBufferedConn(conn net.Conn) *bufferedConn {
+	c := &bufferedConn{Conn: conn}
+	c.ready = sync.NewCond(&c.mu)
+	go c.flush()
+	return c
+}
+
+// Write queues b for the server without blocking.
+func (c *bufferedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.pending = append(c.pending, append([]byte(nil), b...))
+	c.ready.Signal()
+	return len(b), nil
+}
+
+// Close discards unsent bytes and closes the pipe.
+func (c *bufferedConn) Close() error {
+	c.fail(net.ErrClosed)
+	return c.Conn.Close()
+}
+
+func (c *bufferedConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.pending = nil
+	c.ready.Signal()
+}
+
+// flush writes queued bytes to the pipe as the server reads them.
+func (c *bufferedConn) flush() {
+	for {
+		c.mu.Lock()
+		for len(c.pending) == 0 && c.err == nil {
+			c.ready.Wait()
+		}
+		if c.err != nil {
+			c.mu.Unlock()
+			return
+		}
+		b := c.pending[0]
+		c.pending = c.pending[1:]
+		c.mu.Unlock()
+
+		if _, err := c.Conn.Write(b); err != nil {
+			c.fail(err)
+			return
+		}
+	}
+}
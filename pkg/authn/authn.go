@@ -0,0 +1,78 @@
+// Package authn defines the interface for plugging custom authentication
+// backends, such as LDAP or OAuth token introspection, into Tick-Storm.
+package authn
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+)
+
+var (
+	// ErrInvalidCredentials rejects the client with INVALID_AUTH and counts
+	// towards its per-IP attempt limit.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrRateLimited rejects the client with RATE_LIMITED.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrUnavailable reports that the backend could not decide, for example
+	// because a directory server is down. The client gets SERVER_BUSY with a
+	// retry hint and the attempt is not counted as a failure.
+	ErrUnavailable = errors.New("authentication backend unavailable")
+)
+
+// ConnMeta describes the connection an AUTH request arrived on.
+type ConnMeta struct {
+	RemoteAddr string
+	LocalAddr  string
+
+	// ServerName is the TLS SNI host the client connected to; empty for
+	// plaintext connections.
+	ServerName string
+
+	// PeerCertificates is the client's verified certificate chain when mutual
+	// TLS is enabled.
+	PeerCertificates []*x509.Certificate
+}
+
+// Request is a client's AUTH request.
+type Request struct {
+	Username string
+	Password string
+	ClientID string
+	Version  string // Client software version
+}
+
+// Session is the identity an Authenticator grants.
+type Session struct {
+	// Username selects the client's priority class and tenant.
+	Username string
+
+	// ClientID identifies the client for delivery retention and resume;
+	// empty keeps the ID the client sent.
+	ClientID string
+
+	// Attributes carries backend-specific claims, such as groups or scopes,
+	// for use by custom frame handlers.
+	Attributes map[string]string
+}
+
+// Authenticator verifies AUTH requests. Implementations must be safe for
+// concurrent use and should honour ctx, which is cancelled when the
+// connection's authentication deadline passes or the server shuts down.
+//
+// Errors wrapping ErrInvalidCredentials, ErrRateLimited or ErrUnavailable are
+// reported to the client with the matching code; any other error is treated
+// as invalid credentials.
+type Authenticator interface {
+	Authenticate(ctx context.Context, meta ConnMeta, req Request) (Session, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(ctx context.Context, meta ConnMeta, req Request) (Session, error)
+
+// Authenticate calls f.
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, meta ConnMeta, req Request) (Session, error) {
+	return f(ctx, meta, req)
+}
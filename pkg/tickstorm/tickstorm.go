@@ -0,0 +1,340 @@
+// Package tickstorm embeds the Tick-Storm streaming engine in other Go
+// services.
+//
+// A Server is configured with options and runs the same protocol, TLS,
+// authentication and delivery pipeline as the standalone binary:
+//
+//	srv := tickstorm.New(
+//		tickstorm.WithListenAddr(":9000"),
+//		tickstorm.WithDataSource(feed),
+//	)
+//	if err := srv.Start(); err != nil { ... }
+//	defer srv.Shutdown(ctx)
+//
+// Environment variables recognized by the standalone server are still read
+// when the Server is created and override the options they configure.
+package tickstorm
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/furkansarikaya/tick-storm/internal/auth"
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+	"github.com/furkansarikaya/tick-storm/internal/server"
+	"github.com/furkansarikaya/tick-storm/pkg/authn"
+)
+
+// Server is a Tick-Storm server. Start begins accepting connections and
+// Shutdown drains them.
+type Server = server.Server
+
+// Config is the full server configuration, for settings without an option.
+type Config = server.Config
+
+// Protocol and data types.
+type (
+	Tick             = pb.Tick
+	SymbolInfo       = pb.SymbolInfo
+	StreamStatus     = pb.StreamStatus
+	StreamState      = pb.StreamState
+	SubscriptionMode = pb.SubscriptionMode
+	Subscription     = server.Subscription
+	Frame            = protocol.Frame
+	MessageType      = protocol.MessageType
+)
+
+// Subscription modes.
+const (
+	ModeSecond = pb.SubscriptionMode_SUBSCRIPTION_MODE_SECOND
+	ModeMinute = pb.SubscriptionMode_SUBSCRIPTION_MODE_MINUTE
+)
+
+// Stream states reported by a StreamStatusSource.
+const (
+	StreamLive   = pb.StreamState_STREAM_STATE_LIVE
+	StreamHalted = pb.StreamState_STREAM_STATE_HALTED
+	StreamStale  = pb.StreamState_STREAM_STATE_STALE
+)
+
+// Extension points.
+type (
+	// DataSource produces the ticks streamed to each subscription.
+	DataSource = server.DataSource
+
+	// SymbolDirectory is optionally implemented by a DataSource to answer
+	// SYMBOL_LIST requests with reference data.
+	SymbolDirectory = server.SymbolDirectory
+
+	// StreamStatusSource is optionally implemented by a DataSource to report
+	// symbol halts, resumes and stale data to subscribers.
+	StreamStatusSource = server.StreamStatusSource
+
+	// Authenticator verifies AUTH requests against a custom backend; see
+	// WithAuthenticator.
+	Authenticator = authn.Authenticator
+
+	// AuthenticatorFunc adapts a function to the Authenticator interface.
+	AuthenticatorFunc = authn.AuthenticatorFunc
+
+	// ConnMeta, AuthRequest and AuthSession are an Authenticator's inputs
+	// and result.
+	ConnMeta    = authn.ConnMeta
+	AuthRequest = authn.Request
+	AuthSession = authn.Session
+
+	// AuthConfig configures the built-in username/password authentication.
+	AuthConfig = auth.Config
+
+	// FrameHandler handles a custom message type; see WithFrameHandler.
+	FrameHandler = server.FrameHandler
+
+	// FrameMiddleware wraps every frame handler; see WithMiddleware.
+	FrameMiddleware = server.FrameMiddleware
+
+	// ConnectionHandler is the per-connection state passed to frame handlers.
+	ConnectionHandler = server.ConnectionHandler
+
+	// Connection is a client connection, passed to lifecycle hooks.
+	Connection = server.Connection
+
+	// Lifecycle hooks; see WithOnConnect, WithOnAuthenticated, WithOnSubscribe
+	// and WithOnDisconnect.
+	ConnectHook       = server.ConnectHook
+	AuthenticatedHook = server.AuthenticatedHook
+	SubscribeHook     = server.SubscribeHook
+	DisconnectHook    = server.DisconnectHook
+
+	// UsageRecord is one user's usage over an accounting period.
+	UsageRecord = server.UsageRecord
+
+	// UsageExporter ships usage records to a billing pipeline.
+	UsageExporter = server.UsageExporter
+
+	// TradingSession describes when one symbol group trades; see
+	// WithTradingSessions.
+	TradingSession = server.TradingSession
+
+	// SimulationConfig configures the built-in market simulator; see
+	// WithSimulation.
+	SimulationConfig = server.SimulationConfig
+	SimSymbol        = server.SimSymbol
+
+	// ReplayConfig replays recorded ticks; see WithReplay. ReplayDecoder
+	// reads recordings in formats other than CSV, such as Parquet.
+	ReplayConfig  = server.ReplayConfig
+	ReplayDecoder = server.ReplayDecoder
+
+	// IngestConfig lets producers push ticks over PUBLISH frames and
+	// POST /ingest; see WithIngest.
+	IngestConfig   = server.IngestConfig
+	ProducerConfig = server.ProducerConfig
+
+	// ValidationLimits bounds symbol counts, batch sizes, prices, volumes
+	// and timestamps; see WithValidationLimits.
+	ValidationLimits = protocol.Limits
+)
+
+// DefaultValidationLimits returns the built-in validation limits for
+// further tuning.
+func DefaultValidationLimits() ValidationLimits {
+	return protocol.DefaultLimits()
+}
+
+// NewSimulationConfig returns the named simulator scenario ("calm",
+// "volatile", "mean-reverting" or "gappy") for further tuning.
+func NewSimulationConfig(scenario string) (*SimulationConfig, error) {
+	return server.NewSimulationConfig(scenario)
+}
+
+// Policies for ticks outside their trading session.
+const (
+	SessionPolicyPause = server.SessionPolicyPause
+	SessionPolicyFlag  = server.SessionPolicyFlag
+)
+
+// ErrVetoed wraps the error a lifecycle hook rejected a client with.
+var ErrVetoed = server.ErrVetoed
+
+// Errors an Authenticator returns to reject a client.
+var (
+	ErrInvalidCredentials = authn.ErrInvalidCredentials
+	ErrRateLimited        = authn.ErrRateLimited
+	ErrAuthUnavailable    = authn.ErrUnavailable
+)
+
+// DefaultConfig returns the configuration New starts from.
+func DefaultConfig() *Config {
+	return server.DefaultConfig()
+}
+
+// Option configures a Server created by New.
+type Option func(*options)
+
+type options struct {
+	config     *Config
+	handlers   []frameHandler
+	middleware []FrameMiddleware
+	hooks      []func(*server.Hooks)
+}
+
+type frameHandler struct {
+	msgType MessageType
+	name    string
+	fn      FrameHandler
+}
+
+// New creates a Server from the default configuration and opts.
+func New(opts ...Option) *Server {
+	o := &options{config: server.DefaultConfig()}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	srv := server.NewServer(o.config)
+	for _, h := range o.handlers {
+		srv.Dispatcher().Handle(h.msgType, h.name, h.fn)
+	}
+	srv.Dispatcher().Use(o.middleware...)
+	for _, register := range o.hooks {
+		register(srv.Hooks())
+	}
+	return srv
+}
+
+// WithConfig replaces the configuration options are applied to. Options
+// after it modify cfg.
+func WithConfig(cfg *Config) Option {
+	return func(o *options) { o.config = cfg }
+}
+
+// WithListenAddr sets the address the default listener binds.
+func WithListenAddr(addr string) Option {
+	return func(o *options) { o.config.ListenAddr = addr }
+}
+
+// WithListener serves the default listener on l instead of binding
+// ListenAddr. TLS and PROXY protocol settings still apply on top of l, and
+// the server closes l on shutdown.
+func WithListener(l net.Listener) Option {
+	return func(o *options) {
+		o.config.Listener = l
+		o.config.ListenAddr = l.Addr().String()
+	}
+}
+
+// WithDataSource streams ticks from src instead of synthetic data.
+func WithDataSource(src DataSource) Option {
+	return func(o *options) { o.config.DataSource = src }
+}
+
+// WithAuthConfig sets the built-in credentials, replacing STREAM_USER and
+// STREAM_PASS.
+func WithAuthConfig(cfg *AuthConfig) Option {
+	return func(o *options) { o.config.Auth = cfg }
+}
+
+// WithAuthenticator verifies clients with a custom backend instead of the
+// built-in credentials. Per-IP rate limiting still applies.
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *options) { o.config.Authenticator = a }
+}
+
+// WithLogger sets the logger for the server and its subsystems.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) { o.config.Logger = logger }
+}
+
+// WithoutTLS serves plaintext TCP, for use behind a TLS-terminating proxy or
+// in tests.
+func WithoutTLS() Option {
+	return func(o *options) { o.config.TLS = nil }
+}
+
+// WithFrameHandler handles frames of a custom message type. name labels the
+// type in metrics and logs.
+func WithFrameHandler(msgType MessageType, name string, fn FrameHandler) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, frameHandler{msgType: msgType, name: name, fn: fn})
+	}
+}
+
+// WithMiddleware wraps every frame handler, inside the built-in tracing,
+// metrics and rate-limiting middleware.
+func WithMiddleware(mw ...FrameMiddleware) Option {
+	return func(o *options) { o.middleware = append(o.middleware, mw...) }
+}
+
+// WithOnConnect calls fn for each accepted connection, before authentication.
+// An error closes the connection.
+func WithOnConnect(fn ConnectHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, func(h *server.Hooks) { h.OnConnect(fn) })
+	}
+}
+
+// WithOnAuthenticated calls fn once a client's credentials are verified. An
+// error rejects the client, for example to enforce a custom quota.
+func WithOnAuthenticated(fn AuthenticatedHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, func(h *server.Hooks) { h.OnAuthenticated(fn) })
+	}
+}
+
+// WithOnSubscribe calls fn before each subscription takes effect. An error
+// rejects the subscription.
+func WithOnSubscribe(fn SubscribeHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, func(h *server.Hooks) { h.OnSubscribe(fn) })
+	}
+}
+
+// WithOnDisconnect calls fn when a connection ends.
+func WithOnDisconnect(fn DisconnectHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, func(h *server.Hooks) { h.OnDisconnect(fn) })
+	}
+}
+
+// WithUsageExporter closes a usage accounting period every interval and
+// passes its per-user records to exp.
+func WithUsageExporter(interval time.Duration, exp UsageExporter) Option {
+	return func(o *options) {
+		o.config.UsageInterval = interval
+		o.config.UsageExporter = exp
+	}
+}
+
+// WithIngest broadcasts ticks published by producers when no DataSource is
+// set.
+func WithIngest(ingest *IngestConfig) Option {
+	return func(o *options) { o.config.Ingest = ingest }
+}
+
+// WithReplay streams recorded ticks from replay when no DataSource is set.
+func WithReplay(replay *ReplayConfig) Option {
+	return func(o *options) { o.config.Replay = replay }
+}
+
+// WithSimulation streams simulated market data from sim when no DataSource
+// is set.
+func WithSimulation(sim *SimulationConfig) Option {
+	return func(o *options) { o.config.Simulation = sim }
+}
+
+// WithValidationLimits replaces the built-in validation limits, e.g. to
+// admit crypto prices and volumes. Limits apply to the whole process.
+func WithValidationLimits(limits ValidationLimits) Option {
+	return func(o *options) { o.config.ValidationLimits = &limits }
+}
+
+// WithTradingSessions restricts each symbol group to its trading hours.
+// Ticks outside them are dropped or flagged according to policy.
+func WithTradingSessions(policy string, sessions ...TradingSession) Option {
+	return func(o *options) {
+		o.config.SessionPolicy = policy
+		o.config.TradingSessions = sessions
+	}
+}
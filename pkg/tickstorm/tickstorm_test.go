@@ -0,0 +1,84 @@
+package tickstorm
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/furkansarikaya/tick-storm/internal/protocol"
+	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
+)
+
+// feed emits one tick per subscription and then waits.
+type feed struct{}
+
+func (feed) Stream(ctx context.Context, sub *Subscription, emit func([]*Tick)) {
+	emit([]*Tick{{Symbol: "ACME", Price: 42, TimestampMs: time.Now().UnixMilli(), Mode: sub.Mode}})
+	<-ctx.Done()
+}
+
+func TestEmbeddedServerStreamsFromDataSource(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	custom := make(chan string, 1)
+	srv := New(
+		WithListener(ln),
+		WithoutTLS(),
+		WithDataSource(feed{}),
+		WithAuthConfig(&AuthConfig{
+			Username:        "embed",
+			Password:        "secret",
+			MaxAttempts:     3,
+			RateLimitWindow: time.Minute,
+		}),
+		WithFrameHandler(0x40, "custom", func(_ context.Context, _ *ConnectionHandler, f *Frame) error {
+			custom <- string(f.Payload)
+			return nil
+		}),
+	)
+	require.NoError(t, srv.Start())
+	defer srv.Stop(context.Background())
+	assert.Equal(t, ln.Addr().String(), srv.ListenAddr())
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := protocol.NewFrameReader(conn, protocol.DefaultMaxMessageSize)
+	writer := protocol.NewFrameWriter(conn)
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{Username: "embed", Password: "secret", ClientId: "embed-test"})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteFrame(frame))
+	resp, err := reader.ReadFrame()
+	require.NoError(t, err)
+	require.Equal(t, protocol.MessageTypeACK, resp.Type, "authenticated by the configured credentials")
+
+	require.NoError(t, writer.WriteFrame(&protocol.Frame{Version: protocol.ProtocolVersion, Type: 0x40, Payload: []byte("hello")}))
+	select {
+	case payload := <-custom:
+		assert.Equal(t, "hello", payload)
+	case <-time.After(2 * time.Second):
+		t.Fatal("custom frame handler was not called")
+	}
+
+	frame, err = protocol.MarshalMessage(protocol.MessageTypeSubscribe, &pb.SubscribeRequest{Mode: ModeSecond})
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteFrame(frame))
+	for {
+		resp, err = reader.ReadFrame()
+		require.NoError(t, err)
+		if resp.Type == protocol.MessageTypeDataBatch {
+			break
+		}
+	}
+	var batch pb.DataBatch
+	require.NoError(t, protocol.UnmarshalMessage(resp, &batch))
+	require.Len(t, batch.Ticks, 1)
+	assert.Equal(t, "ACME", batch.Ticks[0].Symbol)
+}
@@ -19,11 +19,11 @@ GIT_COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo "unknown")
 GO_VERSION=$(go version | cut -d' ' -f3)
 
 # Build flags for static binary
+BUILDINFO="github.com/furkansarikaya/tick-storm/internal/buildinfo"
 LDFLAGS="-s -w \
-    -X 'main.Version=${VERSION}' \
-    -X 'main.BuildTime=${BUILD_TIME}' \
-    -X 'main.GitCommit=${GIT_COMMIT}' \
-    -X 'main.GoVersion=${GO_VERSION}'"
+    -X '${BUILDINFO}.Version=${VERSION}' \
+    -X '${BUILDINFO}.Commit=${GIT_COMMIT}' \
+    -X '${BUILDINFO}.BuildDate=${BUILD_TIME}'"
 
 echo -e "${GREEN}Building ${BINARY_NAME} v${VERSION}...${NC}"
 echo "  Git Commit: ${GIT_COMMIT}"
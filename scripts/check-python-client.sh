@@ -0,0 +1,71 @@
+#!/usr/bin/env bash
+
+# Checks the Python reference client against a real server: the server is
+# first run through the conformance suite, then the client's tests run
+# against it, including the live ones.
+
+set -euo pipefail
+
+GREEN='\033[0;32m'
+RED='\033[0;31m'
+NC='\033[0m' # No Color
+
+ROOT=$(cd "$(dirname "$0")/.." && pwd)
+PORT=${PORT:-18080}
+HEARTBEAT_TIMEOUT=${HEARTBEAT_TIMEOUT:-3s}
+export STREAM_USER=${STREAM_USER:-conformance}
+export STREAM_PASS=${STREAM_PASS:-conformance-secret}
+
+WORKDIR=$(mktemp -d)
+SERVER_PID=""
+
+stop_server() {
+    if [ -n "${SERVER_PID}" ]; then
+        kill "${SERVER_PID}" 2>/dev/null || true
+        wait "${SERVER_PID}" 2>/dev/null || true
+        SERVER_PID=""
+    fi
+}
+cleanup() {
+    stop_server
+    rm -rf "${WORKDIR}"
+}
+trap cleanup EXIT
+
+# Each phase gets a fresh server: the server accepts only 10 connections a
+# minute from one IP, and both phases connect from 127.0.0.1.
+start_server() {
+    LISTEN_ADDR="127.0.0.1:${PORT}" OPS_LISTEN_ADDR="" HEARTBEAT_TIMEOUT="${HEARTBEAT_TIMEOUT}" \
+        "${WORKDIR}/tick-storm" > "${WORKDIR}/server.log" 2>&1 &
+    SERVER_PID=$!
+    for _ in $(seq 50); do
+        if grep -q "server listening" "${WORKDIR}/server.log"; then
+            return
+        fi
+        sleep 0.1
+    done
+    echo -e "${RED}Server did not start${NC}"
+    cat "${WORKDIR}/server.log"
+    exit 1
+}
+
+fail() {
+    echo -e "${RED}$1; server errors:${NC}"
+    grep -v "level=INFO" "${WORKDIR}/server.log" | tail -20
+    exit 1
+}
+
+cd "${ROOT}"
+go build -o "${WORKDIR}/tick-storm" ./cmd/server
+
+echo -e "${GREEN}Running conformance suite...${NC}"
+start_server
+go run ./cmd/conformance -addr "127.0.0.1:${PORT}" -heartbeat-timeout "${HEARTBEAT_TIMEOUT}" \
+    || fail "Server failed conformance"
+stop_server
+
+echo -e "${GREEN}Running Python client tests...${NC}"
+start_server
+(cd clients/python && TICKSTORM_ADDR="127.0.0.1:${PORT}" python3 -m unittest discover -s tests -v) \
+    || fail "Python client tests failed"
+echo -e "${GREEN}✓ Python client checked against a conforming server${NC}"
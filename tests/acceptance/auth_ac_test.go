@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/furkansarikaya/tick-storm/internal/auth"
 	"github.com/furkansarikaya/tick-storm/internal/server"
 	"github.com/furkansarikaya/tick-storm/internal/protocol"
 	pb "github.com/furkansarikaya/tick-storm/internal/protocol/pb"
@@ -137,6 +138,10 @@ func TestAC1_ValidCredentialsAccepted(t *testing.T) {
 	require.NoError(t, protocol.UnmarshalMessage(resp, &ack))
 	require.True(t, ack.Success)
 	require.Equal(t, pb.MessageType_MESSAGE_TYPE_AUTH, ack.AckType)
+	require.Equal(t, s.GetVersion(), ack.Metadata["server_version"])
+	require.NotEmpty(t, ack.Metadata["server_commit"])
+	require.Equal(t, s.AffinityToken(), ack.Metadata["affinity_token"])
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, ack.Metadata["connection_id"])
 }
 
 // AC-1: Duplicate AUTH on the same connection should return ALREADY_AUTHENTICATED
@@ -186,3 +191,37 @@ func TestAC1_DuplicateAuthOnSameConnection(t *testing.T) {
     statsAfter := s.GetStats()
     require.EqualValues(t, prevFailures+1, statsAfter["auth_failures"])
 }
+
+// AC-1: Challenge-response auth succeeds without the password crossing the wire.
+func TestAC1_ChallengeResponseAccepted(t *testing.T) {
+	setCreds(t, "hmac_user", "hmac_pass")
+	s, addr := startTestServer(t)
+	defer func() { _ = s.Stop(context.Background()) }()
+
+	conn := dial(t, addr)
+	defer conn.Close()
+
+	frame, err := protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username:  "hmac_user",
+		Mechanism: protocol.AuthMechanismHMACSHA256,
+	})
+	require.NoError(t, err)
+	writeFrame(t, conn, frame)
+
+	resp := readFrame(t, conn)
+	require.Equal(t, protocol.MessageTypeAuthChallenge, resp.Type)
+	var challenge pb.AuthChallenge
+	require.NoError(t, protocol.UnmarshalMessage(resp, &challenge))
+	require.Len(t, challenge.Nonce, protocol.AuthNonceSize)
+
+	frame, err = protocol.MarshalMessage(protocol.MessageTypeAuth, &pb.AuthRequest{
+		Username:  "hmac_user",
+		Mechanism: protocol.AuthMechanismHMACSHA256,
+		Response:  auth.ChallengeResponse("hmac_pass", challenge.Nonce),
+	})
+	require.NoError(t, err)
+	writeFrame(t, conn, frame)
+
+	resp = readFrame(t, conn)
+	require.Equal(t, protocol.MessageTypeACK, resp.Type)
+}